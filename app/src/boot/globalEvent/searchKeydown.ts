@@ -1,6 +1,3 @@
-/// #if !BROWSER
-import * as path from "path";
-/// #endif
 import {matchHotKey} from "../../protyle/util/hotKey";
 import {fetchPost} from "../../util/fetch";
 import {Constants} from "../../constants";
@@ -9,8 +6,7 @@ import {App} from "../../index";
 import {Dialog} from "../../dialog";
 import {getAllModels} from "../../layout/getAll";
 import {hasClosestByClassName} from "../../protyle/util/hasClosest";
-import {getArticle, inputEvent, openSearchEditor, replace} from "../../search/util";
-import {useShell} from "../../util/pathName";
+import {getArticle, inputEvent, openAssetSearchResult, openSearchEditor, replace} from "../../search/util";
 import {assetInputEvent, renderPreview} from "../../search/assets";
 import {initSearchMenu} from "../../menus/search";
 import {writeText} from "../../protyle/util/compatibility";
@@ -229,9 +225,7 @@ export const searchKeydown = (app: App, event: KeyboardEvent) => {
                 });
             }
         } else {
-            /// #if !BROWSER
-            useShell("showItemInFolder", path.join(window.siyuan.config.system.dataDir, currentList.lastElementChild.getAttribute("aria-label")));
-            /// #endif
+            openAssetSearchResult(app, currentList);
         }
         return true;
     }
@@ -19,6 +19,30 @@ import {afterLoadPlugin, loadPlugin, loadPlugins} from "../plugin/loader";
 import {useShell} from "../util/pathName";
 import {switchSettingPanelSubTab} from "./setting/mount";
 
+/** 内核插件权限批准提示，未声明权限清单时返回空字符串 */
+const genPluginPermissionsTip = (permissions?: IPluginPermissions) => {
+    if (!permissions) {
+        return "";
+    }
+    const items: string[] = [];
+    if (permissions.readonly) {
+        items.push("readonly");
+    }
+    if (permissions.api?.length > 0) {
+        items.push(`api: ${permissions.api.join(", ")}`);
+    }
+    if (permissions.storage?.length > 0) {
+        items.push(`storage: ${permissions.storage.join(", ")}`);
+    }
+    if (permissions.network) {
+        items.push("network");
+    }
+    if (permissions.mcp) {
+        items.push("mcp");
+    }
+    return window.siyuan.languages.pluginPermissionsTip.replace("${x}", escapeHtml(items.join("; ") || "-"));
+};
+
 /** 集市 Tab 侧栏 / 全局搜索索引文案 */
 export const collectBazaarTabSearchStrings = (): string[] => [
     window.siyuan.languages.bazaar,
@@ -866,10 +890,12 @@ type="checkbox">
                                 if (window.siyuan.config.bazaar.petalDisabled) {
                                     confirmDialog(window.siyuan.languages.confirm, window.siyuan.languages.enablePluginTip2);
                                 } else {
-                                    confirmDialog("💡 " + window.siyuan.languages.enablePlugin, window.siyuan.languages.enablePluginTip, () => {
+                                    const permissions: IPluginPermissions = response.data.permissions;
+                                    confirmDialog("💡 " + window.siyuan.languages.enablePlugin, window.siyuan.languages.enablePluginTip + (permissions ? "<br><br>" + genPluginPermissionsTip(permissions) : ""), () => {
                                         fetchPost("/api/petal/setPetalEnabled", {
                                             packageName: pkgItem.name,
                                             enabled: true,
+                                            approvePermissions: !!permissions,
                                             app: Constants.SIYUAN_APPID,
                                         }, (response) => {
                                             loadPlugin(app, response.data).then(() => {
@@ -1040,30 +1066,43 @@ type="checkbox">
                     if (!target.hasAttribute("disabled")) {
                         target.setAttribute("disabled", "disabled");
                         const enabled = (target as HTMLInputElement).checked;
-                        fetchPost("/api/petal/setPetalEnabled", {
-                            packageName: pkgItem.name,
-                            enabled,
-                            app: Constants.SIYUAN_APPID,
-                        }, (response) => {
-                            target.removeAttribute("disabled");
-                            if (enabled) {
-                                if (window.siyuan.config.bazaar.petalDisabled) {
+                        const setPetalEnabled = (approvePermissions: boolean) => {
+                            fetchPost("/api/petal/setPetalEnabled", {
+                                packageName: pkgItem.name,
+                                enabled,
+                                approvePermissions,
+                                app: Constants.SIYUAN_APPID,
+                            }, (response) => {
+                                target.removeAttribute("disabled");
+                                if (enabled) {
+                                    if (window.siyuan.config.bazaar.petalDisabled) {
+                                        target.parentElement.querySelector('[data-type="setting"]')?.classList.add("fn__none");
+                                        return;
+                                    }
+                                    loadPlugin(app, response.data).then(() => {
+                                        this._genMyHTML("plugins", app, false);
+                                    });
+                                } else {
+                                    uninstall(app, pkgItem.name, true);
                                     target.parentElement.querySelector('[data-type="setting"]')?.classList.add("fn__none");
-                                    return;
-                                }
-                                loadPlugin(app, response.data).then(() => {
-                                    this._genMyHTML("plugins", app, false);
-                                });
-                            } else {
-                                uninstall(app, pkgItem.name, true);
-                                target.parentElement.querySelector('[data-type="setting"]')?.classList.add("fn__none");
-                                const disableTip = target.getAttribute("data-disabletip");
-                                if (disableTip) {
-                                    target.setAttribute("disabled", "disabled");
-                                    target.setAttribute("aria-label", disableTip);
+                                    const disableTip = target.getAttribute("data-disabletip");
+                                    if (disableTip) {
+                                        target.setAttribute("disabled", "disabled");
+                                        target.setAttribute("aria-label", disableTip);
+                                    }
                                 }
-                            }
-                        });
+                            });
+                        };
+                        if (enabled && pkgItem.permissions) {
+                            confirmDialog("💡 " + window.siyuan.languages.enablePlugin, genPluginPermissionsTip(pkgItem.permissions), () => {
+                                setPetalEnabled(true);
+                            }, () => {
+                                (target as HTMLInputElement).checked = false;
+                                target.removeAttribute("disabled");
+                            });
+                        } else {
+                            setPetalEnabled(false);
+                        }
                     }
                     event.stopPropagation();
                     break;
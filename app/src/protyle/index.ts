@@ -187,6 +187,11 @@ export class Protyle {
                         case "transactions":
                             this.onTransaction(data);
                             break;
+                        case "undoState":
+                            // 文档被同步或回滚后内核清理了撤销日志
+                            syncMirrorFromBroadcast(data.data);
+                            refreshUndoButtons(this.protyle);
+                            break;
                         case "readonly":
                             window.siyuan.config.editor.readOnly = data.data;
                             setReadonlyByConfig(this.protyle, true);
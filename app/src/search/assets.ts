@@ -1,6 +1,6 @@
 import {Constants} from "../constants";
 import {fetchPost} from "../util/fetch";
-import {escapeAriaLabel} from "../util/escape";
+import {escapeAriaLabel, escapeHtml} from "../util/escape";
 import {setStorageVal, updateHotkeyTip} from "../protyle/util/compatibility";
 /// #if !MOBILE
 import {genQueryHTML} from "./util";
@@ -182,7 +182,7 @@ export const assetInputEvent = (element: Element, localSearch?: ISearchAssetOpti
             page,
             query: searchInputElement.value,
             types: localSearch.types,
-            method: localSearch.method,
+            method: localSearch.method === 4 && !window.siyuan.config.ai.embedding.enabled ? 0 : localSearch.method,
             orderBy: localSearch.sort
         }, (response) => {
             loadingElement.classList.add("fn__none");
@@ -200,11 +200,14 @@ export const assetInputEvent = (element: Element, localSearch?: ISearchAssetOpti
                 path: string
                 name: string
                 hSize: string
+                locator?: IAssetContentLocator
             }, index: number) => {
-                resultHTML += `<div data-type="search-item" class="b3-list-item${index === 0 ? " b3-list-item--focus" : ""}" data-id="${item.id}">
+                const locatorLabel = getAssetLocatorLabel(item.locator);
+                resultHTML += `<div data-type="search-item" class="b3-list-item${index === 0 ? " b3-list-item--focus" : ""}" data-id="${item.id}"${item.locator?.type === "page" ? ` data-page="${item.locator.page}"` : ""}>
 <span class="ft__on-surface">${item.ext}</span>
 <span class="fn__space"></span>
 <span class="b3-list-item__text">${item.content}</span>
+${locatorLabel ? `<span class="b3-list-item__meta b3-list-item__meta--ellipsis">${escapeHtml(locatorLabel)}</span>` : ""}
 <span class="b3-list-item__meta">${item.hSize}</span>
 <span class="b3-list-item__meta b3-list-item__meta--ellipsis ariaLabel" aria-label="${escapeAriaLabel(item.path)}">${item.name}</span>
 </div>`;
@@ -227,6 +230,24 @@ export const assetInputEvent = (element: Element, localSearch?: ISearchAssetOpti
     }, Constants.TIMEOUT_INPUT);
 };
 
+// 资源文件内容分块的位置：PDF 页码、幻灯片序号、工作表区域或 EPUB 章节
+const getAssetLocatorLabel = (locator?: IAssetContentLocator) => {
+    if (!locator) {
+        return "";
+    }
+    switch (locator.type) {
+        case "page":
+            return `P${locator.page}`;
+        case "slide":
+            return `#${locator.slide}`;
+        case "sheet":
+            return `${locator.sheet}!${locator.range}`;
+        case "chapter":
+            return locator.chapter || `#${locator.index}`;
+    }
+    return "";
+};
+
 export const renderPreview = (element: Element, id: string, query: string, queryMethod: number) => {
     fetchPost("/api/search/getAssetContent", {id, query, queryMethod}, (response) => {
         element.innerHTML = `<p style="white-space: pre-wrap;">${response.data.assetContent.content}</p>`;
@@ -295,6 +316,17 @@ export const assetMethodMenu = (target: HTMLElement, cb: () => void) => {
             cb();
         }
     }).element);
+    if (window.siyuan.config.ai.embedding.enabled) {
+        window.siyuan.menus.menu.append(new MenuItem({
+            icon: "iconSparkles",
+            label: window.siyuan.languages.semanticSearch,
+            current: method === 4,
+            click() {
+                window.siyuan.storage[Constants.LOCAL_SEARCHASSET].method = 4;
+                cb();
+            }
+        }).element);
+    }
     /// #if MOBILE
     window.siyuan.menus.menu.fullscreen();
     /// #else
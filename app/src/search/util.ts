@@ -5,7 +5,7 @@ import * as path from "path";
 import {Constants} from "../constants";
 import {escapeAriaLabel, escapeHtml} from "../util/escape";
 import {fetchPost} from "../util/fetch";
-import {openFile, openFileById} from "../editor/util";
+import {openAsset, openFile, openFileById} from "../editor/util";
 import {showMessage} from "../dialog/message";
 import {reloadProtyle} from "../protyle/util/reload";
 import {MenuItem} from "../menus/Menu";
@@ -82,6 +82,19 @@ export const openGlobalSearch = (app: App, text: string, replace: boolean, searc
 };
 
 // closeCB 不存在为页签搜索
+// PDF 命中分块时在对应页打开，其他资源文件在文件夹中显示
+export const openAssetSearchResult = (app: App, item: Element) => {
+    const assetPath = item.lastElementChild.getAttribute("aria-label");
+    const page = item.getAttribute("data-page");
+    if (page && pathPosix().extname(assetPath).toLowerCase() === ".pdf") {
+        openAsset(app, assetPath, parseInt(page));
+        return;
+    }
+    /// #if !BROWSER
+    useShell("showItemInFolder", path.join(window.siyuan.config.system.dataDir, assetPath));
+    /// #endif
+};
+
 export const genSearch = (app: App, config: Config.IUILayoutTabSearchConfig, element: HTMLElement, closeCB?: () => void) => {
     let includeChild = true;
     let enableIncludeChild = false;
@@ -857,9 +870,7 @@ export const genSearch = (app: App, config: Config.IUILayoutTabSearchConfig, ele
                     } else if (isDblClick && isNotCtrl(event)) {
                         clearTimeout(clickTimeout);
                         if (searchType === "asset") {
-                            /// #if !BROWSER
-                            useShell("showItemInFolder", path.join(window.siyuan.config.system.dataDir, target.lastElementChild.getAttribute("aria-label")));
-                            /// #endif
+                            openAssetSearchResult(app, target);
                         } else {
                             openSearchEditor({
                                 rootId: target.getAttribute("data-root-id"),
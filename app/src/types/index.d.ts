@@ -24,6 +24,7 @@ type TOperation =
     | "updateAttrViewCell"
     | "updateAttrViewCol"
     | "updateAttrViewColTemplate"
+    | "updateAttrViewColFormula"
     | "sortAttrViewRow"
     | "sortAttrViewCol"
     | "sortAttrViewKey"
@@ -61,6 +62,11 @@ type TOperation =
     | "setAttrViewCardAspectRatio"
     | "setAttrViewCoverFrom"
     | "setAttrViewCoverFromAssetKeyID"
+    | "setAttrViewStartDateKeyID"
+    | "setAttrViewEndDateKeyID"
+    | "setAttrViewCalendarMode"
+    | "setAttrViewFirstDayOfWeek"
+    | "setAttrViewTimelineScale"
     | "setAttrViewFitImage"
     | "setAttrViewShowIcon"
     | "setAttrViewWrapField"
@@ -98,7 +104,7 @@ type TEventBus = "ws-main" | "sync-start" | "sync-end" | "sync-fail" |
     "mobile-keyboard-show" | "mobile-keyboard-hide" |
     "code-language-update" | "code-language-change" |
     "kernel-plugin-state-change"
-type TAVView = "table" | "gallery" | "kanban"
+type TAVView = "table" | "gallery" | "kanban" | "calendar" | "timeline"
 type TAVAlign = "" | "left" | "center" | "right"
 type TAVCol =
     "text"
@@ -118,6 +124,7 @@ type TAVCol =
     | "updated"
     | "checkbox"
     | "lineNumber"
+    | "formula"
 type TAVFilterOperator =
     "="
     | "!="
@@ -439,6 +446,16 @@ interface ISearchAssetOption {
     k: string,
 }
 
+interface IAssetContentLocator {
+    type: "page" | "slide" | "sheet" | "chapter",
+    page?: number,
+    slide?: number,
+    sheet?: string,
+    range?: string,
+    chapter?: string,
+    index?: number,
+}
+
 interface ITextOption {
     color?: string,
     type: string
@@ -974,9 +991,18 @@ interface IBazaarItem {
     installedIncompatible?: boolean; // 仅 plugin
     bazaarIncompatible?: boolean; // 仅 plugin
     enabled?: boolean; // 仅 plugin
+    permissions?: IPluginPermissions; // 仅 plugin
     modes?: string[]; // 仅 theme
 }
 
+interface IPluginPermissions {
+    readonly: boolean;
+    api: string[];
+    network: boolean;
+    storage: string[];
+    mcp: boolean;
+}
+
 interface IAV {
     id: string;
     name: string;
@@ -1142,6 +1168,10 @@ interface IAVColumn {
         autoFillNow: boolean,
         fillSpecificTime: boolean,
     }
+    formula?: {
+        expr: string,
+        resultType: TAVCol,
+    }
     // 选项列表
     options?: {
         name: string,
@@ -1217,6 +1247,10 @@ interface IAVCellValue {
     date?: IAVCellDateValue
     created?: IAVCellDateValue
     updated?: IAVCellDateValue
+    formula?: {
+        resultType: TAVCol,
+        error?: string
+    }
 }
 
 interface IAVCellRelationValue {
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"math/rand/v2"
 	"os"
 	"path/filepath"
@@ -53,8 +54,9 @@ const systemPrompt = `You are a SiYuan AI assistant. You help users manage their
 - Dailynote (daily note/diary/journal): a special document on the notebook's daily-note save path. For diary/daily note/journal requests, use dailynote.create (not document.create) to open today's note, then dailynote.append/prepend to add content.
 
 ## Tool Usage Patterns
-- Find: search.fulltext (keyword) → block.get (by ID). For semantic search use search.semantic.
+- Find: search.fulltext (keyword) → block.get (by ID). For semantic search use search.semantic. Attachment contents (PDF/Word/Excel/EPUB etc.): search.asset (keyword) or search.semanticasset (semantic) → search.getasset (by path).
 - Explore structure: document.list (children under an hPath) → document.get → block.get_children → block.get. Use block breadcrumb to trace a block's location.
+- Analyze links: graph.path (how two notes connect), graph.rank (most central documents), graph.communities (topic clusters), graph.orphans / graph.deadends (unlinked or dead-end documents), graph.bridges (notes connecting separate clusters).
 - Create content: document.create (notebook + hPath) → block.append/prepend/insert (dataType "markdown").
 - Modify: block.update replaces ONE block's content with new markdown — it does NOT create or append new blocks. To both modify and add, call block.update first, then block.append/prepend/insert as separate calls.
 - Organize: document.move (full document), document.rename (title), block.move (single content block), document.delete.
@@ -162,9 +164,10 @@ var toolSignatureKeys = map[string][]string{
 	"document":  {"id", "path", "notebook", "keyword"},
 	"database":  {"id", "keyID", "itemID", "itemIDs", "keyword"},
 	"ref":       {"id", "keyword"},
+	"graph":     {"action", "from", "to", "notebook"},
 	"notebook":  {"id", "name"},
 	"inbox":     {"id", "ids", "page"},
-	"tag":       {"label", "old", "new", "keyword"},
+	"tag":       {"label", "old", "new", "keyword", "labels", "target"},
 	"bookmark":  {"label", "old", "new"},
 	"dailynote": {"notebook"},
 	"template":  {"id", "path", "name", "keyword"},
@@ -309,6 +312,12 @@ type AgentEvent struct {
 	RetryMax         int
 	SnapshotID       string
 	TurnID           string
+	FromModel        string
+	ToModel          string
+	FallbackReason   string
+	SubAgentID       string
+	SubAgentTask     string
+	SubAgentState    string
 	Effects          mcptools.ToolEffects
 }
 
@@ -380,7 +389,7 @@ type PluginAction struct {
 // 是会话持久化的唯一数据源（不再单独持久化 messages）。
 type SessionEntry struct {
 	ID            string             `json:"id,omitempty"`
-	Type          string             `json:"type"` // user|thinking|assistant|confirm|snapshot|rollback
+	Type          string             `json:"type"` // user|thinking|assistant|confirm|snapshot|rollback|fallback
 	Content       string             `json:"content,omitempty"`
 	References    []Reference        `json:"references,omitempty"`
 	EditorContext *EditorContext     `json:"editorContext,omitempty"`
@@ -400,6 +409,9 @@ type SessionEntry struct {
 	Questions     []map[string]any   `json:"questions,omitempty"`
 	Answers       []string           `json:"answers,omitempty"`
 	SnapshotID    string             `json:"snapshotID,omitempty"`
+	FromModel     string             `json:"fromModel,omitempty"` // 仅 fallback
+	ToModel       string             `json:"toModel,omitempty"`   // 仅 fallback
+	Reason        string             `json:"reason,omitempty"`    // 仅 fallback，触发切换的错误分类
 }
 
 // SessionEntryStep 描述一次思考步骤。工具调用只保留名字列表，
@@ -431,7 +443,8 @@ type agentCheckpoint struct {
 	LastCommittedTurnID   string         `json:"lastCommittedTurnID,omitempty"`
 }
 
-func AgentChat(ctx context.Context, client *openai.Client, model string, sessionID string, userEntryID string, contentRevision int64, userMessage string, language string, references []Reference, editorCtx EditorContext, pluginActions []PluginAction, regenerate bool, confirmTimeout time.Duration, maxRetries int, reasoningEffort string, requestTimeout, streamIdleTimeout time.Duration) <-chan AgentEvent {
+// AgentChat 运行一个 turn。models 首项为主模型，其余为故障转移候选，为空时直接结束。
+func AgentChat(ctx context.Context, models []*ModelCandidate, sessionID string, userEntryID string, contentRevision int64, userMessage string, language string, references []Reference, editorCtx EditorContext, pluginActions []PluginAction, regenerate bool, confirmTimeout time.Duration, maxRetries int, reasoningEffort string, streamIdleTimeout time.Duration) <-chan AgentEvent {
 	ch := make(chan AgentEvent, 256)
 
 	go func() {
@@ -441,6 +454,11 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 				logging.LogErrorf("agent chat panic: %v\n%s", r, logging.ShortStack())
 			}
 		}()
+		if 1 > len(models) {
+			return
+		}
+		chain := newModelChain(models)
+		model := chain.model().Name
 
 		if kernelModel.Conf.AI.MCP != nil {
 			mcpclient.EnsureMCPConnected(kernelModel.Conf.AI.MCP.Servers)
@@ -456,7 +474,8 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 		// 在此统一解析一次，后续 checkpoint 与消息重建均使用解析后的值，保证全链路一致。
 		userMessage = kernelModel.Conf.Variables.Resolve(userMessage)
 
-		tools := convertMCPToolsToOpenAI()
+		run := unattendedRunFrom(ctx)
+		tools := run.filterTools(convertMCPToolsToOpenAI())
 		var messages []openai.ChatCompletionMessage
 		var checkpointMsgs []AgentMessage
 		var totalPrompt, totalCompletion, lastPromptTokens, lastCachedTokens int
@@ -465,7 +484,8 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 		var doomLoop doomLoopTracker
 		var compactCount int
 		var snapshotIDs []string
-		snapshotCreated := false // 整个 AgentChat 过程最多打一次自动快照，避免多轮工具调用时每轮都打
+		snapshotCreated := false  // 整个 AgentChat 过程最多打一次自动快照，避免多轮工具调用时每轮都打
+		var snapshotMu sync.Mutex // 委派期间多个子智能体可能同时请求快照
 		var roundsSinceCheckpoint int
 
 		if sessionID != "" {
@@ -582,6 +602,7 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 			turn.LastPromptTokens = lastPromptTokens
 			turn.CachedTokens = lastCachedTokens
 			turn.ContextLimit = contextLimit
+			turn.ModelSwitches = append([]agentModelSwitch(nil), chain.switches...)
 			if err := saveRuntimeTurn(sessionID, turn, alwaysAllow["*"]); err != nil {
 				logging.LogErrorf("save agent runtime failed: %s", err)
 				return false
@@ -628,7 +649,11 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 				ReasoningEffort: reasoningEffort,
 			}
 
-			stream, firstResp, roundCancel, streamErr := createStreamWithRetry(ctx, client, req, maxRetries, requestTimeout, streamIdleTimeout, delayForCategory, ch)
+			stream, firstResp, roundCancel, streamErr := chain.createStream(ctx, req, maxRetries, streamIdleTimeout, delayForCategory, ch)
+			if switched := chain.model().Name; switched != model {
+				model = switched
+				contextLimit = GetModelContextLimit(model)
+			}
 			if streamErr != nil {
 				if compactCount < 3 && isContextOverflow(streamErr) {
 					keepTurns := max(3-compactCount, 1)
@@ -665,6 +690,7 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 						break
 					}
 					logging.LogErrorf("agent stream error: %s", recvErr.Error())
+					recordModelFailure(chain.model().ID, classifyRetry(recvErr))
 					content := contentBuilder.String()
 					if content != "" || reasoningBuilder.String() != "" {
 						checkpointMsgs = append(checkpointMsgs, AgentMessage{Role: "assistant", Content: content})
@@ -700,23 +726,7 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 						sendEvent(ch, AgentEvent{Type: "reasoning", Token: choice.Delta.ReasoningContent})
 					}
 
-					for _, tcd := range choice.Delta.ToolCalls {
-						idx := 0
-						if tcd.Index != nil {
-							idx = *tcd.Index
-						}
-						for len(aggregatedToolCalls) <= idx {
-							aggregatedToolCalls = append(aggregatedToolCalls, openai.ToolCall{})
-						}
-						if tcd.ID != "" {
-							aggregatedToolCalls[idx].ID = tcd.ID
-							aggregatedToolCalls[idx].Type = tcd.Type
-						}
-						if tcd.Function.Name != "" {
-							aggregatedToolCalls[idx].Function.Name = tcd.Function.Name
-						}
-						aggregatedToolCalls[idx].Function.Arguments += tcd.Function.Arguments
-					}
+					aggregatedToolCalls = appendToolCallDeltas(aggregatedToolCalls, choice.Delta.ToolCalls)
 				}
 				if contentBuilder.Len() > 0 && time.Since(lastDraftCheckpoint) >= time.Second {
 					turn.DraftContent = contentBuilder.String()
@@ -725,6 +735,9 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 				}
 
 				if resp.Usage != nil {
+					kernelModel.RecordAIUsage(kernelModel.AIFeatureAgent, chain.model().Provider, model, sessionID, util.AIUsage{
+						PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens,
+					})
 					totalPrompt += resp.Usage.PromptTokens
 					totalCompletion += resp.Usage.CompletionTokens
 					// 记录最后一次 stream 的 prompt tokens（= 当前上下文已用），供前端底部显示。
@@ -742,13 +755,7 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 			turn.DraftContent = ""
 
 			if len(aggregatedToolCalls) > 0 {
-				filtered := make([]openai.ToolCall, 0, len(aggregatedToolCalls))
-				for _, tc := range aggregatedToolCalls {
-					if tc.Function.Name != "" {
-						filtered = append(filtered, tc)
-					}
-				}
-				aggregatedToolCalls = filtered
+				aggregatedToolCalls = filterNamedToolCalls(aggregatedToolCalls)
 
 				messages = append(messages, openai.ChatCompletionMessage{
 					Role:             openai.ChatMessageRoleAssistant,
@@ -800,7 +807,22 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 						Arguments: args,
 					})
 
-					if needsConfirm(tc.Function.Name, action, alwaysAllow) {
+					// 无人值守运行没有用户可确认，按白名单和确认策略直接拒绝，其余调用视为已批准。
+					if rejection := run.reject(tc.Function.Name, action, alwaysAllow); rejection != "" {
+						sendEvent(ch, AgentEvent{Type: "tool_result", Name: tc.Function.Name, Result: rejection})
+						messages = append(messages, openai.ChatCompletionMessage{
+							Role:       openai.ChatMessageRoleTool,
+							Content:    wrapToolOutput(rejection),
+							ToolCallID: tc.ID,
+						})
+						checkpointMsgs[assistantIdx].ToolCalls[i].Result = rejection
+						checkpointMsgs[assistantIdx].ToolCalls[i].State = "skipped"
+						if !saveTurn("running") {
+							return
+						}
+						continue
+					}
+					if run == nil && needsConfirm(tc.Function.Name, action, alwaysAllow) {
 						confirmID := fmt.Sprintf("%s_%s_%d", turn.TurnID, tc.ID, i)
 						ch2 := make(chan confirmResult, 1)
 						confirmChannelsMu.Lock()
@@ -951,6 +973,45 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 					} else if tc.Function.Name == "frontend" {
 						resultStr, executionUnknown = handleFrontendTool(ctx, tc, ch, confirmTimeout)
 						isErr = executionUnknown
+					} else if tc.Function.Name == "delegate" {
+						d := &delegation{
+							sessionID:           sessionID,
+							turnID:              turn.TurnID,
+							toolCallID:          tc.ID,
+							language:            language,
+							models:              chain.candidates[chain.current:],
+							tools:               tools,
+							alwaysAllow:         maps.Clone(alwaysAllow),
+							run:                 run,
+							concurrency:         kernelModel.Conf.AI.Agent.SubAgentConcurrency,
+							maxRounds:           maxRounds,
+							maxRetries:          maxRetries,
+							streamIdleTimeout:   streamIdleTimeout,
+							temperature:         float32(temperature),
+							maxCompletionTokens: maxCompletionTokens,
+							reasoningEffort:     reasoningEffort,
+							ch:                  ch,
+						}
+						// 子智能体与父会话共用一次自动快照，快照 ID 记入父会话当前 turn。
+						d.snapshot = func() error {
+							snapshotMu.Lock()
+							defer snapshotMu.Unlock()
+							if snapshotCreated {
+								return nil
+							}
+							id, err := kernelModel.IndexRepo("AI agent auto snapshot")
+							if err != nil {
+								return err
+							}
+							snapshotIDs = append(snapshotIDs, id)
+							snapshotCreated = true
+							sendCriticalEvent(ctx, ch, AgentEvent{Type: "snapshot", SnapshotID: id})
+							return nil
+						}
+						var subPrompt, subCompletion int
+						resultStr, isErr, subPrompt, subCompletion = runDelegation(ctx, d, tc.Function.Arguments)
+						totalPrompt += subPrompt
+						totalCompletion += subCompletion
 					} else {
 						resultStr, isErr, executionUnknown = executeTool(ctx, tc, sessionID)
 					}
@@ -1066,7 +1127,10 @@ func AgentChat(ctx context.Context, client *openai.Client, model string, session
 	return ch
 }
 
-func GenerateTitle(client *openai.Client, model string, userMsg string, language string) string {
+func GenerateTitle(client *openai.Client, provider, model string, userMsg string, language string) string {
+	if err := kernelModel.CheckAIBudget(model); err != nil {
+		return fallbackTitle(userMsg)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
@@ -1077,24 +1141,30 @@ func GenerateTitle(client *openai.Client, model string, userMsg string, language
 		},
 		MaxCompletionTokens: 50,
 	})
+	if err == nil {
+		kernelModel.RecordAIUsage(kernelModel.AIFeatureTitle, provider, model, "", util.AIUsage{
+			PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens,
+		})
+	}
 	if err != nil || len(resp.Choices) == 0 {
-		runes := []rune(userMsg)
-		if len(runes) > 30 {
-			return string(runes[:30]) + "..."
-		}
-		return userMsg
+		return fallbackTitle(userMsg)
 	}
 	title := strings.TrimSpace(resp.Choices[0].Message.Content)
 	if title == "" {
-		runes := []rune(userMsg)
-		if len(runes) > 30 {
-			return string(runes[:30]) + "..."
-		}
-		return userMsg
+		return fallbackTitle(userMsg)
 	}
 	return title
 }
 
+// fallbackTitle 在无法调用模型生成标题时截取用户消息作为标题。
+func fallbackTitle(userMsg string) string {
+	runes := []rune(userMsg)
+	if len(runes) > 30 {
+		return string(runes[:30]) + "..."
+	}
+	return userMsg
+}
+
 // safeActions 按 action 字符串全局匹配，命中即免 UI 确认。
 // 契约：此处列出的 action 名必须代表纯只读操作。
 // 新增工具时，写操作的 action 切勿与此表冲突，否则将静默豁免确认。
@@ -1114,7 +1184,7 @@ var safeActions = map[string]bool{
 
 var safeWholeTools = map[string]bool{
 	"question": true, "todo_write": true, "web_fetch": true, "web_search": true,
-	"search": true, "sql": true,
+	"search": true, "sql": true, "delegate": true,
 }
 
 func needsConfirm(toolName string, action string, alwaysAllow map[string]bool) bool {
@@ -0,0 +1,835 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	kernelConf "github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/job"
+	kernelModel "github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Agent 自动化的触发方式。
+const (
+	AutomationTriggerCron      = "cron"      // 按 cron 表达式定时运行
+	AutomationTriggerDailyNote = "dailyNote" // 新建日记后运行
+	AutomationTriggerAttr      = "attr"      // 块新增指定属性（或属性变为指定值）后运行
+	AutomationTriggerTag       = "tag"       // 块首次带上指定标签后运行
+)
+
+// 自动化运行时需要确认的工具调用的处理方式。
+const (
+	AutomationConfirmDeny  = "deny"  // 自动拒绝，只执行无需确认的工具调用
+	AutomationConfirmAllow = "allow" // 自动批准
+)
+
+const (
+	automationJobPrefix = "agentAutomation:"
+	maxAutomationFired  = 1000 // 每个标签触发自动化最多记录的已触发块数
+)
+
+var (
+	ErrAutomationNotFound = errors.New("agent automation not found")
+	ErrAutomationRunning  = errors.New("agent automation is already running")
+)
+
+// Automation 是一条 Agent 自动化：保存的提示词和工具白名单，在触发时无人值守运行，
+// 每次运行记录为一个普通会话，最终回复可追加到目标文档。
+type Automation struct {
+	ID            string             `json:"id"`
+	Name          string             `json:"name"`
+	Enabled       bool               `json:"enabled"`
+	Prompt        string             `json:"prompt"`
+	Tools         []string           `json:"tools"` // 允许调用的工具名，为空时不提供工具
+	Trigger       *AutomationTrigger `json:"trigger"`
+	ConfirmPolicy string             `json:"confirmPolicy"` // 需要确认的工具调用的处理方式
+	TargetDocID   string             `json:"targetDocID"`   // 最终回复追加到的文档，为空时日记触发写入新建的日记，其他触发不写入
+	ModelID       string             `json:"modelId"`       // 为空时使用 Agent 默认模型
+	Created       int64              `json:"created"`
+	Updated       int64              `json:"updated"`
+
+	LastRun       int64    `json:"lastRun"`                 // 最近一次运行结束时间
+	LastStatus    string   `json:"lastStatus"`              // 最近一次运行状态：finished、interrupted、failed
+	LastError     string   `json:"lastError,omitempty"`     // 最近一次运行的错误信息
+	LastSessionID string   `json:"lastSessionID,omitempty"` // 最近一次运行的会话
+	Fired         []string `json:"fired,omitempty"`         // 标签触发已处理过的块 ID，避免每次编辑都重复触发
+
+	NextRun int64 `json:"nextRun,omitempty"` // 定时触发的下一次运行时间，仅在 API 返回值中设置
+}
+
+// AutomationTrigger 描述自动化的触发条件，按 Type 使用对应字段。
+type AutomationTrigger struct {
+	Type  string `json:"type"`
+	Cron  string `json:"cron,omitempty"`  // cron 触发的表达式，如 "0 9 * * 1-5"
+	Box   string `json:"box,omitempty"`   // dailyNote 触发限定的笔记本 ID，为空时不限
+	Attr  string `json:"attr,omitempty"`  // attr 触发的属性名
+	Value string `json:"value,omitempty"` // attr 触发的属性值，为空时只要求存在该属性
+	Tag   string `json:"tag,omitempty"`   // tag 触发的标签，不含 #
+}
+
+// matchesAttrs 判断块属性是否满足 attr 触发条件。
+func (trigger *AutomationTrigger) matchesAttrs(attrs map[string]string) bool {
+	value, ok := attrs[trigger.Attr]
+	return ok && ("" == trigger.Value || value == trigger.Value)
+}
+
+// automationEvent 是触发一次运行的上下文，作为编辑器上下文传给 Agent。
+type automationEvent struct {
+	box     string
+	docID   string
+	blockID string
+}
+
+func (event automationEvent) editorContext() EditorContext {
+	return EditorContext{NotebookID: event.box, ActiveDocID: event.docID, FocusedBlockID: event.blockID}
+}
+
+var (
+	automations        []*Automation
+	automationsLock    = sync.Mutex{}
+	automationsRunning = sync.Map{}
+)
+
+// automationsPath 返回自动化配置文件路径。自动化保存在本机工作空间的 conf 目录下，不参与数据同步，
+// 避免多台设备同时运行同一条自动化。
+func automationsPath() string {
+	return filepath.Join(util.ConfDir, "agent-automations.json")
+}
+
+func loadAutomations() (ret []*Automation) {
+	ret = []*Automation{}
+	path := automationsPath()
+	if !filelock.IsExist(path) {
+		return
+	}
+
+	data, err := filelock.ReadFile(path)
+	if err != nil {
+		logging.LogErrorf("read agent automations [%s] failed: %s", path, err)
+		return
+	}
+	var loaded []*Automation
+	if err = gulu.JSON.UnmarshalJSON(data, &loaded); err != nil {
+		logging.LogErrorf("unmarshal agent automations [%s] failed: %s", path, err)
+		return
+	}
+	for _, automation := range loaded {
+		if automation != nil && automation.ID != "" && automation.Trigger != nil {
+			ret = append(ret, automation)
+		}
+	}
+	return
+}
+
+// saveAutomationsLocked 写入所有自动化，调用方需持有 automationsLock。
+func saveAutomationsLocked() {
+	path := automationsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logging.LogErrorf("create agent automations dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(automations, "", "\t")
+	if err != nil {
+		logging.LogErrorf("marshal agent automations failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(path, data); err != nil {
+		logging.LogErrorf("write agent automations [%s] failed: %s", path, err)
+	}
+}
+
+func findAutomation(id string) int {
+	return slices.IndexFunc(automations, func(automation *Automation) bool { return automation.ID == id })
+}
+
+func copyAutomation(automation *Automation) *Automation {
+	ret := *automation
+	ret.Tools = slices.Clone(automation.Tools)
+	ret.Fired = slices.Clone(automation.Fired)
+	if automation.Trigger != nil {
+		trigger := *automation.Trigger
+		ret.Trigger = &trigger
+	}
+	return &ret
+}
+
+// apiAutomation 返回用于 API 输出的副本，附带下一次运行时间并去掉内部记录。
+func apiAutomation(automation *Automation) *Automation {
+	ret := copyAutomation(automation)
+	ret.Fired = nil
+	if next, ok := job.GetScheduledJobNext(automationJobPrefix + automation.ID); ok {
+		ret.NextRun = next.UnixMilli()
+	}
+	return ret
+}
+
+// InitAutomations 在启动时加载自动化、注册定时任务并订阅触发事件。内核未运行期间错过的定时运行不会补跑。
+func InitAutomations() {
+	automationsLock.Lock()
+	automations = loadAutomations()
+	for _, automation := range automations {
+		scheduleAutomation(automation)
+	}
+	automationsLock.Unlock()
+
+	subscribe := func(topic string, fn any) {
+		if err := eventbus.Subscribe(topic, fn); err != nil {
+			logging.LogErrorf("subscribe agent automation event [%s] failed: %s", topic, err)
+		}
+	}
+	subscribe(util.EvtDailyNoteCreated, automationOnDailyNoteCreated)
+	subscribe(util.EvtBlockAttrsUpdated, automationOnBlockAttrsUpdated)
+	subscribe(util.EvtTxCommitted, automationOnTxCommitted)
+}
+
+// scheduleAutomation 按自动化当前配置注册或移除定时任务。
+func scheduleAutomation(automation *Automation) {
+	jobID := automationJobPrefix + automation.ID
+	job.RemoveScheduledJob(jobID)
+	if !automation.Enabled || AutomationTriggerCron != automation.Trigger.Type {
+		return
+	}
+
+	rule, err := job.NewSchedule(automation.Trigger.Cron, 0)
+	if err != nil {
+		logging.LogErrorf("schedule agent automation [%s] failed: %s", automation.ID, err)
+		return
+	}
+	id := automation.ID
+	job.AddScheduledJob(&job.ScheduledJob{
+		ID:       jobID,
+		Schedule: rule,
+		Func: func(time.Time) {
+			automationsLock.Lock()
+			i := findAutomation(id)
+			var scheduled *Automation
+			if 0 <= i {
+				scheduled = copyAutomation(automations[i])
+			}
+			automationsLock.Unlock()
+			if scheduled != nil {
+				startAutomation(scheduled, automationEvent{})
+			}
+		},
+	})
+}
+
+func normalizeAutomation(automation *Automation) error {
+	automation.Prompt = strings.TrimSpace(automation.Prompt)
+	if automation.Prompt == "" {
+		return errors.New("prompt is required")
+	}
+	automation.Name = strings.TrimSpace(automation.Name)
+	if automation.Name == "" {
+		automation.Name = fallbackTitle(automation.Prompt)
+	}
+
+	tools := []string{}
+	for _, name := range automation.Tools {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.Contains(tools, name) {
+			tools = append(tools, name)
+		}
+	}
+	automation.Tools = tools
+
+	switch automation.ConfirmPolicy {
+	case "":
+		automation.ConfirmPolicy = AutomationConfirmDeny
+	case AutomationConfirmDeny, AutomationConfirmAllow:
+	default:
+		return fmt.Errorf("invalid confirmPolicy [%s], expected one of deny, allow", automation.ConfirmPolicy)
+	}
+
+	trigger := automation.Trigger
+	if trigger == nil {
+		return errors.New("trigger is required")
+	}
+	switch trigger.Type {
+	case AutomationTriggerCron:
+		trigger.Cron = strings.TrimSpace(trigger.Cron)
+		if _, err := job.NewSchedule(trigger.Cron, 0); err != nil {
+			return fmt.Errorf("invalid cron [%s]: %w", trigger.Cron, err)
+		}
+	case AutomationTriggerDailyNote:
+		trigger.Box = strings.TrimSpace(trigger.Box)
+	case AutomationTriggerAttr:
+		trigger.Attr = strings.TrimSpace(trigger.Attr)
+		if trigger.Attr == "" {
+			return errors.New("attr is required")
+		}
+	case AutomationTriggerTag:
+		trigger.Tag = strings.Trim(strings.TrimSpace(trigger.Tag), "#")
+		if trigger.Tag == "" {
+			return errors.New("tag is required")
+		}
+	default:
+		return fmt.Errorf("invalid trigger type [%s], expected one of cron, dailyNote, attr, tag", trigger.Type)
+	}
+
+	automation.TargetDocID = strings.TrimSpace(automation.TargetDocID)
+	if automation.TargetDocID != "" && !ast.IsNodeIDPattern(automation.TargetDocID) {
+		return fmt.Errorf("invalid targetDocID [%s]", automation.TargetDocID)
+	}
+	automation.ModelID = strings.TrimSpace(automation.ModelID)
+	return nil
+}
+
+// ListAutomations 返回所有自动化。
+func ListAutomations() (ret []*Automation) {
+	automationsLock.Lock()
+	defer automationsLock.Unlock()
+
+	ret = []*Automation{}
+	for _, automation := range automations {
+		ret = append(ret, apiAutomation(automation))
+	}
+	return
+}
+
+// SaveAutomation 在 ID 为空时新建自动化，否则更新已有自动化并保留其运行记录。
+func SaveAutomation(automation *Automation) (ret *Automation, err error) {
+	if err = normalizeAutomation(automation); err != nil {
+		return
+	}
+
+	automationsLock.Lock()
+	defer automationsLock.Unlock()
+
+	saved := copyAutomation(automation)
+	saved.NextRun = 0
+	now := time.Now().UnixMilli()
+	if saved.ID == "" {
+		saved.ID = ast.NewNodeID()
+		saved.Created = now
+		saved.LastRun, saved.LastStatus, saved.LastError, saved.LastSessionID, saved.Fired = 0, "", "", "", nil
+		automations = append(automations, saved)
+	} else {
+		i := findAutomation(saved.ID)
+		if 0 > i {
+			err = ErrAutomationNotFound
+			return
+		}
+		old := automations[i]
+		saved.Created = old.Created
+		saved.LastRun, saved.LastStatus, saved.LastError, saved.LastSessionID = old.LastRun, old.LastStatus, old.LastError, old.LastSessionID
+		saved.Fired = nil
+		if AutomationTriggerTag == saved.Trigger.Type && AutomationTriggerTag == old.Trigger.Type && saved.Trigger.Tag == old.Trigger.Tag {
+			saved.Fired = old.Fired
+		}
+		automations[i] = saved
+	}
+	saved.Updated = now
+	saveAutomationsLocked()
+	scheduleAutomation(saved)
+	ret = apiAutomation(saved)
+	return
+}
+
+// RemoveAutomation 删除自动化，已记录的运行会话保留。
+func RemoveAutomation(id string) error {
+	automationsLock.Lock()
+	defer automationsLock.Unlock()
+
+	i := findAutomation(id)
+	if 0 > i {
+		return ErrAutomationNotFound
+	}
+	automations = slices.Delete(automations, i, i+1)
+	saveAutomationsLocked()
+	job.RemoveScheduledJob(automationJobPrefix + id)
+	return nil
+}
+
+// RunAutomation 立即运行一次自动化（不要求已启用），返回记录本次运行的会话 ID。
+func RunAutomation(id string) (sessionID string, err error) {
+	automationsLock.Lock()
+	i := findAutomation(id)
+	var automation *Automation
+	if 0 <= i {
+		automation = copyAutomation(automations[i])
+	}
+	automationsLock.Unlock()
+	if automation == nil {
+		err = ErrAutomationNotFound
+		return
+	}
+	return startAutomation(automation, automationEvent{})
+}
+
+// triggeredAutomations 返回已启用且满足条件的指定触发方式的自动化副本。
+func triggeredAutomations(typ string, match func(automation *Automation) bool) (ret []*Automation) {
+	automationsLock.Lock()
+	defer automationsLock.Unlock()
+
+	for _, automation := range automations {
+		if automation.Enabled && typ == automation.Trigger.Type && (match == nil || match(automation)) {
+			ret = append(ret, copyAutomation(automation))
+		}
+	}
+	return
+}
+
+func automationOnDailyNoteCreated(boxID, p, id string) {
+	matched := triggeredAutomations(AutomationTriggerDailyNote, func(automation *Automation) bool {
+		return automation.Trigger.Box == "" || automation.Trigger.Box == boxID
+	})
+	for _, automation := range matched {
+		go startAutomation(automation, automationEvent{box: boxID, docID: id})
+	}
+}
+
+func automationOnBlockAttrsUpdated(id string, oldAttrs, newAttrs map[string]string) {
+	matched := triggeredAutomations(AutomationTriggerAttr, func(automation *Automation) bool {
+		return automation.Trigger.matchesAttrs(newAttrs) && !automation.Trigger.matchesAttrs(oldAttrs)
+	})
+	if 1 > len(matched) {
+		return
+	}
+
+	bt := treenode.GetBlockTree(id)
+	if bt == nil {
+		return
+	}
+	for _, automation := range matched {
+		// 忽略自动化写入目标文档引起的变更，避免运行结果再次触发自身
+		if bt.RootID == automation.TargetDocID {
+			continue
+		}
+		go startAutomation(automation, automationEvent{box: bt.BoxID, docID: bt.RootID, blockID: id})
+	}
+}
+
+func automationOnTxCommitted(rootIDs, blockIDs, avIDs []string) {
+	matched := triggeredAutomations(AutomationTriggerTag, nil)
+	if 1 > len(matched) || 1 > len(blockIDs) {
+		return
+	}
+	go checkAutomationTags(matched, blockIDs)
+}
+
+// checkAutomationTags 检查变更的块是否带有自动化关注的标签，每个块对同一自动化只触发一次。
+func checkAutomationTags(matched []*Automation, blockIDs []string) {
+	luteEngine := util.NewLute()
+	trees := map[string]*parse.Tree{}
+	for _, id := range blockIDs {
+		bt := treenode.GetBlockTree(id)
+		if bt == nil {
+			continue
+		}
+		tree, ok := trees[bt.RootID]
+		if !ok {
+			tree, _ = filesys.LoadTree(bt.BoxID, bt.Path, luteEngine)
+			trees[bt.RootID] = tree
+		}
+		if tree == nil {
+			continue
+		}
+		node := treenode.GetNodeInTree(tree, id)
+		if node == nil {
+			continue
+		}
+
+		tags := nodeTags(node)
+		for _, automation := range matched {
+			if bt.RootID == automation.TargetDocID || !slices.Contains(tags, automation.Trigger.Tag) {
+				continue
+			}
+			fireTagAutomation(automation, automationEvent{box: bt.BoxID, docID: bt.RootID, blockID: id})
+		}
+	}
+}
+
+// fireTagAutomation 由标签触发自动化。先记录块已触发避免并发的变更重复触发，启动失败时撤销记录，块在下次变更时可以再次触发。
+func fireTagAutomation(automation *Automation, event automationEvent) {
+	if !markAutomationFired(automation.ID, event.blockID) {
+		return
+	}
+	if _, err := startAutomation(automation, event); err != nil {
+		unmarkAutomationFired(automation.ID, event.blockID)
+	}
+}
+
+// nodeTags 返回叶子块内容中的标签。容器块的标签属于其子块，不在此统计。
+func nodeTags(node *ast.Node) (ret []string) {
+	if node.IsContainerBlock() {
+		return
+	}
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsTextMarkType("tag") {
+			ret = append(ret, strings.TrimSpace(n.TextMarkTextContent))
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+// markAutomationFired 记录块已触发过自动化，已记录过时返回 false。
+func markAutomationFired(automationID, blockID string) bool {
+	automationsLock.Lock()
+	defer automationsLock.Unlock()
+
+	i := findAutomation(automationID)
+	if 0 > i || slices.Contains(automations[i].Fired, blockID) {
+		return false
+	}
+	fired := append(automations[i].Fired, blockID)
+	if len(fired) > maxAutomationFired {
+		fired = fired[len(fired)-maxAutomationFired:]
+	}
+	automations[i].Fired = fired
+	saveAutomationsLocked()
+	return true
+}
+
+// unmarkAutomationFired 撤销块已触发过自动化的记录。
+func unmarkAutomationFired(automationID, blockID string) {
+	automationsLock.Lock()
+	defer automationsLock.Unlock()
+
+	i := findAutomation(automationID)
+	if 0 > i {
+		return
+	}
+	fired := slices.DeleteFunc(slices.Clone(automations[i].Fired), func(id string) bool { return id == blockID })
+	if len(fired) == len(automations[i].Fired) {
+		return
+	}
+	automations[i].Fired = fired
+	saveAutomationsLocked()
+}
+
+// startAutomation 创建记录本次运行的会话并在后台运行自动化。同一自动化同时只运行一个实例，运行中再次触发会被跳过。
+func startAutomation(automation *Automation, event automationEvent) (sessionID string, err error) {
+	if _, running := automationsRunning.LoadOrStore(automation.ID, true); running {
+		logging.LogWarnf("agent automation [%s] is already running, skipped", automation.ID)
+		err = ErrAutomationRunning
+		return
+	}
+
+	models, err := automationModels(automation)
+	var userEntryID string
+	var revision int64
+	if err == nil {
+		sessionID, userEntryID, revision, err = createAutomationSession(automation, event, time.Now())
+	}
+	if err != nil {
+		automationsRunning.Delete(automation.ID)
+		logging.LogErrorf("start agent automation [%s] failed: %s", automation.ID, err)
+		finishAutomation(automation.ID, sessionID, "failed", err.Error())
+		return
+	}
+
+	go func() {
+		defer automationsRunning.Delete(automation.ID)
+		defer logging.Recover()
+		runAutomation(automation, event, sessionID, userEntryID, revision, models)
+	}()
+	return
+}
+
+func automationModels(automation *Automation) ([]*ModelCandidate, error) {
+	var provider *kernelConf.Provider
+	var selectedModel *kernelConf.Model
+	if automation.ModelID != "" {
+		provider, selectedModel = kernelModel.Conf.AI.GetModel(automation.ModelID)
+	} else {
+		provider, selectedModel = kernelModel.Conf.AI.GetAgentModel()
+	}
+	if nil == provider || nil == selectedModel {
+		return nil, errors.New(kernelModel.Conf.Language(193))
+	}
+	if err := kernelModel.CheckAIBudget(selectedModel.Name); err != nil {
+		return nil, err
+	}
+	return ModelCandidates(provider, selectedModel), nil
+}
+
+// createAutomationSession 新建只包含自动化提示词的会话，Agent 运行结束后再提交本次 turn。
+func createAutomationSession(automation *Automation, event automationEvent, now time.Time) (sessionID, userEntryID string, revision int64, err error) {
+	sessionID = ast.NewNodeID()
+	userEntryID = ast.NewNodeID()
+	userEntry := map[string]any{
+		"id":        userEntryID,
+		"type":      "user",
+		"content":   automation.Prompt,
+		"timestamp": now.UnixMilli(),
+	}
+	if editorCtx := cloneEditorContext(event.editorContext()); editorCtx != nil {
+		userEntry["editorContext"] = editorCtx
+	}
+	session := map[string]any{
+		"id":           sessionID,
+		"title":        automation.Name + " " + now.Format("2006-01-02 15:04"),
+		"titled":       true,
+		"createdAt":    now.UnixMilli(),
+		"updatedAt":    now.UnixMilli(),
+		"automationID": automation.ID,
+		"entries":      []any{userEntry},
+	}
+	data, err := gulu.JSON.MarshalJSON(session)
+	if err != nil {
+		return
+	}
+	revision, err = SaveSession(data)
+	return
+}
+
+func runAutomation(automation *Automation, event automationEvent, sessionID, userEntryID string, revision int64, models []*ModelCandidate) {
+	agentConf := kernelModel.Conf.AI.Agent
+	sessionTimeout := time.Duration(agentConf.SessionTimeout) * time.Second
+	if sessionTimeout <= 0 {
+		sessionTimeout = 600 * time.Second
+	}
+	confirmTimeout := time.Duration(agentConf.ConfirmTimeout) * time.Second
+	if confirmTimeout <= 0 {
+		confirmTimeout = 120 * time.Second
+	}
+	streamIdleTimeout := time.Duration(agentConf.StreamIdleTimeout) * time.Second
+	if streamIdleTimeout <= 0 {
+		streamIdleTimeout = 120 * time.Second
+	}
+	run := &unattendedRun{tools: map[string]bool{}, allowConfirm: AutomationConfirmAllow == automation.ConfirmPolicy}
+	for _, name := range automation.Tools {
+		run.tools[name] = true
+	}
+	ctx, cancel := context.WithTimeout(withUnattendedRun(context.Background(), run), sessionTimeout)
+	defer cancel()
+
+	logging.LogInfof("agent automation [%s] started, session [%s]", automation.ID, sessionID)
+	broadcastAutomationSession(sessionID)
+	var turnID, errMsg string
+	for ev := range AgentChat(ctx, models, sessionID, userEntryID, revision, automation.Prompt, kernelModel.Conf.Lang, nil, event.editorContext(), nil, false, confirmTimeout, max(agentConf.MaxRetries, 0), "", streamIdleTimeout) {
+		switch ev.Type {
+		case "turn":
+			turnID = ev.TurnID
+		case "error":
+			errMsg = ev.Error
+		}
+	}
+
+	status := "failed"
+	if turnID == "" {
+		if errMsg == "" {
+			errMsg = "agent turn did not start"
+		}
+	} else {
+		state, content, err := commitAutomationSession(sessionID, turnID, revision)
+		if err != nil {
+			errMsg = err.Error()
+		} else {
+			status = state
+			if docID := automationTargetDocID(automation, event); docID != "" && content != "" && "finished" == state {
+				if err = appendAutomationResult(docID, content); err != nil {
+					errMsg = err.Error()
+				}
+			}
+		}
+	}
+	if errMsg != "" {
+		logging.LogErrorf("agent automation [%s] %s: %s", automation.ID, status, errMsg)
+	} else {
+		logging.LogInfof("agent automation [%s] %s", automation.ID, status)
+	}
+	finishAutomation(automation.ID, sessionID, status, errMsg)
+	broadcastAutomationSession(sessionID)
+}
+
+// commitAutomationSession 把已结束的 runtime turn 提交进会话，返回 turn 的结束状态和最终回复。
+func commitAutomationSession(sessionID, turnID string, revision int64) (state, content string, err error) {
+	runtime, err := loadRuntimeState(sessionID)
+	if err != nil {
+		return
+	}
+	if runtime == nil || runtime.ActiveTurn == nil || runtime.ActiveTurn.TurnID != turnID {
+		err = ErrSessionConflict
+		return
+	}
+	state = runtime.ActiveTurn.State
+
+	session, err := GetSessionState(sessionID, false)
+	if err != nil {
+		return
+	}
+	session["expectedRevision"] = revision
+	session["commitTurnID"] = turnID
+	session["updatedAt"] = time.Now().UnixMilli()
+	data, err := gulu.JSON.MarshalJSON(session)
+	if err != nil {
+		return
+	}
+	_, committed, err := SaveSessionState(data)
+	if err != nil {
+		return
+	}
+	content = lastAssistantContent(committed)
+	return
+}
+
+// lastAssistantContent 返回会话中最后一条非空的助手回复。
+func lastAssistantContent(session map[string]any) string {
+	var entries []map[string]any
+	switch v := session["entries"].(type) {
+	case []map[string]any:
+		entries = v
+	case []any:
+		for _, entry := range v {
+			if m, ok := entry.(map[string]any); ok {
+				entries = append(entries, m)
+			}
+		}
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i]["type"] != "assistant" {
+			continue
+		}
+		if content, _ := entries[i]["content"].(string); strings.TrimSpace(content) != "" {
+			return content
+		}
+	}
+	return ""
+}
+
+func automationTargetDocID(automation *Automation, event automationEvent) string {
+	if automation.TargetDocID != "" {
+		return automation.TargetDocID
+	}
+	if AutomationTriggerDailyNote == automation.Trigger.Type {
+		return event.docID
+	}
+	return ""
+}
+
+// appendAutomationResult 把 Markdown 形式的运行结果追加到文档末尾。
+func appendAutomationResult(docID, content string) error {
+	if bt := treenode.GetBlockTree(docID); bt == nil || docID != bt.RootID {
+		return fmt.Errorf("target document [%s] not found", docID)
+	}
+
+	luteEngine := util.NewLute()
+	luteEngine.SetHTMLTag2TextMark(true)
+	dom, _ := luteEngine.Md2BlockDOMTree(content, true)
+	if dom == "" {
+		return nil
+	}
+	transactions := []*kernelModel.Transaction{{
+		DoOperations: []*kernelModel.Operation{{
+			Action:   "appendInsert",
+			Data:     dom,
+			ParentID: docID,
+		}},
+	}}
+	kernelModel.PerformTransactions(&transactions)
+	kernelModel.FlushTxQueue()
+	util.PushReloadProtyle(docID)
+	return nil
+}
+
+func finishAutomation(id, sessionID, status, errMsg string) {
+	automationsLock.Lock()
+	defer automationsLock.Unlock()
+
+	i := findAutomation(id)
+	if 0 > i {
+		return
+	}
+	automation := automations[i]
+	automation.LastRun = time.Now().UnixMilli()
+	automation.LastStatus = status
+	automation.LastError = errMsg
+	if sessionID != "" {
+		automation.LastSessionID = sessionID
+	}
+	saveAutomationsLocked()
+}
+
+// broadcastAutomationSession 通知打开了 agentChat dock 的实例刷新会话列表。
+func broadcastAutomationSession(sessionID string) {
+	util.BroadcastByType("agentChat", "agentSessionChanged", 0, "", map[string]string{"sessionID": sessionID, "action": "update"})
+}
+
+// unattendedRun 是无人值守运行对 AgentChat 的约束，通过 context 传入，不扩充交互式会话的参数。
+type unattendedRun struct {
+	tools        map[string]bool // 允许调用的工具
+	allowConfirm bool            // 需要确认的工具调用是否自动批准
+}
+
+type unattendedRunKey struct{}
+
+func withUnattendedRun(ctx context.Context, run *unattendedRun) context.Context {
+	return context.WithValue(ctx, unattendedRunKey{}, run)
+}
+
+// unattendedRunFrom 返回 context 中的无人值守约束，交互式会话返回 nil。
+func unattendedRunFrom(ctx context.Context) *unattendedRun {
+	run, _ := ctx.Value(unattendedRunKey{}).(*unattendedRun)
+	return run
+}
+
+// interactiveTools 需要用户作答或由浏览器执行，无人值守运行中始终不可用。
+var interactiveTools = map[string]bool{"question": true, "frontend": true}
+
+func (run *unattendedRun) allows(name string) bool {
+	return run == nil || (!interactiveTools[name] && run.tools[name])
+}
+
+func (run *unattendedRun) filterTools(tools []openai.Tool) []openai.Tool {
+	if run == nil {
+		return tools
+	}
+	ret := make([]openai.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function != nil && run.allows(tool.Function.Name) {
+			ret = append(ret, tool)
+		}
+	}
+	return ret
+}
+
+// reject 返回无人值守运行拒绝该工具调用的原因，允许执行时返回空串。
+func (run *unattendedRun) reject(name, action string, alwaysAllow map[string]bool) string {
+	if run == nil {
+		return ""
+	}
+	if !run.allows(name) {
+		return "Tool is not allowed in this automation"
+	}
+	if !run.allowConfirm && needsConfirm(name, action, alwaysAllow) {
+		return "Operation rejected by the automation confirm policy"
+	}
+	return ""
+}
@@ -0,0 +1,286 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package agent
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/siyuan/kernel/job"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func useTestAutomations(t *testing.T) {
+	t.Helper()
+	useTestDataDir(t)
+	originalConfDir := util.ConfDir
+	util.ConfDir = t.TempDir()
+	automationsLock.Lock()
+	originalAutomations := automations
+	automations = []*Automation{}
+	automationsLock.Unlock()
+	t.Cleanup(func() {
+		util.ConfDir = originalConfDir
+		automationsLock.Lock()
+		for _, automation := range automations {
+			job.RemoveScheduledJob(automationJobPrefix + automation.ID)
+		}
+		automations = originalAutomations
+		automationsLock.Unlock()
+	})
+}
+
+func TestNormalizeAutomation(t *testing.T) {
+	automation := &Automation{
+		Prompt:  "  Summarize today  ",
+		Tools:   []string{" sql ", "sql", "", "block"},
+		Trigger: &AutomationTrigger{Type: AutomationTriggerTag, Tag: "#todo#"},
+	}
+	if err := normalizeAutomation(automation); err != nil {
+		t.Fatal(err)
+	}
+	if automation.Prompt != "Summarize today" || automation.Name != "Summarize today" {
+		t.Fatalf("prompt and default name were not normalized: %#v", automation)
+	}
+	if !slices.Equal(automation.Tools, []string{"sql", "block"}) {
+		t.Fatalf("tools were not trimmed and deduplicated: %#v", automation.Tools)
+	}
+	if automation.ConfirmPolicy != AutomationConfirmDeny || automation.Trigger.Tag != "todo" {
+		t.Fatalf("confirm policy or tag was not normalized: %#v", automation)
+	}
+
+	invalid := []*Automation{
+		{Trigger: &AutomationTrigger{Type: AutomationTriggerDailyNote}},
+		{Prompt: "p"},
+		{Prompt: "p", Trigger: &AutomationTrigger{Type: "webhook"}},
+		{Prompt: "p", Trigger: &AutomationTrigger{Type: AutomationTriggerCron, Cron: "not a cron"}},
+		{Prompt: "p", Trigger: &AutomationTrigger{Type: AutomationTriggerAttr}},
+		{Prompt: "p", Trigger: &AutomationTrigger{Type: AutomationTriggerTag, Tag: "#"}},
+		{Prompt: "p", Trigger: &AutomationTrigger{Type: AutomationTriggerDailyNote}, ConfirmPolicy: "ask"},
+		{Prompt: "p", Trigger: &AutomationTrigger{Type: AutomationTriggerDailyNote}, TargetDocID: "doc"},
+	}
+	for i, automation := range invalid {
+		if err := normalizeAutomation(automation); err == nil {
+			t.Fatalf("invalid automation %d was accepted: %#v", i, automation)
+		}
+	}
+}
+
+func TestSaveAutomationSchedulesAndKeepsRunState(t *testing.T) {
+	useTestAutomations(t)
+
+	created, err := SaveAutomation(&Automation{
+		Name:    "daily summary",
+		Enabled: true,
+		Prompt:  "Summarize",
+		Trigger: &AutomationTrigger{Type: AutomationTriggerCron, Cron: "0 9 * * *"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.Created == 0 || created.NextRun == 0 {
+		t.Fatalf("created automation was not scheduled: %#v", created)
+	}
+	finishAutomation(created.ID, "20260715120000-abcdefg", "finished", "")
+
+	automationsLock.Lock()
+	automations = loadAutomations()
+	automationsLock.Unlock()
+	listed := ListAutomations()
+	if len(listed) != 1 || listed[0].LastStatus != "finished" || listed[0].LastSessionID != "20260715120000-abcdefg" {
+		t.Fatalf("automation run state was not persisted: %#v", listed)
+	}
+
+	update := *listed[0]
+	update.Enabled = false
+	update.LastStatus = ""
+	updated, err := SaveAutomation(&update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.LastStatus != "finished" || updated.Created != created.Created || updated.NextRun != 0 {
+		t.Fatalf("update did not keep run state or unschedule the automation: %#v", updated)
+	}
+	if _, ok := job.GetScheduledJobNext(automationJobPrefix + created.ID); ok {
+		t.Fatal("disabled automation is still scheduled")
+	}
+
+	if err = RemoveAutomation(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = RemoveAutomation(created.ID); err != ErrAutomationNotFound {
+		t.Fatalf("removing a missing automation returned %v", err)
+	}
+	if _, err = SaveAutomation(&update); err != ErrAutomationNotFound {
+		t.Fatalf("updating a removed automation returned %v", err)
+	}
+}
+
+func TestAutomationTriggerMatchesAttrs(t *testing.T) {
+	trigger := &AutomationTrigger{Type: AutomationTriggerAttr, Attr: "custom-ai"}
+	if !trigger.matchesAttrs(map[string]string{"custom-ai": "summary"}) || trigger.matchesAttrs(map[string]string{"custom-other": "summary"}) {
+		t.Fatal("attr trigger without value should only require the attribute")
+	}
+	trigger.Value = "summary"
+	if !trigger.matchesAttrs(map[string]string{"custom-ai": "summary"}) || trigger.matchesAttrs(map[string]string{"custom-ai": "translate"}) {
+		t.Fatal("attr trigger with value should require the exact value")
+	}
+}
+
+func TestMarkAutomationFiredOnce(t *testing.T) {
+	useTestAutomations(t)
+
+	created, err := SaveAutomation(&Automation{
+		Prompt:  "Process tagged block",
+		Trigger: &AutomationTrigger{Type: AutomationTriggerTag, Tag: "inbox"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !markAutomationFired(created.ID, "20260715120000-aaaaaaa") || markAutomationFired(created.ID, "20260715120000-aaaaaaa") {
+		t.Fatal("a block must trigger a tag automation only once")
+	}
+	if !markAutomationFired(created.ID, "20260715120000-bbbbbbb") {
+		t.Fatal("another block should still trigger the automation")
+	}
+}
+
+func TestFireTagAutomationUnmarksOnFailure(t *testing.T) {
+	useTestAutomations(t)
+
+	created, err := SaveAutomation(&Automation{
+		Prompt:  "Process tagged block",
+		Trigger: &AutomationTrigger{Type: AutomationTriggerTag, Tag: "inbox"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 自动化运行中时启动失败，块不应被记录为已触发
+	automationsRunning.Store(created.ID, true)
+	t.Cleanup(func() { automationsRunning.Delete(created.ID) })
+	fireTagAutomation(created, automationEvent{blockID: "20260715120000-aaaaaaa"})
+	if !markAutomationFired(created.ID, "20260715120000-aaaaaaa") {
+		t.Fatal("a block must trigger the automation again after a failed start")
+	}
+}
+
+func TestNodeTags(t *testing.T) {
+	luteEngine := util.NewLute()
+	tree := luteEngine.BlockDOM2Tree(luteEngine.Md2BlockDOM("before #inbox# after #project/a#", true))
+	paragraph := tree.Root.FirstChild
+	if paragraph == nil || paragraph.Type != ast.NodeParagraph {
+		t.Fatalf("unexpected tree: %#v", tree.Root)
+	}
+	if tags := nodeTags(paragraph); !slices.Equal(tags, []string{"inbox", "project/a"}) {
+		t.Fatalf("unexpected tags: %#v", tags)
+	}
+	if tags := nodeTags(tree.Root); len(tags) != 0 {
+		t.Fatalf("container blocks must not report child tags: %#v", tags)
+	}
+}
+
+func TestUnattendedRunFiltersAndRejectsTools(t *testing.T) {
+	tools := []openai.Tool{
+		{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "sql"}},
+		{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "block"}},
+		{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "question"}},
+		{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "web_fetch"}},
+	}
+	var interactive *unattendedRun
+	if len(interactive.filterTools(tools)) != len(tools) || interactive.reject("block", "delete", map[string]bool{}) != "" {
+		t.Fatal("interactive sessions must not be restricted")
+	}
+
+	run := &unattendedRun{tools: map[string]bool{"sql": true, "block": true, "question": true}}
+	var names []string
+	for _, tool := range run.filterTools(tools) {
+		names = append(names, tool.Function.Name)
+	}
+	if !slices.Equal(names, []string{"sql", "block"}) {
+		t.Fatalf("unexpected unattended tools: %#v", names)
+	}
+	if run.reject("web_fetch", "", map[string]bool{}) == "" || run.reject("question", "", map[string]bool{}) == "" {
+		t.Fatal("tools outside the allowlist and interactive tools must be rejected")
+	}
+	if run.reject("sql", "", map[string]bool{}) != "" {
+		t.Fatal("allowed tools without confirmation must run")
+	}
+	if run.reject("block", "delete", map[string]bool{}) == "" {
+		t.Fatal("deny policy must reject calls that need confirmation")
+	}
+	run.allowConfirm = true
+	if run.reject("block", "delete", map[string]bool{}) != "" {
+		t.Fatal("allow policy must approve calls that need confirmation")
+	}
+
+	ctx := withUnattendedRun(context.Background(), run)
+	if unattendedRunFrom(ctx) != run || unattendedRunFrom(context.Background()) != nil {
+		t.Fatal("unattended run was not carried by context")
+	}
+}
+
+func TestCommitAutomationSession(t *testing.T) {
+	useTestAutomations(t)
+
+	automation := &Automation{ID: "20260715120000-ccccccc", Name: "digest", Prompt: "Write a digest", Trigger: &AutomationTrigger{Type: AutomationTriggerDailyNote}}
+	event := automationEvent{box: "20260715120000-ddddddd", docID: "20260715120000-eeeeeee"}
+	sessionID, userEntryID, revision, err := createAutomationSession(automation, event, time.Date(2026, 7, 15, 9, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sessionLocks.Delete(sessionID) })
+
+	turn := &agentRuntimeTurn{
+		TurnID:       "20260715120001-abcdefg",
+		Mode:         "append",
+		UserEntryID:  userEntryID,
+		BaseRevision: revision,
+		State:        "running",
+		Delta: []AgentMessage{
+			{Role: "assistant", Content: "Looking up today's notes", ToolCalls: []AgentToolCall{{ID: "call-1", Name: "sql", Arguments: map[string]any{}, State: "finished"}}},
+			{Role: "assistant", Content: "Today's digest"},
+		},
+	}
+	if err = beginRuntimeTurn(sessionID, turn, false); err != nil {
+		t.Fatal(err)
+	}
+	turn.State = "finished"
+	if err = saveRuntimeTurn(sessionID, turn, false); err != nil {
+		t.Fatal(err)
+	}
+
+	state, content, err := commitAutomationSession(sessionID, turn.TurnID, revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "finished" || content != "Today's digest" {
+		t.Fatalf("unexpected commit result: state=%s, content=%s", state, content)
+	}
+	session, err := GetSessionState(sessionID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session["lastCommittedTurnID"] != turn.TurnID || session["automationID"] != automation.ID || session["title"] != "digest 2026-07-15 09:00" {
+		t.Fatalf("automation session was not committed: %#v", session)
+	}
+	entries := session["entries"].([]any)
+	user := entries[0].(map[string]any)
+	editorCtx := user["editorContext"].(map[string]any)
+	if editorCtx["activeDocID"] != event.docID || editorCtx["notebookID"] != event.box {
+		t.Fatalf("trigger context was not recorded on the user entry: %#v", user)
+	}
+	if automationTargetDocID(automation, event) != event.docID {
+		t.Fatal("daily note automations without a target should write into the new daily note")
+	}
+}
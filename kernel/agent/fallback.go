@@ -0,0 +1,292 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package agent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/logging"
+	kernelConf "github.com/siyuan-note/siyuan/kernel/conf"
+	kernelModel "github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ModelCandidate 是 Agent 会话可用的一个模型。首个候选为会话主模型，其余按故障转移链顺序排列。
+type ModelCandidate struct {
+	ID             string // 配置中的模型 ID，用于健康状态统计
+	Name           string // 请求时发送给服务商的模型名
+	Provider       string // 服务商名称，用于用量统计
+	Client         *openai.Client
+	RequestTimeout time.Duration
+	Fallback       *kernelConf.AgentFallback // 主模型为 nil
+}
+
+// NewModelCandidate 根据服务商和模型配置创建候选，fallback 为 nil 表示主模型。
+func NewModelCandidate(provider *kernelConf.Provider, selectedModel *kernelConf.Model, fallback *kernelConf.AgentFallback) *ModelCandidate {
+	// Provider 请求超时只限制建立上游流；流建立后由可重置的空闲超时检测连续无输出，
+	// 避免持续正常输出的长回答被固定截止时间中断。
+	requestTimeout := time.Duration(provider.RequestTimeout) * time.Second
+	if requestTimeout <= 0 {
+		requestTimeout = 30 * time.Second
+	}
+	return &ModelCandidate{
+		ID:             selectedModel.ID,
+		Name:           selectedModel.Name,
+		Provider:       kernelModel.AIProviderName(provider),
+		Client:         util.NewAIClient(provider.Protocol, provider.APIKey, provider.BaseURL, selectedModel.Name),
+		RequestTimeout: requestTimeout,
+		Fallback:       fallback,
+	}
+}
+
+// ModelCandidates 返回以 selectedModel 为主模型、按 Agent 故障转移链追加候选的模型列表。
+func ModelCandidates(provider *kernelConf.Provider, selectedModel *kernelConf.Model) []*ModelCandidate {
+	ret := []*ModelCandidate{NewModelCandidate(provider, selectedModel, nil)}
+	for _, fallback := range kernelModel.Conf.AI.Agent.Fallbacks {
+		// 未启用或已删除的模型直接跳过，不影响主模型对话
+		fallbackProvider, fallbackModel := kernelModel.Conf.AI.GetModel(fallback.ModelID)
+		if nil == fallbackProvider || nil == fallbackModel || fallbackModel.ID == selectedModel.ID {
+			continue
+		}
+		ret = append(ret, NewModelCandidate(fallbackProvider, fallbackModel, fallback))
+	}
+	return ret
+}
+
+// agentModelSwitch 记录一次会话中途的模型切换，提交 turn 时写入会话 entries。
+type agentModelSwitch struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Reason    string `json:"reason"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// modelChain 维护一个 turn 内的当前模型。切换后在该 turn 后续轮次中继续使用新模型，下一条消息重新从主模型开始。
+type modelChain struct {
+	candidates []*ModelCandidate
+	current    int
+	started    bool
+	switches   []agentModelSwitch
+}
+
+func newModelChain(candidates []*ModelCandidate) *modelChain {
+	return &modelChain{candidates: candidates}
+}
+
+func (chain *modelChain) model() *ModelCandidate {
+	return chain.candidates[chain.current]
+}
+
+// next 返回当前模型之后第一个命中错误分类、不在冷却期且未超出预算的候选下标，没有时返回 -1。
+func (chain *modelChain) next(category string, now time.Time) int {
+	for i := chain.current + 1; i < len(chain.candidates); i++ {
+		candidate := chain.candidates[i]
+		if nil == candidate.Fallback || !candidate.Fallback.Matches(category) {
+			continue
+		}
+		if isModelCoolingDown(candidate.ID, now) {
+			continue
+		}
+		if err := kernelModel.CheckAIBudget(candidate.Name); err != nil {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+func (chain *modelChain) switchTo(index int, reason string, ch chan<- AgentEvent) {
+	from := chain.model()
+	chain.current = index
+	to := chain.model()
+	chain.switches = append(chain.switches, agentModelSwitch{From: from.Name, To: to.Name, Reason: reason, Timestamp: time.Now().UnixMilli()})
+	sendEvent(ch, AgentEvent{Type: "fallback", FromModel: from.Name, ToModel: to.Name, FallbackReason: reason})
+}
+
+// createStream 在当前模型上建立流式请求，重试耗尽后按故障转移链切换模型。
+// turn 开始时若主模型仍在冷却期，直接切换到其最近失败原因对应的可用候选。
+// 每轮请求前检查当前模型的预算，turn 中途用量超出预算时在下一轮停止。
+func (chain *modelChain) createStream(ctx context.Context, req openai.ChatCompletionRequest, maxRetries int, streamIdleTimeout time.Duration, retryDelay func(string, int) time.Duration, ch chan<- AgentEvent) (*openai.ChatCompletionStream, openai.ChatCompletionStreamResponse, context.CancelFunc, error) {
+	if !chain.started {
+		chain.started = true
+		now := time.Now()
+		if health := getModelHealth(chain.model().ID); nil != health && health.CooldownUntil > now.UnixMilli() {
+			if index := chain.next(health.LastError, now); index >= 0 {
+				logging.LogWarnf("agent model [%s] is cooling down after %s, using [%s]", chain.model().Name, health.LastError, chain.candidates[index].Name)
+				chain.switchTo(index, health.LastError, ch)
+			}
+		}
+	}
+
+	if err := kernelModel.CheckAIBudget(chain.model().Name); err != nil {
+		return nil, openai.ChatCompletionStreamResponse{}, nil, err
+	}
+
+	for {
+		candidate := chain.model()
+		req.Model = candidate.Name
+		stream, firstResp, cancel, err := createStreamWithRetry(ctx, candidate.Client, req, maxRetries, candidate.RequestTimeout, streamIdleTimeout, retryDelay, ch)
+		if err == nil {
+			recordModelSuccess(candidate.ID)
+			return stream, firstResp, cancel, nil
+		}
+		if ctx.Err() != nil {
+			return nil, openai.ChatCompletionStreamResponse{}, nil, err
+		}
+
+		category := classifyFallback(err)
+		recordModelFailure(candidate.ID, category)
+		index := chain.next(category, time.Now())
+		if index < 0 {
+			return nil, openai.ChatCompletionStreamResponse{}, nil, err
+		}
+		logging.LogWarnf("agent model [%s] failed (%s), falling back to [%s]: %s", candidate.Name, category, chain.candidates[index].Name, err)
+		chain.switchTo(index, category, ch)
+	}
+}
+
+// classifyFallback 在重试分类的基础上区分上下文超长，便于配置切换到更长上下文的模型。
+func classifyFallback(err error) string {
+	if isContextOverflow(err) {
+		return kernelConf.FallbackOnContextOverflow
+	}
+	return classifyRetry(err)
+}
+
+// ModelHealth 是某个模型最近的可用性统计，仅保存在内存中，内核重启后清空。
+type ModelHealth struct {
+	ModelID             string `json:"modelID"`
+	Successes           int    `json:"successes"`
+	Failures            int    `json:"failures"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+	LastSuccessAt       int64  `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       int64  `json:"lastFailureAt,omitempty"`
+	CooldownUntil       int64  `json:"cooldownUntil,omitempty"`
+}
+
+const (
+	rateLimitCooldown   = time.Minute
+	failureCooldownBase = 30 * time.Second
+	failureCooldownMax  = 10 * time.Minute
+)
+
+var (
+	modelHealthLock sync.Mutex
+	modelHealth     = map[string]*ModelHealth{}
+)
+
+// ModelHealthSnapshot 返回所有已记录模型的健康状态副本，按模型 ID 排序。
+func ModelHealthSnapshot() (ret []*ModelHealth) {
+	modelHealthLock.Lock()
+	defer modelHealthLock.Unlock()
+
+	ret = make([]*ModelHealth, 0, len(modelHealth))
+	for _, health := range modelHealth {
+		cloned := *health
+		ret = append(ret, &cloned)
+	}
+	slices.SortFunc(ret, func(a, b *ModelHealth) int {
+		if a.ModelID < b.ModelID {
+			return -1
+		}
+		if a.ModelID > b.ModelID {
+			return 1
+		}
+		return 0
+	})
+	return
+}
+
+func getModelHealth(modelID string) *ModelHealth {
+	modelHealthLock.Lock()
+	defer modelHealthLock.Unlock()
+
+	health := modelHealth[modelID]
+	if nil == health {
+		return nil
+	}
+	cloned := *health
+	return &cloned
+}
+
+func isModelCoolingDown(modelID string, now time.Time) bool {
+	health := getModelHealth(modelID)
+	return nil != health && health.CooldownUntil > now.UnixMilli()
+}
+
+func recordModelSuccess(modelID string) {
+	if modelID == "" {
+		return
+	}
+
+	modelHealthLock.Lock()
+	defer modelHealthLock.Unlock()
+
+	health := modelHealth[modelID]
+	if nil == health {
+		health = &ModelHealth{ModelID: modelID}
+		modelHealth[modelID] = health
+	}
+	health.Successes++
+	health.ConsecutiveFailures = 0
+	health.LastSuccessAt = time.Now().UnixMilli()
+	health.CooldownUntil = 0
+}
+
+// recordModelFailure 只统计反映模型可用性的错误。上下文超长与请求本身有关，鉴权等致命错误需要用户修改配置，
+// 二者都不进入冷却期。
+func recordModelFailure(modelID, category string) {
+	if modelID == "" {
+		return
+	}
+	switch category {
+	case kernelConf.FallbackOnRateLimit, kernelConf.FallbackOnServerError, kernelConf.FallbackOnTimeout, kernelConf.FallbackOnNetwork:
+	default:
+		return
+	}
+
+	modelHealthLock.Lock()
+	defer modelHealthLock.Unlock()
+
+	health := modelHealth[modelID]
+	if nil == health {
+		health = &ModelHealth{ModelID: modelID}
+		modelHealth[modelID] = health
+	}
+	now := time.Now()
+	health.Failures++
+	health.ConsecutiveFailures++
+	health.LastError = category
+	health.LastFailureAt = now.UnixMilli()
+	health.CooldownUntil = now.Add(modelCooldown(category, health.ConsecutiveFailures)).UnixMilli()
+}
+
+func modelCooldown(category string, consecutiveFailures int) time.Duration {
+	if category == kernelConf.FallbackOnRateLimit {
+		return rateLimitCooldown
+	}
+	cooldown := failureCooldownBase
+	for i := 1; i < consecutiveFailures && cooldown < failureCooldownMax; i++ {
+		cooldown *= 2
+	}
+	return min(cooldown, failureCooldownMax)
+}
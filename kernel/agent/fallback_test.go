@@ -0,0 +1,275 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kernelConf "github.com/siyuan-note/siyuan/kernel/conf"
+	kernelModel "github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func resetModelHealth(t *testing.T) {
+	modelHealthLock.Lock()
+	modelHealth = map[string]*ModelHealth{}
+	modelHealthLock.Unlock()
+	t.Cleanup(func() {
+		modelHealthLock.Lock()
+		modelHealth = map[string]*ModelHealth{}
+		modelHealthLock.Unlock()
+	})
+}
+
+func newStatusServer(t *testing.T, status int, body string, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newStreamServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		flusher := prepareTestStream(t, w)
+		writeTestStreamChunk(t, w, flusher, "ok")
+		writeTestStreamDone(t, w, flusher)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestModelChainFallsBackOnMatchingCondition(t *testing.T) {
+	resetModelHealth(t)
+	var primaryRequests, fallbackRequests atomic.Int32
+	primary := newStatusServer(t, http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, &primaryRequests)
+	fallback := newStreamServer(t, &fallbackRequests)
+
+	chain := newModelChain([]*ModelCandidate{
+		{ID: "primary", Name: "primary-model", Client: newTestOpenAIClient(primary.URL), RequestTimeout: time.Second},
+		{ID: "backup", Name: "backup-model", Client: newTestOpenAIClient(fallback.URL), RequestTimeout: time.Second,
+			Fallback: &kernelConf.AgentFallback{ModelID: "backup", Conditions: []string{kernelConf.FallbackOnRateLimit}}},
+	})
+	ch := make(chan AgentEvent, 8)
+	stream, _, cancel, err := chain.createStream(context.Background(), testChatRequest(), 1, time.Second, noRetryDelay, ch)
+	if err != nil {
+		t.Fatalf("create stream failed: %v", err)
+	}
+	defer cancel()
+	defer stream.Close()
+
+	if primaryRequests.Load() != 2 || fallbackRequests.Load() != 1 {
+		t.Fatalf("requests: primary=%d, fallback=%d", primaryRequests.Load(), fallbackRequests.Load())
+	}
+	if chain.model().ID != "backup" || len(chain.switches) != 1 {
+		t.Fatalf("chain did not switch: current=%s, switches=%#v", chain.model().ID, chain.switches)
+	}
+	if s := chain.switches[0]; s.From != "primary-model" || s.To != "backup-model" || s.Reason != kernelConf.FallbackOnRateLimit {
+		t.Fatalf("unexpected switch: %#v", s)
+	}
+	fallbackEventSeen := false
+	for len(ch) > 0 {
+		if event := <-ch; event.Type == "fallback" && event.ToModel == "backup-model" {
+			fallbackEventSeen = true
+		}
+	}
+	if !fallbackEventSeen {
+		t.Fatal("fallback event was not sent")
+	}
+
+	primaryHealth, backupHealth := getModelHealth("primary"), getModelHealth("backup")
+	if nil == primaryHealth || primaryHealth.Failures != 1 || primaryHealth.LastError != kernelConf.FallbackOnRateLimit || primaryHealth.CooldownUntil <= time.Now().UnixMilli() {
+		t.Fatalf("unexpected primary health: %#v", primaryHealth)
+	}
+	if nil == backupHealth || backupHealth.Successes != 1 {
+		t.Fatalf("unexpected backup health: %#v", backupHealth)
+	}
+}
+
+func TestModelChainKeepsErrorWhenConditionDoesNotMatch(t *testing.T) {
+	resetModelHealth(t)
+	var primaryRequests, fallbackRequests atomic.Int32
+	primary := newStatusServer(t, http.StatusBadRequest, `{"error":{"message":"This model's maximum context length is 8192 tokens","code":"context_length_exceeded"}}`, &primaryRequests)
+	fallback := newStreamServer(t, &fallbackRequests)
+
+	chain := newModelChain([]*ModelCandidate{
+		{ID: "primary", Name: "primary-model", Client: newTestOpenAIClient(primary.URL), RequestTimeout: time.Second},
+		{ID: "backup", Name: "backup-model", Client: newTestOpenAIClient(fallback.URL), RequestTimeout: time.Second,
+			Fallback: &kernelConf.AgentFallback{ModelID: "backup"}},
+	})
+	_, _, _, err := chain.createStream(context.Background(), testChatRequest(), 2, time.Second, noRetryDelay, make(chan AgentEvent, 8))
+	if err == nil || !isContextOverflow(err) {
+		t.Fatalf("expected context overflow error, got %v", err)
+	}
+	if primaryRequests.Load() != 1 || fallbackRequests.Load() != 0 || chain.model().ID != "primary" {
+		t.Fatalf("unexpected fallback: primary=%d, fallback=%d, current=%s", primaryRequests.Load(), fallbackRequests.Load(), chain.model().ID)
+	}
+	if health := getModelHealth("primary"); nil != health {
+		t.Fatalf("context overflow should not affect health: %#v", health)
+	}
+
+	// 配置了上下文超长条件时切换到更长上下文的模型
+	chain = newModelChain([]*ModelCandidate{
+		{ID: "primary", Name: "primary-model", Client: newTestOpenAIClient(primary.URL), RequestTimeout: time.Second},
+		{ID: "backup", Name: "backup-model", Client: newTestOpenAIClient(fallback.URL), RequestTimeout: time.Second,
+			Fallback: &kernelConf.AgentFallback{ModelID: "backup", Conditions: []string{kernelConf.FallbackOnContextOverflow}}},
+	})
+	stream, _, cancel, err := chain.createStream(context.Background(), testChatRequest(), 2, time.Second, noRetryDelay, make(chan AgentEvent, 8))
+	if err != nil {
+		t.Fatalf("create stream failed: %v", err)
+	}
+	defer cancel()
+	defer stream.Close()
+	if chain.model().ID != "backup" || chain.switches[0].Reason != kernelConf.FallbackOnContextOverflow {
+		t.Fatalf("chain did not switch on context overflow: %#v", chain.switches)
+	}
+}
+
+func TestModelChainSkipsCoolingDownPrimary(t *testing.T) {
+	resetModelHealth(t)
+	recordModelFailure("primary", kernelConf.FallbackOnServerError)
+
+	var primaryRequests, fallbackRequests atomic.Int32
+	primary := newStreamServer(t, &primaryRequests)
+	fallback := newStreamServer(t, &fallbackRequests)
+	chain := newModelChain([]*ModelCandidate{
+		{ID: "primary", Name: "primary-model", Client: newTestOpenAIClient(primary.URL), RequestTimeout: time.Second},
+		{ID: "backup", Name: "backup-model", Client: newTestOpenAIClient(fallback.URL), RequestTimeout: time.Second,
+			Fallback: &kernelConf.AgentFallback{ModelID: "backup"}},
+	})
+	stream, _, cancel, err := chain.createStream(context.Background(), testChatRequest(), 0, time.Second, noRetryDelay, make(chan AgentEvent, 8))
+	if err != nil {
+		t.Fatalf("create stream failed: %v", err)
+	}
+	defer cancel()
+	defer stream.Close()
+	if primaryRequests.Load() != 0 || fallbackRequests.Load() != 1 {
+		t.Fatalf("cooling down primary was used: primary=%d, fallback=%d", primaryRequests.Load(), fallbackRequests.Load())
+	}
+}
+
+func TestModelChainChecksBudgetPerCandidateAndRound(t *testing.T) {
+	resetModelHealth(t)
+	originalConf, originalDataDir := kernelModel.Conf, util.DataDir
+	t.Cleanup(func() { kernelModel.Conf, util.DataDir = originalConf, originalDataDir })
+	util.DataDir = t.TempDir()
+	kernelModel.Conf = kernelModel.NewAppConf()
+	kernelModel.Conf.AI = kernelConf.NewAI()
+	kernelModel.Conf.AI.Usage.Prices = []*kernelConf.ModelPrice{{Model: "primary-model", Input: 1}, {Model: "backup-model", Input: 1}}
+	kernelModel.Conf.AI.Usage.Budgets = []*kernelConf.Budget{
+		{Model: "primary-model", Amount: 1, Action: kernelConf.BudgetActionBlock},
+		{Model: "backup-model", Amount: 1, Action: kernelConf.BudgetActionBlock},
+	}
+	kernelModel.RecordAIUsage(kernelModel.AIFeatureAgent, "OpenAI", "backup-model", "", util.AIUsage{PromptTokens: 2000000})
+
+	// 超出预算的候选不参与故障转移
+	var primaryRequests, fallbackRequests atomic.Int32
+	primary := newStatusServer(t, http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, &primaryRequests)
+	fallback := newStreamServer(t, &fallbackRequests)
+	chain := newModelChain([]*ModelCandidate{
+		{ID: "primary", Name: "primary-model", Client: newTestOpenAIClient(primary.URL), RequestTimeout: time.Second},
+		{ID: "backup", Name: "backup-model", Client: newTestOpenAIClient(fallback.URL), RequestTimeout: time.Second,
+			Fallback: &kernelConf.AgentFallback{ModelID: "backup", Conditions: []string{kernelConf.FallbackOnRateLimit}}},
+	})
+	if _, _, _, err := chain.createStream(context.Background(), testChatRequest(), 0, time.Second, noRetryDelay, make(chan AgentEvent, 8)); err == nil {
+		t.Fatal("expected the primary error without a fallback over budget")
+	}
+	if primaryRequests.Load() != 1 || fallbackRequests.Load() != 0 || len(chain.switches) != 0 {
+		t.Fatalf("fallback over budget was used: primary=%d, fallback=%d", primaryRequests.Load(), fallbackRequests.Load())
+	}
+
+	// turn 中途用量超出预算时，后续轮次不再请求
+	var requests atomic.Int32
+	server := newStreamServer(t, &requests)
+	chain = newModelChain([]*ModelCandidate{{ID: "primary", Name: "primary-model", Client: newTestOpenAIClient(server.URL), RequestTimeout: time.Second}})
+	stream, _, cancel, err := chain.createStream(context.Background(), testChatRequest(), 0, time.Second, noRetryDelay, make(chan AgentEvent, 8))
+	if err != nil {
+		t.Fatalf("create stream failed: %v", err)
+	}
+	cancel()
+	stream.Close()
+	kernelModel.RecordAIUsage(kernelModel.AIFeatureAgent, "OpenAI", "primary-model", "", util.AIUsage{PromptTokens: 2000000})
+	if _, _, _, err = chain.createStream(context.Background(), testChatRequest(), 0, time.Second, noRetryDelay, make(chan AgentEvent, 8)); !errors.Is(err, kernelModel.ErrAIBudgetExceeded) {
+		t.Fatalf("expected budget error in the next round, got %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("round over budget was requested: %d", requests.Load())
+	}
+}
+
+func TestModelCooldown(t *testing.T) {
+	tests := []struct {
+		category string
+		failures int
+		want     time.Duration
+	}{
+		{kernelConf.FallbackOnRateLimit, 5, time.Minute},
+		{kernelConf.FallbackOnServerError, 1, 30 * time.Second},
+		{kernelConf.FallbackOnTimeout, 3, 2 * time.Minute},
+		{kernelConf.FallbackOnNetwork, 20, 10 * time.Minute},
+	}
+	for _, test := range tests {
+		if got := modelCooldown(test.category, test.failures); got != test.want {
+			t.Errorf("modelCooldown(%s, %d) = %s, want %s", test.category, test.failures, got, test.want)
+		}
+	}
+
+	resetModelHealth(t)
+	recordModelFailure("m", kernelConf.FallbackOnTimeout)
+	recordModelSuccess("m")
+	if health := getModelHealth("m"); health.ConsecutiveFailures != 0 || health.CooldownUntil != 0 || health.Failures != 1 || health.Successes != 1 {
+		t.Fatalf("success did not reset health: %#v", health)
+	}
+	if snapshot := ModelHealthSnapshot(); len(snapshot) != 1 || snapshot[0].ModelID != "m" {
+		t.Fatalf("unexpected snapshot: %#v", snapshot)
+	}
+}
+
+func TestApplyRuntimeRecordsModelSwitches(t *testing.T) {
+	session := map[string]any{
+		"entries": []any{map[string]any{"id": "user-1", "type": "user", "content": "hello"}},
+	}
+	turn := &agentRuntimeTurn{
+		TurnID:        "20260715120008-abcdefg",
+		UserEntryID:   "user-1",
+		Delta:         []AgentMessage{{Role: "assistant", Content: "answer"}},
+		ModelSwitches: []agentModelSwitch{{From: "primary-model", To: "backup-model", Reason: kernelConf.FallbackOnTimeout, Timestamp: 1}},
+	}
+	for range 2 {
+		if err := applyRuntimeTurnToSessionLocked(session, turn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries := session["entries"].([]any)
+	if len(entries) != 3 {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+	entry := entries[2].(map[string]any)
+	if entry["type"] != "fallback" || entry["fromModel"] != "primary-model" || entry["toModel"] != "backup-model" || entry["reason"] != kernelConf.FallbackOnTimeout {
+		t.Fatalf("unexpected fallback entry: %#v", entry)
+	}
+}
@@ -41,25 +41,26 @@ type agentRuntime struct {
 }
 
 type agentRuntimeTurn struct {
-	TurnID            string         `json:"turnID"`
-	Mode              string         `json:"mode"`
-	UserEntryID       string         `json:"userEntryID"`
-	TargetUserEntryID string         `json:"targetUserEntryID,omitempty"`
-	UserContent       string         `json:"userContent,omitempty"`
-	UserReferences    *[]Reference   `json:"userReferences,omitempty"`
-	UserEditorContext *EditorContext `json:"userEditorContext,omitempty"`
-	BaseRevision      int64          `json:"baseRevision"`
-	State             string         `json:"state"`
-	Delta             []AgentMessage `json:"delta,omitempty"`
-	DraftContent      string         `json:"draftContent,omitempty"`
-	SnapshotIDs       []string       `json:"snapshotIDs,omitempty"`
-	PromptTokens      int            `json:"promptTokens,omitempty"`
-	CompletionTokens  int            `json:"completionTokens,omitempty"`
-	LastPromptTokens  int            `json:"lastPromptTokens,omitempty"`
-	CachedTokens      int            `json:"cachedTokens,omitempty"`
-	ContextLimit      int            `json:"contextLimit,omitempty"`
-	TokenBreakdown    map[string]int `json:"tokenBreakdown,omitempty"`
-	UpdatedAt         int64          `json:"updatedAt"`
+	TurnID            string             `json:"turnID"`
+	Mode              string             `json:"mode"`
+	UserEntryID       string             `json:"userEntryID"`
+	TargetUserEntryID string             `json:"targetUserEntryID,omitempty"`
+	UserContent       string             `json:"userContent,omitempty"`
+	UserReferences    *[]Reference       `json:"userReferences,omitempty"`
+	UserEditorContext *EditorContext     `json:"userEditorContext,omitempty"`
+	BaseRevision      int64              `json:"baseRevision"`
+	State             string             `json:"state"`
+	Delta             []AgentMessage     `json:"delta,omitempty"`
+	DraftContent      string             `json:"draftContent,omitempty"`
+	SnapshotIDs       []string           `json:"snapshotIDs,omitempty"`
+	ModelSwitches     []agentModelSwitch `json:"modelSwitches,omitempty"`
+	PromptTokens      int                `json:"promptTokens,omitempty"`
+	CompletionTokens  int                `json:"completionTokens,omitempty"`
+	LastPromptTokens  int                `json:"lastPromptTokens,omitempty"`
+	CachedTokens      int                `json:"cachedTokens,omitempty"`
+	ContextLimit      int                `json:"contextLimit,omitempty"`
+	TokenBreakdown    map[string]int     `json:"tokenBreakdown,omitempty"`
+	UpdatedAt         int64              `json:"updatedAt"`
 }
 
 type runtimeCompaction struct {
@@ -398,18 +399,36 @@ func applyRuntimeTurnToSessionLocked(session map[string]any, turn *agentRuntimeT
 				merged = append(merged, authoritative[authoritativeIndex])
 				authoritativeIndex++
 			}
-		case "thinking", "confirm", "question", "snapshot", "rollback":
+		case "thinking", "confirm", "question", "snapshot", "rollback", "fallback":
 			merged = append(merged, raw)
 		}
 	}
 	merged = append(merged, authoritative[authoritativeIndex:]...)
 
 	existingSnapshots := map[string]bool{}
+	existingIDs := map[string]bool{}
 	for _, raw := range merged {
 		entry, _ := raw.(map[string]any)
 		if snapshotID, _ := entry["snapshotID"].(string); snapshotID != "" {
 			existingSnapshots[snapshotID] = true
 		}
+		if id, _ := entry["id"].(string); id != "" {
+			existingIDs[id] = true
+		}
+	}
+	for i, modelSwitch := range turn.ModelSwitches {
+		id := fmt.Sprintf("runtime_fallback_%s_%d", turn.TurnID, i)
+		if existingIDs[id] {
+			continue
+		}
+		merged = append(merged, map[string]any{
+			"id":        id,
+			"type":      "fallback",
+			"fromModel": modelSwitch.From,
+			"toModel":   modelSwitch.To,
+			"reason":    modelSwitch.Reason,
+			"timestamp": modelSwitch.Timestamp,
+		})
 	}
 	for i, snapshotID := range turn.SnapshotIDs {
 		if existingSnapshots[snapshotID] {
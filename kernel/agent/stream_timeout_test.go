@@ -210,7 +210,8 @@ func TestAgentChatPartialStreamTimeoutSavesInterruptedWithoutRetry(t *testing.T)
 	}))
 	defer server.Close()
 
-	events := AgentChat(context.Background(), newTestOpenAIClient(server.URL), "test-model", testSessionID, "user-1", 1, "hello", "English", nil, EditorContext{}, nil, false, time.Second, 3, "", time.Second, 50*time.Millisecond)
+	models := []*ModelCandidate{{ID: "test-model", Name: "test-model", Client: newTestOpenAIClient(server.URL), RequestTimeout: time.Second}}
+	events := AgentChat(context.Background(), models, testSessionID, "user-1", 1, "hello", "English", nil, EditorContext{}, nil, false, time.Second, 3, "", 50*time.Millisecond)
 	contentSeen := false
 	errorSeen := false
 	for event := range events {
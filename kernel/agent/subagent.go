@@ -0,0 +1,499 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package agent
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	kernelModel "github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	maxSubAgentTasks = 8 // 一次委派最多拆分的子任务数
+
+	subAgentPrompt = `
+
+## Sub-agent Mode
+You are a sub-agent started by another SiYuan AI assistant to handle one focused part of a larger task.
+- You cannot see the parent conversation; rely only on the instruction and context below.
+- You cannot ask the user questions. Work autonomously with the tools provided.
+- Operations that need user confirmation may be rejected; if so, describe what should be done instead of retrying.
+- When done, reply with a concise final summary of your findings or changes, including the IDs of relevant blocks and documents. Only this final reply is returned to the parent agent.`
+)
+
+// subAgentExcludedTools 在子智能体中始终不可用：委派不可嵌套，question/frontend 需要用户交互，
+// todo_write 会覆盖父会话的任务列表。
+var subAgentExcludedTools = map[string]bool{"delegate": true, "question": true, "frontend": true, "todo_write": true}
+
+// SubAgentTranscript 是一个子智能体的完整运行记录，保存在父会话目录下，随父会话一起删除。
+type SubAgentTranscript struct {
+	ID               string         `json:"id"`
+	TurnID           string         `json:"turnID"`     // 发起委派的父会话 turn
+	ToolCallID       string         `json:"toolCallID"` // 发起委派的父会话工具调用
+	Instruction      string         `json:"instruction"`
+	Context          string         `json:"context,omitempty"`
+	Tools            []string       `json:"tools"`
+	Model            string         `json:"model"`
+	State            string         `json:"state"` // running、finished、interrupted、failed
+	Summary          string         `json:"summary"`
+	Error            string         `json:"error,omitempty"`
+	Messages         []AgentMessage `json:"messages,omitempty"`
+	PromptTokens     int            `json:"promptTokens"`
+	CompletionTokens int            `json:"completionTokens"`
+	StartedAt        int64          `json:"startedAt"`
+	FinishedAt       int64          `json:"finishedAt,omitempty"`
+}
+
+// subAgentTask 是 delegate 工具调用中的一个子任务。
+type subAgentTask struct {
+	instruction string
+	context     string
+	tools       []string
+}
+
+// delegation 携带父会话运行一次委派所需的状态。父会话在委派期间阻塞等待，子智能体只读这些字段。
+type delegation struct {
+	sessionID           string
+	turnID              string
+	toolCallID          string
+	language            string
+	models              []*ModelCandidate
+	tools               []openai.Tool // 父会话本轮可用的工具
+	alwaysAllow         map[string]bool
+	run                 *unattendedRun
+	concurrency         int
+	maxRounds           int
+	maxRetries          int
+	streamIdleTimeout   time.Duration
+	temperature         float32
+	maxCompletionTokens int
+	reasoningEffort     string
+	snapshot            func() error // 子智能体首次执行本地写操作前调用，由父会话保证整轮最多打一次快照
+	ch                  chan<- AgentEvent
+}
+
+func parseSubAgentTasks(args map[string]any) (ret []subAgentTask) {
+	items, _ := args["tasks"].([]any)
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		instruction, _ := obj["instruction"].(string)
+		instruction = strings.TrimSpace(instruction)
+		if instruction == "" {
+			continue
+		}
+		task := subAgentTask{instruction: instruction}
+		task.context, _ = obj["context"].(string)
+		task.context = strings.TrimSpace(task.context)
+		if names, ok := obj["tools"].([]any); ok {
+			for _, name := range names {
+				if s, ok := name.(string); ok && strings.TrimSpace(s) != "" {
+					task.tools = append(task.tools, strings.TrimSpace(s))
+				}
+			}
+		}
+		ret = append(ret, task)
+	}
+	return
+}
+
+// subAgentTools 返回子智能体可用的工具：请求的工具与父会话可用工具的交集，未指定时为父会话的全部可用工具。
+func subAgentTools(parentTools []openai.Tool, requested []string) []openai.Tool {
+	ret := make([]openai.Tool, 0, len(parentTools))
+	for _, tool := range parentTools {
+		if tool.Function == nil || subAgentExcludedTools[tool.Function.Name] {
+			continue
+		}
+		if len(requested) > 0 && !slices.Contains(requested, tool.Function.Name) {
+			continue
+		}
+		ret = append(ret, tool)
+	}
+	return ret
+}
+
+// runDelegation 并发运行 delegate 工具调用中的子任务，返回只包含各子智能体最终总结的工具结果，以及子智能体消耗的 tokens。
+// 子智能体使用父会话的 context，父会话取消时一并取消。
+func runDelegation(ctx context.Context, d *delegation, argsJSON string) (result string, isErr bool, promptTokens, completionTokens int) {
+	tasks := parseSubAgentTasks(parseToolArgs(argsJSON))
+	if len(tasks) == 0 {
+		return "delegate error: tasks[] with a non-empty instruction is required", true, 0, 0
+	}
+	if len(tasks) > maxSubAgentTasks {
+		return fmt.Sprintf("delegate error: at most %d tasks are allowed per call, split the work into several calls", maxSubAgentTasks), true, 0, 0
+	}
+
+	transcripts := make([]*SubAgentTranscript, len(tasks))
+	sem := make(chan struct{}, max(d.concurrency, 1))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		tools := subAgentTools(d.tools, task.tools)
+		transcript := &SubAgentTranscript{
+			ID:          ast.NewNodeID(),
+			TurnID:      d.turnID,
+			ToolCallID:  d.toolCallID,
+			Instruction: task.instruction,
+			Context:     task.context,
+			Tools:       []string{},
+			State:       "running",
+		}
+		for _, tool := range tools {
+			transcript.Tools = append(transcript.Tools, tool.Function.Name)
+		}
+		transcripts[i] = transcript
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logging.LogErrorf("sub-agent panic: %v\n%s", r, logging.ShortStack())
+					transcript.State, transcript.Error = "failed", fmt.Sprint(r)
+				}
+			}()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				transcript.State, transcript.Summary = "interrupted", "Operation cancelled"
+				return
+			}
+			runSubAgent(ctx, d, transcript, tools)
+		}()
+	}
+	wg.Wait()
+
+	var sb strings.Builder
+	sb.WriteString("Sub-agent results (full transcripts are attached to this session):\n")
+	for i, transcript := range transcripts {
+		promptTokens += transcript.PromptTokens
+		completionTokens += transcript.CompletionTokens
+		fmt.Fprintf(&sb, "\n### %d. [%s] %s (sub-agent %s)\n", i+1, transcript.State, fallbackTitle(transcript.Instruction), transcript.ID)
+		summary := transcript.Summary
+		if summary == "" {
+			summary = transcript.Error
+		}
+		if summary == "" {
+			summary = "(no summary)"
+		}
+		sb.WriteString(summary)
+		sb.WriteString("\n")
+	}
+	return sb.String(), false, promptTokens, completionTokens
+}
+
+// runSubAgent 运行一个子智能体直到给出最终回复、达到工具调用轮数上限或被取消。
+func runSubAgent(ctx context.Context, d *delegation, transcript *SubAgentTranscript, tools []openai.Tool) {
+	transcript.StartedAt = time.Now().UnixMilli()
+	sendEvent(d.ch, AgentEvent{Type: "subagent", SubAgentID: transcript.ID, SubAgentTask: transcript.Instruction, SubAgentState: transcript.State})
+	saveSubAgentTranscript(d.sessionID, transcript)
+	defer func() {
+		transcript.FinishedAt = time.Now().UnixMilli()
+		saveSubAgentTranscript(d.sessionID, transcript)
+		sendEvent(d.ch, AgentEvent{Type: "subagent", SubAgentID: transcript.ID, SubAgentTask: transcript.Instruction, SubAgentState: transcript.State, Result: transcript.Summary})
+	}()
+
+	userContent := transcript.Instruction
+	if transcript.Context != "" {
+		userContent += "\n\n<context>\n" + transcript.Context + "\n</context>"
+	}
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: buildSystemPrompt(d.language, nil) + subAgentPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: userContent},
+	}
+	transcript.Messages = append(transcript.Messages, AgentMessage{Role: "user", Content: userContent})
+
+	// 子智能体的重试与故障转移事件不推送给前端，避免与父会话的同类事件混淆。
+	events := make(chan AgentEvent, 16)
+	defer close(events)
+	go func() {
+		for range events {
+		}
+	}()
+
+	chain := newModelChain(d.models)
+	var lastContent string
+	for round := 0; d.maxRounds <= 0 || round < d.maxRounds; round++ {
+		if ctx.Err() != nil {
+			transcript.State, transcript.Summary = "interrupted", "Operation cancelled"
+			return
+		}
+
+		req := openai.ChatCompletionRequest{
+			Model:               chain.model().Name,
+			Messages:            messages,
+			Tools:               tools,
+			Stream:              true,
+			StreamOptions:       &openai.StreamOptions{IncludeUsage: true},
+			Temperature:         d.temperature,
+			MaxCompletionTokens: d.maxCompletionTokens,
+			ReasoningEffort:     d.reasoningEffort,
+		}
+		stream, firstResp, roundCancel, err := chain.createStream(ctx, req, d.maxRetries, d.streamIdleTimeout, delayForCategory, events)
+		transcript.Model = chain.model().Name
+		if err != nil {
+			if ctx.Err() != nil {
+				transcript.State, transcript.Summary = "interrupted", "Operation cancelled"
+			} else {
+				transcript.State, transcript.Error = "failed", getAgentErrorMessage(err)
+			}
+			return
+		}
+
+		var contentBuilder strings.Builder
+		var toolCalls []openai.ToolCall
+		resp := firstResp
+		for {
+			for _, choice := range resp.Choices {
+				contentBuilder.WriteString(choice.Delta.Content)
+				toolCalls = appendToolCallDeltas(toolCalls, choice.Delta.ToolCalls)
+			}
+			if resp.Usage != nil {
+				kernelModel.RecordAIUsage(kernelModel.AIFeatureAgent, chain.model().Provider, chain.model().Name, d.sessionID, util.AIUsage{
+					PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens,
+				})
+				transcript.PromptTokens += resp.Usage.PromptTokens
+				transcript.CompletionTokens += resp.Usage.CompletionTokens
+			}
+
+			resp, err = recvStreamWithIdleTimeout(stream, d.streamIdleTimeout, roundCancel)
+			if err != nil {
+				break
+			}
+		}
+		stream.Close()
+		roundCancel()
+		if err != io.EOF {
+			recordModelFailure(chain.model().ID, classifyRetry(err))
+			if ctx.Err() != nil {
+				transcript.State, transcript.Summary = "interrupted", "Operation cancelled"
+			} else {
+				transcript.State, transcript.Error = "failed", getAgentErrorMessage(err)
+			}
+			return
+		}
+
+		content := contentBuilder.String()
+		if content != "" {
+			lastContent = content
+		}
+		toolCalls = filterNamedToolCalls(toolCalls)
+		if len(toolCalls) == 0 {
+			transcript.Messages = append(transcript.Messages, AgentMessage{Role: "assistant", Content: content})
+			transcript.State, transcript.Summary = "finished", strings.TrimSpace(content)
+			return
+		}
+
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content, ToolCalls: toolCalls})
+		assistant := AgentMessage{Role: "assistant", Content: content}
+		for _, tc := range toolCalls {
+			args := parseToolArgs(tc.Function.Arguments)
+			action, _ := args["action"].(string)
+			resultStr, state, unknown := executeSubAgentTool(ctx, d, tools, tc, action)
+			resultStr = wrapToolOutput(util.TruncateToolOutput(resultStr, d.sessionID))
+			messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, Content: resultStr, ToolCallID: tc.ID})
+			assistant.ToolCalls = append(assistant.ToolCalls, AgentToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: args, Result: resultStr, State: state})
+			if unknown {
+				transcript.Messages = append(transcript.Messages, assistant)
+				transcript.State, transcript.Error = "interrupted", toolUnknownResult
+				return
+			}
+		}
+		transcript.Messages = append(transcript.Messages, assistant)
+		saveSubAgentTranscript(d.sessionID, transcript)
+	}
+
+	transcript.State = "interrupted"
+	transcript.Summary = strings.TrimSpace(lastContent)
+	transcript.Error = "Sub-agent reached the tool call round limit"
+}
+
+// executeSubAgentTool 执行子智能体的一次工具调用，返回结果文本、调用状态以及副作用结果是否未知。
+// 子智能体无法向用户确认，需要确认的调用仅在会话已设置始终允许或自动化确认策略为自动批准时执行。
+func executeSubAgentTool(ctx context.Context, d *delegation, tools []openai.Tool, tc openai.ToolCall, action string) (result, state string, unknown bool) {
+	allowed := false
+	for _, tool := range tools {
+		if tool.Function.Name == tc.Function.Name {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "Tool is not available to this sub-agent", "skipped", false
+	}
+	if needsConfirm(tc.Function.Name, action, d.alwaysAllow) && (d.run == nil || !d.run.allowConfirm) {
+		return "Operation requires user confirmation, which is not available to sub-agents. Report it in your summary instead.", "skipped", false
+	}
+	if needsLocalSnapshot(tc.Function.Name, action) {
+		if err := d.snapshot(); err != nil {
+			logging.LogErrorf("sub-agent auto snapshot failed: %s", err)
+			return "Operation aborted due to snapshot failure", "skipped", false
+		}
+	}
+	if ctx.Err() != nil {
+		return "Operation cancelled", "skipped", false
+	}
+
+	result, _, unknown = executeTool(ctx, tc, d.sessionID)
+	state = "finished"
+	if unknown {
+		state = "unknown"
+	}
+	return
+}
+
+// appendToolCallDeltas 把流式响应中的工具调用增量合并到 aggregated。
+func appendToolCallDeltas(aggregated []openai.ToolCall, deltas []openai.ToolCall) []openai.ToolCall {
+	for _, tcd := range deltas {
+		idx := 0
+		if tcd.Index != nil {
+			idx = *tcd.Index
+		}
+		for len(aggregated) <= idx {
+			aggregated = append(aggregated, openai.ToolCall{})
+		}
+		if tcd.ID != "" {
+			aggregated[idx].ID = tcd.ID
+			aggregated[idx].Type = tcd.Type
+		}
+		if tcd.Function.Name != "" {
+			aggregated[idx].Function.Name = tcd.Function.Name
+		}
+		aggregated[idx].Function.Arguments += tcd.Function.Arguments
+	}
+	return aggregated
+}
+
+// filterNamedToolCalls 丢弃没有工具名的残缺调用。
+func filterNamedToolCalls(toolCalls []openai.ToolCall) []openai.ToolCall {
+	filtered := make([]openai.ToolCall, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		if tc.Function.Name != "" {
+			filtered = append(filtered, tc)
+		}
+	}
+	return filtered
+}
+
+func subAgentsDir(sessionID string) string {
+	return filepath.Join(sessionsDir(), sessionID, "subagents")
+}
+
+// saveSubAgentTranscript 把子智能体记录写入父会话目录。与 runtime 一样只附着在已存在的会话上，
+// 避免迟到的写入复活已删除的会话。
+func saveSubAgentTranscript(sessionID string, transcript *SubAgentTranscript) {
+	if sessionID == "" || !isValidSessionID(sessionID) {
+		return
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(transcript, "", "\t")
+	if err != nil {
+		logging.LogErrorf("marshal sub-agent transcript failed: %s", err)
+		return
+	}
+
+	lock := sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+	if _, err = os.Stat(filepath.Join(sessionsDir(), sessionID, "session.json")); err != nil {
+		return
+	}
+	dir := subAgentsDir(sessionID)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		logging.LogErrorf("create sub-agent transcript dir failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(filepath.Join(dir, transcript.ID+".json"), data); err != nil {
+		logging.LogErrorf("save sub-agent transcript failed: %s", err)
+	}
+}
+
+// ListSubAgentTranscripts 返回会话中的子智能体记录（不含消息），按开始时间排序。turnID 不为空时只返回该 turn 的记录。
+func ListSubAgentTranscripts(sessionID, turnID string) ([]*SubAgentTranscript, error) {
+	if sessionID == "" || !isValidSessionID(sessionID) {
+		return nil, fmt.Errorf("invalid session id")
+	}
+	lock := sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ret := []*SubAgentTranscript{}
+	entries, err := os.ReadDir(subAgentsDir(sessionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ret, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		transcript, err := readSubAgentTranscriptLocked(sessionID, strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			logging.LogWarnf("read sub-agent transcript [%s] failed: %s", entry.Name(), err)
+			continue
+		}
+		if turnID != "" && transcript.TurnID != turnID {
+			continue
+		}
+		transcript.Messages = nil
+		ret = append(ret, transcript)
+	}
+	slices.SortFunc(ret, func(a, b *SubAgentTranscript) int { return cmp.Compare(a.StartedAt, b.StartedAt) })
+	return ret, nil
+}
+
+// GetSubAgentTranscript 返回会话中一个子智能体的完整记录。
+func GetSubAgentTranscript(sessionID, id string) (*SubAgentTranscript, error) {
+	if sessionID == "" || !isValidSessionID(sessionID) || !ast.IsNodeIDPattern(id) {
+		return nil, fmt.Errorf("invalid session or sub-agent id")
+	}
+	lock := sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+	return readSubAgentTranscriptLocked(sessionID, id)
+}
+
+func readSubAgentTranscriptLocked(sessionID, id string) (*SubAgentTranscript, error) {
+	data, err := os.ReadFile(filepath.Join(subAgentsDir(sessionID), id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	transcript := &SubAgentTranscript{}
+	if err = gulu.JSON.UnmarshalJSON(data, transcript); err != nil {
+		return nil, err
+	}
+	return transcript, nil
+}
@@ -0,0 +1,243 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	kernelConf "github.com/siyuan-note/siyuan/kernel/conf"
+	kernelModel "github.com/siyuan-note/siyuan/kernel/model"
+)
+
+func useTestSubAgentConf(t *testing.T) {
+	t.Helper()
+	useTestDataDir(t)
+	originalConf := kernelModel.Conf
+	kernelModel.Conf = kernelModel.NewAppConf()
+	kernelModel.Conf.AI = kernelConf.NewAI()
+	kernelModel.Conf.AI.MCP = nil
+	kernelModel.Conf.Variables = kernelConf.NewVariables()
+	t.Cleanup(func() {
+		kernelModel.Conf = originalConf
+	})
+
+	session := map[string]any{
+		"id":        testSessionID,
+		"title":     "delegate test",
+		"createdAt": int64(1),
+		"updatedAt": int64(1),
+		"entries":   []any{map[string]any{"id": "user-1", "type": "user", "content": "hello"}},
+	}
+	if _, err := SaveSession(marshalSession(t, session)); err != nil {
+		t.Fatalf("save initial session failed: %v", err)
+	}
+}
+
+func testTools(names ...string) (ret []openai.Tool) {
+	for _, name := range names {
+		ret = append(ret, openai.Tool{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: name}})
+	}
+	return
+}
+
+func toolNames(tools []openai.Tool) (ret []string) {
+	for _, tool := range tools {
+		ret = append(ret, tool.Function.Name)
+	}
+	return
+}
+
+func testDelegationArgs(t *testing.T, instructions ...string) string {
+	t.Helper()
+	var tasks []map[string]any
+	for _, instruction := range instructions {
+		tasks = append(tasks, map[string]any{"instruction": instruction})
+	}
+	data, err := json.Marshal(map[string]any{"tasks": tasks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSubAgentToolsRestrictsToParentTools(t *testing.T) {
+	parent := testTools("sql", "block", "delegate", "question", "frontend", "todo_write")
+
+	all := toolNames(subAgentTools(parent, nil))
+	if strings.Join(all, ",") != "sql,block" {
+		t.Fatalf("default sub-agent tools = %v, want sql,block", all)
+	}
+
+	requested := toolNames(subAgentTools(parent, []string{"sql", "delegate", "file"}))
+	if strings.Join(requested, ",") != "sql" {
+		t.Fatalf("requested sub-agent tools = %v, want sql", requested)
+	}
+}
+
+func TestExecuteSubAgentToolRejectsUnavailableAndUnconfirmedCalls(t *testing.T) {
+	d := &delegation{alwaysAllow: map[string]bool{}, snapshot: func() error {
+		t.Fatal("rejected calls must not take a snapshot")
+		return nil
+	}}
+	tools := testTools("block")
+
+	tc := openai.ToolCall{ID: "call-1", Function: openai.FunctionCall{Name: "sql", Arguments: `{"stmt":"SELECT 1"}`}}
+	if _, state, _ := executeSubAgentTool(context.Background(), d, tools, tc, ""); state != "skipped" {
+		t.Fatalf("unavailable tool state = %s, want skipped", state)
+	}
+
+	tc = openai.ToolCall{ID: "call-2", Function: openai.FunctionCall{Name: "block", Arguments: `{"action":"delete","id":"20260715120000-abcdefg"}`}}
+	result, state, unknown := executeSubAgentTool(context.Background(), d, tools, tc, "delete")
+	if state != "skipped" || unknown || !strings.Contains(result, "confirmation") {
+		t.Fatalf("unconfirmed write was not rejected: result=%q, state=%s, unknown=%v", result, state, unknown)
+	}
+}
+
+func TestRunDelegationCapsConcurrencyAndAttachesTranscripts(t *testing.T) {
+	useTestSubAgentConf(t)
+
+	var inFlight, peak, requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := peak.Load()
+			if current <= previous || peak.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+
+		body := &openai.ChatCompletionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			t.Errorf("decode request failed: %v", err)
+			return
+		}
+		if len(body.Tools) != 1 || body.Tools[0].Function.Name != "sql" {
+			t.Errorf("sub-agent tools = %#v, want only sql", body.Tools)
+		}
+		instruction := body.Messages[len(body.Messages)-1].Content
+
+		time.Sleep(50 * time.Millisecond)
+		flusher := prepareTestStream(t, w)
+		writeTestStreamChunk(t, w, flusher, "done: "+instruction)
+		writeTestStreamDone(t, w, flusher)
+	}))
+	defer server.Close()
+
+	events := make(chan AgentEvent, 64)
+	d := &delegation{
+		sessionID:   testSessionID,
+		turnID:      "20260715120001-turnaaa",
+		toolCallID:  "call-1",
+		language:    "English",
+		models:      []*ModelCandidate{{ID: "test-model", Name: "test-model", Client: newTestOpenAIClient(server.URL), RequestTimeout: time.Second}},
+		tools:       testTools("sql", "delegate", "question"),
+		alwaysAllow: map[string]bool{},
+		concurrency: 2,
+		maxRounds:   3,
+		snapshot:    func() error { return nil },
+		ch:          events,
+	}
+	result, isErr, _, _ := runDelegation(context.Background(), d, testDelegationArgs(t, "task one", "task two", "task three", "task four"))
+	if isErr {
+		t.Fatalf("delegation failed: %s", result)
+	}
+	if requests.Load() != 4 || peak.Load() > 2 {
+		t.Fatalf("requests=%d peak=%d, want 4 requests with at most 2 in flight", requests.Load(), peak.Load())
+	}
+	for _, instruction := range []string{"task one", "task two", "task three", "task four"} {
+		if !strings.Contains(result, "done: "+instruction) {
+			t.Fatalf("result is missing summary of %q:\n%s", instruction, result)
+		}
+	}
+
+	transcripts, err := ListSubAgentTranscripts(testSessionID, d.turnID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transcripts) != 4 {
+		t.Fatalf("transcript count = %d, want 4", len(transcripts))
+	}
+	for _, transcript := range transcripts {
+		if transcript.State != "finished" || transcript.ToolCallID != "call-1" || transcript.Messages != nil {
+			t.Fatalf("unexpected listed transcript: %#v", transcript)
+		}
+		if !strings.Contains(result, transcript.ID) {
+			t.Fatalf("result does not reference sub-agent %s", transcript.ID)
+		}
+	}
+	full, err := GetSubAgentTranscript(testSessionID, transcripts[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Messages) != 2 || full.Messages[1].Content != full.Summary {
+		t.Fatalf("full transcript messages were not saved: %#v", full.Messages)
+	}
+
+	if err = DeleteSession(testSessionID); err != nil {
+		t.Fatal(err)
+	}
+	if transcripts, err = ListSubAgentTranscripts(testSessionID, ""); err != nil || len(transcripts) != 0 {
+		t.Fatalf("transcripts survived session removal: %v, %v", transcripts, err)
+	}
+}
+
+func TestRunDelegationCancelledWithParent(t *testing.T) {
+	useTestSubAgentConf(t)
+
+	started := make(chan struct{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 读完请求体后服务端才会监测连接关闭，请求取消时 r.Context() 才会结束。
+		_, _ = io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &delegation{
+		sessionID:   testSessionID,
+		turnID:      "20260715120001-turnaaa",
+		language:    "English",
+		models:      []*ModelCandidate{{ID: "test-model", Name: "test-model", Client: newTestOpenAIClient(server.URL), RequestTimeout: 10 * time.Second}},
+		tools:       testTools("sql"),
+		alwaysAllow: map[string]bool{},
+		concurrency: 1,
+		snapshot:    func() error { return nil },
+		ch:          make(chan AgentEvent, 64),
+	}
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	done := make(chan string, 1)
+	go func() {
+		result, _, _, _ := runDelegation(ctx, d, testDelegationArgs(t, "slow one", "slow two"))
+		done <- result
+	}()
+	select {
+	case result := <-done:
+		if strings.Count(result, "[interrupted]") != 2 {
+			t.Fatalf("sub-agents were not interrupted with the parent:\n%s", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("delegation did not stop after the parent was cancelled")
+	}
+}
@@ -184,6 +184,7 @@ func TestQueryToolActionEffects(t *testing.T) {
 		{toolName: "sql", action: "select"},
 		{toolName: "search", action: "fulltext"},
 		{toolName: "search", action: "semantic", needsConfirm: true},
+		{toolName: "search", action: "hybrid", needsConfirm: true},
 		{toolName: "search", action: "asset"},
 		{toolName: "search", action: "getasset"},
 		{toolName: "search", action: "unknown"},
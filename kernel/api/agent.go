@@ -91,7 +91,14 @@ func agentChat(c *gin.Context) {
 		c.JSON(http.StatusOK, ret)
 		return
 	}
-	client := util.NewOpenAIClientWithModel(selectedProvider.APIKey, selectedProvider.BaseURL, selectedModel.Name)
+	if err := model.CheckAIBudget(selectedModel.Name); err != nil {
+		ret := gulu.Ret.NewResult()
+		ret.Code = -1
+		ret.Msg = err.Error()
+		c.JSON(http.StatusOK, ret)
+		return
+	}
+	models := agent.ModelCandidates(selectedProvider, selectedModel)
 
 	confirmTimeout := time.Duration(model.Conf.AI.Agent.ConfirmTimeout) * time.Second
 	if confirmTimeout <= 0 {
@@ -101,12 +108,6 @@ func agentChat(c *gin.Context) {
 	if maxRetries < 0 {
 		maxRetries = 0
 	}
-	// Provider 请求超时只限制建立上游流；流建立后由可重置的空闲超时检测连续无输出，
-	// 避免持续正常输出的长回答被固定截止时间中断。
-	requestTimeout := time.Duration(selectedProvider.RequestTimeout) * time.Second
-	if requestTimeout <= 0 {
-		requestTimeout = 30 * time.Second
-	}
 	streamIdleTimeout := time.Duration(model.Conf.AI.Agent.StreamIdleTimeout) * time.Second
 	if streamIdleTimeout <= 0 {
 		streamIdleTimeout = 120 * time.Second
@@ -134,7 +135,7 @@ func agentChat(c *gin.Context) {
 	if req.ContentRevision != nil {
 		contentRevision = *req.ContentRevision
 	}
-	eventCh := agent.AgentChat(ctx, client, selectedModel.Name, req.SessionID, req.UserEntryID, contentRevision, req.Message, req.Language, req.References, req.EditorContext, req.PluginActions, req.Regenerate, confirmTimeout, maxRetries, req.ReasoningEffort, requestTimeout, streamIdleTimeout)
+	eventCh := agent.AgentChat(ctx, models, req.SessionID, req.UserEntryID, contentRevision, req.Message, req.Language, req.References, req.EditorContext, req.PluginActions, req.Regenerate, confirmTimeout, maxRetries, req.ReasoningEffort, streamIdleTimeout)
 	defer cancel()
 	streamClosed := false
 	defer func() {
@@ -338,9 +339,9 @@ func agentChatTitle(c *gin.Context) {
 		c.JSON(http.StatusOK, ret)
 		return
 	}
-	client := util.NewOpenAIClientWithModel(selectedProvider.APIKey, selectedProvider.BaseURL, selectedModel.Name)
+	client := util.NewAIClient(selectedProvider.Protocol, selectedProvider.APIKey, selectedProvider.BaseURL, selectedModel.Name)
 
-	title := agent.GenerateTitle(client, selectedModel.Name, req.Message, req.Language)
+	title := agent.GenerateTitle(client, model.AIProviderName(selectedProvider), selectedModel.Name, req.Message, req.Language)
 	ret := gulu.Ret.NewResult()
 	ret.Data = title
 	c.JSON(http.StatusOK, ret)
@@ -622,6 +623,13 @@ type sessionMeta struct {
 	RecoveryTurnID string `json:"recoveryTurnID"`
 }
 
+func getAgentModelHealth(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = agent.ModelHealthSnapshot()
+}
+
 func writeSSE(c *gin.Context, event agent.AgentEvent) error {
 	switch event.Type {
 	case "turn":
@@ -680,6 +688,19 @@ func writeSSE(c *gin.Context, event agent.AgentEvent) error {
 		})
 	case "snapshot":
 		return writeSSEEvent(c, "snapshot", map[string]string{"snapshotID": event.SnapshotID})
+	case "fallback":
+		return writeSSEEvent(c, "fallback", map[string]string{
+			"fromModel": event.FromModel,
+			"toModel":   event.ToModel,
+			"reason":    event.FallbackReason,
+		})
+	case "subagent":
+		return writeSSEEvent(c, "subagent", map[string]string{
+			"id":      event.SubAgentID,
+			"task":    event.SubAgentTask,
+			"state":   event.SubAgentState,
+			"summary": event.Result,
+		})
 	}
 	return nil
 }
@@ -803,3 +824,121 @@ func renameSkill(c *gin.Context) {
 		return
 	}
 }
+
+func lsAgentAutomations(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = agent.ListAutomations()
+}
+
+func saveAgentAutomation(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	req := &agent.Automation{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		ret.Code = -1
+		ret.Msg = "invalid request: " + err.Error()
+		return
+	}
+
+	saved, err := agent.SaveAutomation(req)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = saved
+}
+
+type agentAutomationReq struct {
+	ID string `json:"id"`
+}
+
+func removeAgentAutomation(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	req := &agentAutomationReq{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		ret.Code = -1
+		ret.Msg = "invalid request: " + err.Error()
+		return
+	}
+
+	if err := agent.RemoveAutomation(req.ID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+// runAgentAutomation 立即在后台运行一次自动化，返回记录本次运行的会话 ID。
+func runAgentAutomation(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	req := &agentAutomationReq{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		ret.Code = -1
+		ret.Msg = "invalid request: " + err.Error()
+		return
+	}
+
+	sessionID, err := agent.RunAutomation(req.ID)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]any{"sessionID": sessionID}
+}
+
+type agentSubAgentReq struct {
+	SessionID string `json:"sessionID"`
+	TurnID    string `json:"turnID"`
+	ID        string `json:"id"`
+}
+
+// lsAgentSubAgents 列出会话中委派运行的子智能体，turnID 不为空时只列出该 turn 的子智能体。
+func lsAgentSubAgents(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	req := &agentSubAgentReq{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		ret.Code = -1
+		ret.Msg = "invalid request: " + err.Error()
+		return
+	}
+
+	transcripts, err := agent.ListSubAgentTranscripts(req.SessionID, req.TurnID)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = transcripts
+}
+
+// getAgentSubAgent 返回一个子智能体的完整运行记录。
+func getAgentSubAgent(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	req := &agentSubAgentReq{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		ret.Code = -1
+		ret.Msg = "invalid request: " + err.Error()
+		return
+	}
+
+	transcript, err := agent.GetSubAgentTranscript(req.SessionID, req.ID)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = transcript
+}
@@ -97,7 +97,7 @@ func testModel(c *gin.Context) {
 		return
 	}
 
-	available, matched, err := util.TestModel(provider.APIKey, provider.BaseURL, modelName, provider.RequestTimeout)
+	available, matched, err := util.TestModel(provider.Protocol, provider.APIKey, provider.BaseURL, modelName, provider.RequestTimeout)
 	// 可用模型清单裁剪到前 50 条，避免响应体过大
 	if 50 < len(available) {
 		available = available[:50]
@@ -134,7 +134,7 @@ func testEmbeddingModel(c *gin.Context) {
 		return
 	}
 
-	matched, dims, err := util.TestEmbeddingModel(embedding.APIKey, embedding.BaseURL, embedding.Name, embedding.Dimensions, embedding.Timeout)
+	matched, dims, err := util.TestEmbeddingModel(embedding.Protocol, embedding.APIKey, embedding.BaseURL, embedding.Name, embedding.Dimensions, embedding.Timeout)
 	// 测试结果统一以 code=0 返回，具体成败信息放在 data 中由前端控制展示，
 	// 避免触发统一的错误消息提示导致按钮状态无法恢复
 	result := map[string]any{
@@ -208,7 +208,7 @@ func listModels(c *gin.Context) {
 		return
 	}
 
-	models, err := util.ListAvailableModels(provider.APIKey, provider.BaseURL, provider.RequestTimeout)
+	models, err := util.ListAvailableModels(provider.Protocol, provider.APIKey, provider.BaseURL, provider.RequestTimeout)
 	result := map[string]any{
 		"models": models,
 	}
@@ -225,6 +225,58 @@ func embeddingStat(c *gin.Context) {
 	ret.Data = model.GetEmbeddingStat()
 }
 
+// getAIUsage 返回指定月份的 AI 用量报告，month 格式为 YYYY-MM（默认本月），groupBy 为 day/feature/provider/model/session（默认 model）。
+func getAIUsage(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var month, groupBy string
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("month", &month, false, false),
+		util.BindJsonArg("groupBy", &groupBy, false, false),
+	) {
+		return
+	}
+
+	report, err := model.GetAIUsageReport(month, groupBy)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = report
+}
+
+// setAIUsageConf 更新 AI 用量的模型单价和月度预算配置。
+func setAIUsageConf(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	usage := &conf.Usage{}
+	if err = gulu.JSON.UnmarshalJSON(param, usage); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = model.SetAIUsage(usage)
+}
+
 // mcpStatus 返回所有已配置 MCP server 的连接状态，供设置页轮询展示。
 func mcpStatus(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
@@ -421,6 +421,52 @@ func removeUnusedAssets(c *gin.Context) {
 	}
 }
 
+func deduplicateAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var dryRun bool
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("dryRun", &dryRun, false, false)) {
+		return
+	}
+
+	result, err := model.DeduplicateAssets(dryRun)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = result
+}
+
+func processAssetImages(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var dryRun bool
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("dryRun", &dryRun, false, false)) {
+		return
+	}
+
+	result, err := model.ProcessAssetImages(dryRun)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = result
+}
+
 func getUnusedAssets(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
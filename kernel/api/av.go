@@ -218,7 +218,7 @@ func setAttrViewGroup(c *gin.Context) {
 		return
 	}
 
-	ret = renderAttrView(blockID, avID, "", "", 1, -1, nil, false, false, "", "")
+	ret = renderAttrView(blockID, avID, "", "", 1, -1, nil, false, false, "", "", nil)
 	if ret.Code == 0 && model.IsReadOnlyRoleContext(c) {
 		publishAccess := model.GetPublishAccess()
 		retDataMap := ret.Data.(map[string]any)
@@ -295,7 +295,7 @@ func changeAttrViewLayout(c *gin.Context) {
 		return
 	}
 
-	ret = renderAttrView(blockID, avID, "", "", 1, -1, nil, false, false, "", "")
+	ret = renderAttrView(blockID, avID, "", "", 1, -1, nil, false, false, "", "", nil)
 	if ret.Code == 0 && model.IsReadOnlyRoleContext(c) {
 		publishAccess := model.GetPublishAccess()
 		retDataMap := ret.Data.(map[string]any)
@@ -981,8 +981,21 @@ func renderAttributeView(c *gin.Context) {
 	if targetGroupIDArg := arg["targetGroupID"]; nil != targetGroupIDArg {
 		targetGroupID = targetGroupIDArg.(string)
 	}
+	// 日历和时间线布局按可见日期区间加载：{"start": 毫秒时间戳, "end": 毫秒时间戳}
+	var dateWindow *av.DateWindow
+	if dateWindowArg, ok := arg["dateWindow"].(map[string]any); ok {
+		start, _ := dateWindowArg["start"].(float64)
+		end, _ := dateWindowArg["end"].(float64)
+		dateWindow = &av.DateWindow{Start: int64(start), End: int64(end)}
+		if !dateWindow.IsValid() {
+			ret.Code = -1
+			ret.Msg = "invalid date window"
+			c.JSON(http.StatusOK, ret)
+			return
+		}
+	}
 
-	ret = renderAttrView(blockID, id, viewID, query, page, pageSize, groupPaging, createIfNotExist, ignoreRows, targetItemID, targetGroupID)
+	ret = renderAttrView(blockID, id, viewID, query, page, pageSize, groupPaging, createIfNotExist, ignoreRows, targetItemID, targetGroupID, dateWindow)
 	if ret.Code == 0 && model.IsReadOnlyRoleContext(c) {
 		publishAccess := model.GetPublishAccess()
 		retDataMap := ret.Data.(map[string]any)
@@ -998,9 +1011,9 @@ func renderAttributeView(c *gin.Context) {
 	c.Data(http.StatusOK, "application/json; charset=utf-8", marshalBytes)
 }
 
-func renderAttrView(blockID, avID, viewID, query string, page, pageSize int, groupPaging map[string]any, createIfNotExist, ignoreRows bool, targetItemID, targetGroupID string) (ret *gulu.Result) {
+func renderAttrView(blockID, avID, viewID, query string, page, pageSize int, groupPaging map[string]any, createIfNotExist, ignoreRows bool, targetItemID, targetGroupID string, dateWindow *av.DateWindow) (ret *gulu.Result) {
 	ret = gulu.Ret.NewResult()
-	view, attrView, target, err := model.RenderAttributeViewWithTarget(blockID, avID, viewID, query, page, pageSize, groupPaging, createIfNotExist, ignoreRows, targetItemID, targetGroupID)
+	view, attrView, target, err := model.RenderAttributeViewWithTarget(blockID, avID, viewID, query, page, pageSize, groupPaging, createIfNotExist, ignoreRows, targetItemID, targetGroupID, dateWindow)
 	if err != nil {
 		ret.Code = -1
 		if errors.Is(err, av.ErrSpecTooNew) {
@@ -173,7 +173,8 @@ func installBazaarPlugin(c *gin.Context) {
 
 	util.PushMsg(model.Conf.Language(69), 3000)
 	ret.Data = map[string]any{
-		"packages": model.GetBazaarPackages("plugins", frontend, keyword),
+		"packages":    model.GetBazaarPackages("plugins", frontend, keyword),
+		"permissions": model.GetPluginPermissions(packageName), // 内核插件声明的权限，需在启用前由用户批准
 	}
 }
 
@@ -165,3 +165,174 @@ func getLocalGraph(c *gin.Context) {
 	}
 	util.RandomSleep(200, 500)
 }
+
+func getGraphShortestPath(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var from, to string
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("from", &from, true, true),
+		util.BindJsonArg("to", &to, true, true),
+	) {
+		return
+	}
+	opts, ok := graphAnalysisOptions(c, arg, ret)
+	if !ok {
+		return
+	}
+
+	path, err := model.GraphShortestPath(from, to, opts)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = path
+}
+
+func getGraphCentrality(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	metric := model.GraphCentralityPageRank
+	limit := 32.0
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("metric", &metric, false, false),
+		util.BindJsonArg("limit", &limit, false, false),
+	) {
+		return
+	}
+	if model.GraphCentralityPageRank != metric && model.GraphCentralityDegree != metric {
+		ret.Code = -1
+		ret.Msg = "metric should be one of [pagerank, degree]"
+		return
+	}
+	opts, ok := graphAnalysisOptions(c, arg, ret)
+	if !ok {
+		return
+	}
+
+	ret.Data = map[string]any{
+		"metric": metric,
+		"nodes":  model.GraphCentrality(metric, int(limit), opts),
+	}
+}
+
+func getGraphCommunities(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	minSize, limit := 2.0, 0.0
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("minSize", &minSize, false, false),
+		util.BindJsonArg("limit", &limit, false, false),
+	) {
+		return
+	}
+	opts, ok := graphAnalysisOptions(c, arg, ret)
+	if !ok {
+		return
+	}
+
+	ret.Data = map[string]any{
+		"communities": model.GraphCommunities(int(minSize), int(limit), opts),
+	}
+}
+
+func getGraphOrphans(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	opts, ok := graphAnalysisOptions(c, arg, ret)
+	if !ok {
+		return
+	}
+
+	ret.Data = map[string]any{
+		"nodes": model.GraphOrphanDocs(opts),
+	}
+}
+
+func getGraphDeadEnds(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	opts, ok := graphAnalysisOptions(c, arg, ret)
+	if !ok {
+		return
+	}
+
+	ret.Data = map[string]any{
+		"nodes": model.GraphDeadEndDocs(opts),
+	}
+}
+
+func getGraphBridges(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	limit := 32.0
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("limit", &limit, false, false)) {
+		return
+	}
+	opts, ok := graphAnalysisOptions(c, arg, ret)
+	if !ok {
+		return
+	}
+
+	ret.Data = map[string]any{
+		"nodes": model.GraphBridgingDocs(int(limit), opts),
+	}
+}
+
+// graphAnalysisOptions 解析关系图分析的公共参数：box 限定笔记本，tag 是否分析标签（默认使用全局关系图配置）。
+// 只读发布访问时过滤掉不可见的文档。
+func graphAnalysisOptions(c *gin.Context, arg map[string]any, ret *gulu.Result) (opts *model.GraphAnalysisOptions, ok bool) {
+	opts = &model.GraphAnalysisOptions{Tag: model.Conf.Graph.Global.Tag}
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("box", &opts.Box, false, false),
+		util.BindJsonArg("tag", &opts.Tag, false, false),
+	) {
+		return
+	}
+
+	if model.IsReadOnlyRoleContext(c) {
+		publishIgnore := model.GetInvisiblePublishAccess(model.GetPublishAccess())
+		opts.Accessible = func(box, p string) bool {
+			return model.CheckPathAccessableByPublishIgnore(box, p, publishIgnore)
+		}
+	}
+	ok = true
+	return
+}
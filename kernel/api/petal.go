@@ -54,15 +54,24 @@ func setPetalEnabled(c *gin.Context) {
 	}
 
 	var packageName, app string
-	var enabled bool
+	var enabled, approvePermissions bool
 	if !util.ParseJsonArgs(arg, ret,
 		util.BindJsonArg("packageName", &packageName, true, true),
 		util.BindJsonArg("enabled", &enabled, true, false),
 		util.BindJsonArg("app", &app, false, false),
+		util.BindJsonArg("approvePermissions", &approvePermissions, false, false),
 	) {
 		return
 	}
 
+	if enabled && approvePermissions {
+		if _, err := model.ApprovePetalPermissions(packageName); err != nil {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+
 	data, err := model.SetPetalEnabled(packageName, enabled)
 	if err != nil {
 		ret.Code = -1
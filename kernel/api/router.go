@@ -194,6 +194,9 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/tag/getTag", model.CheckAuth, getTag)
 	ginServer.Handle("POST", "/api/tag/renameTag", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, renameTag)
 	ginServer.Handle("POST", "/api/tag/removeTag", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeTag)
+	ginServer.Handle("POST", "/api/tag/getTagMetas", model.CheckAuth, getTagMetas)
+	ginServer.Handle("POST", "/api/tag/setTagMeta", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setTagMeta)
+	ginServer.Handle("POST", "/api/tag/mergeTags", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, mergeTags)
 
 	ginServer.Handle("POST", "/api/lute/spinBlockDOM", model.CheckAuth, spinBlockDOM) // 未测试
 	ginServer.Handle("POST", "/api/lute/html2BlockDOM", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, html2BlockDOM)
@@ -347,6 +350,8 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/asset/getMissingAssets", model.CheckAuth, getMissingAssets)
 	ginServer.Handle("POST", "/api/asset/removeUnusedAsset", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeUnusedAsset)
 	ginServer.Handle("POST", "/api/asset/removeUnusedAssets", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeUnusedAssets)
+	ginServer.Handle("POST", "/api/asset/deduplicateAssets", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, deduplicateAssets)
+	ginServer.Handle("POST", "/api/asset/processAssetImages", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, processAssetImages)
 	ginServer.Handle("POST", "/api/asset/getDocImageAssets", model.CheckAuth, getDocImageAssets)
 	ginServer.Handle("POST", "/api/asset/getDocAssets", model.CheckAuth, getDocAssets)
 	ginServer.Handle("POST", "/api/asset/renameAsset", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, renameAsset)
@@ -407,9 +412,11 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/template/render", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, renderTemplate)
 	ginServer.Handle("POST", "/api/template/docSaveAsTemplate", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, docSaveAsTemplate)
 	ginServer.Handle("POST", "/api/template/renderSprig", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, renderSprig)
+	ginServer.Handle("POST", "/api/template/getTemplateParams", model.CheckAuth, model.CheckAdminRole, getTemplateParams)
 
 	ginServer.Handle("POST", "/api/transactions", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, performTransactions)
 	ginServer.Handle("POST", "/api/transactions/undoState", model.CheckAuth, undoState)
+	ginServer.Handle("POST", "/api/transactions/undoTimeline", model.CheckAuth, model.CheckAdminRole, undoTimeline)
 	ginServer.Handle("POST", "/api/transactions/undo", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, performUndo)
 	ginServer.Handle("POST", "/api/transactions/redo", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, performRedo)
 	ginServer.Handle("POST", "/api/transactions/clearHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, clearHistory)
@@ -428,9 +435,22 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/setting/login2faCloudUser", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, login2faCloudUser)
 	ginServer.Handle("POST", "/api/setting/setEmoji", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setEmoji)
 	ginServer.Handle("POST", "/api/setting/setFlashcard", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setFlashcard)
+	ginServer.Handle("POST", "/api/setting/setImage", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setImage)
 	ginServer.Handle("POST", "/api/setting/setAI", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAI)
 	ginServer.Handle("POST", "/api/setting/setSecrets", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSecrets)
 	ginServer.Handle("POST", "/api/setting/setVariables", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setVariables)
+	ginServer.Handle("POST", "/api/setting/getAPITokens", model.CheckAuth, model.CheckAdminRole, getAPITokens)
+	ginServer.Handle("POST", "/api/setting/createAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createAPIToken)
+	ginServer.Handle("POST", "/api/setting/updateAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, updateAPIToken)
+	ginServer.Handle("POST", "/api/setting/revokeAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, revokeAPIToken)
+	ginServer.Handle("POST", "/api/setting/removeAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeAPIToken)
+	ginServer.Handle("POST", "/api/setting/getWebhooks", model.CheckAuth, model.CheckAdminRole, getWebhooks)
+	ginServer.Handle("POST", "/api/setting/createWebhook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createWebhook)
+	ginServer.Handle("POST", "/api/setting/updateWebhook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, updateWebhook)
+	ginServer.Handle("POST", "/api/setting/removeWebhook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeWebhook)
+	ginServer.Handle("POST", "/api/setting/testWebhook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, testWebhook)
+	ginServer.Handle("POST", "/api/setting/getWebhookDeliveries", model.CheckAuth, model.CheckAdminRole, getWebhookDeliveries)
+	ginServer.Handle("POST", "/api/setting/redeliverWebhook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, redeliverWebhook)
 	ginServer.Handle("POST", "/api/setting/setBazaar", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setBazaar)
 	ginServer.Handle("POST", "/api/setting/setPublish", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setPublish)
 	ginServer.Handle("POST", "/api/setting/getPublish", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, getPublish)
@@ -444,6 +464,12 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/graph/resetLocalGraph", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, resetLocalGraph)
 	ginServer.Handle("POST", "/api/graph/getGraph", model.CheckAuth, getGraph)
 	ginServer.Handle("POST", "/api/graph/getLocalGraph", model.CheckAuth, getLocalGraph)
+	ginServer.Handle("POST", "/api/graph/shortestPath", model.CheckAuth, getGraphShortestPath)
+	ginServer.Handle("POST", "/api/graph/centrality", model.CheckAuth, getGraphCentrality)
+	ginServer.Handle("POST", "/api/graph/communities", model.CheckAuth, getGraphCommunities)
+	ginServer.Handle("POST", "/api/graph/orphans", model.CheckAuth, getGraphOrphans)
+	ginServer.Handle("POST", "/api/graph/deadEnds", model.CheckAuth, getGraphDeadEnds)
+	ginServer.Handle("POST", "/api/graph/bridges", model.CheckAuth, getGraphBridges)
 
 	ginServer.Handle("POST", "/api/bazaar/getBazaarPlugin", model.CheckAuth, getBazaarPlugin)
 	ginServer.Handle("POST", "/api/bazaar/getInstalledPlugin", model.CheckAuth, getInstalledPlugin)
@@ -567,6 +593,8 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/ai/testRerankModel", model.CheckAuth, model.CheckAdminRole, testRerankModel)
 	ginServer.Handle("POST", "/api/ai/listModels", model.CheckAuth, model.CheckAdminRole, listModels)
 	ginServer.Handle("POST", "/api/ai/embeddingStat", model.CheckAuth, model.CheckAdminRole, embeddingStat)
+	ginServer.Handle("POST", "/api/ai/getUsage", model.CheckAuth, model.CheckAdminRole, getAIUsage)
+	ginServer.Handle("POST", "/api/ai/setUsageConf", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAIUsageConf)
 	ginServer.Handle("POST", "/api/ai/mcpStatus", model.CheckAuth, model.CheckAdminRole, mcpStatus)
 	ginServer.Handle("POST", "/api/ai/mcpOAuthAuthorize", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, mcpOAuthAuthorize)
 	ginServer.Handle("POST", "/api/ai/mcpOAuthDisconnect", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, mcpOAuthDisconnect)
@@ -581,6 +609,13 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/ai/agent/getSession", model.CheckAuth, model.CheckAdminRole, getSession)
 	ginServer.Handle("POST", "/api/ai/agent/saveSession", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, saveSession)
 	ginServer.Handle("POST", "/api/ai/agent/removeSession", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeSession)
+	ginServer.Handle("POST", "/api/ai/agent/lsSubAgents", model.CheckAuth, model.CheckAdminRole, lsAgentSubAgents)
+	ginServer.Handle("POST", "/api/ai/agent/getSubAgent", model.CheckAuth, model.CheckAdminRole, getAgentSubAgent)
+	ginServer.Handle("POST", "/api/ai/agent/getModelHealth", model.CheckAuth, model.CheckAdminRole, getAgentModelHealth)
+	ginServer.Handle("POST", "/api/ai/agent/lsAutomations", model.CheckAuth, model.CheckAdminRole, lsAgentAutomations)
+	ginServer.Handle("POST", "/api/ai/agent/saveAutomation", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, saveAgentAutomation)
+	ginServer.Handle("POST", "/api/ai/agent/removeAutomation", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeAgentAutomation)
+	ginServer.Handle("POST", "/api/ai/agent/runAutomation", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, runAgentAutomation)
 	ginServer.Handle("POST", "/api/ai/agent/lsSkills", model.CheckAuth, model.CheckAdminRole, lsSkills)
 	ginServer.Handle("POST", "/api/ai/agent/getSkill", model.CheckAuth, model.CheckAdminRole, getSkill)
 	ginServer.Handle("POST", "/api/ai/agent/saveSkill", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, saveSkill)
@@ -592,7 +592,7 @@ func parseSearchBlockArgs(arg map[string]any) (page, pageSize int, query string,
 		}
 	}
 
-	// method：0：关键字，1：查询语法，2：SQL，3：正则表达式
+	// method：0：关键字，1：查询语法，2：SQL，3：正则表达式，5：混合（关键字 + 语义）
 	methodArg := arg["method"]
 	if nil != methodArg {
 		method = int(methodArg.(float64))
@@ -642,7 +642,7 @@ func parseSearchAssetContentArgs(arg map[string]any) (page, pageSize int, query
 		}
 	}
 
-	// method：0：关键字，1：查询语法，2：SQL，3：正则表达式
+	// method：0：关键字，1：查询语法，2：SQL，3：正则表达式，4：语义
 	methodArg := arg["method"]
 	if nil != methodArg {
 		method = int(methodArg.(float64))
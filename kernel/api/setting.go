@@ -352,6 +352,37 @@ func setFlashcard(c *gin.Context) {
 	ret.Data = flashcard
 }
 
+func setImage(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	image := conf.NewImagePipeline()
+	if err = gulu.JSON.UnmarshalJSON(param, image); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err = model.SetImagePipeline(image); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = image
+}
+
 func setAccount(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -605,6 +636,20 @@ func setSearch(c *gin.Context) {
 		s.Limit = 32
 	}
 
+	if "" == s.HybridFusion {
+		// 未携带混合搜索字段的调用保持当前配置
+		s.HybridFusion = model.Conf.Search.HybridFusion
+		s.HybridSemanticWeight = model.Conf.Search.HybridSemanticWeight
+		s.HybridCandidateCount = model.Conf.Search.HybridCandidateCount
+	}
+	if conf.HybridFusionRRF != s.HybridFusion && conf.HybridFusionWeighted != s.HybridFusion {
+		s.HybridFusion = conf.HybridFusionRRF
+	}
+	s.HybridSemanticWeight = min(max(s.HybridSemanticWeight, 0), 1)
+	if 1 > s.HybridCandidateCount {
+		s.HybridCandidateCount = 64
+	}
+
 	oldCaseSensitive := model.Conf.Search.CaseSensitive
 	oldHanSensitive := model.Conf.Search.HanSensitiveVal()
 	oldIndexAssetPath := model.Conf.Search.IndexAssetPath
@@ -910,3 +955,293 @@ func setEmoji(c *gin.Context) {
 
 	model.Conf.Editor.Emoji = emoji
 }
+
+func getAPITokens(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.ListAPITokens()
+}
+
+func createAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name, role, boxes, routeGroups, expired, ok := parseAPITokenArgs(arg, ret)
+	if !ok {
+		return
+	}
+
+	info, token, err := model.CreateAPIToken(name, role, boxes, routeGroups, expired)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]any{
+		"info":  info,
+		"token": token,
+	}
+}
+
+func updateAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id string
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("id", &id, true, true)) {
+		return
+	}
+
+	name, role, boxes, routeGroups, expired, ok := parseAPITokenArgs(arg, ret)
+	if !ok {
+		return
+	}
+
+	info, err := model.UpdateAPIToken(id, name, role, boxes, routeGroups, expired)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = info
+}
+
+func revokeAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id string
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("id", &id, true, true)) {
+		return
+	}
+
+	if err := model.RevokeAPIToken(id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+}
+
+func removeAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id string
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("id", &id, true, true)) {
+		return
+	}
+
+	if err := model.RemoveAPIToken(id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+}
+
+func parseAPITokenArgs(arg map[string]any, ret *gulu.Result) (name, role string, boxes, routeGroups []string, expired int64, ok bool) {
+	var boxesArg, routeGroupsArg []any
+	var expiredArg float64
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("name", &name, true, true),
+		util.BindJsonArg("role", &role, true, true),
+		util.BindJsonArg("boxes", &boxesArg, false, false),
+		util.BindJsonArg("routeGroups", &routeGroupsArg, false, false),
+		util.BindJsonArg("expired", &expiredArg, false, false),
+	) {
+		return
+	}
+
+	for _, box := range boxesArg {
+		if s, isStr := box.(string); isStr {
+			boxes = append(boxes, s)
+		}
+	}
+	for _, group := range routeGroupsArg {
+		if s, isStr := group.(string); isStr {
+			routeGroups = append(routeGroups, s)
+		}
+	}
+	expired = int64(expiredArg)
+	ok = true
+	return
+}
+
+func getWebhooks(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]any{
+		"webhooks": model.ListWebhooks(),
+		"events":   conf.WebhookEvents,
+	}
+}
+
+func createWebhook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	webhook, ok := parseWebhookArg(c, ret)
+	if !ok {
+		return
+	}
+
+	created, err := model.CreateWebhook(webhook)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = created
+}
+
+func updateWebhook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	webhook, ok := parseWebhookArg(c, ret)
+	if !ok {
+		return
+	}
+	if "" == webhook.ID {
+		ret.Code = -1
+		ret.Msg = "id is required"
+		return
+	}
+
+	updated, err := model.UpdateWebhook(webhook)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = updated
+}
+
+func removeWebhook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id string
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("id", &id, true, true)) {
+		return
+	}
+
+	if err := model.RemoveWebhook(id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+}
+
+func testWebhook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id string
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("id", &id, true, true)) {
+		return
+	}
+
+	delivery, err := model.TestWebhook(id)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = delivery
+}
+
+func getWebhookDeliveries(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var webhookID, status string
+	limit := 100.0
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("webhookID", &webhookID, false, false),
+		util.BindJsonArg("status", &status, false, false),
+		util.BindJsonArg("limit", &limit, false, false),
+	) {
+		return
+	}
+
+	ret.Data = map[string]any{
+		"deliveries": model.GetWebhookDeliveries(webhookID, status, int(limit)),
+	}
+}
+
+func redeliverWebhook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id string
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("id", &id, true, true)) {
+		return
+	}
+
+	if err := model.RedeliverWebhook(id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+}
+
+func parseWebhookArg(c *gin.Context, ret *gulu.Result) (webhook *conf.Webhook, ok bool) {
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return nil, false
+	}
+
+	webhook = &conf.Webhook{}
+	if err = gulu.JSON.UnmarshalJSON(param, webhook); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return nil, false
+	}
+	return
+}
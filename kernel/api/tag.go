@@ -106,3 +106,81 @@ func removeTag(c *gin.Context) {
 		return
 	}
 }
+
+func getTagMetas(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetTagMetas()
+}
+
+func setTagMeta(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var label, color, icon, description string
+	var aliasesArg []any
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("label", &label, true, true),
+		util.BindJsonArg("color", &color, false, false),
+		util.BindJsonArg("icon", &icon, false, false),
+		util.BindJsonArg("description", &description, false, false),
+		util.BindJsonArg("aliases", &aliasesArg, false, false),
+	) {
+		return
+	}
+
+	meta := &model.TagMeta{Color: color, Icon: icon, Description: description}
+	for _, alias := range aliasesArg {
+		if str, elemOk := alias.(string); elemOk {
+			meta.Aliases = append(meta.Aliases, str)
+		}
+	}
+	if err := model.SetTagMeta(label, meta); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]any{"closeTimeout": 5000}
+		return
+	}
+}
+
+func mergeTags(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var labelsArg []any
+	var target string
+	var dryRun bool
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("labels", &labelsArg, true, true),
+		util.BindJsonArg("target", &target, true, true),
+		util.BindJsonArg("dryRun", &dryRun, false, false),
+	) {
+		return
+	}
+
+	var labels []string
+	for _, label := range labelsArg {
+		if str, elemOk := label.(string); elemOk {
+			labels = append(labels, str)
+		}
+	}
+	result, err := model.MergeTags(labels, target, dryRun)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]any{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = result
+}
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/88250/gulu"
@@ -91,7 +92,12 @@ func renderTemplate(c *gin.Context) {
 		preview = previewArg.(bool)
 	}
 
-	_, content, err := model.RenderTemplate(p, id, preview)
+	params, ok := templateParamsArg(arg, ret)
+	if !ok {
+		return
+	}
+
+	_, content, err := model.RenderTemplate(p, id, params, preview)
 	if err != nil {
 		ret.Code = -1
 		ret.Msg = util.EscapeHTML(err.Error())
@@ -103,3 +109,53 @@ func renderTemplate(c *gin.Context) {
 		"content": content,
 	}
 }
+
+func getTemplateParams(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var p string
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("path", &p, true, true)) {
+		return
+	}
+
+	if !util.IsAbsPathInWorkspace(p) {
+		ret.Code = -1
+		ret.Msg = "Path [" + p + "] is not in workspace"
+		return
+	}
+
+	params, err := model.GetTemplateParams(p)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = util.EscapeHTML(err.Error())
+		return
+	}
+	ret.Data = map[string]any{
+		"path":   p,
+		"params": params,
+	}
+}
+
+// templateParamsArg 从请求参数中提取模板输入参数的值，非字符串的值按其文本形式传入。
+func templateParamsArg(arg map[string]any, ret *gulu.Result) (params map[string]string, ok bool) {
+	var paramsArg map[string]any
+	if !util.ParseJsonArgs(arg, ret, util.BindJsonArg("params", &paramsArg, false, false)) {
+		return
+	}
+
+	params = map[string]string{}
+	for k, v := range paramsArg {
+		if nil == v {
+			continue
+		}
+		params[k] = fmt.Sprint(v)
+	}
+	ok = true
+	return
+}
@@ -161,6 +161,29 @@ func undoState(c *gin.Context) {
 	}
 }
 
+// undoTimeline 按时间顺序返回指定文档的撤销/重做操作记录，已撤销（可重做）的记录标记 undone。
+func undoTimeline(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var rootID string
+	if !util.ParseJsonArgs(arg, ret,
+		util.BindJsonArg("rootID", &rootID, true, true),
+	) {
+		return
+	}
+
+	ret.Data = map[string]any{
+		"rootID":   rootID,
+		"timeline": model.GlobalUndoLog.Timeline(rootID),
+	}
+}
+
 // performUndo 撤销指定文档最近一次操作。
 // 弹出 rootID 撤销栈顶，同步执行其逆操作，广播给其它窗口/端。
 // 单文档撤销：发起窗口靠响应数据本地乐观应用，广播排除发起方（ExcludeSelf）。
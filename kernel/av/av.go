@@ -159,6 +159,7 @@ const (
 	KeyTypeRelation   KeyType = "relation"   // 关联
 	KeyTypeRollup     KeyType = "rollup"     // 汇总
 	KeyTypeLineNumber KeyType = "lineNumber" // 行号
+	KeyTypeFormula    KeyType = "formula"    // 公式
 )
 
 // Key 描述了属性视图属性字段的基础结构。
@@ -194,6 +195,9 @@ type Key struct {
 
 	// 更新时间
 	Updated *Updated `json:"updated,omitempty"` // 更新时间设置
+
+	// 公式
+	Formula *Formula `json:"formula,omitempty"` // 公式设置
 }
 
 func NewKey(id, name, icon string, keyType KeyType) *Key {
@@ -253,19 +257,21 @@ type SelectOption struct {
 
 // View 描述了视图的结构。
 type View struct {
-	ID               string         `json:"id"`                // 视图 ID
-	Icon             string         `json:"icon"`              // 视图图标
-	Name             string         `json:"name"`              // 视图名称
-	HideAttrViewName bool           `json:"hideAttrViewName"`  // 是否隐藏属性视图名称
-	Desc             string         `json:"desc"`              // 视图描述
-	Filters          []*ViewFilter  `json:"filters,omitempty"` // 过滤规则
-	Sorts            []*ViewSort    `json:"sorts,omitempty"`   // 排序规则
-	PageSize         int            `json:"pageSize"`          // 每页条目数
-	LayoutType       LayoutType     `json:"type"`              // 当前布局类型
-	Table            *LayoutTable   `json:"table,omitempty"`   // 表格布局
-	Gallery          *LayoutGallery `json:"gallery,omitempty"` // 卡片布局
-	Kanban           *LayoutKanban  `json:"kanban,omitempty"`  // 看板布局
-	ItemIDs          []string       `json:"itemIds,omitempty"` // 项目 ID 列表，用于维护所有项目
+	ID               string          `json:"id"`                 // 视图 ID
+	Icon             string          `json:"icon"`               // 视图图标
+	Name             string          `json:"name"`               // 视图名称
+	HideAttrViewName bool            `json:"hideAttrViewName"`   // 是否隐藏属性视图名称
+	Desc             string          `json:"desc"`               // 视图描述
+	Filters          []*ViewFilter   `json:"filters,omitempty"`  // 过滤规则
+	Sorts            []*ViewSort     `json:"sorts,omitempty"`    // 排序规则
+	PageSize         int             `json:"pageSize"`           // 每页条目数
+	LayoutType       LayoutType      `json:"type"`               // 当前布局类型
+	Table            *LayoutTable    `json:"table,omitempty"`    // 表格布局
+	Gallery          *LayoutGallery  `json:"gallery,omitempty"`  // 卡片布局
+	Kanban           *LayoutKanban   `json:"kanban,omitempty"`   // 看板布局
+	Calendar         *LayoutCalendar `json:"calendar,omitempty"` // 日历布局
+	Timeline         *LayoutTimeline `json:"timeline,omitempty"` // 时间线布局
+	ItemIDs          []string        `json:"itemIds,omitempty"`  // 项目 ID 列表，用于维护所有项目
 
 	Group        *ViewGroup `json:"group,omitempty"`     // 分组规则
 	GroupCreated int64      `json:"groupCreated"`        // 分组生成时间戳
@@ -366,9 +372,11 @@ type GroupCalc struct {
 type LayoutType string
 
 const (
-	LayoutTypeTable   LayoutType = "table"   // 属性视图类型 - 表格
-	LayoutTypeGallery LayoutType = "gallery" // 属性视图类型 - 卡片
-	LayoutTypeKanban  LayoutType = "kanban"  // 属性视图类型 - 看板
+	LayoutTypeTable    LayoutType = "table"    // 属性视图类型 - 表格
+	LayoutTypeGallery  LayoutType = "gallery"  // 属性视图类型 - 卡片
+	LayoutTypeKanban   LayoutType = "kanban"   // 属性视图类型 - 看板
+	LayoutTypeCalendar LayoutType = "calendar" // 属性视图类型 - 日历
+	LayoutTypeTimeline LayoutType = "timeline" // 属性视图类型 - 时间线
 )
 
 const (
@@ -430,6 +438,30 @@ func NewKanbanView() (ret *View) {
 	}
 }
 
+func NewCalendarView() (ret *View) {
+	return &View{
+		ID:         ast.NewNodeID(),
+		Name:       GetAttributeViewI18n("calendar"),
+		Filters:    []*ViewFilter{{Combination: FilterCombinationAnd}},
+		Sorts:      []*ViewSort{},
+		PageSize:   ViewDefaultPageSize,
+		LayoutType: LayoutTypeCalendar,
+		Calendar:   NewLayoutCalendar(),
+	}
+}
+
+func NewTimelineView() (ret *View) {
+	return &View{
+		ID:         ast.NewNodeID(),
+		Name:       GetAttributeViewI18n("timeline"),
+		Filters:    []*ViewFilter{{Combination: FilterCombinationAnd}},
+		Sorts:      []*ViewSort{},
+		PageSize:   ViewDefaultPageSize,
+		LayoutType: LayoutTypeTimeline,
+		Timeline:   NewLayoutTimeline(),
+	}
+}
+
 // Viewable 描述了视图的接口。
 type Viewable interface {
 
@@ -1068,6 +1100,20 @@ func (av *AttributeView) Clone() (ret *AttributeView) {
 			for _, field := range view.Kanban.Fields {
 				field.ID = keyIDMap[field.ID]
 			}
+		case LayoutTypeCalendar:
+			view.Calendar.ID = ast.NewNodeID()
+			for _, field := range view.Calendar.Fields {
+				field.ID = keyIDMap[field.ID]
+			}
+			view.Calendar.StartKeyID = keyIDMap[view.Calendar.StartKeyID]
+			view.Calendar.EndKeyID = keyIDMap[view.Calendar.EndKeyID]
+		case LayoutTypeTimeline:
+			view.Timeline.ID = ast.NewNodeID()
+			for _, field := range view.Timeline.Fields {
+				field.ID = keyIDMap[field.ID]
+			}
+			view.Timeline.StartKeyID = keyIDMap[view.Timeline.StartKeyID]
+			view.Timeline.EndKeyID = keyIDMap[view.Timeline.EndKeyID]
 		}
 		view.ItemIDs = []string{}
 	}
@@ -1109,8 +1155,14 @@ var (
 	ErrKeyNotFound            = errors.New("key not found")
 	ErrWrongLayoutType        = errors.New("wrong layout type")
 	ErrInvalidColumnAlign     = errors.New("invalid column align")
+	ErrInvalidDateKey         = errors.New("invalid date key")
+	ErrInvalidCalendarMode    = errors.New("invalid calendar mode")
+	ErrInvalidTimelineScale   = errors.New("invalid timeline scale")
+	ErrFormulaCircularRef     = errors.New("circular reference")
 	ErrSpecTooNew             = errors.New("attribute view spec is too new")
 	ErrFilterTooDeep          = errors.New("filter nesting depth exceeds the maximum allowed")
+	ErrFormulaTooDeep         = errors.New("formula nesting depth exceeds the maximum allowed")
+	ErrFormulaTooLong         = errors.New("formula length exceeds the maximum allowed")
 )
 
 const (
@@ -130,6 +130,27 @@ func calcField(collection Collection, field Field, fieldIndex int, attrView *Att
 		calcFieldRelation(collection, field, fieldIndex)
 	case KeyTypeRollup:
 		calcFieldRollup(collection, field, fieldIndex)
+	case KeyTypeFormula:
+		calcFieldFormula(collection, field, fieldIndex, attrView)
+	}
+}
+
+// calcFieldFormula 按公式的结果类型计算，公式值的计算结果存放在对应类型的字段上，所以可以直接复用对应类型的计算。
+func calcFieldFormula(collection Collection, field Field, fieldIndex int, attrView *AttributeView) {
+	resultType := KeyTypeText
+	if key, _ := attrView.GetKey(field.GetID()); nil != key && nil != key.Formula {
+		resultType = key.Formula.ResultType
+	}
+
+	switch resultType {
+	case KeyTypeNumber:
+		calcFieldNumber(collection, field, fieldIndex)
+	case KeyTypeDate:
+		calcFieldDate(collection, field, fieldIndex)
+	case KeyTypeCheckbox:
+		calcFieldCheckbox(collection, field, fieldIndex)
+	default:
+		calcFieldText(collection, field, fieldIndex)
 	}
 }
 
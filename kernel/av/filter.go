@@ -1048,6 +1048,21 @@ func (value *Value) filter(other *Value, relativeDate, relativeDate2 *RelativeDa
 				return !value.Checkbox.Checked
 			}
 		}
+	case KeyTypeFormula:
+		if nil != value.Formula {
+			// 按计算结果类型过滤，过滤规则值的结果类型和当前不一致时（公式修改过）不过滤
+			var otherResult *Value
+			if nil != other && nil != other.Formula {
+				otherResult = other.GetFormulaResult()
+				if otherResult.Type != value.Formula.ResultType {
+					return true
+				}
+			}
+			if nil == otherResult && nil == relativeDate && KeyTypeCheckbox != value.Formula.ResultType {
+				return true
+			}
+			return value.GetFormulaResult().filter(otherResult, relativeDate, relativeDate2, operator)
+		}
 	case KeyTypeRelation: // 过滤汇总字段，并且汇总目标是关联字段时才会进入该分支
 		if nil != value.Relation && 0 < len(value.Relation.Contents) && nil != value.Relation.Contents[0].Block &&
 			nil != other && nil != other.Relation && 0 < len(other.Relation.BlockIDs) {
@@ -1355,7 +1370,7 @@ func (filter *ViewFilter) IsValid() bool {
 
 func (filter *ViewFilter) GetAffectValue(key *Key, addingBlockID string) (ret *Value) {
 	if nil != filter.Value {
-		if KeyTypeRelation == filter.Value.Type || KeyTypeTemplate == filter.Value.Type || KeyTypeRollup == filter.Value.Type || KeyTypeUpdated == filter.Value.Type || KeyTypeCreated == filter.Value.Type {
+		if KeyTypeRelation == filter.Value.Type || KeyTypeTemplate == filter.Value.Type || KeyTypeRollup == filter.Value.Type || KeyTypeUpdated == filter.Value.Type || KeyTypeCreated == filter.Value.Type || KeyTypeFormula == filter.Value.Type {
 			// 所有生成的数据都不设置默认值
 			return nil
 		}
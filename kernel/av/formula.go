@@ -0,0 +1,1559 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Formula 描述了公式字段的设置。
+type Formula struct {
+	Expr       string  `json:"expr"`       // 公式表达式
+	ResultType KeyType `json:"resultType"` // 结果类型，根据表达式推导，取值为 text/number/date/checkbox
+}
+
+// ValueFormula 描述了公式字段值的计算信息，计算结果按结果类型存放在 Value 的 Text/Number/Date/Checkbox 上。
+type ValueFormula struct {
+	ResultType KeyType `json:"resultType"`      // 结果类型
+	Error      string  `json:"error,omitempty"` // 计算错误信息
+}
+
+// GetFormulaResult 将公式字段值转换为结果类型的普通字段值，以便复用数字、日期、复选框和文本的排序、过滤和计算逻辑。
+func (value *Value) GetFormulaResult() (ret *Value) {
+	if nil == value || nil == value.Formula {
+		return
+	}
+
+	ret = &Value{ID: value.ID, KeyID: value.KeyID, BlockID: value.BlockID, Type: value.Formula.ResultType, CreatedAt: value.CreatedAt, UpdatedAt: value.UpdatedAt}
+	switch value.Formula.ResultType {
+	case KeyTypeNumber:
+		ret.Number = value.Number
+	case KeyTypeDate:
+		ret.Date = value.Date
+	case KeyTypeCheckbox:
+		ret.Checkbox = value.Checkbox
+	default:
+		ret.Type = KeyTypeText
+		ret.Text = value.Text
+	}
+	return
+}
+
+// FormulaResolver 根据字段名返回当前项目上该字段的值，字段不存在时 ok 为 false。
+type FormulaResolver func(keyName string) (value *Value, ok bool)
+
+// FormulaProgram 描述了编译后的公式。
+type FormulaProgram struct {
+	root formulaNode
+	Refs []string // 公式引用的字段名
+}
+
+const (
+	MaxFormulaLength       = 4096 // 公式表达式的最大字符数
+	MaxFormulaNestingDepth = 128  // 公式括号、函数调用和一元运算的最大嵌套深度
+)
+
+// ParseFormula 解析公式表达式。表达式过长或嵌套过深时返回错误，避免递归解析耗尽栈空间。
+func ParseFormula(expr string) (ret *FormulaProgram, err error) {
+	if utf8.RuneCountInString(expr) > MaxFormulaLength {
+		err = ErrFormulaTooLong
+		return
+	}
+
+	tokens, err := lexFormula(expr)
+	if nil != err {
+		return
+	}
+
+	p := &formulaParser{tokens: tokens}
+	root, err := p.parseExpr()
+	if nil != err {
+		return
+	}
+	if tok := p.peek(); formulaTokenEOF != tok.typ {
+		err = fmt.Errorf("unexpected [%s] at %d", tok.text, tok.pos)
+		return
+	}
+
+	ret = &FormulaProgram{root: root, Refs: p.refs}
+	return
+}
+
+// InferResultType 根据引用字段的类型推导公式的结果类型。
+func (program *FormulaProgram) InferResultType(keyTypeOf func(keyName string) *Key) KeyType {
+	kind := program.root.kind(func(name string) formulaKind {
+		return formulaKindOfKey(keyTypeOf(name))
+	})
+	return kind.resultType()
+}
+
+// Eval 计算公式并将结果按 resultType 写入 value。
+func (program *FormulaProgram) Eval(value *Value, resultType KeyType, numberFormat NumberFormat, resolver FormulaResolver) {
+	ctx := &formulaContext{resolver: resolver, now: time.Now()}
+	result, err := program.root.eval(ctx)
+	setFormulaResult(value, result, err, resultType, numberFormat)
+}
+
+// SetFormulaError 将公式字段值置为计算错误，比如公式解析失败或者存在循环引用。
+func SetFormulaError(value *Value, err error, resultType KeyType) {
+	setFormulaResult(value, nil, err, resultType, NumberFormatNone)
+}
+
+func setFormulaResult(value *Value, result *formulaValue, err error, resultType KeyType, numberFormat NumberFormat) {
+	value.Type = KeyTypeFormula
+	value.Formula = &ValueFormula{ResultType: resultType}
+	value.Text, value.Number, value.Date, value.Checkbox = nil, nil, nil, nil
+	switch resultType {
+	case KeyTypeNumber:
+		value.Number = &ValueNumber{Format: numberFormat}
+	case KeyTypeDate:
+		value.Date = &ValueDate{}
+	case KeyTypeCheckbox:
+		value.Checkbox = &ValueCheckbox{}
+	default:
+		value.Formula.ResultType = KeyTypeText
+		value.Text = &ValueText{}
+	}
+
+	if nil != err {
+		value.Formula.Error = err.Error()
+		return
+	}
+	if nil == result || formulaKindNull == result.kind {
+		return
+	}
+
+	switch value.Formula.ResultType {
+	case KeyTypeNumber:
+		num, isNull, numErr := result.toNumber()
+		if nil != numErr {
+			value.Formula.Error = numErr.Error()
+			return
+		}
+		// NaN 和无穷大无法序列化为 JSON，比如 sqrt(-1) 和 10^1000
+		if math.IsNaN(num) || math.IsInf(num, 0) {
+			value.Formula.Error = "result is not a finite number"
+			return
+		}
+		if !isNull {
+			value.Number = NewFormattedValueNumber(num, numberFormat)
+		}
+	case KeyTypeDate:
+		if formulaKindDate != result.kind {
+			value.Formula.Error = fmt.Sprintf("expected date but got %s", result.kind)
+			return
+		}
+		value.Date = NewFormattedValueDate(result.date, 0, DateFormatNone, result.isNotTime, false)
+	case KeyTypeCheckbox:
+		value.Checkbox.Checked = result.toBool()
+	default:
+		value.Text.Content = result.toText()
+	}
+}
+
+// GetFormulaKeysByResolutionOrder 按依赖顺序返回公式字段并推导结果类型，存在循环引用的字段通过 cyclicKeys 返回。
+func GetFormulaKeysByResolutionOrder(attrView *AttributeView) (ret []*Key, programs map[string]*FormulaProgram, cyclicKeys map[string]bool) {
+	programs = map[string]*FormulaProgram{}
+	cyclicKeys = map[string]bool{}
+	keysByName := map[string]*Key{}
+	var formulaKeys []*Key
+	for _, keyValues := range attrView.KeyValues {
+		if _, ok := keysByName[keyValues.Key.Name]; !ok {
+			keysByName[keyValues.Key.Name] = keyValues.Key
+		}
+		if KeyTypeFormula == keyValues.Key.Type {
+			formulaKeys = append(formulaKeys, keyValues.Key)
+		}
+	}
+
+	// 0 未访问，1 访问中，2 已完成
+	states := map[string]int{}
+	var visit func(key *Key) bool
+	visit = func(key *Key) bool {
+		switch states[key.ID] {
+		case 1:
+			return false
+		case 2:
+			return !cyclicKeys[key.ID]
+		}
+
+		states[key.ID] = 1
+		acyclic := true
+		if nil != key.Formula {
+			if program, err := ParseFormula(key.Formula.Expr); nil == err {
+				programs[key.ID] = program
+				for _, ref := range program.Refs {
+					if refKey := keysByName[ref]; nil != refKey && KeyTypeFormula == refKey.Type {
+						if !visit(refKey) {
+							acyclic = false
+						}
+					}
+				}
+			}
+		}
+		states[key.ID] = 2
+		if !acyclic {
+			cyclicKeys[key.ID] = true
+			return false
+		}
+
+		if program := programs[key.ID]; nil != program {
+			key.Formula.ResultType = program.InferResultType(func(name string) *Key { return keysByName[name] })
+		}
+		ret = append(ret, key)
+		return true
+	}
+
+	for _, key := range formulaKeys {
+		visit(key)
+	}
+	for _, key := range formulaKeys {
+		if cyclicKeys[key.ID] {
+			ret = append(ret, key)
+		}
+	}
+	return
+}
+
+// formulaKind 描述了公式中值的类型，类型推导时 formulaKindNull 表示无法确定。
+type formulaKind int
+
+const (
+	formulaKindNull formulaKind = iota
+	formulaKindNumber
+	formulaKindText
+	formulaKindDate
+	formulaKindCheckbox
+	formulaKindList
+)
+
+func (kind formulaKind) String() string {
+	switch kind {
+	case formulaKindNumber:
+		return "number"
+	case formulaKindText:
+		return "text"
+	case formulaKindDate:
+		return "date"
+	case formulaKindCheckbox:
+		return "checkbox"
+	case formulaKindList:
+		return "list"
+	}
+	return "empty"
+}
+
+func (kind formulaKind) resultType() KeyType {
+	switch kind {
+	case formulaKindNumber:
+		return KeyTypeNumber
+	case formulaKindDate:
+		return KeyTypeDate
+	case formulaKindCheckbox:
+		return KeyTypeCheckbox
+	}
+	return KeyTypeText
+}
+
+func formulaKindOfKey(key *Key) formulaKind {
+	if nil == key {
+		return formulaKindNull
+	}
+
+	switch key.Type {
+	case KeyTypeNumber:
+		return formulaKindNumber
+	case KeyTypeDate, KeyTypeCreated, KeyTypeUpdated:
+		return formulaKindDate
+	case KeyTypeCheckbox:
+		return formulaKindCheckbox
+	case KeyTypeMSelect, KeyTypeMAsset, KeyTypeRelation, KeyTypeRollup:
+		return formulaKindList
+	case KeyTypeFormula:
+		if nil == key.Formula {
+			return formulaKindText
+		}
+		switch key.Formula.ResultType {
+		case KeyTypeNumber:
+			return formulaKindNumber
+		case KeyTypeDate:
+			return formulaKindDate
+		case KeyTypeCheckbox:
+			return formulaKindCheckbox
+		}
+		return formulaKindText
+	}
+	return formulaKindText
+}
+
+type formulaValue struct {
+	kind      formulaKind
+	num       float64
+	text      string
+	date      int64 // 毫秒时间戳
+	isNotTime bool  // 日期是否不包含时间
+	checked   bool
+	list      []*formulaValue
+}
+
+var formulaNull = &formulaValue{}
+
+func newFormulaNumber(num float64) *formulaValue {
+	return &formulaValue{kind: formulaKindNumber, num: num}
+}
+
+func newFormulaText(text string) *formulaValue {
+	return &formulaValue{kind: formulaKindText, text: text}
+}
+
+func newFormulaDate(t time.Time, isNotTime bool) *formulaValue {
+	return &formulaValue{kind: formulaKindDate, date: t.UnixMilli(), isNotTime: isNotTime}
+}
+
+func newFormulaCheckbox(checked bool) *formulaValue {
+	return &formulaValue{kind: formulaKindCheckbox, checked: checked}
+}
+
+// newFormulaValue 将字段值转换为公式中的值。
+func newFormulaValue(value *Value) *formulaValue {
+	if nil == value {
+		return formulaNull
+	}
+
+	switch value.Type {
+	case KeyTypeBlock:
+		if nil != value.Block {
+			return newFormulaText(value.Block.Content)
+		}
+	case KeyTypeText:
+		if nil != value.Text {
+			return newFormulaText(value.Text.Content)
+		}
+	case KeyTypeNumber:
+		if nil != value.Number && value.Number.IsNotEmpty {
+			return newFormulaNumber(value.Number.Content)
+		}
+	case KeyTypeDate:
+		if nil != value.Date && value.Date.IsNotEmpty {
+			return &formulaValue{kind: formulaKindDate, date: value.Date.Content, isNotTime: value.Date.IsNotTime}
+		}
+	case KeyTypeSelect:
+		if 0 < len(value.MSelect) {
+			return newFormulaText(value.MSelect[0].Content)
+		}
+	case KeyTypeMSelect:
+		ret := &formulaValue{kind: formulaKindList}
+		for _, s := range value.MSelect {
+			ret.list = append(ret.list, newFormulaText(s.Content))
+		}
+		return ret
+	case KeyTypeURL:
+		if nil != value.URL {
+			return newFormulaText(value.URL.Content)
+		}
+	case KeyTypeEmail:
+		if nil != value.Email {
+			return newFormulaText(value.Email.Content)
+		}
+	case KeyTypePhone:
+		if nil != value.Phone {
+			return newFormulaText(value.Phone.Content)
+		}
+	case KeyTypeMAsset:
+		ret := &formulaValue{kind: formulaKindList}
+		for _, asset := range value.MAsset {
+			ret.list = append(ret.list, newFormulaText(asset.Content))
+		}
+		return ret
+	case KeyTypeTemplate:
+		if nil != value.Template {
+			return newFormulaText(strings.TrimSpace(value.Template.Content))
+		}
+	case KeyTypeCreated:
+		if nil != value.Created && value.Created.IsNotEmpty {
+			return &formulaValue{kind: formulaKindDate, date: value.Created.Content}
+		}
+	case KeyTypeUpdated:
+		if nil != value.Updated && value.Updated.IsNotEmpty {
+			return &formulaValue{kind: formulaKindDate, date: value.Updated.Content}
+		}
+	case KeyTypeCheckbox:
+		return newFormulaCheckbox(nil != value.Checkbox && value.Checkbox.Checked)
+	case KeyTypeRelation:
+		ret := &formulaValue{kind: formulaKindList}
+		if nil != value.Relation {
+			for _, content := range value.Relation.Contents {
+				ret.list = append(ret.list, newFormulaValue(content))
+			}
+		}
+		return ret
+	case KeyTypeRollup:
+		ret := &formulaValue{kind: formulaKindList}
+		if nil != value.Rollup {
+			for _, content := range value.Rollup.Contents {
+				ret.list = append(ret.list, newFormulaValue(content))
+			}
+		}
+		return ret
+	case KeyTypeFormula:
+		if nil != value.Formula && "" == value.Formula.Error {
+			return newFormulaValue(value.GetFormulaResult())
+		}
+	}
+	return formulaNull
+}
+
+func (v *formulaValue) isEmpty() bool {
+	switch v.kind {
+	case formulaKindNull:
+		return true
+	case formulaKindText:
+		return "" == strings.TrimSpace(v.text)
+	case formulaKindCheckbox:
+		return !v.checked
+	case formulaKindList:
+		for _, item := range v.list {
+			if !item.isEmpty() {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// toNumber 将值转换为数字，isNull 表示空值。只包含一个元素的列表（比如汇总计算结果）按该元素处理。
+func (v *formulaValue) toNumber() (ret float64, isNull bool, err error) {
+	switch v.kind {
+	case formulaKindNull:
+		isNull = true
+	case formulaKindNumber:
+		ret = v.num
+	case formulaKindCheckbox:
+		if v.checked {
+			ret = 1
+		}
+	case formulaKindText:
+		if "" == strings.TrimSpace(v.text) {
+			isNull = true
+			return
+		}
+		var ok bool
+		if ret, ok = util.Convert2Float(v.text); !ok {
+			err = fmt.Errorf("cannot convert [%s] to number", v.text)
+		}
+	case formulaKindDate:
+		ret = float64(v.date)
+	case formulaKindList:
+		switch len(v.list) {
+		case 0:
+			isNull = true
+		case 1:
+			return v.list[0].toNumber()
+		default:
+			err = errors.New("cannot convert list to number")
+		}
+	}
+	return
+}
+
+func (v *formulaValue) toText() string {
+	switch v.kind {
+	case formulaKindNumber:
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	case formulaKindText:
+		return v.text
+	case formulaKindDate:
+		if v.isNotTime {
+			return time.UnixMilli(v.date).Format("2006-01-02")
+		}
+		return time.UnixMilli(v.date).Format("2006-01-02 15:04")
+	case formulaKindCheckbox:
+		if v.checked {
+			return "true"
+		}
+		return "false"
+	case formulaKindList:
+		var texts []string
+		for _, item := range v.list {
+			if text := item.toText(); "" != text {
+				texts = append(texts, text)
+			}
+		}
+		return strings.Join(texts, ", ")
+	}
+	return ""
+}
+
+func (v *formulaValue) toBool() bool {
+	switch v.kind {
+	case formulaKindNumber:
+		return 0 != v.num
+	case formulaKindDate:
+		return true
+	case formulaKindList:
+		if 1 == len(v.list) {
+			return v.list[0].toBool()
+		}
+	}
+	return !v.isEmpty()
+}
+
+// toDate 将值转换为日期，只包含一个元素的列表（比如汇总最早/最晚日期）按该元素处理。
+func (v *formulaValue) toDate() (ret *formulaValue, err error) {
+	switch v.kind {
+	case formulaKindNull:
+		ret = formulaNull
+	case formulaKindDate:
+		ret = v
+	case formulaKindText:
+		ret, err = parseFormulaDate(v.text)
+	case formulaKindList:
+		switch len(v.list) {
+		case 0:
+			ret = formulaNull
+		case 1:
+			return v.list[0].toDate()
+		default:
+			err = errors.New("cannot convert list to date")
+		}
+	default:
+		err = fmt.Errorf("cannot convert %s to date", v.kind)
+	}
+	return
+}
+
+// flatten 展开列表，用于 sum/min/max 等聚合函数。
+func (v *formulaValue) flatten() (ret []*formulaValue) {
+	if formulaKindList != v.kind {
+		return []*formulaValue{v}
+	}
+	for _, item := range v.list {
+		ret = append(ret, item.flatten()...)
+	}
+	return
+}
+
+func parseFormulaDate(text string) (ret *formulaValue, err error) {
+	text = strings.TrimSpace(text)
+	if "" == text {
+		ret = formulaNull
+		return
+	}
+
+	for _, layout := range []string{"2006-01-02", "2006/01/02", "20060102"} {
+		if t, parseErr := time.ParseInLocation(layout, text, time.Local); nil == parseErr {
+			ret = newFormulaDate(t, true)
+			return
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006/01/02 15:04", "2006-01-02T15:04:05", time.RFC3339} {
+		if t, parseErr := time.ParseInLocation(layout, text, time.Local); nil == parseErr {
+			ret = newFormulaDate(t, false)
+			return
+		}
+	}
+	err = fmt.Errorf("cannot parse date [%s]", text)
+	return
+}
+
+type formulaContext struct {
+	resolver FormulaResolver
+	now      time.Time
+}
+
+type formulaNode interface {
+	eval(ctx *formulaContext) (*formulaValue, error)
+	kind(keyKind func(keyName string) formulaKind) formulaKind
+}
+
+type formulaLiteralNode struct {
+	value *formulaValue
+}
+
+func (n *formulaLiteralNode) eval(*formulaContext) (*formulaValue, error) {
+	return n.value, nil
+}
+
+func (n *formulaLiteralNode) kind(func(string) formulaKind) formulaKind {
+	return n.value.kind
+}
+
+type formulaPropNode struct {
+	name string
+}
+
+func (n *formulaPropNode) eval(ctx *formulaContext) (*formulaValue, error) {
+	if nil == ctx.resolver {
+		return formulaNull, nil
+	}
+
+	value, ok := ctx.resolver(n.name)
+	if !ok {
+		return nil, fmt.Errorf("field [%s] not found", n.name)
+	}
+	if nil != value && KeyTypeFormula == value.Type && nil != value.Formula && "" != value.Formula.Error {
+		return nil, fmt.Errorf("field [%s]: %s", n.name, value.Formula.Error)
+	}
+	return newFormulaValue(value), nil
+}
+
+func (n *formulaPropNode) kind(keyKind func(string) formulaKind) formulaKind {
+	return keyKind(n.name)
+}
+
+type formulaUnaryNode struct {
+	op string
+	x  formulaNode
+}
+
+func (n *formulaUnaryNode) eval(ctx *formulaContext) (*formulaValue, error) {
+	x, err := n.x.eval(ctx)
+	if nil != err {
+		return nil, err
+	}
+
+	if "!" == n.op {
+		return newFormulaCheckbox(!x.toBool()), nil
+	}
+
+	num, isNull, err := x.toNumber()
+	if nil != err || isNull {
+		return formulaNull, err
+	}
+	return newFormulaNumber(-num), nil
+}
+
+func (n *formulaUnaryNode) kind(func(string) formulaKind) formulaKind {
+	if "!" == n.op {
+		return formulaKindCheckbox
+	}
+	return formulaKindNumber
+}
+
+type formulaBinaryNode struct {
+	op   string
+	l, r formulaNode
+}
+
+func (n *formulaBinaryNode) eval(ctx *formulaContext) (*formulaValue, error) {
+	l, err := n.l.eval(ctx)
+	if nil != err {
+		return nil, err
+	}
+
+	// 逻辑运算短路求值
+	switch n.op {
+	case "&&":
+		if !l.toBool() {
+			return newFormulaCheckbox(false), nil
+		}
+		r, rErr := n.r.eval(ctx)
+		if nil != rErr {
+			return nil, rErr
+		}
+		return newFormulaCheckbox(r.toBool()), nil
+	case "||":
+		if l.toBool() {
+			return newFormulaCheckbox(true), nil
+		}
+		r, rErr := n.r.eval(ctx)
+		if nil != rErr {
+			return nil, rErr
+		}
+		return newFormulaCheckbox(r.toBool()), nil
+	}
+
+	r, err := n.r.eval(ctx)
+	if nil != err {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return newFormulaCheckbox(0 == compareFormulaValues(l, r)), nil
+	case "!=":
+		return newFormulaCheckbox(0 != compareFormulaValues(l, r)), nil
+	case "<", "<=", ">", ">=":
+		if formulaKindNull == l.kind || formulaKindNull == r.kind {
+			return newFormulaCheckbox(false), nil
+		}
+		result := compareFormulaValues(l, r)
+		switch n.op {
+		case "<":
+			return newFormulaCheckbox(0 > result), nil
+		case "<=":
+			return newFormulaCheckbox(0 >= result), nil
+		case ">":
+			return newFormulaCheckbox(0 < result), nil
+		default:
+			return newFormulaCheckbox(0 <= result), nil
+		}
+	case "+":
+		if formulaKindText == l.kind || formulaKindText == r.kind {
+			return newFormulaText(l.toText() + r.toText()), nil
+		}
+	}
+
+	lNum, lNull, err := l.toNumber()
+	if nil != err {
+		return nil, err
+	}
+	rNum, rNull, err := r.toNumber()
+	if nil != err {
+		return nil, err
+	}
+	if lNull || rNull {
+		return formulaNull, nil
+	}
+
+	switch n.op {
+	case "+":
+		return newFormulaNumber(lNum + rNum), nil
+	case "-":
+		return newFormulaNumber(lNum - rNum), nil
+	case "*":
+		return newFormulaNumber(lNum * rNum), nil
+	case "/":
+		if 0 == rNum {
+			return nil, errors.New("division by zero")
+		}
+		return newFormulaNumber(lNum / rNum), nil
+	case "%":
+		if 0 == rNum {
+			return nil, errors.New("division by zero")
+		}
+		return newFormulaNumber(math.Mod(lNum, rNum)), nil
+	case "^":
+		return newFormulaNumber(math.Pow(lNum, rNum)), nil
+	}
+	return nil, fmt.Errorf("unknown operator [%s]", n.op)
+}
+
+func (n *formulaBinaryNode) kind(keyKind func(string) formulaKind) formulaKind {
+	switch n.op {
+	case "&&", "||", "==", "!=", "<", "<=", ">", ">=":
+		return formulaKindCheckbox
+	case "+":
+		if formulaKindText == n.l.kind(keyKind) || formulaKindText == n.r.kind(keyKind) {
+			return formulaKindText
+		}
+	}
+	return formulaKindNumber
+}
+
+// compareFormulaValues 比较两个值，空值小于任何非空值。
+func compareFormulaValues(l, r *formulaValue) int {
+	if formulaKindNull == l.kind || formulaKindNull == r.kind {
+		if l.isEmpty() && r.isEmpty() {
+			return 0
+		}
+		if formulaKindNull == l.kind {
+			return -1
+		}
+		return 1
+	}
+
+	if l.kind == r.kind {
+		switch l.kind {
+		case formulaKindNumber:
+			return compareFormulaNumbers(l.num, r.num)
+		case formulaKindDate:
+			return compareFormulaNumbers(float64(l.date), float64(r.date))
+		case formulaKindCheckbox:
+			if l.checked == r.checked {
+				return 0
+			}
+			if r.checked {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(l.toText(), r.toText())
+	}
+
+	if formulaKindText != l.kind || formulaKindText != r.kind {
+		lNum, lNull, lErr := l.toNumber()
+		rNum, rNull, rErr := r.toNumber()
+		if nil == lErr && nil == rErr && !lNull && !rNull {
+			return compareFormulaNumbers(lNum, rNum)
+		}
+	}
+	return strings.Compare(l.toText(), r.toText())
+}
+
+func compareFormulaNumbers(l, r float64) int {
+	if l < r {
+		return -1
+	}
+	if l > r {
+		return 1
+	}
+	return 0
+}
+
+type formulaCallNode struct {
+	name string
+	fn   *formulaFunc
+	args []formulaNode
+}
+
+func (n *formulaCallNode) eval(ctx *formulaContext) (*formulaValue, error) {
+	if "if" == n.name { // 条件只计算命中的分支
+		cond, err := n.args[0].eval(ctx)
+		if nil != err {
+			return nil, err
+		}
+		if cond.toBool() {
+			return n.args[1].eval(ctx)
+		}
+		if 3 > len(n.args) {
+			return formulaNull, nil
+		}
+		return n.args[2].eval(ctx)
+	}
+
+	var args []*formulaValue
+	for _, arg := range n.args {
+		v, err := arg.eval(ctx)
+		if nil != err {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return n.fn.call(ctx, args)
+}
+
+func (n *formulaCallNode) kind(keyKind func(string) formulaKind) formulaKind {
+	if "if" == n.name {
+		thenKind := n.args[1].kind(keyKind)
+		if 3 > len(n.args) {
+			return thenKind
+		}
+		elseKind := n.args[2].kind(keyKind)
+		if formulaKindNull == thenKind {
+			return elseKind
+		}
+		if formulaKindNull == elseKind || thenKind == elseKind {
+			return thenKind
+		}
+		return formulaKindText
+	}
+	return n.fn.kind
+}
+
+type formulaFunc struct {
+	minArgs, maxArgs int         // maxArgs 为 -1 时表示不限参数个数
+	kind             formulaKind // 返回值类型
+	call             func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error)
+}
+
+var formulaFuncs map[string]*formulaFunc
+
+func init() {
+	formulaFuncs = map[string]*formulaFunc{
+		"if": {minArgs: 2, maxArgs: 3},
+		"empty": {1, 1, formulaKindCheckbox, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return newFormulaCheckbox(args[0].isEmpty()), nil
+		}},
+
+		// 数字
+		"abs":   formulaMathFunc(math.Abs),
+		"floor": formulaMathFunc(math.Floor),
+		"ceil":  formulaMathFunc(math.Ceil),
+		"sqrt":  formulaMathFunc(math.Sqrt),
+		"round": {1, 2, formulaKindNumber, formulaRound},
+		"pow":   {2, 2, formulaKindNumber, formulaPow},
+		"toNumber": {1, 1, formulaKindNumber, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return formulaNumberResult(args[0].toNumber())
+		}},
+		"sum":     {1, -1, formulaKindNumber, formulaAggregate("sum")},
+		"average": {1, -1, formulaKindNumber, formulaAggregate("average")},
+		"min":     {1, -1, formulaKindNumber, formulaAggregate("min")},
+		"max":     {1, -1, formulaKindNumber, formulaAggregate("max")},
+		"count":   {1, 1, formulaKindNumber, formulaCount},
+
+		// 文本
+		"length": {1, 1, formulaKindNumber, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return newFormulaNumber(float64(utf8.RuneCountInString(args[0].toText()))), nil
+		}},
+		"concat": {1, -1, formulaKindText, formulaConcat},
+		"join":   {2, 2, formulaKindText, formulaJoin},
+		"lower":  formulaTextFunc(strings.ToLower),
+		"upper":  formulaTextFunc(strings.ToUpper),
+		"trim":   formulaTextFunc(strings.TrimSpace),
+		"format": formulaTextFunc(func(s string) string { return s }),
+		"replace": {3, 3, formulaKindText, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return newFormulaText(strings.ReplaceAll(args[0].toText(), args[1].toText(), args[2].toText())), nil
+		}},
+		"slice":    {2, 3, formulaKindText, formulaSlice},
+		"contains": {2, 2, formulaKindCheckbox, formulaContains},
+		"startsWith": {2, 2, formulaKindCheckbox, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return newFormulaCheckbox(strings.HasPrefix(args[0].toText(), args[1].toText())), nil
+		}},
+		"endsWith": {2, 2, formulaKindCheckbox, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return newFormulaCheckbox(strings.HasSuffix(args[0].toText(), args[1].toText())), nil
+		}},
+
+		// 列表
+		"first": {1, 1, formulaKindNull, formulaFirst},
+		"last":  {1, 1, formulaKindNull, formulaLast},
+
+		// 日期
+		"now": {0, 0, formulaKindDate, func(ctx *formulaContext, _ []*formulaValue) (*formulaValue, error) {
+			return newFormulaDate(ctx.now, false), nil
+		}},
+		"today":        {0, 0, formulaKindDate, formulaToday},
+		"date":         {3, 3, formulaKindDate, formulaDate},
+		"parseDate":    {1, 1, formulaKindDate, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) { return args[0].toDate() }},
+		"dateAdd":      {3, 3, formulaKindDate, formulaDateAdd(1)},
+		"dateSubtract": {3, 3, formulaKindDate, formulaDateAdd(-1)},
+		"dateBetween":  {3, 3, formulaKindNumber, formulaDateBetween},
+		"formatDate":   {2, 2, formulaKindText, formulaFormatDate},
+		"year":         formulaDatePartFunc(func(t time.Time) int { return t.Year() }),
+		"month":        formulaDatePartFunc(func(t time.Time) int { return int(t.Month()) }),
+		"day":          formulaDatePartFunc(func(t time.Time) int { return t.Day() }),
+		"hour":         formulaDatePartFunc(func(t time.Time) int { return t.Hour() }),
+		"minute":       formulaDatePartFunc(func(t time.Time) int { return t.Minute() }),
+		"weekday":      formulaDatePartFunc(func(t time.Time) int { return int(t.Weekday()) }),
+	}
+}
+
+func formulaNumberResult(num float64, isNull bool, err error) (*formulaValue, error) {
+	if nil != err {
+		return nil, err
+	}
+	if isNull {
+		return formulaNull, nil
+	}
+	return newFormulaNumber(num), nil
+}
+
+func formulaMathFunc(f func(float64) float64) *formulaFunc {
+	return &formulaFunc{1, 1, formulaKindNumber, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+		num, isNull, err := args[0].toNumber()
+		if nil != err || isNull {
+			return formulaNumberResult(num, isNull, err)
+		}
+		return newFormulaNumber(f(num)), nil
+	}}
+}
+
+func formulaTextFunc(f func(string) string) *formulaFunc {
+	return &formulaFunc{1, 1, formulaKindText, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+		return newFormulaText(f(args[0].toText())), nil
+	}}
+}
+
+func formulaDatePartFunc(f func(time.Time) int) *formulaFunc {
+	return &formulaFunc{1, 1, formulaKindNumber, func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+		d, err := args[0].toDate()
+		if nil != err || formulaKindNull == d.kind {
+			return formulaNull, err
+		}
+		return newFormulaNumber(float64(f(time.UnixMilli(d.date)))), nil
+	}}
+}
+
+func formulaRound(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	num, isNull, err := args[0].toNumber()
+	if nil != err || isNull {
+		return formulaNumberResult(num, isNull, err)
+	}
+
+	precision := 0.0
+	if 1 < len(args) {
+		if precision, _, err = args[1].toNumber(); nil != err {
+			return nil, err
+		}
+	}
+	return newFormulaNumber(Round(num, int(precision))), nil
+}
+
+func formulaPow(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	x, xNull, err := args[0].toNumber()
+	if nil != err || xNull {
+		return formulaNumberResult(x, xNull, err)
+	}
+	y, yNull, err := args[1].toNumber()
+	if nil != err || yNull {
+		return formulaNumberResult(y, yNull, err)
+	}
+	return newFormulaNumber(math.Pow(x, y)), nil
+}
+
+func formulaAggregate(op string) func(*formulaContext, []*formulaValue) (*formulaValue, error) {
+	return func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+		var numbers []float64
+		for _, arg := range args {
+			for _, item := range arg.flatten() {
+				num, isNull, err := item.toNumber()
+				if nil != err {
+					return nil, err
+				}
+				if !isNull {
+					numbers = append(numbers, num)
+				}
+			}
+		}
+
+		if 1 > len(numbers) {
+			if "sum" == op {
+				return newFormulaNumber(0), nil
+			}
+			return formulaNull, nil
+		}
+
+		ret := numbers[0]
+		switch op {
+		case "sum", "average":
+			for _, num := range numbers[1:] {
+				ret += num
+			}
+			if "average" == op {
+				ret /= float64(len(numbers))
+			}
+		case "min":
+			for _, num := range numbers[1:] {
+				ret = math.Min(ret, num)
+			}
+		case "max":
+			for _, num := range numbers[1:] {
+				ret = math.Max(ret, num)
+			}
+		}
+		return newFormulaNumber(ret), nil
+	}
+}
+
+func formulaCount(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	count := 0
+	for _, item := range args[0].flatten() {
+		if !item.isEmpty() || formulaKindCheckbox == item.kind {
+			count++
+		}
+	}
+	return newFormulaNumber(float64(count)), nil
+}
+
+func formulaConcat(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	buf := strings.Builder{}
+	for _, arg := range args {
+		buf.WriteString(arg.toText())
+	}
+	return newFormulaText(buf.String()), nil
+}
+
+func formulaJoin(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	var texts []string
+	for _, item := range args[0].flatten() {
+		if text := item.toText(); "" != text {
+			texts = append(texts, text)
+		}
+	}
+	return newFormulaText(strings.Join(texts, args[1].toText())), nil
+}
+
+func formulaSlice(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	runes := []rune(args[0].toText())
+	start, _, err := args[1].toNumber()
+	if nil != err {
+		return nil, err
+	}
+	end := float64(len(runes))
+	if 2 < len(args) {
+		if end, _, err = args[2].toNumber(); nil != err {
+			return nil, err
+		}
+	}
+
+	s, e := max(0, min(int(start), len(runes))), max(0, min(int(end), len(runes)))
+	if s > e {
+		return newFormulaText(""), nil
+	}
+	return newFormulaText(string(runes[s:e])), nil
+}
+
+func formulaContains(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	target := args[1].toText()
+	if formulaKindList == args[0].kind {
+		for _, item := range args[0].flatten() {
+			if item.toText() == target {
+				return newFormulaCheckbox(true), nil
+			}
+		}
+		return newFormulaCheckbox(false), nil
+	}
+	return newFormulaCheckbox(strings.Contains(args[0].toText(), target)), nil
+}
+
+func formulaFirst(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	items := args[0].flatten()
+	if 1 > len(items) {
+		return formulaNull, nil
+	}
+	return items[0], nil
+}
+
+func formulaLast(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	items := args[0].flatten()
+	if 1 > len(items) {
+		return formulaNull, nil
+	}
+	return items[len(items)-1], nil
+}
+
+func formulaToday(ctx *formulaContext, _ []*formulaValue) (*formulaValue, error) {
+	y, m, d := ctx.now.Date()
+	return newFormulaDate(time.Date(y, m, d, 0, 0, 0, 0, time.Local), true), nil
+}
+
+func formulaDate(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	var parts [3]int
+	for i, arg := range args {
+		num, isNull, err := arg.toNumber()
+		if nil != err || isNull {
+			return formulaNull, err
+		}
+		parts[i] = int(num)
+	}
+	return newFormulaDate(time.Date(parts[0], time.Month(parts[1]), parts[2], 0, 0, 0, 0, time.Local), true), nil
+}
+
+func formulaDateAdd(sign int) func(*formulaContext, []*formulaValue) (*formulaValue, error) {
+	return func(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+		d, err := args[0].toDate()
+		if nil != err || formulaKindNull == d.kind {
+			return formulaNull, err
+		}
+		num, isNull, err := args[1].toNumber()
+		if nil != err || isNull {
+			return formulaNull, err
+		}
+
+		n := sign * int(num)
+		t := time.UnixMilli(d.date)
+		switch unit := normalizeFormulaDateUnit(args[2].toText()); unit {
+		case "year":
+			t = t.AddDate(n, 0, 0)
+		case "quarter":
+			t = t.AddDate(0, 3*n, 0)
+		case "month":
+			t = t.AddDate(0, n, 0)
+		case "week":
+			t = t.AddDate(0, 0, 7*n)
+		case "day":
+			t = t.AddDate(0, 0, n)
+		case "hour":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "minute":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "second":
+			t = t.Add(time.Duration(n) * time.Second)
+		default:
+			return nil, fmt.Errorf("unknown date unit [%s]", unit)
+		}
+		return newFormulaDate(t, d.isNotTime), nil
+	}
+}
+
+// formulaDateBetween 返回 date1 - date2 的差值，按单位向零取整。
+func formulaDateBetween(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	d1, err := args[0].toDate()
+	if nil != err || formulaKindNull == d1.kind {
+		return formulaNull, err
+	}
+	d2, err := args[1].toDate()
+	if nil != err || formulaKindNull == d2.kind {
+		return formulaNull, err
+	}
+
+	t1, t2 := time.UnixMilli(d1.date), time.UnixMilli(d2.date)
+	duration := t1.Sub(t2)
+	var ret int64
+	switch unit := normalizeFormulaDateUnit(args[2].toText()); unit {
+	case "year":
+		ret = int64(formulaMonthsBetween(t1, t2) / 12)
+	case "quarter":
+		ret = int64(formulaMonthsBetween(t1, t2) / 3)
+	case "month":
+		ret = int64(formulaMonthsBetween(t1, t2))
+	case "week":
+		ret = int64(duration / (7 * 24 * time.Hour))
+	case "day":
+		ret = int64(duration / (24 * time.Hour))
+	case "hour":
+		ret = int64(duration / time.Hour)
+	case "minute":
+		ret = int64(duration / time.Minute)
+	case "second":
+		ret = int64(duration / time.Second)
+	default:
+		return nil, fmt.Errorf("unknown date unit [%s]", unit)
+	}
+	return newFormulaNumber(float64(ret)), nil
+}
+
+func formulaMonthsBetween(t1, t2 time.Time) (ret int) {
+	ret = (t1.Year()-t2.Year())*12 + int(t1.Month()) - int(t2.Month())
+	if 0 < ret && t1.Before(t2.AddDate(0, ret, 0)) {
+		ret--
+	} else if 0 > ret && t1.After(t2.AddDate(0, ret, 0)) {
+		ret++
+	}
+	return
+}
+
+func normalizeFormulaDateUnit(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	return strings.TrimSuffix(unit, "s")
+}
+
+var formulaDateLayoutReplacer = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05")
+
+func formulaFormatDate(_ *formulaContext, args []*formulaValue) (*formulaValue, error) {
+	d, err := args[0].toDate()
+	if nil != err || formulaKindNull == d.kind {
+		return formulaNull, err
+	}
+	return newFormulaText(time.UnixMilli(d.date).Format(formulaDateLayoutReplacer.Replace(args[1].toText()))), nil
+}
+
+type formulaTokenType int
+
+const (
+	formulaTokenEOF formulaTokenType = iota
+	formulaTokenNumber
+	formulaTokenString
+	formulaTokenIdent
+	formulaTokenOp
+)
+
+type formulaToken struct {
+	typ  formulaTokenType
+	text string
+	num  float64
+	pos  int
+}
+
+func lexFormula(expr string) (ret []*formulaToken, err error) {
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || ('.' == r && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || '.' == runes[i]) {
+				i++
+			}
+			text := string(runes[start:i])
+			num, parseErr := strconv.ParseFloat(text, 64)
+			if nil != parseErr {
+				err = fmt.Errorf("invalid number [%s] at %d", text, start)
+				return
+			}
+			ret = append(ret, &formulaToken{typ: formulaTokenNumber, text: text, num: num, pos: start})
+		case '"' == r || '\'' == r:
+			start := i
+			buf := strings.Builder{}
+			i++
+			closed := false
+			for i < len(runes) {
+				if '\\' == runes[i] && i+1 < len(runes) {
+					switch runes[i+1] {
+					case 'n':
+						buf.WriteRune('\n')
+					case 't':
+						buf.WriteRune('\t')
+					default:
+						buf.WriteRune(runes[i+1])
+					}
+					i += 2
+					continue
+				}
+				if r == runes[i] {
+					closed = true
+					i++
+					break
+				}
+				buf.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				err = fmt.Errorf("unterminated string at %d", start)
+				return
+			}
+			ret = append(ret, &formulaToken{typ: formulaTokenString, text: buf.String(), pos: start})
+		case unicode.IsLetter(r) || '_' == r:
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || '_' == runes[i]) {
+				i++
+			}
+			ret = append(ret, &formulaToken{typ: formulaTokenIdent, text: string(runes[start:i]), pos: start})
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/%^()<>!,", op) && 1 == len([]rune(op)) {
+				err = fmt.Errorf("unexpected character [%s] at %d", op, i)
+				return
+			}
+			ret = append(ret, &formulaToken{typ: formulaTokenOp, text: op, pos: i})
+			i += len([]rune(op))
+		}
+	}
+	ret = append(ret, &formulaToken{typ: formulaTokenEOF, pos: len(runes)})
+	return
+}
+
+// formulaParser 按运算符优先级递归下降解析公式：|| < && < 相等 < 比较 < 加减 < 乘除取模 < 一元 < 乘方。
+type formulaParser struct {
+	tokens []*formulaToken
+	pos    int
+	refs   []string
+	depth  int
+}
+
+// enter 进入一层嵌套，超过最大嵌套深度时返回错误，调用方在返回前需调用 leave。
+func (p *formulaParser) enter() error {
+	p.depth++
+	if p.depth > MaxFormulaNestingDepth {
+		return ErrFormulaTooDeep
+	}
+	return nil
+}
+
+func (p *formulaParser) leave() {
+	p.depth--
+}
+
+func (p *formulaParser) peek() *formulaToken {
+	return p.tokens[p.pos]
+}
+
+func (p *formulaParser) next() *formulaToken {
+	tok := p.tokens[p.pos]
+	if formulaTokenEOF != tok.typ {
+		p.pos++
+	}
+	return tok
+}
+
+// matchOp 匹配运算符，and/or/not 作为 &&/||/! 的别名。
+func (p *formulaParser) matchOp(ops ...string) (ret string, ok bool) {
+	tok := p.peek()
+	text := tok.text
+	if formulaTokenIdent == tok.typ {
+		switch text {
+		case "and":
+			text = "&&"
+		case "or":
+			text = "||"
+		case "not":
+			text = "!"
+		default:
+			return
+		}
+	} else if formulaTokenOp != tok.typ {
+		return
+	}
+
+	for _, op := range ops {
+		if op == text {
+			p.next()
+			return op, true
+		}
+	}
+	return
+}
+
+func (p *formulaParser) expect(op string) error {
+	if tok := p.next(); formulaTokenOp != tok.typ || op != tok.text {
+		if formulaTokenEOF == tok.typ {
+			return fmt.Errorf("expected [%s] but reached end", op)
+		}
+		return fmt.Errorf("expected [%s] but got [%s] at %d", op, tok.text, tok.pos)
+	}
+	return nil
+}
+
+func (p *formulaParser) parseExpr() (formulaNode, error) {
+	defer p.leave()
+	if err := p.enter(); nil != err {
+		return nil, err
+	}
+	return p.parseBinary(0)
+}
+
+var formulaBinaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *formulaParser) parseBinary(level int) (ret formulaNode, err error) {
+	if level >= len(formulaBinaryLevels) {
+		return p.parseUnary()
+	}
+
+	if ret, err = p.parseBinary(level + 1); nil != err {
+		return
+	}
+	for {
+		op, ok := p.matchOp(formulaBinaryLevels[level]...)
+		if !ok {
+			return
+		}
+		var r formulaNode
+		if r, err = p.parseBinary(level + 1); nil != err {
+			return
+		}
+		ret = &formulaBinaryNode{op: op, l: ret, r: r}
+	}
+}
+
+func (p *formulaParser) parseUnary() (formulaNode, error) {
+	defer p.leave()
+	if err := p.enter(); nil != err {
+		return nil, err
+	}
+
+	if op, ok := p.matchOp("-", "!"); ok {
+		x, err := p.parseUnary()
+		if nil != err {
+			return nil, err
+		}
+		return &formulaUnaryNode{op: op, x: x}, nil
+	}
+	if _, ok := p.matchOp("+"); ok {
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+func (p *formulaParser) parsePower() (ret formulaNode, err error) {
+	if ret, err = p.parsePrimary(); nil != err {
+		return
+	}
+	if _, ok := p.matchOp("^"); ok {
+		var r formulaNode
+		if r, err = p.parseUnary(); nil != err { // 乘方右结合
+			return
+		}
+		ret = &formulaBinaryNode{op: "^", l: ret, r: r}
+	}
+	return
+}
+
+func (p *formulaParser) parsePrimary() (formulaNode, error) {
+	tok := p.next()
+	switch tok.typ {
+	case formulaTokenNumber:
+		return &formulaLiteralNode{value: newFormulaNumber(tok.num)}, nil
+	case formulaTokenString:
+		return &formulaLiteralNode{value: newFormulaText(tok.text)}, nil
+	case formulaTokenOp:
+		if "(" == tok.text {
+			x, err := p.parseExpr()
+			if nil != err {
+				return nil, err
+			}
+			if err = p.expect(")"); nil != err {
+				return nil, err
+			}
+			return x, nil
+		}
+		return nil, fmt.Errorf("unexpected [%s] at %d", tok.text, tok.pos)
+	case formulaTokenIdent:
+		switch tok.text {
+		case "true":
+			return &formulaLiteralNode{value: newFormulaCheckbox(true)}, nil
+		case "false":
+			return &formulaLiteralNode{value: newFormulaCheckbox(false)}, nil
+		case "prop":
+			return p.parseProp(tok)
+		}
+		return p.parseCall(tok)
+	}
+	return nil, errors.New("unexpected end of formula")
+}
+
+// parseProp 解析字段引用 prop("字段名")，字段名必须是字符串字面量。
+func (p *formulaParser) parseProp(tok *formulaToken) (formulaNode, error) {
+	if err := p.expect("("); nil != err {
+		return nil, err
+	}
+	name := p.next()
+	if formulaTokenString != name.typ {
+		return nil, fmt.Errorf("prop at %d expects a field name string", tok.pos)
+	}
+	if err := p.expect(")"); nil != err {
+		return nil, err
+	}
+	p.refs = append(p.refs, name.text)
+	return &formulaPropNode{name: name.text}, nil
+}
+
+func (p *formulaParser) parseCall(tok *formulaToken) (formulaNode, error) {
+	fn := formulaFuncs[tok.text]
+	if nil == fn {
+		return nil, fmt.Errorf("unknown function [%s] at %d", tok.text, tok.pos)
+	}
+	if err := p.expect("("); nil != err {
+		return nil, err
+	}
+
+	ret := &formulaCallNode{name: tok.text, fn: fn}
+	if _, ok := p.matchOp(")"); !ok {
+		for {
+			arg, err := p.parseExpr()
+			if nil != err {
+				return nil, err
+			}
+			ret.args = append(ret.args, arg)
+			if _, ok = p.matchOp(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); nil != err {
+			return nil, err
+		}
+	}
+
+	if len(ret.args) < fn.minArgs || (-1 != fn.maxArgs && len(ret.args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments for [%s] at %d", tok.text, tok.pos)
+	}
+	return ret, nil
+}
@@ -0,0 +1,220 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFormulaKeyValues() []*KeyValues {
+	day := time.Date(2024, 3, 30, 0, 0, 0, 0, time.Local).UnixMilli()
+	return []*KeyValues{
+		{Key: &Key{ID: "name", Name: "Name", Type: KeyTypeBlock}, Values: []*Value{{KeyID: "name", BlockID: "r", Type: KeyTypeBlock, Block: &ValueBlock{Content: "Task"}}}},
+		{Key: &Key{ID: "price", Name: "Price", Type: KeyTypeNumber}, Values: []*Value{{KeyID: "price", BlockID: "r", Type: KeyTypeNumber, Number: &ValueNumber{Content: 12.5, IsNotEmpty: true}}}},
+		{Key: &Key{ID: "qty", Name: "Qty", Type: KeyTypeNumber}, Values: []*Value{{KeyID: "qty", BlockID: "r", Type: KeyTypeNumber, Number: &ValueNumber{}}}},
+		{Key: &Key{ID: "due", Name: "Due", Type: KeyTypeDate}, Values: []*Value{{KeyID: "due", BlockID: "r", Type: KeyTypeDate, Date: &ValueDate{Content: day, IsNotEmpty: true, IsNotTime: true}}}},
+		{Key: &Key{ID: "done", Name: "Done", Type: KeyTypeCheckbox}, Values: []*Value{{KeyID: "done", BlockID: "r", Type: KeyTypeCheckbox, Checkbox: &ValueCheckbox{Checked: true}}}},
+		{Key: &Key{ID: "tags", Name: "Tags", Type: KeyTypeMSelect}, Values: []*Value{{KeyID: "tags", BlockID: "r", Type: KeyTypeMSelect, MSelect: []*ValueSelect{{Content: "a"}, {Content: "b"}}}}},
+		{Key: &Key{ID: "sum", Name: "Sum", Type: KeyTypeRollup}, Values: []*Value{{KeyID: "sum", BlockID: "r", Type: KeyTypeRollup, Rollup: &ValueRollup{Contents: []*Value{{Type: KeyTypeNumber, Number: &ValueNumber{Content: 7, IsNotEmpty: true}}}}}}},
+		{Key: &Key{ID: "rel", Name: "Rel", Type: KeyTypeRelation}, Values: []*Value{{KeyID: "rel", BlockID: "r", Type: KeyTypeRelation, Relation: &ValueRelation{Contents: []*Value{
+			{Type: KeyTypeBlock, Block: &ValueBlock{Content: "x"}},
+			{Type: KeyTypeBlock, Block: &ValueBlock{Content: "y"}},
+		}}}}},
+	}
+}
+
+func evalTestFormula(t *testing.T, expr string, keyValues []*KeyValues) *Value {
+	program, err := ParseFormula(expr)
+	if nil != err {
+		t.Fatalf("parse formula [%s] failed: %s", expr, err)
+	}
+
+	keyOf := func(name string) *Key {
+		for _, kv := range keyValues {
+			if kv.Key.Name == name {
+				return kv.Key
+			}
+		}
+		return nil
+	}
+	value := &Value{Type: KeyTypeFormula}
+	program.Eval(value, program.InferResultType(keyOf), NumberFormatNone, func(name string) (*Value, bool) {
+		key := keyOf(name)
+		if nil == key {
+			return nil, false
+		}
+		return GetValue(keyValues, key.ID, "r"), true
+	})
+	return value
+}
+
+func TestFormulaEval(t *testing.T) {
+	keyValues := testFormulaKeyValues()
+	cases := []struct {
+		expr       string
+		resultType KeyType
+		want       string
+	}{
+		{`1 + 2 * 3 - 4 / 2`, KeyTypeNumber, "5"},
+		{`(1 + 2) * 3 % 4`, KeyTypeNumber, "1"},
+		{`-2 ^ 2`, KeyTypeNumber, "-4"},
+		{`2 ^ 3 ^ 2`, KeyTypeNumber, "512"},
+		{`prop("Price") * 2`, KeyTypeNumber, "25"},
+		{`prop("Price") * prop("Qty")`, KeyTypeNumber, ""},
+		{`round(prop("Price") / 3, 2)`, KeyTypeNumber, "4.17"},
+		{`sum(prop("Sum"), 3) + prop("Sum")`, KeyTypeNumber, "17"},
+		{`count(prop("Rel"))`, KeyTypeNumber, "2"},
+		{`prop("Name") + " #" + 1`, KeyTypeText, "Task #1"},
+		{`upper(join(prop("Tags"), "|"))`, KeyTypeText, "A|B"},
+		{`if(prop("Done"), "yes", "no")`, KeyTypeText, "yes"},
+		{`if(empty(prop("Qty")), 0, 1)`, KeyTypeNumber, "0"},
+		{`prop("Price") > 10 and not prop("Done")`, KeyTypeCheckbox, ""},
+		{`contains(prop("Tags"), "b") || false`, KeyTypeCheckbox, CheckboxCheckedStr},
+		{`dateAdd(prop("Due"), 3, "days")`, KeyTypeDate, "2024-04-02"},
+		{`dateSubtract(prop("Due"), 1, "month")`, KeyTypeDate, "2024-03-01"},
+		{`dateBetween(date(2024, 5, 29), prop("Due"), "months")`, KeyTypeNumber, "1"},
+		{`formatDate(prop("Due"), "YYYY/MM/DD")`, KeyTypeText, "2024/03/30"},
+		{`year(prop("Due")) * 100 + month(prop("Due"))`, KeyTypeNumber, "202403"},
+		{`slice("思源笔记", 2)`, KeyTypeText, "笔记"},
+	}
+	for _, c := range cases {
+		value := evalTestFormula(t, c.expr, keyValues)
+		if "" != value.Formula.Error {
+			t.Fatalf("formula [%s] failed: %s", c.expr, value.Formula.Error)
+		}
+		if c.resultType != value.Formula.ResultType {
+			t.Fatalf("formula [%s] expected result type [%s], got [%s]", c.expr, c.resultType, value.Formula.ResultType)
+		}
+		if got := value.String(true); c.want != got {
+			t.Fatalf("formula [%s] expected [%s], got [%s]", c.expr, c.want, got)
+		}
+	}
+}
+
+func TestFormulaErrors(t *testing.T) {
+	for _, expr := range []string{``, `1 +`, `foo(1)`, `prop(Name)`, `round()`, `"abc`, `1 = 2`} {
+		if _, err := ParseFormula(expr); nil == err {
+			t.Fatalf("expected parse error for formula [%s]", expr)
+		}
+	}
+
+	keyValues := testFormulaKeyValues()
+	for _, expr := range []string{`1 / 0`, `prop("Missing")`, `dateAdd(prop("Due"), 1, "fortnight")`} {
+		value := evalTestFormula(t, expr, keyValues)
+		if "" == value.Formula.Error {
+			t.Fatalf("expected eval error for formula [%s]", expr)
+		}
+		if !value.IsEmpty() {
+			t.Fatalf("expected failed formula [%s] to be empty", expr)
+		}
+	}
+}
+
+func TestFormulaLimits(t *testing.T) {
+	nested := strings.Repeat("(", 50) + "1" + strings.Repeat(")", 50)
+	if _, err := ParseFormula(nested); nil != err {
+		t.Fatalf("parse nested formula failed: %s", err)
+	}
+
+	for _, expr := range []string{
+		strings.Repeat("(", 1000) + "1" + strings.Repeat(")", 1000),
+		strings.Repeat("-", 1000) + "1",
+		strings.Repeat("abs(", 500) + "1" + strings.Repeat(")", 500),
+	} {
+		if _, err := ParseFormula(expr); !errors.Is(err, ErrFormulaTooDeep) {
+			t.Fatalf("expected too deep error, got [%v]", err)
+		}
+	}
+
+	if _, err := ParseFormula(strings.Repeat("1+", MaxFormulaLength) + "1"); !errors.Is(err, ErrFormulaTooLong) {
+		t.Fatalf("expected too long error, got [%v]", err)
+	}
+}
+
+func TestFormulaNonFiniteResults(t *testing.T) {
+	keyValues := testFormulaKeyValues()
+	for _, expr := range []string{`sqrt(-1)`, `10 ^ 1000`, `pow(10, 400)`} {
+		value := evalTestFormula(t, expr, keyValues)
+		if "" == value.Formula.Error {
+			t.Fatalf("expected non-finite error for formula [%s]", expr)
+		}
+		if !value.IsEmpty() {
+			t.Fatalf("expected non-finite formula [%s] to be empty", expr)
+		}
+		if _, err := json.Marshal(value); nil != err {
+			t.Fatalf("marshal non-finite formula [%s] failed: %s", expr, err)
+		}
+	}
+}
+
+func TestFormulaTypedResults(t *testing.T) {
+	keyValues := testFormulaKeyValues()
+	v1 := evalTestFormula(t, `prop("Price") - 3`, keyValues)
+	v2 := evalTestFormula(t, `prop("Price") + 100`, keyValues)
+	if 0 <= v1.Compare(v2, nil) {
+		t.Fatal("expected numeric comparison of formula results")
+	}
+
+	filterValue := &Value{Type: KeyTypeFormula, Formula: &ValueFormula{ResultType: KeyTypeNumber}, Number: &ValueNumber{Content: 20, IsNotEmpty: true}}
+	if !v1.filter(filterValue, nil, nil, FilterOperatorIsLess) || v2.filter(filterValue, nil, nil, FilterOperatorIsLess) {
+		t.Fatal("expected numeric filtering of formula results")
+	}
+
+	checked := evalTestFormula(t, `prop("Done")`, keyValues)
+	if !checked.filter(nil, nil, nil, FilterOperatorIsTrue) {
+		t.Fatal("expected checkbox filtering of formula results")
+	}
+
+	due := evalTestFormula(t, `dateAdd(prop("Due"), 1, "day")`, keyValues)
+	dateFilter := &Value{Type: KeyTypeFormula, Formula: &ValueFormula{ResultType: KeyTypeDate}, Date: &ValueDate{Content: time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local).UnixMilli(), IsNotEmpty: true}}
+	if !due.filter(dateFilter, nil, nil, FilterOperatorIsLess) {
+		t.Fatal("expected date filtering of formula results")
+	}
+
+	textFilter := &Value{Type: KeyTypeFormula, Formula: &ValueFormula{ResultType: KeyTypeText}, Text: &ValueText{Content: "x"}}
+	if !v1.filter(textFilter, nil, nil, FilterOperatorContains) {
+		t.Fatal("expected filter with mismatched result type to be ignored")
+	}
+}
+
+func TestGetFormulaKeysByResolutionOrder(t *testing.T) {
+	attrView := &AttributeView{KeyValues: []*KeyValues{
+		{Key: &Key{ID: "n", Name: "N", Type: KeyTypeNumber}},
+		{Key: &Key{ID: "c", Name: "C", Type: KeyTypeFormula, Formula: &Formula{Expr: `prop("B") > 1`}}},
+		{Key: &Key{ID: "b", Name: "B", Type: KeyTypeFormula, Formula: &Formula{Expr: `prop("N") * 2`}}},
+		{Key: &Key{ID: "x", Name: "X", Type: KeyTypeFormula, Formula: &Formula{Expr: `prop("Y")`}}},
+		{Key: &Key{ID: "y", Name: "Y", Type: KeyTypeFormula, Formula: &Formula{Expr: `prop("X")`}}},
+	}}
+
+	keys, programs, cyclicKeys := GetFormulaKeysByResolutionOrder(attrView)
+	if 4 != len(keys) || "b" != keys[0].ID || "c" != keys[1].ID {
+		t.Fatalf("unexpected resolution order [%v]", keys)
+	}
+	if nil == programs["b"] || nil == programs["c"] {
+		t.Fatal("expected compiled programs")
+	}
+	if !cyclicKeys["x"] || !cyclicKeys["y"] || cyclicKeys["b"] {
+		t.Fatalf("unexpected cyclic keys [%v]", cyclicKeys)
+	}
+	if KeyTypeNumber != keys[0].Formula.ResultType || KeyTypeCheckbox != keys[1].Formula.ResultType {
+		t.Fatalf("unexpected result types [%s] [%s]", keys[0].Formula.ResultType, keys[1].Formula.ResultType)
+	}
+}
@@ -84,6 +84,12 @@ func NewViewBaseInstance(view *View) *BaseInstance {
 	case LayoutTypeKanban:
 		showIcon = view.Kanban.ShowIcon
 		wrapField = view.Kanban.WrapField
+	case LayoutTypeCalendar:
+		showIcon = view.Calendar.ShowIcon
+		wrapField = view.Calendar.WrapField
+	case LayoutTypeTimeline:
+		showIcon = view.Timeline.ShowIcon
+		wrapField = view.Timeline.WrapField
 	}
 	return &BaseInstance{
 		ID:               view.ID,
@@ -162,6 +168,7 @@ type BaseInstanceField struct {
 	Date         *Date           `json:"date,omitempty"`     // 日期设置
 	Created      *Created        `json:"created,omitempty"`  // 创建时间设置
 	Updated      *Updated        `json:"updated,omitempty"`  // 更新时间设置
+	Formula      *Formula        `json:"formula,omitempty"`  // 公式设置
 }
 
 func (baseInstanceField *BaseInstanceField) GetID() string {
@@ -248,3 +255,55 @@ type Item interface {
 	// GetID 返回项目的 ID。
 	GetID() string
 }
+
+// DateSpan 描述了日历和时间线布局中项目的日期区间。
+type DateSpan struct {
+	Start  int64 `json:"start"`  // 开始时间戳（毫秒），0 表示未排期
+	End    int64 `json:"end"`    // 结束时间戳（毫秒），没有结束日期时和开始时间相同
+	AllDay bool  `json:"allDay"` // 是否为全天（日期字段未包含时间）
+}
+
+// NewDateSpan 根据开始日期字段值和结束日期字段值生成日期区间。
+// 没有指定结束日期字段时使用开始日期字段自身的结束日期；结束早于开始时按开始时间处理。
+func NewDateSpan(startValue, endValue *Value) (ret *DateSpan) {
+	ret = &DateSpan{}
+	if nil == startValue || nil == startValue.Date || !startValue.Date.IsNotEmpty {
+		return
+	}
+
+	ret.Start = startValue.Date.Content
+	ret.End = ret.Start
+	ret.AllDay = startValue.Date.IsNotTime
+	if nil != endValue && nil != endValue.Date && endValue.Date.IsNotEmpty {
+		ret.End = endValue.Date.Content
+		ret.AllDay = ret.AllDay && endValue.Date.IsNotTime
+	} else if startValue.Date.HasEndDate && startValue.Date.IsNotEmpty2 {
+		ret.End = startValue.Date.Content2
+	}
+	if ret.End < ret.Start {
+		ret.End = ret.Start
+	}
+	return
+}
+
+// IsScheduled 判断日期区间是否已排期。
+func (span *DateSpan) IsScheduled() bool {
+	return 0 != span.Start
+}
+
+// Overlaps 判断日期区间是否与可见区间相交，未排期的日期区间不与任何可见区间相交。
+func (span *DateSpan) Overlaps(window *DateWindow) bool {
+	return span.IsScheduled() && span.Start < window.End && window.Start <= span.End
+}
+
+// DateWindow 描述了日历和时间线布局当前可见的日期区间 [Start, End)，单位为毫秒。
+// 渲染时只有与可见区间相交的项目参与计数和分页。
+type DateWindow struct {
+	Start int64 `json:"start"` // 开始时间戳（毫秒，包含）
+	End   int64 `json:"end"`   // 结束时间戳（毫秒，不包含）
+}
+
+// IsValid 判断可见区间是否有效。
+func (window *DateWindow) IsValid() bool {
+	return nil != window && window.Start < window.End
+}
@@ -0,0 +1,178 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"github.com/88250/lute/ast"
+)
+
+// CalendarMode 描述了日历视图的显示模式。
+type CalendarMode string
+
+const (
+	CalendarModeMonth CalendarMode = "month" // 月视图
+	CalendarModeWeek  CalendarMode = "week"  // 周视图
+)
+
+// IsValid 判断日历视图的显示模式是否合法。
+func (mode CalendarMode) IsValid() bool {
+	return CalendarModeMonth == mode || CalendarModeWeek == mode
+}
+
+// LayoutCalendar 描述了日历布局的结构。
+type LayoutCalendar struct {
+	*BaseLayout
+
+	StartKeyID       string       `json:"startKeyID"`         // 开始日期字段 ID
+	EndKeyID         string       `json:"endKeyID,omitempty"` // 结束日期字段 ID，为空时使用开始日期字段自身的结束日期
+	Mode             CalendarMode `json:"mode"`               // 显示模式，month：月视图，week：周视图
+	FirstDayOfWeek   int          `json:"firstDayOfWeek"`     // 每周的第一天，0：周日，1：周一
+	DisplayFieldName bool         `json:"displayFieldName"`   // 是否显示字段名称
+
+	Fields []*ViewCalendarField `json:"fields"` // 字段
+}
+
+func NewLayoutCalendar() *LayoutCalendar {
+	return &LayoutCalendar{
+		BaseLayout: &BaseLayout{
+			Spec:     0,
+			ID:       ast.NewNodeID(),
+			ShowIcon: true,
+		},
+		Mode:           CalendarModeMonth,
+		FirstDayOfWeek: 1,
+	}
+}
+
+// ViewCalendarField 描述了日历字段的结构。
+type ViewCalendarField struct {
+	*BaseField
+}
+
+// Calendar 描述了日历视图实例的结构。
+type Calendar struct {
+	*BaseInstance
+
+	StartKeyID       string           `json:"startKeyID"`       // 开始日期字段 ID
+	EndKeyID         string           `json:"endKeyID"`         // 结束日期字段 ID
+	Mode             CalendarMode     `json:"mode"`             // 显示模式
+	FirstDayOfWeek   int              `json:"firstDayOfWeek"`   // 每周的第一天
+	DisplayFieldName bool             `json:"displayFieldName"` // 是否显示字段名称
+	Fields           []*CalendarField `json:"fields"`           // 事件字段
+	Events           []*CalendarEvent `json:"events"`           // 事件
+	EventCount       int              `json:"eventCount"`       // 总事件数（指定可见区间时为区间内的事件数）
+	Window           *DateWindow      `json:"window,omitempty"` // 可见日期区间，为空时不按日期过滤
+}
+
+// CalendarEvent 描述了日历实例事件的结构。
+type CalendarEvent struct {
+	*DateSpan
+
+	ID     string                `json:"id"`     // 事件 ID
+	Values []*CalendarFieldValue `json:"values"` // 事件字段值
+}
+
+// CalendarField 描述了日历实例字段的结构。
+type CalendarField struct {
+	*BaseInstanceField
+}
+
+// CalendarFieldValue 描述了事件字段实例值的结构。
+type CalendarFieldValue struct {
+	*BaseValue
+}
+
+func (event *CalendarEvent) GetID() string {
+	return event.ID
+}
+
+func (event *CalendarEvent) GetBlockValue() (ret *Value) {
+	for _, v := range event.Values {
+		if KeyTypeBlock == v.ValueType {
+			ret = v.Value
+			break
+		}
+	}
+	return
+}
+
+func (event *CalendarEvent) GetValues() (ret []*Value) {
+	ret = []*Value{}
+	for _, v := range event.Values {
+		ret = append(ret, v.Value)
+	}
+	return
+}
+
+func (event *CalendarEvent) GetValue(keyID string) (ret *Value) {
+	for _, value := range event.Values {
+		if nil != value.Value && keyID == value.Value.KeyID {
+			ret = value.Value
+			break
+		}
+	}
+	return
+}
+
+func (calendar *Calendar) GetItems() (ret []Item) {
+	ret = []Item{}
+	for _, event := range calendar.Events {
+		ret = append(ret, event)
+	}
+	return
+}
+
+func (calendar *Calendar) SetItems(items []Item) {
+	calendar.Events = []*CalendarEvent{}
+	for _, item := range items {
+		calendar.Events = append(calendar.Events, item.(*CalendarEvent))
+	}
+}
+
+func (calendar *Calendar) CountItems() int {
+	return len(calendar.Events)
+}
+
+func (calendar *Calendar) GetFields() (ret []Field) {
+	ret = []Field{}
+	for _, field := range calendar.Fields {
+		ret = append(ret, field)
+	}
+	return ret
+}
+
+func (calendar *Calendar) GetField(id string) (ret Field, fieldIndex int) {
+	for i, field := range calendar.Fields {
+		if field.ID == id {
+			return field, i
+		}
+	}
+	return nil, -1
+}
+
+func (calendar *Calendar) GetValue(itemID, keyID string) (ret *Value) {
+	for _, event := range calendar.Events {
+		if event.ID == itemID {
+			return event.GetValue(keyID)
+		}
+	}
+	return nil
+}
+
+func (calendar *Calendar) GetType() LayoutType {
+	return LayoutTypeCalendar
+}
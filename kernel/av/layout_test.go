@@ -0,0 +1,98 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewDateSpan(t *testing.T) {
+	if span := NewDateSpan(nil, nil); span.IsScheduled() {
+		t.Fatal("expected unscheduled date span for empty start value")
+	}
+	if span := NewDateSpan(&Value{Date: &ValueDate{Content: 1000}}, nil); span.IsScheduled() {
+		t.Fatal("expected unscheduled date span for start value without content")
+	}
+
+	start := &Value{Date: &ValueDate{Content: 1000, IsNotEmpty: true, IsNotTime: true, HasEndDate: true, Content2: 5000, IsNotEmpty2: true}}
+	span := NewDateSpan(start, nil)
+	if 1000 != span.Start || 5000 != span.End || !span.AllDay {
+		t.Fatalf("expected start value end date to be used, got [%+v]", span)
+	}
+
+	end := &Value{Date: &ValueDate{Content: 3000, IsNotEmpty: true}}
+	span = NewDateSpan(start, end)
+	if 1000 != span.Start || 3000 != span.End || span.AllDay {
+		t.Fatalf("expected end value to be used, got [%+v]", span)
+	}
+
+	end.Date.Content = 500
+	span = NewDateSpan(start, end)
+	if 1000 != span.End {
+		t.Fatalf("expected end before start to be clamped, got [%+v]", span)
+	}
+}
+
+func TestCalendarAndTimelineLayout(t *testing.T) {
+	if !CalendarModeWeek.IsValid() || CalendarMode("day").IsValid() {
+		t.Fatal("unexpected calendar mode validation")
+	}
+	if !TimelineScaleQuarter.IsValid() || TimelineScale("hour").IsValid() {
+		t.Fatal("unexpected timeline scale validation")
+	}
+
+	calendar := &Calendar{Events: []*CalendarEvent{{ID: "a", DateSpan: &DateSpan{Start: 1, End: 2}}, {ID: "b", DateSpan: &DateSpan{}}}}
+	items := calendar.GetItems()
+	calendar.SetItems(items[1:])
+	if 1 != calendar.CountItems() || "b" != calendar.Events[0].ID {
+		t.Fatalf("unexpected calendar events after set items: %d", calendar.CountItems())
+	}
+
+	data, err := json.Marshal(&TimelineItem{ID: "a", DateSpan: &DateSpan{Start: 1, End: 2, AllDay: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"start":1,"end":2,"allDay":true`) {
+		t.Fatalf("expected date span fields to be flattened, got [%s]", data)
+	}
+}
+
+func TestDateSpanOverlapsWindow(t *testing.T) {
+	window := &DateWindow{Start: 1000, End: 2000}
+	if !window.IsValid() || (&DateWindow{Start: 2000, End: 2000}).IsValid() || (*DateWindow)(nil).IsValid() {
+		t.Fatal("unexpected date window validation")
+	}
+
+	cases := []struct {
+		span *DateSpan
+		want bool
+	}{
+		{&DateSpan{}, false},                     // 未排期
+		{&DateSpan{Start: 500, End: 999}, false}, // 区间之前结束
+		{&DateSpan{Start: 500, End: 1000}, true}, // 结束于区间开始
+		{&DateSpan{Start: 1500, End: 1500}, true},
+		{&DateSpan{Start: 500, End: 3000}, true}, // 跨越整个区间
+		{&DateSpan{Start: 2000, End: 2500}, false},
+	}
+	for _, c := range cases {
+		if got := c.span.Overlaps(window); got != c.want {
+			t.Fatalf("span [%+v] overlaps window = %v, want %v", c.span, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"github.com/88250/lute/ast"
+)
+
+// TimelineScale 描述了时间线视图的时间刻度。
+type TimelineScale string
+
+const (
+	TimelineScaleDay     TimelineScale = "day"     // 日
+	TimelineScaleWeek    TimelineScale = "week"    // 周
+	TimelineScaleMonth   TimelineScale = "month"   // 月
+	TimelineScaleQuarter TimelineScale = "quarter" // 季度
+	TimelineScaleYear    TimelineScale = "year"    // 年
+)
+
+// IsValid 判断时间线视图的时间刻度是否合法。
+func (scale TimelineScale) IsValid() bool {
+	return TimelineScaleDay == scale || TimelineScaleWeek == scale || TimelineScaleMonth == scale ||
+		TimelineScaleQuarter == scale || TimelineScaleYear == scale
+}
+
+// LayoutTimeline 描述了时间线（甘特图）布局的结构。
+type LayoutTimeline struct {
+	*BaseLayout
+
+	StartKeyID       string        `json:"startKeyID"`         // 开始日期字段 ID
+	EndKeyID         string        `json:"endKeyID,omitempty"` // 结束日期字段 ID，为空时使用开始日期字段自身的结束日期
+	Scale            TimelineScale `json:"scale"`              // 时间刻度
+	DisplayFieldName bool          `json:"displayFieldName"`   // 是否显示字段名称
+
+	Fields []*ViewTimelineField `json:"fields"` // 字段
+}
+
+func NewLayoutTimeline() *LayoutTimeline {
+	return &LayoutTimeline{
+		BaseLayout: &BaseLayout{
+			Spec:     0,
+			ID:       ast.NewNodeID(),
+			ShowIcon: true,
+		},
+		Scale: TimelineScaleWeek,
+	}
+}
+
+// ViewTimelineField 描述了时间线字段的结构。
+type ViewTimelineField struct {
+	*BaseField
+}
+
+// Timeline 描述了时间线视图实例的结构。
+type Timeline struct {
+	*BaseInstance
+
+	StartKeyID       string           `json:"startKeyID"`       // 开始日期字段 ID
+	EndKeyID         string           `json:"endKeyID"`         // 结束日期字段 ID
+	Scale            TimelineScale    `json:"scale"`            // 时间刻度
+	DisplayFieldName bool             `json:"displayFieldName"` // 是否显示字段名称
+	Fields           []*TimelineField `json:"fields"`           // 条目字段
+	Items            []*TimelineItem  `json:"items"`            // 条目
+	ItemCount        int              `json:"itemCount"`        // 总条目数（指定可见区间时为区间内的条目数）
+	Window           *DateWindow      `json:"window,omitempty"` // 可见日期区间，为空时不按日期过滤
+}
+
+// TimelineItem 描述了时间线实例条目的结构。
+type TimelineItem struct {
+	*DateSpan
+
+	ID     string                `json:"id"`     // 条目 ID
+	Values []*TimelineFieldValue `json:"values"` // 条目字段值
+}
+
+// TimelineField 描述了时间线实例字段的结构。
+type TimelineField struct {
+	*BaseInstanceField
+}
+
+// TimelineFieldValue 描述了条目字段实例值的结构。
+type TimelineFieldValue struct {
+	*BaseValue
+}
+
+func (item *TimelineItem) GetID() string {
+	return item.ID
+}
+
+func (item *TimelineItem) GetBlockValue() (ret *Value) {
+	for _, v := range item.Values {
+		if KeyTypeBlock == v.ValueType {
+			ret = v.Value
+			break
+		}
+	}
+	return
+}
+
+func (item *TimelineItem) GetValues() (ret []*Value) {
+	ret = []*Value{}
+	for _, v := range item.Values {
+		ret = append(ret, v.Value)
+	}
+	return
+}
+
+func (item *TimelineItem) GetValue(keyID string) (ret *Value) {
+	for _, value := range item.Values {
+		if nil != value.Value && keyID == value.Value.KeyID {
+			ret = value.Value
+			break
+		}
+	}
+	return
+}
+
+func (timeline *Timeline) GetItems() (ret []Item) {
+	ret = []Item{}
+	for _, item := range timeline.Items {
+		ret = append(ret, item)
+	}
+	return
+}
+
+func (timeline *Timeline) SetItems(items []Item) {
+	timeline.Items = []*TimelineItem{}
+	for _, item := range items {
+		timeline.Items = append(timeline.Items, item.(*TimelineItem))
+	}
+}
+
+func (timeline *Timeline) CountItems() int {
+	return len(timeline.Items)
+}
+
+func (timeline *Timeline) GetFields() (ret []Field) {
+	ret = []Field{}
+	for _, field := range timeline.Fields {
+		ret = append(ret, field)
+	}
+	return ret
+}
+
+func (timeline *Timeline) GetField(id string) (ret Field, fieldIndex int) {
+	for i, field := range timeline.Fields {
+		if field.ID == id {
+			return field, i
+		}
+	}
+	return nil, -1
+}
+
+func (timeline *Timeline) GetValue(itemID, keyID string) (ret *Value) {
+	for _, item := range timeline.Items {
+		if item.ID == itemID {
+			return item.GetValue(keyID)
+		}
+	}
+	return nil
+}
+
+func (timeline *Timeline) GetType() LayoutType {
+	return LayoutTypeTimeline
+}
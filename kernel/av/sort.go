@@ -404,6 +404,23 @@ func (value *Value) Compare(other *Value, optionSort map[string]int) int {
 			}
 			return 1
 		}
+	case KeyTypeFormula:
+		if nil != value.Formula && nil != other.Formula {
+			// 按计算结果类型比较，结果类型不同（比如公式修改过）时按文本比较
+			v1, v2 := value.GetFormulaResult(), other.GetFormulaResult()
+			if v1.Type == v2.Type {
+				return v1.Compare(v2, optionSort)
+			}
+
+			s1, s2 := value.String(false), other.String(false)
+			if 0 == strings.Compare(s1, s2) {
+				return 0
+			}
+			if util.EmojiPinYinCompare(s1, s2) {
+				return -1
+			}
+			return 1
+		}
 	case KeyTypeCheckbox:
 		if nil != value.Checkbox && nil != other.Checkbox {
 			if value.Checkbox.Checked && !other.Checkbox.Checked {
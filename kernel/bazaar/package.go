@@ -56,6 +56,8 @@ type Package struct {
 	Funding           *Funding      `json:"funding"`
 	Keywords          []string      `json:"keywords"`
 
+	Permissions *PluginPermissions `json:"permissions,omitempty"` // Plugin：plugin.json 中声明的内核插件权限
+
 	PreferredFunding string `json:"preferredFunding"`
 	PreferredName    string `json:"preferredName"`
 	PreferredDesc    string `json:"preferredDesc"`
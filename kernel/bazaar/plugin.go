@@ -20,6 +20,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -117,3 +119,130 @@ func IsTargetSupported(platforms []string, target string) bool {
 	}
 	return false
 }
+
+// PluginPermissionAll 表示权限声明中的“全部”。
+const PluginPermissionAll = "*"
+
+// PluginPermissions 描述内核插件在 plugin.json 中声明的权限清单。
+type PluginPermissions struct {
+	Readonly bool     `json:"readonly"` // 是否仅以只读身份调用内核 API
+	API      []string `json:"api"`      // 可调用的内核 API 分组，如 "block"、"filetree"，"*" 表示全部
+	Network  bool     `json:"network"`  // 是否可以通过 siyuan.server 对外提供 HTTP、WebSocket 和 SSE 服务
+	Storage  []string `json:"storage"`  // 可访问的存储路径前缀（相对于插件存储目录），"*" 表示全部
+	MCP      bool     `json:"mcp"`      // 是否可以注册 MCP 工具
+}
+
+// FullPluginPermissions 返回未声明权限清单的插件所拥有的全部权限，用于兼容旧插件。
+func FullPluginPermissions() *PluginPermissions {
+	return &PluginPermissions{
+		API:     []string{PluginPermissionAll},
+		Network: true,
+		Storage: []string{PluginPermissionAll},
+		MCP:     true,
+	}
+}
+
+// AllowAPIGroup 判断是否允许调用指定分组的内核 API。
+func (p *PluginPermissions) AllowAPIGroup(group string) bool {
+	for _, g := range p.API {
+		if PluginPermissionAll == g || ("" != group && group == g) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowStoragePath 判断是否允许访问存储路径，path 为相对于插件存储目录的斜杠分隔路径。
+func (p *PluginPermissions) AllowStoragePath(path string) bool {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	for _, prefix := range p.Storage {
+		if PluginPermissionAll == prefix {
+			return true
+		}
+
+		prefix = strings.Trim(filepath.ToSlash(prefix), "/")
+		if "" == prefix {
+			continue
+		}
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers 判断当前权限是否包含 other 中的全部权限，用于判断已批准的权限是否足以覆盖插件声明的权限。
+func (p *PluginPermissions) Covers(other *PluginPermissions) bool {
+	if nil == other {
+		return true
+	}
+
+	if p.Readonly && !other.Readonly {
+		return false
+	}
+	if (other.Network && !p.Network) || (other.MCP && !p.MCP) {
+		return false
+	}
+	for _, group := range other.API {
+		if PluginPermissionAll == group {
+			if !slices.Contains(p.API, PluginPermissionAll) {
+				return false
+			}
+			continue
+		}
+		if !p.AllowAPIGroup(group) {
+			return false
+		}
+	}
+	for _, prefix := range other.Storage {
+		if PluginPermissionAll == prefix {
+			if !slices.Contains(p.Storage, PluginPermissionAll) {
+				return false
+			}
+			continue
+		}
+		if !p.AllowStoragePath(prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersect 返回同时被当前权限和 other 允许的权限，用于从插件声明的权限中只取出用户已经批准的部分。
+func (p *PluginPermissions) Intersect(other *PluginPermissions) *PluginPermissions {
+	return &PluginPermissions{
+		Readonly: p.Readonly || other.Readonly,
+		API:      intersectPluginPermissionItems(p.API, other.API, p.AllowAPIGroup, other.AllowAPIGroup),
+		Network:  p.Network && other.Network,
+		Storage:  intersectPluginPermissionItems(p.Storage, other.Storage, p.AllowStoragePath, other.AllowStoragePath),
+		MCP:      p.MCP && other.MCP,
+	}
+}
+
+func intersectPluginPermissionItems(a, b []string, allowA, allowB func(string) bool) (ret []string) {
+	ret = []string{}
+	if slices.Contains(a, PluginPermissionAll) && slices.Contains(b, PluginPermissionAll) {
+		return append(ret, PluginPermissionAll)
+	}
+	for _, item := range a {
+		if PluginPermissionAll != item && allowB(item) && !slices.Contains(ret, item) {
+			ret = append(ret, item)
+		}
+	}
+	for _, item := range b {
+		if PluginPermissionAll != item && allowA(item) && !slices.Contains(ret, item) {
+			ret = append(ret, item)
+		}
+	}
+	return
+}
+
+// ParseInstalledPluginPermissions 解析已安装插件声明的权限清单，未声明时返回 nil。
+func ParseInstalledPluginPermissions(name string) (ret *PluginPermissions) {
+	plugin, err := ParsePackageJSON(filepath.Join(util.DataDir, "plugins", name, "plugin.json"))
+	if nil != err || nil == plugin {
+		return
+	}
+	ret = plugin.Permissions
+	return
+}
@@ -0,0 +1,89 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/siyuan-note/siyuan/kernel/model"
+
+	"github.com/spf13/cobra"
+)
+
+var aiCmd = &cobra.Command{
+	Use:   "ai",
+	Short: "Inspect AI features",
+}
+
+var aiUsageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show AI token usage and cost for a month",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		month, _ := cmd.Flags().GetString("month")
+		groupBy, _ := cmd.Flags().GetString("by")
+		report, err := model.GetAIUsageReport(month, groupBy)
+		if err != nil {
+			return err
+		}
+
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(data))
+		default:
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tIMAGES\tCOST (%s)\n", strings.ToUpper(report.GroupBy), report.Currency)
+			for _, group := range append(report.Groups, report.Total) {
+				key := group.Key
+				if group == report.Total {
+					key = "TOTAL " + report.Month
+				} else if key == "" {
+					key = "-"
+				}
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.4f\n", key, group.Requests, group.PromptTokens, group.CompletionTokens, group.Images, group.Cost)
+			}
+			w.Flush()
+
+			if 0 < len(report.Budgets) {
+				fmt.Println()
+				w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "BUDGET MODEL\tACTION\tSPENT\tAMOUNT\tEXCEEDED")
+				for _, budget := range report.Budgets {
+					scope := budget.Model
+					if scope == "" {
+						scope = "*"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%.4f\t%.4f\t%v\n", scope, budget.Action, budget.Spent, budget.Amount, budget.Exceeded)
+				}
+				w.Flush()
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	aiUsageCmd.Flags().String("month", "", "month to report, like 2026-01 (default current month)")
+	aiUsageCmd.Flags().String("by", "model", "group by: day, feature, provider, model or session")
+
+	rootCmd.AddCommand(aiCmd)
+	aiCmd.AddCommand(aiUsageCmd)
+}
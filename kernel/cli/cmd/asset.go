@@ -141,6 +141,77 @@ var assetCleanCmd = &cobra.Command{
 	},
 }
 
+var assetDedupCmd = &cobra.Command{
+	Use:   "dedup",
+	Short: "Merge assets with identical content and rewrite links to them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := model.DeduplicateAssets(dryRun)
+		if err != nil {
+			return err
+		}
+
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(data))
+		default:
+			if len(result.Groups) == 0 {
+				fmt.Println("No duplicated assets found.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEEP\tDUPLICATE")
+			for _, group := range result.Groups {
+				for _, dup := range group.Duplicates {
+					fmt.Fprintf(w, "%s\t%s\n", group.Keep, dup)
+				}
+			}
+			w.Flush()
+			if result.DryRun {
+				fmt.Printf("\n[dry-run] Would remove %d duplicated asset(s), saving %d bytes\n", result.RemovedCount, result.SavedSize)
+			} else {
+				fmt.Printf("\nRemoved %d duplicated asset(s), saved %d bytes, updated %d document(s) and %d database(s)\n",
+					result.RemovedCount, result.SavedSize, result.UpdatedDocs, result.UpdatedAVs)
+			}
+		}
+		return nil
+	},
+}
+
+var assetProcessImagesCmd = &cobra.Command{
+	Use:   "process-images",
+	Short: "Strip metadata, downscale and re-encode existing images using the image pipeline settings",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := model.ProcessAssetImages(dryRun)
+		if err != nil {
+			return err
+		}
+
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(data))
+		default:
+			if len(result.Images) == 0 {
+				fmt.Println("No images need processing.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PATH\tNEW PATH\tSIZE\tNEW SIZE")
+			for _, img := range result.Images {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", img.Path, img.NewPath, img.OriginalSize, img.Size)
+			}
+			w.Flush()
+			if result.DryRun {
+				fmt.Printf("\n[dry-run] Would process %d image(s), saving %d bytes\n", len(result.Images), result.SavedSize)
+			} else {
+				fmt.Printf("\nProcessed %d image(s), saved %d bytes\n", len(result.Images), result.SavedSize)
+			}
+		}
+		return nil
+	},
+}
+
 var assetStatCmd = &cobra.Command{
 	Use:   "stat --path <path>",
 	Short: "Show asset file info",
@@ -185,4 +256,6 @@ func init() {
 	assetCmd.AddCommand(assetUnusedCmd)
 	assetCmd.AddCommand(assetCleanCmd)
 	assetCmd.AddCommand(assetStatCmd)
+	assetCmd.AddCommand(assetDedupCmd)
+	assetCmd.AddCommand(assetProcessImagesCmd)
 }
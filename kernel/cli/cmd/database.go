@@ -483,6 +483,11 @@ func formatValue(v *av.Value) string {
 			return formatValue(v.Rollup.Contents[0])
 		}
 		return ""
+	case av.KeyTypeFormula:
+		if v.Formula == nil || v.Formula.Error != "" {
+			return ""
+		}
+		return formatValue(v.GetFormulaResult())
 	default:
 		return ""
 	}
@@ -0,0 +1,222 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/siyuan-note/siyuan/kernel/model"
+
+	"github.com/spf13/cobra"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Analyze the document reference graph",
+}
+
+var graphPathCmd = &cobra.Command{
+	Use:   "path --from <id> --to <id>",
+	Short: "Find the shortest path between the documents of two blocks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		if from == "" || to == "" {
+			return fmt.Errorf("--from and --to are required")
+		}
+
+		path, err := model.GraphShortestPath(from, to, graphAnalysisOptions(cmd))
+		if err != nil {
+			return err
+		}
+
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(path, "", "  ")
+			fmt.Println(string(data))
+		default:
+			if !path.Found {
+				fmt.Println("(no path)")
+				return nil
+			}
+			printGraphNodeTable(path.Nodes)
+			fmt.Printf("\n%d hop(s)\n", path.Hops)
+		}
+		return nil
+	},
+}
+
+var graphRankCmd = &cobra.Command{
+	Use:   "rank",
+	Short: "Rank documents by centrality",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		metric, _ := cmd.Flags().GetString("metric")
+		if metric != model.GraphCentralityPageRank && metric != model.GraphCentralityDegree {
+			return fmt.Errorf("--metric must be pagerank or degree")
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		printGraphRankedNodes(model.GraphCentrality(metric, limit, graphAnalysisOptions(cmd)))
+		return nil
+	},
+}
+
+var graphBridgesCmd = &cobra.Command{
+	Use:   "bridges",
+	Short: "List bridging documents that connect otherwise separate clusters",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		printGraphRankedNodes(model.GraphBridgingDocs(limit, graphAnalysisOptions(cmd)))
+		return nil
+	},
+}
+
+var graphCommunitiesCmd = &cobra.Command{
+	Use:   "communities",
+	Short: "Detect clusters of closely linked documents",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		minSize, _ := cmd.Flags().GetInt("min-size")
+		limit, _ := cmd.Flags().GetInt("limit")
+		communities := model.GraphCommunities(minSize, limit, graphAnalysisOptions(cmd))
+
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(communities, "", "  ")
+			fmt.Println(string(data))
+		default:
+			if len(communities) == 0 {
+				fmt.Println("(none)")
+				return nil
+			}
+			for i, community := range communities {
+				if 0 < i {
+					fmt.Println()
+				}
+				fmt.Printf("Community %d (%d documents)\n", community.ID, community.Size)
+				printGraphNodeTable(community.Nodes)
+			}
+		}
+		return nil
+	},
+}
+
+var graphOrphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "List documents that neither reference nor are referenced by other documents",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		printGraphNodes(model.GraphOrphanDocs(graphAnalysisOptions(cmd)))
+		return nil
+	},
+}
+
+var graphDeadEndsCmd = &cobra.Command{
+	Use:   "deadends",
+	Short: "List referenced documents that do not reference any other document",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		printGraphNodes(model.GraphDeadEndDocs(graphAnalysisOptions(cmd)))
+		return nil
+	},
+}
+
+func graphAnalysisOptions(cmd *cobra.Command) *model.GraphAnalysisOptions {
+	opts := &model.GraphAnalysisOptions{Tag: model.Conf.Graph.Global.Tag}
+	opts.Box, _ = cmd.Flags().GetString("notebook")
+	if cmd.Flags().Changed("tag") {
+		opts.Tag, _ = cmd.Flags().GetBool("tag")
+	}
+	return opts
+}
+
+func printGraphNodes(nodes []*model.GraphAnalysisNode) {
+	switch outputFormat {
+	case "json":
+		data, _ := json.MarshalIndent(nodes, "", "  ")
+		fmt.Println(string(data))
+	default:
+		printGraphNodeTable(nodes)
+		fmt.Printf("\n%d document(s)\n", len(nodes))
+	}
+}
+
+func printGraphNodeTable(nodes []*model.GraphAnalysisNode) {
+	if len(nodes) == 0 {
+		fmt.Println("(none)")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tHPATH")
+	for _, node := range nodes {
+		hPath := node.HPath
+		if hPath == "" {
+			hPath = "#" + node.Label + "#"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", node.ID, node.Type, truncate(hPath, 60))
+	}
+	w.Flush()
+}
+
+func printGraphRankedNodes(nodes []*model.GraphRankedNode) {
+	switch outputFormat {
+	case "json":
+		data, _ := json.MarshalIndent(nodes, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(nodes) == 0 {
+		fmt.Println("(none)")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCORE\tIN\tOUT\tHPATH")
+	for _, node := range nodes {
+		fmt.Fprintf(w, "%s\t%.4f\t%d\t%d\t%s\n", node.ID, node.Score, node.InDegree, node.OutDegree, truncate(node.HPath, 60))
+	}
+	w.Flush()
+}
+
+func init() {
+	for _, c := range []*cobra.Command{graphPathCmd, graphRankCmd, graphBridgesCmd, graphCommunitiesCmd, graphOrphansCmd, graphDeadEndsCmd} {
+		c.Flags().String("notebook", "", "limit the analysis to one notebook ID")
+		c.Flags().Bool("tag", false, "treat tags as graph nodes (default: the global graph tag setting)")
+	}
+
+	graphPathCmd.Flags().String("from", "", "source block ID")
+	graphPathCmd.Flags().String("to", "", "target block ID")
+
+	graphRankCmd.Flags().String("metric", model.GraphCentralityPageRank, "centrality metric: pagerank or degree")
+	graphRankCmd.Flags().Int("limit", 32, "max documents to list, 0 for all")
+
+	graphBridgesCmd.Flags().Int("limit", 32, "max documents to list, 0 for all")
+
+	graphCommunitiesCmd.Flags().Int("min-size", 2, "minimum documents per community")
+	graphCommunitiesCmd.Flags().Int("limit", 0, "max communities to list, 0 for all")
+
+	rootCmd.AddCommand(graphCmd)
+	graphCmd.AddCommand(graphPathCmd)
+	graphCmd.AddCommand(graphRankCmd)
+	graphCmd.AddCommand(graphBridgesCmd)
+	graphCmd.AddCommand(graphCommunitiesCmd)
+	graphCmd.AddCommand(graphOrphansCmd)
+	graphCmd.AddCommand(graphDeadEndsCmd)
+}
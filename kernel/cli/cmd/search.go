@@ -29,7 +29,7 @@ import (
 
 var searchCmd = &cobra.Command{
 	Use:   "search <query>",
-	Short: "Full-text search (blocks, semantic, or asset file contents with --asset)",
+	Short: "Full-text search (blocks, semantic, hybrid, or asset file contents with --asset)",
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		query := args[0]
@@ -97,6 +97,9 @@ func runAssetSearch(cmd *cobra.Command, query string, method, orderBy, page, pag
 	extFlags, _ := cmd.Flags().GetStringArray("ext")
 	types := stringSliceToMap(extFlags)
 
+	if method == 5 {
+		method = 0
+	}
 	assetContents, matchedAssetCount, pageCount, err := model.FullTextSearchAssetContent(query, types, method, orderBy, page, pageSize)
 	if err != nil {
 		return err
@@ -147,10 +150,10 @@ func printAssetSearchResult(assetContents []*model.AssetContent, matchedAssetCou
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tEXT\tNAME\tSIZE\tPATH\tCONTENT")
+	fmt.Fprintln(w, "ID\tEXT\tNAME\tSIZE\tPATH\tLOCATION\tCONTENT")
 	for _, a := range assetContents {
 		content := truncate(a.Content, 60)
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.Ext, a.Name, a.HSize, a.Path, content)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.Ext, a.Name, a.HSize, a.Path, a.Locator, content)
 	}
 	w.Flush()
 
@@ -212,7 +215,7 @@ func init() {
 	searchCmd.Flags().StringArray("path", nil, "path prefix filter (repeatable)")
 	searchCmd.Flags().StringArrayP("type", "t", nil, "block type filter, repeatable (document heading paragraph list listItem codeBlock mathBlock table blockquote superBlock htmlBlock embedBlock databaseBlock audioBlock videoBlock iframeBlock widgetBlock callout)")
 	searchCmd.Flags().StringArray("subtype", nil, "block subtype filter, repeatable (o u t)")
-	searchCmd.Flags().IntP("method", "m", 0, "search method: 0=keyword 1=query-syntax 2=sql 3=regex 4=semantic (asset mode ignores 4=semantic; uses 0-3 with same meanings)")
+	searchCmd.Flags().IntP("method", "m", 0, "search method: 0=keyword 1=query-syntax 2=sql 3=regex 4=semantic 5=hybrid (keyword + semantic) (asset mode supports 0-4 with same meanings, ignores 5)")
 	searchCmd.Flags().IntP("order-by", "o", 0, "order — blocks: 0=type 1=created-asc 2=created-desc 3=updated-asc 4=updated-desc 5=content 6=relevance-asc 7=relevance-desc; asset: 0=relevance-desc 1=relevance-asc 2=updated-asc 3=updated-desc")
 	searchCmd.Flags().IntP("page", "p", 1, "page number")
 	searchCmd.Flags().IntP("page-size", "s", 32, "results per page")
@@ -18,6 +18,7 @@ package cmd
 
 import (
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/agent"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/job"
 	"github.com/siyuan-note/siyuan/kernel/model"
@@ -76,6 +77,9 @@ var serveCmd = &cobra.Command{
 		model.InitBoxes()
 		model.LoadFlashcards()
 		util.LoadAssetsTexts()
+		model.LoadUndoLog()
+		model.InitWebhooks()
+		agent.InitAutomations()
 
 		util.SetBooted()
 		util.PushClearAllMsg()
@@ -98,6 +98,105 @@ var tagRenameCmd = &cobra.Command{
 	},
 }
 
+var tagMetaCmd = &cobra.Command{
+	Use:   "meta --label <label> [--color <color>] [--icon <icon>] [--description <text>] [--alias <alias>]...",
+	Short: "Show or set a tag's color, icon, description and aliases",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		label, _ := cmd.Flags().GetString("label")
+		if label == "" {
+			return fmt.Errorf("--label is required")
+		}
+
+		meta := model.GetTagMetas()[label]
+		if meta == nil {
+			meta = &model.TagMeta{}
+		}
+		changed := false
+		if cmd.Flags().Changed("color") {
+			meta.Color, _ = cmd.Flags().GetString("color")
+			changed = true
+		}
+		if cmd.Flags().Changed("icon") {
+			meta.Icon, _ = cmd.Flags().GetString("icon")
+			changed = true
+		}
+		if cmd.Flags().Changed("description") {
+			meta.Description, _ = cmd.Flags().GetString("description")
+			changed = true
+		}
+		if cmd.Flags().Changed("alias") {
+			meta.Aliases, _ = cmd.Flags().GetStringSlice("alias")
+			changed = true
+		}
+
+		if !changed {
+			data, _ := json.MarshalIndent(meta, "", "  ")
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if dryRun {
+			fmt.Printf("[dry-run] Would set metadata of tag \"%s\"\n", label)
+			return nil
+		}
+
+		if err := model.SetTagMeta(label, meta); err != nil {
+			return err
+		}
+		model.AppendPushReloadTagEntry()
+		return nil
+	},
+}
+
+var tagMergeCmd = &cobra.Command{
+	Use:   "merge --label <label>[,<label>...] --into <target>",
+	Short: "Merge tags into one tag across all notebooks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		labels, _ := cmd.Flags().GetStringSlice("label")
+		target, _ := cmd.Flags().GetString("into")
+		if len(labels) == 0 {
+			return fmt.Errorf("--label is required")
+		}
+		if target == "" {
+			return fmt.Errorf("--into is required")
+		}
+
+		result, err := model.MergeTags(labels, target, dryRun)
+		if err != nil {
+			return err
+		}
+		if !dryRun {
+			model.AppendPushReloadTagEntry()
+		}
+
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(data))
+		default:
+			printTagMergeResult(result)
+		}
+		return nil
+	},
+}
+
+func printTagMergeResult(result *model.TagMergeResult) {
+	prefix := ""
+	if result.DryRun {
+		prefix = "[dry-run] Would rewrite"
+	} else {
+		prefix = "Rewrote"
+	}
+	fmt.Printf("%s %d occurrence(s) in %d document(s) into \"%s\"\n", prefix, result.Occurrences, len(result.Docs), result.Target)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tOCCURRENCES\tPATH")
+	for _, doc := range result.Docs {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", doc.ID, doc.Occurrences, doc.HPath)
+	}
+	w.Flush()
+}
+
 func printTagTable(tags []string) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintln(w, "LABEL")
@@ -112,9 +211,18 @@ func init() {
 	tagRemoveCmd.Flags().String("label", "", "tag label to remove")
 	tagRenameCmd.Flags().String("old", "", "current tag label")
 	tagRenameCmd.Flags().String("new", "", "new tag label")
+	tagMetaCmd.Flags().String("label", "", "tag label")
+	tagMetaCmd.Flags().String("color", "", "tag color")
+	tagMetaCmd.Flags().String("icon", "", "tag icon")
+	tagMetaCmd.Flags().String("description", "", "tag description")
+	tagMetaCmd.Flags().StringSlice("alias", nil, "tag aliases (repeatable, replaces existing aliases)")
+	tagMergeCmd.Flags().StringSlice("label", nil, "tag labels to merge (comma separated or repeatable)")
+	tagMergeCmd.Flags().String("into", "", "target tag label")
 
 	rootCmd.AddCommand(tagCmd)
 	tagCmd.AddCommand(tagListCmd)
 	tagCmd.AddCommand(tagRemoveCmd)
 	tagCmd.AddCommand(tagRenameCmd)
+	tagCmd.AddCommand(tagMetaCmd)
+	tagCmd.AddCommand(tagMergeCmd)
 }
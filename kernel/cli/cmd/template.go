@@ -105,8 +105,45 @@ var templateRemoveCmd = &cobra.Command{
 	},
 }
 
+var templateParamsCmd = &cobra.Command{
+	Use:   "params --path <path>",
+	Short: "Show the input parameters a template declares",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, _ := cmd.Flags().GetString("path")
+		abs, err := resolveTemplateAbs(p)
+		if err != nil {
+			return err
+		}
+		params, err := model.GetTemplateParams(abs)
+		if err != nil {
+			return err
+		}
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(params, "", "  ")
+			fmt.Println(string(data))
+		default:
+			if len(params) == 0 {
+				fmt.Println("No parameters declared.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tTYPE\tREQUIRED\tDEFAULT\tOPTIONS")
+			for _, param := range params {
+				options := strings.Join(param.Options, ",")
+				if param.Type == model.TemplateParamTypeRow {
+					options = "av=" + param.AV
+				}
+				fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n", param.Name, param.Type, param.Required, param.Default, options)
+			}
+			w.Flush()
+		}
+		return nil
+	},
+}
+
 var templateRenderCmd = &cobra.Command{
-	Use:   "render --path <path> --id <id>",
+	Use:   "render --path <path> --id <id> [--param <name>=<value>]...",
 	Short: "Render a template against a block (preview)",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		p, _ := cmd.Flags().GetString("path")
@@ -118,7 +155,11 @@ var templateRenderCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		_, dom, err := model.RenderTemplate(abs, id, true)
+		params, err := parseTemplateParamFlags(cmd)
+		if err != nil {
+			return err
+		}
+		_, dom, err := model.RenderTemplate(abs, id, params, true)
 		if err != nil {
 			return err
 		}
@@ -185,6 +226,20 @@ var templateCreateCmd = &cobra.Command{
 	},
 }
 
+// parseTemplateParamFlags 把 --param name=value 解析为模板输入参数的值。
+func parseTemplateParamFlags(cmd *cobra.Command) (map[string]string, error) {
+	pairs, _ := cmd.Flags().GetStringArray("param")
+	params := map[string]string{}
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --param %q, expected name=value", pair)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
 // resolveTemplateAbs 把模板路径解析为 data/templates 下的绝对路径，拒绝越界。
 // 接受绝对路径或相对 data/templates 的相对路径。
 func resolveTemplateAbs(p string) (string, error) {
@@ -209,6 +264,8 @@ func init() {
 	templateRemoveCmd.Flags().String("path", "", "template path (absolute or relative to data/templates)")
 	templateRenderCmd.Flags().String("path", "", "template path (absolute or relative to data/templates)")
 	templateRenderCmd.Flags().String("id", "", "block ID to render against")
+	templateRenderCmd.Flags().StringArray("param", nil, "template parameter value as name=value (repeatable)")
+	templateParamsCmd.Flags().String("path", "", "template path (absolute or relative to data/templates)")
 	templateSaveAsCmd.Flags().String("id", "", "source document block ID")
 	templateSaveAsCmd.Flags().String("name", "", "template name without extension")
 	templateSaveAsCmd.Flags().Bool("overwrite", false, "overwrite if exists")
@@ -221,6 +278,7 @@ func init() {
 	templateCmd.AddCommand(templateSearchCmd)
 	templateCmd.AddCommand(templateGetCmd)
 	templateCmd.AddCommand(templateRemoveCmd)
+	templateCmd.AddCommand(templateParamsCmd)
 	templateCmd.AddCommand(templateRenderCmd)
 	templateCmd.AddCommand(templateSaveAsCmd)
 	templateCmd.AddCommand(templateCreateCmd)
@@ -0,0 +1,312 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/model"
+
+	"github.com/spf13/cobra"
+)
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Manage outbound webhooks for document and block changes",
+}
+
+var webhookListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List webhooks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		webhooks := model.ListWebhooks()
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(webhooks, "", "  ")
+			fmt.Println(string(data))
+		default:
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tURL\tENABLED\tEVENTS\tNOTEBOOKS\tPATHS\tTYPES\tATTRS")
+			for _, webhook := range webhooks {
+				var attrs []string
+				for k, v := range webhook.Attrs {
+					attrs = append(attrs, k+"="+v)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%s\t%s\t%s\t%s\n", webhook.ID, webhook.Name, webhook.URL, webhook.Enabled,
+					strings.Join(webhook.Events, ","), strings.Join(webhook.Boxes, ","), strings.Join(webhook.Paths, ","),
+					strings.Join(webhook.BlockTypes, ","), strings.Join(attrs, ","))
+			}
+			w.Flush()
+		}
+		return nil
+	},
+}
+
+// webhookCreateCmd 创建 Webhook，签名密钥仅在此时输出一次。
+var webhookCreateCmd = &cobra.Command{
+	Use:   "create --url <url>",
+	Short: "Create a webhook",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		webhook := &conf.Webhook{Enabled: true}
+		applyWebhookFlags(cmd, webhook)
+		if webhook.URL == "" {
+			return fmt.Errorf("--url is required")
+		}
+
+		if dryRun {
+			fmt.Printf("[dry-run] Would create webhook for %s\n", webhook.URL)
+			return nil
+		}
+
+		created, err := model.CreateWebhook(webhook)
+		if err != nil {
+			return err
+		}
+
+		switch outputFormat {
+		case "json":
+			data, _ := json.MarshalIndent(created, "", "  ")
+			fmt.Println(string(data))
+		default:
+			fmt.Printf("%s\t%s\n", created.ID, created.Secret)
+		}
+		return nil
+	},
+}
+
+// webhookUpdateCmd 只更新显式指定的选项，其它配置保持不变。
+var webhookUpdateCmd = &cobra.Command{
+	Use:   "update --id <id>",
+	Short: "Update a webhook",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, _ := cmd.Flags().GetString("id")
+		if id == "" {
+			return fmt.Errorf("--id is required")
+		}
+
+		var webhook *conf.Webhook
+		for _, w := range model.ListWebhooks() {
+			if w.ID == id {
+				webhook = w
+				break
+			}
+		}
+		if webhook == nil {
+			return model.ErrWebhookNotFound
+		}
+		applyWebhookFlags(cmd, webhook)
+
+		if dryRun {
+			fmt.Printf("[dry-run] Would update webhook %s\n", id)
+			return nil
+		}
+
+		if _, err := model.UpdateWebhook(webhook); err != nil {
+			return err
+		}
+		fmt.Println(id)
+		return nil
+	},
+}
+
+var webhookRemoveCmd = &cobra.Command{
+	Use:   "remove --id <id>",
+	Short: "Remove a webhook",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, _ := cmd.Flags().GetString("id")
+		if id == "" {
+			return fmt.Errorf("--id is required")
+		}
+
+		if dryRun {
+			fmt.Printf("[dry-run] Would remove webhook %s\n", id)
+			return nil
+		}
+
+		model.LoadWebhookDeliveries()
+		if err := model.RemoveWebhook(id); err != nil {
+			return err
+		}
+		model.SaveWebhookDeliveries()
+		fmt.Println(id)
+		return nil
+	},
+}
+
+var webhookTestCmd = &cobra.Command{
+	Use:   "test --id <id>",
+	Short: "Send a ping event to a webhook",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, _ := cmd.Flags().GetString("id")
+		if id == "" {
+			return fmt.Errorf("--id is required")
+		}
+
+		if dryRun {
+			fmt.Printf("[dry-run] Would send a ping event to webhook %s\n", id)
+			return nil
+		}
+
+		model.LoadWebhookDeliveries()
+		delivery, err := model.TestWebhook(id)
+		if err != nil {
+			return err
+		}
+		model.SaveWebhookDeliveries()
+		printWebhookDeliveries([]*model.WebhookDelivery{delivery})
+		return nil
+	},
+}
+
+var webhookDeliveriesCmd = &cobra.Command{
+	Use:   "deliveries",
+	Short: "List recent webhook deliveries",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, _ := cmd.Flags().GetString("id")
+		status, _ := cmd.Flags().GetString("status")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		model.LoadWebhookDeliveries()
+		printWebhookDeliveries(model.GetWebhookDeliveries(id, status, limit))
+		return nil
+	},
+}
+
+// webhookRedeliverCmd 重置投递记录并立即执行一次投递任务。
+var webhookRedeliverCmd = &cobra.Command{
+	Use:   "redeliver --delivery <id>",
+	Short: "Redeliver a webhook delivery",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, _ := cmd.Flags().GetString("delivery")
+		if id == "" {
+			return fmt.Errorf("--delivery is required")
+		}
+
+		if dryRun {
+			fmt.Printf("[dry-run] Would redeliver webhook delivery %s\n", id)
+			return nil
+		}
+
+		model.LoadWebhookDeliveries()
+		if err := model.RedeliverWebhook(id); err != nil {
+			return err
+		}
+		model.DeliverWebhooksJob()
+		model.SaveWebhookDeliveries()
+		for _, delivery := range model.GetWebhookDeliveries("", "", 0) {
+			if delivery.ID == id {
+				printWebhookDeliveries([]*model.WebhookDelivery{delivery})
+				break
+			}
+		}
+		return nil
+	},
+}
+
+func applyWebhookFlags(cmd *cobra.Command, webhook *conf.Webhook) {
+	flags := cmd.Flags()
+	if flags.Changed("name") {
+		webhook.Name, _ = flags.GetString("name")
+	}
+	if flags.Changed("url") {
+		webhook.URL, _ = flags.GetString("url")
+	}
+	if flags.Changed("secret") {
+		webhook.Secret, _ = flags.GetString("secret")
+	}
+	if flags.Changed("enabled") {
+		webhook.Enabled, _ = flags.GetBool("enabled")
+	}
+	if flags.Changed("event") {
+		webhook.Events, _ = flags.GetStringSlice("event")
+	}
+	if flags.Changed("notebook") {
+		webhook.Boxes, _ = flags.GetStringSlice("notebook")
+	}
+	if flags.Changed("path") {
+		webhook.Paths, _ = flags.GetStringSlice("path")
+	}
+	if flags.Changed("type") {
+		webhook.BlockTypes, _ = flags.GetStringSlice("type")
+	}
+	if flags.Changed("attr") {
+		webhook.Attrs, _ = flags.GetStringToString("attr")
+	}
+}
+
+func printWebhookDeliveries(deliveries []*model.WebhookDelivery) {
+	switch outputFormat {
+	case "json":
+		data, _ := json.MarshalIndent(deliveries, "", "  ")
+		fmt.Println(string(data))
+	default:
+		formatTime := func(millis int64) string {
+			if 0 == millis {
+				return "-"
+			}
+			return time.UnixMilli(millis).Format(time.RFC3339)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tWEBHOOK\tEVENT\tSTATUS\tATTEMPTS\tCODE\tLAST ATTEMPT\tNEXT ATTEMPT\tERROR")
+		for _, d := range deliveries {
+			next := "-"
+			if d.Status == model.WebhookDeliveryPending {
+				next = formatTime(d.NextAttempt)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n", d.ID, d.WebhookID, d.Event, d.Status, d.Attempts,
+				d.ResponseCode, formatTime(d.LastAttempt), next, d.Error)
+		}
+		w.Flush()
+	}
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{webhookCreateCmd, webhookUpdateCmd} {
+		cmd.Flags().String("name", "", "webhook name")
+		cmd.Flags().String("url", "", "delivery URL (http or https)")
+		cmd.Flags().String("secret", "", "HMAC-SHA256 signing secret (generated when empty on create)")
+		cmd.Flags().Bool("enabled", true, "whether the webhook delivers events")
+		cmd.Flags().StringSlice("event", nil, "subscribed events: "+strings.Join(conf.WebhookEvents, ", ")+" (empty = all)")
+		cmd.Flags().StringSlice("notebook", nil, "notebook IDs to filter by (empty = all)")
+		cmd.Flags().StringSlice("path", nil, "document path or human-readable path prefixes to filter by (empty = all)")
+		cmd.Flags().StringSlice("type", nil, "block types to filter by, like \"d,h,p\" (empty = all)")
+		cmd.Flags().StringToString("attr", nil, "block attributes to filter by, like \"custom-sync=true\" (empty value = attribute present)")
+	}
+	webhookUpdateCmd.Flags().String("id", "", "webhook ID")
+	webhookRemoveCmd.Flags().String("id", "", "webhook ID")
+	webhookTestCmd.Flags().String("id", "", "webhook ID")
+	webhookDeliveriesCmd.Flags().String("id", "", "webhook ID to filter by")
+	webhookDeliveriesCmd.Flags().String("status", "", "delivery status to filter by: pending, succeeded or failed")
+	webhookDeliveriesCmd.Flags().Int("limit", 50, "maximum number of deliveries to list (0 = all)")
+	webhookRedeliverCmd.Flags().String("delivery", "", "delivery ID")
+
+	rootCmd.AddCommand(webhookCmd)
+	webhookCmd.AddCommand(webhookListCmd)
+	webhookCmd.AddCommand(webhookCreateCmd)
+	webhookCmd.AddCommand(webhookUpdateCmd)
+	webhookCmd.AddCommand(webhookRemoveCmd)
+	webhookCmd.AddCommand(webhookTestCmd)
+	webhookCmd.AddCommand(webhookDeliveriesCmd)
+	webhookCmd.AddCommand(webhookRedeliverCmd)
+}
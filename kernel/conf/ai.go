@@ -20,6 +20,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -36,6 +37,7 @@ type AI struct {
 	Editing         *Editing         `json:"editing"`
 	Vision          *Vision          `json:"vision"`
 	ImageGeneration *ImageGeneration `json:"imageGeneration"`
+	Usage           *Usage           `json:"usage"`
 	Providers       []*Provider      `json:"providers"`
 }
 
@@ -48,6 +50,46 @@ type Agent struct {
 	Temperature         float64 `json:"temperature"`
 	MaxCompletionTokens int     `json:"maxCompletionTokens"`
 	MaxToolCallRounds   int     `json:"maxToolCallRounds"`
+
+	Fallbacks []*AgentFallback `json:"fallbacks"` // 模型故障转移链，按顺序尝试
+
+	SubAgentConcurrency int `json:"subAgentConcurrency"` // 一次委派中同时运行的子智能体数量上限
+}
+
+// Agent 模型故障转移的触发条件，与 Agent 请求重试的错误分类一致。
+const (
+	FallbackOnRateLimit       = "rate_limit"       // 限流（HTTP 429）
+	FallbackOnServerError     = "server_error"     // 服务端错误（HTTP 5xx）
+	FallbackOnTimeout         = "timeout"          // 建立请求或等待输出超时
+	FallbackOnNetwork         = "network"          // 网络异常
+	FallbackOnContextOverflow = "context_overflow" // 超出模型上下文长度
+)
+
+// AgentFallback 是 Agent 模型故障转移链中的一项。当前模型重试耗尽后仍失败，且错误分类命中 Conditions 时，
+// 会话切换到该模型继续。Conditions 为空时在限流、服务端错误、超时和网络异常时切换。
+type AgentFallback struct {
+	ModelID    string   `json:"modelId"`
+	Conditions []string `json:"conditions"`
+}
+
+// DefaultFallbackConditions 是未配置条件时触发故障转移的错误分类。
+var DefaultFallbackConditions = []string{FallbackOnRateLimit, FallbackOnServerError, FallbackOnTimeout, FallbackOnNetwork}
+
+func isFallbackCondition(condition string) bool {
+	switch condition {
+	case FallbackOnRateLimit, FallbackOnServerError, FallbackOnTimeout, FallbackOnNetwork, FallbackOnContextOverflow:
+		return true
+	}
+	return false
+}
+
+// Matches 判断错误分类是否触发切换到该模型。
+func (fallback *AgentFallback) Matches(category string) bool {
+	conditions := fallback.Conditions
+	if 1 > len(conditions) {
+		conditions = DefaultFallbackConditions
+	}
+	return slices.Contains(conditions, category)
 }
 
 // Editing holds behavior parameters used by the in-editor chat scenario. They
@@ -78,11 +120,54 @@ type ImageGeneration struct {
 	OutputFormat   string `json:"outputFormat"`
 }
 
+// Usage 配置 AI 用量统计的计价与月度预算。用量按模型名计价，价格调整后历史用量按新价格重新计算。
+type Usage struct {
+	Currency string        `json:"currency"` // 仅用于展示，如 USD、CNY
+	Prices   []*ModelPrice `json:"prices"`
+	Budgets  []*Budget     `json:"budgets"`
+}
+
+// ModelPrice 是某个模型的单价。Token 价格单位为每百万 token，图片按张计价。
+type ModelPrice struct {
+	Model  string  `json:"model"` // 模型名，大小写不敏感
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	Image  float64 `json:"image"`
+}
+
+// 月度预算超出后的处理方式。
+const (
+	BudgetActionWarn  = "warn"  // 提示后继续请求
+	BudgetActionBlock = "block" // 拒绝后续请求直到下个月
+)
+
+// Budget 是一项月度预算。
+type Budget struct {
+	Model  string  `json:"model"`  // 模型名，为空时统计所有模型
+	Amount float64 `json:"amount"` // 每月预算金额
+	Action string  `json:"action"` // warn 或 block
+}
+
+// Price 返回模型的单价，未配置时返回 nil。
+func (usage *Usage) Price(model string) *ModelPrice {
+	for _, price := range usage.Prices {
+		if strings.EqualFold(price.Model, model) {
+			return price
+		}
+	}
+	return nil
+}
+
+func defaultUsage() *Usage {
+	return &Usage{Currency: "USD", Prices: []*ModelPrice{}, Budgets: []*Budget{}}
+}
+
 type Embedding struct {
 	ID         string `json:"id"`
 	Enabled    bool   `json:"enabled"`
 	APIKey     string `json:"apiKey"`
 	BaseURL    string `json:"baseURL"`
+	Protocol   string `json:"protocol,omitempty"` // 服务商协议，支持 openai 和 gemini，Anthropic 没有 Embeddings API
 	Name       string `json:"name"`
 	Timeout    int    `json:"timeout"`
 	Dimensions int    `json:"dimensions"` // 输出向量维度，仅 text-embedding-3 及以上模型支持；0 表示用模型默认值（不传该参数）
@@ -107,7 +192,7 @@ type Provider struct {
 	Enabled        bool     `json:"enabled"`
 	APIKey         string   `json:"apiKey"`
 	BaseURL        string   `json:"baseURL"`
-	Protocol       string   `json:"protocol,omitempty"`
+	Protocol       string   `json:"protocol,omitempty"` // 服务商协议：openai（含各类兼容服务）、anthropic、gemini
 	RequestTimeout int      `json:"requestTimeout"`
 	Models         []*Model `json:"models"`
 }
@@ -156,6 +241,8 @@ func defaultAgent() *Agent {
 		Temperature:         1.0,
 		MaxCompletionTokens: 0,
 		MaxToolCallRounds:   64,
+		Fallbacks:           []*AgentFallback{},
+		SubAgentConcurrency: 3,
 	}
 }
 
@@ -185,6 +272,7 @@ func NewAI() *AI {
 		Editing:         defaultEditing(),
 		Vision:          defaultVision(),
 		ImageGeneration: defaultImageGeneration(),
+		Usage:           defaultUsage(),
 	}
 
 	apiKey := os.Getenv("SIYUAN_OPENAI_API_KEY")
@@ -395,7 +483,34 @@ func (ai *AI) Normalize() {
 		} else if ai.Agent.MaxRetries > 10 {
 			ai.Agent.MaxRetries = 10
 		}
+		if ai.Agent.SubAgentConcurrency < 1 {
+			ai.Agent.SubAgentConcurrency = 3
+		} else if ai.Agent.SubAgentConcurrency > 8 {
+			ai.Agent.SubAgentConcurrency = 8
+		}
 	}
+	fallbacks := make([]*AgentFallback, 0, len(ai.Agent.Fallbacks))
+	fallbackModelIDs := map[string]bool{ai.Agent.ModelID: true}
+	for _, fallback := range ai.Agent.Fallbacks {
+		if fallback == nil {
+			continue
+		}
+		fallback.ModelID = strings.TrimSpace(fallback.ModelID)
+		if fallback.ModelID == "" || fallbackModelIDs[fallback.ModelID] {
+			continue
+		}
+		fallbackModelIDs[fallback.ModelID] = true
+		conditions := make([]string, 0, len(fallback.Conditions))
+		for _, condition := range fallback.Conditions {
+			condition = strings.ToLower(strings.TrimSpace(condition))
+			if isFallbackCondition(condition) && !slices.Contains(conditions, condition) {
+				conditions = append(conditions, condition)
+			}
+		}
+		fallback.Conditions = conditions
+		fallbacks = append(fallbacks, fallback)
+	}
+	ai.Agent.Fallbacks = fallbacks
 	if ai.Editing == nil {
 		ai.Editing = defaultEditing()
 	} else {
@@ -456,21 +571,54 @@ func (ai *AI) Normalize() {
 	if ai.ImageGeneration.OutputFormat != "jpeg" && ai.ImageGeneration.OutputFormat != "webp" {
 		ai.ImageGeneration.OutputFormat = "png"
 	}
+	if ai.Usage == nil {
+		ai.Usage = defaultUsage()
+	}
+	ai.Usage.Currency = strings.TrimSpace(ai.Usage.Currency)
+	if ai.Usage.Currency == "" {
+		ai.Usage.Currency = "USD"
+	}
+	prices := make([]*ModelPrice, 0, len(ai.Usage.Prices))
+	for _, price := range ai.Usage.Prices {
+		if price == nil {
+			continue
+		}
+		price.Model = strings.TrimSpace(price.Model)
+		if price.Model == "" || slices.ContainsFunc(prices, func(p *ModelPrice) bool { return strings.EqualFold(p.Model, price.Model) }) {
+			continue
+		}
+		price.Input, price.Output, price.Image = max(price.Input, 0), max(price.Output, 0), max(price.Image, 0)
+		prices = append(prices, price)
+	}
+	ai.Usage.Prices = prices
+	budgets := make([]*Budget, 0, len(ai.Usage.Budgets))
+	for _, budget := range ai.Usage.Budgets {
+		if budget == nil || budget.Amount <= 0 {
+			continue
+		}
+		budget.Model = strings.TrimSpace(budget.Model)
+		budget.Action = strings.ToLower(strings.TrimSpace(budget.Action))
+		if budget.Action != BudgetActionBlock {
+			budget.Action = BudgetActionWarn
+		}
+		budgets = append(budgets, budget)
+	}
+	ai.Usage.Budgets = budgets
 	providers := make([]*Provider, 0, len(ai.Providers))
 	for _, p := range ai.Providers {
 		if p == nil {
 			continue
 		}
+		p.Protocol = strings.ToLower(strings.TrimSpace(p.Protocol))
+		if !util.IsAIProtocol(p.Protocol) {
+			p.Protocol = util.AIProtocolOpenAI
+		}
 		p.BaseURL = strings.TrimSpace(p.BaseURL)
 		if "" == p.BaseURL {
-			p.BaseURL = "https://api.openai.com/v1"
+			p.BaseURL = util.DefaultAIBaseURL(p.Protocol)
 		}
 		p.DisplayName = strings.TrimSpace(p.DisplayName)
 		p.APIKey = strings.TrimSpace(p.APIKey)
-		p.Protocol = strings.ToLower(strings.TrimSpace(p.Protocol))
-		if p.Protocol == "" {
-			p.Protocol = "openai"
-		}
 		if 1 > p.RequestTimeout {
 			p.RequestTimeout = 120
 		} else if 600 < p.RequestTimeout {
@@ -504,6 +652,10 @@ func (ai *AI) Normalize() {
 	if ai.Embedding.Timeout < 1 {
 		ai.Embedding.Timeout = 30
 	}
+	ai.Embedding.Protocol = strings.ToLower(strings.TrimSpace(ai.Embedding.Protocol))
+	if util.AIProtocolGemini != ai.Embedding.Protocol {
+		ai.Embedding.Protocol = util.AIProtocolOpenAI
+	}
 	if ai.Embedding.Dimensions < 0 {
 		ai.Embedding.Dimensions = 0 // 负值非法，归零表示用模型默认维度
 	}
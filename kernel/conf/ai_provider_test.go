@@ -85,3 +85,28 @@ func TestAssignDefaultModelIDsUsesKeylessProvider(t *testing.T) {
 		t.Fatalf("unexpected default model IDs: agent=%q editing=%q", ai.Agent.ModelID, ai.Editing.ModelID)
 	}
 }
+
+func TestAINormalizeProviderProtocol(t *testing.T) {
+	ai := &AI{
+		Providers: []*Provider{
+			{Protocol: " Anthropic "},
+			{Protocol: "gemini", BaseURL: "http://127.0.0.1:8080/v1beta"},
+			{Protocol: "unknown"},
+		},
+		Embedding: &Embedding{Protocol: "anthropic"},
+	}
+	ai.Normalize()
+
+	if p := ai.Providers[0]; p.Protocol != "anthropic" || p.BaseURL != "https://api.anthropic.com/v1" {
+		t.Fatalf("unexpected anthropic provider: %#v", p)
+	}
+	if p := ai.Providers[1]; p.Protocol != "gemini" || p.BaseURL != "http://127.0.0.1:8080/v1beta" {
+		t.Fatalf("unexpected gemini provider: %#v", p)
+	}
+	if p := ai.Providers[2]; p.Protocol != "openai" || p.BaseURL != "https://api.openai.com/v1" {
+		t.Fatalf("unexpected fallback provider: %#v", p)
+	}
+	if ai.Embedding.Protocol != "openai" {
+		t.Fatalf("embedding protocol = %q, want openai", ai.Embedding.Protocol)
+	}
+}
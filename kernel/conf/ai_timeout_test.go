@@ -17,11 +17,12 @@ func TestAINormalizeAgentTimeoutsAndRetries(t *testing.T) {
 		wantSession    int
 		wantStreamIdle int
 		wantMaxRetries int
+		wantSubAgents  int
 	}{
-		{name: "defaults", agent: nil, wantSession: 600, wantStreamIdle: 120, wantMaxRetries: 3},
-		{name: "zero means unlimited session and no retries", agent: &Agent{}, wantSession: 0, wantStreamIdle: 120, wantMaxRetries: 0},
-		{name: "clamps upper bounds", agent: &Agent{SessionTimeout: 7200, StreamIdleTimeout: 900, MaxRetries: 20}, wantSession: 3600, wantStreamIdle: 600, wantMaxRetries: 10},
-		{name: "normalizes negative values", agent: &Agent{SessionTimeout: -1, StreamIdleTimeout: -1, MaxRetries: -1}, wantSession: 0, wantStreamIdle: 120, wantMaxRetries: 0},
+		{name: "defaults", agent: nil, wantSession: 600, wantStreamIdle: 120, wantMaxRetries: 3, wantSubAgents: 3},
+		{name: "zero means unlimited session and no retries", agent: &Agent{}, wantSession: 0, wantStreamIdle: 120, wantMaxRetries: 0, wantSubAgents: 3},
+		{name: "clamps upper bounds", agent: &Agent{SessionTimeout: 7200, StreamIdleTimeout: 900, MaxRetries: 20, SubAgentConcurrency: 20}, wantSession: 3600, wantStreamIdle: 600, wantMaxRetries: 10, wantSubAgents: 8},
+		{name: "normalizes negative values", agent: &Agent{SessionTimeout: -1, StreamIdleTimeout: -1, MaxRetries: -1, SubAgentConcurrency: -1}, wantSession: 0, wantStreamIdle: 120, wantMaxRetries: 0, wantSubAgents: 3},
 	}
 
 	for _, test := range tests {
@@ -37,6 +38,9 @@ func TestAINormalizeAgentTimeoutsAndRetries(t *testing.T) {
 			if ai.Agent.MaxRetries != test.wantMaxRetries {
 				t.Errorf("max retries = %d, want %d", ai.Agent.MaxRetries, test.wantMaxRetries)
 			}
+			if ai.Agent.SubAgentConcurrency != test.wantSubAgents {
+				t.Errorf("sub-agent concurrency = %d, want %d", ai.Agent.SubAgentConcurrency, test.wantSubAgents)
+			}
 		})
 	}
 }
@@ -68,3 +72,57 @@ func TestNewAIDefaultProviderRequestTimeout(t *testing.T) {
 		t.Fatalf("unexpected default provider request timeout: %#v", ai.Providers)
 	}
 }
+
+func TestAINormalizeAgentFallbacks(t *testing.T) {
+	ai := &AI{Agent: &Agent{ModelID: "primary", Fallbacks: []*AgentFallback{
+		nil,
+		{ModelID: " backup ", Conditions: []string{"RATE_LIMIT", "bogus", "rate_limit", "context_overflow"}},
+		{ModelID: "primary"},
+		{ModelID: "backup"},
+		{ModelID: ""},
+		{ModelID: "local"},
+	}}}
+	ai.Normalize()
+
+	fallbacks := ai.Agent.Fallbacks
+	if len(fallbacks) != 2 || fallbacks[0].ModelID != "backup" || fallbacks[1].ModelID != "local" {
+		t.Fatalf("fallbacks = %+v", fallbacks)
+	}
+	if got := fallbacks[0].Conditions; len(got) != 2 || got[0] != FallbackOnRateLimit || got[1] != FallbackOnContextOverflow {
+		t.Fatalf("conditions = %v", got)
+	}
+	if fallbacks[0].Matches(FallbackOnTimeout) || !fallbacks[0].Matches(FallbackOnContextOverflow) {
+		t.Fatal("explicit conditions should restrict fallback")
+	}
+	if !fallbacks[1].Matches(FallbackOnTimeout) || fallbacks[1].Matches(FallbackOnContextOverflow) {
+		t.Fatal("empty conditions should use the default set")
+	}
+
+	ai = &AI{}
+	ai.Normalize()
+	if ai.Agent.Fallbacks == nil {
+		t.Fatal("fallbacks should be non-nil")
+	}
+}
+
+func TestAINormalizeUsage(t *testing.T) {
+	ai := &AI{Usage: &Usage{
+		Prices:  []*ModelPrice{nil, {Model: " gpt-4o ", Input: 2.5, Output: -1}, {Model: "GPT-4O", Input: 9}, {Model: ""}},
+		Budgets: []*Budget{nil, {Amount: 0}, {Amount: 10, Action: "BLOCK"}, {Model: " gpt-4o ", Amount: 5, Action: "unknown"}},
+	}}
+	ai.Normalize()
+
+	if ai.Usage.Currency != "USD" {
+		t.Fatalf("currency = %q, want USD", ai.Usage.Currency)
+	}
+	if len(ai.Usage.Prices) != 1 || ai.Usage.Prices[0].Input != 2.5 || ai.Usage.Prices[0].Output != 0 {
+		t.Fatalf("prices = %+v", ai.Usage.Prices)
+	}
+	if price := ai.Usage.Price("gpt-4O"); price == nil || price.Model != "gpt-4o" {
+		t.Fatalf("price lookup = %+v", price)
+	}
+	budgets := ai.Usage.Budgets
+	if len(budgets) != 2 || budgets[0].Action != BudgetActionBlock || budgets[1].Action != BudgetActionWarn || budgets[1].Model != "gpt-4o" {
+		t.Fatalf("budgets = %+v", budgets)
+	}
+}
@@ -19,11 +19,34 @@ package conf
 import "github.com/88250/gulu"
 
 type API struct {
-	Token string `json:"token"`
+	Token  string      `json:"token"`  // 全局 API token，拥有管理员权限
+	Tokens []*APIToken `json:"tokens"` // 具名 API token，按角色、笔记本和路由分组限定权限
 }
 
 func NewAPI() *API {
 	return &API{
-		Token: gulu.Rand.String(16),
+		Token:  gulu.Rand.String(16),
+		Tokens: []*APIToken{},
 	}
 }
+
+// APIToken 描述一个具名 API token。
+type APIToken struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Hash        string   `json:"hash"`        // token 的 SHA-256 摘要，明文仅在创建时返回一次
+	Hint        string   `json:"hint"`        // token 末尾几位，用于在列表中辨认
+	Role        string   `json:"role"`        // admin/editor/reader
+	Boxes       []string `json:"boxes"`       // 允许访问的笔记本 ID，为空时不限制
+	RouteGroups []string `json:"routeGroups"` // 允许访问的路由分组（/api/{group}/），为空时不限制
+	Created     int64    `json:"created"`
+	Expired     int64    `json:"expired"` // 过期时间，为 0 时永不过期
+	LastUsed    int64    `json:"lastUsed"`
+	Revoked     bool     `json:"revoked"`
+}
+
+const (
+	APITokenRoleAdmin  = "admin"
+	APITokenRoleEditor = "editor"
+	APITokenRoleReader = "reader"
+)
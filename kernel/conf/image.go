@@ -0,0 +1,44 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// ImagePipeline 是上传图片时的本地处理配置，仅处理 JPEG 和 PNG 图片。
+type ImagePipeline struct {
+	Enabled       bool   `json:"enabled"`       // 是否在上传时处理图片
+	StripMetadata bool   `json:"stripMetadata"` // 是否去除 EXIF（含 GPS）、XMP、IPTC 和文本元数据
+	MaxDimension  int    `json:"maxDimension"`  // 长边最大像素，超过时等比缩小，0 表示不缩放
+	Format        string `json:"format"`        // 重新编码的格式，空表示保持原格式，jpeg 表示转为 JPEG（带透明通道的 PNG 保持原格式），webp 表示转为 WebP
+	Quality       int    `json:"quality"`       // JPEG 和 WebP 编码质量，1-100
+	KeepOriginal  bool   `json:"keepOriginal"`  // 是否在资源目录的 .originals 下保留原图
+}
+
+const (
+	ImageFormatOriginal = ""
+	ImageFormatJPEG     = "jpeg"
+	ImageFormatWebP     = "webp"
+)
+
+func NewImagePipeline() *ImagePipeline {
+	return &ImagePipeline{
+		Enabled:       false,
+		StripMetadata: true,
+		MaxDimension:  2560,
+		Format:        ImageFormatOriginal,
+		Quality:       85,
+		KeepOriginal:  false,
+	}
+}
@@ -65,8 +65,17 @@ type Search struct {
 	VirtualRefAlias  bool `json:"virtualRefAlias"`
 	VirtualRefAnchor bool `json:"virtualRefAnchor"`
 	VirtualRefDoc    bool `json:"virtualRefDoc"`
+
+	HybridFusion         string  `json:"hybridFusion"`         // 混合搜索的融合方式：rrf（倒数排名融合）、weighted（归一化分数加权）
+	HybridSemanticWeight float64 `json:"hybridSemanticWeight"` // 混合搜索中语义召回的权重 [0, 1]，关键字召回权重为 1 减去该值
+	HybridCandidateCount int     `json:"hybridCandidateCount"` // 混合搜索中关键字与语义各自召回的候选数
 }
 
+const (
+	HybridFusionRRF      = "rrf"
+	HybridFusionWeighted = "weighted"
+)
+
 func NewSearch() *Search {
 	return &Search{
 		Document:      true,
@@ -109,6 +118,10 @@ func NewSearch() *Search {
 		VirtualRefAlias:  false,
 		VirtualRefAnchor: true,
 		VirtualRefDoc:    true,
+
+		HybridFusion:         HybridFusionRRF,
+		HybridSemanticWeight: 0.5,
+		HybridCandidateCount: 64,
 	}
 }
 
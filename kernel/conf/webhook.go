@@ -0,0 +1,99 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+import (
+	"encoding/hex"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Webhooks 是出站 Webhook 配置，文档和块变更时向外部地址推送签名的 JSON 事件。
+type Webhooks struct {
+	Items []*Webhook `json:"items"`
+}
+
+func NewWebhooks() *Webhooks {
+	return &Webhooks{Items: []*Webhook{}}
+}
+
+// Webhook 描述一个出站 Webhook。过滤条件之间为“与”关系，同一条件内的多个值为“或”关系，为空时不限制。
+type Webhook struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	URL        string            `json:"url"`
+	Secret     string            `json:"secret"` // HMAC-SHA256 签名密钥，运行时为明文，落盘时由 Webhooks.Encrypt 加密
+	Enabled    bool              `json:"enabled"`
+	Events     []string          `json:"events"`     // 订阅的事件类型
+	Boxes      []string          `json:"boxes"`      // 笔记本 ID
+	Paths      []string          `json:"paths"`      // 文档路径前缀，支持存储路径（/20200812220555-lj3enxa）和人类可读路径（/日记/2026）
+	BlockTypes []string          `json:"blockTypes"` // 块类型，使用 SQL blocks 表的 type 字段值，如 d、h、p、l、i
+	Attrs      map[string]string `json:"attrs"`      // 块属性，值为空时仅要求存在该属性
+	Created    int64             `json:"created"`
+	Updated    int64             `json:"updated"`
+}
+
+const (
+	WebhookEventBlockChanged  = "block.changed"   // 事务提交后文档中的块发生变更
+	WebhookEventDocCreated    = "doc.created"     // 创建文档
+	WebhookEventDocRenamed    = "doc.renamed"     // 重命名文档
+	WebhookEventDocMoved      = "doc.moved"       // 移动文档
+	WebhookEventDocRemoved    = "doc.removed"     // 删除文档
+	WebhookEventAvCellUpdated = "av.cell.updated" // 更新数据库单元格
+	WebhookEventPing          = "ping"            // 测试投递，不受订阅和过滤条件限制
+)
+
+var WebhookEvents = []string{
+	WebhookEventBlockChanged,
+	WebhookEventDocCreated,
+	WebhookEventDocRenamed,
+	WebhookEventDocMoved,
+	WebhookEventDocRemoved,
+	WebhookEventAvCellUpdated,
+}
+
+// Encrypt 把内存明文签名密钥加密为密文，供 AppConf.Save() 序列化前调用。
+func (w *Webhooks) Encrypt() {
+	if w == nil {
+		return
+	}
+	for _, item := range w.Items {
+		if item == nil || item.Secret == "" {
+			continue
+		}
+		item.Secret = util.AESEncrypt(item.Secret)
+	}
+}
+
+// Decrypt 把密文解密回明文，解密方式与 Secrets.Decrypt 一致。
+func (w *Webhooks) Decrypt() {
+	if w == nil {
+		return
+	}
+	for _, item := range w.Items {
+		if item == nil || item.Secret == "" {
+			continue
+		}
+		dec := util.AESDecrypt(item.Secret)
+		if dec == nil {
+			continue
+		}
+		if plain, err := hex.DecodeString(string(dec)); err == nil {
+			item.Secret = string(plain)
+		}
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/agent"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/job"
 	"github.com/siyuan-note/siyuan/kernel/model"
@@ -65,6 +66,9 @@ func StartKernel(container, appDir, workspaceBaseDir, timezoneID, localIPs, lang
 		model.InitBoxes()
 		model.LoadFlashcards()
 		util.LoadAssetsTexts()
+		model.LoadUndoLog()
+		model.InitWebhooks()
+		agent.InitAutomations()
 
 		util.SetBooted()
 		util.PushClearAllMsg()
@@ -43,12 +43,16 @@ func StartCron() {
 	go every(10*time.Minute, model.CacheVirtualBlockRefJob)
 	go every(30*time.Second, model.OCRAssetsJob)
 	go every(30*time.Second, model.FlushAssetsTextsJob)
+	go every(10*time.Second, model.FlushUndoLogJob)
+	go every(10*time.Minute, model.FlushAIUsageJob)
+	go every(2*time.Second, model.DeliverWebhooksJob)
 	go every(30*time.Second, model.HookDesktopUIProcJob)
 	go every(24*time.Hour, model.AutoPurgeRepoJob)
 	go every(1*time.Minute, model.AutoFixIndex)
 	go every(30*time.Minute, model.AutoCheckMicrosoftDefenderJob)
 	go every(24*time.Hour, model.ClearOutdatedHistoryDirJob)
 	go every(1*time.Minute, model.AutoLockIdleEncryptedBoxesJob)
+	go every(1*time.Second, ExecScheduledJobs)
 	if util.IsMobileContainer() {
 		go every(3*time.Second, model.AutoConsumeShorthandsJob)
 	}
@@ -0,0 +1,165 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronExpr 为标准的 5 段 cron 表达式：分 时 日 月 周。
+// 支持 *、列表（1,2）、范围（1-5）、步长（*/15、1-30/5）、月份和星期的英文缩写以及 @hourly 等描述符。
+type CronExpr struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool // 日或周为 * 时，按另一段匹配
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+var cronDowNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// ParseCronExpr 解析 cron 表达式。
+func ParseCronExpr(expr string) (ret *CronExpr, err error) {
+	expr = strings.TrimSpace(expr)
+	if descriptor, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = descriptor
+	}
+
+	fields := strings.Fields(expr)
+	if 5 != len(fields) {
+		err = fmt.Errorf("invalid cron expression [%s]: expected 5 fields", expr)
+		return
+	}
+
+	ret = &CronExpr{}
+	if ret.minute, err = parseCronField(fields[0], 0, 59, nil); nil != err {
+		return nil, fmt.Errorf("invalid cron minute [%s]: %s", fields[0], err)
+	}
+	if ret.hour, err = parseCronField(fields[1], 0, 23, nil); nil != err {
+		return nil, fmt.Errorf("invalid cron hour [%s]: %s", fields[1], err)
+	}
+	if ret.dom, err = parseCronField(fields[2], 1, 31, nil); nil != err {
+		return nil, fmt.Errorf("invalid cron day of month [%s]: %s", fields[2], err)
+	}
+	if ret.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); nil != err {
+		return nil, fmt.Errorf("invalid cron month [%s]: %s", fields[3], err)
+	}
+	if ret.dow, err = parseCronField(fields[4], 0, 7, cronDowNames); nil != err {
+		return nil, fmt.Errorf("invalid cron day of week [%s]: %s", fields[4], err)
+	}
+	if 0 != ret.dow&(1<<7) {
+		// 7 和 0 都表示周日
+		ret.dow |= 1
+	}
+	ret.domStar = strings.HasPrefix(fields[2], "*")
+	ret.dowStar = strings.HasPrefix(fields[4], "*")
+	return
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (ret uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			if step, err = strconv.Atoi(stepPart); nil != err || 1 > step {
+				return 0, fmt.Errorf("invalid step [%s]", stepPart)
+			}
+		}
+
+		start, end := min, max
+		if "*" != rangePart {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			if start, err = parseCronValue(startPart, names); nil != err {
+				return
+			}
+			end = start
+			if isRange {
+				if end, err = parseCronValue(endPart, names); nil != err {
+					return
+				}
+			} else if hasStep {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("value out of range [%d-%d]", min, max)
+		}
+
+		for i := start; i <= end; i += step {
+			ret |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+func parseCronValue(value string, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if nil != err {
+		return 0, fmt.Errorf("invalid value [%s]", value)
+	}
+	return n, nil
+}
+
+// Next 返回严格晚于 t 的下一次触发时间，找不到时（如 2 月 30 日）返回零值。
+func (e *CronExpr) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if 0 == e.month&(1<<uint(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !e.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if 0 == e.hour&(1<<uint(t.Hour())) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if 0 == e.minute&(1<<uint(t.Minute())) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchDay 按 cron 惯例匹配日期：日和周都不为 * 时满足其一即可。
+func (e *CronExpr) matchDay(t time.Time) bool {
+	domMatch := 0 != e.dom&(1<<uint(t.Day()))
+	dowMatch := 0 != e.dow&(1<<uint(t.Weekday()))
+	if e.domStar || e.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
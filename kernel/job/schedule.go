@@ -0,0 +1,150 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package job
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siyuan-note/logging"
+)
+
+// Schedule 描述按 cron 表达式或固定间隔触发的时间规则，两者只能设置其一。
+type Schedule struct {
+	Cron     *CronExpr
+	Interval time.Duration
+}
+
+// NewSchedule 根据 cron 表达式或固定间隔创建时间规则。
+func NewSchedule(cron string, interval time.Duration) (ret *Schedule, err error) {
+	cron = strings.TrimSpace(cron)
+	if ("" == cron) == (0 >= interval) {
+		err = fmt.Errorf("either cron or interval is required")
+		return
+	}
+
+	ret = &Schedule{Interval: interval}
+	if "" != cron {
+		ret.Cron, err = ParseCronExpr(cron)
+	} else if interval < time.Second {
+		err = fmt.Errorf("interval must be at least 1s")
+	}
+	return
+}
+
+// Next 返回严格晚于 t 的下一次触发时间。固定间隔以 t 为起点计算。
+func (s *Schedule) Next(t time.Time) time.Time {
+	if nil != s.Cron {
+		return s.Cron.Next(t)
+	}
+	return t.Add(s.Interval)
+}
+
+// Missed 返回 (last, now] 之间错过的触发时间，最多返回 limit 个。
+func (s *Schedule) Missed(last, now time.Time, limit int) (ret []time.Time) {
+	for next := s.Next(last); !next.IsZero() && !next.After(now) && len(ret) < limit; next = s.Next(next) {
+		ret = append(ret, next)
+	}
+	return
+}
+
+// ScheduledJob 为注册到定时任务调度器中的任务。
+type ScheduledJob struct {
+	ID       string
+	Schedule *Schedule
+	Next     time.Time                     // 下一次触发时间
+	Func     func(scheduledTime time.Time) // 触发时在新的 goroutine 中调用
+}
+
+var (
+	scheduledJobs     = map[string]*ScheduledJob{}
+	scheduledJobsLock = sync.Mutex{}
+)
+
+// AddScheduledJob 注册定时任务，ID 相同的任务会被替换。Next 为零值时从当前时间开始计算。
+func AddScheduledJob(job *ScheduledJob) {
+	if job.Next.IsZero() {
+		job.Next = job.Schedule.Next(time.Now())
+	}
+
+	scheduledJobsLock.Lock()
+	defer scheduledJobsLock.Unlock()
+	scheduledJobs[job.ID] = job
+}
+
+// RemoveScheduledJob 移除定时任务。
+func RemoveScheduledJob(id string) {
+	scheduledJobsLock.Lock()
+	defer scheduledJobsLock.Unlock()
+	delete(scheduledJobs, id)
+}
+
+// RemoveScheduledJobsByPrefix 移除 ID 以 prefix 开头的所有定时任务。
+func RemoveScheduledJobsByPrefix(prefix string) {
+	scheduledJobsLock.Lock()
+	defer scheduledJobsLock.Unlock()
+	for id := range scheduledJobs {
+		if strings.HasPrefix(id, prefix) {
+			delete(scheduledJobs, id)
+		}
+	}
+}
+
+// GetScheduledJobNext 返回定时任务的下一次触发时间。
+func GetScheduledJobNext(id string) (ret time.Time, ok bool) {
+	scheduledJobsLock.Lock()
+	defer scheduledJobsLock.Unlock()
+	if job := scheduledJobs[id]; nil != job {
+		ret, ok = job.Next, true
+	}
+	return
+}
+
+// ExecScheduledJobs 触发所有到期的定时任务，由 StartCron 每秒调用一次。
+func ExecScheduledJobs() {
+	execScheduledJobs(time.Now())
+}
+
+func execScheduledJobs(now time.Time) {
+	scheduledJobsLock.Lock()
+	var dueJobs []*ScheduledJob
+	var dueTimes []time.Time
+	for id, job := range scheduledJobs {
+		if job.Next.IsZero() || now.Before(job.Next) {
+			continue
+		}
+
+		dueJobs = append(dueJobs, job)
+		dueTimes = append(dueTimes, job.Next)
+		// 调度器停顿（如休眠）后只触发一次，下一次触发时间从当前时间开始计算
+		job.Next = job.Schedule.Next(now)
+		if job.Next.IsZero() {
+			logging.LogWarnf("scheduled job [%s] has no next run time, removed", id)
+			delete(scheduledJobs, id)
+		}
+	}
+	scheduledJobsLock.Unlock()
+
+	for i, job := range dueJobs {
+		go func() {
+			defer logging.Recover()
+			job.Func(dueTimes[i])
+		}()
+	}
+}
@@ -0,0 +1,89 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronExprNext(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 7, 30, 0, time.Local) // 周三
+	cases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 5, 1, 10, 15, 0, 0, time.Local)},
+		{"0 2 * * *", time.Date(2024, 5, 2, 2, 0, 0, 0, time.Local)},
+		{"30 9 * * mon-fri", time.Date(2024, 5, 2, 9, 30, 0, 0, time.Local)},
+		{"0 0 1 jan *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"0 12 * * 7", time.Date(2024, 5, 5, 12, 0, 0, 0, time.Local)},
+		{"0 0 13 * 5", time.Date(2024, 5, 3, 0, 0, 0, 0, time.Local)}, // 日和周满足其一即可
+		{"@hourly", time.Date(2024, 5, 1, 11, 0, 0, 0, time.Local)},
+	}
+	for _, c := range cases {
+		expr, err := ParseCronExpr(c.expr)
+		if nil != err {
+			t.Fatalf("parse cron [%s] failed: %s", c.expr, err)
+		}
+		if next := expr.Next(base); !next.Equal(c.next) {
+			t.Fatalf("cron [%s] next expected [%s], got [%s]", c.expr, c.next, next)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := ParseCronExpr(expr); nil == err {
+			t.Fatalf("expected invalid cron [%s]", expr)
+		}
+	}
+
+	if expr, _ := ParseCronExpr("0 0 30 2 *"); !expr.Next(base).IsZero() {
+		t.Fatal("expected no next run for February 30th")
+	}
+}
+
+func TestScheduleMissedAndExec(t *testing.T) {
+	schedule, err := NewSchedule("", time.Hour)
+	if nil != err {
+		t.Fatalf("new schedule failed: %s", err)
+	}
+	last := time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+	if missed := schedule.Missed(last, last.Add(3*time.Hour+time.Minute), 10); 3 != len(missed) {
+		t.Fatalf("expected 3 missed runs, got %d", len(missed))
+	}
+	if missed := schedule.Missed(last, last.Add(100*time.Hour), 5); 5 != len(missed) {
+		t.Fatalf("expected missed runs to be limited, got %d", len(missed))
+	}
+	if _, err = NewSchedule("* * * * *", time.Minute); nil == err {
+		t.Fatal("expected error when both cron and interval are set")
+	}
+
+	fired := make(chan time.Time, 1)
+	now := time.Now()
+	AddScheduledJob(&ScheduledJob{ID: "test:exec", Schedule: schedule, Next: now.Add(-time.Second), Func: func(scheduledTime time.Time) { fired <- scheduledTime }})
+	t.Cleanup(func() { RemoveScheduledJobsByPrefix("test:") })
+
+	execScheduledJobs(now)
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("due job was not fired")
+	}
+	if next, ok := GetScheduledJobNext("test:exec"); !ok || !next.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected next run [%s]", next)
+	}
+}
@@ -63,6 +63,48 @@ func processRequest(req *JsonRpcRequest, session *Session, protocolVersion strin
 		}
 		return handleToolsCall(req)
 
+	case "resources/list":
+		if isNotification {
+			return nil
+		}
+		return handleResourcesList(req)
+
+	case "resources/templates/list":
+		if isNotification {
+			return nil
+		}
+		return handleResourceTemplatesList(req.ID)
+
+	case "resources/read":
+		if isNotification {
+			return nil
+		}
+		return handleResourcesRead(req)
+
+	case "resources/subscribe":
+		if isNotification {
+			return nil
+		}
+		return handleResourcesSubscribe(req, session, true)
+
+	case "resources/unsubscribe":
+		if isNotification {
+			return nil
+		}
+		return handleResourcesSubscribe(req, session, false)
+
+	case "prompts/list":
+		if isNotification {
+			return nil
+		}
+		return handlePromptsList(req.ID)
+
+	case "prompts/get":
+		if isNotification {
+			return nil
+		}
+		return handlePromptsGet(req)
+
 	default:
 		if isNotification {
 			return nil
@@ -107,6 +149,36 @@ func processRequest2026(req *JsonRpcRequest) any {
 		}
 		return handleToolsCall(req)
 
+	case "resources/list":
+		if isNotification {
+			return nil
+		}
+		return handleResourcesList(req)
+
+	case "resources/templates/list":
+		if isNotification {
+			return nil
+		}
+		return handleResourceTemplatesList(req.ID)
+
+	case "resources/read":
+		if isNotification {
+			return nil
+		}
+		return handleResourcesRead(req)
+
+	case "prompts/list":
+		if isNotification {
+			return nil
+		}
+		return handlePromptsList(req.ID)
+
+	case "prompts/get":
+		if isNotification {
+			return nil
+		}
+		return handlePromptsGet(req)
+
 	default:
 		if isNotification {
 			return nil
@@ -136,7 +208,9 @@ func handleInitialize(req *JsonRpcRequest, session *Session) *JsonRpcResponse {
 		Result: map[string]any{
 			"protocolVersion": serverVersion,
 			"capabilities": ServerCapabilities{
-				Tools: &ToolsCapability{ListChanged: false},
+				Tools:     &ToolsCapability{ListChanged: false},
+				Resources: &ResourcesCapability{Subscribe: true, ListChanged: false},
+				Prompts:   &PromptsCapability{ListChanged: false},
 			},
 			"serverInfo": ServerInfo{
 				Name:    ServerName,
@@ -218,6 +292,9 @@ func handleDiscover(req *JsonRpcRequest) *JsonRpcResponse {
 			"protocolVersion": ProtocolV20260728,
 			"capabilities": ServerCapabilities{
 				Tools: &ToolsCapability{ListChanged: false},
+				// 无状态协议没有会话，不支持资源订阅
+				Resources: &ResourcesCapability{Subscribe: false, ListChanged: false},
+				Prompts:   &PromptsCapability{ListChanged: false},
 			},
 			"serverInfo": ServerInfo{
 				Name:    ServerName,
@@ -0,0 +1,105 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package mcp
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 提示词由工作区模板（data/templates/）提供，提示词名称为去掉 .md 后缀的模板相对路径。
+var promptArguments = []*PromptArgument{
+	{
+		Name:        "id",
+		Description: "ID of the block the template is rendered for, filling .action{.title}, .action{.id}, .action{.name} and .action{.alias}",
+		Required:    false,
+	},
+}
+
+func handlePromptsList(id any) *JsonRpcResponse {
+	prompts := []*Prompt{}
+	for _, t := range model.SearchTemplate("") {
+		name := strings.TrimSuffix(t.RelativePath, ".md")
+		prompts = append(prompts, &Prompt{
+			Name:        name,
+			Title:       filepath.Base(name),
+			Description: "SiYuan template " + t.RelativePath,
+			Arguments:   promptArguments,
+		})
+	}
+	return &JsonRpcResponse{
+		JsonRpc: "2.0",
+		Result:  map[string]any{"prompts": prompts},
+		ID:      id,
+	}
+}
+
+func handlePromptsGet(req *JsonRpcRequest) any {
+	params, _ := req.Params.(map[string]any)
+	name, _ := params["name"].(string)
+	args, _ := params["arguments"].(map[string]any)
+	blockID, _ := args["id"].(string)
+
+	p := resolvePromptTemplatePath(name)
+	if p == "" {
+		return &JsonRpcErrorResponse{
+			JsonRpc: "2.0",
+			Error:   RpcError{Code: -32602, Message: "Invalid params: prompt not found", Data: map[string]any{"name": name}},
+			ID:      req.ID,
+		}
+	}
+
+	md, err := model.RenderTemplateMarkdown(p, blockID, nil)
+	if err != nil {
+		return &JsonRpcErrorResponse{
+			JsonRpc: "2.0",
+			Error:   RpcError{Code: -32603, Message: "Render template failed: " + err.Error()},
+			ID:      req.ID,
+		}
+	}
+
+	return &JsonRpcResponse{
+		JsonRpc: "2.0",
+		Result: map[string]any{
+			"description": "SiYuan template " + name,
+			"messages": []*PromptMessage{{
+				Role:    "user",
+				Content: PromptMessageContent{Type: "text", Text: md},
+			}},
+		},
+		ID: req.ID,
+	}
+}
+
+// resolvePromptTemplatePath 返回提示词对应的模板文件绝对路径，模板不存在或越出模板目录时返回空。
+func resolvePromptTemplatePath(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	templates := filepath.Join(util.DataDir, "templates")
+	p := filepath.Join(templates, filepath.FromSlash(name)+".md")
+	rel, err := filepath.Rel(templates, p)
+	if err != nil || strings.HasPrefix(rel, "..") || !gulu.File.IsExist(p) {
+		return ""
+	}
+	return p
+}
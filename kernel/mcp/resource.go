@@ -0,0 +1,307 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package mcp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// 资源 URI 沿用思源块超链接的 siyuan://blocks/{id} 格式，文档即为根块。
+const (
+	resourceBlockPrefix    = "siyuan://blocks/"
+	resourceDatabasePrefix = "siyuan://databases/"
+
+	resourcePageSize = 100
+
+	// 资源列表游标，先按更新时间倒序列出文档，再列出数据库
+	resourceCursorDoc = "doc:"
+	resourceCursorAv  = "av:"
+
+	rpcErrResourceNotFound = -32002
+)
+
+var resourceTemplates = []*ResourceTemplate{
+	{
+		URITemplate: resourceBlockPrefix + "{id}",
+		Name:        "block",
+		Title:       "Block or document",
+		Description: "Markdown of a block. Reading a document block returns the whole document.",
+		MimeType:    "text/markdown",
+	},
+	{
+		URITemplate: resourceDatabasePrefix + "{id}",
+		Name:        "database",
+		Title:       "Database",
+		Description: "Fields and items of a database (attribute view) as JSON.",
+		MimeType:    "application/json",
+	},
+}
+
+// parseResourceURI 解析资源 URI，返回资源类型（"block" 或 "database"）和 ID。
+func parseResourceURI(uri string) (kind, id string, ok bool) {
+	switch {
+	case strings.HasPrefix(uri, resourceBlockPrefix):
+		kind, id = "block", strings.TrimPrefix(uri, resourceBlockPrefix)
+	case strings.HasPrefix(uri, resourceDatabasePrefix):
+		kind, id = "database", strings.TrimPrefix(uri, resourceDatabasePrefix)
+	default:
+		return
+	}
+	ok = ast.IsNodeIDPattern(id)
+	return
+}
+
+func handleResourcesList(req *JsonRpcRequest) any {
+	params, _ := req.Params.(map[string]any)
+	cursor, _ := params["cursor"].(string)
+	if cursor == "" {
+		cursor = resourceCursorDoc + "0"
+	}
+
+	var phase string
+	switch {
+	case strings.HasPrefix(cursor, resourceCursorDoc):
+		phase = resourceCursorDoc
+	case strings.HasPrefix(cursor, resourceCursorAv):
+		phase = resourceCursorAv
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(cursor, phase))
+	if phase == "" || err != nil || offset < 0 {
+		return &JsonRpcErrorResponse{
+			JsonRpc: "2.0",
+			Error:   RpcError{Code: -32602, Message: "Invalid params: invalid cursor"},
+			ID:      req.ID,
+		}
+	}
+
+	resources := []*Resource{}
+	nextCursor := ""
+	if phase == resourceCursorDoc {
+		var hasMore bool
+		resources, hasMore = listDocResources(offset, resourcePageSize)
+		if hasMore {
+			nextCursor = resourceCursorDoc + strconv.Itoa(offset+resourcePageSize)
+		} else {
+			phase, offset = resourceCursorAv, 0
+		}
+	}
+	if phase == resourceCursorAv && len(resources) < resourcePageSize {
+		limit := resourcePageSize - len(resources)
+		avResources, hasMore := listDatabaseResources(offset, limit)
+		resources = append(resources, avResources...)
+		if hasMore {
+			nextCursor = resourceCursorAv + strconv.Itoa(offset+limit)
+		}
+	}
+
+	result := map[string]any{"resources": resources}
+	if nextCursor != "" {
+		result["nextCursor"] = nextCursor
+	}
+	return &JsonRpcResponse{JsonRpc: "2.0", Result: result, ID: req.ID}
+}
+
+func listDocResources(offset, limit int) (ret []*Resource, hasMore bool) {
+	ret = []*Resource{}
+	stmt := "SELECT * FROM blocks WHERE type = 'd' ORDER BY updated DESC LIMIT ? OFFSET ?"
+	blocks := sql.SelectBlocksRawStmtArgs(stmt, []any{limit + 1, offset}, limit+1)
+	if len(blocks) > limit {
+		blocks, hasMore = blocks[:limit], true
+	}
+	for _, b := range blocks {
+		ret = append(ret, &Resource{
+			URI:         resourceBlockPrefix + b.ID,
+			Name:        b.Content,
+			Title:       b.Content,
+			Description: b.HPath,
+			MimeType:    "text/markdown",
+			Annotations: &ResourceAnnotations{LastModified: formatResourceTime(b.Updated)},
+		})
+	}
+	return
+}
+
+func listDatabaseResources(offset, limit int) (ret []*Resource, hasMore bool) {
+	ret = []*Resource{}
+	var avIDs []string
+	for avID := range av.GetBlockRels() {
+		avIDs = append(avIDs, avID)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(avIDs)))
+	if offset >= len(avIDs) {
+		return
+	}
+	avIDs = avIDs[offset:]
+	if len(avIDs) > limit {
+		avIDs, hasMore = avIDs[:limit], true
+	}
+
+	for _, avID := range avIDs {
+		name, _ := av.GetAttributeViewName(avID)
+		if name == "" {
+			name = avID
+		}
+		ret = append(ret, &Resource{
+			URI:      resourceDatabasePrefix + avID,
+			Name:     name,
+			Title:    name,
+			MimeType: "application/json",
+		})
+	}
+	return
+}
+
+// formatResourceTime 将思源的 yyyyMMddHHmmss 时间转换为 ISO 8601 格式。
+func formatResourceTime(updated string) string {
+	t, err := time.ParseInLocation("20060102150405", updated, time.Local)
+	if err != nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func handleResourceTemplatesList(id any) *JsonRpcResponse {
+	return &JsonRpcResponse{
+		JsonRpc: "2.0",
+		Result:  map[string]any{"resourceTemplates": resourceTemplates},
+		ID:      id,
+	}
+}
+
+func handleResourcesRead(req *JsonRpcRequest) any {
+	params, _ := req.Params.(map[string]any)
+	uri, _ := params["uri"].(string)
+	kind, id, ok := parseResourceURI(uri)
+	if !ok {
+		return &JsonRpcErrorResponse{
+			JsonRpc: "2.0",
+			Error:   RpcError{Code: -32602, Message: "Invalid params: unsupported resource uri", Data: map[string]any{"uri": uri}},
+			ID:      req.ID,
+		}
+	}
+
+	var contents *ResourceContents
+	switch kind {
+	case "block":
+		contents = readBlockResource(uri, id)
+	case "database":
+		contents = readDatabaseResource(uri, id)
+	}
+	if contents == nil {
+		return &JsonRpcErrorResponse{
+			JsonRpc: "2.0",
+			Error:   RpcError{Code: rpcErrResourceNotFound, Message: "Resource not found", Data: map[string]any{"uri": uri}},
+			ID:      req.ID,
+		}
+	}
+
+	return &JsonRpcResponse{
+		JsonRpc: "2.0",
+		Result:  map[string]any{"contents": []*ResourceContents{contents}},
+		ID:      req.ID,
+	}
+}
+
+func readBlockResource(uri, id string) *ResourceContents {
+	bt := treenode.GetBlockTree(id)
+	if bt == nil {
+		return nil
+	}
+
+	var md string
+	if bt.ID == bt.RootID {
+		_, md = model.ExportMarkdownContent(id, 4, 0, true, false, false, false, false)
+	} else {
+		md = model.GetBlockKramdown(id, "md")
+	}
+	return &ResourceContents{URI: uri, MimeType: "text/markdown", Text: md}
+}
+
+func readDatabaseResource(uri, id string) *ResourceContents {
+	attrView := model.GetAttributeView(id)
+	if attrView == nil {
+		return nil
+	}
+
+	type field struct {
+		ID   string     `json:"id"`
+		Name string     `json:"name"`
+		Type av.KeyType `json:"type"`
+	}
+	type item struct {
+		ID     string            `json:"id"`
+		Values map[string]string `json:"values"` // 字段名到值的文本
+	}
+
+	var fields []*field
+	for _, kv := range attrView.KeyValues {
+		fields = append(fields, &field{ID: kv.Key.ID, Name: kv.Key.Name, Type: kv.Key.Type})
+	}
+
+	items := []*item{}
+	if blockKeyValues := attrView.GetBlockKeyValues(); blockKeyValues != nil {
+		for _, blockValue := range blockKeyValues.Values {
+			it := &item{ID: blockValue.BlockID, Values: map[string]string{}}
+			for _, kv := range attrView.KeyValues {
+				if value := kv.GetValue(blockValue.BlockID); value != nil {
+					it.Values[kv.Key.Name] = value.String(true)
+				}
+			}
+			items = append(items, it)
+		}
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(map[string]any{
+		"id":     attrView.ID,
+		"name":   attrView.Name,
+		"fields": fields,
+		"items":  items,
+	}, "", "  ")
+	if err != nil {
+		return nil
+	}
+	return &ResourceContents{URI: uri, MimeType: "application/json", Text: string(data)}
+}
+
+func handleResourcesSubscribe(req *JsonRpcRequest, session *Session, subscribe bool) any {
+	params, _ := req.Params.(map[string]any)
+	uri, _ := params["uri"].(string)
+	if _, _, ok := parseResourceURI(uri); !ok {
+		return &JsonRpcErrorResponse{
+			JsonRpc: "2.0",
+			Error:   RpcError{Code: -32602, Message: "Invalid params: unsupported resource uri", Data: map[string]any{"uri": uri}},
+			ID:      req.ID,
+		}
+	}
+
+	if subscribe {
+		session.subscribe(uri)
+	} else {
+		session.unsubscribe(uri)
+	}
+	return &JsonRpcResponse{JsonRpc: "2.0", Result: map[string]any{}, ID: req.ID}
+}
@@ -20,19 +20,76 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
 func Serve(ginServer *gin.Engine) {
 	// MCP 工具暴露任意工作区文件读写删、SQL、插件分发等管理级原语，必须要求管理员角色，
 	// 否则 Publish 匿名模式注入的 RoleReader JWT 可经此链路越权调用全部工具。
 	ginServer.POST("/mcp", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, handlePost)
-	ginServer.GET("/mcp", model.CheckAuth, model.CheckAdminRole, func(c *gin.Context) {
-		c.Status(http.StatusMethodNotAllowed)
-	})
+	ginServer.GET("/mcp", model.CheckAuth, model.CheckAdminRole, handleGet)
 	ginServer.DELETE("/mcp", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, handleDelete)
+
+	if err := eventbus.Subscribe(util.EvtTxCommitted, notifyResourcesUpdated); err != nil {
+		logging.LogErrorf("subscribe mcp resource updates failed: %s", err)
+	}
+}
+
+// handleGet 打开会话的 SSE 流，用于推送资源订阅变更等服务端通知。
+func handleGet(c *gin.Context) {
+	if !strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := c.GetHeader("Mcp-Session-Id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mcp-Session-Id required"})
+		return
+	}
+	session := getSession(sessionID)
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-session.closed:
+			return
+		case <-keepAlive.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case notification := <-session.notifications:
+			data, err := json.Marshal(notification)
+			if err != nil {
+				continue
+			}
+			if _, err = c.Writer.WriteString("event: message\ndata: " + string(data) + "\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
 }
 
 func handlePost(c *gin.Context) {
@@ -26,6 +26,11 @@ type Session struct {
 	ID          string
 	initialized bool
 	ready       bool
+
+	subscriptionsMu sync.Mutex
+	subscriptions   map[string]bool      // 已订阅的资源 URI
+	notifications   chan *JsonRpcRequest // 待通过 GET SSE 流推送的服务端通知
+	closed          chan struct{}
 }
 
 var (
@@ -35,7 +40,10 @@ var (
 
 func newSession() *Session {
 	s := &Session{
-		ID: gulu.Rand.String(16),
+		ID:            gulu.Rand.String(16),
+		subscriptions: map[string]bool{},
+		notifications: make(chan *JsonRpcRequest, 64),
+		closed:        make(chan struct{}),
 	}
 	sessionsMu.Lock()
 	sessions[s.ID] = s
@@ -52,6 +60,41 @@ func getSession(id string) *Session {
 
 func removeSession(id string) {
 	sessionsMu.Lock()
+	s := sessions[id]
 	delete(sessions, id)
 	sessionsMu.Unlock()
+	if s != nil {
+		close(s.closed)
+	}
+}
+
+func (s *Session) subscribe(uri string) {
+	s.subscriptionsMu.Lock()
+	s.subscriptions[uri] = true
+	s.subscriptionsMu.Unlock()
+}
+
+func (s *Session) unsubscribe(uri string) {
+	s.subscriptionsMu.Lock()
+	delete(s.subscriptions, uri)
+	s.subscriptionsMu.Unlock()
+}
+
+func (s *Session) subscribedURIs() (ret []string) {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+	for uri := range s.subscriptions {
+		ret = append(ret, uri)
+	}
+	return
+}
+
+// notify 将通知放入会话的推送队列，队列已满时丢弃，避免阻塞事务提交。
+func (s *Session) notify(notification *JsonRpcRequest) bool {
+	select {
+	case s.notifications <- notification:
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,55 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package mcp
+
+import (
+	"slices"
+
+	"github.com/siyuan-note/logging"
+)
+
+// notifyResourcesUpdated 在事务提交后向订阅了变更资源的会话推送 notifications/resources/updated。
+// 订阅文档的会话在文档内任意块变更时都会收到通知。
+func notifyResourcesUpdated(rootIDs, blockIDs, avIDs []string) {
+	sessionsMu.RLock()
+	defer sessionsMu.RUnlock()
+
+	for _, session := range sessions {
+		for _, uri := range session.subscribedURIs() {
+			kind, id, _ := parseResourceURI(uri)
+			changed := false
+			switch kind {
+			case "block":
+				changed = slices.Contains(rootIDs, id) || slices.Contains(blockIDs, id)
+			case "database":
+				changed = slices.Contains(avIDs, id)
+			}
+			if !changed {
+				continue
+			}
+
+			notification := &JsonRpcRequest{
+				JsonRpc: "2.0",
+				Method:  "notifications/resources/updated",
+				Params:  map[string]any{"uri": uri},
+			}
+			if !session.notify(notification) {
+				logging.LogWarnf("mcp session [%s] notification queue is full, dropped resource update [%s]", session.ID, uri)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package mcp
+
+import (
+	"testing"
+)
+
+func TestParseResourceURI(t *testing.T) {
+	cases := []struct {
+		uri  string
+		kind string
+		id   string
+		ok   bool
+	}{
+		{"siyuan://blocks/20240101120000-abcdefg", "block", "20240101120000-abcdefg", true},
+		{"siyuan://databases/20240101120000-abcdefg", "database", "20240101120000-abcdefg", true},
+		{"siyuan://blocks/not-an-id", "block", "not-an-id", false},
+		{"file:///etc/passwd", "", "", false},
+	}
+	for _, c := range cases {
+		kind, id, ok := parseResourceURI(c.uri)
+		if kind != c.kind || id != c.id || ok != c.ok {
+			t.Errorf("parseResourceURI(%q) = %q, %q, %v; want %q, %q, %v", c.uri, kind, id, ok, c.kind, c.id, c.ok)
+		}
+	}
+}
+
+func TestResourceSubscriptionNotifications(t *testing.T) {
+	session := newSession()
+	defer removeSession(session.ID)
+
+	docURI := "siyuan://blocks/20240101120000-aaaaaaa"
+	avURI := "siyuan://databases/20240101120000-bbbbbbb"
+	for _, uri := range []string{docURI, avURI} {
+		resp := processRequest(&JsonRpcRequest{
+			JsonRpc: "2.0",
+			Method:  "resources/subscribe",
+			Params:  map[string]any{"uri": uri},
+			ID:      1,
+		}, session, ProtocolVersion)
+		if _, ok := resp.(*JsonRpcResponse); !ok {
+			t.Fatalf("subscribe %s: unexpected response %#v", uri, resp)
+		}
+	}
+
+	resp := processRequest(&JsonRpcRequest{
+		JsonRpc: "2.0",
+		Method:  "resources/subscribe",
+		Params:  map[string]any{"uri": "https://example.com"},
+		ID:      2,
+	}, session, ProtocolVersion)
+	if errResp, ok := resp.(*JsonRpcErrorResponse); !ok || errResp.Error.Code != -32602 {
+		t.Fatalf("subscribe unsupported uri: unexpected response %#v", resp)
+	}
+
+	// A block change inside the subscribed document notifies via its root ID
+	notifyResourcesUpdated([]string{"20240101120000-aaaaaaa"}, []string{"20240101120000-ccccccc"}, nil)
+	// Unrelated changes do not notify
+	notifyResourcesUpdated([]string{"20240101120000-ddddddd"}, nil, []string{"20240101120000-eeeeeee"})
+	notifyResourcesUpdated(nil, nil, []string{"20240101120000-bbbbbbb"})
+
+	var got []string
+	for len(session.notifications) > 0 {
+		notification := <-session.notifications
+		if notification.Method != "notifications/resources/updated" || notification.ID != nil {
+			t.Fatalf("unexpected notification %#v", notification)
+		}
+		got = append(got, notification.Params.(map[string]any)["uri"].(string))
+	}
+	if len(got) != 2 || got[0] != docURI || got[1] != avURI {
+		t.Fatalf("notified %v, want [%s %s]", got, docURI, avURI)
+	}
+
+	processRequest(&JsonRpcRequest{
+		JsonRpc: "2.0",
+		Method:  "resources/unsubscribe",
+		Params:  map[string]any{"uri": docURI},
+		ID:      3,
+	}, session, ProtocolVersion)
+	notifyResourcesUpdated([]string{"20240101120000-aaaaaaa"}, nil, nil)
+	if len(session.notifications) != 0 {
+		t.Fatalf("notified after unsubscribe")
+	}
+}
@@ -28,14 +28,15 @@ import (
 
 var AssetTool = &Tool{
 	Name:        "asset",
-	Description: "Asset management. Actions: upload(id, files=comma-separated absolute paths), unused(), clean(path?), stat(path).",
+	Description: "Asset management. Actions: upload(id, files=comma-separated absolute paths), unused(), clean(path?), stat(path), dedup(dryRun?).",
 	InputSchema: ToolSchema{
 		Type: "object",
 		Properties: map[string]Property{
-			"action": {Type: "string", Description: "Operation", Enum: []string{"upload", "unused", "clean", "stat"}},
+			"action": {Type: "string", Description: "Operation", Enum: []string{"upload", "unused", "clean", "stat", "dedup"}},
 			"id":     {Type: "string", Description: "Document block ID (for upload)"},
 			"files":  {Type: "string", Description: "Comma-separated absolute file paths (for upload)"},
 			"path":   {Type: "string", Description: "Single unused asset path to remove, relative to data directory (for clean, optional). Use as returned by the unused action, e.g. assets/image/xxx.png."},
+			"dryRun": {Type: "boolean", Description: "Only list duplicated assets without merging them (for dedup)"},
 		},
 		Required: []string{"action"},
 	},
@@ -57,9 +58,11 @@ func assetHandler(args map[string]any) (CallToolResult, error) {
 		return assetClean(args)
 	case "stat":
 		return assetStat(args)
+	case "dedup":
+		return assetDedup(args)
 	}
 	return CallToolResult{
-		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [upload, unused, clean, stat]"}},
+		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [upload, unused, clean, stat, dedup]"}},
 		IsError: true,
 	}, nil
 }
@@ -143,3 +146,29 @@ func assetStat(args map[string]any) (CallToolResult, error) {
 		p, info.Size(), info.IsDir(), info.ModTime().Format("2006-01-02 15:04:05"),
 	)}}}, nil
 }
+
+func assetDedup(args map[string]any) (CallToolResult, error) {
+	dryRun, _ := args["dryRun"].(bool)
+	result, err := model.DeduplicateAssets(dryRun)
+	if err != nil {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "deduplicate assets failed: " + err.Error()}}, IsError: true}, nil
+	}
+	if len(result.Groups) == 0 {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "no duplicated assets found"}}}, nil
+	}
+
+	var sb strings.Builder
+	if result.DryRun {
+		sb.WriteString(fmt.Sprintf("Preview (no changes made): %d duplicated asset(s), %d bytes\n\n", result.RemovedCount, result.SavedSize))
+	} else {
+		sb.WriteString(fmt.Sprintf("Removed %d duplicated asset(s), saved %d bytes, updated %d document(s) and %d database(s)\n\n",
+			result.RemovedCount, result.SavedSize, result.UpdatedDocs, result.UpdatedAVs))
+	}
+	for _, group := range result.Groups {
+		sb.WriteString(fmt.Sprintf("- keep %s\n", group.Keep))
+		for _, dup := range group.Duplicates {
+			sb.WriteString(fmt.Sprintf("  - %s\n", dup))
+		}
+	}
+	return CallToolResult{Content: []ContentItem{{Type: "text", Text: sb.String()}}}, nil
+}
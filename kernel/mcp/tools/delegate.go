@@ -0,0 +1,58 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tools
+
+// DelegateTool lets the agent split a large task into sub-tasks run by sub-agents. Like "question"
+// and "frontend", calls are intercepted by the agent loop (agent.runDelegation), which runs each
+// sub-agent with its own context and returns only their final summaries. The Handler is a fallback stub.
+var DelegateTool = &Tool{
+	Name:        "delegate",
+	Description: "Delegate independent sub-tasks to sub-agents that run in parallel, each with a fresh context, and get back only their final summaries. Use for large tasks that would need many tool calls (e.g. summarizing every meeting note of a quarter: one task per batch of documents). tasks[]: each {instruction (self-contained, the sub-agent cannot see this conversation), context? (IDs, paths or facts it needs), tools? (tool names it may use; default all except delegate/question/frontend/todo_write)}. Sub-agents cannot ask the user or confirm writes unless the session always allows operations.",
+	InputSchema: ToolSchema{
+		Type: "object",
+		Properties: map[string]Property{
+			"tasks": {
+				Type: "array", Description: "Sub-tasks to run in parallel",
+				Items: &Property{
+					Type: "object",
+					Properties: map[string]Property{
+						"instruction": {Type: "string", Description: "Focused, self-contained instruction for the sub-agent"},
+						"context":     {Type: "string", Description: "Extra context the sub-agent needs, such as document IDs or constraints"},
+						"tools": {
+							Type: "array", Description: "Tool names the sub-agent may use",
+							Items: &Property{Type: "string"},
+						},
+					},
+					Required: []string{"instruction"},
+				},
+			},
+		},
+		Required: []string{"tasks"},
+	},
+	Handler: delegateHandler,
+}
+
+func init() {
+	register(DelegateTool)
+}
+
+func delegateHandler(args map[string]any) (CallToolResult, error) {
+	return CallToolResult{
+		Content: []ContentItem{{Type: "text", Text: "delegate is only available in the agent chat (not via direct tool invocation)"}},
+		IsError: true,
+	}, nil
+}
@@ -0,0 +1,197 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/siyuan-note/siyuan/kernel/model"
+)
+
+var GraphTool = &Tool{
+	Name:        "graph",
+	Description: "Analyze the document reference graph (documents linked by block references, optionally tags). Actions: path(from, to) shortest path between the documents of two blocks, rank(metric?, limit?) most central documents, communities(minSize?, limit?) clusters of closely linked documents, orphans() unlinked documents, deadends() referenced documents without outgoing references, bridges(limit?) documents connecting otherwise separate clusters.",
+	InputSchema: ToolSchema{
+		Type: "object",
+		Properties: map[string]Property{
+			"action":   {Type: "string", Description: "Operation", Enum: []string{"path", "rank", "communities", "orphans", "deadends", "bridges"}},
+			"from":     {Type: "string", Description: "Source block ID (path only)"},
+			"to":       {Type: "string", Description: "Target block ID (path only)"},
+			"metric":   {Type: "string", Description: "Centrality metric for rank (default pagerank)", Enum: []string{model.GraphCentralityPageRank, model.GraphCentralityDegree}},
+			"limit":    {Type: "number", Description: "Max documents (rank/bridges, default 20) or communities (communities, default 10) to return"},
+			"minSize":  {Type: "number", Description: "Minimum documents per community (communities only, default 2)"},
+			"notebook": {Type: "string", Description: "Limit the analysis to one notebook ID (optional)"},
+			"tag":      {Type: "boolean", Description: "Treat tags as graph nodes so documents sharing a tag are connected (default: the global graph tag setting)"},
+		},
+		Required: []string{"action"},
+	},
+	EffectScope: EffectScopeLocal,
+	ActionEffects: map[string]ToolEffects{
+		"path":        {LocalRead: true},
+		"rank":        {LocalRead: true},
+		"communities": {LocalRead: true},
+		"orphans":     {LocalRead: true},
+		"deadends":    {LocalRead: true},
+		"bridges":     {LocalRead: true},
+	},
+	Handler: graphHandler,
+}
+
+// graphListLimit 限制返回给模型的文档列表长度，避免孤立文档等列表过长占满上下文。
+const graphListLimit = 100
+
+func init() {
+	register(GraphTool)
+}
+
+func graphHandler(args map[string]any) (CallToolResult, error) {
+	action, _ := args["action"].(string)
+	switch action {
+	case "path":
+		return graphPath(args)
+	case "rank":
+		return graphRank(args)
+	case "communities":
+		return graphCommunities(args)
+	case "orphans":
+		return graphDocList("Orphan documents", model.GraphOrphanDocs(graphOptions(args)))
+	case "deadends":
+		return graphDocList("Dead-end documents", model.GraphDeadEndDocs(graphOptions(args)))
+	case "bridges":
+		return graphBridges(args)
+	}
+	return CallToolResult{
+		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [path, rank, communities, orphans, deadends, bridges]"}},
+		IsError: true,
+	}, nil
+}
+
+func graphOptions(args map[string]any) *model.GraphAnalysisOptions {
+	opts := &model.GraphAnalysisOptions{Tag: model.Conf.Graph.Global.Tag}
+	opts.Box, _ = args["notebook"].(string)
+	if v, ok := args["tag"].(bool); ok {
+		opts.Tag = v
+	}
+	return opts
+}
+
+func graphIntArg(args map[string]any, key string, defaultValue int) int {
+	if v, ok := args[key].(float64); ok && 0 < v {
+		return int(v)
+	}
+	return defaultValue
+}
+
+func graphPath(args map[string]any) (CallToolResult, error) {
+	from, _ := args["from"].(string)
+	to, _ := args["to"].(string)
+	if from == "" || to == "" {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "from and to are required"}}, IsError: true}, nil
+	}
+
+	path, err := model.GraphShortestPath(from, to, graphOptions(args))
+	if err != nil {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	if !path.Found {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "no path found between the two documents"}}}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Shortest path (%d hop(s)):\n\n", path.Hops))
+	for i, node := range path.Nodes {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, formatGraphNode(node)))
+	}
+	return CallToolResult{Content: []ContentItem{{Type: "text", Text: sb.String()}}}, nil
+}
+
+func graphRank(args map[string]any) (CallToolResult, error) {
+	metric, _ := args["metric"].(string)
+	if metric == "" {
+		metric = model.GraphCentralityPageRank
+	}
+	if metric != model.GraphCentralityPageRank && metric != model.GraphCentralityDegree {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "metric must be pagerank or degree"}}, IsError: true}, nil
+	}
+
+	nodes := model.GraphCentrality(metric, graphIntArg(args, "limit", 20), graphOptions(args))
+	return graphRankedList(fmt.Sprintf("Documents ranked by %s", metric), nodes)
+}
+
+func graphBridges(args map[string]any) (CallToolResult, error) {
+	nodes := model.GraphBridgingDocs(graphIntArg(args, "limit", 20), graphOptions(args))
+	return graphRankedList("Bridging documents ranked by betweenness", nodes)
+}
+
+func graphCommunities(args map[string]any) (CallToolResult, error) {
+	communities := model.GraphCommunities(graphIntArg(args, "minSize", 2), graphIntArg(args, "limit", 10), graphOptions(args))
+	if len(communities) == 0 {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "no communities found"}}}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Communities (%d):\n", len(communities)))
+	for _, community := range communities {
+		sb.WriteString(fmt.Sprintf("\n## Community %d (%d documents)\n", community.ID, community.Size))
+		for i, node := range community.Nodes {
+			if i >= graphListLimit {
+				sb.WriteString(fmt.Sprintf("- ... %d more\n", len(community.Nodes)-i))
+				break
+			}
+			sb.WriteString("- " + formatGraphNode(node) + "\n")
+		}
+	}
+	return CallToolResult{Content: []ContentItem{{Type: "text", Text: sb.String()}}}, nil
+}
+
+func graphDocList(title string, nodes []*model.GraphAnalysisNode) (CallToolResult, error) {
+	if len(nodes) == 0 {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "no documents found"}}}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s (%d):\n\n", title, len(nodes)))
+	for i, node := range nodes {
+		if i >= graphListLimit {
+			sb.WriteString(fmt.Sprintf("- ... %d more\n", len(nodes)-i))
+			break
+		}
+		sb.WriteString("- " + formatGraphNode(node) + "\n")
+	}
+	return CallToolResult{Content: []ContentItem{{Type: "text", Text: sb.String()}}}, nil
+}
+
+func graphRankedList(title string, nodes []*model.GraphRankedNode) (CallToolResult, error) {
+	if len(nodes) == 0 {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "no documents found"}}}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s (%d):\n\n", title, len(nodes)))
+	for i, node := range nodes {
+		sb.WriteString(fmt.Sprintf("%d. %s score: %.4f, referenced by: %d, references: %d\n", i+1, formatGraphNode(node.GraphAnalysisNode), node.Score, node.InDegree, node.OutDegree))
+	}
+	return CallToolResult{Content: []ContentItem{{Type: "text", Text: sb.String()}}}, nil
+}
+
+func formatGraphNode(node *model.GraphAnalysisNode) string {
+	if node.Type == model.GraphAnalysisNodeTag {
+		return "#" + node.Label + "# (tag)"
+	}
+	return fmt.Sprintf("%s (id: %s)", node.HPath, node.ID)
+}
@@ -25,31 +25,33 @@ import (
 
 var SearchTool = &Tool{
 	Name:        "search",
-	Description: "Search. Actions: fulltext(query, page=1, pageSize=20, notebook?, path?, type?, subtype?, method?, orderBy?, groupBy?), semantic(query, page=1, pageSize=20, notebook?, path?, type?, subtype?) — semantic needs AI embedding configured; asset(query, page=1, pageSize=32, ext?, method?, orderBy?) — full-text search inside asset file contents (PDF/Word/Excel/txt etc.), returns matched snippets with <mark> tags; getasset(path) — get the full indexed content of one asset file by its path (e.g. 'assets/foo.pdf').",
+	Description: "Search. Actions: fulltext(query, page=1, pageSize=20, notebook?, path?, type?, subtype?, method?, orderBy?, groupBy?), semantic(query, page=1, pageSize=20, notebook?, path?, type?, subtype?) — semantic needs AI embedding configured; hybrid(query, page=1, pageSize=20, notebook?, path?, type?, subtype?, groupBy?) — keyword and semantic retrieval fused into one ranking, best for natural-language questions; falls back to keyword-only results when embedding is not configured; asset(query, page=1, pageSize=32, ext?, method?, orderBy?) — full-text search inside asset file contents (PDF/Word/Excel/txt etc.), returns matched snippets with <mark> tags and their location (PDF page, slide, sheet range, EPUB chapter); semanticasset(query, page=1, pageSize=32, ext?) — semantic search inside asset file contents, needs AI embedding configured; getasset(path) — get the full indexed content of one asset file by its path (e.g. 'assets/foo.pdf').",
 	InputSchema: ToolSchema{
 		Type: "object",
 		Properties: map[string]Property{
-			"action":   {Type: "string", Description: "Operation: fulltext, semantic, asset, or getasset", Enum: []string{"fulltext", "semantic", "asset", "getasset"}},
-			"query":    {Type: "string", Description: "Search keywords (required for fulltext/semantic/asset)"},
+			"action":   {Type: "string", Description: "Operation: fulltext, semantic, hybrid, asset, semanticasset, or getasset", Enum: []string{"fulltext", "semantic", "hybrid", "asset", "semanticasset", "getasset"}},
+			"query":    {Type: "string", Description: "Search keywords (required for fulltext/semantic/hybrid/asset/semanticasset)"},
 			"page":     {Type: "number", Description: "Page number (default 1)"},
-			"pageSize": {Type: "number", Description: "Results per page (default 20 for fulltext/semantic, 32 for asset)"},
-			"notebook": {Type: "string", Description: "Comma-separated notebook IDs to filter (optional, fulltext/semantic only)"},
-			"path":     {Type: "string", Description: "Comma-separated path prefixes to filter (optional, fulltext/semantic only); for getasset, a single asset file path like 'assets/foo.pdf'"},
-			"type":     {Type: "string", Description: "Comma-separated block types to filter, e.g. 'document,heading,paragraph' (optional, fulltext/semantic only)"},
-			"subtype":  {Type: "string", Description: "Comma-separated block subtypes to filter, e.g. 'o,u,t' (optional, fulltext/semantic only)"},
-			"ext":      {Type: "string", Description: "Comma-separated asset file extensions to filter, e.g. 'pdf,docx,xlsx' (optional, asset only)"},
+			"pageSize": {Type: "number", Description: "Results per page (default 20 for fulltext/semantic/hybrid, 32 for asset)"},
+			"notebook": {Type: "string", Description: "Comma-separated notebook IDs to filter (optional, fulltext/semantic/hybrid only)"},
+			"path":     {Type: "string", Description: "Comma-separated path prefixes to filter (optional, fulltext/semantic/hybrid only); for getasset, a single asset file path like 'assets/foo.pdf'"},
+			"type":     {Type: "string", Description: "Comma-separated block types to filter, e.g. 'document,heading,paragraph' (optional, fulltext/semantic/hybrid only)"},
+			"subtype":  {Type: "string", Description: "Comma-separated block subtypes to filter, e.g. 'o,u,t' (optional, fulltext/semantic/hybrid only)"},
+			"ext":      {Type: "string", Description: "Comma-separated asset file extensions to filter, e.g. 'pdf,docx,xlsx' (optional, asset/semanticasset only)"},
 			"method":   {Type: "number", Description: "Search method: fulltext/asset 0=keyword 1=query-syntax 2=sql 3=regex (default 0)"},
 			"orderBy":  {Type: "number", Description: "Sort order — fulltext: 0=type 1=created-asc 2=created-desc 3=updated-asc 4=updated-desc 5=content 6=relevance-asc 7=relevance-desc; asset: 0=relevance-desc 1=relevance-asc 2=updated-asc 3=updated-desc (default 0)"},
-			"groupBy":  {Type: "number", Description: "Group by (fulltext only): 0=none 1=document (default 0)"},
+			"groupBy":  {Type: "number", Description: "Group by (fulltext/hybrid only): 0=none 1=document (default 0)"},
 		},
 		Required: []string{"action"},
 	},
 	EffectScope: EffectScopeLocal,
 	ActionEffects: map[string]ToolEffects{
-		"fulltext": {LocalRead: true},
-		"semantic": {LocalRead: true, DataEgress: true, ExternalCost: true},
-		"asset":    {LocalRead: true},
-		"getasset": {LocalRead: true},
+		"fulltext":      {LocalRead: true},
+		"semantic":      {LocalRead: true, DataEgress: true, ExternalCost: true},
+		"hybrid":        {LocalRead: true, DataEgress: true, ExternalCost: true},
+		"asset":         {LocalRead: true},
+		"semanticasset": {LocalRead: true, DataEgress: true, ExternalCost: true},
+		"getasset":      {LocalRead: true},
 	},
 	Handler: searchHandler,
 }
@@ -65,13 +67,17 @@ func searchHandler(args map[string]any) (CallToolResult, error) {
 		return fulltextSearch(args)
 	case "semantic":
 		return semanticSearch(args)
+	case "hybrid":
+		return hybridSearch(args)
 	case "asset":
 		return assetSearch(args)
+	case "semanticasset":
+		return semanticAssetSearch(args)
 	case "getasset":
 		return getAssetHandler(args)
 	}
 	return CallToolResult{
-		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [fulltext, semantic, asset, getasset]"}},
+		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [fulltext, semantic, hybrid, asset, semanticasset, getasset]"}},
 		IsError: true,
 	}, nil
 }
@@ -109,6 +115,13 @@ func fulltextSearch(args map[string]any) (CallToolResult, error) {
 	if v, ok := args["groupBy"].(float64); ok {
 		groupBy = int(v)
 	}
+	if 5 == method {
+		// 混合搜索会把查询发送给嵌入服务，需走 hybrid 动作以便按外发数据的效果分类确认
+		return CallToolResult{
+			Content: []ContentItem{{Type: "text", Text: "method 5 (hybrid) is not available for action 'fulltext', use action 'hybrid' instead"}},
+			IsError: true,
+		}, nil
+	}
 
 	blocks, matchedCount, matchedRootCount, pageCount, docMode := model.FullTextSearchBlock(
 		query, notebooks, paths, types, subtypes, method, orderBy, groupBy, page, pageSize,
@@ -188,7 +201,7 @@ func semanticSearch(args map[string]any) (CallToolResult, error) {
 	}, nil
 }
 
-func assetSearch(args map[string]any) (CallToolResult, error) {
+func hybridSearch(args map[string]any) (CallToolResult, error) {
 	query, _ := args["query"].(string)
 	page := 1
 	if v, ok := args["page"].(float64); ok {
@@ -205,13 +218,48 @@ func assetSearch(args map[string]any) (CallToolResult, error) {
 		pageSize = 32
 	}
 
-	// ext 入参为逗号分隔的扩展名白名单，底层按 map[string]bool 接收
-	extSlice := parseStringSlice(args["ext"])
-	types := map[string]bool{}
-	for _, e := range extSlice {
-		types[e] = true
+	notebooks := parseStringSlice(args["notebook"])
+	paths := parseStringSlice(args["path"])
+	types := parseStringSet(args["type"])
+	subtypes := parseStringSet(args["subtype"])
+	groupBy := 0
+	if v, ok := args["groupBy"].(float64); ok {
+		groupBy = int(v)
+	}
+
+	blocks, matchedCount, matchedRootCount, pageCount, _ := model.FullTextSearchBlock(
+		query, notebooks, paths, types, subtypes, 5, 0, groupBy, page, pageSize,
+	)
+
+	if matchedCount == 0 {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "No results found."}}}, nil
 	}
 
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d hybrid results (page %d/%d):\n\n", matchedCount, page, pageCount))
+	for _, b := range blocks {
+		content := b.Markdown
+		if content == "" {
+			content = b.Content
+		}
+		if len(content) > 200 {
+			content = content[:200] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("- [%s] %s\n  %s\n  id: %s\n\n", b.HPath, b.Type, content, b.ID))
+	}
+	if matchedRootCount > 0 {
+		sb.WriteString(fmt.Sprintf("(%d documents matched)\n", matchedRootCount))
+	}
+	return CallToolResult{
+		Content: []ContentItem{{Type: "text", Text: sb.String()}},
+	}, nil
+}
+
+func assetSearch(args map[string]any) (CallToolResult, error) {
+	query, _ := args["query"].(string)
+	page, pageSize := parseAssetSearchPage(args)
+	types := parseAssetSearchExts(args)
+
 	method := 0
 	if v, ok := args["method"].(float64); ok {
 		method = int(v)
@@ -220,6 +268,13 @@ func assetSearch(args map[string]any) (CallToolResult, error) {
 	if v, ok := args["orderBy"].(float64); ok {
 		orderBy = int(v)
 	}
+	if 4 == method {
+		// 语义搜索会把查询发送给嵌入服务，需走 semanticasset 动作以便按外发数据的效果分类确认
+		return CallToolResult{
+			Content: []ContentItem{{Type: "text", Text: "method 4 (semantic) is not available for action 'asset', use action 'semanticasset' instead"}},
+			IsError: true,
+		}, nil
+	}
 
 	assetContents, matchedAssetCount, pageCount, err := model.FullTextSearchAssetContent(query, types, method, orderBy, page, pageSize)
 	if err != nil {
@@ -229,9 +284,55 @@ func assetSearch(args map[string]any) (CallToolResult, error) {
 	if matchedAssetCount == 0 {
 		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "No asset content results found."}}}, nil
 	}
+	return CallToolResult{
+		Content: []ContentItem{{Type: "text", Text: formatAssetSearchResult(fmt.Sprintf("Found %d asset matches (page %d/%d):\n\n", matchedAssetCount, page, pageCount), assetContents)}},
+	}, nil
+}
+
+func semanticAssetSearch(args map[string]any) (CallToolResult, error) {
+	query, _ := args["query"].(string)
+	page, pageSize := parseAssetSearchPage(args)
+	types := parseAssetSearchExts(args)
+
+	assetContents, matchedAssetCount, pageCount := model.SemanticSearchAssetContent(query, types, page, pageSize)
+	if matchedAssetCount == 0 {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "No semantic asset content results. Make sure AI embedding is configured in SiYuan settings and asset contents have been embedded."}}}, nil
+	}
+	return CallToolResult{
+		Content: []ContentItem{{Type: "text", Text: formatAssetSearchResult(fmt.Sprintf("Found %d semantic asset matches (page %d/%d):\n\n", matchedAssetCount, page, pageCount), assetContents)}},
+	}, nil
+}
+
+func parseAssetSearchPage(args map[string]any) (page, pageSize int) {
+	page = 1
+	if v, ok := args["page"].(float64); ok {
+		page = int(v)
+	}
+	pageSize = 32
+	if v, ok := args["pageSize"].(float64); ok {
+		pageSize = int(v)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 32
+	}
+	return
+}
+
+// parseAssetSearchExts 解析逗号分隔的扩展名白名单，底层按 map[string]bool 接收
+func parseAssetSearchExts(args map[string]any) map[string]bool {
+	types := map[string]bool{}
+	for _, e := range parseStringSlice(args["ext"]) {
+		types[e] = true
+	}
+	return types
+}
 
+func formatAssetSearchResult(header string, assetContents []*model.AssetContent) string {
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Found %d asset matches (page %d/%d):\n\n", matchedAssetCount, page, pageCount))
+	sb.WriteString(header)
 	for _, a := range assetContents {
 		content := a.Content
 		if len(content) > 200 {
@@ -239,13 +340,14 @@ func assetSearch(args map[string]any) (CallToolResult, error) {
 		}
 		sb.WriteString(fmt.Sprintf("- [%s] %s\n", a.Ext, a.Name))
 		sb.WriteString(fmt.Sprintf("  path: %s\n", a.Path))
+		if a.Locator != nil {
+			sb.WriteString(fmt.Sprintf("  location: %s\n", a.Locator))
+		}
 		sb.WriteString(fmt.Sprintf("  size: %s\n", a.HSize))
 		sb.WriteString(fmt.Sprintf("  content: %s\n", content))
 		sb.WriteString(fmt.Sprintf("  id: %s\n\n", a.ID))
 	}
-	return CallToolResult{
-		Content: []ContentItem{{Type: "text", Text: sb.String()}},
-	}, nil
+	return sb.String()
 }
 
 func getAssetHandler(args map[string]any) (CallToolResult, error) {
@@ -25,15 +25,22 @@ import (
 
 var TagTool = &Tool{
 	Name:        "tag",
-	Description: "Tag management. Actions: list(keyword?), rename(old, new), remove(label).",
+	Description: "Tag management. Actions: list(keyword?), rename(old, new), remove(label), meta(label, color?, icon?, description?, aliases?), merge(labels, target, dryRun?).",
 	InputSchema: ToolSchema{
 		Type: "object",
 		Properties: map[string]Property{
-			"action":  {Type: "string", Description: "Operation", Enum: []string{"list", "rename", "remove"}},
-			"keyword": {Type: "string", Description: "Search keyword (for list)"},
-			"old":     {Type: "string", Description: "Old tag label (for rename)"},
-			"new":     {Type: "string", Description: "New tag label (for rename)"},
-			"label":   {Type: "string", Description: "Tag label (for remove)"},
+			"action":      {Type: "string", Description: "Operation", Enum: []string{"list", "rename", "remove", "meta", "merge"}},
+			"keyword":     {Type: "string", Description: "Search keyword (for list)"},
+			"old":         {Type: "string", Description: "Old tag label (for rename)"},
+			"new":         {Type: "string", Description: "New tag label (for rename)"},
+			"label":       {Type: "string", Description: "Tag label (for remove/meta)"},
+			"color":       {Type: "string", Description: "Tag color (for meta)"},
+			"icon":        {Type: "string", Description: "Tag icon (for meta)"},
+			"description": {Type: "string", Description: "Tag description (for meta)"},
+			"aliases":     {Type: "array", Description: "Alias labels resolving to this tag, replaces existing aliases (for meta)", Items: &Property{Type: "string"}},
+			"labels":      {Type: "array", Description: "Tag labels to merge (for merge)", Items: &Property{Type: "string"}},
+			"target":      {Type: "string", Description: "Target tag label (for merge)"},
+			"dryRun":      {Type: "boolean", Description: "Only preview affected documents without rewriting (for merge)"},
 		},
 		Required: []string{"action"},
 	},
@@ -53,9 +60,13 @@ func tagHandler(args map[string]any) (CallToolResult, error) {
 		return tagRename(args)
 	case "remove":
 		return tagRemove(args)
+	case "meta":
+		return tagMeta(args)
+	case "merge":
+		return tagMerge(args)
 	}
 	return CallToolResult{
-		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [list, rename, remove]"}},
+		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [list, rename, remove, meta, merge]"}},
 		IsError: true,
 	}, nil
 }
@@ -102,3 +113,66 @@ func tagRemove(args map[string]any) (CallToolResult, error) {
 
 	return CallToolResult{Content: []ContentItem{{Type: "text", Text: "tag removed: #" + label}}}, nil
 }
+
+func tagMeta(args map[string]any) (CallToolResult, error) {
+	label, _ := args["label"].(string)
+	if label == "" {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "label is required"}}, IsError: true}, nil
+	}
+
+	meta := model.GetTagMetas()[label]
+	if meta == nil {
+		meta = &model.TagMeta{}
+	}
+	if v, ok := args["color"].(string); ok {
+		meta.Color = v
+	}
+	if v, ok := args["icon"].(string); ok {
+		meta.Icon = v
+	}
+	if v, ok := args["description"].(string); ok {
+		meta.Description = v
+	}
+	if v, ok := args["aliases"].([]any); ok {
+		meta.Aliases = tagStrings(v)
+	}
+
+	if err := model.SetTagMeta(label, meta); err != nil {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "set tag meta failed: " + err.Error()}}, IsError: true}, nil
+	}
+	return CallToolResult{Content: []ContentItem{{Type: "text", Text: "tag meta updated: #" + label}}}, nil
+}
+
+func tagMerge(args map[string]any) (CallToolResult, error) {
+	rawLabels, _ := args["labels"].([]any)
+	labels := tagStrings(rawLabels)
+	target, _ := args["target"].(string)
+	dryRun, _ := args["dryRun"].(bool)
+	if len(labels) == 0 || target == "" {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "labels and target are required"}}, IsError: true}, nil
+	}
+
+	result, err := model.MergeTags(labels, target, dryRun)
+	if err != nil {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "merge tags failed: " + err.Error()}}, IsError: true}, nil
+	}
+
+	var sb strings.Builder
+	if result.DryRun {
+		sb.WriteString("Preview (no changes made): ")
+	}
+	sb.WriteString(fmt.Sprintf("%d occurrence(s) in %d document(s) merged into #%s\n", result.Occurrences, len(result.Docs), result.Target))
+	for _, doc := range result.Docs {
+		sb.WriteString(fmt.Sprintf("- %s (%s): %d\n", doc.HPath, doc.ID, doc.Occurrences))
+	}
+	return CallToolResult{Content: []ContentItem{{Type: "text", Text: sb.String()}}}, nil
+}
+
+func tagStrings(values []any) (ret []string) {
+	for _, v := range values {
+		if str, ok := v.(string); ok && str != "" {
+			ret = append(ret, str)
+		}
+	}
+	return
+}
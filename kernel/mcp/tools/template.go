@@ -28,13 +28,14 @@ import (
 
 var TemplateTool = &Tool{
 	Name:        "template",
-	Description: "Template management. Actions: search(keyword?), get(path), remove(path), render(path, id), save_as(id, name, overwrite?), create(name, content, overwrite?).",
+	Description: "Template management. Actions: search(keyword?), get(path), remove(path), params(path), render(path, id, params?), save_as(id, name, overwrite?), create(name, content, overwrite?).",
 	InputSchema: ToolSchema{
 		Type: "object",
 		Properties: map[string]Property{
-			"action":    {Type: "string", Description: "Operation", Enum: []string{"search", "get", "remove", "render", "save_as", "create"}},
+			"action":    {Type: "string", Description: "Operation", Enum: []string{"search", "get", "remove", "params", "render", "save_as", "create"}},
 			"keyword":   {Type: "string", Description: "Search keyword; empty lists all (for search)"},
-			"path":      {Type: "string", Description: "Template file path as returned by search (for get, remove, render)"},
+			"path":      {Type: "string", Description: "Template file path as returned by search (for get, remove, params, render)"},
+			"params":    {Type: "object", Description: "Values of the parameters declared by the template, keyed by parameter name (for render)"},
 			"id":        {Type: "string", Description: "Block ID (for render, save_as)"},
 			"name":      {Type: "string", Description: "Template name without extension (for save_as, create)"},
 			"content":   {Type: "string", Description: "Markdown content (for create)"},
@@ -58,6 +59,8 @@ func templateHandler(args map[string]any) (CallToolResult, error) {
 		return templateGet(args)
 	case "remove":
 		return templateRemove(args)
+	case "params":
+		return templateParams(args)
 	case "render":
 		return templateRender(args)
 	case "save_as":
@@ -66,7 +69,7 @@ func templateHandler(args map[string]any) (CallToolResult, error) {
 		return templateCreate(args)
 	}
 	return CallToolResult{
-		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [search, get, remove, render, save_as, create]"}},
+		Content: []ContentItem{{Type: "text", Text: "unknown action '" + action + "', expected one of: [search, get, remove, params, render, save_as, create]"}},
 		IsError: true,
 	}, nil
 }
@@ -129,6 +132,49 @@ func templateRemove(args map[string]any) (CallToolResult, error) {
 	return CallToolResult{Content: []ContentItem{{Type: "text", Text: "template removed: " + p}}}, nil
 }
 
+func templateParams(args map[string]any) (CallToolResult, error) {
+	p, _ := args["path"].(string)
+	abs, err := resolveTemplatePath(p)
+	if err != nil {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+
+	params, err := model.GetTemplateParams(abs)
+	if err != nil {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "get template params failed: " + err.Error()}}, IsError: true}, nil
+	}
+	if len(params) == 0 {
+		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "template declares no parameters"}}}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Parameters (%d):\n\n", len(params)))
+	for _, param := range params {
+		sb.WriteString(fmt.Sprintf("- %s (%s", param.Name, param.Type))
+		if param.Required {
+			sb.WriteString(", required")
+		}
+		sb.WriteString(")")
+		if param.Label != "" {
+			sb.WriteString(": " + param.Label)
+		}
+		sb.WriteString("\n")
+		if param.Description != "" {
+			sb.WriteString("  description: " + param.Description + "\n")
+		}
+		if param.Default != "" {
+			sb.WriteString("  default: " + param.Default + "\n")
+		}
+		if len(param.Options) > 0 {
+			sb.WriteString("  options: " + strings.Join(param.Options, ", ") + "\n")
+		}
+		if param.AV != "" {
+			sb.WriteString("  database: " + param.AV + "\n")
+		}
+	}
+	return CallToolResult{Content: []ContentItem{{Type: "text", Text: sb.String()}}}, nil
+}
+
 func templateRender(args map[string]any) (CallToolResult, error) {
 	p, _ := args["path"].(string)
 	id, _ := args["id"].(string)
@@ -140,7 +186,16 @@ func templateRender(args map[string]any) (CallToolResult, error) {
 		return CallToolResult{Content: []ContentItem{{Type: "text", Text: err.Error()}}, IsError: true}, nil
 	}
 
-	_, dom, err := model.RenderTemplate(abs, id, true)
+	params := map[string]string{}
+	if rawParams, ok := args["params"].(map[string]any); ok {
+		for k, v := range rawParams {
+			if v != nil {
+				params[k] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	_, dom, err := model.RenderTemplate(abs, id, params, true)
 	if err != nil {
 		return CallToolResult{Content: []ContentItem{{Type: "text", Text: "render template failed: " + err.Error()}}, IsError: true}, nil
 	}
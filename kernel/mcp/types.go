@@ -52,13 +52,74 @@ type RpcError struct {
 }
 
 type ServerCapabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools     *ToolsCapability     `json:"tools,omitempty"`
+	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
 }
 
 type ToolsCapability struct {
 	ListChanged bool `json:"listChanged"`
 }
 
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe"`
+	ListChanged bool `json:"listChanged"`
+}
+
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged"`
+}
+
+type Resource struct {
+	URI         string               `json:"uri"`
+	Name        string               `json:"name"`
+	Title       string               `json:"title,omitempty"`
+	Description string               `json:"description,omitempty"`
+	MimeType    string               `json:"mimeType,omitempty"`
+	Annotations *ResourceAnnotations `json:"annotations,omitempty"`
+}
+
+type ResourceAnnotations struct {
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+type Prompt struct {
+	Name        string            `json:"name"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Arguments   []*PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+type PromptMessage struct {
+	Role    string               `json:"role"`
+	Content PromptMessageContent `json:"content"`
+}
+
+type PromptMessageContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
 type ServerInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -31,6 +31,7 @@ import (
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/agent"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/job"
 	"github.com/siyuan-note/siyuan/kernel/model"
@@ -223,6 +224,9 @@ func StartKernel(container, appDir, workspaceBaseDir, timezoneID, localIPs, lang
 		model.InitBoxes()
 		model.LoadFlashcards()
 		util.LoadAssetsTexts()
+		model.LoadUndoLog()
+		model.InitWebhooks()
+		agent.InitAutomations()
 
 		util.SetBooted()
 		util.PushClearAllMsg()
@@ -106,8 +106,13 @@ func chatGPTComplete(msg string, contextMsgs []string, cloud bool) (ret string,
 	if cloud {
 		gpt = &CloudGPT{}
 	} else {
+		if err = CheckAIBudget(m.Name); nil != err {
+			util.PushErrMsg(err.Error(), 5000)
+			return
+		}
 		gpt = &OpenAIGPT{
-			c:                   util.NewOpenAIClientWithModel(prov.APIKey, prov.BaseURL, m.Name),
+			c:                   util.NewAIClient(prov.Protocol, prov.APIKey, prov.BaseURL, m.Name),
+			p:                   prov,
 			m:                   m,
 			timeout:             prov.RequestTimeout,
 			maxCompletionTokens: editing.MaxCompletionTokens,
@@ -190,6 +195,7 @@ type GPT interface {
 
 type OpenAIGPT struct {
 	c                   *openai.Client
+	p                   *conf.Provider
 	m                   *conf.Model
 	timeout             int
 	maxCompletionTokens int
@@ -197,7 +203,11 @@ type OpenAIGPT struct {
 }
 
 func (gpt *OpenAIGPT) chat(msg string, contextMsgs []string) (partRet string, stop bool, err error) {
-	return util.ChatGPT(msg, contextMsgs, gpt.c, gpt.m.Name, gpt.maxCompletionTokens, gpt.temperature, gpt.timeout)
+	partRet, stop, usage, err := util.ChatGPT(msg, contextMsgs, gpt.c, gpt.m.Name, gpt.maxCompletionTokens, gpt.temperature, gpt.timeout)
+	if nil == err {
+		RecordAIUsage(AIFeatureEditing, AIProviderName(gpt.p), gpt.m.Name, "", usage)
+	}
+	return
 }
 
 type CloudGPT struct {
@@ -0,0 +1,441 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 记录用量的 AI 功能。
+const (
+	AIFeatureAgent           = "agent"
+	AIFeatureEditing         = "editing"
+	AIFeatureTitle           = "title"
+	AIFeatureEmbedding       = "embedding"
+	AIFeatureRerank          = "rerank"
+	AIFeatureVision          = "vision"
+	AIFeatureImageGeneration = "imageGeneration"
+)
+
+var ErrAIBudgetExceeded = errors.New("AI monthly budget exceeded")
+
+// AIUsageRecord 是用量账本中的一条记录，按天、功能、服务商、模型和会话聚合。
+type AIUsageRecord struct {
+	Day              string `json:"day"` // 2006-01-02
+	Feature          string `json:"feature"`
+	Provider         string `json:"provider"`
+	Model            string `json:"model"`
+	Session          string `json:"session,omitempty"` // Agent 会话 ID
+	Requests         int    `json:"requests"`
+	PromptTokens     int    `json:"promptTokens"`
+	CompletionTokens int    `json:"completionTokens"`
+	Images           int    `json:"images,omitempty"`
+}
+
+func (record *AIUsageRecord) key() string {
+	return record.Day + "\x00" + record.Feature + "\x00" + record.Provider + "\x00" + record.Model + "\x00" + record.Session
+}
+
+func (record *AIUsageRecord) add(other *AIUsageRecord) {
+	record.Requests += other.Requests
+	record.PromptTokens += other.PromptTokens
+	record.CompletionTokens += other.CompletionTokens
+	record.Images += other.Images
+}
+
+// cost 按当前配置的单价计算费用，未配置单价的模型费用为 0。
+func (record *AIUsageRecord) cost(usage *conf.Usage) float64 {
+	price := usage.Price(record.Model)
+	if nil == price {
+		return 0
+	}
+	return float64(record.PromptTokens)/1000000*price.Input + float64(record.CompletionTokens)/1000000*price.Output + float64(record.Images)*price.Image
+}
+
+var (
+	aiUsageLock    = sync.Mutex{}
+	aiUsageWarned  = map[string]bool{}                      // 本月已提示过的 warn 预算
+	aiUsagePending = map[string][]*AIUsageRecord{}          // 尚未写入账本的用量，键为月份
+	aiUsageTotals  = map[string]map[string]*AIUsageRecord{} // 所有设备按模型汇总的月度用量，用于预算检查，键为月份和模型
+)
+
+// RecordAIUsage 记录一次 AI 调用的用量。用量先缓存在内存中，由 FlushAIUsageJob 定期按设备分文件写入 data/storage/ai/usage/<月份>/，
+// 避免每次调用都产生同步变更，也避免多设备同步时互相覆盖；超出 warn 预算时提示一次。
+func RecordAIUsage(feature, provider, model, session string, usage util.AIUsage) {
+	if nil == Conf || nil == Conf.AI || "" == model {
+		return
+	}
+
+	now := time.Now()
+	record := &AIUsageRecord{
+		Day:              now.Format("2006-01-02"),
+		Feature:          feature,
+		Provider:         provider,
+		Model:            model,
+		Session:          session,
+		Requests:         1,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Images:           usage.Images,
+	}
+
+	month := now.Format("2006-01")
+	aiUsageLock.Lock()
+	aiUsagePending[month] = mergeAIUsageRecord(aiUsagePending[month], record)
+	if totals := aiUsageTotals[month]; nil != totals {
+		addAIUsageTotal(totals, month, record)
+	}
+	aiUsageLock.Unlock()
+	checkAIBudget(model, false)
+}
+
+// FlushAIUsageJob 把内存中缓存的用量写入账本。
+func FlushAIUsageJob() {
+	flushAIUsage()
+}
+
+func flushAIUsage() {
+	aiUsageLock.Lock()
+	defer aiUsageLock.Unlock()
+
+	for month, records := range aiUsagePending {
+		if err := writeAIUsageRecords(aiUsageLedgerPath(month), records); nil != err {
+			// 写入失败时保留在内存中，下次再试
+			continue
+		}
+		delete(aiUsagePending, month)
+	}
+}
+
+func mergeAIUsageRecord(records []*AIUsageRecord, record *AIUsageRecord) []*AIUsageRecord {
+	for _, existing := range records {
+		if existing.key() == record.key() {
+			existing.add(record)
+			return records
+		}
+	}
+	merged := *record
+	return append(records, &merged)
+}
+
+// addAIUsageTotal 把记录累加到按模型汇总的月度用量。
+func addAIUsageTotal(totals map[string]*AIUsageRecord, month string, record *AIUsageRecord) {
+	total := totals[record.Model]
+	if nil == total {
+		total = &AIUsageRecord{Day: month, Model: record.Model}
+		totals[record.Model] = total
+	}
+	total.add(record)
+}
+
+// writeAIUsageRecords 把记录合并到账本文件，调用方需持有 aiUsageLock。
+// 账本按天聚合，体积很小，每次直接读改写，内核与命令行同时写入时也不会丢失记录。
+func writeAIUsageRecords(ledgerPath string, records []*AIUsageRecord) (err error) {
+	ledger := loadAIUsageLedger(ledgerPath)
+	for _, record := range records {
+		ledger = mergeAIUsageRecord(ledger, record)
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(ledger, "", "  ")
+	if nil != err {
+		logging.LogErrorf("marshal AI usage ledger failed: %s", err)
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(ledgerPath), 0755); nil != err {
+		logging.LogErrorf("create AI usage ledger dir failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(ledgerPath, data); nil != err {
+		logging.LogErrorf("write AI usage ledger failed: %s", err)
+	}
+	return
+}
+
+// CheckAIBudget 在发起 AI 请求前检查月度预算。超出 block 预算时返回 ErrAIBudgetExceeded，超出 warn 预算时每月提示一次后放行。
+func CheckAIBudget(model string) error {
+	return checkAIBudget(model, true)
+}
+
+func checkAIBudget(model string, block bool) error {
+	if nil == Conf || nil == Conf.AI || nil == Conf.AI.Usage || 1 > len(Conf.AI.Usage.Budgets) {
+		return nil
+	}
+
+	month := time.Now().Format("2006-01")
+	for _, status := range aiBudgetStatuses(Conf.AI.Usage, month, aiUsageMonthTotals(month)) {
+		if !status.Exceeded || ("" != status.Model && !strings.EqualFold(status.Model, model)) {
+			continue
+		}
+		if conf.BudgetActionBlock == status.Action {
+			if block {
+				logging.LogWarnf("AI budget [%s %.2f] exceeded, blocked request to model [%s]", status.Model, status.Amount, model)
+				return fmt.Errorf("%w: %.2f/%.2f %s", ErrAIBudgetExceeded, status.Spent, status.Amount, Conf.AI.Usage.Currency)
+			}
+			continue
+		}
+
+		warnKey := month + "\x00" + status.Model + "\x00" + fmt.Sprint(status.Amount)
+		aiUsageLock.Lock()
+		warned := aiUsageWarned[warnKey]
+		aiUsageWarned[warnKey] = true
+		aiUsageLock.Unlock()
+		if !warned {
+			scope := status.Model
+			if "" == scope {
+				scope = "all models"
+			}
+			util.PushMsg(fmt.Sprintf("AI monthly budget exceeded (%s): %.2f/%.2f %s", scope, status.Spent, status.Amount, Conf.AI.Usage.Currency), 7000)
+		}
+	}
+	return nil
+}
+
+// AIUsageGroup 是用量报告中的一个分组汇总。
+type AIUsageGroup struct {
+	Key              string  `json:"key"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	Images           int     `json:"images"`
+	Cost             float64 `json:"cost"`
+}
+
+// AIBudgetStatus 是某项月度预算的使用情况。
+type AIBudgetStatus struct {
+	Model    string  `json:"model"`
+	Amount   float64 `json:"amount"`
+	Action   string  `json:"action"`
+	Spent    float64 `json:"spent"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+type AIUsageReport struct {
+	Month    string            `json:"month"`
+	GroupBy  string            `json:"groupBy"`
+	Currency string            `json:"currency"`
+	Total    *AIUsageGroup     `json:"total"`
+	Groups   []*AIUsageGroup   `json:"groups"`
+	Budgets  []*AIBudgetStatus `json:"budgets"`
+}
+
+// GetAIUsageReport 汇总指定月份（2006-01，为空时为本月）所有设备的用量。
+// groupBy 支持 day、feature、provider、model 和 session，默认按模型分组，分组按费用和 token 数降序排列。
+func GetAIUsageReport(month, groupBy string) (ret *AIUsageReport, err error) {
+	if "" == month {
+		month = time.Now().Format("2006-01")
+	}
+	if _, err = time.Parse("2006-01", month); nil != err {
+		err = fmt.Errorf("invalid month [%s], expected YYYY-MM", month)
+		return
+	}
+	if "" == groupBy {
+		groupBy = "model"
+	}
+	groupKey := aiUsageGroupKeys[groupBy]
+	if nil == groupKey {
+		err = fmt.Errorf("invalid group [%s]", groupBy)
+		return
+	}
+
+	usage := Conf.AI.Usage
+	records := loadAIUsageMonth(month)
+	ret = &AIUsageReport{Month: month, GroupBy: groupBy, Currency: usage.Currency, Total: &AIUsageGroup{}, Groups: []*AIUsageGroup{}}
+	groups := map[string]*AIUsageGroup{}
+	for _, record := range records {
+		key := groupKey(record)
+		group := groups[key]
+		if nil == group {
+			group = &AIUsageGroup{Key: key}
+			groups[key] = group
+			ret.Groups = append(ret.Groups, group)
+		}
+		cost := record.cost(usage)
+		for _, g := range []*AIUsageGroup{group, ret.Total} {
+			g.Requests += record.Requests
+			g.PromptTokens += record.PromptTokens
+			g.CompletionTokens += record.CompletionTokens
+			g.Images += record.Images
+			g.Cost += cost
+		}
+	}
+	slices.SortStableFunc(ret.Groups, func(a, b *AIUsageGroup) int {
+		if "day" == groupBy {
+			return strings.Compare(a.Key, b.Key)
+		}
+		if a.Cost != b.Cost {
+			if a.Cost > b.Cost {
+				return -1
+			}
+			return 1
+		}
+		return (b.PromptTokens + b.CompletionTokens) - (a.PromptTokens + a.CompletionTokens)
+	})
+	ret.Budgets = aiBudgetStatuses(usage, month, records)
+	return
+}
+
+var aiUsageGroupKeys = map[string]func(record *AIUsageRecord) string{
+	"day":      func(record *AIUsageRecord) string { return record.Day },
+	"feature":  func(record *AIUsageRecord) string { return record.Feature },
+	"provider": func(record *AIUsageRecord) string { return record.Provider },
+	"model":    func(record *AIUsageRecord) string { return record.Model },
+	"session":  func(record *AIUsageRecord) string { return record.Session },
+}
+
+func aiBudgetStatuses(usage *conf.Usage, month string, records []*AIUsageRecord) (ret []*AIBudgetStatus) {
+	ret = []*AIBudgetStatus{}
+	if nil == usage {
+		return
+	}
+	for _, budget := range usage.Budgets {
+		status := &AIBudgetStatus{Model: budget.Model, Amount: budget.Amount, Action: budget.Action}
+		for _, record := range records {
+			if !strings.HasPrefix(record.Day, month) {
+				continue
+			}
+			if "" == budget.Model || strings.EqualFold(budget.Model, record.Model) {
+				status.Spent += record.cost(usage)
+			}
+		}
+		status.Exceeded = status.Spent >= status.Amount
+		ret = append(ret, status)
+	}
+	return
+}
+
+// aiUsageMonthTotals 返回某月所有设备按模型汇总的用量。汇总结果缓存在内存中，同步拉取到其它设备的账本后清空缓存。
+func aiUsageMonthTotals(month string) (ret []*AIUsageRecord) {
+	aiUsageLock.Lock()
+	defer aiUsageLock.Unlock()
+
+	totals := aiUsageTotals[month]
+	if nil == totals {
+		totals = map[string]*AIUsageRecord{}
+		for _, record := range loadAIUsageMonth0(month) {
+			addAIUsageTotal(totals, month, record)
+		}
+		clear(aiUsageTotals)
+		aiUsageTotals[month] = totals
+	}
+	for _, total := range totals {
+		copied := *total
+		ret = append(ret, &copied)
+	}
+	return
+}
+
+// resetAIUsageTotals 清空月度用量汇总缓存。
+func resetAIUsageTotals() {
+	aiUsageLock.Lock()
+	defer aiUsageLock.Unlock()
+	clear(aiUsageTotals)
+}
+
+// loadAIUsageMonth 加载某月所有设备的账本，包括本机尚未写入账本的用量。
+func loadAIUsageMonth(month string) (ret []*AIUsageRecord) {
+	aiUsageLock.Lock()
+	defer aiUsageLock.Unlock()
+	return loadAIUsageMonth0(month)
+}
+
+func loadAIUsageMonth0(month string) (ret []*AIUsageRecord) {
+	for _, record := range aiUsagePending[month] {
+		copied := *record
+		ret = append(ret, &copied)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(aiUsageDir(), month))
+	if nil != err {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || ".json" != filepath.Ext(entry.Name()) {
+			continue
+		}
+		ret = append(ret, loadAIUsageLedger(filepath.Join(aiUsageDir(), month, entry.Name()))...)
+	}
+	return
+}
+
+func loadAIUsageLedger(ledgerPath string) (ret []*AIUsageRecord) {
+	ret = []*AIUsageRecord{}
+	if !filelock.IsExist(ledgerPath) {
+		return
+	}
+	data, err := filelock.ReadFile(ledgerPath)
+	if nil != err {
+		logging.LogErrorf("read AI usage ledger [%s] failed: %s", ledgerPath, err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal AI usage ledger [%s] failed: %s", ledgerPath, err)
+		return []*AIUsageRecord{}
+	}
+	return
+}
+
+func aiUsageDir() string {
+	return filepath.Join(util.DataDir, "storage", "ai", "usage")
+}
+
+func aiUsageLedgerPath(month string) string {
+	deviceID := util.GetDeviceName()
+	if nil != Conf && nil != Conf.System && "" != Conf.System.ID {
+		deviceID = Conf.System.ID
+	}
+	return filepath.Join(aiUsageDir(), month, deviceID+".json")
+}
+
+// aiProviderName 返回账本中记录的服务商名称，未设置显示名称时使用接口地址的主机名。
+func aiProviderName(displayName, baseURL string) string {
+	if "" != displayName {
+		return displayName
+	}
+	if u, err := url.Parse(baseURL); nil == err && "" != u.Host {
+		return u.Host
+	}
+	return baseURL
+}
+
+// AIProviderName 返回服务商在用量账本中的名称。
+func AIProviderName(provider *conf.Provider) string {
+	return aiProviderName(provider.DisplayName, provider.BaseURL)
+}
+
+// SetAIUsage 更新用量计价与预算配置。
+func SetAIUsage(usage *conf.Usage) *conf.Usage {
+	Conf.m.Lock()
+	Conf.AI.Usage = usage
+	Conf.AI.Normalize()
+	usage = Conf.AI.Usage
+	Conf.m.Unlock()
+	Conf.Save()
+	return usage
+}
@@ -0,0 +1,158 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func setupAIUsageTest(t *testing.T) {
+	originalConf, originalDataDir := Conf, util.DataDir
+	t.Cleanup(func() {
+		Conf, util.DataDir = originalConf, originalDataDir
+		clear(aiUsagePending)
+		clear(aiUsageTotals)
+	})
+	clear(aiUsagePending)
+	clear(aiUsageTotals)
+	util.DataDir = t.TempDir()
+	Conf = NewAppConf()
+	Conf.AI = conf.NewAI()
+	Conf.AI.Usage.Prices = []*conf.ModelPrice{{Model: "gpt-4o", Input: 2, Output: 10}, {Model: "image-model", Image: 0.5}}
+}
+
+func TestRecordAIUsageAggregatesAndReports(t *testing.T) {
+	setupAIUsageTest(t)
+
+	RecordAIUsage(AIFeatureAgent, "OpenAI", "gpt-4o", "session-1", util.AIUsage{PromptTokens: 1000000, CompletionTokens: 100000})
+	RecordAIUsage(AIFeatureAgent, "OpenAI", "GPT-4o", "session-1", util.AIUsage{PromptTokens: 500000})
+	RecordAIUsage(AIFeatureAgent, "OpenAI", "gpt-4o", "session-1", util.AIUsage{PromptTokens: 500000})
+	RecordAIUsage(AIFeatureImageGeneration, "OpenAI", "image-model", "", util.AIUsage{Images: 1})
+	RecordAIUsage(AIFeatureEmbedding, "api.example.com", "embed", "", util.AIUsage{PromptTokens: 100})
+
+	month := time.Now().Format("2006-01")
+	if ledger := loadAIUsageLedger(aiUsageLedgerPath(month)); len(ledger) != 0 {
+		t.Fatalf("用量应先缓存在内存中，实际已写入 %d 条", len(ledger))
+	}
+	flushAIUsage()
+	ledger := loadAIUsageLedger(aiUsageLedgerPath(month))
+	if len(ledger) != 4 {
+		t.Fatalf("账本应按天、功能、服务商、模型和会话聚合，实际 %d 条", len(ledger))
+	}
+
+	// 其它设备的账本一并统计
+	other := filepath.Join(aiUsageDir(), month, "other-device.json")
+	if err := os.WriteFile(other, []byte(`[{"day":"`+month+`-01","feature":"editing","provider":"OpenAI","model":"gpt-4o","requests":1,"promptTokens":1000000,"completionTokens":0}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := GetAIUsageReport("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Requests != 6 || report.Total.Images != 1 || report.Total.PromptTokens != 3000100 {
+		t.Fatalf("汇总错误：%+v", report.Total)
+	}
+	// gpt-4o: 2M 输入 * 2 + 0.1M 输出 * 10 + 其它设备 1M 输入 * 2 = 7；图片 0.5
+	if report.Total.Cost != 7.5 {
+		t.Fatalf("费用错误：%v", report.Total.Cost)
+	}
+	if report.Groups[0].Key != "gpt-4o" || report.Groups[0].Cost != 6 {
+		t.Fatalf("分组应按费用降序：%+v", report.Groups[0])
+	}
+
+	report, err = GetAIUsageReport(month, "feature")
+	if err != nil || len(report.Groups) != 4 {
+		t.Fatalf("按功能分组错误：%v %+v", err, report)
+	}
+	if _, err = GetAIUsageReport("2026/01", ""); err == nil {
+		t.Fatal("非法月份应返回错误")
+	}
+	if _, err = GetAIUsageReport("", "unknown"); err == nil {
+		t.Fatal("非法分组应返回错误")
+	}
+}
+
+func TestCheckAIBudget(t *testing.T) {
+	setupAIUsageTest(t)
+	Conf.AI.Usage.Budgets = []*conf.Budget{
+		{Model: "gpt-4o", Amount: 1, Action: conf.BudgetActionBlock},
+		{Amount: 0.5, Action: conf.BudgetActionWarn},
+	}
+
+	if err := CheckAIBudget("gpt-4o"); err != nil {
+		t.Fatalf("未超出预算时不应拦截：%v", err)
+	}
+	RecordAIUsage(AIFeatureAgent, "OpenAI", "gpt-4o", "", util.AIUsage{PromptTokens: 600000})
+	if err := CheckAIBudget("gpt-4o"); !errors.Is(err, ErrAIBudgetExceeded) {
+		t.Fatalf("超出 block 预算时应拦截：%v", err)
+	}
+	if err := CheckAIBudget("other-model"); err != nil {
+		t.Fatalf("warn 预算和其它模型的 block 预算不应拦截：%v", err)
+	}
+
+	report, err := GetAIUsageReport("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Budgets) != 2 || !report.Budgets[0].Exceeded || report.Budgets[0].Spent != 1.2 || !report.Budgets[1].Exceeded {
+		t.Fatalf("预算状态错误：%+v %+v", report.Budgets[0], report.Budgets[1])
+	}
+}
+
+func TestAIUsageBufferedAndCached(t *testing.T) {
+	setupAIUsageTest(t)
+	Conf.AI.Usage.Budgets = []*conf.Budget{{Model: "gpt-4o", Amount: 1, Action: conf.BudgetActionBlock}}
+
+	month := time.Now().Format("2006-01")
+	RecordAIUsage(AIFeatureAgent, "OpenAI", "gpt-4o", "", util.AIUsage{PromptTokens: 100000})
+	report, err := GetAIUsageReport("", "")
+	if err != nil || report.Total.Requests != 1 {
+		t.Fatalf("报告应包含尚未写入账本的用量：%v %+v", err, report)
+	}
+
+	flushAIUsage()
+	RecordAIUsage(AIFeatureAgent, "OpenAI", "gpt-4o", "", util.AIUsage{PromptTokens: 100000})
+	flushAIUsage()
+	ledger := loadAIUsageLedger(aiUsageLedgerPath(month))
+	if len(ledger) != 1 || ledger[0].Requests != 2 || len(aiUsagePending) != 0 {
+		t.Fatalf("多次写入应合并到同一条账本记录：%+v", ledger)
+	}
+
+	// 预算检查使用内存中的汇总，不再重新读取账本
+	if err = CheckAIBudget("gpt-4o"); err != nil {
+		t.Fatalf("未超出预算时不应拦截：%v", err)
+	}
+	other := filepath.Join(aiUsageDir(), month, "other-device.json")
+	if err = os.WriteFile(other, []byte(`[{"day":"`+month+`-01","feature":"editing","provider":"OpenAI","model":"gpt-4o","requests":1,"promptTokens":1000000,"completionTokens":0}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckAIBudget("gpt-4o"); err != nil {
+		t.Fatalf("汇总缓存未清空前不应读取账本：%v", err)
+	}
+	resetAIUsageTotals()
+	if err = CheckAIBudget("gpt-4o"); !errors.Is(err, ErrAIBudgetExceeded) {
+		t.Fatalf("同步拉取其它设备的账本后应重新汇总：%v", err)
+	}
+}
@@ -0,0 +1,535 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+const (
+	APITokenContextKey = "apiToken"
+
+	apiTokenLastUsedSaveInterval = 10 * time.Minute
+)
+
+var (
+	ErrAPITokenNotFound    = errors.New("API token not found")
+	ErrAPITokenExpired     = errors.New("API token expired")
+	ErrAPITokenRevoked     = errors.New("API token revoked")
+	ErrAPITokenInvalidRole = errors.New("invalid API token role")
+
+	apiTokenLock = sync.RWMutex{}
+
+	// 最近使用时间先记在内存中（token ID -> 毫秒时间戳），按间隔合并到配置后落盘，避免每次请求都加写锁和保存配置
+	apiTokenLastUsed      = sync.Map{}
+	apiTokenLastUsedSaved atomic.Int64
+
+	// 具名 token 不能管理 token，避免受限 token 自行提权
+	apiTokenForbiddenRoutes = []string{
+		"/api/system/setAPIToken", "/api/setting/getAPITokens", "/api/setting/createAPIToken",
+		"/api/setting/updateAPIToken", "/api/setting/revokeAPIToken", "/api/setting/removeAPIToken",
+	}
+
+	// 非管理员角色的具名 token 和限定笔记本的具名 token 可以访问的路由，未列出的路由一律拒绝
+	apiTokenRoutes = map[string]*apiTokenRoute{
+		"/api/system/version":     {role: RoleReader, scope: apiTokenScopeNone},
+		"/api/system/currentTime": {role: RoleReader, scope: apiTokenScopeNone},
+		"/api/lute/md2html":       {role: RoleReader, scope: apiTokenScopeNone},
+
+		"/api/notebook/lsNotebooks":          {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/search/fullTextSearchBlock":    {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/search/searchRefBlock":         {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/search/semanticSearchBlock":    {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/block/getRecentUpdatedBlocks":  {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/tag/getTag":                    {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/bookmark/getBookmark":          {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/graph/getGraph":                {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/av/renderAttributeView":        {role: RoleReader, scope: apiTokenScopeAll},
+		"/api/av/getAttributeViewKeysByAvID": {role: RoleReader, scope: apiTokenScopeAll},
+
+		"/api/notebook/getNotebookConf":  {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/notebook/getNotebookInfo":  {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/filetree/listDocsByPath":   {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/filetree/getHPathByPath":   {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/filetree/getIDsByHPath":    {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/filetree/getDoc":           {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"id", "startID", "endID"}},
+		"/api/filetree/getHPathByID":     {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/filetree/getPathByID":      {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/filetree/getFullHPathByID": {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/getBlockInfo":        {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"id"}},
+		"/api/block/getBlockDOM":         {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/getBlockDOMs":        {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"ids"}},
+		"/api/block/getBlockKramdown":    {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/getBlockKramdowns":   {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"ids"}},
+		"/api/block/getChildBlocks":      {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/getTailChildBlocks":  {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/getBlockBreadcrumb":  {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"id"}},
+		"/api/block/getBlockIndex":       {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/getDocInfo":          {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"id"}},
+		"/api/block/getDocsInfo":         {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"ids"}},
+		"/api/block/getRefText":          {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"id"}},
+		"/api/block/getTreeStat":         {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/getBlocksWordCount":  {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"ids"}},
+		"/api/outline/getDocOutline":     {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"id"}},
+		"/api/ref/getBacklink":           {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"id"}},
+		"/api/ref/getBacklink2":          {role: RoleReader, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"id"}},
+		"/api/attr/getBlockAttrs":        {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/attr/batchGetBlockAttrs":   {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"ids"}},
+		"/api/graph/getLocalGraph":       {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/export/exportMdContent":    {role: RoleReader, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+
+		"/api/transactions":                 {role: RoleEditor, scope: apiTokenScopeAll},
+		"/api/asset/upload":                 {role: RoleEditor, scope: apiTokenScopeAll},
+		"/api/av/setAttributeViewBlockAttr": {role: RoleEditor, scope: apiTokenScopeAll},
+		"/api/filetree/createDocWithMd":     {role: RoleEditor, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}, blockArgs: []string{"parentID"}},
+		"/api/filetree/createDoc":           {role: RoleEditor, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/filetree/createDailyNote":     {role: RoleEditor, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/filetree/renameDoc":           {role: RoleEditor, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/filetree/removeDoc":           {role: RoleEditor, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/filetree/renameDocByID":       {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/filetree/removeDocByID":       {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/insertBlock":            {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"parentID", "previousID", "nextID"}},
+		"/api/block/prependBlock":           {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"parentID"}},
+		"/api/block/appendBlock":            {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"parentID"}},
+		"/api/block/updateBlock":            {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/deleteBlock":            {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/moveBlock":              {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"id", "parentID", "previousID"}},
+		"/api/block/foldBlock":              {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/unfoldBlock":            {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+		"/api/block/appendDailyNoteBlock":   {role: RoleEditor, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/block/prependDailyNoteBlock":  {role: RoleEditor, scope: apiTokenScopeArgs, boxArgs: []string{"notebook"}},
+		"/api/attr/setBlockAttrs":           {role: RoleEditor, scope: apiTokenScopeArgs, blockArgs: []string{"id"}},
+	}
+)
+
+// apiTokenBoxScope 描述具名 token 访问路由时如何确定请求涉及的笔记本。
+type apiTokenBoxScope int
+
+const (
+	apiTokenScopeNone apiTokenBoxScope = iota // 不涉及笔记本数据
+	apiTokenScopeArgs                         // 通过请求参数中的笔记本 ID 和块 ID 确定笔记本
+	apiTokenScopeAll                          // 涉及所有笔记本，限定笔记本的 token 不能访问
+)
+
+// apiTokenRoute 为具名 token 可访问路由的授权规则。
+type apiTokenRoute struct {
+	role      Role             // 访问该路由所需的最低角色
+	scope     apiTokenBoxScope // 笔记本范围的确定方式
+	boxArgs   []string         // 请求参数中表示笔记本 ID 的字段
+	blockArgs []string         // 请求参数中表示块 ID 的字段，块 ID 通过块树映射到所在笔记本
+}
+
+// APITokenInfo 为具名 API token 的展示信息，不包含 token 摘要。
+type APITokenInfo struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Hint        string   `json:"hint"`
+	Role        string   `json:"role"`
+	Boxes       []string `json:"boxes"`
+	RouteGroups []string `json:"routeGroups"`
+	Created     int64    `json:"created"`
+	Expired     int64    `json:"expired"`
+	LastUsed    int64    `json:"lastUsed"`
+	Revoked     bool     `json:"revoked"`
+}
+
+func newAPITokenInfo(token *conf.APIToken) *APITokenInfo {
+	return &APITokenInfo{
+		ID:          token.ID,
+		Name:        token.Name,
+		Hint:        token.Hint,
+		Role:        token.Role,
+		Boxes:       token.Boxes,
+		RouteGroups: token.RouteGroups,
+		Created:     token.Created,
+		Expired:     token.Expired,
+		LastUsed:    apiTokenLastUsedTime(token),
+		Revoked:     token.Revoked,
+	}
+}
+
+func ListAPITokens() (ret []*APITokenInfo) {
+	apiTokenLock.RLock()
+	defer apiTokenLock.RUnlock()
+
+	ret = []*APITokenInfo{}
+	for _, token := range Conf.Api.Tokens {
+		ret = append(ret, newAPITokenInfo(token))
+	}
+	return
+}
+
+// CreateAPIToken 创建具名 API token，返回的 token 明文仅此一次可见。
+func CreateAPIToken(name, role string, boxes, routeGroups []string, expired int64) (info *APITokenInfo, token string, err error) {
+	if err = checkAPITokenArgs(role, boxes, routeGroups); nil != err {
+		return
+	}
+
+	token = gulu.Rand.String(32)
+	apiToken := &conf.APIToken{
+		ID:          ast.NewNodeID(),
+		Name:        strings.TrimSpace(name),
+		Hash:        hashAPIToken(token),
+		Hint:        token[len(token)-4:],
+		Role:        role,
+		Boxes:       boxes,
+		RouteGroups: routeGroups,
+		Created:     time.Now().UnixMilli(),
+		Expired:     expired,
+	}
+
+	apiTokenLock.Lock()
+	Conf.Api.Tokens = append(Conf.Api.Tokens, apiToken)
+	info = newAPITokenInfo(apiToken)
+	apiTokenLock.Unlock()
+	Conf.Save()
+	return
+}
+
+func UpdateAPIToken(id, name, role string, boxes, routeGroups []string, expired int64) (info *APITokenInfo, err error) {
+	if err = checkAPITokenArgs(role, boxes, routeGroups); nil != err {
+		return
+	}
+
+	apiTokenLock.Lock()
+	apiToken := getAPITokenByID(id)
+	if nil == apiToken {
+		apiTokenLock.Unlock()
+		err = ErrAPITokenNotFound
+		return
+	}
+	apiToken.Name = strings.TrimSpace(name)
+	apiToken.Role = role
+	apiToken.Boxes = boxes
+	apiToken.RouteGroups = routeGroups
+	apiToken.Expired = expired
+	info = newAPITokenInfo(apiToken)
+	apiTokenLock.Unlock()
+	Conf.Save()
+	return
+}
+
+func RevokeAPIToken(id string) (err error) {
+	apiTokenLock.Lock()
+	apiToken := getAPITokenByID(id)
+	if nil == apiToken {
+		apiTokenLock.Unlock()
+		return ErrAPITokenNotFound
+	}
+	apiToken.Revoked = true
+	apiTokenLock.Unlock()
+	Conf.Save()
+	return
+}
+
+func RemoveAPIToken(id string) (err error) {
+	apiTokenLock.Lock()
+	idx := slices.IndexFunc(Conf.Api.Tokens, func(token *conf.APIToken) bool { return id == token.ID })
+	if 0 > idx {
+		apiTokenLock.Unlock()
+		return ErrAPITokenNotFound
+	}
+	Conf.Api.Tokens = slices.Delete(Conf.Api.Tokens, idx, idx+1)
+	apiTokenLock.Unlock()
+	apiTokenLastUsed.Delete(id)
+	Conf.Save()
+	return
+}
+
+func GetGinContextAPIToken(c *gin.Context) *conf.APIToken {
+	if token, exists := c.Get(APITokenContextKey); exists {
+		return token.(*conf.APIToken)
+	}
+	return nil
+}
+
+func checkAPITokenArgs(role string, boxes, routeGroups []string) error {
+	if _, ok := apiTokenRole(role); !ok {
+		return ErrAPITokenInvalidRole
+	}
+	for _, box := range boxes {
+		if !ast.IsNodeIDPattern(box) {
+			return errors.New("invalid notebook ID [" + box + "]")
+		}
+	}
+	for _, group := range routeGroups {
+		if "" == group || strings.Contains(group, "/") {
+			return errors.New("invalid route group [" + group + "]")
+		}
+	}
+	return nil
+}
+
+func apiTokenRole(role string) (Role, bool) {
+	switch role {
+	case conf.APITokenRoleAdmin:
+		return RoleAdministrator, true
+	case conf.APITokenRoleEditor:
+		return RoleEditor, true
+	case conf.APITokenRoleReader:
+		return RoleReader, true
+	}
+	return RoleVisitor, false
+}
+
+func getAPITokenByID(id string) *conf.APIToken {
+	for _, token := range Conf.Api.Tokens {
+		if id == token.ID {
+			return token
+		}
+	}
+	return nil
+}
+
+func hashAPIToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// matchAPIToken 查找与明文 token 匹配的具名 token，并记录最近使用时间。
+func matchAPIToken(token string) (ret *conf.APIToken, err error) {
+	hash := []byte(hashAPIToken(token))
+
+	apiTokenLock.RLock()
+	defer apiTokenLock.RUnlock()
+
+	for _, apiToken := range Conf.Api.Tokens {
+		if 1 == subtle.ConstantTimeCompare(hash, []byte(apiToken.Hash)) {
+			ret = apiToken
+			break
+		}
+	}
+	if nil == ret {
+		err = ErrAPITokenNotFound
+		return
+	}
+
+	if ret.Revoked {
+		err = ErrAPITokenRevoked
+		return
+	}
+
+	now := time.Now()
+	if 0 < ret.Expired && now.UnixMilli() > ret.Expired {
+		err = ErrAPITokenExpired
+		return
+	}
+
+	apiTokenLastUsed.Store(ret.ID, now.UnixMilli())
+	saved := apiTokenLastUsedSaved.Load()
+	if 0 == saved {
+		// 首次使用时开始计时
+		apiTokenLastUsedSaved.CompareAndSwap(0, now.UnixMilli())
+	} else if now.Sub(time.UnixMilli(saved)) > apiTokenLastUsedSaveInterval && apiTokenLastUsedSaved.CompareAndSwap(saved, now.UnixMilli()) {
+		go saveAPITokensLastUsed()
+	}
+	return
+}
+
+// apiTokenLastUsedTime 返回 token 的最近使用时间，包括尚未落盘的。
+func apiTokenLastUsedTime(token *conf.APIToken) int64 {
+	if lastUsed, ok := apiTokenLastUsed.Load(token.ID); ok {
+		return max(token.LastUsed, lastUsed.(int64))
+	}
+	return token.LastUsed
+}
+
+// saveAPITokensLastUsed 将内存中的最近使用时间合并到配置并保存。
+func saveAPITokensLastUsed() {
+	apiTokenLock.Lock()
+	for _, token := range Conf.Api.Tokens {
+		token.LastUsed = apiTokenLastUsedTime(token)
+	}
+	apiTokenLock.Unlock()
+	Conf.Save()
+}
+
+// authAPIToken 使用具名 token 进行鉴权，通过时设置角色，否则中止请求。
+func authAPIToken(c *gin.Context, token *conf.APIToken) bool {
+	role, ok := apiTokenRole(token.Role)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, map[string]any{"code": -1, "msg": "Auth failed [API token role]"})
+		c.Abort()
+		return false
+	}
+
+	if msg := checkAPITokenScope(c.Request, token); "" != msg {
+		c.JSON(http.StatusForbidden, map[string]any{"code": -1, "msg": msg})
+		c.Abort()
+		return false
+	}
+
+	c.Set(RoleContextKey, role)
+	c.Set(APITokenContextKey, token)
+	return true
+}
+
+// checkAPITokenScope 检查请求是否在具名 token 的角色、路由分组和笔记本范围内，返回不为空时表示拒绝的原因。
+func checkAPITokenScope(req *http.Request, token *conf.APIToken) string {
+	urlPath := req.URL.Path
+	if slices.Contains(apiTokenForbiddenRoutes, urlPath) {
+		return "API token is not allowed to access [" + urlPath + "]"
+	}
+
+	group := apiRouteGroup(urlPath)
+	if 0 < len(token.RouteGroups) && !slices.Contains(token.RouteGroups, group) {
+		return "API token is not allowed to access route group [" + group + "]"
+	}
+
+	if !strings.HasPrefix(urlPath, "/api/") {
+		return ""
+	}
+
+	route := apiTokenRoutes[urlPath]
+	if role, _ := apiTokenRole(token.Role); RoleAdministrator != role && (nil == route || role > route.role) {
+		return "API token with role [" + token.Role + "] is not allowed to access [" + urlPath + "]"
+	}
+
+	if 1 > len(token.Boxes) {
+		return ""
+	}
+
+	if nil == route || apiTokenScopeAll == route.scope {
+		return "API token restricted to notebooks is not allowed to access [" + urlPath + "]"
+	}
+	if apiTokenScopeNone == route.scope {
+		return ""
+	}
+
+	boxes, ok := apiTokenRequestBoxes(req, route)
+	if !ok {
+		return "API token restricted to notebooks cannot resolve the notebooks of [" + urlPath + "]"
+	}
+	for _, box := range boxes {
+		if !slices.Contains(token.Boxes, box) {
+			return "API token is not allowed to access notebook [" + box + "]"
+		}
+	}
+	return ""
+}
+
+func apiRouteGroup(urlPath string) string {
+	after, ok := strings.CutPrefix(urlPath, "/api/")
+	if !ok {
+		return ""
+	}
+	group, _, _ := strings.Cut(after, "/")
+	return group
+}
+
+// isAPITokenRoute 判断当前请求是否为非管理员具名 token 按路由白名单获准访问的路由。
+func isAPITokenRoute(c *gin.Context) bool {
+	token := GetGinContextAPIToken(c)
+	if nil == token {
+		return false
+	}
+
+	role, _ := apiTokenRole(token.Role)
+	if RoleEditor != role && RoleReader != role {
+		return false
+	}
+	route := apiTokenRoutes[c.Request.URL.Path]
+	return nil != route && role <= route.role
+}
+
+// apiTokenRequestBoxes 按路由规则从 JSON 请求参数中解析请求涉及的笔记本，块 ID 通过块树映射到所在笔记本。
+// 参数无法解析、ID 不合法或块不存在时返回 false。
+func apiTokenRequestBoxes(req *http.Request, route *apiTokenRoute) (ret []string, ok bool) {
+	if nil == req.Body || http.MethodGet == req.Method {
+		return
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	if nil != err {
+		return
+	}
+
+	var arg map[string]any
+	if err = json.Unmarshal(data, &arg); nil != err {
+		return
+	}
+
+	var blockIDs []string
+	for _, name := range route.boxArgs {
+		if ret, ok = appendAPITokenArgIDs(ret, arg[name]); !ok {
+			return
+		}
+	}
+	for _, name := range route.blockArgs {
+		if blockIDs, ok = appendAPITokenArgIDs(blockIDs, arg[name]); !ok {
+			return
+		}
+	}
+
+	blockIDs = gulu.Str.RemoveDuplicatedElem(blockIDs)
+	bts := treenode.GetBlockTrees(blockIDs)
+	for _, id := range blockIDs {
+		bt := bts[id]
+		if nil == bt {
+			return nil, false
+		}
+		ret = append(ret, bt.BoxID)
+	}
+
+	ret = gulu.Str.RemoveDuplicatedElem(ret)
+	ok = 0 < len(ret)
+	return
+}
+
+func appendAPITokenArgIDs(ids []string, value any) ([]string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return ids, true
+	case string:
+		if "" == v {
+			return ids, true
+		}
+		if !ast.IsNodeIDPattern(v) {
+			return ids, false
+		}
+		return append(ids, v), true
+	case []any:
+		for _, item := range v {
+			id, isStr := item.(string)
+			if !isStr || !ast.IsNodeIDPattern(id) {
+				return ids, false
+			}
+			ids = append(ids, id)
+		}
+		return ids, true
+	}
+	return ids, false
+}
@@ -0,0 +1,209 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestMatchAPIToken(t *testing.T) {
+	originalConf := Conf
+	t.Cleanup(func() { Conf = originalConf })
+
+	now := time.Now().UnixMilli()
+	Conf = NewAppConf()
+	Conf.Api = &conf.API{Tokens: []*conf.APIToken{
+		{ID: "valid", Hash: hashAPIToken("valid-token"), Role: conf.APITokenRoleReader, LastUsed: now},
+		{ID: "expired", Hash: hashAPIToken("expired-token"), Role: conf.APITokenRoleReader, Expired: now - 1000, LastUsed: now},
+		{ID: "revoked", Hash: hashAPIToken("revoked-token"), Role: conf.APITokenRoleReader, Revoked: true, LastUsed: now},
+	}}
+
+	if token, err := matchAPIToken("valid-token"); nil != err || "valid" != token.ID {
+		t.Fatalf("expected valid token, got [%v] [%v]", token, err)
+	}
+	if _, err := matchAPIToken("expired-token"); !errors.Is(err, ErrAPITokenExpired) {
+		t.Fatalf("expected expired token error, got [%v]", err)
+	}
+	if _, err := matchAPIToken("revoked-token"); !errors.Is(err, ErrAPITokenRevoked) {
+		t.Fatalf("expected revoked token error, got [%v]", err)
+	}
+	if _, err := matchAPIToken("unknown-token"); !errors.Is(err, ErrAPITokenNotFound) {
+		t.Fatalf("expected token not found error, got [%v]", err)
+	}
+}
+
+func TestMatchAPITokenLastUsed(t *testing.T) {
+	originalConf := Conf
+	t.Cleanup(func() {
+		Conf = originalConf
+		apiTokenLastUsed.Delete("valid")
+	})
+
+	Conf = NewAppConf()
+	Conf.Api = &conf.API{Tokens: []*conf.APIToken{{ID: "valid", Hash: hashAPIToken("valid-token"), Role: conf.APITokenRoleReader}}}
+	apiTokenLastUsedSaved.Store(time.Now().UnixMilli())
+
+	if _, err := matchAPIToken("valid-token"); nil != err {
+		t.Fatalf("expected valid token, got [%v]", err)
+	}
+	if 0 != Conf.Api.Tokens[0].LastUsed {
+		t.Fatalf("expected last used time to stay in memory until the save interval elapses")
+	}
+	if infos := ListAPITokens(); 1 != len(infos) || 0 == infos[0].LastUsed {
+		t.Fatalf("expected listed token to report the in-memory last used time, got [%+v]", infos)
+	}
+}
+
+func TestCheckAPITokenScope(t *testing.T) {
+	allowedBox, deniedBox := "20240101120000-aaaaaaa", "20240101120000-bbbbbbb"
+	token := &conf.APIToken{Role: conf.APITokenRoleEditor, Boxes: []string{allowedBox}, RouteGroups: []string{"filetree", "query"}}
+
+	cases := []struct {
+		path    string
+		body    string
+		allowed bool
+	}{
+		{"/api/filetree/createDocWithMd", `{"notebook":"` + allowedBox + `","path":"/foo"}`, true},
+		{"/api/filetree/createDocWithMd", `{"notebook":"` + deniedBox + `","path":"/foo"}`, false},
+		{"/api/filetree/moveDocs", `{"fromPaths":[],"toNotebook":"` + deniedBox + `"}`, false},
+		{"/api/block/getBlockKramdown", `{"id":"20240101120000-ccccccc"}`, false},
+		{"/api/filetree/createDocWithMd", `{"notebook":"` + allowedBox + `","parentID":"20240101120000-ccccccc"}`, false},
+		{"/api/filetree/createDoc", `{"path":"/foo"}`, false},
+		{"/api/filetree/createDoc", `{"notebook":"../foo"}`, false},
+		{"/api/query/sql", `{"stmt":"SELECT * FROM blocks"}`, false},
+		{"/api/notebook/lsNotebooks", `{}`, false},
+		{"/api/setting/createAPIToken", `{}`, false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", c.path, strings.NewReader(c.body))
+		msg := checkAPITokenScope(req, token)
+		if c.allowed != ("" == msg) {
+			t.Fatalf("unexpected scope check for [%s] [%s]: [%s]", c.path, c.body, msg)
+		}
+
+		// 鉴权后请求体仍可被处理函数读取
+		if body, _ := io.ReadAll(req.Body); c.body != string(body) && strings.HasPrefix(c.path, "/api/filetree/") {
+			t.Fatalf("request body was not restored for [%s]", c.path)
+		}
+	}
+
+	if "block" != apiRouteGroup("/api/block/updateBlock") || "" != apiRouteGroup("/assets/foo.png") {
+		t.Fatal("unexpected API route group")
+	}
+}
+
+func TestAPITokenRoleRoutes(t *testing.T) {
+	editor := &conf.APIToken{Role: conf.APITokenRoleEditor}
+	reader := &conf.APIToken{Role: conf.APITokenRoleReader}
+	admin := &conf.APIToken{Role: conf.APITokenRoleAdmin}
+
+	adminRoutes := []string{
+		"/api/notebook/removeNotebook", "/api/history/clearWorkspaceHistory", "/api/history/rollbackNotebookHistory",
+		"/api/export/exportData", "/api/template/renderSprig", "/api/query/sql", "/api/setting/getWebhooks",
+	}
+	for _, path := range adminRoutes {
+		if msg := checkAPITokenScope(httptest.NewRequest("POST", path, strings.NewReader(`{}`)), editor); "" == msg {
+			t.Fatalf("editor API token should be rejected on [%s]", path)
+		}
+		if msg := checkAPITokenScope(httptest.NewRequest("POST", path, strings.NewReader(`{}`)), admin); "" != msg {
+			t.Fatalf("admin API token should be allowed on [%s]: [%s]", path, msg)
+		}
+		if checkAdminRoleWithAPIToken(path, editor) {
+			t.Fatalf("editor API token should not pass the admin role check on [%s]", path)
+		}
+	}
+
+	if msg := checkAPITokenScope(httptest.NewRequest("POST", "/api/block/updateBlock", strings.NewReader(`{}`)), editor); "" != msg {
+		t.Fatalf("editor API token should be allowed to update blocks: [%s]", msg)
+	}
+	if !checkAdminRoleWithAPIToken("/api/block/updateBlock", editor) {
+		t.Fatal("editor API token should pass the admin role check on [/api/block/updateBlock]")
+	}
+	if msg := checkAPITokenScope(httptest.NewRequest("POST", "/api/block/updateBlock", strings.NewReader(`{}`)), reader); "" == msg {
+		t.Fatal("reader API token should be rejected on [/api/block/updateBlock]")
+	}
+	if msg := checkAPITokenScope(httptest.NewRequest("POST", "/api/block/getBlockKramdown", strings.NewReader(`{}`)), reader); "" != msg {
+		t.Fatalf("reader API token should be allowed to read blocks: [%s]", msg)
+	}
+}
+
+func checkAdminRoleWithAPIToken(path string, token *conf.APIToken) bool {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("POST", path, nil)
+	role, _ := apiTokenRole(token.Role)
+	c.Set(RoleContextKey, role)
+	c.Set(APITokenContextKey, token)
+	CheckAdminRole(c)
+	return !c.IsAborted() && http.StatusForbidden != recorder.Code
+}
+
+func TestKernelPluginRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pluginContext := func(path string, role Role, apiGroups ...any) (*gin.Context, *httptest.ResponseRecorder) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest("POST", path, nil)
+		c.Set(RoleContextKey, role)
+		c.Set(ClaimsContextKey, jwt.MapClaims{"aud": KernelPluginJWTAudience, ClaimsKeyRole: float64(role), ClaimsKeyAPIGroups: apiGroups})
+		return c, recorder
+	}
+
+	// 插件只能访问已批准的路由分组
+	if c, _ := pluginContext("/api/block/updateBlock", RoleEditor, "block"); "" != checkKernelPluginScope(c) {
+		t.Fatal("kernel plugin should be allowed to access approved route group")
+	}
+	for _, path := range []string{"/api/filetree/removeDoc", "/assets/foo.png"} {
+		if c, _ := pluginContext(path, RoleEditor, "block"); "" == checkKernelPluginScope(c) {
+			t.Fatalf("kernel plugin should not be allowed to access [%s]", path)
+		}
+	}
+	if c, _ := pluginContext("/assets/foo.png", RoleAdministrator, "*"); "" != checkKernelPluginScope(c) {
+		t.Fatal("kernel plugin granted all route groups should be allowed to access assets")
+	}
+
+	// 非管理员插件不能访问仅限管理员的路由
+	cases := []struct {
+		path    string
+		role    Role
+		allowed bool
+	}{
+		{"/api/block/updateBlock", RoleEditor, true},
+		{"/api/block/updateBlock", RoleReader, false},
+		{"/api/block/getBlockKramdown", RoleReader, true},
+		{"/api/notebook/removeNotebook", RoleEditor, false},
+		{"/api/template/renderSprig", RoleReader, false},
+	}
+	for _, cs := range cases {
+		c, recorder := pluginContext(cs.path, cs.role, "*")
+		CheckAdminRole(c)
+		if allowed := !c.IsAborted() && http.StatusForbidden != recorder.Code; cs.allowed != allowed {
+			t.Fatalf("unexpected admin role check for kernel plugin with role [%d] on [%s]", cs.role, cs.path)
+		}
+	}
+}
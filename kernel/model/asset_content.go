@@ -17,8 +17,8 @@
 package model
 
 import (
-	"bytes"
 	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -56,9 +56,54 @@ type AssetContent struct {
 	HSize   string `json:"hSize"`
 	Updated int64  `json:"updated"`
 	Content string `json:"content"`
+
+	Locator *AssetContentLocator `json:"locator,omitempty"` // 命中分块在文件中的位置，整个文件作为一条记录时为空
+}
+
+// AssetContentLocator 描述资源文件内容分块在文件中的位置。
+type AssetContentLocator struct {
+	Type    string `json:"type"`              // page：PDF 页，slide：幻灯片，sheet：工作表区域，chapter：EPUB 章节
+	Page    int    `json:"page,omitempty"`    // PDF 页码，从 1 开始
+	Slide   int    `json:"slide,omitempty"`   // 幻灯片序号，从 1 开始
+	Sheet   string `json:"sheet,omitempty"`   // 工作表名称
+	Range   string `json:"range,omitempty"`   // 工作表单元格区域，形如 A1:F100
+	Chapter string `json:"chapter,omitempty"` // EPUB 章节标题
+	Index   int    `json:"index,omitempty"`   // EPUB 章节序号，从 1 开始
+}
+
+const (
+	AssetContentLocatorPage    = "page"
+	AssetContentLocatorSlide   = "slide"
+	AssetContentLocatorSheet   = "sheet"
+	AssetContentLocatorChapter = "chapter"
+)
+
+func (locator *AssetContentLocator) String() string {
+	if nil == locator {
+		return ""
+	}
+
+	switch locator.Type {
+	case AssetContentLocatorPage:
+		return "page " + strconv.Itoa(locator.Page)
+	case AssetContentLocatorSlide:
+		return "slide " + strconv.Itoa(locator.Slide)
+	case AssetContentLocatorSheet:
+		return locator.Sheet + "!" + locator.Range
+	case AssetContentLocatorChapter:
+		if "" == locator.Chapter {
+			return "chapter " + strconv.Itoa(locator.Index)
+		}
+		return "chapter " + strconv.Itoa(locator.Index) + ": " + locator.Chapter
+	}
+	return ""
 }
 
 func GetAssetContent(id, query string, queryMethod int) (ret *AssetContent) {
+	if 4 == queryMethod {
+		// 语义搜索的查询为自然语言，无法用于高亮
+		query = ""
+	}
 	if "" != query && (0 == queryMethod || 1 == queryMethod) {
 		if 0 == queryMethod {
 			query = stringQuery(query)
@@ -77,7 +122,7 @@ func GetAssetContent(id, query string, queryMethod int) (ret *AssetContent) {
 	}
 
 	projections := "id, name, ext, path, size, updated, " +
-		"highlight(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "') AS content"
+		"highlight(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "') AS content, locator"
 	stmt := "SELECT " + projections + " FROM " + table + " WHERE " + filter
 	assetContents := sql.SelectAssetContentsRawStmtNoParseArgs(stmt, args, 1)
 	results := fromSQLAssetContents(&assetContents)
@@ -92,7 +137,7 @@ func GetAssetContent(id, query string, queryMethod int) (ret *AssetContent) {
 // GetAssetContentByPath 按资源文件路径获取已索引的完整内容。
 //
 // path 为工作空间相对路径，形如 "assets/xxx.pdf"。返回 nil 表示该文件尚未被索引。
-// 与 GetAssetContent（按索引记录 id）不同，此处直接返回 content 原文，不做高亮。
+// 与 GetAssetContent（按索引记录 id）不同，此处直接返回 content 原文，不做高亮；分块索引的文件按分块顺序拼接全部内容。
 func GetAssetContentByPath(path string) (ret *AssetContent) {
 	path = strings.TrimSpace(path)
 	if "" == path {
@@ -100,20 +145,27 @@ func GetAssetContentByPath(path string) (ret *AssetContent) {
 	}
 
 	table := "asset_contents_fts_case_insensitive"
-	stmt := "SELECT id, name, ext, path, size, updated, content FROM " + table + " WHERE path = ? LIMIT 1"
-	assetContents := sql.SelectAssetContentsRawStmtNoParseArgs(stmt, []any{path}, 1)
+	stmt := "SELECT id, name, ext, path, size, updated, content FROM " + table + " WHERE path = ? ORDER BY rowid ASC"
+	assetContents := sql.SelectAssetContentsRawStmtNoParseArgs(stmt, []any{path}, math.MaxInt32)
 	results := fromSQLAssetContents(&assetContents)
 	if 1 > len(results) {
 		return
 	}
 	ret = results[0]
+	if 1 < len(results) {
+		var contents []string
+		for _, result := range results {
+			contents = append(contents, result.Content)
+		}
+		ret.Content = strings.Join(contents, "\n\n")
+	}
 	return
 }
 
 // FullTextSearchAssetContent 搜索资源文件内容。
 //
-// method：0：关键字，1：查询语法，2：SQL，3：正则表达式
-// orderBy: 0：按相关度降序，1：按相关度升序，2：按更新时间升序，3：按更新时间降序
+// method：0：关键字，1：查询语法，2：SQL，3：正则表达式，4：语义
+// orderBy: 0：按相关度降序，1：按相关度升序，2：按更新时间升序，3：按更新时间降序，语义搜索固定按相似度降序
 func FullTextSearchAssetContent(query string, types map[string]bool, method, orderBy, page, pageSize int) (ret []*AssetContent, matchedAssetCount, pageCount int, err error) {
 	query = strings.TrimSpace(query)
 	orderByClause := buildAssetContentOrderBy(orderBy)
@@ -129,6 +181,9 @@ func FullTextSearchAssetContent(query string, types map[string]bool, method, ord
 	case 3: // 正则表达式
 		typeFilter, typeArgs := buildAssetContentTypeFilter(types)
 		ret, matchedAssetCount = fullTextSearchAssetContentByRegexp(query, typeFilter, typeArgs, orderByClause, page, pageSize)
+	case 4: // 语义
+		ret, matchedAssetCount, pageCount = SemanticSearchAssetContent(query, types, page, pageSize)
+		return
 	default: // 关键字
 		filter, filterArgs := buildAssetContentTypeFilter(types)
 		ret, matchedAssetCount = fullTextSearchAssetContentByKeyword(query, filter, filterArgs, orderByClause, page, pageSize)
@@ -169,9 +224,19 @@ func fullTextSearchAssetContentByRegexp(exp, typeFilter string, typeArgs []any,
 	return
 }
 
+// assetContentFieldRegexp 构造文件名、内容和元数据的正则过滤条件。
+// 分块记录都带有文件名，文件名命中时每个文件只保留第一条分块，内容命中时保留所有命中的分块。
 func assetContentFieldRegexp(exp string) (clause string, args []any) {
-	clause = "(name REGEXP ? OR content REGEXP ?)"
-	args = []any{exp, exp}
+	clause = "(content REGEXP ? OR meta REGEXP ? OR (name REGEXP ? AND ('' = IFNULL(locator, '') OR rowid IN (SELECT MIN(rowid) FROM `asset_contents_fts_case_insensitive` WHERE name REGEXP ? GROUP BY path))))"
+	args = []any{exp, exp, exp, exp}
+	return
+}
+
+// assetContentFieldMatch 构造文件名、内容和元数据的全文搜索条件，文件名命中的分块去重规则同 assetContentFieldRegexp。
+func assetContentFieldMatch(query string) (clause string, args []any) {
+	table := "`asset_contents_fts_case_insensitive`"
+	clause = table + " MATCH ? AND ('' = IFNULL(locator, '') OR rowid IN (SELECT rowid FROM " + table + " WHERE " + table + " MATCH ?) OR rowid IN (SELECT MIN(rowid) FROM " + table + " WHERE " + table + " MATCH ? GROUP BY path))"
+	args = []any{buildAssetContentColumnFilter() + ":(" + query + ")", "{content meta}:(" + query + ")", "name:(" + query + ")"}
 	return
 }
 
@@ -179,7 +244,7 @@ func fullTextSearchAssetContentCountByRegexp(exp, typeFilter string, typeArgs []
 	table := "asset_contents_fts_case_insensitive"
 	fieldFilter, args := assetContentFieldRegexp(exp)
 	args = append(args, typeArgs...)
-	stmt := "SELECT COUNT(path) AS `assets` FROM `" + table + "` WHERE " + fieldFilter + typeFilter
+	stmt := "SELECT COUNT(DISTINCT path) AS `assets` FROM `" + table + "` WHERE " + fieldFilter + typeFilter
 	result, _ := sql.QueryAssetContentNoLimitArgs(stmt, args...)
 	if 1 > len(result) {
 		return
@@ -191,12 +256,12 @@ func fullTextSearchAssetContentCountByRegexp(exp, typeFilter string, typeArgs []
 func fullTextSearchAssetContentByFTS(query, typeFilter string, typeArgs []any, orderBy string, page, pageSize int) (ret []*AssetContent, matchedAssetCount int) {
 	table := "asset_contents_fts_case_insensitive"
 	projections := "id, name, ext, path, size, updated, " +
-		"snippet(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 64) AS content"
-	stmt := "SELECT " + projections + " FROM " + table + " WHERE `" + table + "` MATCH ?" + typeFilter
+		"snippet(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 64) AS content, locator"
+	fieldFilter, args := assetContentFieldMatch(query)
+	args = append(args, typeArgs...)
+	stmt := "SELECT " + projections + " FROM " + table + " WHERE " + fieldFilter + typeFilter
 	stmt += " " + orderBy
 	stmt += " LIMIT " + strconv.Itoa(pageSize) + " OFFSET " + strconv.Itoa((page-1)*pageSize)
-	args := []any{buildAssetContentColumnFilter() + ":(" + query + ")"}
-	args = append(args, typeArgs...)
 	assetContents := sql.SelectAssetContentsRawStmtNoParseArgs(stmt, args, Conf.Search.Limit)
 	ret = fromSQLAssetContents(&assetContents)
 	if 1 > len(ret) {
@@ -224,7 +289,7 @@ func searchAssetContentBySQL(stmt string, page, pageSize int) (ret []*AssetConte
 	}
 
 	stmt = strings.ToLower(stmt)
-	stmt = strings.ReplaceAll(stmt, "select * ", "select COUNT(path) AS `assets` ")
+	stmt = strings.ReplaceAll(stmt, "select * ", "select COUNT(DISTINCT path) AS `assets` ")
 	stmt = removeLimitClause(stmt)
 	result, _ := sql.QueryAssetContentNoLimit(stmt)
 	if 1 > len(result) {
@@ -241,9 +306,9 @@ func fullTextSearchAssetContentCount(query, typeFilter string, typeArgs []any) (
 	query = filterQueryInvisibleChars(query)
 
 	table := "asset_contents_fts_case_insensitive"
-	stmt := "SELECT COUNT(path) AS `assets` FROM `" + table + "` WHERE `" + table + "` MATCH ?" + typeFilter
-	args := []any{buildAssetContentColumnFilter() + ":(" + query + ")"}
+	fieldFilter, args := assetContentFieldMatch(query)
 	args = append(args, typeArgs...)
+	stmt := "SELECT COUNT(DISTINCT path) AS `assets` FROM `" + table + "` WHERE " + fieldFilter + typeFilter
 	result, _ := sql.QueryAssetContentNoLimitArgs(stmt, args...)
 	if 1 > len(result) {
 		return
@@ -267,6 +332,15 @@ func fromSQLAssetContent(assetContent *sql.AssetContent) *AssetContent {
 		content = strings.ReplaceAll(content, search.SearchMarkRight, "</mark>")
 	}
 
+	var locator *AssetContentLocator
+	if "" != assetContent.Locator {
+		locator = &AssetContentLocator{}
+		if err := gulu.JSON.UnmarshalJSON([]byte(assetContent.Locator), locator); err != nil {
+			logging.LogWarnf("unmarshal asset content locator [%s] failed: %s", assetContent.Locator, err)
+			locator = nil
+		}
+	}
+
 	return &AssetContent{
 		ID:      assetContent.ID,
 		Name:    assetContent.Name,
@@ -276,11 +350,12 @@ func fromSQLAssetContent(assetContent *sql.AssetContent) *AssetContent {
 		HSize:   humanize.BytesCustomCeil(uint64(assetContent.Size), 2),
 		Updated: assetContent.Updated,
 		Content: content,
+		Locator: locator,
 	}
 }
 
 func buildAssetContentColumnFilter() string {
-	return "{name content}"
+	return "{name content meta}"
 }
 
 func buildAssetContentTypeFilter(types map[string]bool) (clause string, args []any) {
@@ -291,6 +366,10 @@ func buildAssetContentTypeFilter(types map[string]bool) (clause string, args []a
 	var enabledTypes []string
 	for k, enabled := range types {
 		if enabled {
+			if !strings.HasPrefix(k, ".") {
+				// 兼容命令行和 MCP 工具传入不带点的扩展名，比如 pdf
+				k = "." + k
+			}
 			enabledTypes = append(enabledTypes, k)
 		}
 	}
@@ -354,21 +433,12 @@ func indexAssetContent(absPath string) {
 	}
 
 	assetsDir := util.GetDataAssetsAbsPath()
-	p := "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir))
+	result.Path = "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir))
+	result.Size = info.Size()
+	result.Updated = info.ModTime().Unix()
+	assetContents := result.toSQLAssetContents(ext)
 
-	assetContents := []*sql.AssetContent{
-		{
-			ID:      ast.NewNodeID(),
-			Name:    util.RemoveID(filepath.Base(p)),
-			Ext:     ext,
-			Path:    p,
-			Size:    info.Size(),
-			Updated: info.ModTime().Unix(),
-			Content: result.Content,
-		},
-	}
-
-	sql.DeleteAssetContentsByPathQueue(p)
+	sql.DeleteAssetContentsByPathQueue(result.Path)
 	sql.IndexAssetContentsQueue(assetContents)
 }
 
@@ -459,15 +529,7 @@ func (searcher *AssetsSearcher) FullIndex() {
 
 	var assetContents []*sql.AssetContent
 	for _, result := range results {
-		assetContents = append(assetContents, &sql.AssetContent{
-			ID:      ast.NewNodeID(),
-			Name:    util.RemoveID(filepath.Base(result.Path)),
-			Ext:     strings.ToLower(filepath.Ext(result.Path)),
-			Path:    result.Path,
-			Size:    result.Size,
-			Updated: result.Updated,
-			Content: result.Content,
-		})
+		assetContents = append(assetContents, result.toSQLAssetContents(strings.ToLower(filepath.Ext(result.Path)))...)
 	}
 
 	sql.IndexAssetContentsQueue(assetContents)
@@ -475,6 +537,9 @@ func (searcher *AssetsSearcher) FullIndex() {
 
 func NewAssetsSearcher() *AssetsSearcher {
 	txtAssetParser := &TxtAssetParser{}
+	htmlAssetParser := &HtmlAssetParser{}
+	odfAssetParser := &OdfAssetParser{}
+	mailAssetParser := &MailAssetParser{}
 	return &AssetsSearcher{
 		parsers: map[string]AssetParser{
 			".txt":      txtAssetParser,
@@ -483,8 +548,6 @@ func NewAssetsSearcher() *AssetsSearcher {
 			".json":     txtAssetParser,
 			".log":      txtAssetParser,
 			".sql":      txtAssetParser,
-			".html":     txtAssetParser,
-			".xml":      txtAssetParser,
 			".java":     txtAssetParser,
 			".h":        txtAssetParser,
 			".c":        txtAssetParser,
@@ -515,6 +578,18 @@ func NewAssetsSearcher() *AssetsSearcher {
 			".xlsx":     &XlsxAssetParser{},
 			".pdf":      &PdfAssetParser{},
 			".epub":     &EpubAssetParser{},
+			".html":     htmlAssetParser,
+			".htm":      htmlAssetParser,
+			".xhtml":    htmlAssetParser,
+			".xml":      &XmlAssetParser{},
+			".odt":      odfAssetParser,
+			".ods":      odfAssetParser,
+			".odp":      odfAssetParser,
+			".rtf":      &RtfAssetParser{},
+			".eml":      mailAssetParser,
+			".mhtml":    mailAssetParser,
+			".mht":      mailAssetParser,
+			".mbox":     &MailAssetParser{mbox: true},
 		},
 
 		lock: &sync.Mutex{},
@@ -535,6 +610,53 @@ type AssetParseResult struct {
 	Size    int64
 	Updated int64
 	Content string
+	Meta    string               // 元数据，比如邮件头，单独索引到 meta 字段
+	Chunks  []*AssetContentChunk // 非空时按分块分别索引，不再使用 Content
+}
+
+// AssetContentChunk 为资源文件中可定位的一段内容，比如 PDF 的一页。
+type AssetContentChunk struct {
+	Locator *AssetContentLocator
+	Content string
+}
+
+// toSQLAssetContents 将解析结果转换为索引记录，每个分块一条记录。
+func (result *AssetParseResult) toSQLAssetContents(ext string) (ret []*sql.AssetContent) {
+	newAssetContent := func(content, locator, meta string) *sql.AssetContent {
+		return &sql.AssetContent{
+			ID:      ast.NewNodeID(),
+			Name:    util.RemoveID(filepath.Base(result.Path)),
+			Ext:     ext,
+			Path:    result.Path,
+			Size:    result.Size,
+			Updated: result.Updated,
+			Content: content,
+			Locator: locator,
+			Meta:    meta,
+		}
+	}
+
+	if 1 > len(result.Chunks) {
+		ret = append(ret, newAssetContent(result.Content, "", result.Meta))
+		return
+	}
+
+	// 元数据属于整个文件，只写入第一个分块，避免元数据命中时返回所有分块
+	meta := result.Meta
+	for _, chunk := range result.Chunks {
+		var locator string
+		if nil != chunk.Locator {
+			data, err := gulu.JSON.MarshalJSON(chunk.Locator)
+			if err != nil {
+				logging.LogErrorf("marshal asset content locator failed: %s", err)
+				continue
+			}
+			locator = string(data)
+		}
+		ret = append(ret, newAssetContent(chunk.Content, locator, meta))
+		meta = ""
+	}
+	return
 }
 
 type AssetParser interface {
@@ -666,22 +788,22 @@ func (parser *PptxAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 	}
 	defer os.RemoveAll(tmp)
 
-	f, err := os.Open(tmp)
-	if err != nil {
-		logging.LogErrorf("open [%s] failed: [%s]", tmp, err)
-		return
-	}
-	defer f.Close()
-
-	data, _, err := docconv.ConvertPptx(f)
+	slides, err := pptxSlideTexts(tmp)
 	if err != nil {
 		logging.LogErrorf("convert [%s] failed: [%s]", tmp, err)
 		return
 	}
 
-	var content = normalizeNonTxtAssetContent(data)
-	ret = &AssetParseResult{
-		Content: content,
+	ret = &AssetParseResult{}
+	for i, slide := range slides {
+		content := normalizeNonTxtAssetContent(slide)
+		if "" == strings.TrimSpace(content) {
+			continue
+		}
+		ret.Chunks = append(ret.Chunks, &AssetContentChunk{
+			Locator: &AssetContentLocator{Type: AssetContentLocatorSlide, Slide: i + 1},
+			Content: content,
+		})
 	}
 	return
 }
@@ -711,24 +833,14 @@ func (parser *XlsxAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 	}
 	defer x.Close()
 
-	buf := bytes.Buffer{}
-	sheetMap := x.GetSheetMap()
-	for _, sheetName := range sheetMap {
+	ret = &AssetParseResult{}
+	for _, sheetName := range x.GetSheetList() {
 		rows, getErr := x.GetRows(sheetName)
 		if nil != getErr {
 			logging.LogErrorf("get rows from sheet [%s] failed: [%s]", sheetName, getErr)
-			return
-		}
-		for _, row := range rows {
-			for _, colCell := range row {
-				buf.WriteString(colCell + " ")
-			}
+			return nil
 		}
-	}
-
-	var content = normalizeNonTxtAssetContent(buf.String())
-	ret = &AssetParseResult{
-		Content: content,
+		ret.Chunks = append(ret.Chunks, xlsxSheetChunks(sheetName, rows)...)
 	}
 	return
 }
@@ -928,13 +1040,17 @@ func (parser *PdfAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 		logging.LogInfof("convert [%s] PDF with [%d] pages using [%d] workers took [%s]", absPath, pc.PageCount, cores, time.Since(now))
 	}
 
-	// loop through ordered PDF text pages and join content for asset parse DB result
-	contentBuilder := bytes.Buffer{}
-	for _, pt := range pageText {
-		contentBuilder.WriteString(" " + normalizeNonTxtAssetContent(pt))
-	}
-	ret = &AssetParseResult{
-		Content: contentBuilder.String(),
+	// loop through ordered PDF text pages and index each page as a chunk so that hits can be located by page number
+	ret = &AssetParseResult{}
+	for i, pt := range pageText {
+		content := normalizeNonTxtAssetContent(pt)
+		if "" == strings.TrimSpace(content) {
+			continue
+		}
+		ret.Chunks = append(ret.Chunks, &AssetContentChunk{
+			Locator: &AssetContentLocator{Type: AssetContentLocatorPage, Page: i + 1},
+			Content: content,
+		})
 	}
 	return
 }
@@ -957,22 +1073,23 @@ func (parser *EpubAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 	}
 	defer os.RemoveAll(tmp)
 
-	f, err := os.Open(tmp)
+	ret = &AssetParseResult{}
+	index := 0
+	err := epub.Reader(tmp, func(chapter string, data []byte) bool {
+		index++
+		content := normalizeNonTxtAssetContent(htmlToText(data, "application/xhtml+xml"))
+		if "" == strings.TrimSpace(content) {
+			return true
+		}
+		ret.Chunks = append(ret.Chunks, &AssetContentChunk{
+			Locator: &AssetContentLocator{Type: AssetContentLocatorChapter, Chapter: strings.TrimSpace(chapter), Index: index},
+			Content: content,
+		})
+		return true
+	})
 	if err != nil {
-		logging.LogErrorf("open [%s] failed: [%s]", tmp, err)
-		return
-	}
-	defer f.Close()
-
-	buf := bytes.Buffer{}
-	if err = epub.ToTxt(tmp, &buf); err != nil {
 		logging.LogErrorf("convert [%s] failed: [%s]", tmp, err)
-		return
-	}
-
-	content := normalizeNonTxtAssetContent(buf.String())
-	ret = &AssetParseResult{
-		Content: content,
+		return nil
 	}
 	return
 }
@@ -0,0 +1,202 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// XlsxAssetContentChunkRows 为 .xlsx 每个内容分块包含的行数。
+const XlsxAssetContentChunkRows = 100
+
+// xlsxSheetChunks 将工作表按行切分为内容分块，分块定位到对应的单元格区域。
+func xlsxSheetChunks(sheetName string, rows [][]string) (ret []*AssetContentChunk) {
+	for start := 0; start < len(rows); start += XlsxAssetContentChunkRows {
+		end := min(start+XlsxAssetContentChunkRows, len(rows))
+		buf := bytes.Buffer{}
+		maxCols := 0
+		for _, row := range rows[start:end] {
+			maxCols = max(maxCols, len(row))
+			for _, colCell := range row {
+				buf.WriteString(colCell + " ")
+			}
+		}
+
+		content := normalizeNonTxtAssetContent(buf.String())
+		if "" == content {
+			continue
+		}
+
+		from, _ := excelize.CoordinatesToCellName(1, start+1)
+		to, _ := excelize.CoordinatesToCellName(max(maxCols, 1), end)
+		ret = append(ret, &AssetContentChunk{
+			Locator: &AssetContentLocator{Type: AssetContentLocatorSheet, Sheet: sheetName, Range: from + ":" + to},
+			Content: content,
+		})
+	}
+	return
+}
+
+var pptxSlideFileRegexp = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// pptxSlideTexts 按放映顺序返回每张幻灯片的文本。
+//
+// 放映顺序由 ppt/presentation.xml 的 sldIdLst 决定，幻灯片文件名中的序号在调整顺序后不会变化，
+// 所以仅在无法解析放映顺序时才按文件名序号排序。
+func pptxSlideTexts(absPath string) (ret []string, err error) {
+	zipReader, err := zip.OpenReader(absPath)
+	if err != nil {
+		return
+	}
+	defer zipReader.Close()
+
+	files := map[string]*zip.File{}
+	for _, f := range zipReader.File {
+		files[f.Name] = f
+	}
+
+	slidePaths := pptxSlideOrder(files)
+	if 1 > len(slidePaths) {
+		for name := range files {
+			if pptxSlideFileRegexp.MatchString(name) {
+				slidePaths = append(slidePaths, name)
+			}
+		}
+		sort.Slice(slidePaths, func(i, j int) bool {
+			ni, _ := strconv.Atoi(pptxSlideFileRegexp.FindStringSubmatch(slidePaths[i])[1])
+			nj, _ := strconv.Atoi(pptxSlideFileRegexp.FindStringSubmatch(slidePaths[j])[1])
+			return ni < nj
+		})
+	}
+
+	for _, slidePath := range slidePaths {
+		f := files[slidePath]
+		if nil == f {
+			ret = append(ret, "")
+			continue
+		}
+
+		reader, openErr := f.Open()
+		if nil != openErr {
+			err = openErr
+			return
+		}
+		text, parseErr := pptxSlideText(reader)
+		reader.Close()
+		if nil != parseErr {
+			err = parseErr
+			return
+		}
+		ret = append(ret, text)
+	}
+	return
+}
+
+// pptxSlideOrder 解析演示文稿中幻灯片的放映顺序，返回幻灯片在压缩包中的路径。
+func pptxSlideOrder(files map[string]*zip.File) (ret []string) {
+	var presentation struct {
+		SlideIDs []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if nil != unmarshalZipXML(files["ppt/presentation.xml"], &presentation) || nil != unmarshalZipXML(files["ppt/_rels/presentation.xml.rels"], &rels) {
+		return
+	}
+
+	targets := map[string]string{}
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("ppt", target)
+		}
+		targets[rel.ID] = target
+	}
+	for _, slideID := range presentation.SlideIDs {
+		if target := targets[slideID.RID]; "" != target {
+			ret = append(ret, target)
+		}
+	}
+	return
+}
+
+func unmarshalZipXML(f *zip.File, v any) (err error) {
+	if nil == f {
+		return io.ErrUnexpectedEOF
+	}
+
+	reader, err := f.Open()
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return xml.NewDecoder(reader).Decode(v)
+}
+
+// pptxSlideText 提取幻灯片中 a:t 元素的文本，段落之间换行。
+func pptxSlideText(reader io.Reader) (ret string, err error) {
+	decoder := xml.NewDecoder(reader)
+	buf := &bytes.Buffer{}
+	inText := false
+	for {
+		token, tokenErr := decoder.Token()
+		if io.EOF == tokenErr {
+			break
+		}
+		if nil != tokenErr {
+			err = tokenErr
+			return
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if "t" == t.Name.Local {
+				inText = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				buf.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				buf.Write(t)
+			}
+		}
+	}
+	ret = buf.String()
+	return
+}
@@ -0,0 +1,107 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestXlsxSheetChunks(t *testing.T) {
+	var rows [][]string
+	for i := 0; i < XlsxAssetContentChunkRows+5; i++ {
+		rows = append(rows, []string{"a", "b"})
+	}
+	rows[XlsxAssetContentChunkRows+2] = []string{"x", "y", "needle"}
+
+	chunks := xlsxSheetChunks("Sheet1", rows)
+	if 2 != len(chunks) {
+		t.Fatalf("工作表分块数量错误：%d", len(chunks))
+	}
+	if "Sheet1!A1:B100" != chunks[0].Locator.String() {
+		t.Fatalf("第一个分块区域错误：%s", chunks[0].Locator)
+	}
+	if "Sheet1!A101:C105" != chunks[1].Locator.String() || !strings.Contains(chunks[1].Content, "needle") {
+		t.Fatalf("第二个分块错误：%s %q", chunks[1].Locator, chunks[1].Content)
+	}
+}
+
+func TestPptxSlideTextsFollowPresentationOrder(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "test.pptx")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter := zip.NewWriter(f)
+	files := map[string]string{
+		"ppt/presentation.xml":            `<p:presentation xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><p:sldIdLst><p:sldId id="256" r:id="rId3"/><p:sldId id="257" r:id="rId2"/></p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId2" Target="slides/slide1.xml"/><Relationship Id="rId3" Target="slides/slide2.xml"/></Relationships>`,
+		"ppt/slides/slide1.xml":           `<p:sld xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:p><a:r><a:t>Quar</a:t></a:r><a:r><a:t>terly</a:t></a:r></a:p></p:sld>`,
+		"ppt/slides/slide2.xml":           `<p:sld xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:p><a:r><a:t>封面</a:t></a:r></a:p></p:sld>`,
+	}
+	for name, content := range files {
+		w, createErr := zipWriter.Create(name)
+		if nil != createErr {
+			t.Fatal(createErr)
+		}
+		w.Write([]byte(content))
+	}
+	zipWriter.Close()
+	f.Close()
+
+	slides, err := pptxSlideTexts(p)
+	if err != nil {
+		t.Fatalf("解析幻灯片失败：%s", err)
+	}
+	if 2 != len(slides) || "封面" != strings.TrimSpace(slides[0]) || "Quarterly" != strings.TrimSpace(slides[1]) {
+		t.Fatalf("幻灯片顺序或文本错误：%q", slides)
+	}
+}
+
+func TestAssetParseResultToSQLAssetContents(t *testing.T) {
+	result := &AssetParseResult{
+		Path:    "assets/book-20240101120000-abcdefg.pdf",
+		Size:    1024,
+		Updated: 1,
+		Chunks: []*AssetContentChunk{
+			{Locator: &AssetContentLocator{Type: AssetContentLocatorPage, Page: 1}, Content: "first"},
+			{Locator: &AssetContentLocator{Type: AssetContentLocatorPage, Page: 3}, Content: "third"},
+		},
+	}
+	assetContents := result.toSQLAssetContents(".pdf")
+	if 2 != len(assetContents) {
+		t.Fatalf("分块记录数量错误：%d", len(assetContents))
+	}
+	if `{"type":"page","page":3}` != assetContents[1].Locator || "third" != assetContents[1].Content || "book.pdf" != assetContents[1].Name {
+		t.Fatalf("分块记录错误：%+v", assetContents[1])
+	}
+
+	locator := fromSQLAssetContent(assetContents[1]).Locator
+	if nil == locator || 3 != locator.Page {
+		t.Fatalf("分块定位信息解析错误：%+v", locator)
+	}
+
+	result.Chunks = nil
+	result.Content = "whole"
+	assetContents = result.toSQLAssetContents(".pdf")
+	if 1 != len(assetContents) || "" != assetContents[0].Locator || nil != fromSQLAssetContent(assetContents[0]).Locator {
+		t.Fatalf("未分块的文件应索引为一条无定位信息的记录：%+v", assetContents)
+	}
+}
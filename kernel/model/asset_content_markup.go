@@ -0,0 +1,640 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/88250/go-humanize"
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	MarkupAssetContentMaxSize = 1024 * 1024 * 16
+	MailAssetContentMaxSize   = 1024 * 1024 * 64
+	OdfAssetContentMaxSize    = 1024 * 1024 * 128
+)
+
+// readAssetForParse 复制资源文件到临时目录后读取内容，超过 maxSize 时跳过。
+func readAssetForParse(absPath string, maxSize int64) (ret []byte) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		logging.LogErrorf("stat file [%s] failed: %s", absPath, err)
+		return
+	}
+
+	if maxSize < info.Size() {
+		logging.LogWarnf("asset [%s] is too large [%s]", absPath, humanize.BytesCustomCeil(uint64(info.Size()), 2))
+		return
+	}
+
+	tmp := copyTempAsset(absPath)
+	if "" == tmp {
+		return
+	}
+	defer os.RemoveAll(tmp)
+
+	ret, err = os.ReadFile(tmp)
+	if err != nil {
+		logging.LogErrorf("read file [%s] failed: %s", absPath, err)
+		return nil
+	}
+	return
+}
+
+// HtmlAssetParser 解析 .html/.htm/.xhtml，去除标签、脚本和样式，仅保留文本。
+type HtmlAssetParser struct {
+}
+
+func (parser *HtmlAssetParser) Parse(absPath string) (ret *AssetParseResult) {
+	data := readAssetForParse(absPath, MarkupAssetContentMaxSize)
+	if nil == data {
+		return
+	}
+
+	content := normalizeNonTxtAssetContent(htmlToText(data, ""))
+	ret = &AssetParseResult{
+		Content: content,
+	}
+	return
+}
+
+// htmlSkipTags 为不包含正文的 HTML 元素。
+var htmlSkipTags = map[string]bool{"script": true, "style": true, "noscript": true, "template": true, "svg": true}
+
+// htmlInlineTags 为行级 HTML 元素，元素边界不插入空白，避免拆开单词。
+var htmlInlineTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true, "code": true, "data": true, "dfn": true,
+	"em": true, "font": true, "i": true, "kbd": true, "mark": true, "q": true, "s": true, "samp": true, "small": true,
+	"span": true, "strike": true, "strong": true, "sub": true, "sup": true, "time": true, "u": true, "var": true,
+}
+
+// htmlToText 提取 HTML 文本，contentType 用于识别 charset，为空时从 meta 标签中检测。
+func htmlToText(data []byte, contentType string) string {
+	reader, err := charset.NewReader(bytes.NewReader(data), contentType)
+	if err != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	buf := &bytes.Buffer{}
+	tokenizer := html.NewTokenizer(reader)
+	skipDepth := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return buf.String()
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if htmlSkipTags[string(name)] {
+				skipDepth++
+			}
+			if !htmlInlineTags[string(name)] {
+				buf.WriteByte(' ')
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if htmlSkipTags[string(name)] && 0 < skipDepth {
+				skipDepth--
+			}
+			if !htmlInlineTags[string(name)] {
+				buf.WriteByte(' ')
+			}
+		case html.SelfClosingTagToken:
+			buf.WriteByte(' ')
+		case html.TextToken:
+			if 0 == skipDepth {
+				buf.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// XmlAssetParser 解析 .xml，仅保留元素中的文本。
+type XmlAssetParser struct {
+}
+
+func (parser *XmlAssetParser) Parse(absPath string) (ret *AssetParseResult) {
+	data := readAssetForParse(absPath, MarkupAssetContentMaxSize)
+	if nil == data {
+		return
+	}
+
+	content, err := xmlToText(bytes.NewReader(data))
+	if err != nil {
+		logging.LogErrorf("parse xml [%s] failed: %s", absPath, err)
+		return
+	}
+	ret = &AssetParseResult{
+		Content: normalizeNonTxtAssetContent(content),
+	}
+	return
+}
+
+// odfInlineElements 为 ODF 的行级元素，元素边界不插入空白，避免拆开单词。
+var odfInlineElements = map[string]bool{"span": true, "a": true, "bookmark": true, "bookmark-start": true, "bookmark-end": true, "soft-page-break": true, "ruby": true, "ruby-base": true}
+
+// xmlToText 提取 XML 中的文本，元素边界插入空白，因此 ODF 的 text:s、text:tab 和 text:line-break 也会转换为空白。
+func xmlToText(reader io.Reader) (ret string, err error) {
+	decoder := xml.NewDecoder(reader)
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	buf := &bytes.Buffer{}
+	for {
+		token, tokenErr := decoder.Token()
+		if io.EOF == tokenErr {
+			break
+		}
+		if nil != tokenErr {
+			if 0 < buf.Len() {
+				// 尽量保留已解析的内容
+				break
+			}
+			err = tokenErr
+			return
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !odfInlineElements[t.Name.Local] {
+				buf.WriteByte(' ')
+			}
+		case xml.EndElement:
+			if !odfInlineElements[t.Name.Local] {
+				buf.WriteByte(' ')
+			}
+		case xml.CharData:
+			buf.Write(t)
+		}
+	}
+	ret = buf.String()
+	return
+}
+
+// OdfAssetParser 解析 OpenDocument 文本、表格和演示文稿（.odt/.ods/.odp）。
+type OdfAssetParser struct {
+}
+
+func (parser *OdfAssetParser) Parse(absPath string) (ret *AssetParseResult) {
+	if !gulu.File.IsExist(absPath) {
+		return
+	}
+
+	data := readAssetForParse(absPath, OdfAssetContentMaxSize)
+	if nil == data {
+		return
+	}
+
+	content, err := odfToText(data)
+	if err != nil {
+		logging.LogErrorf("convert [%s] failed: [%s]", absPath, err)
+		return
+	}
+	ret = &AssetParseResult{
+		Content: normalizeNonTxtAssetContent(content),
+	}
+	return
+}
+
+// odfToText 提取 ODF 压缩包中 content.xml 的文本，同时包含 meta.xml 中的标题等元数据。
+func odfToText(data []byte) (ret string, err error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return
+	}
+
+	var contents []string
+	for _, name := range []string{"meta.xml", "content.xml"} {
+		f, openErr := zipReader.Open(name)
+		if nil != openErr {
+			if "content.xml" == name {
+				err = openErr
+				return
+			}
+			continue
+		}
+
+		// 压缩包内的条目解压后可能远大于压缩包本身，限制读取大小
+		text, parseErr := xmlToText(io.LimitReader(f, OdfAssetContentMaxSize))
+		f.Close()
+		if nil != parseErr {
+			err = parseErr
+			return
+		}
+		contents = append(contents, text)
+	}
+	ret = strings.Join(contents, " ")
+	return
+}
+
+// RtfAssetParser 解析 .rtf。
+type RtfAssetParser struct {
+}
+
+func (parser *RtfAssetParser) Parse(absPath string) (ret *AssetParseResult) {
+	data := readAssetForParse(absPath, MarkupAssetContentMaxSize)
+	if nil == data {
+		return
+	}
+
+	ret = &AssetParseResult{
+		Content: normalizeNonTxtAssetContent(rtfToText(data)),
+	}
+	return
+}
+
+// rtfSkipDestinations 为不包含正文的 RTF 目标组。
+var rtfSkipDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "listtable": true, "listoverridetable": true,
+	"rsidtbl": true, "generator": true, "pict": true, "object": true, "themedata": true, "colorschememapping": true,
+	"latentstyles": true, "datastore": true, "xmlnstbl": true, "filetbl": true, "revtbl": true, "pgdsctbl": true,
+	"header": true, "headerl": true, "headerr": true, "headerf": true, "footer": true, "footerl": true, "footerr": true, "footerf": true,
+}
+
+// rtfToText 将 RTF 转换为纯文本，支持 \'hh 代码页转义（按 \ansicpg 解码）和 \uN Unicode 转义。
+func rtfToText(data []byte) string {
+	type group struct {
+		skip        bool
+		unicodeSkip int
+	}
+
+	decoder := charmap.Windows1252.NewDecoder()
+	buf := &bytes.Buffer{}
+	var pendingBytes []byte
+	flushBytes := func() {
+		if 0 < len(pendingBytes) {
+			if decoded, err := decoder.Bytes(pendingBytes); nil == err {
+				buf.Write(decoded)
+			}
+			pendingBytes = pendingBytes[:0]
+		}
+	}
+
+	stack := []group{{unicodeSkip: 1}}
+	skipChars := 0 // \uN 之后需要跳过的替代字符数
+	for i := 0; i < len(data); i++ {
+		current := &stack[len(stack)-1]
+		c := data[i]
+		switch c {
+		case '{':
+			flushBytes()
+			stack = append(stack, *current)
+			skipChars = 0
+		case '}':
+			flushBytes()
+			if 1 < len(stack) {
+				stack = stack[:len(stack)-1]
+			}
+			skipChars = 0
+		case '\\':
+			if i+1 >= len(data) {
+				break
+			}
+			next := data[i+1]
+			switch {
+			case '\'' == next && i+3 < len(data):
+				if b, err := strconv.ParseUint(string(data[i+2:i+4]), 16, 8); nil == err {
+					if 0 < skipChars {
+						skipChars--
+					} else if !current.skip {
+						pendingBytes = append(pendingBytes, byte(b))
+					}
+				}
+				i += 3
+			case '*' == next:
+				current.skip = true
+				i++
+			case '\\' == next || '{' == next || '}' == next:
+				flushBytes()
+				if !current.skip {
+					buf.WriteByte(next)
+				}
+				i++
+			case isASCIILetter(next):
+				flushBytes()
+				j := i + 1
+				for j < len(data) && isASCIILetter(data[j]) {
+					j++
+				}
+				word := string(data[i+1 : j])
+				k := j
+				if k < len(data) && ('-' == data[k] || isASCIIDigit(data[k])) {
+					k++
+					for k < len(data) && isASCIIDigit(data[k]) {
+						k++
+					}
+				}
+				param, hasParam := 0, k > j
+				if hasParam {
+					param, _ = strconv.Atoi(string(data[j:k]))
+				}
+				if k < len(data) && ' ' == data[k] {
+					k++ // 控制字后的空格是分隔符
+				}
+				i = k - 1
+
+				switch {
+				case rtfSkipDestinations[word]:
+					current.skip = true
+				case "ansicpg" == word:
+					if cm := rtfCodePage(param); nil != cm {
+						decoder = cm.NewDecoder()
+					}
+				case "uc" == word:
+					current.unicodeSkip = param
+				case "u" == word:
+					if !current.skip {
+						if 0 > param {
+							param += 65536
+						}
+						buf.WriteRune(rune(param))
+					}
+					skipChars = current.unicodeSkip
+				case "par" == word || "line" == word || "sect" == word || "page" == word || "row" == word:
+					if !current.skip {
+						buf.WriteByte('\n')
+					}
+				case "tab" == word || "cell" == word:
+					if !current.skip {
+						buf.WriteByte(' ')
+					}
+				}
+			default:
+				// 其他控制符号，如 \~ \- \_
+				flushBytes()
+				if '~' == next && !current.skip {
+					buf.WriteByte(' ')
+				}
+				i++
+			}
+		case '\r', '\n':
+		default:
+			flushBytes()
+			if 0 < skipChars {
+				skipChars--
+				continue
+			}
+			if !current.skip {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	flushBytes()
+	return buf.String()
+}
+
+func rtfCodePage(codePage int) *charmap.Charmap {
+	switch codePage {
+	case 1250:
+		return charmap.Windows1250
+	case 1251:
+		return charmap.Windows1251
+	case 1252:
+		return charmap.Windows1252
+	case 1253:
+		return charmap.Windows1253
+	case 1254:
+		return charmap.Windows1254
+	case 1255:
+		return charmap.Windows1255
+	case 1256:
+		return charmap.Windows1256
+	case 1257:
+		return charmap.Windows1257
+	case 1258:
+		return charmap.Windows1258
+	case 874:
+		return charmap.Windows874
+	}
+	return nil
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && 'z' >= c) || ('A' <= c && 'Z' >= c)
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && '9' >= c
+}
+
+// MailAssetParser 解析电子邮件（.eml）、邮箱归档（.mbox）和网页归档（.mhtml/.mht）。
+// 邮件头中的发件人、收件人、主题和日期单独索引到元数据字段，不混入正文。
+type MailAssetParser struct {
+	mbox bool
+}
+
+func (parser *MailAssetParser) Parse(absPath string) (ret *AssetParseResult) {
+	data := readAssetForParse(absPath, MailAssetContentMaxSize)
+	if nil == data {
+		return
+	}
+
+	var messages [][]byte
+	if parser.mbox {
+		messages = splitMbox(data)
+	} else {
+		messages = [][]byte{data}
+	}
+
+	var metas, contents []string
+	for _, message := range messages {
+		meta, content, err := mailToText(message)
+		if err != nil {
+			logging.LogWarnf("parse mail message in [%s] failed: %s", absPath, err)
+			continue
+		}
+		metas = append(metas, meta)
+		contents = append(contents, content)
+	}
+	if 1 > len(contents) {
+		return
+	}
+
+	ret = &AssetParseResult{
+		Content: normalizeNonTxtAssetContent(strings.Join(contents, "\n")),
+		Meta:    strings.TrimSpace(strings.Join(metas, "\n")),
+	}
+	return
+}
+
+// splitMbox 按 mboxrd 格式的 "From " 分隔行拆分邮件，并还原 ">From " 转义。
+func splitMbox(data []byte) (ret [][]byte) {
+	var current *bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), MailAssetContentMaxSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.HasPrefix(line, []byte("From ")) {
+			if nil != current {
+				ret = append(ret, current.Bytes())
+			}
+			current = &bytes.Buffer{}
+			continue
+		}
+		if nil == current {
+			continue
+		}
+
+		if unquoted := bytes.TrimLeft(line, ">"); len(unquoted) < len(line) && bytes.HasPrefix(unquoted, []byte("From ")) {
+			line = line[1:]
+		}
+		current.Write(line)
+		current.WriteByte('\n')
+	}
+	if nil != current {
+		ret = append(ret, current.Bytes())
+	}
+	return
+}
+
+var mailHeaderKeys = []string{"From", "To", "Cc", "Subject", "Date"}
+
+// mailToText 分别提取邮件头和正文文本，邮件头每行形如 "From: ..."。
+// 多部分邮件优先使用 text/plain 正文，没有时使用 text/html 正文。
+func mailToText(data []byte) (meta, body string, err error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	wordDecoder := &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+	buf := &bytes.Buffer{}
+	for _, key := range mailHeaderKeys {
+		value := msg.Header.Get(key)
+		if "" == value {
+			continue
+		}
+		if decoded, decodeErr := wordDecoder.DecodeHeader(value); nil == decodeErr {
+			value = decoded
+		}
+		buf.WriteString(key + ": " + value + "\n")
+	}
+
+	meta = buf.String()
+
+	plain, htmlText := mailPartText(textproto.MIMEHeader(msg.Header), msg.Body, 0)
+	body = plain
+	if "" == strings.TrimSpace(body) {
+		body = htmlText
+	}
+	return
+}
+
+// mailPartText 递归提取 MIME 部分的纯文本和 HTML 文本，附件只保留文件名。
+func mailPartText(header textproto.MIMEHeader, body io.Reader, depth int) (plain, htmlText string) {
+	if 16 < depth {
+		return
+	}
+
+	get := header.Get
+
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		var plains, htmls []string
+		for {
+			part, partErr := reader.NextRawPart()
+			if nil != partErr {
+				break
+			}
+			p, h := mailPartText(part.Header, part, depth+1)
+			if "" != p {
+				plains = append(plains, p)
+			}
+			if "" != h {
+				htmls = append(htmls, h)
+			}
+		}
+		return strings.Join(plains, "\n"), strings.Join(htmls, "\n")
+	}
+
+	if disposition, dispositionParams, _ := mime.ParseMediaType(get("Content-Disposition")); "attachment" == disposition {
+		if filename := dispositionParams["filename"]; "" != filename {
+			plain = filename
+		}
+		return
+	}
+
+	if "message/rfc822" == mediaType {
+		data, readErr := io.ReadAll(decodeTransferEncoding(get("Content-Transfer-Encoding"), body))
+		if nil != readErr {
+			return
+		}
+		// 附带的邮件属于正文内容，邮件头和正文一起保留
+		meta, body, _ := mailToText(data)
+		plain = strings.TrimSpace(meta + "\n" + body)
+		return
+	}
+
+	if !strings.HasPrefix(mediaType, "text/") {
+		return
+	}
+
+	data, err := io.ReadAll(decodeTransferEncoding(get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return
+	}
+
+	if "text/html" == mediaType {
+		htmlText = htmlToText(data, get("Content-Type"))
+		return
+	}
+
+	if cs := params["charset"]; "" != cs && !strings.EqualFold(cs, "utf-8") && !strings.EqualFold(cs, "us-ascii") {
+		if reader, csErr := charset.NewReaderLabel(cs, bytes.NewReader(data)); nil == csErr {
+			if decoded, readErr := io.ReadAll(reader); nil == readErr {
+				data = decoded
+			}
+		}
+	}
+	if !utf8.Valid(data) {
+		return
+	}
+	plain = string(data)
+	return
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
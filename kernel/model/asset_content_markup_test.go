@@ -0,0 +1,135 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHtmlToTextSkipsScripts(t *testing.T) {
+	data := []byte(`<html><head><title>标题</title><style>p{color:red}</style></head>
+<body><p>Hello <b>wor</b>ld</p><script>alert("x")</script><div>第二段</div></body></html>`)
+	text := htmlToText(data, "text/html")
+	for _, want := range []string{"标题", "Hello world", "第二段"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("HTML 文本缺少 %q：%q", want, text)
+		}
+	}
+	if strings.Contains(text, "alert") || strings.Contains(text, "color") {
+		t.Fatalf("HTML 文本不应包含脚本或样式：%q", text)
+	}
+}
+
+func TestOdfToText(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buf)
+	files := map[string]string{
+		"mimetype":    "application/vnd.oasis.opendocument.text",
+		"meta.xml":    `<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><office:meta><dc:title>会议纪要</dc:title></office:meta></office:document-meta>`,
+		"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"><office:body><office:text><text:p>Quar<text:span>terly</text:span> report</text:p><text:p>下一段</text:p></office:text></office:body></office:document-content>`,
+	}
+	for name, content := range files {
+		w, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zipWriter.Close()
+
+	text, err := odfToText(buf.Bytes())
+	if err != nil {
+		t.Fatalf("解析 ODF 失败：%s", err)
+	}
+	for _, want := range []string{"会议纪要", "Quarterly report", "下一段"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("ODF 文本缺少 %q：%q", want, text)
+		}
+	}
+}
+
+func TestRtfToText(t *testing.T) {
+	data := []byte(`{\rtf1\ansi\ansicpg1252{\fonttbl{\f0 Arial;}}{\*\generator Writer;}\f0 Caf\'e9 au lait\par \uc1 \u20013?\u25991?\tab end}`)
+	text := rtfToText(data)
+	for _, want := range []string{"Café au lait", "中文", "end"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("RTF 文本缺少 %q：%q", want, text)
+		}
+	}
+	if strings.Contains(text, "Arial") || strings.Contains(text, "Writer") || strings.Contains(text, "?") {
+		t.Fatalf("RTF 文本不应包含控制组内容：%q", text)
+	}
+}
+
+func TestMailToTextSeparatesHeaders(t *testing.T) {
+	data := []byte("From: Alice <alice@example.com>\r\n" +
+		"To: bob@example.com\r\n" +
+		"Subject: =?UTF-8?B?5Lya6K6u6YCa55+l?=\r\n" +
+		"Date: Mon, 02 Jan 2006 15:04:05 +0800\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Caf=C3=A9 at noon\r\n" +
+		"--b1\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Disposition: attachment; filename=\"agenda.pdf\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"JVBERi0=\r\n" +
+		"--b1--\r\n")
+	meta, text, err := mailToText(data)
+	if err != nil {
+		t.Fatalf("解析邮件失败：%s", err)
+	}
+	for _, want := range []string{"From: Alice <alice@example.com>", "To: bob@example.com", "Subject: 会议通知", "Date: Mon, 02 Jan 2006"} {
+		if !strings.Contains(meta, want) {
+			t.Fatalf("邮件头缺少 %q：%q", want, meta)
+		}
+	}
+	for _, want := range []string{"Café at noon", "agenda.pdf"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("邮件文本缺少 %q：%q", want, text)
+		}
+	}
+	if strings.Contains(text, "alice@example.com") || strings.Contains(text, "会议通知") {
+		t.Fatalf("邮件正文不应包含邮件头：%q", text)
+	}
+
+	assetContents := (&AssetParseResult{Path: "assets/mail.eml", Content: text, Meta: meta}).toSQLAssetContents(".eml")
+	if 1 != len(assetContents) || meta != assetContents[0].Meta || text != assetContents[0].Content {
+		t.Fatalf("邮件头应单独写入元数据字段：%v", assetContents)
+	}
+}
+
+func TestSplitMbox(t *testing.T) {
+	data := []byte("From alice@example.com Mon Jan  2 15:04:05 2006\nSubject: one\n\n>From the top\n" +
+		"From bob@example.com Mon Jan  2 15:04:05 2006\nSubject: two\n\nbody\n")
+	messages := splitMbox(data)
+	if 2 != len(messages) {
+		t.Fatalf("mbox 邮件数量错误：%d", len(messages))
+	}
+	if !bytes.Contains(messages[0], []byte("\nFrom the top\n")) {
+		t.Fatalf("mbox 未还原 >From 转义：%q", messages[0])
+	}
+}
@@ -17,6 +17,7 @@
 package model
 
 import (
+	gosql "database/sql"
 	"slices"
 	"strings"
 	"testing"
@@ -25,17 +26,74 @@ import (
 func TestAssetContentFieldRegexpUsesArguments(t *testing.T) {
 	payload := "x'); DELETE FROM asset_contents_fts_case_insensitive; --"
 	clause, args := assetContentFieldRegexp(payload)
-	if "(name REGEXP ? OR content REGEXP ?)" != clause {
+	if strings.Contains(clause, payload) || 4 != strings.Count(clause, "REGEXP ?") {
 		t.Fatalf("正则过滤子句未使用占位符：%q", clause)
 	}
-	if strings.Contains(clause, payload) {
-		t.Fatalf("正则过滤子句不应包含用户输入：%q", clause)
-	}
-	if 2 != len(args) || payload != args[0] || payload != args[1] {
+	if 4 != len(args) || slices.ContainsFunc(args, func(arg any) bool { return payload != arg }) {
 		t.Fatalf("正则过滤参数错误：%v", args)
 	}
 }
 
+func TestAssetContentFieldFilterDeduplicatesChunks(t *testing.T) {
+	testDB, err := gosql.Open("sqlite3_extended", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Close()
+	// 未使用 fts5 构建标签时退化为普通表，只验证正则搜索
+	fts := true
+	if _, err = testDB.Exec("CREATE VIRTUAL TABLE asset_contents_fts_case_insensitive USING fts5(id UNINDEXED, name, ext, path, size UNINDEXED, updated UNINDEXED, content, locator UNINDEXED, meta)"); err != nil {
+		fts = false
+		if _, err = testDB.Exec("CREATE TABLE asset_contents_fts_case_insensitive (id TEXT, name TEXT, ext TEXT, path TEXT, size INTEGER, updated INTEGER, content TEXT, locator TEXT, meta TEXT)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := [][]any{
+		{"1", "report.pdf", ".pdf", "assets/report.pdf", "alpha", `{"type":"page","page":1}`, ""},
+		{"2", "report.pdf", ".pdf", "assets/report.pdf", "needle", `{"type":"page","page":2}`, ""},
+		{"3", "report.pdf", ".pdf", "assets/report.pdf", "needle", `{"type":"page","page":3}`, ""},
+		{"4", "needle.txt", ".txt", "assets/needle.txt", "gamma", "", ""},
+		{"5", "mail.eml", ".eml", "assets/mail.eml", "body", "", "From: sender"},
+	}
+	for _, row := range rows {
+		if _, err = testDB.Exec("INSERT INTO asset_contents_fts_case_insensitive VALUES (?, ?, ?, ?, 0, 0, ?, ?, ?)", row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		query        string
+		hits, assets int
+		regexp       bool
+	}{
+		{"report", 1, 1, false},
+		{"needle", 3, 2, false},
+		{"report", 1, 1, true},
+		{"needle", 3, 2, true},
+		{"sender", 1, 1, false},
+		{"sender", 1, 1, true},
+	}
+	for _, c := range cases {
+		if !fts && !c.regexp {
+			continue
+		}
+
+		clause, args := assetContentFieldMatch(c.query)
+		if c.regexp {
+			clause, args = assetContentFieldRegexp(c.query)
+		}
+
+		var hits, assets int
+		stmt := "SELECT COUNT(*), COUNT(DISTINCT path) FROM asset_contents_fts_case_insensitive WHERE " + clause
+		if err = testDB.QueryRow(stmt, args...).Scan(&hits, &assets); err != nil {
+			t.Fatal(err)
+		}
+		if c.hits != hits || c.assets != assets {
+			t.Fatalf("搜索 [%s]（正则：%v）应命中 %d 条分块、%d 个文件，实际为 %d 条、%d 个", c.query, c.regexp, c.hits, c.assets, hits, assets)
+		}
+	}
+}
+
 func TestBuildAssetContentTypeFilterUsesArguments(t *testing.T) {
 	payload := ".pdf'); DELETE FROM asset_contents_fts_case_insensitive; --"
 	clause, args := buildAssetContentTypeFilter(map[string]bool{
@@ -69,7 +127,12 @@ func TestBuildAssetContentTypeFilterEmpty(t *testing.T) {
 func TestPDFParser(t *testing.T) {
 	p := &PdfAssetParser{}
 	res := p.Parse("../testdata/parsertest.pdf")
-	if res == nil || res.Content == "" {
+	if res == nil || 1 > len(res.Chunks) {
 		t.Fatalf("empty or nil PDF content result")
 	}
+	for _, chunk := range res.Chunks {
+		if AssetContentLocatorPage != chunk.Locator.Type || 1 > chunk.Locator.Page || "" == chunk.Content {
+			t.Fatalf("invalid PDF page chunk %+v", chunk.Locator)
+		}
+	}
 }
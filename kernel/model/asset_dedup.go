@@ -0,0 +1,335 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// AssetDedupGroup 描述了一组内容相同的资源文件，Keep 为保留的资源，Duplicates 中的资源合并到 Keep。
+type AssetDedupGroup struct {
+	Hash       string   `json:"hash"`
+	Size       int64    `json:"size"`
+	Keep       string   `json:"keep"`
+	Duplicates []string `json:"duplicates"`
+}
+
+// AssetDedupResult 是资源去重的结果，预览时不修改文件。
+type AssetDedupResult struct {
+	Groups       []*AssetDedupGroup `json:"groups"`
+	RemovedCount int                `json:"removedCount"`
+	SavedSize    int64              `json:"savedSize"`
+	UpdatedDocs  int                `json:"updatedDocs"`
+	UpdatedAVs   int                `json:"updatedAVs"`
+	DryRun       bool               `json:"dryRun"`
+}
+
+type assetDedupFile struct {
+	path    string // 以 assets/ 开头的链接地址
+	absPath string
+	size    int64
+	modTime time.Time
+}
+
+// DeduplicateAssets 合并 data/assets 下内容相同的资源文件：保留最早的一份，将文档和数据库中指向其他副本的链接改写为保留的资源，
+// 然后删除其他副本（删除前保存到历史）。加密笔记本的资源不参与去重。dryRun 为 true 时只返回重复分组。
+func DeduplicateAssets(dryRun bool) (ret *AssetDedupResult, err error) {
+	ret = &AssetDedupResult{Groups: []*AssetDedupGroup{}, DryRun: dryRun}
+
+	FlushTxQueue()
+	if !dryRun {
+		util.PushEndlessProgress(Conf.Language(110))
+		defer util.PushClearProgress()
+	}
+
+	ret.Groups = findDuplicatedAssets(util.GetDataAssetsAbsPath())
+	for _, group := range ret.Groups {
+		ret.RemovedCount += len(group.Duplicates)
+		ret.SavedSize += group.Size * int64(len(group.Duplicates))
+	}
+	if dryRun || 1 > len(ret.Groups) {
+		return
+	}
+
+	replacements := map[string]string{}
+	for _, group := range ret.Groups {
+		for _, dup := range group.Duplicates {
+			replacements[dup] = group.Keep
+		}
+	}
+
+	historyDir, err := getHistoryDir(HistoryOpClean)
+	if err != nil {
+		return
+	}
+
+	var rootIDs []string
+	if rootIDs, err = rewriteAssetLinksInTrees(replacements, historyDir); err != nil {
+		return
+	}
+	ret.UpdatedDocs = len(rootIDs)
+	if ret.UpdatedAVs, err = rewriteAssetLinksInAttrViews(replacements); err != nil {
+		return
+	}
+
+	for _, group := range ret.Groups {
+		for _, dup := range group.Duplicates {
+			absPath := filepath.Join(util.DataDir, dup)
+			if err = filelock.Copy(absPath, filepath.Join(historyDir, dup)); err != nil {
+				return
+			}
+
+			// PDF 标注随资源一起迁移，保留的资源已有标注时不覆盖
+			if dupSya, keepSya := absPath+".sya", filepath.Join(util.DataDir, group.Keep)+".sya"; filelock.IsExist(dupSya) {
+				if !filelock.IsExist(keepSya) {
+					if renameErr := filelock.Rename(dupSya, keepSya); nil != renameErr {
+						logging.LogErrorf("move PDF annotation [%s] failed: %s", dupSya, renameErr)
+					}
+				} else if removeErr := filelock.RemoveWithoutFatal(dupSya); nil != removeErr {
+					logging.LogErrorf("remove PDF annotation [%s] failed: %s", dupSya, removeErr)
+				}
+			}
+
+			if ocrText := util.GetAssetText(dup); "" != ocrText && "" == util.GetAssetText(group.Keep) {
+				util.SetAssetText(group.Keep, ocrText)
+			}
+
+			if util.IsMobileContainer() {
+				HandleAssetsRemoveEvent(absPath)
+			}
+			if err = filelock.RemoveWithoutFatal(absPath); err != nil {
+				logging.LogErrorf("remove duplicated asset [%s] failed: %s", absPath, err)
+				return
+			}
+			util.RemoveAssetText(dup)
+		}
+		cache.SetAssetHash(group.Hash, group.Keep)
+	}
+
+	indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+	sql.FlushQueue()
+	cache.LoadAssets()
+	InvalidateUndoLog(rootIDs)
+	for _, rootID := range rootIDs {
+		ReloadProtyle(rootID)
+	}
+	IncSync()
+	return
+}
+
+// findDuplicatedAssets 查找 assetsDir 下内容相同的资源文件。先按大小分组，只对大小相同的文件计算 hash。
+func findDuplicatedAssets(assetsDir string) (ret []*AssetDedupGroup) {
+	ret = []*AssetDedupGroup{}
+	sizeFiles := map[int64][]*assetDedupFile{}
+	filelock.Walk(assetsDir, func(absPath string, d fs.DirEntry, err error) error {
+		if nil != err || nil == d {
+			return err
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".sya") || strings.HasPrefix(d.Name(), ".") || filelock.IsHidden(absPath) || util.IsOfficeTempFile(absPath) {
+			return nil
+		}
+
+		info, infoErr := d.Info()
+		if nil != infoErr || 1 > info.Size() {
+			return nil
+		}
+		p := "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir))
+		sizeFiles[info.Size()] = append(sizeFiles[info.Size()], &assetDedupFile{path: p, absPath: absPath, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+
+	for size, files := range sizeFiles {
+		if 2 > len(files) {
+			continue
+		}
+
+		hashFiles := map[string][]*assetDedupFile{}
+		for _, file := range files {
+			hash, hashErr := util.GetEtag(file.absPath)
+			if nil != hashErr {
+				logging.LogWarnf("get asset [%s] hash failed: %s", file.absPath, hashErr)
+				continue
+			}
+			hashFiles[hash] = append(hashFiles[hash], file)
+		}
+
+		for hash, sameFiles := range hashFiles {
+			if 2 > len(sameFiles) {
+				continue
+			}
+
+			// 保留最早的资源，修改时间相同时按路径排序保证结果稳定
+			sort.Slice(sameFiles, func(i, j int) bool {
+				if !sameFiles[i].modTime.Equal(sameFiles[j].modTime) {
+					return sameFiles[i].modTime.Before(sameFiles[j].modTime)
+				}
+				return sameFiles[i].path < sameFiles[j].path
+			})
+			group := &AssetDedupGroup{Hash: hash, Size: size, Keep: sameFiles[0].path}
+			for _, file := range sameFiles[1:] {
+				group.Duplicates = append(group.Duplicates, file.path)
+			}
+			ret = append(ret, group)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Keep < ret[j].Keep })
+	return
+}
+
+// newAssetLinkReplacer 返回将资源链接地址按 replacements 改写的 Replacer，较长的地址优先匹配。
+func newAssetLinkReplacer(replacements map[string]string) *strings.Replacer {
+	var olds []string
+	for old := range replacements {
+		olds = append(olds, old)
+	}
+	sort.Slice(olds, func(i, j int) bool { return len(olds[i]) > len(olds[j]) })
+
+	var oldnew []string
+	for _, old := range olds {
+		oldnew = append(oldnew, old, replacements[old])
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// rewriteAssetLinksInTrees 改写所有非加密笔记本文档中的资源链接地址，返回被修改的文档 ID。
+func rewriteAssetLinksInTrees(replacements map[string]string, historyDir string) (rootIDs []string, err error) {
+	notebooks, err := ListNotebooks()
+	if err != nil {
+		return
+	}
+
+	replacer := newAssetLinkReplacer(replacements)
+	luteEngine := util.NewLute()
+	for _, notebook := range notebooks {
+		// 加密笔记本的资源不在 data/assets 下，不参与去重
+		if IsEncryptedBox(notebook.ID) {
+			continue
+		}
+
+		for _, paths := range pagedPaths(filepath.Join(util.DataDir, notebook.ID), 32) {
+			for _, treeAbsPath := range paths {
+				data, readErr := filelock.ReadFile(treeAbsPath)
+				if nil != readErr {
+					logging.LogErrorf("get data [path=%s] failed: %s", treeAbsPath, readErr)
+					err = readErr
+					return
+				}
+
+				content := string(data)
+				replaced := replacer.Replace(content)
+				if replaced == content {
+					continue
+				}
+
+				rootID := util.GetTreeID(treeAbsPath)
+				p := filepath.ToSlash(strings.TrimPrefix(treeAbsPath, filepath.Join(util.DataDir, notebook.ID)))
+				if oldTree, parseErr := filesys.LoadTreeByData(data, notebook.ID, p, luteEngine); nil == parseErr {
+					generateTreeHistory(oldTree, historyDir)
+				}
+
+				data = []byte(replaced)
+				if writeErr := filelock.WriteFile(treeAbsPath, data); nil != writeErr {
+					logging.LogErrorf("write data [path=%s] failed: %s", treeAbsPath, writeErr)
+					err = writeErr
+					return
+				}
+
+				cache.RemoveTreeData(rootID)
+				tree, parseErr := filesys.LoadTreeByData(data, notebook.ID, p, luteEngine)
+				if nil != parseErr {
+					logging.LogWarnf("parse json to tree [%s] failed: %s", treeAbsPath, parseErr)
+					continue
+				}
+
+				treenode.UpsertBlockTree(tree)
+				sql.UpsertTreeQueue(tree)
+				rootIDs = append(rootIDs, tree.ID)
+				util.PushEndlessProgress(fmt.Sprintf(Conf.Language(111), util.EscapeHTML(tree.Root.IALAttr("title"))))
+			}
+		}
+	}
+	return
+}
+
+// rewriteAssetLinksInAttrViews 改写数据库资源字段中的链接地址，返回被修改的数据库数量。
+func rewriteAssetLinksInAttrViews(replacements map[string]string) (count int, err error) {
+	storageAvDir := filepath.Join(util.DataDir, "storage", "av")
+	if !gulu.File.IsDir(storageAvDir) {
+		return
+	}
+
+	entries, err := os.ReadDir(storageAvDir)
+	if err != nil {
+		logging.LogErrorf("read dir [%s] failed: %s", storageAvDir, err)
+		return
+	}
+
+	replacer := newAssetLinkReplacer(replacements)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") || !ast.IsNodeIDPattern(strings.TrimSuffix(entry.Name(), ".json")) {
+			continue
+		}
+
+		avPath := filepath.Join(storageAvDir, entry.Name())
+		data, readErr := filelock.ReadFile(avPath)
+		if nil != readErr {
+			logging.LogErrorf("read file [%s] failed: %s", avPath, readErr)
+			err = readErr
+			return
+		}
+
+		if util.IsCiphertext(data) {
+			continue
+		}
+
+		content := string(data)
+		replaced := replacer.Replace(content)
+		if replaced == content {
+			continue
+		}
+		if err = filelock.WriteFile(avPath, []byte(replaced)); err != nil {
+			logging.LogErrorf("write file [%s] failed: %s", avPath, err)
+			return
+		}
+		cache.RemoveAVData(strings.TrimSuffix(entry.Name(), ".json"))
+		count++
+		util.PushEndlessProgress(fmt.Sprintf(Conf.Language(111), util.EscapeHTML(entry.Name())))
+	}
+	return
+}
@@ -0,0 +1,82 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindDuplicatedAssets(t *testing.T) {
+	assetsDir := t.TempDir()
+	files := map[string]string{
+		"a-20240101000000-aaaaaaa.png":     "same",
+		"sub/b-20240101000000-bbbbbbb.png": "same",
+		"c-20240101000000-ccccccc.png":     "same",
+		"d-20240101000000-ddddddd.png":     "diff",
+		"e-20240101000000-eeeeeee.png":     "",
+		"f-20240101000000-fffffff.png":     "",
+	}
+	base := time.Now().Add(-time.Hour)
+	for name, content := range files {
+		p := filepath.Join(assetsDir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 子目录中的副本最早，应被保留；其余副本修改时间相同，按路径排序
+	for name, modTime := range map[string]time.Time{
+		"sub/b-20240101000000-bbbbbbb.png": base,
+		"a-20240101000000-aaaaaaa.png":     base.Add(time.Minute),
+		"c-20240101000000-ccccccc.png":     base.Add(time.Minute),
+	} {
+		if err := os.Chtimes(filepath.Join(assetsDir, name), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups := findDuplicatedAssets(assetsDir)
+	if 1 != len(groups) {
+		t.Fatalf("应找到 1 组重复资源，实际为 %d 组", len(groups))
+	}
+	group := groups[0]
+	if "assets/sub/b-20240101000000-bbbbbbb.png" != group.Keep {
+		t.Fatalf("保留的资源错误：%s", group.Keep)
+	}
+	if 2 != len(group.Duplicates) || "assets/a-20240101000000-aaaaaaa.png" != group.Duplicates[0] || "assets/c-20240101000000-ccccccc.png" != group.Duplicates[1] {
+		t.Fatalf("重复资源错误：%v", group.Duplicates)
+	}
+	if 4 != group.Size {
+		t.Fatalf("资源大小错误：%d", group.Size)
+	}
+}
+
+func TestNewAssetLinkReplacer(t *testing.T) {
+	replacer := newAssetLinkReplacer(map[string]string{
+		"assets/a.png":     "assets/keep.png",
+		"assets/a.png.bak": "assets/keep2.png",
+	})
+	got := replacer.Replace(`{"Data":"assets/a.png"} ![x](assets/a.png.bak)`)
+	if `{"Data":"assets/keep.png"} ![x](assets/keep2.png)` != got {
+		t.Fatalf("资源链接改写错误：%s", got)
+	}
+}
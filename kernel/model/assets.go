@@ -118,12 +118,25 @@ func GetAssetPathByHash(hash, boxID string) string {
 		if nil == sqlAsset {
 			return ""
 		}
+		// 索引中的资源文件可能已经被删除
+		if _, absErr := GetAssetAbsPath(sqlAsset.Path); nil != absErr {
+			return ""
+		}
 		cache.SetAssetHash(sqlAsset.Hash, sqlAsset.Path)
 		return sqlAsset.Path
 	}
 	return assetHash.Path
 }
 
+// reusableAssetPath 返回内容相同（hash 一致）的已有资源路径，上传时直接复用而不再写入新文件。
+// 空文件等使用随机 hash 的资源不复用。
+func reusableAssetPath(hash, boxID string) string {
+	if strings.HasPrefix(hash, "random_") {
+		return ""
+	}
+	return strings.TrimPrefix(GetAssetPathByHash(hash, boxID), "/")
+}
+
 func HandleAssetsRemoveEvent(assetAbsPath string) {
 	if !filelock.IsExist(assetAbsPath) {
 		return
@@ -220,7 +233,8 @@ func NeedGenerateAssetsThumbnail(sourceImgPath string) bool {
 
 func GenerateAssetsThumbnail(sourceImgPath, resizedImgPath string) (err error) {
 	start := time.Now()
-	img, err := imaging.Open(sourceImgPath)
+	// 按 EXIF 方向旋转，与上传图片处理保持一致
+	img, err := imaging.Open(sourceImgPath, imaging.AutoOrientation(true))
 	if err != nil {
 		return
 	}
@@ -245,7 +259,20 @@ func GenerateAssetsThumbnail(sourceImgPath, resizedImgPath string) (err error) {
 	if err != nil {
 		return
 	}
-	err = imaging.Save(resizedImg, resizedImgPath)
+	quality := conf.NewImagePipeline().Quality
+	if nil != Conf.Image {
+		quality = Conf.Image.Quality
+	}
+	if ".webp" == strings.ToLower(filepath.Ext(resizedImgPath)) {
+		// imaging 不支持编码 WebP，比如图片处理转换得到的 WebP 资源
+		buf := &bytes.Buffer{}
+		if err = encodeImage(buf, resizedImg, "webp", quality); err != nil {
+			return
+		}
+		err = os.WriteFile(resizedImgPath, buf.Bytes(), 0644)
+	} else {
+		err = imaging.Save(resizedImg, resizedImgPath, imaging.JPEGQuality(quality))
+	}
 	if err != nil {
 		return
 	}
@@ -422,13 +449,19 @@ func AnalyzeImage(ctx context.Context, data []byte, question, detail string) (An
 	if err := validateImageModel(provider, visionModel); err != nil {
 		return AnalyzeImageResult{}, err
 	}
+	if err := CheckAIBudget(visionModel.Name); err != nil {
+		return AnalyzeImageResult{}, err
+	}
 	prepared, err := util.PrepareForVision(data, Conf.AI.Vision.MaxImageBytes, Conf.AI.Vision.MaxPixels, Conf.AI.Vision.MaxEdge)
 	if err != nil {
 		return AnalyzeImageResult{}, err
 	}
-	analysis, err := util.NewOpenAIImageAdapter(
-		provider.APIKey, provider.BaseURL, visionModel.Name, Conf.AI.Vision.RequestTimeout,
+	analysis, usage, err := util.NewImageAdapter(
+		provider.Protocol, provider.APIKey, provider.BaseURL, visionModel.Name, Conf.AI.Vision.RequestTimeout,
 	).Analyze(ctx, prepared, question, detail)
+	if usage.PromptTokens > 0 || err == nil {
+		RecordAIUsage(AIFeatureVision, AIProviderName(provider), visionModel.Name, "", usage)
+	}
 	if err != nil {
 		return AnalyzeImageResult{}, markImageExecutionUnknown(fmt.Errorf("analyze image failed: %w", err))
 	}
@@ -444,6 +477,9 @@ func GenerateImage(ctx context.Context, request GenerateImageRequest) (GenerateI
 	if err := validateImageModel(provider, generationModel); err != nil {
 		return GenerateImageResult{}, err
 	}
+	if provider.Protocol != "" && provider.Protocol != util.AIProtocolOpenAI {
+		return GenerateImageResult{}, fmt.Errorf("unsupported image generation provider protocol: %s", provider.Protocol)
+	}
 	prompt := strings.TrimSpace(request.Prompt)
 	if prompt == "" {
 		return GenerateImageResult{}, errors.New("prompt is required for image generation")
@@ -454,6 +490,9 @@ func GenerateImage(ctx context.Context, request GenerateImageRequest) (GenerateI
 	if outputFormat != "png" && outputFormat != "jpeg" && outputFormat != "webp" {
 		return GenerateImageResult{}, errors.New("unsupported image output format")
 	}
+	if err := CheckAIBudget(generationModel.Name); err != nil {
+		return GenerateImageResult{}, err
+	}
 	generated, err := util.NewOpenAIImageAdapter(
 		provider.APIKey, provider.BaseURL, generationModel.Name, Conf.AI.ImageGeneration.RequestTimeout,
 	).Generate(ctx, util.GenerateImageRequest{
@@ -462,6 +501,7 @@ func GenerateImage(ctx context.Context, request GenerateImageRequest) (GenerateI
 	if err != nil {
 		return GenerateImageResult{}, markImageExecutionUnknown(fmt.Errorf("generate image failed: %w", err))
 	}
+	RecordAIUsage(AIFeatureImageGeneration, AIProviderName(provider), generationModel.Name, "", generated.Usage)
 	if ctx.Err() != nil {
 		return GenerateImageResult{}, markImageExecutionUnknown(errors.New("image generation was cancelled"))
 	}
@@ -506,7 +546,7 @@ func validateImageModel(provider *conf.Provider, imageModel *conf.Model) error {
 	if provider == nil || imageModel == nil {
 		return errors.New("image model is not configured")
 	}
-	if provider.Protocol != "" && provider.Protocol != "openai" {
+	if provider.Protocol != "" && !util.IsAIProtocol(provider.Protocol) {
 		return fmt.Errorf("unsupported multimodal provider protocol: %s", provider.Protocol)
 	}
 	return nil
@@ -42,8 +42,9 @@ func TestAnalyzeImageDoesNotRequireDocument(t *testing.T) {
 	}))
 	defer server.Close()
 
-	originalConf := Conf
-	t.Cleanup(func() { Conf = originalConf })
+	originalConf, originalDataDir := Conf, util.DataDir
+	t.Cleanup(func() { Conf, util.DataDir = originalConf, originalDataDir })
+	util.DataDir = t.TempDir()
 	ai := conf.NewAI()
 	modelID := "20260715130000-abcdefg"
 	ai.Providers = []*conf.Provider{{
@@ -78,8 +79,9 @@ func TestGenerateImageDoesNotRequireDocument(t *testing.T) {
 	}))
 	defer server.Close()
 
-	originalConf := Conf
-	t.Cleanup(func() { Conf = originalConf })
+	originalConf, originalDataDir := Conf, util.DataDir
+	t.Cleanup(func() { Conf, util.DataDir = originalConf, originalDataDir })
+	util.DataDir = t.TempDir()
 	ai := conf.NewAI()
 	modelID := "20260715130000-hijklmn"
 	ai.Providers = []*conf.Provider{{
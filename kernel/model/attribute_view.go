@@ -35,6 +35,7 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
 	"github.com/jinzhu/copier"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/av"
@@ -409,6 +410,11 @@ func getAttrViewAddingBlockDefaultValues(attrView *av.AttributeView, view, group
 		return
 	}
 
+	if av.KeyTypeFormula == groupKey.Type {
+		// 公式字段的值是计算生成的，不需要设置分组值
+		return
+	}
+
 	newValue := getNewValueByNearItem(nearItem, groupKey, addingItemID)
 	if av.KeyTypeSelect == groupKey.Type || av.KeyTypeMSelect == groupKey.Type {
 		// 因为单选或多选只能按选项分组，并且可能存在空白分组（找不到临近项），所以单选或多选类型的分组字段使用分组值内容对应的选项
@@ -731,7 +737,7 @@ func syncAttrViewTableColWidth(operation *Operation) (err error) {
 				break
 			}
 		}
-	case av.LayoutTypeGallery, av.LayoutTypeKanban:
+	case av.LayoutTypeGallery, av.LayoutTypeKanban, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	}
 
@@ -934,7 +940,7 @@ func setAttributeViewGroup(attrView *av.AttributeView, view *av.View, group *av.
 				// 如果分组字段是单选或多选，则将分组排序方式改为按选项排序 https://github.com/siyuan-note/siyuan/issues/15534
 				view.Group.Order = av.GroupOrderSelectOption
 				sortGroupsBySelectOption(view, groupKey)
-			} else if av.KeyTypeCheckbox == groupKey.Type {
+			} else if av.KeyTypeCheckbox == groupKey.Type || (av.KeyTypeFormula == groupKey.Type && nil != groupKey.Formula && av.KeyTypeCheckbox == groupKey.Formula.ResultType) {
 				// 如果分组字段是复选框，则将分组排序改为手动排序，并且已勾选在前面
 				view.Group.Order = av.GroupOrderMan
 				checked := view.GetGroupByGroupValue(av.CheckboxCheckedStr)
@@ -971,7 +977,7 @@ func setAttrViewCardAspectRatio(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	case av.LayoutTypeGallery:
 		view.Gallery.CardAspectRatio = av.CardAspectRatio(operation.Data.(float64))
@@ -1019,7 +1025,7 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 
 	switch newLayout {
 	case av.LayoutTypeTable:
-		if view.Name == av.GetAttributeViewI18n("gallery") || view.Name == av.GetAttributeViewI18n("kanban") {
+		if isAttrViewDefaultViewName(view.Name) {
 			view.Name = av.GetAttributeViewI18n("table")
 		}
 
@@ -1037,9 +1043,13 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 			for _, field := range view.Kanban.Fields {
 				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+			for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: fieldID}})
+			}
 		}
 	case av.LayoutTypeGallery:
-		if view.Name == av.GetAttributeViewI18n("table") || view.Name == av.GetAttributeViewI18n("kanban") {
+		if isAttrViewDefaultViewName(view.Name) {
 			view.Name = av.GetAttributeViewI18n("gallery")
 		}
 
@@ -1057,9 +1067,13 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 			for _, field := range view.Kanban.Fields {
 				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+			for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: fieldID}})
+			}
 		}
 	case av.LayoutTypeKanban:
-		if view.Name == av.GetAttributeViewI18n("table") || view.Name == av.GetAttributeViewI18n("gallery") {
+		if isAttrViewDefaultViewName(view.Name) {
 			view.Name = av.GetAttributeViewI18n("kanban")
 		}
 
@@ -1077,6 +1091,10 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 			for _, field := range view.Gallery.CardFields {
 				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+			for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: fieldID}})
+			}
 		}
 
 		if !view.IsGroupView() {
@@ -1084,6 +1102,30 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 			group := &av.ViewGroup{Field: preferredGroupKey.ID}
 			setAttributeViewGroup(attrView, view, group)
 		}
+	case av.LayoutTypeCalendar:
+		if isAttrViewDefaultViewName(view.Name) {
+			view.Name = av.GetAttributeViewI18n("calendar")
+		}
+
+		if nil == view.Calendar {
+			view.Calendar = av.NewLayoutCalendar()
+			for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+				view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: &av.BaseField{ID: fieldID}})
+			}
+		}
+		setAttrViewPreferredDateKey(attrView, view)
+	case av.LayoutTypeTimeline:
+		if isAttrViewDefaultViewName(view.Name) {
+			view.Name = av.GetAttributeViewI18n("timeline")
+		}
+
+		if nil == view.Timeline {
+			view.Timeline = av.NewLayoutTimeline()
+			for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+				view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: &av.BaseField{ID: fieldID}})
+			}
+		}
+		setAttrViewPreferredDateKey(attrView, view)
 	}
 
 	blockIDs := treenode.GetMirrorAttrViewBlockIDs(avID)
@@ -1129,6 +1171,16 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 	return
 }
 
+// isAttrViewDefaultViewName 判断视图名称是否为某种布局的默认名称，切换布局时默认名称跟随布局变化。
+func isAttrViewDefaultViewName(name string) bool {
+	for _, layout := range []string{"table", "gallery", "kanban", "calendar", "timeline"} {
+		if name == av.GetAttributeViewI18n(layout) {
+			return true
+		}
+	}
+	return false
+}
+
 func (tx *Transaction) doSetAttrViewWrapField(operation *Operation) (ret *TxErr) {
 	err := setAttrViewWrapField(operation)
 	if err != nil {
@@ -1165,6 +1217,16 @@ func setAttrViewWrapField(operation *Operation) (err error) {
 		for _, field := range view.Kanban.Fields {
 			field.Wrap = allFieldWrap
 		}
+	case av.LayoutTypeCalendar:
+		view.Calendar.WrapField = allFieldWrap
+		for _, field := range view.Calendar.Fields {
+			field.Wrap = allFieldWrap
+		}
+	case av.LayoutTypeTimeline:
+		view.Timeline.WrapField = allFieldWrap
+		for _, field := range view.Timeline.Fields {
+			field.Wrap = allFieldWrap
+		}
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -1197,6 +1259,10 @@ func setAttrViewShowIcon(operation *Operation) (err error) {
 		view.Gallery.ShowIcon = operation.Data.(bool)
 	case av.LayoutTypeKanban:
 		view.Kanban.ShowIcon = operation.Data.(bool)
+	case av.LayoutTypeCalendar:
+		view.Calendar.ShowIcon = operation.Data.(bool)
+	case av.LayoutTypeTimeline:
+		view.Timeline.ShowIcon = operation.Data.(bool)
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -1223,7 +1289,7 @@ func setAttrViewFitImage(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	case av.LayoutTypeGallery:
 		view.Gallery.FitImage = operation.Data.(bool)
@@ -1269,6 +1335,10 @@ func setAttrViewDisplayFieldName(operation *Operation) (err error) {
 		view.Gallery.DisplayFieldName = operation.Data.(bool)
 	case av.LayoutTypeKanban:
 		view.Kanban.DisplayFieldName = operation.Data.(bool)
+	case av.LayoutTypeCalendar:
+		view.Calendar.DisplayFieldName = operation.Data.(bool)
+	case av.LayoutTypeTimeline:
+		view.Timeline.DisplayFieldName = operation.Data.(bool)
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -1289,7 +1359,7 @@ func setAttrViewFillColBackgroundColor(operation *Operation) (err error) {
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
 		return
-	case av.LayoutTypeGallery:
+	case av.LayoutTypeGallery, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	case av.LayoutTypeKanban:
 		view.Kanban.FillColBackgroundColor = operation.Data.(bool)
@@ -1319,7 +1389,7 @@ func setAttrViewCardSize(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	case av.LayoutTypeGallery:
 		view.Gallery.CardSize = av.CardSize(operation.Data.(float64))
@@ -1351,7 +1421,7 @@ func setAttrViewCoverFromAssetKeyID(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	case av.LayoutTypeGallery:
 		view.Gallery.CoverFromAssetKeyID = operation.KeyID
@@ -1383,7 +1453,7 @@ func setAttrViewCoverFrom(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	case av.LayoutTypeGallery:
 		view.Gallery.CoverFrom = av.CoverFrom(operation.Data.(float64))
@@ -1395,6 +1465,158 @@ func setAttrViewCoverFrom(operation *Operation) (err error) {
 	return
 }
 
+func (tx *Transaction) doSetAttrViewStartDateKeyID(operation *Operation) (ret *TxErr) {
+	err := setAttrViewDateKeyID(operation, true)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func (tx *Transaction) doSetAttrViewEndDateKeyID(operation *Operation) (ret *TxErr) {
+	err := setAttrViewDateKeyID(operation, false)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// setAttrViewDateKeyID 设置日历和时间线视图的开始/结束日期字段，结束日期字段可以置空。
+func setAttrViewDateKeyID(operation *Operation, isStart bool) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if "" != operation.KeyID || isStart {
+		key, _ := attrView.GetKey(operation.KeyID)
+		if nil == key || av.KeyTypeDate != key.Type {
+			return av.ErrInvalidDateKey
+		}
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable, av.LayoutTypeGallery, av.LayoutTypeKanban:
+		return
+	case av.LayoutTypeCalendar:
+		if isStart {
+			view.Calendar.StartKeyID = operation.KeyID
+		} else {
+			view.Calendar.EndKeyID = operation.KeyID
+		}
+	case av.LayoutTypeTimeline:
+		if isStart {
+			view.Timeline.StartKeyID = operation.KeyID
+		} else {
+			view.Timeline.EndKeyID = operation.KeyID
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewCalendarMode(operation *Operation) (ret *TxErr) {
+	err := setAttrViewCalendarMode(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttrViewCalendarMode(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeCalendar != view.LayoutType {
+		return
+	}
+
+	modeValue, ok := operation.Data.(string)
+	mode := av.CalendarMode(modeValue)
+	if !ok || !mode.IsValid() {
+		return av.ErrInvalidCalendarMode
+	}
+	view.Calendar.Mode = mode
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewFirstDayOfWeek(operation *Operation) (ret *TxErr) {
+	err := setAttrViewFirstDayOfWeek(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttrViewFirstDayOfWeek(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeCalendar != view.LayoutType {
+		return
+	}
+
+	view.Calendar.FirstDayOfWeek = min(max(int(operation.Data.(float64)), 0), 6)
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewTimelineScale(operation *Operation) (ret *TxErr) {
+	err := setAttrViewTimelineScale(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttrViewTimelineScale(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeTimeline != view.LayoutType {
+		return
+	}
+
+	scaleValue, ok := operation.Data.(string)
+	scale := av.TimelineScale(scaleValue)
+	if !ok || !scale.IsValid() {
+		return av.ErrInvalidTimelineScale
+	}
+	view.Timeline.Scale = scale
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
 func AppendAttributeViewDetachedBlocksWithValues(avID string, blocksValues [][]*av.Value) (err error) {
 	attrView, err := av.ParseAttributeView(avID)
 	if err != nil {
@@ -1522,8 +1744,8 @@ func DuplicateAttributeViewRow(tx *Transaction, avID, previousItemID, srcRowID,
 		if av.KeyTypeBlock == keyValues.Key.Type {
 			continue // 主键已处理
 		}
-		if av.KeyTypeRollup == keyValues.Key.Type || av.KeyTypeCreated == keyValues.Key.Type || av.KeyTypeUpdated == keyValues.Key.Type {
-			continue // 汇总/创建时间/更新时间字段在渲染或自动生成时处理，不复制
+		if av.KeyTypeRollup == keyValues.Key.Type || av.KeyTypeCreated == keyValues.Key.Type || av.KeyTypeUpdated == keyValues.Key.Type || av.KeyTypeFormula == keyValues.Key.Type {
+			continue // 汇总/创建时间/更新时间/公式字段在渲染或自动生成时处理，不复制
 		}
 
 		srcVal := keyValues.GetValue(srcRowID)
@@ -2530,6 +2752,11 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 		return
 	}
 
+	groupKeyType := groupKey.Type
+	if av.KeyTypeFormula == groupKeyType && nil != groupKey.Formula {
+		groupKeyType = groupKey.Formula.ResultType // 公式字段按计算结果类型分组
+	}
+
 	var rangeStart, rangeEnd float64
 	switch group.Method {
 	case av.GroupMethodValue:
@@ -2556,7 +2783,7 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 			sort.SliceStable(items, func(i, j int) bool {
 				return items[i].GetValue(group.Field).Updated.Content < items[j].GetValue(group.Field).Updated.Content
 			})
-		} else if av.KeyTypeDate == groupKey.Type {
+		} else if av.KeyTypeDate == groupKeyType {
 			sort.SliceStable(items, func(i, j int) bool {
 				return items[i].GetValue(group.Field).Date.Content < items[j].GetValue(group.Field).Date.Content
 			})
@@ -2620,7 +2847,7 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 		case av.GroupMethodDateDay, av.GroupMethodDateWeek, av.GroupMethodDateMonth, av.GroupMethodDateYear, av.GroupMethodDateRelative:
 			var contentTime time.Time
 			switch value.Type {
-			case av.KeyTypeDate:
+			case av.KeyTypeDate, av.KeyTypeFormula:
 				contentTime = time.UnixMilli(value.Date.Content)
 			case av.KeyTypeCreated:
 				contentTime = time.UnixMilli(value.Created.Content)
@@ -2674,7 +2901,7 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 		}
 	}
 
-	if av.KeyTypeCheckbox != groupKey.Type {
+	if av.KeyTypeCheckbox != groupKeyType {
 		if 1 > len(groupItemsMap[groupValueDefault]) {
 			// 始终保留默认分组 https://github.com/siyuan-note/siyuan/issues/15587
 			groupItemsMap[groupValueDefault] = []av.Item{}
@@ -2701,6 +2928,12 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 		case av.LayoutTypeKanban:
 			v = av.NewKanbanView()
 			v.Kanban = av.NewLayoutKanban()
+		case av.LayoutTypeCalendar:
+			v = av.NewCalendarView()
+			v.Calendar = av.NewLayoutCalendar()
+		case av.LayoutTypeTimeline:
+			v = av.NewTimelineView()
+			v.Timeline = av.NewLayoutTimeline()
 		default:
 			logging.LogWarnf("unknown layout type [%s] for group view", view.LayoutType)
 			return
@@ -2731,7 +2964,7 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 					v.GroupVal.Relation.Contents = []*av.Value{destBlock}
 				}
 			}
-		} else if av.KeyTypeCheckbox == groupKey.Type {
+		} else if av.KeyTypeCheckbox == groupKeyType {
 			v.GroupVal.Text = nil
 			v.GroupVal.Type = av.KeyTypeCheckbox
 			v.GroupVal.Checkbox = &av.ValueCheckbox{}
@@ -3201,6 +3434,10 @@ func updateAttributeViewColRelation(operation *Operation) (err error) {
 				v.Gallery.CardFields = append(v.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: operation.BackRelationKeyID}})
 			case av.LayoutTypeKanban:
 				v.Kanban.Fields = append(v.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: operation.BackRelationKeyID}})
+			case av.LayoutTypeCalendar:
+				v.Calendar.Fields = append(v.Calendar.Fields, &av.ViewCalendarField{BaseField: &av.BaseField{ID: operation.BackRelationKeyID}})
+			case av.LayoutTypeTimeline:
+				v.Timeline.Fields = append(v.Timeline.Fields, &av.ViewTimelineField{BaseField: &av.BaseField{ID: operation.BackRelationKeyID}})
 			}
 		}
 
@@ -3440,6 +3677,10 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 		view = av.NewGalleryView()
 	case av.LayoutTypeKanban:
 		view = av.NewKanbanView()
+	case av.LayoutTypeCalendar:
+		view = av.NewCalendarView()
+	case av.LayoutTypeTimeline:
+		view = av.NewTimelineView()
 	}
 
 	view.ID = operation.ID
@@ -3520,6 +3761,43 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 		view.Kanban.FillColBackgroundColor = masterView.Kanban.FillColBackgroundColor
 		view.Kanban.ShowIcon = masterView.Kanban.ShowIcon
 		view.Kanban.WrapField = masterView.Kanban.WrapField
+	case av.LayoutTypeCalendar:
+		for _, field := range masterView.Calendar.Fields {
+			view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{
+				BaseField: &av.BaseField{
+					ID:     field.ID,
+					Wrap:   field.Wrap,
+					Hidden: field.Hidden,
+					Desc:   field.Desc,
+				},
+			})
+		}
+
+		view.Calendar.StartKeyID = masterView.Calendar.StartKeyID
+		view.Calendar.EndKeyID = masterView.Calendar.EndKeyID
+		view.Calendar.Mode = masterView.Calendar.Mode
+		view.Calendar.FirstDayOfWeek = masterView.Calendar.FirstDayOfWeek
+		view.Calendar.DisplayFieldName = masterView.Calendar.DisplayFieldName
+		view.Calendar.ShowIcon = masterView.Calendar.ShowIcon
+		view.Calendar.WrapField = masterView.Calendar.WrapField
+	case av.LayoutTypeTimeline:
+		for _, field := range masterView.Timeline.Fields {
+			view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{
+				BaseField: &av.BaseField{
+					ID:     field.ID,
+					Wrap:   field.Wrap,
+					Hidden: field.Hidden,
+					Desc:   field.Desc,
+				},
+			})
+		}
+
+		view.Timeline.StartKeyID = masterView.Timeline.StartKeyID
+		view.Timeline.EndKeyID = masterView.Timeline.EndKeyID
+		view.Timeline.Scale = masterView.Timeline.Scale
+		view.Timeline.DisplayFieldName = masterView.Timeline.DisplayFieldName
+		view.Timeline.ShowIcon = masterView.Timeline.ShowIcon
+		view.Timeline.WrapField = masterView.Timeline.WrapField
 	}
 
 	view.ItemIDs = masterView.ItemIDs
@@ -3591,6 +3869,14 @@ func addAttrViewView(avID, viewID, blockID string, layout av.LayoutType) (err er
 			for _, field := range firstView.Kanban.Fields {
 				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar:
+			for _, field := range firstView.Calendar.Fields {
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
+			}
+		case av.LayoutTypeTimeline:
+			for _, field := range firstView.Timeline.Fields {
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
+			}
 		}
 	case av.LayoutTypeGallery:
 		view = av.NewGalleryView()
@@ -3607,6 +3893,14 @@ func addAttrViewView(avID, viewID, blockID string, layout av.LayoutType) (err er
 			for _, field := range firstView.Kanban.Fields {
 				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar:
+			for _, field := range firstView.Calendar.Fields {
+				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: field.ID}})
+			}
+		case av.LayoutTypeTimeline:
+			for _, field := range firstView.Timeline.Fields {
+				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: field.ID}})
+			}
 		}
 	case av.LayoutTypeKanban:
 		view = av.NewKanbanView()
@@ -3623,6 +3917,24 @@ func addAttrViewView(avID, viewID, blockID string, layout av.LayoutType) (err er
 			for _, field := range firstView.Kanban.Fields {
 				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar:
+			for _, field := range firstView.Calendar.Fields {
+				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: field.ID}})
+			}
+		case av.LayoutTypeTimeline:
+			for _, field := range firstView.Timeline.Fields {
+				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: field.ID}})
+			}
+		}
+	case av.LayoutTypeCalendar:
+		view = av.NewCalendarView()
+		for _, fieldID := range getAttrViewLayoutFieldIDs(firstView, firstView.LayoutType) {
+			view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: &av.BaseField{ID: fieldID}})
+		}
+	case av.LayoutTypeTimeline:
+		view = av.NewTimelineView()
+		for _, fieldID := range getAttrViewLayoutFieldIDs(firstView, firstView.LayoutType) {
+			view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: &av.BaseField{ID: fieldID}})
 		}
 	default:
 		err = av.ErrWrongLayoutType
@@ -3640,6 +3952,7 @@ func addAttrViewView(avID, viewID, blockID string, layout av.LayoutType) (err er
 		group := &av.ViewGroup{Field: preferredGroupKey.ID}
 		setAttributeViewGroup(attrView, view, group)
 	}
+	setAttrViewPreferredDateKey(attrView, view)
 
 	node, tree, _ := getNodeByBlockID(nil, blockID)
 	if nil == node {
@@ -3691,6 +4004,81 @@ func getKanbanPreferredGroupKey(attrView *av.AttributeView) (ret *av.Key) {
 				newField.Wrap = view.Kanban.WrapField
 				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: newField})
 			}
+			if nil != view.Calendar {
+				newField.Wrap = view.Calendar.WrapField
+				view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: newField})
+			}
+			if nil != view.Timeline {
+				newField.Wrap = view.Timeline.WrapField
+				view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: newField})
+			}
+		}
+	}
+	return
+}
+
+// setAttrViewPreferredDateKey 为未指定开始日期字段的日历和时间线视图选择已有的日期字段。
+// 不存在日期字段时保持为空，由用户指定或新建日期字段，避免切换布局时隐式新建字段。
+func setAttrViewPreferredDateKey(attrView *av.AttributeView, view *av.View) {
+	key := getCalendarPreferredDateKey(attrView)
+	if nil == key {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeCalendar:
+		if "" == view.Calendar.StartKeyID {
+			view.Calendar.StartKeyID = key.ID
+		}
+	case av.LayoutTypeTimeline:
+		if "" == view.Timeline.StartKeyID {
+			view.Timeline.StartKeyID = key.ID
+		}
+	}
+}
+
+// getCalendarPreferredDateKey 返回日历和时间线视图默认使用的日期字段，不存在日期字段时返回 nil。
+func getCalendarPreferredDateKey(attrView *av.AttributeView) (ret *av.Key) {
+	for _, kv := range attrView.KeyValues {
+		if av.KeyTypeDate == kv.Key.Type {
+			return kv.Key
+		}
+	}
+	return
+}
+
+// getAttrViewLayoutFieldIDs 返回视图在指定布局下的字段 ID 列表，用于新建或切换布局时沿用原有字段。
+func getAttrViewLayoutFieldIDs(view *av.View, layout av.LayoutType) (ret []string) {
+	switch layout {
+	case av.LayoutTypeTable:
+		if nil != view.Table {
+			for _, col := range view.Table.Columns {
+				ret = append(ret, col.ID)
+			}
+		}
+	case av.LayoutTypeGallery:
+		if nil != view.Gallery {
+			for _, field := range view.Gallery.CardFields {
+				ret = append(ret, field.ID)
+			}
+		}
+	case av.LayoutTypeKanban:
+		if nil != view.Kanban {
+			for _, field := range view.Kanban.Fields {
+				ret = append(ret, field.ID)
+			}
+		}
+	case av.LayoutTypeCalendar:
+		if nil != view.Calendar {
+			for _, field := range view.Calendar.Fields {
+				ret = append(ret, field.ID)
+			}
+		}
+	case av.LayoutTypeTimeline:
+		if nil != view.Timeline {
+			for _, field := range view.Timeline.Fields {
+				ret = append(ret, field.ID)
+			}
 		}
 	}
 	return
@@ -4081,7 +4469,7 @@ func setAttributeViewColumnCalc(operation *Operation) (err error) {
 				break
 			}
 		}
-	case av.LayoutTypeGallery, av.LayoutTypeKanban:
+	case av.LayoutTypeGallery, av.LayoutTypeKanban, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	}
 
@@ -4648,6 +5036,38 @@ func duplicateAttributeViewKey(operation *Operation) (err error) {
 					break
 				}
 			}
+		case av.LayoutTypeCalendar:
+			for i, field := range view.Calendar.Fields {
+				if field.ID == key.ID {
+					view.Calendar.Fields = append(view.Calendar.Fields[:i+1], append([]*av.ViewCalendarField{
+						{
+							BaseField: &av.BaseField{
+								ID:     copyKey.ID,
+								Wrap:   field.Wrap,
+								Hidden: field.Hidden,
+								Desc:   field.Desc,
+							},
+						},
+					}, view.Calendar.Fields[i+1:]...)...)
+					break
+				}
+			}
+		case av.LayoutTypeTimeline:
+			for i, field := range view.Timeline.Fields {
+				if field.ID == key.ID {
+					view.Timeline.Fields = append(view.Timeline.Fields[:i+1], append([]*av.ViewTimelineField{
+						{
+							BaseField: &av.BaseField{
+								ID:     copyKey.ID,
+								Wrap:   field.Wrap,
+								Hidden: field.Hidden,
+								Desc:   field.Desc,
+							},
+						},
+					}, view.Timeline.Fields[i+1:]...)...)
+					break
+				}
+			}
 		}
 	}
 
@@ -4682,7 +5102,7 @@ func setAttributeViewColWidth(operation *Operation) (err error) {
 				break
 			}
 		}
-	case av.LayoutTypeGallery, av.LayoutTypeKanban:
+	case av.LayoutTypeGallery, av.LayoutTypeKanban, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	}
 
@@ -4775,6 +5195,22 @@ func setAttributeViewColWrap(operation *Operation) (err error) {
 			allFieldWrap = allFieldWrap && field.Wrap
 		}
 		view.Kanban.WrapField = allFieldWrap
+	case av.LayoutTypeCalendar:
+		for _, field := range view.Calendar.Fields {
+			if field.ID == operation.ID {
+				field.Wrap = newWrap
+			}
+			allFieldWrap = allFieldWrap && field.Wrap
+		}
+		view.Calendar.WrapField = allFieldWrap
+	case av.LayoutTypeTimeline:
+		for _, field := range view.Timeline.Fields {
+			if field.ID == operation.ID {
+				field.Wrap = newWrap
+			}
+			allFieldWrap = allFieldWrap && field.Wrap
+		}
+		view.Timeline.WrapField = allFieldWrap
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -4822,6 +5258,20 @@ func setAttributeViewColHidden(operation *Operation) (err error) {
 				break
 			}
 		}
+	case av.LayoutTypeCalendar:
+		for _, field := range view.Calendar.Fields {
+			if field.ID == operation.ID {
+				field.Hidden = operation.Data.(bool)
+				break
+			}
+		}
+	case av.LayoutTypeTimeline:
+		for _, field := range view.Timeline.Fields {
+			if field.ID == operation.ID {
+				field.Hidden = operation.Data.(bool)
+				break
+			}
+		}
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -4855,7 +5305,7 @@ func setAttributeViewColPin(operation *Operation) (err error) {
 				break
 			}
 		}
-	case av.LayoutTypeGallery, av.LayoutTypeKanban:
+	case av.LayoutTypeGallery, av.LayoutTypeKanban, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	}
 
@@ -4944,7 +5394,7 @@ func sortAttributeViewRow(operation *Operation) (err error) {
 		if groupView := view.GetGroupByID(operation.GroupID); nil != groupView {
 			groupKey := view.GetGroupKey(attrView)
 			isAcrossGroup := operation.GroupID != operation.TargetGroupID
-			if isAcrossGroup && (av.KeyTypeTemplate == groupKey.Type || av.KeyTypeCreated == groupKey.Type || av.KeyTypeUpdated == groupKey.Type) {
+			if isAcrossGroup && (av.KeyTypeTemplate == groupKey.Type || av.KeyTypeCreated == groupKey.Type || av.KeyTypeUpdated == groupKey.Type || av.KeyTypeFormula == groupKey.Type) {
 				// 这些字段类型不支持跨分组移动，因为它们的值是自动计算生成的
 				return
 			}
@@ -5118,6 +5568,48 @@ func SortAttributeViewViewKey(avID, blockID, keyID, previousKeyID string) (err e
 			}
 		}
 		view.Kanban.Fields = util.InsertElem(view.Kanban.Fields, previousIndex, field)
+	case av.LayoutTypeCalendar:
+		var field *av.ViewCalendarField
+		for i, calendarField := range view.Calendar.Fields {
+			if calendarField.ID == keyID {
+				field = calendarField
+				curIndex = i
+				break
+			}
+		}
+		if nil == field {
+			return
+		}
+
+		view.Calendar.Fields = append(view.Calendar.Fields[:curIndex], view.Calendar.Fields[curIndex+1:]...)
+		for i, calendarField := range view.Calendar.Fields {
+			if calendarField.ID == previousKeyID {
+				previousIndex = i + 1
+				break
+			}
+		}
+		view.Calendar.Fields = util.InsertElem(view.Calendar.Fields, previousIndex, field)
+	case av.LayoutTypeTimeline:
+		var field *av.ViewTimelineField
+		for i, timelineField := range view.Timeline.Fields {
+			if timelineField.ID == keyID {
+				field = timelineField
+				curIndex = i
+				break
+			}
+		}
+		if nil == field {
+			return
+		}
+
+		view.Timeline.Fields = append(view.Timeline.Fields[:curIndex], view.Timeline.Fields[curIndex+1:]...)
+		for i, timelineField := range view.Timeline.Fields {
+			if timelineField.ID == previousKeyID {
+				previousIndex = i + 1
+				break
+			}
+		}
+		view.Timeline.Fields = util.InsertElem(view.Timeline.Fields, previousIndex, field)
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -5226,11 +5718,13 @@ func AddAttributeViewKey(avID, keyID, keyName, keyType, keyIcon, previousKeyID s
 	switch keyTyp {
 	case av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
 		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber, av.KeyTypeFormula:
 
 		key := av.NewKey(keyID, keyName, keyIcon, keyTyp)
 		if av.KeyTypeRollup == keyTyp {
 			key.Rollup = &av.Rollup{Calc: &av.RollupCalc{Operator: av.CalcOperatorNone}}
+		} else if av.KeyTypeFormula == keyTyp {
+			key.Formula = &av.Formula{ResultType: av.KeyTypeText}
 		}
 
 		attrView.KeyValues = append(attrView.KeyValues, &av.KeyValues{Key: key})
@@ -5301,6 +5795,44 @@ func AddAttributeViewKey(avID, keyID, keyName, keyType, keyIcon, previousKeyID s
 					}
 				}
 			}
+			if nil != view.Calendar {
+				newField.Wrap = view.Calendar.WrapField
+
+				if "" == previousKeyID {
+					view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: newField})
+				} else {
+					added := false
+					for i, field := range view.Calendar.Fields {
+						if field.ID == previousKeyID {
+							view.Calendar.Fields = append(view.Calendar.Fields[:i+1], append([]*av.ViewCalendarField{{BaseField: newField}}, view.Calendar.Fields[i+1:]...)...)
+							added = true
+							break
+						}
+					}
+					if !added {
+						view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: newField})
+					}
+				}
+			}
+			if nil != view.Timeline {
+				newField.Wrap = view.Timeline.WrapField
+
+				if "" == previousKeyID {
+					view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: newField})
+				} else {
+					added := false
+					for i, field := range view.Timeline.Fields {
+						if field.ID == previousKeyID {
+							view.Timeline.Fields = append(view.Timeline.Fields[:i+1], append([]*av.ViewTimelineField{{BaseField: newField}}, view.Timeline.Fields[i+1:]...)...)
+							added = true
+							break
+						}
+					}
+					if !added {
+						view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: newField})
+					}
+				}
+			}
 		}
 	}
 
@@ -5338,6 +5870,46 @@ func updateAttributeViewColTemplate(operation *Operation) (err error) {
 	return
 }
 
+func (tx *Transaction) doUpdateAttrViewColFormula(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColFormula(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColFormula(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	expr, _ := operation.Data.(string)
+	if "" != strings.TrimSpace(expr) {
+		if _, err = av.ParseFormula(expr); nil != err {
+			return
+		}
+	}
+
+	keyValues, err := attrView.GetKeyValues(operation.ID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeFormula != keyValues.Key.Type {
+		return
+	}
+
+	if nil == keyValues.Key.Formula {
+		keyValues.Key.Formula = &av.Formula{}
+	}
+	keyValues.Key.Formula.Expr = expr
+	av.GetFormulaKeysByResolutionOrder(attrView) // 重新推导结果类型
+
+	regenAttrViewGroups(attrView)
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
 func (tx *Transaction) doUpdateAttrViewColNumberFormat(operation *Operation) (ret *TxErr) {
 	err := updateAttributeViewColNumberFormat(operation)
 	if err != nil {
@@ -5354,9 +5926,9 @@ func updateAttributeViewColNumberFormat(operation *Operation) (err error) {
 
 	colType := av.KeyType(operation.Typ)
 	switch colType {
-	case av.KeyTypeNumber:
+	case av.KeyTypeNumber, av.KeyTypeFormula: // 公式字段的计算结果为数字时使用字段上的数字格式
 		for _, keyValues := range attrView.KeyValues {
-			if keyValues.Key.ID == operation.ID && av.KeyTypeNumber == keyValues.Key.Type {
+			if keyValues.Key.ID == operation.ID && colType == keyValues.Key.Type {
 				keyValues.Key.NumberFormat = av.NumberFormat(operation.Format)
 				break
 			}
@@ -5386,7 +5958,7 @@ func updateAttributeViewColumn(operation *Operation) (err error) {
 	switch colType {
 	case av.KeyTypeBlock, av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
 		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber, av.KeyTypeFormula:
 		for _, keyValues := range attrView.KeyValues {
 			if keyValues.Key.ID == operation.ID {
 				isPrimaryKey := av.KeyTypeBlock == keyValues.Key.Type
@@ -5403,6 +5975,9 @@ func updateAttributeViewColumn(operation *Operation) (err error) {
 
 				changeType = keyValues.Key.Type != colType
 				keyValues.Key.Type = colType
+				if av.KeyTypeFormula == colType && nil == keyValues.Key.Formula {
+					keyValues.Key.Formula = &av.Formula{ResultType: av.KeyTypeText}
+				}
 
 				for _, value := range keyValues.Values {
 					value.Type = colType
@@ -5542,6 +6117,20 @@ func RemoveAttributeViewKey(avID, keyID string, removeRelationDest bool) (err er
 									break
 								}
 							}
+						case av.LayoutTypeCalendar:
+							for i, field := range view.Calendar.Fields {
+								if field.ID == removedKey.Relation.BackKeyID {
+									view.Calendar.Fields = append(view.Calendar.Fields[:i], view.Calendar.Fields[i+1:]...)
+									break
+								}
+							}
+						case av.LayoutTypeTimeline:
+							for i, field := range view.Timeline.Fields {
+								if field.ID == removedKey.Relation.BackKeyID {
+									view.Timeline.Fields = append(view.Timeline.Fields[:i], view.Timeline.Fields[i+1:]...)
+									break
+								}
+							}
 						}
 					}
 				}
@@ -5595,6 +6184,34 @@ func RemoveAttributeViewKey(avID, keyID string, removeRelationDest bool) (err er
 				}
 			}
 		}
+		if nil != view.Calendar {
+			for i, field := range view.Calendar.Fields {
+				if field.ID == keyID {
+					view.Calendar.Fields = append(view.Calendar.Fields[:i], view.Calendar.Fields[i+1:]...)
+					break
+				}
+			}
+			if view.Calendar.StartKeyID == keyID {
+				view.Calendar.StartKeyID = ""
+			}
+			if view.Calendar.EndKeyID == keyID {
+				view.Calendar.EndKeyID = ""
+			}
+		}
+		if nil != view.Timeline {
+			for i, field := range view.Timeline.Fields {
+				if field.ID == keyID {
+					view.Timeline.Fields = append(view.Timeline.Fields[:i], view.Timeline.Fields[i+1:]...)
+					break
+				}
+			}
+			if view.Timeline.StartKeyID == keyID {
+				view.Timeline.StartKeyID = ""
+			}
+			if view.Timeline.EndKeyID == keyID {
+				view.Timeline.EndKeyID = ""
+			}
+		}
 	}
 
 	for _, view := range attrView.Views {
@@ -5971,6 +6588,11 @@ func updateAttributeViewValue(tx *Transaction, attrView *av.AttributeView, keyID
 	if err = av.SaveAttributeView(attrView); nil != err {
 		return
 	}
+	var boundBlockID string
+	if nil != blockVal && !blockVal.IsDetached && nil != blockVal.Block {
+		boundBlockID = blockVal.Block.ID
+	}
+	eventbus.Publish(util.EvtAvCellUpdated, avID, keyID, itemID, boundBlockID, val)
 	if 0 != relationChangeMode && nil != key && nil != key.Relation && "" != key.Relation.AvID && key.Relation.AvID != avID {
 		ReloadAttrView(key.Relation.AvID)
 	}
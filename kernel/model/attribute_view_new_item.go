@@ -377,7 +377,7 @@ func applyNewItemContentTemplate(templatePath, docID string) error {
 	if nil != err {
 		return err
 	}
-	templateTree, templateDOM, err := RenderTemplate(absPath, docID, false)
+	templateTree, templateDOM, err := RenderTemplate(absPath, docID, nil, false)
 	if nil != err {
 		return err
 	}
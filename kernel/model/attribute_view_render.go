@@ -47,11 +47,12 @@ type AttributeViewRenderTarget struct {
 }
 
 func RenderAttributeView(blockID, avID, viewID, query string, page, pageSize int, groupPaging map[string]any, createIfNotExist, ignoreRows bool) (viewable av.Viewable, attrView *av.AttributeView, err error) {
-	viewable, attrView, _, err = RenderAttributeViewWithTarget(blockID, avID, viewID, query, page, pageSize, groupPaging, createIfNotExist, ignoreRows, "", "")
+	viewable, attrView, _, err = RenderAttributeViewWithTarget(blockID, avID, viewID, query, page, pageSize, groupPaging, createIfNotExist, ignoreRows, "", "", nil)
 	return
 }
 
-func RenderAttributeViewWithTarget(blockID, avID, viewID, query string, page, pageSize int, groupPaging map[string]any, createIfNotExist, ignoreRows bool, targetItemID, targetGroupID string) (viewable av.Viewable, attrView *av.AttributeView, target *AttributeViewRenderTarget, err error) {
+// RenderAttributeViewWithTarget 渲染属性视图。dateWindow 仅对日历和时间线布局生效，指定后只在可见日期区间内计数和分页。
+func RenderAttributeViewWithTarget(blockID, avID, viewID, query string, page, pageSize int, groupPaging map[string]any, createIfNotExist, ignoreRows bool, targetItemID, targetGroupID string, dateWindow *av.DateWindow) (viewable av.Viewable, attrView *av.AttributeView, target *AttributeViewRenderTarget, err error) {
 	if !ast.IsNodeIDPattern(avID) {
 		err = ErrInvalidID
 		return
@@ -142,7 +143,7 @@ func RenderAttributeViewWithTarget(blockID, avID, viewID, query string, page, pa
 	} else {
 	}
 
-	viewable, err = renderAttributeView(attrView, blockID, viewID, query, page, pageSize, groupPaging, ignoreRows, target, targetGroupID)
+	viewable, err = renderAttributeView(attrView, blockID, viewID, query, page, pageSize, groupPaging, ignoreRows, target, targetGroupID, dateWindow)
 	return
 }
 
@@ -154,7 +155,7 @@ const (
 	groupValueNext7Days, groupValueNext30Days                = "_@next7Days@_", "_@next30Days@_"
 )
 
-func renderAttributeView(attrView *av.AttributeView, nodeID, viewID, query string, page, pageSize int, groupPaging map[string]any, ignoreRows bool, target *AttributeViewRenderTarget, targetGroupID string) (viewable av.Viewable, err error) {
+func renderAttributeView(attrView *av.AttributeView, nodeID, viewID, query string, page, pageSize int, groupPaging map[string]any, ignoreRows bool, target *AttributeViewRenderTarget, targetGroupID string, dateWindow *av.DateWindow) (viewable av.Viewable, err error) {
 	// 获取待渲染的视图
 	view, err := getRenderAttributeViewView(attrView, viewID, nodeID, nil == target)
 	if nil != err {
@@ -167,6 +168,7 @@ func renderAttributeView(attrView *av.AttributeView, nodeID, viewID, query strin
 
 	// 渲染视图
 	viewable = sql.RenderView(attrView, view, query, ignoreRows)
+	setAttributeViewDateWindow(viewable, dateWindow)
 	renderTargetItemID := targetItemID(target)
 	if view.IsGroupView() || view.LayoutType == av.LayoutTypeKanban {
 		renderTargetItemID = ""
@@ -182,12 +184,12 @@ func renderAttributeView(attrView *av.AttributeView, nodeID, viewID, query strin
 
 	// 渲染分组视图。当 ignoreRows 时若有已生成的分组则渲染元数据供面板使用，无分组则跳过（生成分组需要行数据）
 	if !ignoreRows || len(view.Groups) > 0 {
-		err = renderAttributeViewGroups(viewable, attrView, view, query, page, pageSize, groupPaging, ignoreRows, target, targetGroupID)
+		err = renderAttributeViewGroups(viewable, attrView, view, query, page, pageSize, groupPaging, ignoreRows, target, targetGroupID, dateWindow)
 	}
 	return
 }
 
-func renderAttributeViewGroups(viewable av.Viewable, attrView *av.AttributeView, view *av.View, query string, page, pageSize int, groupPaging map[string]any, ignoreRows bool, target *AttributeViewRenderTarget, targetGroupID string) (err error) {
+func renderAttributeViewGroups(viewable av.Viewable, attrView *av.AttributeView, view *av.View, query string, page, pageSize int, groupPaging map[string]any, ignoreRows bool, target *AttributeViewRenderTarget, targetGroupID string, dateWindow *av.DateWindow) (err error) {
 	groupKey := view.GetGroupKey(attrView)
 	if nil == groupKey {
 		if view.LayoutType == av.LayoutTypeKanban {
@@ -220,7 +222,7 @@ func renderAttributeViewGroups(viewable av.Viewable, attrView *av.AttributeView,
 		}
 	}
 
-	// 如果是按模板分组则需要重新生成分组。
+	// 如果是按模板或公式分组则需要重新生成分组。
 	// ignoreRows 时跳过重新生成（需要行数据），沿用已保存的分组。
 	if !ignoreRows && isGroupByTemplate(attrView, view) {
 		genAttrViewGroups(view, attrView) // 仅重新生成一个视图的分组以提升性能
@@ -272,6 +274,7 @@ func renderAttributeViewGroups(viewable av.Viewable, attrView *av.AttributeView,
 	var groups []av.Viewable
 	for _, groupView := range view.Groups {
 		groupViewable := sql.RenderGroupView(attrView, view, groupView, query)
+		setAttributeViewDateWindow(groupViewable, dateWindow)
 
 		groupPage, groupPageSize := page, pageSize
 		if nil != groupPaging {
@@ -321,6 +324,10 @@ func renderAttributeViewGroups(viewable av.Viewable, attrView *av.AttributeView,
 			groupView.Gallery.CardFields = nil
 		case av.LayoutTypeKanban:
 			groupView.Kanban.Fields = nil
+		case av.LayoutTypeCalendar:
+			groupView.Calendar.Fields = nil
+		case av.LayoutTypeTimeline:
+			groupView.Timeline.Fields = nil
 		}
 	}
 	viewable.SetGroups(groups)
@@ -512,7 +519,7 @@ func isGroupByTemplate(attrView *av.AttributeView, view *av.View) bool {
 	if nil == groupKey {
 		return false
 	}
-	return av.KeyTypeTemplate == groupKey.Type
+	return av.KeyTypeTemplate == groupKey.Type || av.KeyTypeFormula == groupKey.Type
 }
 
 func renderViewableInstance(viewable av.Viewable, view *av.View, attrView *av.AttributeView, page, pageSize int, ignoreRows bool, targetItemID string) (targetIndex, targetOffset int, err error) {
@@ -575,6 +582,50 @@ func renderViewableInstance(viewable av.Viewable, view *av.View, attrView *av.At
 			targetOffset = start
 		}
 		kanban.Cards = kanban.Cards[start:end]
+	case av.LayoutTypeCalendar:
+		calendar := viewable.(*av.Calendar)
+		if calendar.Window.IsValid() {
+			events := calendar.Events[:0]
+			for _, event := range calendar.Events {
+				if event.Overlaps(calendar.Window) {
+					events = append(events, event)
+				}
+			}
+			calendar.Events = events
+		}
+		targetIndex = findAttributeViewTargetIndex(targetItemID, len(calendar.Events), func(index int) string { return calendar.Events[index].ID })
+		calendar.EventCount = len(calendar.Events)
+		calendar.PageSize = view.PageSize
+		if 1 > pageSize {
+			pageSize = calendar.PageSize
+		}
+		start, end := getAttributeViewRenderRange(page, pageSize, targetIndex, calendar.PageSize, len(calendar.Events))
+		if targetIndex >= 0 {
+			targetOffset = start
+		}
+		calendar.Events = calendar.Events[start:end]
+	case av.LayoutTypeTimeline:
+		timeline := viewable.(*av.Timeline)
+		if timeline.Window.IsValid() {
+			items := timeline.Items[:0]
+			for _, item := range timeline.Items {
+				if item.Overlaps(timeline.Window) {
+					items = append(items, item)
+				}
+			}
+			timeline.Items = items
+		}
+		targetIndex = findAttributeViewTargetIndex(targetItemID, len(timeline.Items), func(index int) string { return timeline.Items[index].ID })
+		timeline.ItemCount = len(timeline.Items)
+		timeline.PageSize = view.PageSize
+		if 1 > pageSize {
+			pageSize = timeline.PageSize
+		}
+		start, end := getAttributeViewRenderRange(page, pageSize, targetIndex, timeline.PageSize, len(timeline.Items))
+		if targetIndex >= 0 {
+			targetOffset = start
+		}
+		timeline.Items = timeline.Items[start:end]
 	}
 	return
 }
@@ -598,6 +649,20 @@ func findAttributeViewTargetIndex(targetItemID string, length int, getID func(in
 	return -1
 }
 
+// setAttributeViewDateWindow 为日历和时间线视图实例设置可见日期区间，其他布局忽略。
+func setAttributeViewDateWindow(viewable av.Viewable, window *av.DateWindow) {
+	if !window.IsValid() {
+		return
+	}
+
+	switch v := viewable.(type) {
+	case *av.Calendar:
+		v.Window = window
+	case *av.Timeline:
+		v.Window = window
+	}
+}
+
 func getAttributeViewRenderRange(page, pageSize, targetIndex, defaultPageSize, length int) (start, end int) {
 	if 1 > defaultPageSize {
 		defaultPageSize = av.ViewDefaultPageSize
@@ -745,7 +810,7 @@ func RenderRepoSnapshotAttributeView(indexID, avID string) (viewable av.Viewable
 		return
 	}
 
-	viewable, err = renderAttributeView(attrView, "", "", "", 1, -1, nil, false, nil, "")
+	viewable, err = renderAttributeView(attrView, "", "", "", 1, -1, nil, false, nil, "", nil)
 	return
 }
 
@@ -849,6 +914,6 @@ func RenderHistoryAttributeView(blockID, avID, viewID, query string, page, pageS
 		return
 	}
 
-	viewable, err = renderAttributeView(attrView, blockID, viewID, query, page, pageSize, groupPaging, false, nil, "")
+	viewable, err = renderAttributeView(attrView, blockID, viewID, query, page, pageSize, groupPaging, false, nil, "", nil)
 	return
 }
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/siyuan-note/logging"
@@ -44,7 +45,10 @@ const (
 
 	iss = "siyuan-kernel" // token 的发行者
 
-	ClaimsKeyRole string = "role"
+	ClaimsKeyRole      string = "role"
+	ClaimsKeyAPIGroups string = "api" // 内核插件 token 可访问的路由分组
+
+	KernelPluginJWTAudience = "siyuan-kernel-plugin" // 内核插件 token 的受众
 )
 
 var (
@@ -130,17 +134,18 @@ func InitPublishJWT() {
 	}
 }
 
-// CreatePluginJWT 为指定名称的内核插件创建一个 JWT，包含管理员权限。插件使用这个 JWT 调用内核 API。
-func CreatePluginJWT(name string) (string, error) {
+// CreatePluginJWT 为指定名称的内核插件创建一个 JWT，包含指定的角色和已批准的路由分组。插件使用这个 JWT 调用内核 API。
+func CreatePluginJWT(name string, role Role, apiGroups []string) (string, error) {
 	t := jwt.NewWithClaims(
 		jwt.SigningMethodHS256,
 		jwt.MapClaims{
 			"iss": iss,
 			"sub": name,
-			"aud": "siyuan-kernel-plugin",
+			"aud": KernelPluginJWTAudience,
 			"jti": uuid.New().String(),
 
-			ClaimsKeyRole: RoleAdministrator,
+			ClaimsKeyRole:      role,
+			ClaimsKeyAPIGroups: apiGroups,
 		},
 	)
 	if token, err := t.SignedString(jwtKey); err != nil {
@@ -185,6 +190,52 @@ func GetClaimRole(claims jwt.MapClaims) Role {
 	return RoleVisitor
 }
 
+// getKernelPluginClaims 返回当前请求中内核插件 token 的声明，不是内核插件 token 时返回 nil。
+func getKernelPluginClaims(c *gin.Context) jwt.MapClaims {
+	value, exists := c.Get(ClaimsContextKey)
+	if !exists {
+		return nil
+	}
+	claims, ok := value.(jwt.MapClaims)
+	if !ok || KernelPluginJWTAudience != claims["aud"] {
+		return nil
+	}
+	return claims
+}
+
+// checkKernelPluginScope 检查内核插件 token 是否可以访问当前请求的路由分组，非 API 路径需要全部分组的权限。
+// 返回不为空时表示拒绝的原因。
+func checkKernelPluginScope(c *gin.Context) string {
+	claims := getKernelPluginClaims(c)
+	if nil == claims {
+		return ""
+	}
+
+	group := apiRouteGroup(c.Request.URL.Path)
+	groups, _ := claims[ClaimsKeyAPIGroups].([]any)
+	for _, g := range groups {
+		if "*" == g || ("" != group && group == g) {
+			return ""
+		}
+	}
+	return "Kernel plugin is not allowed to access [" + c.Request.URL.Path + "]"
+}
+
+// isKernelPluginRoute 判断是否为非管理员内核插件访问具名 token 路由白名单中角色允许的路由。
+func isKernelPluginRoute(c *gin.Context) bool {
+	claims := getKernelPluginClaims(c)
+	if nil == claims {
+		return false
+	}
+
+	role := GetClaimRole(claims)
+	if RoleEditor != role && RoleReader != role {
+		return false
+	}
+	route := apiTokenRoutes[c.Request.URL.Path]
+	return nil != route && role <= route.role
+}
+
 // IsPublishServiceToken 检查 token 是否来自发布服务
 func IsPublishServiceToken(token *jwt.Token) bool {
 	if token == nil || !token.Valid {
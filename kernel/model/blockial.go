@@ -29,6 +29,7 @@ import (
 	"github.com/88250/lute/html"
 	"github.com/88250/lute/parse"
 	"github.com/araddon/dateparse"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/siyuan/kernel/av"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
@@ -487,4 +488,5 @@ func pushBlockAttrs(oldAttrs map[string]string, node *ast.Node) {
 		UndoOperations: []*Operation{},
 	}}
 	util.PushEvent(evt)
+	eventbus.Publish(util.EvtBlockAttrsUpdated, node.ID, oldAttrs, newAttrs)
 }
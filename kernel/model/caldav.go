@@ -607,6 +607,9 @@ func (b *CalDavBackend) CalendarHomeSetPath(ctx context.Context) (string, error)
 func (b *CalDavBackend) CreateCalendar(ctx context.Context, calendar *caldav.Calendar) (err error) {
 	// logging.LogDebugf("CalDAV CreateCalendar -> calendar: %#v", calendar)
 	calendar.Path = PathCleanWithSlash(calendar.Path)
+	if IsNoteCalendarPath(calendar.Path) {
+		return ErrorCalDavNoteCalendarReadOnly
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -624,6 +627,9 @@ func (b *CalDavBackend) ListCalendars(ctx context.Context) (calendars_ []caldav.
 	}
 
 	calendars_, err = calendars.ListCalendars()
+	if err == nil {
+		calendars_ = append(calendars_, listNoteCalendars()...)
+	}
 	// logging.LogDebugf("CalDAV ListCalendars <- calendars: %#v, err: %s", calendars_, err)
 	return
 }
@@ -631,6 +637,9 @@ func (b *CalDavBackend) ListCalendars(ctx context.Context) (calendars_ []caldav.
 func (b *CalDavBackend) GetCalendar(ctx context.Context, calendarPath string) (calendar *caldav.Calendar, err error) {
 	// logging.LogDebugf("CalDAV GetCalendar -> calendarPath: %s", calendarPath)
 	calendarPath = PathCleanWithSlash(calendarPath)
+	if IsNoteCalendarPath(calendarPath) {
+		return getNoteCalendar(calendarPath)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -644,6 +653,9 @@ func (b *CalDavBackend) GetCalendar(ctx context.Context, calendarPath string) (c
 func (b *CalDavBackend) DeleteCalendar(ctx context.Context, calendarPath string) (err error) {
 	// logging.LogDebugf("CalDAV DeleteCalendar -> calendarPath: %s", calendarPath)
 	calendarPath = PathCleanWithSlash(calendarPath)
+	if IsNoteCalendarPath(calendarPath) {
+		return ErrorCalDavNoteCalendarReadOnly
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -657,6 +669,9 @@ func (b *CalDavBackend) DeleteCalendar(ctx context.Context, calendarPath string)
 func (b *CalDavBackend) PutCalendarObject(ctx context.Context, objectPath string, calendar *ical.Calendar, opts *caldav.PutCalendarObjectOptions) (calendarObject *caldav.CalendarObject, err error) {
 	// logging.LogDebugf("CalDAV PutCalendarObject -> objectPath: %s, opts: %#v", objectPath, opts)
 	objectPath = PathCleanWithSlash(objectPath)
+	if isNoteCalendarObjectPath(objectPath) {
+		return putNoteCalendarObject(objectPath, calendar)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -670,6 +685,9 @@ func (b *CalDavBackend) PutCalendarObject(ctx context.Context, objectPath string
 func (b *CalDavBackend) ListCalendarObjects(ctx context.Context, calendarPath string, req *caldav.CalendarCompRequest) (calendarObjects []caldav.CalendarObject, err error) {
 	// logging.LogDebugf("CalDAV ListCalendarObjects -> calendarPath: %s, req: %#v", calendarPath, req)
 	calendarPath = PathCleanWithSlash(calendarPath)
+	if IsNoteCalendarPath(calendarPath) {
+		return listNoteCalendarObjects(calendarPath)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -683,6 +701,9 @@ func (b *CalDavBackend) ListCalendarObjects(ctx context.Context, calendarPath st
 func (b *CalDavBackend) GetCalendarObject(ctx context.Context, objectPath string, req *caldav.CalendarCompRequest) (calendarObject *caldav.CalendarObject, err error) {
 	// logging.LogDebugf("CalDAV GetCalendarObject -> objectPath: %s, req: %#v", objectPath, req)
 	objectPath = PathCleanWithSlash(objectPath)
+	if isNoteCalendarObjectPath(objectPath) {
+		return getNoteCalendarObject(objectPath)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -696,6 +717,12 @@ func (b *CalDavBackend) GetCalendarObject(ctx context.Context, objectPath string
 func (b *CalDavBackend) QueryCalendarObjects(ctx context.Context, calendarPath string, query *caldav.CalendarQuery) (calendarObjects []caldav.CalendarObject, err error) {
 	// logging.LogDebugf("CalDAV QueryCalendarObjects -> calendarPath: %s, query: %#v", calendarPath, query)
 	calendarPath = PathCleanWithSlash(calendarPath)
+	if IsNoteCalendarPath(calendarPath) {
+		if calendarObjects, err = listNoteCalendarObjects(calendarPath); err != nil {
+			return
+		}
+		return caldav.Filter(query, calendarObjects)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -709,6 +736,9 @@ func (b *CalDavBackend) QueryCalendarObjects(ctx context.Context, calendarPath s
 func (b *CalDavBackend) DeleteCalendarObject(ctx context.Context, objectPath string) (err error) {
 	// logging.LogDebugf("CalDAV DeleteCalendarObject -> objectPath: %s", objectPath)
 	objectPath = PathCleanWithSlash(objectPath)
+	if isNoteCalendarObjectPath(objectPath) {
+		return deleteNoteCalendarObject(objectPath)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -0,0 +1,583 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 由笔记内容生成的虚拟日历：
+//   - 任务日历：带有截止时间属性（custom-task-due）的任务列表项，发布为 VTODO
+//   - 数据库日历：每个数据库的每个日期字段对应一个日历，发布为 VEVENT
+//
+// 虚拟日历不落盘，每次请求时根据笔记内容实时生成；客户端对完成状态和日期的修改通过事务写回块或数据库。
+
+const (
+	CalDavTasksCalendarName          = "siyuan-tasks"
+	CalDavTasksCalendarPath          = CalDavHomeSetPath + "/" + CalDavTasksCalendarName
+	CalDavAttrViewCalendarPrefix     = "siyuan-av-"
+	CalDavAttrViewCalendarPathPrefix = CalDavHomeSetPath + "/" + CalDavAttrViewCalendarPrefix
+
+	// NodeAttrTaskDue 任务列表项的截止时间，格式为 2006-01-02 或 2006-01-02 15:04
+	NodeAttrTaskDue = "custom-task-due"
+
+	calDavProductID = "-//b3log.org//SiYuan//EN"
+
+	calDavToDoStatusNeedsAction = "NEEDS-ACTION"
+	calDavToDoStatusCompleted   = "COMPLETED"
+)
+
+var (
+	ErrorCalDavNoteCalendarReadOnly = errors.New("CalDAV: calendar generated from notes is read-only")
+	ErrorCalDavNoteObjectInvalid    = errors.New("CalDAV: calendar object does not match the note calendar")
+
+	taskDueDateLayouts    = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+	taskListItemMarkerReg = regexp.MustCompile(`^\s*(?:[*+-]|\d+[.)])\s+\[(.)\]`)
+)
+
+// IsNoteCalendarPath 判断日历路径是否为由笔记内容生成的虚拟日历。
+func IsNoteCalendarPath(calendarPath string) bool {
+	return CalDavTasksCalendarPath == calendarPath || strings.HasPrefix(calendarPath, CalDavAttrViewCalendarPathPrefix)
+}
+
+func isNoteCalendarObjectPath(objectPath string) bool {
+	return IsNoteCalendarPath(PathCleanWithSlash(path.Dir(objectPath)))
+}
+
+func noteCalendarAttrViewPath(avID, keyID string) string {
+	return CalDavAttrViewCalendarPathPrefix + avID + "-" + keyID
+}
+
+func parseNoteCalendarAttrViewPath(calendarPath string) (avID, keyID string, err error) {
+	name := strings.TrimPrefix(calendarPath, CalDavAttrViewCalendarPathPrefix)
+	if len(name) < 24 || '-' != name[22] {
+		err = ErrorCalDavCalendarPathInvalid
+		return
+	}
+
+	avID, keyID = name[:22], name[23:]
+	if !ast.IsNodeIDPattern(avID) || !ast.IsNodeIDPattern(keyID) {
+		err = ErrorCalDavCalendarPathInvalid
+	}
+	return
+}
+
+func listNoteCalendars() (ret []caldav.Calendar) {
+	ret = append(ret, newTasksCalendar())
+
+	avIDs, _ := getAllAvIDs()
+	avBlockRels := av.GetBlockRels()
+	for _, avID := range avIDs {
+		if nil == avBlockRels[avID] {
+			// 未被任何块引用的数据库不发布
+			continue
+		}
+
+		attrView, err := av.ParseAttributeView(avID)
+		if nil != err {
+			continue
+		}
+
+		for _, kv := range attrView.KeyValues {
+			if av.KeyTypeDate != kv.Key.Type {
+				continue
+			}
+			ret = append(ret, newAttrViewCalendar(attrView, kv.Key))
+		}
+	}
+	return
+}
+
+func newTasksCalendar() caldav.Calendar {
+	return caldav.Calendar{
+		Path:                  CalDavTasksCalendarPath,
+		Name:                  CalDavTasksCalendarName,
+		Description:           "SiYuan tasks",
+		MaxResourceSize:       calendarMaxResourceSize,
+		SupportedComponentSet: []string{ical.CompToDo},
+	}
+}
+
+func newAttrViewCalendar(attrView *av.AttributeView, key *av.Key) caldav.Calendar {
+	name := attrView.Name
+	if "" == name {
+		name = attrView.ID
+	}
+	return caldav.Calendar{
+		Path:                  noteCalendarAttrViewPath(attrView.ID, key.ID),
+		Name:                  name + " - " + key.Name,
+		Description:           "SiYuan database " + name,
+		MaxResourceSize:       calendarMaxResourceSize,
+		SupportedComponentSet: []string{ical.CompEvent},
+	}
+}
+
+func getNoteCalendar(calendarPath string) (ret *caldav.Calendar, err error) {
+	if CalDavTasksCalendarPath == calendarPath {
+		calendar := newTasksCalendar()
+		ret = &calendar
+		return
+	}
+
+	attrView, key, err := getNoteCalendarAttrViewKey(calendarPath)
+	if nil != err {
+		return
+	}
+	calendar := newAttrViewCalendar(attrView, key)
+	ret = &calendar
+	return
+}
+
+func getNoteCalendarAttrViewKey(calendarPath string) (attrView *av.AttributeView, key *av.Key, err error) {
+	avID, keyID, err := parseNoteCalendarAttrViewPath(calendarPath)
+	if nil != err {
+		return
+	}
+
+	attrView, err = av.ParseAttributeView(avID)
+	if nil != err {
+		err = ErrorCalDavCalendarNotFound
+		return
+	}
+
+	key, err = attrView.GetKey(keyID)
+	if nil != err || av.KeyTypeDate != key.Type {
+		err = ErrorCalDavCalendarNotFound
+	}
+	return
+}
+
+func listNoteCalendarObjects(calendarPath string) (ret []caldav.CalendarObject, err error) {
+	if CalDavTasksCalendarPath == calendarPath {
+		for _, block := range queryDueTaskBlocks(nil) {
+			if object := newTaskCalendarObject(block); nil != object {
+				ret = append(ret, *object)
+			}
+		}
+		return
+	}
+
+	attrView, key, err := getNoteCalendarAttrViewKey(calendarPath)
+	if nil != err {
+		return
+	}
+
+	for _, value := range attrView.GetBlockKeyValues().Values {
+		if object := newAttrViewCalendarObject(attrView, key, value); nil != object {
+			ret = append(ret, *object)
+		}
+	}
+	return
+}
+
+func getNoteCalendarObject(objectPath string) (ret *caldav.CalendarObject, err error) {
+	calendarPath, objectID, err := parseNoteCalendarObjectPath(objectPath)
+	if nil != err {
+		return
+	}
+
+	if CalDavTasksCalendarPath == calendarPath {
+		if blocks := queryDueTaskBlocks([]string{objectID}); 0 < len(blocks) {
+			ret = newTaskCalendarObject(blocks[0])
+		}
+	} else {
+		var attrView *av.AttributeView
+		var key *av.Key
+		attrView, key, err = getNoteCalendarAttrViewKey(calendarPath)
+		if nil != err {
+			return
+		}
+		if blockValue := attrView.GetValue(attrView.GetBlockKeyValues().Key.ID, objectID); nil != blockValue {
+			ret = newAttrViewCalendarObject(attrView, key, blockValue)
+		}
+	}
+
+	if nil == ret {
+		err = ErrorCalDavCalendarObjectNotFound
+	}
+	return
+}
+
+func putNoteCalendarObject(objectPath string, calendarData *ical.Calendar) (ret *caldav.CalendarObject, err error) {
+	calendarPath, objectID, err := parseNoteCalendarObjectPath(objectPath)
+	if nil != err {
+		return
+	}
+
+	if CalDavTasksCalendarPath == calendarPath {
+		todo := findCalendarComponent(calendarData, ical.CompToDo)
+		if nil == todo {
+			err = ErrorCalDavNoteObjectInvalid
+			return
+		}
+
+		if err = updateTaskByCalendarComponent(objectID, todo); nil != err {
+			return
+		}
+	} else {
+		event := findCalendarComponent(calendarData, ical.CompEvent)
+		if nil == event {
+			err = ErrorCalDavNoteObjectInvalid
+			return
+		}
+
+		var attrView *av.AttributeView
+		var key *av.Key
+		attrView, key, err = getNoteCalendarAttrViewKey(calendarPath)
+		if nil != err {
+			return
+		}
+
+		if err = updateAttrViewDateByCalendarComponent(attrView.ID, key.ID, objectID, event); nil != err {
+			return
+		}
+	}
+	return getNoteCalendarObject(objectPath)
+}
+
+// deleteNoteCalendarObject 在客户端删除虚拟日历对象时仅清除日期，不删除块或数据库条目。
+func deleteNoteCalendarObject(objectPath string) (err error) {
+	calendarPath, objectID, err := parseNoteCalendarObjectPath(objectPath)
+	if nil != err {
+		return
+	}
+
+	if CalDavTasksCalendarPath == calendarPath {
+		return updateTaskListItem(objectID, nil, false, time.Time{}, false)
+	}
+
+	avID, keyID, err := parseNoteCalendarAttrViewPath(calendarPath)
+	if nil != err {
+		return
+	}
+	return updateAttrViewDate(avID, keyID, objectID, map[string]any{"isNotEmpty": false, "hasEndDate": false, "isNotEmpty2": false})
+}
+
+func parseNoteCalendarObjectPath(objectPath string) (calendarPath, objectID string, err error) {
+	calendarPath, objectID, err = ParseCalendarObjectPath(objectPath)
+	if nil != err {
+		return
+	}
+
+	objectID = strings.TrimSuffix(objectID, ICalendarFileExt)
+	if !ast.IsNodeIDPattern(objectID) {
+		err = ErrorCalDavCalendarObjectPathInvalid
+	}
+	return
+}
+
+func findCalendarComponent(calendarData *ical.Calendar, name string) *ical.Component {
+	if nil == calendarData {
+		return nil
+	}
+	for _, child := range calendarData.Children {
+		if name == child.Name {
+			return child
+		}
+	}
+	return nil
+}
+
+// queryDueTaskBlocks 查询设置了截止时间的任务列表项，ids 不为空时仅查询指定的块。
+func queryDueTaskBlocks(ids []string) (ret []*sql.Block) {
+	stmt := "SELECT block_id FROM attributes WHERE name = ?"
+	args := []any{NodeAttrTaskDue}
+	if 0 < len(ids) {
+		stmt += " AND block_id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	rows, err := sql.QueryNoLimitArgs(stmt, args...)
+	if nil != err {
+		logging.LogErrorf("query due tasks failed: %s", err)
+		return
+	}
+
+	var blockIDs []string
+	for _, row := range rows {
+		blockIDs = append(blockIDs, row["block_id"].(string))
+	}
+	for _, block := range sql.GetBlocks(blockIDs) {
+		if nil != block && "i" == block.Type && "t" == block.SubType {
+			ret = append(ret, block)
+		}
+	}
+	return
+}
+
+func newTaskCalendarObject(block *sql.Block) *caldav.CalendarObject {
+	due, dueIsDate, ok := parseTaskDue(parseIALValue(block.IAL, NodeAttrTaskDue))
+	if !ok {
+		return nil
+	}
+
+	updated := parseBlockTime(block.Updated)
+	todo := ical.NewComponent(ical.CompToDo)
+	todo.Props.SetText(ical.PropUID, block.ID)
+	todo.Props.SetDateTime(ical.PropDateTimeStamp, updated.UTC())
+	todo.Props.SetDateTime(ical.PropLastModified, updated.UTC())
+	todo.Props.SetText(ical.PropSummary, block.Content)
+	todo.Props.SetText(ical.PropDescription, block.HPath)
+	todo.Props.SetText(ical.PropURL, "siyuan://blocks/"+block.ID)
+	if dueIsDate {
+		todo.Props.SetDate(ical.PropDue, due)
+	} else {
+		todo.Props.SetDateTime(ical.PropDue, due.UTC())
+	}
+	if isTaskListItemChecked(block.Markdown) {
+		todo.Props.SetText(ical.PropStatus, calDavToDoStatusCompleted)
+		todo.Props.SetDateTime(ical.PropCompleted, updated.UTC())
+		todo.Props.SetText(ical.PropPercentComplete, "100")
+	} else {
+		todo.Props.SetText(ical.PropStatus, calDavToDoStatusNeedsAction)
+	}
+	return newNoteCalendarObject(PathJoinWithSlash(CalDavTasksCalendarPath, block.ID+ICalendarFileExt), todo, updated)
+}
+
+func newAttrViewCalendarObject(attrView *av.AttributeView, key *av.Key, blockValue *av.Value) *caldav.CalendarObject {
+	dateValue := attrView.GetValue(key.ID, blockValue.BlockID)
+	if nil == dateValue || nil == dateValue.Date || !dateValue.Date.IsNotEmpty {
+		return nil
+	}
+
+	updated := time.UnixMilli(dateValue.UpdatedAt)
+	if blockValue.UpdatedAt > dateValue.UpdatedAt {
+		updated = time.UnixMilli(blockValue.UpdatedAt)
+	}
+
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, blockValue.BlockID)
+	event.Props.SetDateTime(ical.PropDateTimeStamp, updated.UTC())
+	event.Props.SetDateTime(ical.PropLastModified, updated.UTC())
+	if nil != blockValue.Block {
+		event.Props.SetText(ical.PropSummary, blockValue.Block.Content)
+		if !blockValue.IsDetached && "" != blockValue.Block.ID {
+			event.Props.SetText(ical.PropURL, "siyuan://blocks/"+blockValue.Block.ID)
+		}
+	}
+
+	start := time.UnixMilli(dateValue.Date.Content)
+	end := time.UnixMilli(dateValue.Date.Content2)
+	hasEnd := dateValue.Date.HasEndDate && dateValue.Date.IsNotEmpty2 && !end.Before(start)
+	if dateValue.Date.IsNotTime {
+		event.Props.SetDate(ical.PropDateTimeStart, start)
+		if hasEnd {
+			// 全天事件的 DTEND 不包含在事件内
+			event.Props.SetDate(ical.PropDateTimeEnd, end.AddDate(0, 0, 1))
+		}
+	} else {
+		event.Props.SetDateTime(ical.PropDateTimeStart, start.UTC())
+		if hasEnd {
+			event.Props.SetDateTime(ical.PropDateTimeEnd, end.UTC())
+		}
+	}
+	return newNoteCalendarObject(PathJoinWithSlash(noteCalendarAttrViewPath(attrView.ID, key.ID), blockValue.BlockID+ICalendarFileExt), event.Component, updated)
+}
+
+func newNoteCalendarObject(objectPath string, component *ical.Component, modTime time.Time) *caldav.CalendarObject {
+	calendarData := ical.NewCalendar()
+	calendarData.Props.SetText(ical.PropVersion, "2.0")
+	calendarData.Props.SetText(ical.PropProductID, calDavProductID)
+	calendarData.Children = append(calendarData.Children, component)
+
+	var data bytes.Buffer
+	if err := ical.NewEncoder(&data).Encode(calendarData); nil != err {
+		logging.LogErrorf("encode iCalendar [%s] failed: %s", objectPath, err)
+		return nil
+	}
+
+	return &caldav.CalendarObject{
+		Path:          objectPath,
+		ModTime:       modTime,
+		ContentLength: int64(data.Len()),
+		ETag:          fmt.Sprintf("%x", sha1.Sum(data.Bytes())),
+		Data:          calendarData,
+	}
+}
+
+func updateTaskByCalendarComponent(blockID string, todo *ical.Component) (err error) {
+	status, _ := todo.Props.Text(ical.PropStatus)
+	status = strings.ToUpper(status)
+	checked := calDavToDoStatusCompleted == status || ("" == status && nil != todo.Props.Get(ical.PropCompleted))
+
+	dueProp := todo.Props.Get(ical.PropDue)
+	if nil == dueProp {
+		return updateTaskListItem(blockID, &checked, false, time.Time{}, false)
+	}
+
+	due, err := dueProp.DateTime(time.Local)
+	if nil != err {
+		return
+	}
+	return updateTaskListItem(blockID, &checked, true, due.Local(), ical.ValueDate == dueProp.ValueType() || len(dueProp.Value) == len("20060102"))
+}
+
+// updateTaskListItem 更新任务列表项的完成状态和截止时间，checked 为 nil 时不修改完成状态，hasDue 为 false 时移除截止时间。
+func updateTaskListItem(blockID string, checked *bool, hasDue bool, due time.Time, dueIsDate bool) (err error) {
+	tree, err := LoadTreeByBlockID(blockID)
+	if nil != err {
+		return
+	}
+
+	li := treenode.GetNodeInTree(tree, blockID)
+	if nil == li || ast.NodeListItem != li.Type || nil == li.ListData || 3 != li.ListData.Typ {
+		return ErrorCalDavCalendarObjectNotFound
+	}
+
+	if nil != checked {
+		markerNode := li.ChildByType(ast.NodeTaskListItemMarker)
+		if nil == markerNode {
+			return ErrorCalDavCalendarObjectNotFound
+		}
+		if *checked != markerNode.TaskListItemChecked {
+			if *checked {
+				markerNode.TaskListItemMarker = 'X'
+			} else {
+				markerNode.TaskListItemMarker = ' '
+			}
+			markerNode.TaskListItemChecked = *checked
+		}
+	}
+
+	if hasDue {
+		li.SetIALAttr(NodeAttrTaskDue, formatTaskDue(due, dueIsDate))
+	} else {
+		li.RemoveIALAttr(NodeAttrTaskDue)
+	}
+	treenode.RefreshUpdated(li)
+
+	transactions := []*Transaction{{DoOperations: []*Operation{{Action: "update", ID: blockID, Data: util.NewLute().RenderNodeBlockDOM(li)}}}}
+	PerformTransactions(&transactions)
+	FlushTxQueue()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = transactions
+	util.PushEvent(evt)
+	return
+}
+
+func updateAttrViewDateByCalendarComponent(avID, keyID, itemID string, event *ical.Component) (err error) {
+	startProp := event.Props.Get(ical.PropDateTimeStart)
+	if nil == startProp {
+		return ErrorCalDavNoteObjectInvalid
+	}
+
+	start, err := startProp.DateTime(time.Local)
+	if nil != err {
+		return
+	}
+	end, err := (&ical.Event{Component: event}).DateTimeEnd(time.Local)
+	if nil != err {
+		return
+	}
+
+	isNotTime := ical.ValueDate == startProp.ValueType() || len(startProp.Value) == len("20060102")
+	if isNotTime {
+		// 全天事件的 DTEND 不包含在事件内
+		end = end.AddDate(0, 0, -1)
+	}
+	hasEndDate := end.After(start)
+	if !hasEndDate {
+		end = start
+	}
+
+	return updateAttrViewDate(avID, keyID, itemID, map[string]any{
+		"content":     start.UnixMilli(),
+		"isNotEmpty":  true,
+		"isNotTime":   isNotTime,
+		"hasEndDate":  hasEndDate,
+		"content2":    end.UnixMilli(),
+		"isNotEmpty2": hasEndDate,
+	})
+}
+
+func updateAttrViewDate(avID, keyID, itemID string, date map[string]any) (err error) {
+	transactions := []*Transaction{{DoOperations: []*Operation{{Action: "updateAttrViewCell", AvID: avID, KeyID: keyID, RowID: itemID, Data: map[string]any{"date": date}}}}}
+	PerformTransactions(&transactions)
+	FlushTxQueue()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = transactions
+	util.PushEvent(evt)
+	ReloadAttrView(avID)
+	return
+}
+
+func parseTaskDue(value string) (ret time.Time, isDate, ok bool) {
+	value = strings.TrimSpace(value)
+	if "" == value {
+		return
+	}
+
+	for _, layout := range taskDueDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); nil == err {
+			return t, "2006-01-02" == layout, true
+		}
+	}
+	return
+}
+
+func formatTaskDue(due time.Time, isDate bool) string {
+	if isDate {
+		return due.Format("2006-01-02")
+	}
+	return due.Format("2006-01-02 15:04")
+}
+
+func isTaskListItemChecked(markdown string) bool {
+	matches := taskListItemMarkerReg.FindStringSubmatch(markdown)
+	return 1 < len(matches) && " " != matches[1]
+}
+
+func parseIALValue(ial, name string) string {
+	idx := strings.Index(ial, " "+name+"=\"")
+	if 0 > idx {
+		return ""
+	}
+
+	value := ial[idx+len(name)+3:]
+	if end := strings.Index(value, "\""); 0 <= end {
+		value = value[:end]
+	}
+	return util.UnescapeHTML(value)
+}
+
+func parseBlockTime(value string) time.Time {
+	ret, err := time.ParseInLocation("20060102150405", value, time.Local)
+	if nil != err {
+		return time.Now()
+	}
+	return ret
+}
@@ -0,0 +1,123 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+)
+
+func TestNoteCalendarPaths(t *testing.T) {
+	avID, keyID := "20240101120000-abcdefg", "20240101120001-hijklmn"
+	calendarPath := noteCalendarAttrViewPath(avID, keyID)
+	if !IsNoteCalendarPath(calendarPath) || !IsNoteCalendarPath(CalDavTasksCalendarPath) || IsNoteCalendarPath(CalDavDefaultCalendarPath) {
+		t.Fatalf("unexpected note calendar path detection [%s]", calendarPath)
+	}
+	if !isNoteCalendarObjectPath(calendarPath + "/20240101120002-opqrstu.ics") {
+		t.Fatal("expected note calendar object path")
+	}
+
+	gotAvID, gotKeyID, err := parseNoteCalendarAttrViewPath(calendarPath)
+	if nil != err || avID != gotAvID || keyID != gotKeyID {
+		t.Fatalf("parse note calendar path failed: [%s] [%s] [%v]", gotAvID, gotKeyID, err)
+	}
+	if _, _, err = parseNoteCalendarAttrViewPath(CalDavAttrViewCalendarPathPrefix + "foo"); nil == err {
+		t.Fatal("expected invalid note calendar path")
+	}
+	if _, _, err = parseNoteCalendarObjectPath(calendarPath + "/foo.ics"); nil == err {
+		t.Fatal("expected invalid note calendar object path")
+	}
+}
+
+func TestTaskCalendarObject(t *testing.T) {
+	for _, c := range []struct {
+		value  string
+		isDate bool
+		ok     bool
+	}{
+		{"2024-05-01", true, true},
+		{"2024-05-01 09:30", false, true},
+		{"2024-05-01T09:30:00", false, true},
+		{"tomorrow", false, false},
+	} {
+		_, isDate, ok := parseTaskDue(c.value)
+		if c.ok != ok || c.isDate != isDate {
+			t.Fatalf("parse task due [%s] got [%v] [%v]", c.value, isDate, ok)
+		}
+	}
+
+	if !isTaskListItemChecked("* [X] foo") || !isTaskListItemChecked("1. [x] foo") || isTaskListItemChecked("- [ ] foo") {
+		t.Fatal("unexpected task list item checked state")
+	}
+
+	block := &sql.Block{
+		ID:       "20240101120002-opqrstu",
+		Type:     "i",
+		SubType:  "t",
+		Content:  "Write report",
+		Markdown: "* [X] Write report",
+		IAL:      `{: id="20240101120002-opqrstu" custom-task-due="2024-05-01 09:30" updated="20240102030405"}`,
+		Updated:  "20240102030405",
+	}
+	object := newTaskCalendarObject(block)
+	if nil == object {
+		t.Fatal("expected task calendar object")
+	}
+	todo := findCalendarComponent(object.Data, ical.CompToDo)
+	if status, _ := todo.Props.Text(ical.PropStatus); calDavToDoStatusCompleted != status {
+		t.Fatalf("expected completed task, got [%s]", status)
+	}
+	due, err := todo.Props.DateTime(ical.PropDue, time.Local)
+	if nil != err || "2024-05-01 09:30" != formatTaskDue(due.Local(), false) {
+		t.Fatalf("unexpected task due [%s] [%v]", due, err)
+	}
+
+	block.IAL = `{: id="20240101120002-opqrstu"}`
+	if nil != newTaskCalendarObject(block) {
+		t.Fatal("expected no calendar object for task without due date")
+	}
+}
+
+func TestAttrViewCalendarObject(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2024, 5, 3, 0, 0, 0, 0, time.Local)
+	attrView := &av.AttributeView{ID: "20240101120000-abcdefg", KeyValues: []*av.KeyValues{
+		{Key: &av.Key{ID: "20240101120001-aaaaaaa", Name: "Name", Type: av.KeyTypeBlock}, Values: []*av.Value{
+			{KeyID: "20240101120001-aaaaaaa", BlockID: "20240101120003-bbbbbbb", Type: av.KeyTypeBlock, IsDetached: true, Block: &av.ValueBlock{Content: "Sprint"}},
+		}},
+		{Key: &av.Key{ID: "20240101120001-ccccccc", Name: "When", Type: av.KeyTypeDate}, Values: []*av.Value{
+			{KeyID: "20240101120001-ccccccc", BlockID: "20240101120003-bbbbbbb", Type: av.KeyTypeDate, Date: &av.ValueDate{Content: start.UnixMilli(), IsNotEmpty: true, IsNotTime: true, HasEndDate: true, Content2: end.UnixMilli(), IsNotEmpty2: true}},
+		}},
+	}}
+
+	key := attrView.KeyValues[1].Key
+	object := newAttrViewCalendarObject(attrView, key, attrView.KeyValues[0].Values[0])
+	if nil == object {
+		t.Fatal("expected database calendar object")
+	}
+	event := &ical.Event{Component: findCalendarComponent(object.Data, ical.CompEvent)}
+	if summary, _ := event.Props.Text(ical.PropSummary); "Sprint" != summary {
+		t.Fatalf("unexpected event summary [%s]", summary)
+	}
+	if dtEnd, _ := event.DateTimeEnd(time.Local); !dtEnd.Equal(end.AddDate(0, 0, 1)) {
+		t.Fatalf("expected exclusive all-day end, got [%s]", dtEnd)
+	}
+}
@@ -73,6 +73,8 @@ type AppConf struct {
 	AI             *conf.AI             `json:"ai"`             // 人工智能配置
 	Secrets        *conf.Secrets        `json:"secrets"`        // 全局密钥库
 	Variables      *conf.Variables      `json:"variables"`      // 全局变量库
+	Webhooks       *conf.Webhooks       `json:"webhooks"`       // 出站 Webhook
+	Image          *conf.ImagePipeline  `json:"image"`          // 上传图片处理
 	Bazaar         *conf.Bazaar         `json:"bazaar"`         // 集市配置
 	Stat           *conf.Stat           `json:"stat"`           // 统计
 	Api            *conf.API            `json:"api"`            // API
@@ -605,6 +607,16 @@ func InitConf() {
 	if nil == Conf.Search.HanSensitive {
 		Conf.Search.SetHanSensitive(true)
 	}
+	if conf.HybridFusionRRF != Conf.Search.HybridFusion && conf.HybridFusionWeighted != Conf.Search.HybridFusion {
+		Conf.Search.HybridFusion = conf.HybridFusionRRF
+	}
+	if 0 > Conf.Search.HybridSemanticWeight || 1 < Conf.Search.HybridSemanticWeight || (0 == Conf.Search.HybridSemanticWeight && 1 > Conf.Search.HybridCandidateCount) {
+		// 旧配置没有混合搜索字段，权重和候选数同时为零值时视为未设置
+		Conf.Search.HybridSemanticWeight = 0.5
+	}
+	if 1 > Conf.Search.HybridCandidateCount {
+		Conf.Search.HybridCandidateCount = 64
+	}
 	sql.SetHanSensitive(Conf.Search.HanSensitiveVal())
 
 	if nil == Conf.Stat {
@@ -674,6 +686,25 @@ func InitConf() {
 		Conf.Variables = conf.NewVariables()
 	}
 
+	if nil == Conf.Webhooks {
+		Conf.Webhooks = conf.NewWebhooks()
+	} else {
+		Conf.Webhooks.Decrypt()
+	}
+
+	if nil == Conf.Image {
+		Conf.Image = conf.NewImagePipeline()
+	}
+	if conf.ImageFormatJPEG != Conf.Image.Format {
+		Conf.Image.Format = conf.ImageFormatOriginal
+	}
+	if 1 > Conf.Image.Quality || 100 < Conf.Image.Quality {
+		Conf.Image.Quality = conf.NewImagePipeline().Quality
+	}
+	if 0 > Conf.Image.MaxDimension {
+		Conf.Image.MaxDimension = 0
+	}
+
 	for _, p := range Conf.AI.Providers {
 		if p == nil || !p.Enabled {
 			continue
@@ -882,6 +913,7 @@ func Close(force, setCurrentWorkspace bool, execInstallPkg int) (exitCode int, i
 
 	util.PushMsg(Conf.Language(95), 10000*60)
 	FlushTxQueue()
+	flushAIUsage()
 
 	cancelPurge()
 
@@ -908,6 +940,8 @@ func Close(force, setCurrentWorkspace bool, execInstallPkg int) (exitCode int, i
 
 	// Improve indexing completeness when exiting https://github.com/siyuan-note/siyuan/issues/12039
 	sql.FlushQueue()
+	SaveUndoLog()
+	SaveWebhookDeliveries()
 
 	util.IsExiting.Store(true)
 	newVerInstallPkgPath := getNewVerInstallPkgPath()
@@ -936,6 +970,7 @@ func Close(force, setCurrentWorkspace bool, execInstallPkg int) (exitCode int, i
 	sql.CloseDatabase()
 	closePushQueue()
 	util.SaveAssetsTexts()
+	saveEmbeddingANN(true)
 	clearWorkspaceTemp("" != installPkgPath)
 	clearCorruptedNotebooks()
 	clearPortJSON()
@@ -1039,6 +1074,9 @@ func (conf *AppConf) Save() {
 	if snapshot.Secrets != nil {
 		snapshot.Secrets.Encrypt()
 	}
+	if snapshot.Webhooks != nil {
+		snapshot.Webhooks.Encrypt()
+	}
 	// safeMode 是纯运行时状态（由 --safe-mode 注入），不随 conf.json 持久化，避免跨启动残留。
 	if snapshot.System != nil {
 		snapshot.System.SafeMode = false
@@ -1248,6 +1286,7 @@ func HideConfSecret(c *AppConf) {
 	c.Sync = &conf.Sync{}
 	c.Secrets = &conf.Secrets{}
 	c.Variables = &conf.Variables{}
+	c.Webhooks = &conf.Webhooks{}
 	c.System.AppDir = ""
 	c.System.ConfDir = ""
 	c.System.DataDir = ""
@@ -105,15 +105,26 @@ func StartEmbeddingIndexer() {
 
 	embeddingTableOk = true
 
+	// ANN 索引在后台加载并与嵌入表对账，首次构建耗时较长，期间语义搜索回退精确扫描
+	go syncEmbeddingANN()
+	annSyncTicker := time.NewTicker(annSyncInterval)
+	defer annSyncTicker.Stop()
+
 	processPendingEmbeddings()
+	processPendingAssetEmbeddings()
 
 	for {
 		select {
 		case <-embeddingDirtyCh:
 			processPendingEmbeddings()
+			processPendingAssetEmbeddings()
 		case <-time.After(30 * time.Second):
 			processPendingEmbeddings()
+			processPendingAssetEmbeddings()
+		case <-annSyncTicker.C:
+			go syncEmbeddingANN()
 		}
+		saveEmbeddingANN(false)
 	}
 }
 
@@ -320,7 +331,7 @@ func recordFailedEmbedding(blocks []map[string]any, reason string) {
 }
 
 func doEmbedAndStore(texts []string, blocks []map[string]any) {
-	vectors, err := util.BatchGetEmbeddings(texts, embeddingKey(), embeddingBaseURL(), embeddingModel(), embeddingDimensions(), embeddingTimeout())
+	vectors, err := getEmbeddings(texts)
 	if err != nil {
 		// 任何 API 错误（含模型不存在/鉴权失败/限流/网络异常）都熔断本轮，避免连接风暴
 		recordFailedEmbedding(blocks, err.Error())
@@ -333,6 +344,7 @@ func doEmbedAndStore(texts []string, blocks []map[string]any) {
 		return
 	}
 
+	var storedIDs []string
 	for i, row := range blocks {
 		id, _ := row["id"].(string)
 		rootID, _ := row["root_id"].(string)
@@ -348,8 +360,13 @@ func doEmbedAndStore(texts []string, blocks []map[string]any) {
 			id, rootID, box, path, buf, embeddingModel(), len(plainText), updated)
 		if err != nil {
 			logging.LogErrorf("store embedding failed for block [%s]: %s", id, err)
+			continue
 		}
+		storedIDs = append(storedIDs, id)
 	}
+
+	// 增量维护 ANN 索引
+	indexEmbeddingANN(storedIDs)
 }
 
 func getEmbeddingIgnoreMatcher() *ignore.GitIgnore {
@@ -424,6 +441,32 @@ func (h *scoredHeap) Pop() any {
 	return x
 }
 
+// embeddingSearchFilter 语义搜索的笔记本、路径、类型过滤条件，精确扫描和 ANN 候选过滤共用。
+// clause 以 " AND " 开头，引用 be.（block_embeddings）与 b.（blocks）两个别名。
+type embeddingSearchFilter struct {
+	clause string
+	args   []any
+}
+
+// newEmbeddingSearchFilter 构造过滤条件，无任何过滤时返回 nil。
+func newEmbeddingSearchFilter(boxes, paths []string, types, subTypes map[string]bool) *embeddingSearchFilter {
+	boxFilter, boxArgs := buildBoxesFilter(boxes, "be.")
+	pathFilter, pathArgs := buildPathsFilter(paths, "be.")
+	boxDocFilter, boxDocArgs := buildRootIDExclusionFilter(hiddenBoxDocRootIDs(), "b.")
+	if 1 > len(boxes) && 1 > len(paths) && 1 > len(types) && "" == boxDocFilter {
+		return nil
+	}
+
+	ret := &embeddingSearchFilter{}
+	if 0 < len(types) {
+		ret.clause += " AND " + buildTypeFilter(types, subTypes, "b.")
+	}
+	ret.clause += boxFilter + pathFilter + boxDocFilter
+	// 过滤值通过绑定参数传递，避免 SQL 拼接注入
+	ret.args = append(append(append([]any{}, boxArgs...), pathArgs...), boxDocArgs...)
+	return ret
+}
+
 func SemanticSearchBlock(query string, boxes, paths []string, types, subTypes map[string]bool, page, pageSize int) (blocks []*Block, matchedBlockCount, matchedRootCount, pageCount int) {
 	blocks = []*Block{}
 
@@ -431,27 +474,64 @@ func SemanticSearchBlock(query string, boxes, paths []string, types, subTypes ma
 		return
 	}
 
-	vectors, err := util.BatchGetEmbeddings([]string{query}, embeddingKey(), embeddingBaseURL(), embeddingModel(), embeddingDimensions(), embeddingTimeout())
+	vectors, err := getEmbeddings([]string{query})
 	if err != nil || 1 > len(vectors) {
 		logging.LogErrorf("get query embedding failed")
 		return
 	}
 	queryVec := vectors[0]
 
-	boxFilter, boxArgs := buildBoxesFilter(boxes, "be.")
-	pathFilter, pathArgs := buildPathsFilter(paths, "be.")
-	boxDocFilter, boxDocArgs := buildRootIDExclusionFilter(hiddenBoxDocRootIDs(), "b.")
-	typeFilter := buildTypeFilter(types, subTypes, "b.")
-	hasFilter := 0 < len(boxes) || 0 < len(paths) || 0 < len(types) || "" != boxDocFilter
-	hasTypeFilter := 0 < len(types)
-
-	numWorkers := max(runtime.GOMAXPROCS(0), 1)
-
 	// 向量召回候选数：启用重排时固定召回 candidateCount 条，保证所有分页基于同一候选集；否则只取当前页所需。
 	topK := page * pageSize
 	if isRerankEnabled() {
 		topK = rerankCandidateCount()
 	}
+
+	result := searchEmbeddings(queryVec, newEmbeddingSearchFilter(boxes, paths, types, subTypes), topK)
+	matchedBlockCount = len(result)
+	if 1 > matchedBlockCount {
+		pageCount = 0
+		return
+	}
+
+	// 按向量相似度降序取出全部候选块 ID。重排启用时 result 已是固定的 candidateCount；
+	// 未启用时 result 即当前页所需，后续分页逻辑统一处理。
+	var candidateIDs []string
+	for _, s := range result {
+		candidateIDs = append(candidateIDs, s.id)
+	}
+
+	sqlBlocks := sql.GetBlocks(candidateIDs)
+
+	// 重排：对 query 与候选块文本逐对精排，失败则降级保留向量相似度原序，不阻断搜索。
+	// 注意 GetBlocks 的返回顺序未必与 candidateIDs 一致，重排以返回的 sqlBlocks 为准。
+	sqlBlocks = rerankSqlBlocks(query, sqlBlocks)
+
+	offset := (page - 1) * pageSize
+	if offset >= len(sqlBlocks) {
+		pageCount = (matchedBlockCount + pageSize - 1) / pageSize
+		return
+	}
+
+	end := min(offset+pageSize, len(sqlBlocks))
+
+	rootIDSet := map[string]bool{}
+	for i := offset; i < end; i++ {
+		b := sqlBlocks[i]
+		rootIDSet[b.RootID] = true
+		blocks = append(blocks, fromSQLBlock(b, "", 36))
+	}
+	matchedRootCount = len(rootIDSet)
+	pageCount = (matchedBlockCount + pageSize - 1) / pageSize
+
+	return
+}
+
+// bruteForceSearchEmbeddings 分批扫描嵌入表逐条计算余弦相似度，返回按相似度降序的 topK 个块。
+// 结果精确，用于 ANN 索引未就绪、数据量较小或过滤选择性很强的场景，也用作召回率抽样的基准。
+func bruteForceSearchEmbeddings(queryVec []float32, filter *embeddingSearchFilter, topK int) []scoredBlock {
+	numWorkers := max(runtime.GOMAXPROCS(0), 1)
+
 	h := &scoredHeap{}
 	heap.Init(h)
 
@@ -461,14 +541,10 @@ func SemanticSearchBlock(query string, boxes, paths []string, types, subTypes ma
 	for {
 		var q string
 		var args []any
-		if hasFilter {
+		if nil != filter {
 			q = fmt.Sprintf("SELECT be.rowid, be.id, be.embedding FROM block_embeddings be JOIN blocks b ON be.id = b.id WHERE be.embedding IS NOT NULL AND length(be.embedding) > 0 AND be.rowid > %d", cursor)
-			if hasTypeFilter {
-				q += " AND " + typeFilter
-			}
-			q += boxFilter + pathFilter + boxDocFilter
-			// 过滤值通过绑定参数传递，避免 SQL 拼接注入
-			args = append(append(append([]any{}, boxArgs...), pathArgs...), boxDocArgs...)
+			q += filter.clause
+			args = filter.args
 			q += fmt.Sprintf(" ORDER BY be.rowid LIMIT %d", scanSize)
 		} else {
 			q = fmt.Sprintf("SELECT rowid, id, embedding FROM block_embeddings WHERE embedding IS NOT NULL AND length(embedding) > 0 AND rowid > %d ORDER BY rowid LIMIT %d", cursor, scanSize)
@@ -533,48 +609,11 @@ func SemanticSearchBlock(query string, boxes, paths []string, types, subTypes ma
 		}
 	}
 
-	matchedBlockCount = h.Len()
-	if 1 > matchedBlockCount {
-		pageCount = 0
-		return
-	}
-
 	result := make([]scoredBlock, h.Len())
 	for i := len(result) - 1; i >= 0; i-- {
 		result[i] = heap.Pop(h).(scoredBlock)
 	}
-
-	// 按向量相似度降序取出全部候选块 ID。重排启用时 result 已是固定的 candidateCount；
-	// 未启用时 result 即当前页所需，后续分页逻辑统一处理。
-	var candidateIDs []string
-	for _, s := range result {
-		candidateIDs = append(candidateIDs, s.id)
-	}
-
-	sqlBlocks := sql.GetBlocks(candidateIDs)
-
-	// 重排：对 query 与候选块文本逐对精排，失败则降级保留向量相似度原序，不阻断搜索。
-	// 注意 GetBlocks 的返回顺序未必与 candidateIDs 一致，重排以返回的 sqlBlocks 为准。
-	sqlBlocks = rerankSqlBlocks(query, sqlBlocks)
-
-	offset := (page - 1) * pageSize
-	if offset >= len(sqlBlocks) {
-		pageCount = (matchedBlockCount + pageSize - 1) / pageSize
-		return
-	}
-
-	end := min(offset+pageSize, len(sqlBlocks))
-
-	rootIDSet := map[string]bool{}
-	for i := offset; i < end; i++ {
-		b := sqlBlocks[i]
-		rootIDSet[b.RootID] = true
-		blocks = append(blocks, fromSQLBlock(b, "", 36))
-	}
-	matchedRootCount = len(rootIDSet)
-	pageCount = (matchedBlockCount + pageSize - 1) / pageSize
-
-	return
+	return result
 }
 
 func isEmbeddingEnabled() bool {
@@ -594,8 +633,7 @@ func rerankSqlBlocks(query string, sqlBlocks []*sql.Block) []*sql.Block {
 		documents[i] = b.Content
 	}
 
-	// topN=0 表示不传 top_n，要求服务端返回全部文档评分，避免被服务端 top_n 上限截断
-	indices, _, err := util.Rerank(query, documents, rerankKey(), rerankEndpoint(), rerankModel(), 0, rerankTimeout())
+	indices, err := rerank(query, documents)
 	if nil != err {
 		logging.LogErrorf("rerank failed, fallback to vector similarity order: %s", err)
 		return sqlBlocks
@@ -644,6 +682,13 @@ func fullReindexEmbedding() {
 		logging.LogErrorf("clear block_embeddings failed: %s", err)
 		return
 	}
+	if err := sql.Exec("DELETE FROM asset_embeddings"); err != nil {
+		logging.LogErrorf("clear asset_embeddings failed: %s", err)
+	}
+	assetEmbeddingFailCount.Store(0)
+	assetEmbeddingRetryAt.Store(0)
+	// ANN 索引随表一起清空，重嵌时由 doEmbedAndStore 增量重建
+	resetEmbeddingANN()
 	logging.LogInfof("embedding vectors cleared, indexer will re-embed all blocks")
 
 	// 若后台索引器死循环未运行（用户启动内核时嵌入未启用、随后才开启并点重建），这里补启动。
@@ -694,11 +739,15 @@ type EmbeddingStat struct {
 	IgnoredByLen    int  `json:"ignoredByLen"`    // 长度忽略（内容过短或过长，ignored_type=1）
 	IgnoredByConfig int  `json:"ignoredByConfig"` // 配置忽略（被 .siyuan/embeddingignore 匹配，ignored_type=2）
 	Enabled         bool `json:"enabled"`         // 是否已启用嵌入
+	AssetIndexed    int  `json:"assetIndexed"`    // 资源文件内容的有效向量数（按段计数）
+	AssetFiles      int  `json:"assetFiles"`      // 已嵌入的资源文件数
+
+	ANN *EmbeddingANNStat `json:"ann"` // 近似最近邻索引状态与召回/延迟统计
 }
 
 // GetEmbeddingStat 查询嵌入索引进度统计。表不存在或未启用时返回零值统计。
 func GetEmbeddingStat() (ret *EmbeddingStat) {
-	ret = &EmbeddingStat{Enabled: isEmbeddingEnabled()}
+	ret = &EmbeddingStat{Enabled: isEmbeddingEnabled(), ANN: getEmbeddingANNStat()}
 	if !checkEmbeddingTable() {
 		return
 	}
@@ -733,6 +782,16 @@ func GetEmbeddingStat() (ret *EmbeddingStat) {
 		}
 	}
 
+	rows, err = sql.QueryNoLimit("SELECT COUNT(*) AS c, COUNT(DISTINCT path) AS files FROM asset_embeddings WHERE length(embedding) > 0")
+	if err == nil && 0 < len(rows) {
+		if c, ok := rows[0]["c"].(int64); ok {
+			ret.AssetIndexed = int(c)
+		}
+		if files, ok := rows[0]["files"].(int64); ok {
+			ret.AssetFiles = int(files)
+		}
+	}
+
 	// 忽略块按原因分别统计：ignored_type=1 为长度忽略，=2 为配置忽略
 	rows, err = sql.QueryNoLimit("SELECT SUM(CASE WHEN ignored_type = 1 THEN 1 ELSE 0 END) AS by_len, SUM(CASE WHEN ignored_type = 2 THEN 1 ELSE 0 END) AS by_conf FROM block_embeddings WHERE ignored_type > 0")
 	if err == nil && 0 < len(rows) {
@@ -766,6 +825,14 @@ func embeddingBaseURL() string {
 	return ""
 }
 
+// embeddingProtocol 返回嵌入服务的协议，使用环境变量配置时固定为 OpenAI 协议。
+func embeddingProtocol() string {
+	if nil != Conf.AI.Embedding && Conf.AI.Embedding.Enabled && "" != Conf.AI.Embedding.BaseURL && "" != Conf.AI.Embedding.Protocol {
+		return Conf.AI.Embedding.Protocol
+	}
+	return util.AIProtocolOpenAI
+}
+
 func embeddingTimeout() int {
 	if nil != Conf.AI.Embedding && Conf.AI.Embedding.Enabled && 0 < Conf.AI.Embedding.Timeout {
 		return Conf.AI.Embedding.Timeout
@@ -782,6 +849,19 @@ func embeddingDimensions() int {
 	return 0
 }
 
+// getEmbeddings 使用当前嵌入配置获取文本向量，并记录用量。
+func getEmbeddings(texts []string) (ret [][]float32, err error) {
+	model := embeddingModel()
+	if err = CheckAIBudget(model); nil != err {
+		return
+	}
+	ret, usage, err := util.BatchGetEmbeddings(texts, embeddingProtocol(), embeddingKey(), embeddingBaseURL(), model, embeddingDimensions(), embeddingTimeout())
+	if nil == err {
+		RecordAIUsage(AIFeatureEmbedding, aiProviderName("", embeddingBaseURL()), model, "", usage)
+	}
+	return
+}
+
 func embeddingModel() string {
 	if nil != Conf.AI.Embedding && Conf.AI.Embedding.Enabled && "" != Conf.AI.Embedding.Name {
 		return Conf.AI.Embedding.Name
@@ -0,0 +1,1082 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 块嵌入向量的近似最近邻（ANN）索引。
+//
+// 语义搜索原先对 block_embeddings 全表逐行解码并计算余弦相似度，几十万块时单次搜索需要数秒。
+// 这里在内存中维护一张 HNSW（分层可导航小世界）图：
+//   - 向量归一化后按块做 int8 标量量化，内存约为 float32 的 1/4，图搜索只用量化向量；
+//   - 图搜索得到的候选再回表取原始向量精确打分并套用笔记本/路径/类型过滤，排序结果与精确扫描一致；
+//   - block_embeddings 是唯一事实来源，索引按 rowid 与表对账（INSERT OR REPLACE 会换 rowid），
+//     新增/重嵌的块由 doEmbedAndStore 增量写入，删除的块在对账或搜索回表时打墓碑，墓碑过多时整图压缩；
+//   - 索引持久化到 temp/embedding_ann.idx，重启后加载再对账，不必全量重建。
+
+const (
+	annIndexVersion = 1
+	annIndexMagic   = 0x53594e4e // "SYNN"
+
+	annM              = 16  // 上层每个节点的最大邻居数
+	annM0             = 32  // 第 0 层每个节点的最大邻居数
+	annMaxLevel       = 16  // 层数上限，防止极端随机值
+	annEfConstruction = 128 // 构图时的候选队列长度
+	annEfSearch       = 96  // 搜索时的最小候选队列长度
+
+	// 有效向量数（或过滤后的候选行数）不超过该值时直接精确扫描：数据量小时精确扫描足够快，
+	// 强选择性过滤下图搜索后再过滤又容易召回不足
+	annBruteForceThreshold = 8192
+	annMaxCandidates       = 4096 // 单次图搜索最多取回的候选数
+	annCandidateBatch      = 500  // 候选回表时 IN 子句的分批大小
+	annCompactRatio        = 0.25 // 墓碑占比超过该值时压缩重建
+	annSaveInterval        = time.Minute
+	annSyncInterval        = 10 * time.Minute
+	annRecallSampleEvery   = 32 // 每 N 次 ANN 搜索抽样一次，在后台与精确扫描对比估算召回率
+)
+
+var (
+	embeddingANN atomic.Pointer[hnswIndex]
+
+	// embeddingANNReady 为真表示索引已与嵌入表完成至少一次对账，可用于搜索；否则搜索回退精确扫描。
+	embeddingANNReady   atomic.Bool
+	embeddingANNSyncing atomic.Bool
+	embeddingANNRecall  atomic.Bool // 召回率抽样是否正在后台运行
+
+	embeddingANNSaveLock sync.Mutex
+	embeddingANNSavedAt  time.Time
+
+	embeddingANNStatLock sync.Mutex
+	embeddingANNStat     = annStatCounter{}
+)
+
+type annStatCounter struct {
+	searches      int64
+	fallbacks     int64
+	totalLatency  time.Duration
+	lastLatency   time.Duration
+	recallSum     float64
+	recallSamples int64
+	syncedAt      int64
+}
+
+// EmbeddingANNStat 近似最近邻索引的状态以及搜索延迟和召回率统计。
+type EmbeddingANNStat struct {
+	Ready         bool    `json:"ready"`         // 索引是否可用于搜索
+	Syncing       bool    `json:"syncing"`       // 是否正在后台对账或构建
+	Nodes         int     `json:"nodes"`         // 索引中的有效向量数
+	Deleted       int     `json:"deleted"`       // 待压缩的墓碑节点数
+	Dimensions    int     `json:"dimensions"`    // 索引向量维度
+	Searches      int64   `json:"searches"`      // 经索引完成的搜索次数
+	Fallbacks     int64   `json:"fallbacks"`     // 回退为精确扫描的搜索次数
+	AvgLatencyMs  float64 `json:"avgLatencyMs"`  // 经索引搜索的平均耗时
+	LastLatencyMs float64 `json:"lastLatencyMs"` // 最近一次经索引搜索的耗时
+	Recall        float64 `json:"recall"`        // 抽样估计的 recall@k，未抽样时为 0
+	RecallSamples int64   `json:"recallSamples"` // 召回率抽样次数
+	SyncedAt      int64   `json:"syncedAt"`      // 最近一次完成对账的时间（毫秒）
+}
+
+func getEmbeddingANNStat() (ret *EmbeddingANNStat) {
+	ret = &EmbeddingANNStat{Ready: embeddingANNReady.Load(), Syncing: embeddingANNSyncing.Load()}
+	if idx := embeddingANN.Load(); nil != idx {
+		ret.Nodes, ret.Deleted, ret.Dimensions = idx.stat()
+	}
+
+	embeddingANNStatLock.Lock()
+	defer embeddingANNStatLock.Unlock()
+	ret.Searches = embeddingANNStat.searches
+	ret.Fallbacks = embeddingANNStat.fallbacks
+	if 0 < embeddingANNStat.searches {
+		ret.AvgLatencyMs = float64(embeddingANNStat.totalLatency.Microseconds()) / 1000 / float64(embeddingANNStat.searches)
+	}
+	ret.LastLatencyMs = float64(embeddingANNStat.lastLatency.Microseconds()) / 1000
+	if 0 < embeddingANNStat.recallSamples {
+		ret.Recall = embeddingANNStat.recallSum / float64(embeddingANNStat.recallSamples)
+	}
+	ret.RecallSamples = embeddingANNStat.recallSamples
+	ret.SyncedAt = embeddingANNStat.syncedAt
+	return
+}
+
+// searchEmbeddings 返回与 queryVec 最相似的 topK 个块（按相似度降序）。索引可用时走 ANN，否则精确扫描。
+func searchEmbeddings(queryVec []float32, filter *embeddingSearchFilter, topK int) []scoredBlock {
+	if idx := embeddingANN.Load(); nil != idx && embeddingANNReady.Load() {
+		start := time.Now()
+		if ret, ok := annSearchEmbeddings(idx, queryVec, filter, topK); ok {
+			elapsed := time.Since(start)
+			embeddingANNStatLock.Lock()
+			embeddingANNStat.searches++
+			embeddingANNStat.totalLatency += elapsed
+			embeddingANNStat.lastLatency = elapsed
+			sample := 0 == embeddingANNStat.searches%annRecallSampleEvery
+			embeddingANNStatLock.Unlock()
+
+			if sample && embeddingANNRecall.CompareAndSwap(false, true) {
+				go sampleEmbeddingANNRecall(queryVec, filter, topK, ret)
+			}
+			return ret
+		}
+
+		embeddingANNStatLock.Lock()
+		embeddingANNStat.fallbacks++
+		embeddingANNStatLock.Unlock()
+	}
+	return bruteForceSearchEmbeddings(queryVec, filter, topK)
+}
+
+// sampleEmbeddingANNRecall 在后台用精确扫描复算同一查询，按两者 topK 的交集估算召回率。
+func sampleEmbeddingANNRecall(queryVec []float32, filter *embeddingSearchFilter, topK int, annResult []scoredBlock) {
+	defer embeddingANNRecall.Store(false)
+
+	exact := bruteForceSearchEmbeddings(queryVec, filter, topK)
+	if 1 > len(exact) {
+		return
+	}
+
+	got := map[string]bool{}
+	for _, s := range annResult {
+		got[s.id] = true
+	}
+	hit := 0
+	for _, s := range exact {
+		if got[s.id] {
+			hit++
+		}
+	}
+
+	embeddingANNStatLock.Lock()
+	embeddingANNStat.recallSum += float64(hit) / float64(len(exact))
+	embeddingANNStat.recallSamples++
+	embeddingANNStatLock.Unlock()
+}
+
+// annSearchEmbeddings 用 ANN 索引召回候选，再回表精确打分和过滤。ok 为假时调用方应回退精确扫描。
+func annSearchEmbeddings(idx *hnswIndex, queryVec []float32, filter *embeddingSearchFilter, topK int) (ret []scoredBlock, ok bool) {
+	live, _, dim := idx.stat()
+	if dim != len(queryVec) || live <= annBruteForceThreshold || topK > annMaxCandidates {
+		return
+	}
+
+	k := topK
+	if nil != filter {
+		filtered := countFilteredEmbeddings(filter)
+		if 0 > filtered || filtered <= annBruteForceThreshold {
+			// 过滤后数据量小，精确扫描更快也更准
+			return
+		}
+		// 按过滤选择性放大候选数，使过滤后仍大概率剩够 topK 条
+		k = topK * min(max(live/filtered, 1), 32)
+	}
+
+	q, valid := normalizeVector(queryVec)
+	if !valid {
+		return
+	}
+
+	// 有效向量数大于 annBruteForceThreshold，必然多于 annMaxCandidates，候选不足只可能是被过滤或墓碑占用，放大重试即可
+	for {
+		k = min(k, annMaxCandidates)
+		candidates := idx.search(q, k, max(annEfSearch, k))
+		ret = rescoreANNCandidates(idx, queryVec, candidates, filter)
+		if len(ret) >= topK {
+			ret = ret[:topK]
+			ok = true
+			return
+		}
+		if k >= annMaxCandidates {
+			// 放大到上限仍不够，交给精确扫描
+			return nil, false
+		}
+		k *= 2
+	}
+}
+
+// rescoreANNCandidates 回表读取候选的原始向量精确打分，同时套用过滤条件。
+// 表中已不存在或向量为空的候选说明块已删除或正在重嵌，顺带在索引中打墓碑。
+func rescoreANNCandidates(idx *hnswIndex, queryVec []float32, candidates []scoredBlock, filter *embeddingSearchFilter) (ret []scoredBlock) {
+	for start := 0; start < len(candidates); start += annCandidateBatch {
+		batch := candidates[start:min(start+annCandidateBatch, len(candidates))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		args := make([]any, 0, len(batch))
+		for _, c := range batch {
+			args = append(args, c.id)
+		}
+
+		rows, err := sql.QueryNoLimitArgs("SELECT id, embedding FROM block_embeddings WHERE id IN ("+placeholders+") AND length(embedding) > 0", args...)
+		if err != nil {
+			logging.LogErrorf("query embeddings for ann candidates failed: %s", err)
+			return
+		}
+		vectors := map[string][]float32{}
+		for _, row := range rows {
+			id, _ := row["id"].(string)
+			embRaw, _ := row["embedding"].([]byte)
+			buf := make([]byte, len(embRaw))
+			copy(buf, embRaw)
+			vectors[id] = decodeVector(buf)
+		}
+		for _, c := range batch {
+			if _, exists := vectors[c.id]; !exists {
+				idx.remove(c.id)
+			}
+		}
+
+		if nil != filter {
+			q := "SELECT be.id FROM block_embeddings be JOIN blocks b ON be.id = b.id WHERE be.id IN (" + placeholders + ")" + filter.clause
+			rows, err = sql.QueryNoLimitArgs(q, append(args, filter.args...)...)
+			if err != nil {
+				logging.LogErrorf("filter ann candidates failed: %s", err)
+				return
+			}
+			accepted := map[string][]float32{}
+			for _, row := range rows {
+				id, _ := row["id"].(string)
+				if vec, exists := vectors[id]; exists {
+					accepted[id] = vec
+				}
+			}
+			vectors = accepted
+		}
+
+		for _, c := range batch {
+			if vec, exists := vectors[c.id]; exists {
+				ret = append(ret, scoredBlock{id: c.id, score: cosineSimilarity(queryVec, vec)})
+			}
+		}
+	}
+
+	slices.SortStableFunc(ret, func(a, b scoredBlock) int {
+		if a.score > b.score {
+			return -1
+		}
+		if a.score < b.score {
+			return 1
+		}
+		return 0
+	})
+	return
+}
+
+func countFilteredEmbeddings(filter *embeddingSearchFilter) int {
+	q := "SELECT COUNT(*) AS c FROM block_embeddings be JOIN blocks b ON be.id = b.id WHERE length(be.embedding) > 0" + filter.clause
+	rows, err := sql.QueryNoLimitArgs(q, filter.args...)
+	if err != nil || 1 > len(rows) {
+		logging.LogErrorf("count filtered embeddings failed: %s", err)
+		return -1
+	}
+	c, _ := rows[0]["c"].(int64)
+	return int(c)
+}
+
+// indexEmbeddingANN 把刚写入表的块向量同步进索引。以表为准回读 rowid 与向量，保证与对账逻辑一致。
+func indexEmbeddingANN(ids []string) {
+	idx := embeddingANN.Load()
+	if nil == idx || 1 > len(ids) {
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := sql.QueryNoLimitArgs("SELECT rowid, id, embedding FROM block_embeddings WHERE id IN ("+placeholders+") AND length(embedding) > 0", args...)
+	if err != nil {
+		logging.LogErrorf("query embeddings for ann index failed: %s", err)
+		return
+	}
+	for _, row := range rows {
+		rowID, _ := row["rowid"].(int64)
+		id, _ := row["id"].(string)
+		embRaw, _ := row["embedding"].([]byte)
+		idx.upsert(id, rowID, decodeVector(embRaw))
+	}
+}
+
+// resetEmbeddingANN 清空索引（重建嵌入时调用）。空索引与清空后的表一致，仍可直接用于搜索。
+func resetEmbeddingANN() {
+	embeddingANN.Store(newHNSWIndex(embeddingModel()))
+	embeddingANNReady.Store(true)
+	if err := os.Remove(embeddingANNPath()); err != nil && !os.IsNotExist(err) {
+		logging.LogErrorf("remove embedding ann index failed: %s", err)
+	}
+}
+
+// syncEmbeddingANN 加载（首次）并以 block_embeddings 为准对账索引：补齐缺失或 rowid 变化的向量，
+// 给表中已不存在的块打墓碑，墓碑过多时压缩。同一时刻只运行一个。
+func syncEmbeddingANN() {
+	if !embeddingANNSyncing.CompareAndSwap(false, true) {
+		return
+	}
+	defer embeddingANNSyncing.Store(false)
+
+	idx := embeddingANN.Load()
+	if nil == idx {
+		idx = loadEmbeddingANN()
+		embeddingANN.Store(idx)
+	}
+	if idx.model != embeddingModel() {
+		logging.LogInfof("embedding model changed [%s -> %s], reset ann index", idx.model, embeddingModel())
+		idx = newHNSWIndex(embeddingModel())
+		embeddingANN.Store(idx)
+	}
+
+	start := time.Now()
+	if !reconcileEmbeddingANN(idx) {
+		return
+	}
+
+	if live, deleted, _ := idx.stat(); 0 < deleted && float64(deleted) > float64(live+deleted)*annCompactRatio {
+		compacted := idx.compact()
+		embeddingANN.Store(compacted)
+		// 压缩期间的增量写入落在旧索引上，再对账一次补到新索引
+		reconcileEmbeddingANN(compacted)
+		idx = compacted
+	}
+
+	embeddingANNReady.Store(true)
+	embeddingANNStatLock.Lock()
+	embeddingANNStat.syncedAt = time.Now().UnixMilli()
+	embeddingANNStatLock.Unlock()
+
+	live, deleted, _ := idx.stat()
+	logging.LogInfof("embedding ann index synced [nodes=%d, deleted=%d] in [%s]", live, deleted, time.Since(start))
+	saveEmbeddingANN(true)
+}
+
+func reconcileEmbeddingANN(idx *hnswIndex) bool {
+	rows, err := sql.QueryNoLimit("SELECT rowid, id FROM block_embeddings WHERE length(embedding) > 0")
+	if err != nil {
+		logging.LogErrorf("query embeddings for ann reconcile failed: %s", err)
+		return false
+	}
+
+	tableRowIDs := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		rowID, _ := row["rowid"].(int64)
+		id, _ := row["id"].(string)
+		tableRowIDs[id] = rowID
+	}
+
+	for id, rowID := range idx.liveRowIDs() {
+		if tableRowID, exists := tableRowIDs[id]; !exists || tableRowID != rowID {
+			idx.remove(id)
+		}
+	}
+
+	indexed := idx.liveRowIDs()
+	var missing []any
+	for id, rowID := range tableRowIDs {
+		if _, exists := indexed[id]; !exists {
+			missing = append(missing, rowID)
+		}
+	}
+	slices.SortFunc(missing, func(a, b any) int { return cmp.Compare(a.(int64), b.(int64)) })
+
+	for start := 0; start < len(missing); start += annCandidateBatch {
+		if !isEmbeddingEnabled() {
+			return false
+		}
+
+		batch := missing[start:min(start+annCandidateBatch, len(missing))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		vecRows, qErr := sql.QueryNoLimitArgs("SELECT rowid, id, embedding FROM block_embeddings WHERE rowid IN ("+placeholders+") AND length(embedding) > 0", batch...)
+		if qErr != nil {
+			logging.LogErrorf("query embeddings for ann reconcile failed: %s", qErr)
+			return false
+		}
+		for _, row := range vecRows {
+			rowID, _ := row["rowid"].(int64)
+			id, _ := row["id"].(string)
+			embRaw, _ := row["embedding"].([]byte)
+			idx.upsert(id, rowID, decodeVector(embRaw))
+		}
+
+		// 首次构建几十万块耗时较长，定期落盘，中断后重启可以从检查点继续对账
+		saveEmbeddingANN(false)
+	}
+	return true
+}
+
+func embeddingANNPath() string {
+	return filepath.Join(util.TempDir, "embedding_ann.idx")
+}
+
+func loadEmbeddingANN() *hnswIndex {
+	p := embeddingANNPath()
+	f, err := os.Open(p)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.LogErrorf("open embedding ann index failed: %s", err)
+		}
+		return newHNSWIndex(embeddingModel())
+	}
+	defer f.Close()
+
+	idx, err := readHNSWIndex(bufio.NewReaderSize(f, 1024*1024))
+	if err != nil {
+		logging.LogWarnf("load embedding ann index [%s] failed, rebuild it: %s", p, err)
+		return newHNSWIndex(embeddingModel())
+	}
+	return idx
+}
+
+// saveEmbeddingANN 把索引写入磁盘。force 为假时仅在距上次保存超过 annSaveInterval 时写入。
+func saveEmbeddingANN(force bool) {
+	idx := embeddingANN.Load()
+	if nil == idx || !idx.isDirty() {
+		return
+	}
+
+	embeddingANNSaveLock.Lock()
+	defer embeddingANNSaveLock.Unlock()
+	if !force && time.Since(embeddingANNSavedAt) < annSaveInterval {
+		return
+	}
+
+	p := embeddingANNPath()
+	tmp := p + ".saving"
+	f, err := os.Create(tmp)
+	if err != nil {
+		logging.LogErrorf("create embedding ann index failed: %s", err)
+		return
+	}
+	w := bufio.NewWriterSize(f, 1024*1024)
+	if err = idx.writeTo(w); err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logging.LogErrorf("write embedding ann index failed: %s", err)
+		os.Remove(tmp)
+		idx.dirty.Store(true)
+		return
+	}
+	if err = os.Rename(tmp, p); err != nil {
+		logging.LogErrorf("rename embedding ann index failed: %s", err)
+		os.Remove(tmp)
+		idx.dirty.Store(true)
+		return
+	}
+	embeddingANNSavedAt = time.Now()
+}
+
+func normalizeVector(vec []float32) (ret []float32, ok bool) {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if 0 == norm {
+		return
+	}
+	norm = math.Sqrt(norm)
+	ret = make([]float32, len(vec))
+	for i, v := range vec {
+		ret[i] = float32(float64(v) / norm)
+	}
+	ok = true
+	return
+}
+
+// quantizeVector 归一化后按向量内最大分量做 int8 对称量化，返回量化值与反量化系数。
+func quantizeVector(vec []float32) (q []int8, scale float32, ok bool) {
+	normalized, ok := normalizeVector(vec)
+	if !ok {
+		return
+	}
+	var maxAbs float32
+	for _, v := range normalized {
+		maxAbs = max(maxAbs, float32(math.Abs(float64(v))))
+	}
+	scale = maxAbs / 127
+	q = make([]int8, len(normalized))
+	for i, v := range normalized {
+		q[i] = int8(math.Round(float64(v / scale)))
+	}
+	return
+}
+
+type hnswNode struct {
+	id      string
+	rowID   int64 // block_embeddings 中的 rowid，用于对账时识别重嵌
+	vec     []int8
+	scale   float32 // 反量化系数：原分量 ≈ vec[i] * scale
+	friends [][]uint32
+	deleted bool
+}
+
+func (n *hnswNode) dequantize() []float32 {
+	ret := make([]float32, len(n.vec))
+	for i, v := range n.vec {
+		ret[i] = float32(v) * n.scale
+	}
+	return ret
+}
+
+// hnswIndex HNSW 图。节点只追加不移除，删除打墓碑（仍参与图遍历以保持连通，但不出现在结果中）。
+type hnswIndex struct {
+	lock     sync.RWMutex
+	model    string
+	dim      int
+	nodes    []*hnswNode
+	ids      map[string]uint32 // 块 ID -> 存活节点下标
+	entry    int32             // 入口节点下标，-1 表示空图
+	maxLevel int
+	deleted  int
+	dirty    atomic.Bool // 写入落盘时只持读锁，用原子变量避免与其他读者竞争
+	rng      *rand.Rand
+}
+
+func newHNSWIndex(model string) *hnswIndex {
+	return &hnswIndex{
+		model: model,
+		ids:   map[string]uint32{},
+		entry: -1,
+		rng:   rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+}
+
+func (h *hnswIndex) stat() (live, deleted, dim int) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.ids), h.deleted, h.dim
+}
+
+func (h *hnswIndex) isDirty() bool {
+	return h.dirty.Load()
+}
+
+func (h *hnswIndex) liveRowIDs() map[string]int64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	ret := make(map[string]int64, len(h.ids))
+	for id, i := range h.ids {
+		ret[id] = h.nodes[i].rowID
+	}
+	return ret
+}
+
+func (h *hnswIndex) remove(id string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.removeLocked(id)
+}
+
+func (h *hnswIndex) removeLocked(id string) {
+	i, exists := h.ids[id]
+	if !exists {
+		return
+	}
+	h.nodes[i].deleted = true
+	delete(h.ids, id)
+	h.deleted++
+	h.dirty.Store(true)
+}
+
+// upsert 插入或替换块向量。rowid 未变说明向量未变，直接跳过。
+func (h *hnswIndex) upsert(id string, rowID int64, vec []float32) {
+	q, scale, ok := quantizeVector(vec)
+	if !ok {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if 0 == h.dim {
+		h.dim = len(q)
+	}
+	if len(q) != h.dim {
+		// 维度与索引不一致（修改了维度配置但未重建），此类向量交给精确扫描处理
+		return
+	}
+
+	if i, exists := h.ids[id]; exists {
+		if h.nodes[i].rowID == rowID {
+			return
+		}
+		h.removeLocked(id)
+	}
+	h.insertLocked(&hnswNode{id: id, rowID: rowID, vec: q, scale: scale})
+}
+
+func (h *hnswIndex) randomLevel() int {
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) / math.Log(annM)))
+	return min(level, annMaxLevel)
+}
+
+func maxFriends(level int) int {
+	if 0 == level {
+		return annM0
+	}
+	return annM
+}
+
+func (h *hnswIndex) insertLocked(node *hnswNode) {
+	level := h.randomLevel()
+	node.friends = make([][]uint32, level+1)
+	i := uint32(len(h.nodes))
+	h.nodes = append(h.nodes, node)
+	h.ids[node.id] = i
+	h.dirty.Store(true)
+
+	if 0 > h.entry {
+		h.entry = int32(i)
+		h.maxLevel = level
+		return
+	}
+
+	q := node.dequantize()
+	eps := []hnswCandidate{{idx: uint32(h.entry), dist: h.distTo(q, uint32(h.entry))}}
+	for l := h.maxLevel; l > level; l-- {
+		eps = h.searchLayer(q, eps, 1, l)[:1]
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		w := h.searchLayer(q, eps, annEfConstruction, l)
+		neighbors := h.selectNeighbors(w, maxFriends(l))
+		node.friends[l] = make([]uint32, 0, len(neighbors))
+		for _, n := range neighbors {
+			node.friends[l] = append(node.friends[l], n.idx)
+			h.link(n.idx, i, l)
+		}
+		eps = w
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entry = int32(i)
+	}
+}
+
+// link 给 from 在第 level 层加一条指向 to 的边，超出邻居上限时裁掉最远的一条。
+// 反向边裁剪不再跑启发式：每次插入会触发几十次裁剪，启发式的两两距离计算在高维向量上是构图的主要开销，
+// 新节点自身的邻居已经按启发式选取，图的方向多样性由此保证。
+func (h *hnswIndex) link(from, to uint32, level int) {
+	n := h.nodes[from]
+	n.friends[level] = append(n.friends[level], to)
+	if len(n.friends[level]) <= maxFriends(level) {
+		return
+	}
+
+	farthest, farthestDist := 0, float32(-1)
+	for i, f := range n.friends[level] {
+		if h.nodes[f].deleted {
+			farthest = i
+			break
+		}
+		if d := h.distBetween(from, f); d > farthestDist {
+			farthest, farthestDist = i, d
+		}
+	}
+	n.friends[level] = slices.Delete(n.friends[level], farthest, farthest+1)
+}
+
+// selectNeighbors 启发式选邻：候选按距离升序，只保留比已选邻居更接近查询点的候选，
+// 使边分散在不同方向上，对聚簇明显的嵌入向量召回更好；不足 m 个时再用被裁掉的候选补齐。
+func (h *hnswIndex) selectNeighbors(candidates []hnswCandidate, m int) []hnswCandidate {
+	selected := make([]hnswCandidate, 0, m)
+	var pruned []hnswCandidate
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		if h.nodes[c.idx].deleted {
+			continue
+		}
+		good := true
+		for _, s := range selected {
+			if h.distBetween(c.idx, s.idx) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c)
+		} else {
+			pruned = append(pruned, c)
+		}
+	}
+	for _, p := range pruned {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, p)
+	}
+	return selected
+}
+
+func (h *hnswIndex) distTo(q []float32, i uint32) float32 {
+	n := h.nodes[i]
+	v := n.vec[:len(q)]
+	var d0, d1, d2, d3 float32
+	j := 0
+	for ; j+4 <= len(q); j += 4 {
+		d0 += q[j] * float32(v[j])
+		d1 += q[j+1] * float32(v[j+1])
+		d2 += q[j+2] * float32(v[j+2])
+		d3 += q[j+3] * float32(v[j+3])
+	}
+	for ; j < len(q); j++ {
+		d0 += q[j] * float32(v[j])
+	}
+	return 1 - (d0+d1+d2+d3)*n.scale
+}
+
+func (h *hnswIndex) distBetween(a, b uint32) float32 {
+	na, nb := h.nodes[a], h.nodes[b]
+	va, vb := na.vec, nb.vec[:len(na.vec)]
+	var d0, d1, d2, d3 int32
+	j := 0
+	for ; j+4 <= len(va); j += 4 {
+		d0 += int32(va[j]) * int32(vb[j])
+		d1 += int32(va[j+1]) * int32(vb[j+1])
+		d2 += int32(va[j+2]) * int32(vb[j+2])
+		d3 += int32(va[j+3]) * int32(vb[j+3])
+	}
+	for ; j < len(va); j++ {
+		d0 += int32(va[j]) * int32(vb[j])
+	}
+	return 1 - float32(d0+d1+d2+d3)*na.scale*nb.scale
+}
+
+// searchLayer 在第 level 层从 eps 出发做贪心 best-first 搜索，返回按距离升序的至多 ef 个节点（含墓碑）。
+func (h *hnswIndex) searchLayer(q []float32, eps []hnswCandidate, ef, level int) []hnswCandidate {
+	visited := make(map[uint32]struct{}, ef*4)
+	candidates := &hnswNearHeap{}
+	results := &hnswFarHeap{}
+	for _, ep := range eps {
+		visited[ep.idx] = struct{}{}
+		heap.Push(candidates, ep)
+		heap.Push(results, ep)
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+
+	for 0 < candidates.Len() {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && c.dist > (*results)[0].dist {
+			break
+		}
+		node := h.nodes[c.idx]
+		if level >= len(node.friends) {
+			continue
+		}
+		for _, f := range node.friends[level] {
+			if _, seen := visited[f]; seen {
+				continue
+			}
+			visited[f] = struct{}{}
+			d := h.distTo(q, f)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, hnswCandidate{idx: f, dist: d})
+				heap.Push(results, hnswCandidate{idx: f, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	ret := make([]hnswCandidate, results.Len())
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return ret
+}
+
+// search 返回与归一化查询向量 q 最接近的至多 k 个存活节点，score 为量化向量上的近似余弦相似度。
+func (h *hnswIndex) search(q []float32, k, ef int) (ret []scoredBlock) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if 0 > h.entry || len(q) != h.dim {
+		return
+	}
+
+	eps := []hnswCandidate{{idx: uint32(h.entry), dist: h.distTo(q, uint32(h.entry))}}
+	for l := h.maxLevel; l > 0; l-- {
+		eps = h.searchLayer(q, eps, 1, l)[:1]
+	}
+	// 墓碑节点会占用候选队列，按墓碑比例放大 ef 保证存活结果够数
+	if 0 < h.deleted {
+		ef += ef * h.deleted / max(len(h.ids), 1)
+	}
+	for _, c := range h.searchLayer(q, eps, max(ef, k), 0) {
+		n := h.nodes[c.idx]
+		if n.deleted {
+			continue
+		}
+		ret = append(ret, scoredBlock{id: n.id, score: 1 - c.dist})
+		if len(ret) >= k {
+			break
+		}
+	}
+	return
+}
+
+// compact 用存活节点重新构图，丢弃墓碑。构图期间旧索引仍可读写，由调用方随后对账补齐差异。
+func (h *hnswIndex) compact() *hnswIndex {
+	h.lock.RLock()
+	live := make([]*hnswNode, 0, len(h.ids))
+	for _, n := range h.nodes {
+		if !n.deleted {
+			live = append(live, n)
+		}
+	}
+	model, dim := h.model, h.dim
+	h.lock.RUnlock()
+
+	ret := newHNSWIndex(model)
+	ret.dim = dim
+	for _, n := range live {
+		ret.insertLocked(&hnswNode{id: n.id, rowID: n.rowID, vec: n.vec, scale: n.scale})
+	}
+	return ret
+}
+
+func (h *hnswIndex) writeTo(w io.Writer) (err error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	bw := &binaryWriter{w: w}
+	bw.u32(annIndexMagic)
+	bw.u32(annIndexVersion)
+	bw.str(h.model)
+	bw.u32(uint32(h.dim))
+	bw.u32(uint32(h.entry))
+	bw.u32(uint32(h.maxLevel))
+	bw.u32(uint32(len(h.nodes)))
+	for _, n := range h.nodes {
+		bw.str(n.id)
+		bw.u64(uint64(n.rowID))
+		if n.deleted {
+			bw.u8(1)
+		} else {
+			bw.u8(0)
+		}
+		bw.u32(math.Float32bits(n.scale))
+		bw.bytes(unsafeInt8Bytes(n.vec))
+		bw.u8(uint8(len(n.friends)))
+		for _, friends := range n.friends {
+			bw.u32(uint32(len(friends)))
+			for _, f := range friends {
+				bw.u32(f)
+			}
+		}
+	}
+	if nil == bw.err {
+		h.dirty.Store(false)
+	}
+	return bw.err
+}
+
+func readHNSWIndex(r io.Reader) (ret *hnswIndex, err error) {
+	br := &binaryReader{r: r}
+	if annIndexMagic != br.u32() || annIndexVersion != br.u32() {
+		if nil != br.err {
+			return nil, br.err
+		}
+		return nil, errors.New("unsupported index version")
+	}
+
+	ret = newHNSWIndex(br.str())
+	ret.dim = int(br.u32())
+	ret.entry = int32(br.u32())
+	ret.maxLevel = int(br.u32())
+	count := br.u32()
+	if nil != br.err {
+		return nil, br.err
+	}
+
+	ret.nodes = make([]*hnswNode, 0, count)
+	for i := uint32(0); i < count && nil == br.err; i++ {
+		n := &hnswNode{id: br.str(), rowID: int64(br.u64())}
+		n.deleted = 1 == br.u8()
+		n.scale = math.Float32frombits(br.u32())
+		n.vec = make([]int8, ret.dim)
+		br.read(unsafeInt8Bytes(n.vec))
+		n.friends = make([][]uint32, br.u8())
+		for l := range n.friends {
+			friendCount := br.u32()
+			if friendCount > annM0+1 {
+				return nil, errors.New("corrupted index")
+			}
+			n.friends[l] = make([]uint32, friendCount)
+			for j := range n.friends[l] {
+				n.friends[l][j] = br.u32()
+			}
+		}
+		ret.nodes = append(ret.nodes, n)
+		if n.deleted {
+			ret.deleted++
+		} else {
+			ret.ids[n.id] = i
+		}
+	}
+	if nil != br.err {
+		return nil, br.err
+	}
+	if ret.entry >= int32(len(ret.nodes)) {
+		return nil, errors.New("corrupted index")
+	}
+	for _, n := range ret.nodes {
+		for _, friends := range n.friends {
+			for _, f := range friends {
+				if f >= uint32(len(ret.nodes)) {
+					return nil, errors.New("corrupted index")
+				}
+			}
+		}
+	}
+	return
+}
+
+type hnswCandidate struct {
+	idx  uint32
+	dist float32
+}
+
+// hnswNearHeap 距离最小者在堆顶，用作待扩展候选队列。
+type hnswNearHeap []hnswCandidate
+
+func (h hnswNearHeap) Len() int           { return len(h) }
+func (h hnswNearHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h hnswNearHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswNearHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswNearHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// hnswFarHeap 距离最大者在堆顶，用作定长结果集。
+type hnswFarHeap []hnswCandidate
+
+func (h hnswFarHeap) Len() int           { return len(h) }
+func (h hnswFarHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h hnswFarHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswFarHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswFarHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type binaryWriter struct {
+	w   io.Writer
+	buf [8]byte
+	err error
+}
+
+func (bw *binaryWriter) bytes(b []byte) {
+	if nil == bw.err {
+		_, bw.err = bw.w.Write(b)
+	}
+}
+
+func (bw *binaryWriter) u8(v uint8) {
+	bw.buf[0] = v
+	bw.bytes(bw.buf[:1])
+}
+
+func (bw *binaryWriter) u32(v uint32) {
+	binary.LittleEndian.PutUint32(bw.buf[:4], v)
+	bw.bytes(bw.buf[:4])
+}
+
+func (bw *binaryWriter) u64(v uint64) {
+	binary.LittleEndian.PutUint64(bw.buf[:8], v)
+	bw.bytes(bw.buf[:8])
+}
+
+func (bw *binaryWriter) str(s string) {
+	bw.u32(uint32(len(s)))
+	bw.bytes([]byte(s))
+}
+
+type binaryReader struct {
+	r   io.Reader
+	buf [8]byte
+	err error
+}
+
+func (br *binaryReader) read(b []byte) {
+	if nil == br.err {
+		_, br.err = io.ReadFull(br.r, b)
+	}
+}
+
+func (br *binaryReader) u8() uint8 {
+	br.read(br.buf[:1])
+	return br.buf[0]
+}
+
+func (br *binaryReader) u32() uint32 {
+	br.read(br.buf[:4])
+	return binary.LittleEndian.Uint32(br.buf[:4])
+}
+
+func (br *binaryReader) u64() uint64 {
+	br.read(br.buf[:8])
+	return binary.LittleEndian.Uint64(br.buf[:8])
+}
+
+func (br *binaryReader) str() string {
+	l := br.u32()
+	if nil != br.err || l > 4096 {
+		if nil == br.err {
+			br.err = errors.New("corrupted string length")
+		}
+		return ""
+	}
+	b := make([]byte, l)
+	br.read(b)
+	return string(b)
+}
+
+func unsafeInt8Bytes(v []int8) []byte {
+	if 0 == len(v) {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&v[0])), len(v))
+}
@@ -0,0 +1,163 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func randomTestVectors(n, dim int, seed uint64) (ids []string, vectors [][]float32) {
+	r := rand.New(rand.NewPCG(seed, seed))
+	for i := range n {
+		vec := make([]float32, dim)
+		for j := range vec {
+			vec[j] = float32(r.NormFloat64())
+		}
+		ids = append(ids, fmt.Sprintf("20260101000000-%07d", i))
+		vectors = append(vectors, vec)
+	}
+	return
+}
+
+func exactTopK(query []float32, ids []string, vectors [][]float32, k int) []string {
+	scored := make([]scoredBlock, 0, len(ids))
+	for i, vec := range vectors {
+		scored = append(scored, scoredBlock{id: ids[i], score: cosineSimilarity(query, vec)})
+	}
+	slices.SortFunc(scored, func(a, b scoredBlock) int {
+		if a.score > b.score {
+			return -1
+		}
+		if a.score < b.score {
+			return 1
+		}
+		return 0
+	})
+	var ret []string
+	for _, s := range scored[:k] {
+		ret = append(ret, s.id)
+	}
+	return ret
+}
+
+func TestHNSWIndexRecall(t *testing.T) {
+	ids, vectors := randomTestVectors(3000, 32, 1)
+	idx := newHNSWIndex("test")
+	for i, id := range ids {
+		idx.upsert(id, int64(i+1), vectors[i])
+	}
+
+	_, queries := randomTestVectors(50, 32, 2)
+	k := 10
+	hit, total := 0, 0
+	for _, query := range queries {
+		q, _ := normalizeVector(query)
+		got := map[string]bool{}
+		for _, s := range idx.search(q, k, annEfSearch) {
+			got[s.id] = true
+		}
+		for _, id := range exactTopK(query, ids, vectors, k) {
+			if got[id] {
+				hit++
+			}
+			total++
+		}
+	}
+	if recall := float64(hit) / float64(total); recall < 0.9 {
+		t.Fatalf("HNSW 召回率过低：%.3f", recall)
+	}
+}
+
+func TestHNSWIndexUpsertAndRemove(t *testing.T) {
+	ids, vectors := randomTestVectors(200, 16, 3)
+	idx := newHNSWIndex("test")
+	for i, id := range ids {
+		idx.upsert(id, int64(i+1), vectors[i])
+	}
+
+	// 相同 rowid 视为未变化，不产生墓碑
+	idx.upsert(ids[0], 1, vectors[1])
+	if live, deleted, _ := idx.stat(); 200 != live || 0 != deleted {
+		t.Fatalf("相同 rowid 不应替换节点：live=%d deleted=%d", live, deleted)
+	}
+
+	// rowid 变化视为重嵌：旧节点打墓碑，新向量可被检索到
+	idx.upsert(ids[0], 1000, vectors[1])
+	if live, deleted, _ := idx.stat(); 200 != live || 1 != deleted {
+		t.Fatalf("重嵌后应有 1 个墓碑：live=%d deleted=%d", live, deleted)
+	}
+
+	idx.remove(ids[2])
+	q, _ := normalizeVector(vectors[2])
+	for _, s := range idx.search(q, 10, annEfSearch) {
+		if s.id == ids[2] {
+			t.Fatalf("已删除的块不应出现在结果中")
+		}
+	}
+
+	compacted := idx.compact()
+	if live, deleted, _ := compacted.stat(); 199 != live || 0 != deleted {
+		t.Fatalf("压缩后应丢弃墓碑：live=%d deleted=%d", live, deleted)
+	}
+	if rowIDs := compacted.liveRowIDs(); 1000 != rowIDs[ids[0]] {
+		t.Fatalf("压缩后应保留最新 rowid：%d", rowIDs[ids[0]])
+	}
+}
+
+func TestHNSWIndexPersistence(t *testing.T) {
+	ids, vectors := randomTestVectors(300, 24, 4)
+	idx := newHNSWIndex("test-model")
+	for i, id := range ids {
+		idx.upsert(id, int64(i+1), vectors[i])
+	}
+	idx.remove(ids[5])
+
+	buf := &bytes.Buffer{}
+	if err := idx.writeTo(buf); err != nil {
+		t.Fatalf("write index failed: %s", err)
+	}
+	if idx.isDirty() {
+		t.Fatalf("落盘后索引不应再标记为脏")
+	}
+
+	loaded, err := readHNSWIndex(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("read index failed: %s", err)
+	}
+	if "test-model" != loaded.model {
+		t.Fatalf("模型名未正确恢复：%s", loaded.model)
+	}
+	live, deleted, dim := loaded.stat()
+	if 299 != live || 1 != deleted || 24 != dim {
+		t.Fatalf("索引统计未正确恢复：live=%d deleted=%d dim=%d", live, deleted, dim)
+	}
+
+	q, _ := normalizeVector(vectors[10])
+	before := idx.search(q, 5, annEfSearch)
+	after := loaded.search(q, 5, annEfSearch)
+	if !slices.Equal(before, after) {
+		t.Fatalf("加载后的检索结果与原索引不一致：%v != %v", before, after)
+	}
+
+	if _, err = readHNSWIndex(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Fatalf("截断的索引文件应读取失败")
+	}
+}
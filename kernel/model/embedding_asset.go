@@ -0,0 +1,421 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"container/heap"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 资源文件内容嵌入：资源文件内容索引（AssetsSearcher）中的每个分块再切分为不超过 assetEmbeddingSegmentLen 个字符的段，
+// 逐段嵌入后存入主库 asset_embeddings 表。索引器每轮比对资源文件内容索引与嵌入表中各文件的修改时间，
+// 新增或修改的文件整体重新嵌入，已删除的文件清理向量。加密笔记本的资源文件不参与嵌入。
+const (
+	assetEmbeddingSegmentLen    = 1024 // 每段最多字符数
+	assetEmbeddingSnippetLen    = 160  // 搜索结果列表中展示的段落字符数
+	assetEmbeddingFilesPerRound = 32   // 每轮最多嵌入的文件数，避免长时间占用嵌入服务而推迟块嵌入
+)
+
+var (
+	// 嵌入服务出错时资源文件嵌入整体退避，失败次数复用块嵌入的退避参数
+	assetEmbeddingFailCount atomic.Int64
+	assetEmbeddingRetryAt   atomic.Int64
+)
+
+// processPendingAssetEmbeddings 嵌入新增或修改过的资源文件内容，并清理已删除资源文件的向量。
+func processPendingAssetEmbeddings() {
+	if !isEmbeddingEnabled() || embeddingStop.Load() {
+		return
+	}
+	if time.Now().Unix() < assetEmbeddingRetryAt.Load() {
+		return
+	}
+
+	indexed, err := queryAssetContentUpdated()
+	if err != nil {
+		logging.LogErrorf("query asset content for embedding failed: %s", err)
+		return
+	}
+	embedded, err := queryAssetEmbeddingUpdated()
+	if err != nil {
+		logging.LogErrorf("query asset embeddings failed: %s", err)
+		return
+	}
+
+	for p := range embedded {
+		// 资源文件内容索引重建期间 indexed 不完整，只按文件是否存在清理，避免重建索引后整体重新嵌入
+		if _, ok := indexed[p]; !ok && !gulu.File.IsExist(filepath.Join(util.DataDir, p)) {
+			if err = sql.Exec("DELETE FROM asset_embeddings WHERE path = ?", p); err != nil {
+				logging.LogErrorf("remove asset embeddings of [%s] failed: %s", p, err)
+			}
+		}
+	}
+
+	var pending []string
+	for p, updated := range indexed {
+		if embeddedUpdated, ok := embedded[p]; !ok || embeddedUpdated != updated {
+			pending = append(pending, p)
+		}
+	}
+	sort.Strings(pending)
+
+	for i, p := range pending {
+		if i >= assetEmbeddingFilesPerRound || embeddingStop.Load() || util.IsExiting.Load() {
+			return
+		}
+		if !embedAsset(p, indexed[p]) {
+			return
+		}
+	}
+}
+
+// queryAssetContentUpdated 返回资源文件内容索引中各文件路径对应的修改时间。
+func queryAssetContentUpdated() (ret map[string]int64, err error) {
+	ret = map[string]int64{}
+	rows, err := sql.QueryAssetContentNoLimitArgs("SELECT path, MAX(updated) AS updated FROM asset_contents_fts_case_insensitive GROUP BY path")
+	if err != nil {
+		return
+	}
+	for _, row := range rows {
+		p, _ := row["path"].(string)
+		updated, _ := row["updated"].(int64)
+		ret[p] = updated
+	}
+	return
+}
+
+// queryAssetEmbeddingUpdated 返回嵌入表中各文件路径对应的修改时间。
+func queryAssetEmbeddingUpdated() (ret map[string]int64, err error) {
+	ret = map[string]int64{}
+	rows, err := sql.QueryNoLimit("SELECT path, MAX(updated) AS updated FROM asset_embeddings GROUP BY path")
+	if err != nil {
+		return
+	}
+	for _, row := range rows {
+		p, _ := row["path"].(string)
+		updated, _ := row["updated"].(int64)
+		ret[p] = updated
+	}
+	return
+}
+
+type assetEmbeddingSegment struct {
+	ext     string
+	locator string
+	chunk   int
+	content string
+}
+
+// embedAsset 重新嵌入一个资源文件的全部内容，返回 false 表示嵌入服务出错，本轮应停止。
+func embedAsset(p string, updated int64) bool {
+	// 加密笔记本的资源文件不参与嵌入；被 .siyuan/embeddingignore 匹配的资源文件只写占位行
+	if IsEncryptedAssetPath(filepath.Join(util.DataDir, p)) {
+		sql.Exec("DELETE FROM asset_embeddings WHERE path = ?", p)
+		return true
+	}
+	if matcher := getEmbeddingIgnoreMatcher(); nil != matcher && matcher.MatchesPath("/"+p) {
+		replaceAssetEmbeddings(p, updated, nil, nil, embeddingIgnoredByConf)
+		return true
+	}
+
+	stmt := "SELECT id, name, ext, path, size, updated, content, locator FROM asset_contents_fts_case_insensitive WHERE path = ? ORDER BY rowid ASC"
+	assetContents := sql.SelectAssetContentsRawStmtNoParseArgs(stmt, []any{p}, PDFAssetContentMaxPage*16)
+	var segments []*assetEmbeddingSegment
+	for _, assetContent := range assetContents {
+		for i, content := range splitAssetEmbeddingText(assetContent.Content, assetEmbeddingSegmentLen) {
+			if len(content) < embeddingMinTextLen {
+				continue
+			}
+			segments = append(segments, &assetEmbeddingSegment{ext: assetContent.Ext, locator: assetContent.Locator, chunk: i, content: content})
+		}
+	}
+	if 1 > len(segments) {
+		replaceAssetEmbeddings(p, updated, nil, nil, embeddingIgnoredByLen)
+		return true
+	}
+
+	var vectors [][]float32
+	for start := 0; start < len(segments); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(segments))
+		var texts []string
+		for _, segment := range segments[start:end] {
+			texts = append(texts, segment.content)
+		}
+
+		batch, err := getEmbeddings(texts)
+		if err == nil && len(batch) != len(texts) {
+			err = fmt.Errorf("count mismatch: requested %d but got %d", len(texts), len(batch))
+		}
+		if err != nil {
+			failCount := assetEmbeddingFailCount.Add(1)
+			assetEmbeddingRetryAt.Store(time.Now().Add(embeddingBackoffFor(int(failCount))).Unix())
+			logging.LogErrorf("create embeddings for asset [%s] failed (%s), retry later", p, err)
+			return false
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	assetEmbeddingFailCount.Store(0)
+	replaceAssetEmbeddings(p, updated, segments, vectors, embeddingIgnoredNone)
+	return true
+}
+
+// replaceAssetEmbeddings 替换资源文件的全部向量。没有可嵌入的内容时写入一条空向量的占位行，记录已处理的修改时间。
+func replaceAssetEmbeddings(p string, updated int64, segments []*assetEmbeddingSegment, vectors [][]float32, ignoredType int) {
+	if err := sql.Exec("DELETE FROM asset_embeddings WHERE path = ?", p); err != nil {
+		logging.LogErrorf("remove asset embeddings of [%s] failed: %s", p, err)
+		return
+	}
+
+	if 1 > len(segments) {
+		sql.Exec("INSERT INTO asset_embeddings (id, path, ext, locator, chunk, content, embedding, model, updated, ignored_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			ast.NewNodeID(), p, strings.ToLower(filepath.Ext(p)), "", 0, "", []byte{}, embeddingModel(), updated, ignoredType)
+		return
+	}
+
+	for i, segment := range segments {
+		if err := sql.Exec("INSERT INTO asset_embeddings (id, path, ext, locator, chunk, content, embedding, model, updated, ignored_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			ast.NewNodeID(), p, strings.ToLower(segment.ext), segment.locator, segment.chunk, segment.content, encodeVector(vectors[i]), embeddingModel(), updated, ignoredType); err != nil {
+			logging.LogErrorf("store embedding for asset [%s] failed: %s", p, err)
+		}
+	}
+}
+
+// splitAssetEmbeddingText 将文本切分为不超过 maxLen 个字符的段，尽量在空白或标点处断开。
+func splitAssetEmbeddingText(text string, maxLen int) (ret []string) {
+	runes := []rune(strings.TrimSpace(text))
+	for 0 < len(runes) {
+		if len(runes) <= maxLen {
+			ret = append(ret, string(runes))
+			break
+		}
+
+		cut := maxLen
+		for i := maxLen; i > maxLen*4/5; i-- {
+			if unicode.IsSpace(runes[i]) || unicode.IsPunct(runes[i-1]) {
+				cut = i
+				break
+			}
+		}
+		if segment := strings.TrimSpace(string(runes[:cut])); "" != segment {
+			ret = append(ret, segment)
+		}
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	return
+}
+
+// SemanticSearchAssetContent 按语义搜索资源文件内容，结果为命中的资源文件内容分块，按相似度降序排列。
+//
+// types 为扩展名过滤，与 FullTextSearchAssetContent 相同。返回结果的 ID 为资源文件内容索引记录 ID，可用于 GetAssetContent 预览，
+// Content 为命中段落的文本。
+func SemanticSearchAssetContent(query string, types map[string]bool, page, pageSize int) (ret []*AssetContent, matchedAssetCount, pageCount int) {
+	ret = []*AssetContent{}
+
+	query = strings.TrimSpace(query)
+	if !embeddingTableOk || !isEmbeddingEnabled() || "" == query {
+		return
+	}
+
+	vectors, err := getEmbeddings([]string{query})
+	if err != nil || 1 > len(vectors) {
+		logging.LogErrorf("get query embedding failed")
+		return
+	}
+
+	topK := page * pageSize
+	if isRerankEnabled() {
+		topK = max(topK, rerankCandidateCount())
+	}
+
+	typeFilter, typeArgs := buildAssetContentTypeFilter(types)
+	scored := bruteForceSearchAssetEmbeddings(vectors[0], typeFilter, typeArgs, topK)
+	matchedAssetCount = len(scored)
+	if 1 > matchedAssetCount {
+		return
+	}
+	pageCount = (matchedAssetCount + pageSize - 1) / pageSize
+
+	var ids []string
+	for _, s := range scored {
+		ids = append(ids, s.id)
+	}
+	segments := getAssetEmbeddingSegments(ids)
+	segments = rerankAssetEmbeddingSegments(query, segments)
+
+	offset := (page - 1) * pageSize
+	if offset >= len(segments) {
+		return
+	}
+	end := min(offset+pageSize, len(segments))
+	for _, segment := range segments[offset:end] {
+		if assetContent := toSemanticAssetContent(segment); nil != assetContent {
+			ret = append(ret, assetContent)
+		}
+	}
+	return
+}
+
+type assetEmbeddingHit struct {
+	path    string
+	locator string
+	content string
+}
+
+// getAssetEmbeddingSegments 按 ids 的顺序返回命中的段落。
+func getAssetEmbeddingSegments(ids []string) (ret []*assetEmbeddingHit) {
+	if 1 > len(ids) {
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := sql.QueryNoLimitArgs("SELECT id, path, locator, content FROM asset_embeddings WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		logging.LogErrorf("query asset embedding segments failed: %s", err)
+		return
+	}
+
+	hits := map[string]*assetEmbeddingHit{}
+	for _, row := range rows {
+		id, _ := row["id"].(string)
+		p, _ := row["path"].(string)
+		locator, _ := row["locator"].(string)
+		content, _ := row["content"].(string)
+		hits[id] = &assetEmbeddingHit{path: p, locator: locator, content: content}
+	}
+	for _, id := range ids {
+		if hit := hits[id]; nil != hit {
+			ret = append(ret, hit)
+		}
+	}
+	return
+}
+
+// rerankAssetEmbeddingSegments 用重排模型对命中段落精排，未启用或调用失败时保留向量相似度顺序。
+func rerankAssetEmbeddingSegments(query string, hits []*assetEmbeddingHit) []*assetEmbeddingHit {
+	if !isRerankEnabled() || len(hits) < 2 {
+		return hits
+	}
+
+	documents := make([]string, len(hits))
+	for i, hit := range hits {
+		documents[i] = hit.content
+	}
+	indices, err := rerank(query, documents)
+	if nil != err || len(indices) != len(hits) {
+		logging.LogErrorf("rerank asset contents failed, fallback to vector similarity order: %v", err)
+		return hits
+	}
+
+	seen := make(map[int]bool, len(indices))
+	reranked := make([]*assetEmbeddingHit, 0, len(indices))
+	for _, idx := range indices {
+		if seen[idx] || 0 > idx || idx >= len(hits) {
+			return hits
+		}
+		seen[idx] = true
+		reranked = append(reranked, hits[idx])
+	}
+	return reranked
+}
+
+// toSemanticAssetContent 将命中段落关联到资源文件内容索引中对应的分块。
+func toSemanticAssetContent(hit *assetEmbeddingHit) *AssetContent {
+	if IsEncryptedAssetPath(filepath.Join(util.DataDir, hit.path)) {
+		return nil
+	}
+
+	stmt := "SELECT id, name, ext, path, size, updated, content, locator FROM asset_contents_fts_case_insensitive WHERE path = ? AND locator = ? LIMIT 1"
+	assetContents := sql.SelectAssetContentsRawStmtNoParseArgs(stmt, []any{hit.path, hit.locator}, 1)
+	if 1 > len(assetContents) {
+		// 资源文件内容索引已变化，待下一轮嵌入时清理
+		return nil
+	}
+
+	assetContent := assetContents[0]
+	snippet := []rune(hit.content)
+	if len(snippet) > assetEmbeddingSnippetLen {
+		snippet = append(snippet[:assetEmbeddingSnippetLen], []rune("...")...)
+	}
+	assetContent.Content = string(snippet)
+	return fromSQLAssetContent(assetContent)
+}
+
+// bruteForceSearchAssetEmbeddings 扫描资源文件内容嵌入表，返回按相似度降序的 topK 个段落。
+// 资源文件段落数量远少于块，不建立 ANN 索引。
+func bruteForceSearchAssetEmbeddings(queryVec []float32, typeFilter string, typeArgs []any, topK int) []scoredBlock {
+	h := &scoredHeap{}
+	heap.Init(h)
+
+	scanSize := 4096
+	cursor := int64(0)
+	for {
+		q := fmt.Sprintf("SELECT rowid, id, embedding FROM asset_embeddings WHERE length(embedding) > 0 AND rowid > %d", cursor)
+		q += typeFilter
+		q += fmt.Sprintf(" ORDER BY rowid LIMIT %d", scanSize)
+		rows, err := sql.QueryNoLimitArgs(q, typeArgs...)
+		if err != nil {
+			logging.LogErrorf("query asset embeddings for search failed: %s", err)
+			break
+		}
+		if 1 > len(rows) {
+			break
+		}
+
+		for _, row := range rows {
+			if rowID, _ := row["rowid"].(int64); rowID > cursor {
+				cursor = rowID
+			}
+			embRaw, _ := row["embedding"].([]byte)
+			if len(embRaw) == 0 {
+				continue
+			}
+			buf := make([]byte, len(embRaw))
+			copy(buf, embRaw)
+			id, _ := row["id"].(string)
+			s := scoredBlock{id: id, score: cosineSimilarity(queryVec, decodeVector(buf))}
+			if h.Len() < topK {
+				heap.Push(h, s)
+			} else if s.score > (*h)[0].score {
+				heap.Pop(h)
+				heap.Push(h, s)
+			}
+		}
+	}
+
+	result := make([]scoredBlock, h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(h).(scoredBlock)
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitAssetEmbeddingText(t *testing.T) {
+	if ret := splitAssetEmbeddingText("  短文本  ", 16); 1 != len(ret) || "短文本" != ret[0] {
+		t.Fatalf("短文本不应被切分：%q", ret)
+	}
+	if ret := splitAssetEmbeddingText(" \n ", 16); 0 != len(ret) {
+		t.Fatalf("空白文本不应产生分段：%q", ret)
+	}
+
+	text := strings.Repeat("word ", 100)
+	ret := splitAssetEmbeddingText(text, 32)
+	if 2 > len(ret) {
+		t.Fatalf("长文本应被切分为多个分段：%q", ret)
+	}
+	for _, segment := range ret {
+		if 32 < utf8.RuneCountInString(segment) {
+			t.Fatalf("分段长度超过上限：%q", segment)
+		}
+		if strings.HasSuffix(segment, "wor") || strings.HasPrefix(segment, "d") {
+			t.Fatalf("分段应在空白处切分：%q", segment)
+		}
+	}
+	if strings.Join(strings.Fields(strings.Join(ret, " ")), " ") != strings.TrimSpace(text) {
+		t.Fatalf("分段拼接后应与原文一致")
+	}
+
+	ret = splitAssetEmbeddingText(strings.Repeat("中", 50), 20)
+	if 3 != len(ret) || 20 != utf8.RuneCountInString(ret[0]) || 10 != utf8.RuneCountInString(ret[2]) {
+		t.Fatalf("无分隔符时应按长度上限切分：%q", ret)
+	}
+}
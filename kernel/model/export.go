@@ -3600,6 +3600,11 @@ func exportTree(tree *parse.Tree, wysiwyg, keepFold, avHiddenCol bool,
 							}
 						}
 						continue
+					} else if av.KeyTypeFormula == cell.Value.Type {
+						val = cell.Value.String(true)
+						val = strings.ReplaceAll(val, "\\|", "|")
+						val = strings.ReplaceAll(val, "|", "\\|")
+						val = strings.ReplaceAll(val, "\n", " ")
 					}
 
 					if "" == val {
@@ -4342,6 +4347,16 @@ func getAttrViewTable(attrView *av.AttributeView, view *av.View, query string) (
 		for _, field := range view.Kanban.Fields {
 			view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
 		}
+	case av.LayoutTypeCalendar:
+		view.Table = av.NewLayoutTable()
+		for _, field := range view.Calendar.Fields {
+			view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
+		}
+	case av.LayoutTypeTimeline:
+		view.Table = av.NewLayoutTable()
+		for _, field := range view.Timeline.Fields {
+			view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
+		}
 	}
 
 	depth := 1
@@ -38,6 +38,7 @@ import (
 	"github.com/88250/lute/html"
 	"github.com/88250/lute/parse"
 	util2 "github.com/88250/lute/util"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/riff"
@@ -1220,6 +1221,7 @@ func DuplicateDoc(tree *parse.Tree) {
 	}
 
 	FlushTxQueue()
+	eventbus.Publish(util.EvtDocCreated, tree.Box, tree.Path, tree.ID)
 	arg := map[string]any{}
 	arg["listDocTree"] = true
 	PushCreate(box, tree.Path, arg)
@@ -1380,7 +1382,7 @@ func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 			logging.LogWarnf("not found daily note template [%s]", tplPath)
 		} else {
 			var renderErr error
-			templateTree, templateDom, renderErr = RenderTemplate(tplPath, id, false)
+			templateTree, templateDom, renderErr = RenderTemplate(tplPath, id, nil, false)
 			if nil != renderErr {
 				logging.LogWarnf("render daily note template [%s] failed: %s", boxConf.DailyNoteTemplatePath, err)
 			}
@@ -1432,6 +1434,7 @@ func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 		return
 	}
 
+	eventbus.Publish(util.EvtDailyNoteCreated, box.ID, p, tree.ID)
 	return
 }
 
@@ -1780,6 +1783,7 @@ func moveDoc(fromBox *Box, fromPath string, toBox *Box, toPath string, luteEngin
 	}
 	evt.Callback = callback
 	util.PushEvent(evt)
+	eventbus.Publish(util.EvtDocMoved, fromBox.ID, fromPath, toBox.ID, newPath, tree.ID)
 
 	refreshDocInfo(fromParentTree)
 	fromRoot := path.Dir(fromPath) == "/"
@@ -1941,6 +1945,7 @@ func removeDoc(box *Box, p string, luteEngine *lute.Lute) (ret *parse.Tree, err
 		"ids": removeIDs,
 	}
 	util.PushEvent(evt)
+	eventbus.Publish(util.EvtDocRemoved, box.ID, p, ret.ID, ret.HPath, removeIDs)
 	task.AppendTask(task.DatabaseIndex, removeDoc0, ret, childrenDir)
 	return
 }
@@ -2054,6 +2059,7 @@ func renameDoc0(boxID, p, title string) (err error) {
 	}
 	if titleChanged {
 		updateRefTextRenameDoc(tree)
+		eventbus.Publish(util.EvtDocRenamed, boxID, p, tree.ID, title)
 	}
 	if titleChanged || emptyAttrUpdated {
 		IncSync()
@@ -2178,6 +2184,7 @@ func createDoc(boxID, p, title, dom string, titleEmpty bool) (tree *parse.Tree,
 	transaction := &Transaction{DoOperations: []*Operation{{Action: "create", Data: tree}}}
 	PerformTransactions(&[]*Transaction{transaction})
 	FlushTxQueue()
+	eventbus.Publish(util.EvtDocCreated, boxID, p, id)
 	return
 }
 
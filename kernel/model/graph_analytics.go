@@ -0,0 +1,613 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"math"
+	"sort"
+
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	GraphAnalysisNodeDoc = "NodeDocument"
+	GraphAnalysisNodeTag = "tag"
+
+	GraphCentralityPageRank = "pagerank"
+	GraphCentralityDegree   = "degree"
+)
+
+const (
+	graphPageRankDamping    = 0.85
+	graphPageRankIterations = 100
+	graphPageRankTolerance  = 1e-9
+
+	graphCommunityIterations = 100
+
+	// graphBetweennessPivots 为计算桥接度时的采样源点数，节点数超过该值时按等间隔采样估算，避免大图上的平方级计算量。
+	graphBetweennessPivots = 512
+)
+
+// GraphAnalysisOptions 描述关系图分析的范围。
+type GraphAnalysisOptions struct {
+	Box string // 限定笔记本，为空时分析所有笔记本
+	Tag bool   // 是否将标签作为节点参与分析，开启后共享同一标签的文档之间视为连通
+
+	// Accessible 用于过滤不可见的文档，为空时不过滤。
+	Accessible func(box, p string) bool
+}
+
+type GraphAnalysisNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+	Box   string `json:"box,omitempty"`
+	Path  string `json:"path,omitempty"`
+	HPath string `json:"hPath,omitempty"`
+}
+
+type GraphRankedNode struct {
+	*GraphAnalysisNode
+	Score     float64 `json:"score"`
+	InDegree  int     `json:"inDegree"`  // 被其他文档引用的文档数
+	OutDegree int     `json:"outDegree"` // 引用的其他文档数
+}
+
+type GraphCommunity struct {
+	ID    int                  `json:"id"`
+	Size  int                  `json:"size"`
+	Nodes []*GraphAnalysisNode `json:"nodes"`
+}
+
+type GraphPath struct {
+	From  string               `json:"from"`
+	To    string               `json:"to"`
+	Found bool                 `json:"found"`
+	Hops  int                  `json:"hops"`
+	Nodes []*GraphAnalysisNode `json:"nodes"`
+}
+
+// GraphShortestPath 计算两个块所在文档之间在引用（和标签）关系上的最短路径，引用方向不影响连通性。
+func GraphShortestPath(fromID, toID string, opts *GraphAnalysisOptions) (ret *GraphPath, err error) {
+	g := loadAnalyticsGraph(opts)
+	from, err := g.resolve(fromID)
+	if nil != err {
+		return
+	}
+	to, err := g.resolve(toID)
+	if nil != err {
+		return
+	}
+
+	ret = &GraphPath{From: fromID, To: toID, Nodes: []*GraphAnalysisNode{}}
+	path := g.shortestPath(from, to)
+	if nil == path {
+		return
+	}
+
+	ret.Found = true
+	ret.Hops = len(path) - 1
+	for _, i := range path {
+		ret.Nodes = append(ret.Nodes, g.nodes[i])
+	}
+	return
+}
+
+// GraphCentrality 按中心度对文档排序，metric 为 pagerank 或 degree，limit 不大于 0 时返回全部文档。
+func GraphCentrality(metric string, limit int, opts *GraphAnalysisOptions) (ret []*GraphRankedNode) {
+	g := loadAnalyticsGraph(opts)
+	var scores []float64
+	switch metric {
+	case GraphCentralityDegree:
+		scores = g.degreeCentrality()
+	default:
+		scores = g.pageRank()
+	}
+	return g.rankDocs(scores, limit, false)
+}
+
+// GraphBridgingDocs 返回桥接文档，即位于不同文档群之间最短路径上的文档，按介数中心度降序排列。
+func GraphBridgingDocs(limit int, opts *GraphAnalysisOptions) (ret []*GraphRankedNode) {
+	g := loadAnalyticsGraph(opts)
+	return g.rankDocs(g.betweenness(), limit, true)
+}
+
+// GraphCommunities 对文档进行社区发现，返回包含至少 minSize 个文档的社区，按规模降序排列。
+func GraphCommunities(minSize, limit int, opts *GraphAnalysisOptions) (ret []*GraphCommunity) {
+	ret = []*GraphCommunity{}
+	g := loadAnalyticsGraph(opts)
+	minSize = max(minSize, 2)
+	for _, members := range g.communities() {
+		var docs []*GraphAnalysisNode
+		for _, i := range members {
+			if GraphAnalysisNodeDoc == g.nodes[i].Type {
+				docs = append(docs, g.nodes[i])
+			}
+		}
+		if len(docs) < minSize {
+			continue
+		}
+		ret = append(ret, &GraphCommunity{ID: len(ret) + 1, Size: len(docs), Nodes: docs})
+		if 0 < limit && len(ret) >= limit {
+			break
+		}
+	}
+	return
+}
+
+// GraphOrphanDocs 返回孤立文档，即没有引用其他文档、也没有被其他文档引用的文档（开启标签分析时还需没有标签）。
+func GraphOrphanDocs(opts *GraphAnalysisOptions) (ret []*GraphAnalysisNode) {
+	ret = []*GraphAnalysisNode{}
+	g := loadAnalyticsGraph(opts)
+	for i, node := range g.nodes {
+		if GraphAnalysisNodeDoc == node.Type && 1 > len(g.adj[i]) {
+			ret = append(ret, node)
+		}
+	}
+	return
+}
+
+// GraphDeadEndDocs 返回死胡同文档，即被其他文档引用、但自身没有引用任何文档的文档。
+func GraphDeadEndDocs(opts *GraphAnalysisOptions) (ret []*GraphAnalysisNode) {
+	ret = []*GraphAnalysisNode{}
+	g := loadAnalyticsGraph(opts)
+	for i, node := range g.nodes {
+		if GraphAnalysisNodeDoc == node.Type && 0 < g.refIn[i] && 1 > g.refOut[i] {
+			ret = append(ret, node)
+		}
+	}
+	return
+}
+
+func loadAnalyticsGraph(opts *GraphAnalysisOptions) *analyticsGraph {
+	if nil == opts {
+		opts = &GraphAnalysisOptions{}
+	}
+
+	var nodes []*GraphAnalysisNode
+	for _, root := range sql.GetAllRootBlocks() {
+		if "" != opts.Box && root.Box != opts.Box {
+			continue
+		}
+		if nil != opts.Accessible && !opts.Accessible(root.Box, root.Path) {
+			continue
+		}
+		nodes = append(nodes, &GraphAnalysisNode{
+			ID:    root.ID,
+			Type:  GraphAnalysisNodeDoc,
+			Label: util.UnescapeHTML(root.Content),
+			Box:   root.Box,
+			Path:  root.Path,
+			HPath: root.HPath,
+		})
+	}
+
+	var refEdges, tagEdges [][2]string
+	for rootID, defRootIDs := range sql.QueryAllRootRefs() {
+		for _, defRootID := range defRootIDs {
+			refEdges = append(refEdges, [2]string{rootID, defRootID})
+		}
+	}
+	if opts.Tag {
+		for _, span := range sql.QueryTagSpans("") {
+			tagEdges = append(tagEdges, [2]string{span.RootID, util.UnescapeHTML(span.Content)})
+		}
+	}
+	return newAnalyticsGraph(nodes, refEdges, tagEdges)
+}
+
+// analyticsGraph 为关系图分析使用的邻接表，节点按 ID 排序以保证各算法结果稳定。
+type analyticsGraph struct {
+	nodes []*GraphAnalysisNode
+	index map[string]int
+
+	out [][]int // 有向边，标签边按双向处理
+	in  [][]int
+	adj [][]int // 无向邻接
+
+	refOut []int // 仅统计文档引用的出度
+	refIn  []int
+}
+
+// newAnalyticsGraph 构建分析图，refEdges 为引用文档到被引用文档的有向边，tagEdges 为文档到标签的边。
+// 端点文档不在 nodes 中的边会被忽略，标签节点按需创建。
+func newAnalyticsGraph(nodes []*GraphAnalysisNode, refEdges, tagEdges [][2]string) (ret *analyticsGraph) {
+	ret = &analyticsGraph{index: map[string]int{}}
+	docs := map[string]*GraphAnalysisNode{}
+	for _, node := range nodes {
+		docs[node.ID] = node
+	}
+
+	tags := map[string]*GraphAnalysisNode{}
+	for _, edge := range tagEdges {
+		if nil == docs[edge[0]] || "" == edge[1] || nil != tags[edge[1]] {
+			continue
+		}
+		tags[edge[1]] = &GraphAnalysisNode{ID: edge[1], Type: GraphAnalysisNodeTag, Label: edge[1]}
+	}
+
+	for _, node := range docs {
+		ret.nodes = append(ret.nodes, node)
+	}
+	for id, node := range tags {
+		if nil == docs[id] {
+			ret.nodes = append(ret.nodes, node)
+		}
+	}
+	sort.Slice(ret.nodes, func(i, j int) bool { return ret.nodes[i].ID < ret.nodes[j].ID })
+	for i, node := range ret.nodes {
+		ret.index[node.ID] = i
+	}
+
+	n := len(ret.nodes)
+	ret.out, ret.in, ret.adj = make([][]int, n), make([][]int, n), make([][]int, n)
+	ret.refOut, ret.refIn = make([]int, n), make([]int, n)
+	directed := map[[2]int]bool{}
+	undirected := map[[2]int]bool{}
+	addEdge := func(from, to int) {
+		if from == to || directed[[2]int{from, to}] {
+			return
+		}
+		directed[[2]int{from, to}] = true
+		ret.out[from] = append(ret.out[from], to)
+		ret.in[to] = append(ret.in[to], from)
+		key := [2]int{min(from, to), max(from, to)}
+		if !undirected[key] {
+			undirected[key] = true
+			ret.adj[from] = append(ret.adj[from], to)
+			ret.adj[to] = append(ret.adj[to], from)
+		}
+	}
+
+	for _, edge := range refEdges {
+		from, ok1 := ret.index[edge[0]]
+		to, ok2 := ret.index[edge[1]]
+		if !ok1 || !ok2 || from == to || directed[[2]int{from, to}] {
+			continue
+		}
+		addEdge(from, to)
+		ret.refOut[from]++
+		ret.refIn[to]++
+	}
+	for _, edge := range tagEdges {
+		if nil == docs[edge[0]] || nil == tags[edge[1]] {
+			continue
+		}
+		doc, tag := ret.index[edge[0]], ret.index[edge[1]]
+		addEdge(doc, tag)
+		addEdge(tag, doc)
+	}
+
+	for i := range n {
+		sort.Ints(ret.out[i])
+		sort.Ints(ret.in[i])
+		sort.Ints(ret.adj[i])
+	}
+	return
+}
+
+// resolve 将块 ID 映射为所在文档的节点，也支持直接传入标签。
+func (g *analyticsGraph) resolve(id string) (ret int, err error) {
+	if i, ok := g.index[id]; ok {
+		return i, nil
+	}
+
+	if block := sql.GetBlock(id); nil != block {
+		if i, ok := g.index[block.RootID]; ok {
+			return i, nil
+		}
+	}
+	return -1, ErrBlockNotFound
+}
+
+// shortestPath 在无向邻接上广度优先搜索，不可达时返回 nil。
+func (g *analyticsGraph) shortestPath(from, to int) []int {
+	prev := make([]int, len(g.nodes))
+	for i := range prev {
+		prev[i] = -1
+	}
+	prev[from] = from
+	queue := []int{from}
+	for 0 < len(queue) && -1 == prev[to] {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range g.adj[cur] {
+			if -1 == prev[next] {
+				prev[next] = cur
+				queue = append(queue, next)
+			}
+		}
+	}
+	if -1 == prev[to] {
+		return nil
+	}
+
+	var ret []int
+	for cur := to; cur != from; cur = prev[cur] {
+		ret = append(ret, cur)
+	}
+	ret = append(ret, from)
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return ret
+}
+
+func (g *analyticsGraph) pageRank() []float64 {
+	n := len(g.nodes)
+	ret := make([]float64, n)
+	if 1 > n {
+		return ret
+	}
+
+	for i := range ret {
+		ret[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for range graphPageRankIterations {
+		// 没有出边的节点将权重平均分给所有节点
+		dangling := 0.0
+		for i := range n {
+			if 1 > len(g.out[i]) {
+				dangling += ret[i]
+			}
+		}
+		base := (1-graphPageRankDamping)/float64(n) + graphPageRankDamping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for i := range n {
+			if 1 > len(g.out[i]) {
+				continue
+			}
+			share := graphPageRankDamping * ret[i] / float64(len(g.out[i]))
+			for _, to := range g.out[i] {
+				next[to] += share
+			}
+		}
+
+		delta := 0.0
+		for i := range n {
+			delta += math.Abs(next[i] - ret[i])
+		}
+		ret, next = next, ret
+		if delta < graphPageRankTolerance {
+			break
+		}
+	}
+	return ret
+}
+
+func (g *analyticsGraph) degreeCentrality() []float64 {
+	n := len(g.nodes)
+	ret := make([]float64, n)
+	if 2 > n {
+		return ret
+	}
+	for i := range n {
+		ret[i] = float64(len(g.adj[i])) / float64(n-1)
+	}
+	return ret
+}
+
+// betweenness 使用 Brandes 算法计算无向图的介数中心度，节点较多时对源点采样估算。
+func (g *analyticsGraph) betweenness() []float64 {
+	n := len(g.nodes)
+	ret := make([]float64, n)
+	if 3 > n {
+		return ret
+	}
+
+	var sources []int
+	if n <= graphBetweennessPivots {
+		for i := range n {
+			sources = append(sources, i)
+		}
+	} else {
+		step := float64(n) / graphBetweennessPivots
+		for i := range graphBetweennessPivots {
+			sources = append(sources, int(float64(i)*step))
+		}
+	}
+
+	sigma := make([]float64, n)
+	dist := make([]int, n)
+	delta := make([]float64, n)
+	preds := make([][]int, n)
+	for _, s := range sources {
+		for i := range n {
+			sigma[i], dist[i], delta[i] = 0, -1, 0
+			preds[i] = preds[i][:0]
+		}
+		sigma[s], dist[s] = 1, 0
+		stack := []int{}
+		queue := []int{s}
+		for 0 < len(queue) {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for _, w := range g.adj[v] {
+				if 0 > dist[w] {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+		for i := len(stack) - 1; 0 <= i; i-- {
+			w := stack[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				ret[w] += delta[w]
+			}
+		}
+	}
+
+	// 无向图中每条路径被两个端点各计算一次，再按采样比例和最大可能值归一化
+	scale := float64(n) / float64(len(sources)) / 2 / (float64(n-1) * float64(n-2) / 2)
+	for i := range ret {
+		ret[i] *= scale
+	}
+	return ret
+}
+
+// communities 使用 Louvain 算法按模块度划分社区，返回按规模降序排列的节点下标分组。
+func (g *analyticsGraph) communities() (ret [][]int) {
+	n := len(g.nodes)
+	labels := make([]int, n) // 原始节点所属的社区
+	for i := range labels {
+		labels[i] = i
+	}
+
+	// 当前层级的加权图，self 为社区内部边权重（按两个方向各计一次）
+	weights := make([]map[int]float64, n)
+	self := make([]float64, n)
+	for i := range n {
+		weights[i] = map[int]float64{}
+		for _, j := range g.adj[i] {
+			weights[i][j] = 1
+		}
+	}
+
+	for range graphCommunityIterations {
+		community, moved := louvainLocalMoving(weights, self)
+		if !moved {
+			break
+		}
+
+		// 重新编号社区并将每个社区聚合为下一层级的一个节点
+		renumber := map[int]int{}
+		for _, c := range community {
+			if _, ok := renumber[c]; !ok {
+				renumber[c] = len(renumber)
+			}
+		}
+		nextWeights := make([]map[int]float64, len(renumber))
+		nextSelf := make([]float64, len(renumber))
+		for i := range nextWeights {
+			nextWeights[i] = map[int]float64{}
+		}
+		for i, neighbors := range weights {
+			ci := renumber[community[i]]
+			nextSelf[ci] += self[i]
+			for j, w := range neighbors {
+				if cj := renumber[community[j]]; ci == cj {
+					nextSelf[ci] += w
+				} else {
+					nextWeights[ci][cj] += w
+				}
+			}
+		}
+		for i, label := range labels {
+			labels[i] = renumber[community[label]]
+		}
+		weights, self = nextWeights, nextSelf
+	}
+
+	groups := map[int][]int{}
+	for i, label := range labels {
+		groups[label] = append(groups[label], i)
+	}
+	for _, members := range groups {
+		ret = append(ret, members)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if len(ret[i]) != len(ret[j]) {
+			return len(ret[i]) > len(ret[j])
+		}
+		return ret[i][0] < ret[j][0]
+	})
+	return
+}
+
+// louvainLocalMoving 逐个将节点移动到模块度增益最大的相邻社区，直到没有节点移动。
+func louvainLocalMoving(weights []map[int]float64, self []float64) (community []int, moved bool) {
+	n := len(weights)
+	community = make([]int, n)
+	degrees := make([]float64, n)
+	totals := make([]float64, n) // 社区内所有节点的度数之和
+	m2 := 0.0
+	for i := range n {
+		community[i] = i
+		degrees[i] = self[i]
+		for _, w := range weights[i] {
+			degrees[i] += w
+		}
+		totals[i] = degrees[i]
+		m2 += degrees[i]
+	}
+	if 0 == m2 {
+		return
+	}
+
+	for range graphCommunityIterations {
+		changed := false
+		for i := range n {
+			links := map[int]float64{}
+			for j, w := range weights[i] {
+				links[community[j]] += w
+			}
+			current := community[i]
+			totals[current] -= degrees[i]
+
+			candidates := make([]int, 0, len(links))
+			for c := range links {
+				candidates = append(candidates, c)
+			}
+			sort.Ints(candidates)
+			// 增益相同时留在原社区，否则取编号最小的社区，保证结果稳定
+			best, bestGain := current, links[current]-totals[current]*degrees[i]/m2
+			for _, c := range candidates {
+				if gain := links[c] - totals[c]*degrees[i]/m2; gain > bestGain+1e-12 {
+					best, bestGain = c, gain
+				}
+			}
+
+			totals[best] += degrees[i]
+			if best != current {
+				community[i] = best
+				changed, moved = true, true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return
+}
+
+// rankDocs 按分值降序返回文档，positiveOnly 为 true 时忽略分值为 0 的文档。
+func (g *analyticsGraph) rankDocs(scores []float64, limit int, positiveOnly bool) (ret []*GraphRankedNode) {
+	ret = []*GraphRankedNode{}
+	for i, node := range g.nodes {
+		if GraphAnalysisNodeDoc != node.Type || (positiveOnly && 0 >= scores[i]) {
+			continue
+		}
+		ret = append(ret, &GraphRankedNode{GraphAnalysisNode: node, Score: scores[i], InDegree: g.refIn[i], OutDegree: g.refOut[i]})
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Score > ret[j].Score })
+	if 0 < limit && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return
+}
@@ -0,0 +1,145 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+)
+
+func testAnalyticsGraph(tag bool) *analyticsGraph {
+	var nodes []*GraphAnalysisNode
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		nodes = append(nodes, &GraphAnalysisNode{ID: id, Type: GraphAnalysisNodeDoc, Label: id})
+	}
+	// a、b、c 和 e、f、g 各自成群，d 连接两个群，h 孤立
+	refEdges := [][2]string{
+		{"a", "b"}, {"b", "c"}, {"c", "a"}, {"a", "b"},
+		{"c", "d"}, {"d", "e"},
+		{"e", "f"}, {"f", "g"}, {"g", "e"},
+		{"x", "a"}, {"a", "a"},
+	}
+	var tagEdges [][2]string
+	if tag {
+		tagEdges = [][2]string{{"h", "t"}, {"a", "t"}}
+	}
+	return newAnalyticsGraph(nodes, refEdges, tagEdges)
+}
+
+func graphNodeIDs(g *analyticsGraph, indexes []int) (ret []string) {
+	for _, i := range indexes {
+		ret = append(ret, g.nodes[i].ID)
+	}
+	return
+}
+
+func TestAnalyticsGraphEdges(t *testing.T) {
+	g := testAnalyticsGraph(false)
+	if 8 != len(g.nodes) {
+		t.Fatalf("节点数错误：%d", len(g.nodes))
+	}
+	a := g.index["a"]
+	if 1 != g.refOut[a] || 1 != g.refIn[a] {
+		t.Fatalf("重复边、自环和未知端点应被忽略：out=%d in=%d", g.refOut[a], g.refIn[a])
+	}
+	if 0 != len(g.adj[g.index["h"]]) {
+		t.Fatalf("h 应为孤立节点")
+	}
+
+	g = testAnalyticsGraph(true)
+	if 9 != len(g.nodes) || GraphAnalysisNodeTag != g.nodes[g.index["t"]].Type {
+		t.Fatalf("开启标签后应创建标签节点")
+	}
+	if 0 != g.refIn[g.index["t"]] || 1 != g.refIn[a] {
+		t.Fatalf("标签边不应计入引用度数")
+	}
+}
+
+func TestAnalyticsGraphShortestPath(t *testing.T) {
+	g := testAnalyticsGraph(false)
+	path := graphNodeIDs(g, g.shortestPath(g.index["b"], g.index["f"]))
+	expected := []string{"b", "c", "d", "e", "f"}
+	if len(path) != len(expected) {
+		t.Fatalf("最短路径错误：%v", path)
+	}
+	for i := range expected {
+		if path[i] != expected[i] {
+			t.Fatalf("最短路径错误：%v", path)
+		}
+	}
+	if nil != g.shortestPath(g.index["a"], g.index["h"]) {
+		t.Fatalf("不可达时应返回 nil")
+	}
+
+	g = testAnalyticsGraph(true)
+	path = graphNodeIDs(g, g.shortestPath(g.index["h"], g.index["b"]))
+	if 4 != len(path) || "t" != path[1] {
+		t.Fatalf("开启标签后应经由标签连通：%v", path)
+	}
+}
+
+func TestAnalyticsGraphPageRank(t *testing.T) {
+	g := testAnalyticsGraph(false)
+	scores := g.pageRank()
+	sum := 0.0
+	for _, score := range scores {
+		sum += score
+	}
+	if 0.999 > sum || 1.001 < sum {
+		t.Fatalf("PageRank 之和应为 1：%f", sum)
+	}
+	if scores[g.index["e"]] <= scores[g.index["h"]] {
+		t.Fatalf("被引用的文档排名应高于孤立文档")
+	}
+
+	ranked := g.rankDocs(scores, 3, false)
+	if 3 != len(ranked) || ranked[0].Score < ranked[1].Score {
+		t.Fatalf("排名结果错误")
+	}
+}
+
+func TestAnalyticsGraphBetweenness(t *testing.T) {
+	g := testAnalyticsGraph(false)
+	ranked := g.rankDocs(g.betweenness(), 0, true)
+	if 1 > len(ranked) || "d" != ranked[0].ID {
+		t.Fatalf("d 应为桥接度最高的文档")
+	}
+	for _, node := range ranked {
+		if "h" == node.ID || "a" == node.ID {
+			t.Fatalf("%s 不应出现在桥接文档中", node.ID)
+		}
+	}
+}
+
+func TestAnalyticsGraphCommunities(t *testing.T) {
+	g := testAnalyticsGraph(false)
+	communities := g.communities()
+	group := map[string]int{}
+	for i, members := range communities {
+		for _, id := range graphNodeIDs(g, members) {
+			group[id] = i
+		}
+	}
+	if group["a"] != group["b"] || group["b"] != group["c"] {
+		t.Fatalf("a、b、c 应在同一社区：%v", group)
+	}
+	if group["e"] != group["f"] || group["f"] != group["g"] {
+		t.Fatalf("e、f、g 应在同一社区：%v", group)
+	}
+	if group["a"] == group["e"] || group["h"] == group["a"] || group["h"] == group["e"] {
+		t.Fatalf("不同的群应划分为不同社区：%v", group)
+	}
+}
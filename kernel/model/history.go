@@ -426,6 +426,8 @@ func RollbackDocHistory(historyPath string) (err error) {
 	if writeErr := indexWriteTreeIndexQueue(tree); nil != writeErr {
 		return
 	}
+	// 回滚后文档内容已变化，原有的撤销日志不再适用
+	InvalidateUndoLog([]string{rootID, tree.ID})
 	ReloadFiletree()
 	ReloadProtyle(rootID)
 
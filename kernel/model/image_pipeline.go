@@ -0,0 +1,488 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// imageOriginalsDir 为保留原图的目录名，位于资源目录下，以 . 开头的目录不参与资源索引和未引用资源清理。
+const imageOriginalsDir = ".originals"
+
+// SetImagePipeline 校验并保存上传图片处理配置。
+func SetImagePipeline(pipeline *conf.ImagePipeline) (err error) {
+	if conf.ImageFormatOriginal != pipeline.Format && conf.ImageFormatJPEG != pipeline.Format && conf.ImageFormatWebP != pipeline.Format {
+		return fmt.Errorf("unsupported image format [%s]", pipeline.Format)
+	}
+	if 1 > pipeline.Quality || 100 < pipeline.Quality {
+		return fmt.Errorf("image quality must be between 1 and 100")
+	}
+	if 0 > pipeline.MaxDimension {
+		return fmt.Errorf("image max dimension must not be negative")
+	}
+
+	Conf.Image = pipeline
+	Conf.Save()
+	return
+}
+
+// isPipelineImage 判断资源是否为图片处理支持的格式。
+func isPipelineImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// applyUploadImagePipeline 在上传写入资源前按配置处理图片。返回写入用的文件名（格式转换时扩展名会改变）和内容；
+// original 非空时调用方需要在写入后调用 keepOriginalImage 保留原图。未启用、非图片或处理失败时按原内容写入。
+// 资源复用需要按返回的内容计算哈希，这样同一原图再次上传时能匹配到已经处理过的资源。
+func applyUploadImagePipeline(fName string, src io.ReadSeeker) (newFName string, newSrc io.ReadSeeker, original []byte, err error) {
+	newFName, newSrc = fName, src
+	pipeline := Conf.Image
+	if nil == pipeline || !pipeline.Enabled || !isPipelineImage(fName) {
+		return
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return
+	}
+	newSrc = bytes.NewReader(data)
+
+	processed, ext, changed, processErr := processImage(data, filepath.Ext(fName), pipeline)
+	if nil != processErr {
+		logging.LogWarnf("process image [%s] failed: %s", fName, processErr)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	newFName = strings.TrimSuffix(fName, filepath.Ext(fName)) + ext
+	newSrc = bytes.NewReader(processed)
+	if pipeline.KeepOriginal {
+		original = data
+	}
+	return
+}
+
+// keepOriginalImage 将原图保存到处理后资源所在目录的 .originals 下，文件名与处理后的资源相同、扩展名为原图扩展名。
+func keepOriginalImage(writePath, originalExt string, original []byte, boxID string) {
+	name := strings.TrimSuffix(filepath.Base(writePath), filepath.Ext(writePath)) + strings.ToLower(originalExt)
+	originalPath := filepath.Join(filepath.Dir(writePath), imageOriginalsDir, name)
+	if err := os.MkdirAll(filepath.Dir(originalPath), 0755); err != nil {
+		logging.LogErrorf("create originals dir failed: %s", err)
+		return
+	}
+	if err := writeAssetFile(originalPath, bytes.NewReader(original), boxID); err != nil {
+		logging.LogErrorf("keep original image [%s] failed: %s", originalPath, err)
+	}
+}
+
+// processImage 按配置处理图片：超过最大尺寸时缩放，需要时转换格式并重新编码，否则仅无损去除元数据。
+// 重新编码会按 EXIF 方向旋转图片并丢弃所有元数据，结果不比原图小时保留原图。changed 为 false 时调用方应保留原内容。
+func processImage(data []byte, ext string, pipeline *conf.ImagePipeline) (ret []byte, newExt string, changed bool, err error) {
+	ret, newExt = data, ext
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	if "jpeg" != format && "png" != format {
+		return
+	}
+
+	targetFormat := format
+	switch pipeline.Format {
+	case conf.ImageFormatJPEG:
+		targetFormat = "jpeg"
+	case conf.ImageFormatWebP:
+		targetFormat = "webp"
+	}
+	orientation := 1
+	if "jpeg" == format {
+		orientation = jpegOrientation(data)
+	}
+
+	needResize := 0 < pipeline.MaxDimension && (cfg.Width > pipeline.MaxDimension || cfg.Height > pipeline.MaxDimension)
+	// 去除元数据时方向信息也会丢失，需要先按方向旋转像素
+	needRotate := pipeline.StripMetadata && 1 != orientation
+	if !needResize && !needRotate && targetFormat == format {
+		if pipeline.StripMetadata {
+			ret = stripImageMetadata(data, format)
+			changed = len(ret) != len(data)
+		}
+		return
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return
+	}
+	if needResize {
+		img = imaging.Fit(img, pipeline.MaxDimension, pipeline.MaxDimension, imaging.Lanczos)
+	}
+	if "jpeg" == targetFormat && "png" == format && !isOpaqueImage(img) {
+		// JPEG 不支持透明通道
+		targetFormat = "png"
+	}
+
+	buf := &bytes.Buffer{}
+	if err = encodeImage(buf, img, targetFormat, pipeline.Quality); err != nil {
+		return
+	}
+	if buf.Len() >= len(data) && !needRotate {
+		// 重新编码后没有变小时保留原图。需要旋转时原图去除元数据后会丢失方向，仍使用重新编码的结果
+		if pipeline.StripMetadata {
+			ret = stripImageMetadata(data, format)
+			changed = len(ret) != len(data)
+		}
+		return
+	}
+	ret, changed = buf.Bytes(), true
+	if targetFormat != format {
+		switch targetFormat {
+		case "jpeg":
+			newExt = ".jpg"
+		case "webp":
+			newExt = ".webp"
+		}
+	}
+	return
+}
+
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "jpeg":
+		return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(quality))
+	case "webp":
+		// WebP 使用有损编码并保留透明通道
+		return webp.Encode(w, img, &webp.Options{Quality: float32(quality)})
+	}
+	return imaging.Encode(w, img, imaging.PNG, imaging.PNGCompressionLevel(png.BestCompression))
+}
+
+// stripImageMetadata 无损去除 JPEG 或 PNG 的元数据。
+func stripImageMetadata(data []byte, format string) []byte {
+	if "jpeg" == format {
+		return stripJPEGMetadata(data)
+	}
+	return stripPNGMetadata(data)
+}
+
+func isOpaqueImage(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// jpegOrientation 返回 JPEG 的 EXIF 方向，没有方向信息时返回 1。
+func jpegOrientation(data []byte) int {
+	if 4 > len(data) || 0xFF != data[0] || 0xD8 != data[1] {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if 0xFF != data[i] {
+			return 1
+		}
+		marker := data[i+1]
+		if 0xFF == marker {
+			i++
+			continue
+		}
+		if 0xDA == marker || 0xD9 == marker {
+			return 1
+		}
+		if 0x01 == marker || (0xD0 <= marker && 0xD7 >= marker) {
+			i += 2
+			continue
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if 2 > size || i+2+size > len(data) {
+			return 1
+		}
+		payload := data[i+4 : i+2+size]
+		if 0xE1 == marker && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return exifOrientation(payload[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation 从 TIFF 结构的 IFD0 中读取方向标签（0x0112）。
+func exifOrientation(tiff []byte) int {
+	if 8 > len(tiff) {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if 0x0112 == order.Uint16(tiff[entry:]) {
+			if orientation := int(order.Uint16(tiff[entry+8:])); 1 <= orientation && 8 >= orientation {
+				return orientation
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// stripJPEGMetadata 无损去除 JPEG 中的 EXIF/XMP（APP1）、IPTC（APP13）和注释段，保留 JFIF、ICC 颜色配置和 Adobe 段。
+func stripJPEGMetadata(data []byte) []byte {
+	if 4 > len(data) || 0xFF != data[0] || 0xD8 != data[1] {
+		return data
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)))
+	buf.Write(data[:2])
+	for i := 2; i+4 <= len(data); {
+		if 0xFF != data[i] {
+			return data
+		}
+		marker := data[i+1]
+		if 0xDA == marker {
+			// 扫描数据及之后的内容原样保留
+			buf.Write(data[i:])
+			return buf.Bytes()
+		}
+		if 0xFF == marker || 0x01 == marker || (0xD0 <= marker && 0xD7 >= marker) {
+			buf.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if 2 > size || i+2+size > len(data) {
+			return data
+		}
+		if 0xE1 != marker && 0xED != marker && 0xFE != marker {
+			buf.Write(data[i : i+2+size])
+		}
+		i += 2 + size
+	}
+	return data
+}
+
+// stripPNGMetadata 去除 PNG 中的 EXIF 和文本块，其余块原样保留。
+func stripPNGMetadata(data []byte) []byte {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, signature) {
+		return data
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)))
+	buf.Write(signature)
+	for i := len(signature); i < len(data); {
+		if i+12 > len(data) {
+			return data
+		}
+		size := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + size
+		if 0 > size || end > len(data) {
+			return data
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			buf.Write(data[i:end])
+		}
+		i = end
+	}
+	return buf.Bytes()
+}
+
+// ProcessedImage 描述了批量处理中的一张图片，格式转换时 NewPath 与 Path 不同。
+type ProcessedImage struct {
+	Path         string `json:"path"`
+	NewPath      string `json:"newPath"`
+	OriginalSize int64  `json:"originalSize"`
+	Size         int64  `json:"size"`
+}
+
+// ImagePipelineResult 是批量处理图片的结果，预览时不修改文件。
+type ImagePipelineResult struct {
+	Images      []*ProcessedImage `json:"images"`
+	SavedSize   int64             `json:"savedSize"`
+	UpdatedDocs int               `json:"updatedDocs"`
+	UpdatedAVs  int               `json:"updatedAVs"`
+	DryRun      bool              `json:"dryRun"`
+}
+
+// ProcessAssetImages 按上传图片处理配置批量处理 data/assets 下已有的 JPEG 和 PNG 图片，不要求启用上传时处理。
+// 格式转换导致扩展名改变时改写文档和数据库中的链接。被替换的图片保存到历史，开启保留原图时同时保存到 .originals。
+// 加密笔记本的资源不参与处理。
+func ProcessAssetImages(dryRun bool) (ret *ImagePipelineResult, err error) {
+	ret = &ImagePipelineResult{Images: []*ProcessedImage{}, DryRun: dryRun}
+	pipeline := Conf.Image
+	if nil == pipeline {
+		pipeline = conf.NewImagePipeline()
+	}
+
+	FlushTxQueue()
+	if !dryRun {
+		util.PushEndlessProgress(Conf.Language(110))
+		defer util.PushClearProgress()
+	}
+
+	assetsDir := util.GetDataAssetsAbsPath()
+	var imgPaths []string
+	filelock.Walk(assetsDir, func(absPath string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr || nil == d {
+			return walkErr
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(d.Name(), ".") && !filelock.IsHidden(absPath) && isPipelineImage(d.Name()) {
+			imgPaths = append(imgPaths, absPath)
+		}
+		return nil
+	})
+
+	var historyDir string
+	if !dryRun {
+		if historyDir, err = getHistoryDir(HistoryOpUpdate); err != nil {
+			return
+		}
+	}
+
+	replacements := map[string]string{}
+	for _, absPath := range imgPaths {
+		data, readErr := filelock.ReadFile(absPath)
+		if nil != readErr {
+			logging.LogErrorf("read image [%s] failed: %s", absPath, readErr)
+			continue
+		}
+
+		processed, ext, changed, processErr := processImage(data, filepath.Ext(absPath), pipeline)
+		if nil != processErr {
+			logging.LogWarnf("process image [%s] failed: %s", absPath, processErr)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		p := "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir))
+		newAbsPath := strings.TrimSuffix(absPath, filepath.Ext(absPath)) + ext
+		newP := strings.TrimSuffix(p, filepath.Ext(p)) + ext
+		if newAbsPath != absPath && filelock.IsExist(newAbsPath) {
+			logging.LogWarnf("skip processing image [%s] because [%s] already exists", absPath, newAbsPath)
+			continue
+		}
+
+		ret.Images = append(ret.Images, &ProcessedImage{Path: p, NewPath: newP, OriginalSize: int64(len(data)), Size: int64(len(processed))})
+		ret.SavedSize += int64(len(data)) - int64(len(processed))
+		if dryRun {
+			continue
+		}
+
+		if err = filelock.Copy(absPath, filepath.Join(historyDir, p)); err != nil {
+			return
+		}
+		if pipeline.KeepOriginal {
+			keepOriginalImage(newAbsPath, filepath.Ext(absPath), data, "")
+		}
+		if err = filelock.WriteFile(newAbsPath, processed); err != nil {
+			logging.LogErrorf("write image [%s] failed: %s", newAbsPath, err)
+			return
+		}
+		if newAbsPath != absPath {
+			if err = filelock.RemoveWithoutFatal(absPath); err != nil {
+				logging.LogErrorf("remove image [%s] failed: %s", absPath, err)
+				return
+			}
+			replacements[p] = newP
+			if ocrText := util.GetAssetText(p); "" != ocrText {
+				util.SetAssetText(newP, ocrText)
+				util.RemoveAssetText(p)
+			}
+		}
+		removeAssetThumbnail(p)
+		if hash, hashErr := util.GetEtagByHandle(bytes.NewReader(processed), int64(len(processed))); nil == hashErr {
+			cache.SetAssetHash(hash, newP)
+		}
+		util.PushEndlessProgress(fmt.Sprintf(Conf.Language(111), util.EscapeHTML(p)))
+	}
+
+	if !dryRun && 0 < len(replacements) {
+		var rootIDs []string
+		if rootIDs, err = rewriteAssetLinksInTrees(replacements, historyDir); err != nil {
+			return
+		}
+		ret.UpdatedDocs = len(rootIDs)
+		if ret.UpdatedAVs, err = rewriteAssetLinksInAttrViews(replacements); err != nil {
+			return
+		}
+		sql.FlushQueue()
+		InvalidateUndoLog(rootIDs)
+		for _, rootID := range rootIDs {
+			ReloadProtyle(rootID)
+		}
+	}
+
+	if !dryRun && 0 < len(ret.Images) {
+		indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+		cache.LoadAssets()
+		IncSync()
+	}
+	return
+}
@@ -0,0 +1,238 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	_ "golang.org/x/image/webp"
+)
+
+func testJPEGWithOrientation(t *testing.T, width, height, orientation int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("II\x2a\x00\x08\x00\x00\x00\x01\x00")
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry, 0x0112)
+	binary.LittleEndian.PutUint16(entry[2:], 3)
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], uint16(orientation))
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(payload)+2))
+	app1 = append(app1, payload...)
+
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+// testPhotoImage 返回带噪点的渐变图片，无损编码体积较大，有损编码能明显压缩。
+func testPhotoImage(width, height int, alpha uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	seed := uint32(1)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			seed = seed*1664525 + 1013904223
+			noise := uint8(seed >> 28)
+			img.Set(x, y, color.NRGBA{R: uint8(x) + noise, G: uint8(y) + noise, B: uint8(x+y) + noise, A: alpha})
+		}
+	}
+	return img
+}
+
+func TestJPEGMetadata(t *testing.T) {
+	data := testJPEGWithOrientation(t, 8, 4, 6)
+	if 6 != jpegOrientation(data) {
+		t.Fatalf("读取 EXIF 方向错误：%d", jpegOrientation(data))
+	}
+
+	stripped := stripJPEGMetadata(data)
+	if bytes.Contains(stripped, []byte("Exif\x00\x00")) {
+		t.Fatalf("EXIF 未被去除")
+	}
+	if 1 != jpegOrientation(stripped) {
+		t.Fatalf("去除 EXIF 后方向应为 1")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); nil != err {
+		t.Fatalf("去除元数据后的 JPEG 无法解码：%s", err)
+	}
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// 在 IHDR 块之后插入 tEXt 块，CRC 对去除逻辑无影响
+	text := []byte("Comment\x00secret")
+	chunk := make([]byte, 8)
+	binary.BigEndian.PutUint32(chunk, uint32(len(text)))
+	copy(chunk[4:], "tEXt")
+	chunk = append(append(chunk, text...), 0, 0, 0, 0)
+	ihdrEnd := 8 + 12 + 13
+	withText := append(append(append([]byte{}, data[:ihdrEnd]...), chunk...), data[ihdrEnd:]...)
+
+	stripped := stripPNGMetadata(withText)
+	if !bytes.Equal(data, stripped) {
+		t.Fatalf("PNG 文本块未被去除")
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	pipeline := conf.NewImagePipeline()
+	pipeline.MaxDimension = 4
+
+	// 方向为 6 时需要顺时针旋转 90 度，8x4 旋转后为 4x8，再缩放到长边 4
+	data := testJPEGWithOrientation(t, 8, 4, 6)
+	ret, ext, changed, err := processImage(data, ".JPG", pipeline)
+	if nil != err || !changed || ".JPG" != ext {
+		t.Fatalf("处理图片失败：%v %v %s", err, changed, ext)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(ret))
+	if nil != err || "jpeg" != format || 2 != cfg.Width || 4 != cfg.Height {
+		t.Fatalf("缩放或旋转结果错误：%v %s %dx%d", err, format, cfg.Width, cfg.Height)
+	}
+
+	// 不需要缩放和旋转时仅去除元数据
+	pipeline.MaxDimension = 0
+	data = testJPEGWithOrientation(t, 8, 4, 1)
+	ret, _, changed, _ = processImage(data, ".jpg", pipeline)
+	if !changed || len(ret) >= len(data) || bytes.Contains(ret, []byte("Exif")) {
+		t.Fatalf("应仅去除元数据")
+	}
+
+	// 不透明 PNG 转为 JPEG，带透明通道的 PNG 保持原格式
+	pipeline.Format = conf.ImageFormatJPEG
+	buf := &bytes.Buffer{}
+	png.Encode(buf, testPhotoImage(64, 64, 255))
+	if _, ext, changed, _ = processImage(buf.Bytes(), ".png", pipeline); !changed || ".jpg" != ext {
+		t.Fatalf("不透明 PNG 应转为 JPEG：%s", ext)
+	}
+	buf.Reset()
+	png.Encode(buf, testPhotoImage(64, 64, 128))
+	if _, ext, _, _ = processImage(buf.Bytes(), ".png", pipeline); ".png" != ext {
+		t.Fatalf("透明 PNG 应保持原格式：%s", ext)
+	}
+
+	// WebP 按编码质量有损编码并保留透明通道，带透明通道的 PNG 也会转换
+	pipeline.Format = conf.ImageFormatWebP
+	if ret, ext, changed, err = processImage(buf.Bytes(), ".png", pipeline); nil != err || !changed || ".webp" != ext {
+		t.Fatalf("PNG 应转为 WebP：%v %s", err, ext)
+	}
+	if cfg, format, err = image.DecodeConfig(bytes.NewReader(ret)); nil != err || "webp" != format || 64 != cfg.Width {
+		t.Fatalf("WebP 编码结果错误：%v %s", err, format)
+	}
+	if len(ret) >= buf.Len()/2 {
+		t.Fatalf("WebP 应为有损编码：%d %d", len(ret), buf.Len())
+	}
+	pipeline.Quality = 20
+	if low, _, _, _ := processImage(buf.Bytes(), ".png", pipeline); len(low) >= len(ret) {
+		t.Fatalf("WebP 编码质量未生效：%d %d", len(low), len(ret))
+	}
+	pipeline.Quality = 85
+	buf.Reset()
+	jpeg.Encode(buf, testPhotoImage(64, 64, 255), &jpeg.Options{Quality: 95})
+	if _, ext, changed, err = processImage(buf.Bytes(), ".jpg", pipeline); nil != err || !changed || ".webp" != ext {
+		t.Fatalf("JPEG 应转为 WebP：%v %s", err, ext)
+	}
+
+	// 重新编码后没有变小时保留原图
+	pipeline.Format = conf.ImageFormatJPEG
+	buf.Reset()
+	png.Encode(buf, image.NewGray(image.Rect(0, 0, 2, 2)))
+	if ret, ext, changed, err = processImage(buf.Bytes(), ".png", pipeline); nil != err || changed || ".png" != ext || !bytes.Equal(ret, buf.Bytes()) {
+		t.Fatalf("重新编码后变大时应保留原图：%v %v %s", err, changed, ext)
+	}
+}
+
+func TestUploadImagePipelineHash(t *testing.T) {
+	originalConf := Conf
+	t.Cleanup(func() { Conf = originalConf })
+	Conf = NewAppConf()
+	Conf.Image = conf.NewImagePipeline()
+	Conf.Image.Enabled = true
+
+	// 资源复用按处理后的内容计算哈希，同一原图再次上传时与已写入的资源哈希一致
+	data := testJPEGWithOrientation(t, 8, 4, 6)
+	var hashes []string
+	for range 2 {
+		_, src, _, err := applyUploadImagePipeline("foo.jpg", bytes.NewReader(data))
+		if nil != err {
+			t.Fatal(err)
+		}
+		hash, err := hashUploadAsset(src)
+		if nil != err {
+			t.Fatal(err)
+		}
+		written, _ := io.ReadAll(src)
+		if bytes.Equal(written, data) {
+			t.Fatal("图片应被处理")
+		}
+		if writtenHash, _ := util.GetEtagByHandle(bytes.NewReader(written), int64(len(written))); writtenHash != hash {
+			t.Fatalf("哈希应按写入的内容计算：%s %s", hash, writtenHash)
+		}
+		hashes = append(hashes, hash)
+	}
+	if hashes[0] != hashes[1] {
+		t.Fatalf("同一原图多次处理后的哈希应一致：%v", hashes)
+	}
+}
+
+func TestGenerateWebPAssetsThumbnail(t *testing.T) {
+	originalConf := Conf
+	t.Cleanup(func() { Conf = originalConf })
+	Conf = NewAppConf()
+
+	buf := &bytes.Buffer{}
+	if err := encodeImage(buf, image.NewNRGBA(image.Rect(0, 0, 1040, 20)), "webp", 85); nil != err {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	source, thumbnail := filepath.Join(dir, "foo.webp"), filepath.Join(dir, "thumbnails", "foo.webp")
+	if err := os.WriteFile(source, buf.Bytes(), 0644); nil != err {
+		t.Fatal(err)
+	}
+	if err := GenerateAssetsThumbnail(source, thumbnail); nil != err {
+		t.Fatalf("生成 WebP 缩略图失败：%s", err)
+	}
+	data, _ := os.ReadFile(thumbnail)
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(data)); nil != err || "webp" != format || 520 != cfg.Width {
+		t.Fatalf("WebP 缩略图错误：%v %s", err, format)
+	}
+}
@@ -42,6 +42,9 @@ type Petal struct {
 	DisabledInPublish bool   `json:"disabledInPublish"` // Whether disabled in publish mode
 	DisallowInstall   bool   `json:"disallowInstall"`   // Whether disallow install
 
+	Permissions         *bazaar.PluginPermissions `json:"permissions"`         // Permissions declared in plugin.json
+	ApprovedPermissions *bazaar.PluginPermissions `json:"approvedPermissions"` // Permissions approved by the user
+
 	JS     string         `json:"js"`     // JS code
 	CSS    string         `json:"css"`    // CSS code
 	I18n   map[string]any `json:"i18n"`   // i18n text
@@ -84,6 +87,7 @@ func SetPetalEnabled(name string, enabled bool) (ret *Petal, err error) {
 	ret.Incompatible = incompatible
 	ret.DisabledInPublish = disabledInPublish
 	ret.DisallowInstall = disallowInstall
+	ret.Permissions = bazaar.ParseInstalledPluginPermissions(name)
 	ret.Kernel = KernelPetal{
 		Incompatible: kernelIncompatible,
 	}
@@ -111,6 +115,63 @@ func SetPetalEnabled(name string, enabled bool) (ret *Petal, err error) {
 	return
 }
 
+// ApprovePetalPermissions 批准插件当前在 plugin.json 中声明的权限。
+func ApprovePetalPermissions(name string) (ret *Petal, err error) {
+	petals := getPetals()
+	ret = getPetalByName(name, petals)
+	if nil == ret {
+		if found, _, _, _, _, _, _ := bazaar.ParseInstalledPlugin(name, ""); !found {
+			err = fmt.Errorf("plugin [%s] not found", name)
+			logging.LogErrorf("%s", err)
+			return
+		}
+
+		ret = &Petal{
+			Name: name,
+		}
+		petals = append(petals, ret)
+	}
+
+	ret.Permissions = bazaar.ParseInstalledPluginPermissions(name)
+	ret.ApprovedPermissions = ret.Permissions
+	savePetals(petals)
+	logging.LogInfof("approved plugin [%s] permissions", name)
+	return
+}
+
+// GetPluginPermissions 返回已安装插件在 plugin.json 中声明的权限，未声明时返回 nil。
+func GetPluginPermissions(name string) *bazaar.PluginPermissions {
+	return bazaar.ParseInstalledPluginPermissions(name)
+}
+
+// IsPermissionsApproved 判断插件声明的权限是否已经被用户批准。
+// 未声明权限清单的旧插件视为拥有全部权限且无需批准；插件更新后扩大了权限时需要重新批准。
+// 已批准过权限的插件更新后删除了权限清单时，仍按已批准的权限运行，不视为旧插件。
+func (petal *Petal) IsPermissionsApproved() bool {
+	if nil == petal.Permissions {
+		return true
+	}
+
+	if nil == petal.ApprovedPermissions {
+		return false
+	}
+	return petal.ApprovedPermissions.Covers(petal.Permissions)
+}
+
+// EffectivePermissions 返回插件实际生效的权限，即插件声明且已被用户批准的权限。
+func (petal *Petal) EffectivePermissions() *bazaar.PluginPermissions {
+	if nil == petal.Permissions {
+		if nil != petal.ApprovedPermissions {
+			return petal.ApprovedPermissions
+		}
+		return bazaar.FullPluginPermissions()
+	}
+	if nil == petal.ApprovedPermissions {
+		return &bazaar.PluginPermissions{Readonly: true, API: []string{}, Storage: []string{}}
+	}
+	return petal.Permissions.Intersect(petal.ApprovedPermissions)
+}
+
 func getPetalByName(name string, petals []*Petal) (ret *Petal) {
 	for _, p := range petals {
 		if name == p.Name {
@@ -176,6 +237,7 @@ func loadPetals(frontend string, isPublish, isKernel bool) (ret []*Petal) {
 	petals := getPetals()
 	for _, petal := range petals {
 		_, petal.Version, petal.DisplayName, petal.Incompatible, petal.DisabledInPublish, petal.DisallowInstall, petal.Kernel.Incompatible = bazaar.ParseInstalledPlugin(petal.Name, frontend)
+		petal.Permissions = bazaar.ParseInstalledPluginPermissions(petal.Name)
 		if !petal.Enabled {
 			// disabled plugin
 			continue
@@ -402,6 +402,68 @@ func FilterViewByPublishAccess(c *gin.Context, publishAccess PublishAccess, view
 				kanban.Groups[i] = FilterViewByPublishAccess(c, publishAccess, viewable)
 			}
 		}
+	case av.LayoutTypeCalendar:
+		calendar := ret.(*av.Calendar)
+		filteredEvents := []*av.CalendarEvent{}
+		for _, event := range calendar.Events {
+			// 默认第一个属性是文档块
+			var bt *treenode.BlockTree
+			if len(event.Values) > 0 {
+				if event.Values[0].Value.Block != nil {
+					id := event.Values[0].Value.Block.ID
+					if id != "" {
+						bt = treenode.GetBlockTree(id)
+					}
+				}
+			}
+			if bt != nil {
+				// 不显示禁止文档
+				if !CheckPathAccessableByPublishIgnore(bt.BoxID, bt.Path, publishIgnore) {
+					event = nil
+				}
+			}
+			if event != nil {
+				filteredEvents = append(filteredEvents, event)
+			}
+		}
+		calendar.Events = filteredEvents
+		calendar.EventCount = len(calendar.Events)
+		if calendar.Groups != nil {
+			for i, viewable := range calendar.Groups {
+				calendar.Groups[i] = FilterViewByPublishAccess(c, publishAccess, viewable)
+			}
+		}
+	case av.LayoutTypeTimeline:
+		timeline := ret.(*av.Timeline)
+		filteredItems := []*av.TimelineItem{}
+		for _, item := range timeline.Items {
+			// 默认第一个属性是文档块
+			var bt *treenode.BlockTree
+			if len(item.Values) > 0 {
+				if item.Values[0].Value.Block != nil {
+					id := item.Values[0].Value.Block.ID
+					if id != "" {
+						bt = treenode.GetBlockTree(id)
+					}
+				}
+			}
+			if bt != nil {
+				// 不显示禁止文档
+				if !CheckPathAccessableByPublishIgnore(bt.BoxID, bt.Path, publishIgnore) {
+					item = nil
+				}
+			}
+			if item != nil {
+				filteredItems = append(filteredItems, item)
+			}
+		}
+		timeline.Items = filteredItems
+		timeline.ItemCount = len(timeline.Items)
+		if timeline.Groups != nil {
+			for i, viewable := range timeline.Groups {
+				timeline.Groups[i] = FilterViewByPublishAccess(c, publishAccess, viewable)
+			}
+		}
 	}
 	return
 }
@@ -874,10 +936,11 @@ func FilterGraphByPublishIgnore(publishIgnore PublishAccess, nodes []*GraphNode,
 
 func FilterTagsByPublishIgnore(publishIgnore PublishAccess, tags *Tags) (ret *Tags) {
 	spans := sql.QueryTagSpans("")
+	metas := GetTagMetas()
 	labelCounts := make(map[string]int)
 	for _, span := range spans {
 		if CheckPathAccessableByPublishIgnore(span.Box, span.Path, publishIgnore) {
-			label := util.UnescapeHTML(span.Content)
+			label := resolveTagAlias(util.UnescapeHTML(span.Content), metas)
 			labelCounts[label] += 1
 		}
 	}
@@ -1253,6 +1253,7 @@ func checkoutRepo(id string) {
 	}
 
 	FullReindexDirect()
+	GlobalUndoLog.Clear("")
 	appendAgentRollbackEntries()
 	time.Sleep(time.Second)
 	FlushTxQueue()
@@ -2134,7 +2135,7 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 	var upserts, removes []string
 	var upsertTrees int
 	// 可能需要重新加载部分功能
-	var needReloadFlashcard, needReloadOcrTexts, needReloadPlugin, needReloadSnippet bool
+	var needReloadFlashcard, needReloadOcrTexts, needReloadPlugin, needReloadSnippet, needReloadAIUsage bool
 	reloadPluginSet := hashset.New()     // 插件代码变更 data/plugins/
 	dataChangePluginSet := hashset.New() // 插件存储数据变更 data/storage/petal/
 	needUnindexBoxes, needIndexBoxes := map[string]bool{}, map[string]bool{}
@@ -2149,6 +2150,10 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 			needReloadOcrTexts = true
 		}
 
+		if strings.HasPrefix(file.Path, "/storage/ai/usage/") {
+			needReloadAIUsage = true
+		}
+
 		if strings.HasSuffix(file.Path, "/.siyuan/conf.json") {
 			needReloadFiletree = true
 			boxID := strings.TrimSuffix(strings.TrimPrefix(file.Path, "/"), "/.siyuan/conf.json")
@@ -2217,6 +2222,10 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 			needReloadOcrTexts = true
 		}
 
+		if strings.HasPrefix(file.Path, "/storage/ai/usage/") {
+			needReloadAIUsage = true
+		}
+
 		if strings.HasSuffix(file.Path, "/.siyuan/conf.json") {
 			needReloadFiletree = true
 			boxID := strings.TrimSuffix(strings.TrimPrefix(file.Path, "/"), "/.siyuan/conf.json")
@@ -2284,6 +2293,10 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 		util.LoadAssetsTexts()
 	}
 
+	if needReloadAIUsage {
+		resetAIUsageTotals()
+	}
+
 	if needReloadPlugin {
 		PushReloadPlugin(uninstallPluginSet, unloadPluginSet, reloadPluginSet, dataChangePluginSet, "")
 	}
@@ -2329,6 +2342,8 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 	}
 
 	upsertRootIDs, removeRootIDs := incReindex(upserts, removes)
+	// 同步修改过的文档不能继续沿用本地的撤销日志
+	InvalidateUndoLog(append(append([]string{}, upsertRootIDs...), removeRootIDs...))
 	needReloadFiletree = !needReloadUI && (needReloadFiletree || 0 < len(upsertRootIDs) || 0 < len(removeRootIDs))
 	if needReloadFiletree {
 		ReloadFiletree()
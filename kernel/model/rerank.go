@@ -16,6 +16,8 @@
 
 package model
 
+import "github.com/siyuan-note/siyuan/kernel/util"
+
 // defaultRerankCandidateCount 向量召回后默认送入重排的候选文档数，与 conf.defaultRerank 保持一致。
 const defaultRerankCandidateCount = 30
 
@@ -59,3 +61,16 @@ func rerankCandidateCount() int {
 	}
 	return defaultRerankCandidateCount
 }
+
+// rerank 使用当前重排配置精排候选文档，并记录用量。topN 不传，要求服务端返回全部文档评分，避免被服务端 top_n 上限截断。
+func rerank(query string, documents []string) (indices []int, err error) {
+	model := rerankModel()
+	if err = CheckAIBudget(model); nil != err {
+		return
+	}
+	indices, _, usage, err := util.Rerank(query, documents, rerankKey(), rerankEndpoint(), model, 0, rerankTimeout())
+	if nil == err {
+		RecordAIUsage(AIFeatureRerank, aiProviderName("", rerankEndpoint()), model, "", usage)
+	}
+	return
+}
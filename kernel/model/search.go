@@ -1264,7 +1264,7 @@ func mergeSamePreNext(n *ast.Node) {
 
 // FullTextSearchBlock 搜索内容块。
 //
-// method：0：关键字，1：查询语法，2：SQL，3：正则表达式
+// method：0：关键字，1：查询语法，2：SQL，3：正则表达式，5：混合（关键字 + 语义）
 // orderBy: 0：按块类型（默认），1：按创建时间升序，2：按创建时间降序，3：按更新时间升序，4：按更新时间降序，5：按内容顺序（仅在按文档分组时），6：按相关度升序，7：按相关度降序
 // groupBy：0：不分组，1：按文档分组
 func FullTextSearchBlock(query string, boxes, paths []string, types, subTypes map[string]bool, method, orderBy, groupBy, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount, pageCount int, docMode bool) {
@@ -1320,6 +1320,8 @@ func FullTextSearchBlockInBox(query string, boxes, paths []string, types, subTyp
 		boxArgs = append(boxArgs, boxDocArgs...)
 		pathFilter, pathArgs := buildPathsFilter(paths)
 		blocks, matchedBlockCount, matchedRootCount = fullTextSearchByRegexpInBox(query, boxFilter, pathFilter, boxArgs, pathArgs, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize, boxID)
+	case 5: // 混合（关键字 + 语义）
+		blocks, matchedBlockCount, matchedRootCount = hybridSearchBlockInBox(query, boxes, paths, types, subTypes, ignoreFilter, beforeLen, page, pageSize, boxID)
 	default: // 关键字
 		typeFilter := buildTypeFilter(types, subTypes)
 		boxFilter, boxArgs := buildBoxesFilter(boxes)
@@ -1331,7 +1333,7 @@ func FullTextSearchBlockInBox(query string, boxes, paths []string, types, subTyp
 			blocks, matchedBlockCount, matchedRootCount = searchBySQLInBox("SELECT * FROM `blocks` WHERE `id` = '"+query+"'", beforeLen, page, pageSize, boxID)
 		} else {
 			if 2 > len(strings.Split(strings.TrimSpace(query), " ")) {
-				query = stringQuery(query)
+				query = tagAliasQuery(query, GetTagMetas())
 				blocks, matchedBlockCount, matchedRootCount = fullTextSearchByFTSInBox(query, boxFilter, pathFilter, boxArgs, pathArgs, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize, boxID)
 			} else {
 				docMode = true // 文档全文搜索模式 https://github.com/siyuan-note/siyuan/issues/10584
@@ -0,0 +1,223 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/search"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+)
+
+// 混合搜索：关键字（FTS）召回与语义（向量）召回并行执行，按排名或分数融合为一个结果列表。
+// 关键字召回擅长精确词命中，语义召回擅长同义改写和自然语言提问，融合后两类查询都能得到合理结果。
+// 未启用嵌入或在加密笔记本内搜索时只有关键字召回，此时结果等同于按相关度排序的关键字搜索。
+
+// hybridRRFK 倒数排名融合的平滑常数，取常用的 60：排名靠前的差异被压平，避免单一召回源主导结果。
+const hybridRRFK = 60
+
+// hybridSearchBlockInBox 执行混合搜索，返回融合后的当前页块（已做关键字高亮）和融合候选总数，超出候选池的页为空。
+func hybridSearchBlockInBox(query string, boxes, paths []string, types, subTypes map[string]bool, ignoreFilter string, beforeLen, page, pageSize int, boxID string) (ret []*Block, matchedBlockCount, matchedRootCount int) {
+	ret = []*Block{}
+	keywords := strings.Fields(query)
+	if 1 > len(keywords) {
+		return
+	}
+
+	// 固定大小的候选池只融合一次，所有分页都在同一融合结果上切分，不随页码变化
+	candidateCount := Conf.Search.HybridCandidateCount
+	if isRerankEnabled() {
+		candidateCount = max(candidateCount, rerankCandidateCount())
+	}
+
+	var keywordHits, semanticHits []scoredBlock
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		keywordHits = hybridKeywordSearch(keywords, boxes, paths, types, subTypes, ignoreFilter, candidateCount, boxID)
+	})
+	// 加密笔记本不参与嵌入向量化，只做关键字召回
+	if "" == boxID && embeddingTableOk && isEmbeddingEnabled() {
+		wg.Go(func() {
+			semanticHits = hybridSemanticSearch(query, boxes, paths, types, subTypes, candidateCount)
+		})
+	}
+	wg.Wait()
+
+	fused := fuseRankings(keywordHits, semanticHits, Conf.Search.HybridFusion, Conf.Search.HybridSemanticWeight)
+	if len(fused) > candidateCount {
+		fused = fused[:candidateCount]
+	}
+	if 1 > len(fused) {
+		return
+	}
+
+	var ids []string
+	for _, s := range fused {
+		ids = append(ids, s.id)
+	}
+	sqlBlocks := getHybridSearchBlocks(ids, ignoreFilter, boxID)
+	if "" == boxID {
+		// 重排：与语义搜索一致，对融合候选逐对精排，未启用或失败时保留融合顺序
+		sqlBlocks = rerankSqlBlocks(query, sqlBlocks)
+	}
+	matchedBlockCount = len(sqlBlocks)
+
+	rootIDs := map[string]bool{}
+	for _, b := range sqlBlocks {
+		rootIDs[b.RootID] = true
+	}
+	matchedRootCount = len(rootIDs)
+
+	offset := (page - 1) * pageSize
+	if offset >= len(sqlBlocks) {
+		return
+	}
+	terms := strings.Join(keywords, search.TermSep)
+	for _, b := range sqlBlocks[offset:min(offset+pageSize, len(sqlBlocks))] {
+		ret = append(ret, fromSQLBlock(b, terms, beforeLen))
+	}
+	return
+}
+
+// hybridKeywordSearch 关键字召回：各关键字以 OR 组合做 FTS 匹配并按 bm25 相关度排序。
+// 与关键字搜索方式要求全部命中不同，这里只要命中任一关键字即可进入候选，融合时由排名决定先后。
+func hybridKeywordSearch(keywords, boxes, paths []string, types, subTypes map[string]bool, ignoreFilter string, limit int, boxID string) (ret []scoredBlock) {
+	var terms []string
+	for _, k := range keywords {
+		k = strings.ReplaceAll(k, "\"", "\"\"")
+		k = strings.ReplaceAll(k, "'", "''")
+		terms = append(terms, "\""+k+"\"")
+	}
+
+	boxFilter, boxArgs := buildBoxesFilter(boxes)
+	boxDocFilter, boxDocArgs := buildRootIDExclusionFilter(hiddenBoxDocRootIDs())
+	pathFilter, pathArgs := buildPathsFilter(paths)
+	stmt := "SELECT id, rank FROM blocks_fts WHERE (blocks_fts MATCH '" + columnFilter() + ":(" + strings.Join(terms, " OR ") + ")'" +
+		") AND " + buildTypeFilter(types, subTypes) + boxFilter + boxDocFilter + pathFilter + ignoreFilter +
+		" ORDER BY rank LIMIT " + strconv.Itoa(limit)
+	args := append(append(append([]any{}, boxArgs...), boxDocArgs...), pathArgs...)
+	rows, err := sql.QueryNoLimitArgsInBox(stmt, boxID, args...)
+	if err != nil {
+		logging.LogErrorf("hybrid keyword search failed: %s", err)
+		return
+	}
+	for _, row := range rows {
+		id, _ := row["id"].(string)
+		rank, _ := row["rank"].(float64)
+		// bm25 越小越相关，取反使分数越大越相关
+		ret = append(ret, scoredBlock{id: id, score: float32(-rank)})
+	}
+	return
+}
+
+func hybridSemanticSearch(query string, boxes, paths []string, types, subTypes map[string]bool, limit int) []scoredBlock {
+	vectors, err := getEmbeddings([]string{query})
+	if err != nil || 1 > len(vectors) {
+		// 语义召回失败时降级为纯关键字结果，不阻断搜索
+		logging.LogErrorf("get query embedding for hybrid search failed: %v", err)
+		return nil
+	}
+	return searchEmbeddings(vectors[0], newEmbeddingSearchFilter(boxes, paths, types, subTypes), limit)
+}
+
+// getHybridSearchBlocks 按融合顺序取回块，同时套用搜索忽略规则（语义召回阶段没有应用）。
+func getHybridSearchBlocks(ids []string, ignoreFilter, boxID string) (ret []*sql.Block) {
+	stmt := "SELECT * FROM blocks WHERE id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")" + ignoreFilter
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	blocks := sql.SelectBlocksRawStmtArgsInBox(stmt, args, len(ids), boxID)
+	byID := make(map[string]*sql.Block, len(blocks))
+	for _, b := range blocks {
+		byID[b.ID] = b
+	}
+	for _, id := range ids {
+		if b := byID[id]; nil != b {
+			ret = append(ret, b)
+		}
+	}
+	return
+}
+
+// fuseRankings 融合关键字与语义两路召回，返回按融合分数降序的块。两路输入都须按相关度降序排列。
+//   - rrf：score = Σ w / (hybridRRFK + rank)，只看排名，不受两路分数尺度不同的影响；
+//   - weighted：两路分数各自做 min-max 归一化后按权重相加，某一路未命中的块该路记 0 分。
+//
+// semanticWeight 为语义召回的权重，关键字召回权重为 1 - semanticWeight。
+func fuseRankings(keywordHits, semanticHits []scoredBlock, fusion string, semanticWeight float64) (ret []scoredBlock) {
+	semanticWeight = min(max(semanticWeight, 0), 1)
+	keywordWeight := 1 - semanticWeight
+
+	scores := map[string]float64{}
+	var order []string
+	add := func(id string, score float64) {
+		if _, exists := scores[id]; !exists {
+			order = append(order, id)
+		}
+		scores[id] += score
+	}
+
+	if conf.HybridFusionWeighted == fusion {
+		for _, hits := range []struct {
+			blocks []scoredBlock
+			weight float64
+		}{{keywordHits, keywordWeight}, {semanticHits, semanticWeight}} {
+			if 1 > len(hits.blocks) {
+				continue
+			}
+			lo, hi := hits.blocks[0].score, hits.blocks[0].score
+			for _, b := range hits.blocks {
+				lo, hi = min(lo, b.score), max(hi, b.score)
+			}
+			for _, b := range hits.blocks {
+				normalized := 1.0
+				if hi > lo {
+					normalized = float64(b.score-lo) / float64(hi-lo)
+				}
+				add(b.id, hits.weight*normalized)
+			}
+		}
+	} else {
+		for rank, b := range keywordHits {
+			add(b.id, keywordWeight/float64(hybridRRFK+rank+1))
+		}
+		for rank, b := range semanticHits {
+			add(b.id, semanticWeight/float64(hybridRRFK+rank+1))
+		}
+	}
+
+	for _, id := range order {
+		ret = append(ret, scoredBlock{id: id, score: float32(scores[id])})
+	}
+	// 稳定排序：同分时保留先关键字后语义的召回顺序
+	slices.SortStableFunc(ret, func(a, b scoredBlock) int {
+		if a.score > b.score {
+			return -1
+		}
+		if a.score < b.score {
+			return 1
+		}
+		return 0
+	})
+	return
+}
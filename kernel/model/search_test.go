@@ -20,6 +20,8 @@ import (
 	"slices"
 	"strings"
 	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
 )
 
 func TestValidEmbedBlockIDs(t *testing.T) {
@@ -223,3 +225,37 @@ func assertOrderBySequence(t *testing.T, orderBy string, fragments ...string) {
 		previous = current
 	}
 }
+
+func TestFuseRankings(t *testing.T) {
+	keywordHits := []scoredBlock{{id: "a", score: 9}, {id: "b", score: 5}, {id: "c", score: 1}}
+	semanticHits := []scoredBlock{{id: "c", score: 0.9}, {id: "d", score: 0.8}, {id: "a", score: 0.2}}
+
+	ids := func(blocks []scoredBlock) (ret []string) {
+		for _, b := range blocks {
+			ret = append(ret, b.id)
+		}
+		return
+	}
+
+	// RRF：a、c 两路都命中，排在只有单路命中的 b、d 之前；a 的两路排名之和更靠前
+	fused := ids(fuseRankings(keywordHits, semanticHits, conf.HybridFusionRRF, 0.5))
+	if !slices.Equal(fused, []string{"a", "c", "b", "d"}) {
+		t.Fatalf("倒数排名融合结果顺序错误：%v", fused)
+	}
+
+	// 权重为 1 时只看语义召回，关键字独有的块记 0 分排在最后
+	fused = ids(fuseRankings(keywordHits, semanticHits, conf.HybridFusionRRF, 1))
+	if !slices.Equal(fused[:3], []string{"c", "d", "a"}) || "b" != fused[3] {
+		t.Fatalf("语义权重为 1 时应按语义排名：%v", fused)
+	}
+
+	// 加权：归一化后 a = 0.5*1 + 0.5*0 = 0.5，c = 0.5*0 + 0.5*1 = 0.5，d = 0.5*0.857，b = 0.5*0.5
+	fused = ids(fuseRankings(keywordHits, semanticHits, conf.HybridFusionWeighted, 0.5))
+	if !slices.Equal(fused, []string{"a", "c", "d", "b"}) {
+		t.Fatalf("加权融合结果顺序错误：%v", fused)
+	}
+
+	if 0 != len(fuseRankings(nil, nil, conf.HybridFusionRRF, 0.5)) {
+		t.Fatalf("两路都为空时融合结果应为空")
+	}
+}
@@ -17,6 +17,7 @@
 package model
 
 import (
+	"errors"
 	"image/color"
 	"net/http"
 	"net/url"
@@ -211,6 +212,12 @@ func CheckAuth(c *gin.Context) {
 		RoleEditor,
 		RoleReader,
 	}) {
+		// 内核插件只能访问用户批准的路由分组
+		if msg := checkKernelPluginScope(c); "" != msg {
+			c.JSON(http.StatusForbidden, map[string]any{"code": -1, "msg": msg})
+			c.Abort()
+			return
+		}
 		c.Next()
 		return
 	}
@@ -235,6 +242,17 @@ func CheckAuth(c *gin.Context) {
 				return
 			}
 
+			if apiToken, err := matchAPIToken(token); nil == err {
+				if authAPIToken(c, apiToken) {
+					c.Next()
+				}
+				return
+			} else if !errors.Is(err, ErrAPITokenNotFound) {
+				c.JSON(http.StatusUnauthorized, map[string]any{"code": -1, "msg": "Auth failed [header: Authorization]: " + err.Error()})
+				c.Abort()
+				return
+			}
+
 			c.JSON(http.StatusUnauthorized, map[string]any{"code": -1, "msg": "Auth failed [header: Authorization]"})
 			c.Abort()
 			return
@@ -249,6 +267,17 @@ func CheckAuth(c *gin.Context) {
 			return
 		}
 
+		if apiToken, err := matchAPIToken(token); nil == err {
+			if authAPIToken(c, apiToken) {
+				c.Next()
+			}
+			return
+		} else if !errors.Is(err, ErrAPITokenNotFound) {
+			c.JSON(http.StatusUnauthorized, map[string]any{"code": -1, "msg": "Auth failed [query: token]: " + err.Error()})
+			c.Abort()
+			return
+		}
+
 		c.JSON(http.StatusUnauthorized, map[string]any{"code": -1, "msg": "Auth failed [query: token]"})
 		c.Abort()
 		return
@@ -384,7 +413,8 @@ func CheckAuth(c *gin.Context) {
 }
 
 func CheckAdminRole(c *gin.Context) {
-	if IsAdminRoleContext(c) {
+	// 编辑者和读者角色的具名 API token 以及内核插件可以访问路由白名单中角色允许的路由，写操作由 CheckReadonly 继续拦截读者
+	if IsAdminRoleContext(c) || isAPITokenRoute(c) || isKernelPluginRoute(c) {
 		c.Next()
 	} else {
 		c.AbortWithStatus(http.StatusForbidden)
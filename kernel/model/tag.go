@@ -20,12 +20,14 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
 	"github.com/emirpasic/gods/sets/hashset"
+	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
@@ -117,6 +119,7 @@ func RemoveTag(label string) (err error) {
 	}
 
 	updateAttributeViewBlockText(updateNodes)
+	removeTagMeta(label)
 
 	sql.FlushQueue()
 	util.PushClearProgress()
@@ -222,12 +225,189 @@ func RenameTag(oldLabel, newLabel string) (err error) {
 	}
 
 	updateAttributeViewBlockText(updateNodes)
+	renameTagMeta(oldLabel, newLabel)
 
 	sql.FlushQueue()
 	util.PushClearProgress()
 	return
 }
 
+// TagMergeDoc 是合并标签时涉及的文档。
+type TagMergeDoc struct {
+	ID          string `json:"id"`
+	Box         string `json:"box"`
+	HPath       string `json:"hPath"`
+	Occurrences int    `json:"occurrences"`
+}
+
+// TagMergeResult 是合并标签的结果，预览时不写入文档。
+type TagMergeResult struct {
+	Labels      []string       `json:"labels"`
+	Target      string         `json:"target"`
+	Docs        []*TagMergeDoc `json:"docs"`
+	Occurrences int            `json:"occurrences"`
+	DryRun      bool           `json:"dryRun"`
+}
+
+// MergeTags 将多个标签（含其子标签）在所有笔记本中改写为目标标签，已解锁的加密笔记本一并处理。
+// dryRun 为 true 时只统计将要改写的文档和出现次数，不修改文档。合并后源标签的元数据并入目标标签。
+func MergeTags(labels []string, target string, dryRun bool) (ret *TagMergeResult, err error) {
+	target = normalizeTagLabel(target)
+	if "" == target {
+		return nil, errors.New(Conf.Language(114))
+	}
+	if invalidChar := treenode.ContainsMarker(target); "" != invalidChar {
+		return nil, fmt.Errorf(Conf.Language(112), invalidChar)
+	}
+
+	var sources []string
+	for _, label := range labels {
+		label = normalizeTagLabel(label)
+		if "" == label || label == target || slices.Contains(sources, label) {
+			continue
+		}
+		if strings.HasPrefix(target, label+"/") {
+			return nil, fmt.Errorf("cannot merge tag [%s] into its child tag [%s]", label, target)
+		}
+		sources = append(sources, label)
+	}
+	if 1 > len(sources) {
+		return nil, errors.New("no tags to merge")
+	}
+
+	FlushTxQueue()
+	sql.FlushQueue()
+
+	// 收集含有待合并标签的块，全局库之外还需要查询已解锁加密笔记本的独立库
+	type treeBlocks struct {
+		boxID    string
+		blockIDs []string
+	}
+	trees := map[string]*treeBlocks{}
+	var rootIDs []string
+	for _, boxID := range append([]string{""}, treenode.GetOpenedEncryptedBoxIDs()...) {
+		for _, label := range sources {
+			for _, span := range sql.QueryTagSpansByLabelInBox(label, boxID) {
+				tb := trees[span.RootID]
+				if nil == tb {
+					tb = &treeBlocks{boxID: boxID}
+					trees[span.RootID] = tb
+					rootIDs = append(rootIDs, span.RootID)
+				}
+				tb.blockIDs = append(tb.blockIDs, span.BlockID)
+			}
+		}
+	}
+
+	ret = &TagMergeResult{Labels: sources, Target: target, Docs: []*TagMergeDoc{}, DryRun: dryRun}
+	var historyDir string
+	if !dryRun {
+		util.PushEndlessProgress(Conf.Language(110))
+		if historyDir, err = getHistoryDir(HistoryOpReplace); nil != err {
+			util.PushClearProgress()
+			return
+		}
+	}
+
+	var reloadTreeIDs []string
+	updateNodes := map[string]*ast.Node{}
+	for _, rootID := range rootIDs {
+		tb := trees[rootID]
+		tree, e := LoadTreeByBlockIDWithReindexInBox(rootID, tb.boxID)
+		if nil != e {
+			if dryRun {
+				logging.LogWarnf("load tree [%s] failed: %s", rootID, e)
+				continue
+			}
+			util.ClearPushProgress(100)
+			return nil, e
+		}
+
+		occurrences := 0
+		for _, blockID := range gulu.Str.RemoveDuplicatedElem(tb.blockIDs) {
+			node := treenode.GetNodeInTree(tree, blockID)
+			if nil == node {
+				continue
+			}
+
+			if ast.NodeDocument == node.Type {
+				if docTagsVal := node.IALAttr("tags"); "" != docTagsVal {
+					var docTags []string
+					for _, docTag := range strings.Split(docTagsVal, ",") {
+						if merged, ok := mergeTagLabel(docTag, sources, target); ok {
+							docTag = merged
+							occurrences++
+						}
+						docTags = append(docTags, docTag)
+					}
+					node.SetIALAttr("tags", strings.Join(gulu.Str.RemoveDuplicatedElem(docTags), ","))
+				}
+				continue
+			}
+
+			for _, nodeTag := range node.ChildrenByType(ast.NodeTextMark) {
+				if !nodeTag.IsTextMarkType("tag") {
+					continue
+				}
+				if merged, ok := mergeTagLabel(nodeTag.TextMarkTextContent, sources, target); ok {
+					nodeTag.TextMarkTextContent = merged
+					occurrences++
+				}
+			}
+			updateNodes[node.ID] = node
+		}
+		if 1 > occurrences {
+			continue
+		}
+
+		ret.Docs = append(ret.Docs, &TagMergeDoc{ID: tree.ID, Box: tree.Box, HPath: tree.HPath, Occurrences: occurrences})
+		ret.Occurrences += occurrences
+		if dryRun {
+			continue
+		}
+
+		generateTreeHistory(tree, historyDir)
+		util.PushEndlessProgress(fmt.Sprintf(Conf.Language(111), util.EscapeHTML(tree.Root.IALAttr("title"))))
+		if err = writeTreeUpsertQueue(tree); err != nil {
+			util.ClearPushProgress(100)
+			return
+		}
+		reloadTreeIDs = append(reloadTreeIDs, tree.ID)
+	}
+	if dryRun {
+		return
+	}
+
+	indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+	sql.FlushQueue()
+
+	for _, id := range gulu.Str.RemoveDuplicatedElem(reloadTreeIDs) {
+		ReloadProtyle(id)
+	}
+
+	updateAttributeViewBlockText(updateNodes)
+	for _, label := range sources {
+		renameTagMeta(label, target)
+	}
+
+	sql.FlushQueue()
+	util.PushClearProgress()
+	return
+}
+
+// mergeTagLabel 将等于源标签或为源标签子标签的 label 改写为目标标签下的对应标签。
+func mergeTagLabel(label string, sources []string, target string) (string, bool) {
+	for _, source := range sources {
+		if label == source {
+			return target, true
+		}
+		if strings.HasPrefix(label, source+"/") {
+			return target + strings.TrimPrefix(label, source), true
+		}
+	}
+	return label, false
+}
+
 type TagBlocks []*Block
 
 func (s TagBlocks) Len() int           { return len(s) }
@@ -235,12 +415,16 @@ func (s TagBlocks) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s TagBlocks) Less(i, j int) bool { return s[i].ID < s[j].ID }
 
 type Tag struct {
-	Name     string `json:"name"`
-	Label    string `json:"label"`
-	Children Tags   `json:"children"`
-	Type     string `json:"type"` // "tag"
-	Depth    int    `json:"depth"`
-	Count    int    `json:"count"`
+	Name        string   `json:"name"`
+	Label       string   `json:"label"`
+	Children    Tags     `json:"children"`
+	Type        string   `json:"type"` // "tag"
+	Depth       int      `json:"depth"`
+	Count       int      `json:"count"`
+	Color       string   `json:"color,omitempty"`
+	Icon        string   `json:"icon,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
 
 	tags Tags
 }
@@ -252,13 +436,15 @@ func BuildTags(ignoreMaxListHintArg bool, appID string, sortVal int) (ret *Tags)
 	sql.FlushQueue()
 
 	ret = &Tags{}
-	labels := labelTags()
+	metas := GetTagMetas()
+	labels := labelTags(metas)
 	tags := Tags{}
 	for label := range labels {
 		tags = buildTags(tags, strings.Split(label, "/"), 0)
 	}
 	appendTagChildren(&tags, labels, sortVal)
 	sortTags(tags, sortVal)
+	appendTagMetas(tags, metas)
 
 	var total int
 	tmp := &Tags{}
@@ -277,6 +463,19 @@ func BuildTags(ignoreMaxListHintArg bool, appID string, sortVal int) (ret *Tags)
 	return
 }
 
+// appendTagMetas 为标签填充颜色、图标、描述和别名。
+func appendTagMetas(tags Tags, metas map[string]*TagMeta) {
+	for _, tag := range tags {
+		if meta := metas[util.UnescapeHTML(tag.Label)]; nil != meta {
+			tag.Color = meta.Color
+			tag.Icon = meta.Icon
+			tag.Description = meta.Description
+			tag.Aliases = meta.Aliases
+		}
+		appendTagMetas(tag.Children, metas)
+	}
+}
+
 func countTag(tag *Tag, total *int) {
 	*total += 1
 	for _, child := range tag.tags {
@@ -319,6 +518,18 @@ func SearchTags(keyword string) (ret []string) {
 	sql.FlushQueue()
 
 	labels := labelBlocksByKeyword(keyword)
+
+	// 关键字命中别名时同时返回规范标签
+	metas := GetTagMetas()
+	for label, meta := range metas {
+		for _, alias := range meta.Aliases {
+			if matchTagKeyword(alias, keyword) {
+				labels[label] = nil
+				break
+			}
+		}
+	}
+
 	keyword = strings.Join(strings.Split(keyword, " "), search.TermSep)
 	for label := range labels {
 		if "" == keyword {
@@ -333,6 +544,19 @@ func SearchTags(keyword string) (ret []string) {
 	return
 }
 
+func matchTagKeyword(label, keyword string) bool {
+	keywords := strings.Fields(keyword)
+	if 1 > len(keywords) {
+		return false
+	}
+	for _, k := range keywords {
+		if !strings.Contains(strings.ToLower(label), strings.ToLower(k)) {
+			return false
+		}
+	}
+	return true
+}
+
 func labelBlocksByKeyword(keyword string) (ret map[string]TagBlocks) {
 	ret = map[string]TagBlocks{}
 
@@ -374,12 +598,13 @@ func labelBlocksByKeyword(keyword string) (ret map[string]TagBlocks) {
 	return
 }
 
-func labelTags() (ret map[string]Tags) {
+// labelTags 统计各标签的出现次数，别名标签计入规范标签。
+func labelTags(metas map[string]*TagMeta) (ret map[string]Tags) {
 	ret = map[string]Tags{}
 
 	tagSpans := sql.QueryTagSpans("")
 	for _, tagSpan := range tagSpans {
-		label := util.UnescapeHTML(tagSpan.Content)
+		label := resolveTagAlias(util.UnescapeHTML(tagSpan.Content), metas)
 		if _, ok := ret[label]; ok {
 			ret[label] = append(ret[label], &Tag{})
 		} else {
@@ -0,0 +1,270 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// TagMeta 是标签的元数据，按标签名保存在 data/storage/tags.json 中，随数据同步。
+type TagMeta struct {
+	Color       string   `json:"color,omitempty"`
+	Icon        string   `json:"icon,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"` // 别名，在搜索和标签计数中解析为当前标签
+}
+
+func (meta *TagMeta) isEmpty() bool {
+	return nil == meta || ("" == meta.Color && "" == meta.Icon && "" == meta.Description && 1 > len(meta.Aliases))
+}
+
+var tagMetaLock = sync.Mutex{}
+
+func GetTagMetas() (ret map[string]*TagMeta) {
+	tagMetaLock.Lock()
+	defer tagMetaLock.Unlock()
+	return getTagMetas()
+}
+
+// SetTagMeta 设置标签的元数据，元数据为空时移除。
+// 别名不能与其它标签的别名重复，也不能是已经设置了别名的标签，避免别名链。
+func SetTagMeta(label string, meta *TagMeta) (err error) {
+	label = normalizeTagLabel(label)
+	if "" == label {
+		return errors.New(Conf.Language(114))
+	}
+
+	tagMetaLock.Lock()
+	defer tagMetaLock.Unlock()
+
+	metas := getTagMetas()
+	if meta.isEmpty() {
+		if _, ok := metas[label]; !ok {
+			return
+		}
+		delete(metas, label)
+		return setTagMetas(metas)
+	}
+
+	var aliases []string
+	for _, alias := range meta.Aliases {
+		alias = normalizeTagLabel(alias)
+		if "" == alias || alias == label || slices.Contains(aliases, alias) {
+			continue
+		}
+		if invalidChar := treenode.ContainsMarker(alias); "" != invalidChar {
+			return fmt.Errorf(Conf.Language(112), invalidChar)
+		}
+		if m := metas[alias]; nil != m && 0 < len(m.Aliases) {
+			return fmt.Errorf("tag [%s] has its own aliases and cannot be an alias", alias)
+		}
+		for other, m := range metas {
+			if other != label && slices.Contains(m.Aliases, alias) {
+				return fmt.Errorf("alias [%s] is already used by tag [%s]", alias, other)
+			}
+		}
+		aliases = append(aliases, alias)
+	}
+	if 0 < len(aliases) {
+		for other, m := range metas {
+			if other != label && slices.Contains(m.Aliases, label) {
+				return fmt.Errorf("tag [%s] is an alias of tag [%s] and cannot have aliases", label, other)
+			}
+		}
+	}
+	sort.Strings(aliases)
+
+	metas[label] = &TagMeta{
+		Color:       strings.TrimSpace(meta.Color),
+		Icon:        strings.TrimSpace(meta.Icon),
+		Description: strings.TrimSpace(meta.Description),
+		Aliases:     aliases,
+	}
+	return setTagMetas(metas)
+}
+
+// renameTagMeta 在重命名标签后迁移元数据，子标签的元数据一并迁移。
+func renameTagMeta(oldLabel, newLabel string) {
+	tagMetaLock.Lock()
+	defer tagMetaLock.Unlock()
+
+	metas := getTagMetas()
+	changed := false
+	for label, meta := range metas {
+		if label != oldLabel && !strings.HasPrefix(label, oldLabel+"/") {
+			continue
+		}
+
+		delete(metas, label)
+		newTagLabel := newLabel + strings.TrimPrefix(label, oldLabel)
+		if existing := metas[newTagLabel]; nil != existing {
+			mergeTagMeta(existing, meta)
+		} else {
+			metas[newTagLabel] = meta
+		}
+		changed = true
+	}
+	if changed {
+		setTagMetas(metas)
+	}
+}
+
+// removeTagMeta 在删除标签后移除元数据。
+func removeTagMeta(label string) {
+	tagMetaLock.Lock()
+	defer tagMetaLock.Unlock()
+
+	metas := getTagMetas()
+	if _, ok := metas[label]; !ok {
+		return
+	}
+	delete(metas, label)
+	setTagMetas(metas)
+}
+
+// mergeTagMeta 将 from 的元数据合并到 to 中，to 已有的颜色、图标和描述保持不变，别名取并集。
+func mergeTagMeta(to, from *TagMeta) {
+	if "" == to.Color {
+		to.Color = from.Color
+	}
+	if "" == to.Icon {
+		to.Icon = from.Icon
+	}
+	if "" == to.Description {
+		to.Description = from.Description
+	}
+	to.Aliases = append(to.Aliases, from.Aliases...)
+	to.Aliases = gulu.Str.RemoveDuplicatedElem(to.Aliases)
+	sort.Strings(to.Aliases)
+}
+
+// resolveTagAlias 将别名标签解析为规范标签，别名的子标签同样解析，如别名 js 的子标签 js/react 解析为 javascript/react。
+func resolveTagAlias(label string, metas map[string]*TagMeta) string {
+	var canonical, matched string
+	for l, meta := range metas {
+		for _, alias := range meta.Aliases {
+			if (label == alias || strings.HasPrefix(label, alias+"/")) && len(alias) > len(matched) {
+				canonical, matched = l, alias
+			}
+		}
+	}
+	if "" == matched {
+		return label
+	}
+	return canonical + strings.TrimPrefix(label, matched)
+}
+
+// tagAliasLabels 返回标签解析后的规范标签及其全部别名，规范标签位于第一个。子标签同样展开上级标签的别名。
+func tagAliasLabels(label string, metas map[string]*TagMeta) (ret []string) {
+	label = resolveTagAlias(label, metas)
+	ret = append(ret, label)
+	for l, meta := range metas {
+		if label != l && !strings.HasPrefix(label, l+"/") {
+			continue
+		}
+		for _, alias := range meta.Aliases {
+			ret = append(ret, alias+strings.TrimPrefix(label, l))
+		}
+	}
+	sort.Strings(ret[1:])
+	return
+}
+
+// tagAliasQuery 将形如 #标签# 的搜索关键字展开为规范标签及其别名的 FTS OR 查询，其他关键字按原样转换。
+func tagAliasQuery(query string, metas map[string]*TagMeta) string {
+	trimmed := strings.TrimSpace(query)
+	if 3 > len(trimmed) || !strings.HasPrefix(trimmed, "#") || !strings.HasSuffix(trimmed, "#") {
+		return stringQuery(query)
+	}
+
+	labels := tagAliasLabels(trimmed[1:len(trimmed)-1], metas)
+	if 2 > len(labels) {
+		return stringQuery(query)
+	}
+
+	var parts []string
+	for _, label := range labels {
+		parts = append(parts, stringQuery("#"+label+"#"))
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func normalizeTagLabel(label string) string {
+	label = strings.TrimSpace(label)
+	label = strings.TrimPrefix(label, "#")
+	label = strings.TrimSuffix(label, "#")
+	label = strings.Trim(label, "/")
+	return strings.TrimSpace(label)
+}
+
+func getTagMetas() (ret map[string]*TagMeta) {
+	ret = map[string]*TagMeta{}
+	dataPath := filepath.Join(util.DataDir, "storage", "tags.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read storage [tags] failed: %s", err)
+		return
+	}
+
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal storage [tags] failed: %s", err)
+		return
+	}
+	for label, meta := range ret {
+		if nil == meta {
+			delete(ret, label)
+		}
+	}
+	return
+}
+
+func setTagMetas(metas map[string]*TagMeta) (err error) {
+	dirPath := filepath.Join(util.DataDir, "storage")
+	if err = os.MkdirAll(dirPath, 0755); err != nil {
+		logging.LogErrorf("create storage [tags] dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(metas, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal storage [tags] failed: %s", err)
+		return
+	}
+
+	if err = filelock.WriteFile(filepath.Join(dirPath, "tags.json"), data); err != nil {
+		logging.LogErrorf("write storage [tags] failed: %s", err)
+		return
+	}
+	return
+}
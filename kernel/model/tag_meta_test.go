@@ -0,0 +1,92 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func testTagMetas() map[string]*TagMeta {
+	return map[string]*TagMeta{
+		"javascript":   {Aliases: []string{"js", "ecmascript"}},
+		"javascript/x": {Color: "red"},
+		"frontend":     {Aliases: []string{"js/ui"}},
+	}
+}
+
+func TestResolveTagAlias(t *testing.T) {
+	metas := testTagMetas()
+	cases := map[string]string{
+		"js":            "javascript",
+		"ecmascript":    "javascript",
+		"js/react":      "javascript/react",
+		"js/ui":         "frontend",
+		"js/ui/button":  "frontend/button",
+		"jsx":           "jsx",
+		"javascript":    "javascript",
+		"ecmascript/es": "javascript/es",
+	}
+	for label, expected := range cases {
+		if got := resolveTagAlias(label, metas); got != expected {
+			t.Fatalf("解析标签 [%s] 应为 [%s]，实际为 [%s]", label, expected, got)
+		}
+	}
+}
+
+func TestTagAliasLabels(t *testing.T) {
+	metas := testTagMetas()
+	if got := tagAliasLabels("js", metas); !slices.Equal(got, []string{"javascript", "ecmascript", "js"}) {
+		t.Fatalf("别名展开错误：%v", got)
+	}
+	if got := tagAliasLabels("javascript/react", metas); !slices.Equal(got, []string{"javascript/react", "ecmascript/react", "js/react"}) {
+		t.Fatalf("子标签别名展开错误：%v", got)
+	}
+	if got := tagAliasLabels("go", metas); !slices.Equal(got, []string{"go"}) {
+		t.Fatalf("无别名标签展开错误：%v", got)
+	}
+}
+
+func TestTagAliasQuery(t *testing.T) {
+	metas := testTagMetas()
+	if got := tagAliasQuery("#js#", metas); `"#javascript#" OR "#ecmascript#" OR "#js#"` != got {
+		t.Fatalf("标签别名查询错误：%s", got)
+	}
+	if got := tagAliasQuery("#go#", metas); `"#go#"` != got {
+		t.Fatalf("无别名标签查询错误：%s", got)
+	}
+	if got := tagAliasQuery("js", metas); `"js"` != got {
+		t.Fatalf("普通关键字查询错误：%s", got)
+	}
+}
+
+func TestMergeTagLabel(t *testing.T) {
+	sources := []string{"js", "ecmascript"}
+	cases := map[string]string{
+		"js":            "javascript",
+		"js/react":      "javascript/react",
+		"ecmascript/es": "javascript/es",
+	}
+	for label, expected := range cases {
+		if got, ok := mergeTagLabel(label, sources, "javascript"); !ok || got != expected {
+			t.Fatalf("合并标签 [%s] 应为 [%s]，实际为 [%s]", label, expected, got)
+		}
+	}
+	if _, ok := mergeTagLabel("jsx", sources, "javascript"); ok {
+		t.Fatalf("标签 [jsx] 不应被合并")
+	}
+}
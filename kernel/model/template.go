@@ -343,7 +343,70 @@ func RenderDynamicIconContentTemplate(content, id string) (ret string) {
 	return
 }
 
-func RenderTemplate(p, id string, preview bool) (tree *parse.Tree, dom string, err error) {
+// RenderTemplateMarkdown 以预览方式渲染模板并返回标准 Markdown。id 为空时不注入 .action{.title} 等块相关变量。
+// params 为模板声明的输入参数的值。
+func RenderTemplateMarkdown(p, id string, params map[string]string) (ret string, err error) {
+	if "" != id {
+		tree, _, renderErr := RenderTemplate(p, id, params, true)
+		if nil != renderErr {
+			return "", renderErr
+		}
+		return treenode.ExportNodeStdMd(tree.Root, NewLute()), nil
+	}
+
+	md, err := os.ReadFile(p)
+	if err != nil {
+		return
+	}
+	md, dataModel, err := templateParamsDataModel(md, params)
+	if err != nil {
+		return
+	}
+	if md, err = executeTemplate(md, dataModel); err != nil {
+		return
+	}
+	tree := parseKTree(md)
+	if nil == tree {
+		err = fmt.Errorf("parse tree [%s] failed", p)
+		return
+	}
+	return treenode.ExportNodeStdMd(tree.Root, NewLute()), nil
+}
+
+// executeTemplate 使用 .action{} 分隔符执行模板。
+func executeTemplate(md []byte, dataModel map[string]string) (ret []byte, err error) {
+	goTpl := template.New("").Delims(".action{", "}")
+	tplFuncMap := filesys.BuiltInTemplateFuncs()
+	sql.SQLTemplateFuncs(&tplFuncMap)
+	goTpl = goTpl.Funcs(tplFuncMap)
+	tpl, err := goTpl.Funcs(tplFuncMap).Parse(gulu.Str.FromBytes(md))
+	if err != nil {
+		err = fmt.Errorf(Conf.Language(44), err.Error())
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Grow(4096)
+	if err = tpl.Execute(buf, dataModel); err != nil {
+		err = fmt.Errorf(Conf.Language(44), err.Error())
+		return
+	}
+	ret = buf.Bytes()
+	return
+}
+
+// templateParamsDataModel 解析模板的参数声明，返回去掉声明后的模板内容和包含参数值的数据模型。
+func templateParamsDataModel(md []byte, values map[string]string) (body []byte, dataModel map[string]string, err error) {
+	params, body, err := parseTemplateParams(md)
+	if err != nil {
+		return
+	}
+	dataModel, err = resolveTemplateParams(params, values, time.Now())
+	return
+}
+
+// RenderTemplate 渲染模板，params 为模板声明的输入参数的值，未声明参数的模板传入 nil 即可。
+func RenderTemplate(p, id string, params map[string]string, preview bool) (tree *parse.Tree, dom string, err error) {
 	tree, err = LoadTreeByBlockID(id)
 	if err != nil {
 		return
@@ -360,7 +423,10 @@ func RenderTemplate(p, id string, preview bool) (tree *parse.Tree, dom string, e
 		return
 	}
 
-	dataModel := map[string]string{}
+	md, dataModel, err := templateParamsDataModel(md, params)
+	if err != nil {
+		return
+	}
 	var titleVar string
 	if nil != block {
 		titleVar = block.Name
@@ -373,23 +439,9 @@ func RenderTemplate(p, id string, preview bool) (tree *parse.Tree, dom string, e
 		dataModel["alias"] = block.Alias
 	}
 
-	goTpl := template.New("").Delims(".action{", "}")
-	tplFuncMap := filesys.BuiltInTemplateFuncs()
-	sql.SQLTemplateFuncs(&tplFuncMap)
-	goTpl = goTpl.Funcs(tplFuncMap)
-	tpl, err := goTpl.Funcs(tplFuncMap).Parse(gulu.Str.FromBytes(md))
-	if err != nil {
-		err = fmt.Errorf(Conf.Language(44), err.Error())
-		return
-	}
-
-	buf := &bytes.Buffer{}
-	buf.Grow(4096)
-	if err = tpl.Execute(buf, dataModel); err != nil {
-		err = fmt.Errorf(Conf.Language(44), err.Error())
+	if md, err = executeTemplate(md, dataModel); err != nil {
 		return
 	}
-	md = buf.Bytes()
 	tree = parseKTree(md)
 	if nil == tree {
 		msg := fmt.Sprintf("parse tree [%s] failed", p)
@@ -0,0 +1,227 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"gopkg.in/yaml.v3"
+)
+
+// 模板参数类型
+const (
+	TemplateParamTypeText   = "text"   // 文本
+	TemplateParamTypeDate   = "date"   // 日期，格式为 2006-01-02
+	TemplateParamTypeSelect = "select" // 从 options 中选择
+	TemplateParamTypeBlock  = "block"  // 块引用，值为块 ID
+	TemplateParamTypeRow    = "row"    // 数据库行，值为 av 指定数据库中的项目 ID
+)
+
+// TemplateParam 描述了模板声明的输入参数。
+//
+// 参数声明在模板开头的 YAML Front Matter 中，渲染时通过 .action{.参数名} 引用：
+//
+//	---
+//	params:
+//	  - name: topic
+//	    type: text
+//	    required: true
+//	  - name: date
+//	    type: date
+//	    default: today
+//	---
+type TemplateParam struct {
+	Name        string   `json:"name" yaml:"name"`
+	Type        string   `json:"type" yaml:"type"`
+	Label       string   `json:"label,omitempty" yaml:"label"`
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Required    bool     `json:"required,omitempty" yaml:"required"`
+	Default     string   `json:"default,omitempty" yaml:"default"`
+	Options     []string `json:"options,omitempty" yaml:"options"` // select 的可选值
+	AV          string   `json:"av,omitempty" yaml:"av"`           // row 所属的数据库 ID
+}
+
+var (
+	templateParamNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// 模板内置变量，参数不能与之同名
+	templateReservedVars = []string{"title", "id", "name", "alias"}
+)
+
+// GetTemplateParams 返回模板声明的输入参数，没有声明时返回空列表。
+func GetTemplateParams(p string) (ret []*TemplateParam, err error) {
+	md, err := os.ReadFile(p)
+	if err != nil {
+		return
+	}
+
+	ret, _, err = parseTemplateParams(md)
+	if nil == ret {
+		ret = []*TemplateParam{}
+	}
+	return
+}
+
+// parseTemplateParams 解析模板开头的参数声明并返回去掉声明后的模板内容。
+// 模板没有 Front Matter、Front Matter 不是 YAML 映射或其中没有 params 时原样返回模板内容。
+func parseTemplateParams(md []byte) (params []*TemplateParam, body []byte, err error) {
+	body = md
+	content := bytes.TrimPrefix(md, []byte("\xef\xbb\xbf"))
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(content, []byte("---\n")) {
+		return
+	}
+
+	rest := content[len("---\n"):]
+	end := bytes.Index(rest, []byte("\n---"))
+	if 0 > end {
+		return
+	}
+	after := rest[end+len("\n---"):]
+	if 0 < len(after) && '\n' != after[0] {
+		return
+	}
+
+	// 模板也可能以分隔线开头，只有能解析为包含 params 键的 YAML 映射时才视为参数声明
+	var doc yaml.Node
+	if nil != yaml.Unmarshal(rest[:end], &doc) || 1 > len(doc.Content) || yaml.MappingNode != doc.Content[0].Kind {
+		return
+	}
+	mapping := doc.Content[0]
+	hasParams := false
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if "params" == mapping.Content[i].Value {
+			hasParams = true
+			break
+		}
+	}
+	if !hasParams {
+		return
+	}
+
+	header := struct {
+		Params []*TemplateParam `yaml:"params"`
+	}{}
+	if err = mapping.Decode(&header); err != nil {
+		err = fmt.Errorf("parse template params failed: %s", err)
+		return
+	}
+	if nil == header.Params {
+		return
+	}
+
+	names := map[string]bool{}
+	for _, param := range header.Params {
+		if nil == param || !templateParamNameRegexp.MatchString(param.Name) {
+			err = fmt.Errorf("invalid template param name [%v]", param)
+			return
+		}
+		if slices.Contains(templateReservedVars, param.Name) {
+			err = fmt.Errorf("template param name [%s] is reserved", param.Name)
+			return
+		}
+		if names[param.Name] {
+			err = fmt.Errorf("duplicated template param [%s]", param.Name)
+			return
+		}
+		names[param.Name] = true
+
+		if "" == param.Type {
+			param.Type = TemplateParamTypeText
+		}
+		switch param.Type {
+		case TemplateParamTypeText, TemplateParamTypeDate, TemplateParamTypeBlock:
+		case TemplateParamTypeSelect:
+			if 1 > len(param.Options) {
+				err = fmt.Errorf("template param [%s] requires options", param.Name)
+				return
+			}
+		case TemplateParamTypeRow:
+			if !ast.IsNodeIDPattern(param.AV) {
+				err = fmt.Errorf("template param [%s] requires a database ID", param.Name)
+				return
+			}
+		default:
+			err = fmt.Errorf("unknown type [%s] of template param [%s]", param.Type, param.Name)
+			return
+		}
+	}
+
+	params = header.Params
+	body = bytes.TrimLeft(after, "\n")
+	return
+}
+
+// resolveTemplateParams 校验参数值并填充默认值，返回可以合并到模板数据模型中的参数值。
+func resolveTemplateParams(params []*TemplateParam, values map[string]string, now time.Time) (ret map[string]string, err error) {
+	ret = map[string]string{}
+	for _, param := range params {
+		value := values[param.Name]
+		if "" == value {
+			value = param.Default
+			if TemplateParamTypeDate == param.Type && ("today" == value || "now" == value) {
+				value = now.Format("2006-01-02")
+			}
+		}
+		if "" == value {
+			if param.Required {
+				err = fmt.Errorf("template param [%s] is required", param.Name)
+				return
+			}
+			ret[param.Name] = ""
+			continue
+		}
+
+		switch param.Type {
+		case TemplateParamTypeDate:
+			if _, parseErr := time.ParseInLocation("2006-01-02", value, time.Local); nil != parseErr {
+				err = fmt.Errorf("template param [%s] requires a date like 2006-01-02: %s", param.Name, value)
+				return
+			}
+		case TemplateParamTypeSelect:
+			if !slices.Contains(param.Options, value) {
+				err = fmt.Errorf("template param [%s] must be one of %v: %s", param.Name, param.Options, value)
+				return
+			}
+		case TemplateParamTypeBlock:
+			if !ast.IsNodeIDPattern(value) || nil == treenode.GetBlockTree(value) {
+				err = fmt.Errorf("template param [%s] refers to a nonexistent block: %s", param.Name, value)
+				return
+			}
+		case TemplateParamTypeRow:
+			attrView, parseErr := av.ParseAttributeView(param.AV)
+			if nil != parseErr {
+				err = fmt.Errorf("template param [%s] refers to a nonexistent database: %s", param.Name, param.AV)
+				return
+			}
+			if !ast.IsNodeIDPattern(value) || nil == attrView.GetBlockValue(value) {
+				err = fmt.Errorf("template param [%s] refers to a nonexistent database row: %s", param.Name, value)
+				return
+			}
+		}
+		ret[param.Name] = value
+	}
+	return
+}
@@ -0,0 +1,101 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTemplateParams(t *testing.T) {
+	md := "---\r\nparams:\r\n  - name: topic\r\n    required: true\r\n  - name: day\r\n    type: date\r\n    default: today\r\n  - name: kind\r\n    type: select\r\n    options: [sync, review]\r\n---\r\n# .action{.topic}\r\n"
+	params, body, err := parseTemplateParams([]byte(md))
+	if err != nil {
+		t.Fatalf("解析模板参数失败：%s", err)
+	}
+	if 3 != len(params) || "topic" != params[0].Name || TemplateParamTypeText != params[0].Type || !params[0].Required {
+		t.Fatalf("模板参数解析错误：%+v", params)
+	}
+	if "# .action{.topic}\n" != string(body) {
+		t.Fatalf("模板内容应去掉参数声明：%q", body)
+	}
+
+	plain := "---\ntitle: foo\n---\n# bar\n"
+	params, body, err = parseTemplateParams([]byte(plain))
+	if err != nil || nil != params || plain != string(body) {
+		t.Fatalf("没有参数声明的模板应原样返回：%v %q %v", params, body, err)
+	}
+
+	invalids := []string{
+		"---\nparams:\n  - name: title\n---\n",
+		"---\nparams:\n  - name: a-b\n---\n",
+		"---\nparams:\n  - name: a\n  - name: a\n---\n",
+		"---\nparams:\n  - name: a\n    type: select\n---\n",
+		"---\nparams:\n  - name: a\n    type: row\n---\n",
+		"---\nparams:\n  - name: a\n    type: color\n---\n",
+	}
+	for _, invalid := range invalids {
+		if _, _, err = parseTemplateParams([]byte(invalid)); nil == err {
+			t.Fatalf("非法参数声明应报错：%q", invalid)
+		}
+	}
+}
+
+func TestParseTemplateParamsThematicBreak(t *testing.T) {
+	// 以分隔线开头的普通模板不应被当作参数声明
+	bodies := []string{
+		"---\n纯文本段落\n---\n# 标题\n",
+		"---\n- 列表项一\n- 列表项二\n---\n",
+		"---\n标题：会议\n\n---\n",
+		"---\n: [不是 YAML\n---\n",
+	}
+	for _, md := range bodies {
+		params, body, err := parseTemplateParams([]byte(md))
+		if err != nil || nil != params || md != string(body) {
+			t.Fatalf("以分隔线开头的模板应原样返回：%q %v %q %v", md, params, body, err)
+		}
+	}
+}
+
+func TestResolveTemplateParams(t *testing.T) {
+	params := []*TemplateParam{
+		{Name: "topic", Type: TemplateParamTypeText, Required: true},
+		{Name: "day", Type: TemplateParamTypeDate, Default: "today"},
+		{Name: "kind", Type: TemplateParamTypeSelect, Options: []string{"sync", "review"}, Default: "sync"},
+		{Name: "note", Type: TemplateParamTypeText},
+	}
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.Local)
+
+	values, err := resolveTemplateParams(params, map[string]string{"topic": "Plan"}, now)
+	if err != nil {
+		t.Fatalf("解析参数值失败：%s", err)
+	}
+	if "Plan" != values["topic"] || "2026-03-04" != values["day"] || "sync" != values["kind"] || "" != values["note"] {
+		t.Fatalf("参数值或默认值错误：%v", values)
+	}
+
+	if _, err = resolveTemplateParams(params, nil, now); nil == err || !strings.Contains(err.Error(), "topic") {
+		t.Fatalf("缺少必填参数应报错：%v", err)
+	}
+	if _, err = resolveTemplateParams(params, map[string]string{"topic": "a", "day": "03/04/2026"}, now); nil == err {
+		t.Fatalf("日期格式错误应报错")
+	}
+	if _, err = resolveTemplateParams(params, map[string]string{"topic": "a", "kind": "other"}, now); nil == err {
+		t.Fatalf("选项之外的值应报错")
+	}
+}
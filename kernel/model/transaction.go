@@ -34,6 +34,7 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/editor"
 	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/av"
@@ -338,6 +339,8 @@ func performTx(tx *Transaction) (ret *TxErr) {
 				ret = tx.doReplaceAttrViewBlock(op)
 			case "updateAttrViewColTemplate":
 				ret = tx.doUpdateAttrViewColTemplate(op)
+			case "updateAttrViewColFormula":
+				ret = tx.doUpdateAttrViewColFormula(op)
 			case "addAttrViewView":
 				ret = tx.doAddAttrViewView(op)
 			case "removeAttrViewView":
@@ -392,6 +395,16 @@ func performTx(tx *Transaction) (ret *TxErr) {
 				ret = tx.doSetAttrViewBlockView(op)
 			case "setAttrViewCardAspectRatio":
 				ret = tx.doSetAttrViewCardAspectRatio(op)
+			case "setAttrViewStartDateKeyID":
+				ret = tx.doSetAttrViewStartDateKeyID(op)
+			case "setAttrViewEndDateKeyID":
+				ret = tx.doSetAttrViewEndDateKeyID(op)
+			case "setAttrViewCalendarMode":
+				ret = tx.doSetAttrViewCalendarMode(op)
+			case "setAttrViewFirstDayOfWeek":
+				ret = tx.doSetAttrViewFirstDayOfWeek(op)
+			case "setAttrViewTimelineScale":
+				ret = tx.doSetAttrViewTimelineScale(op)
 			case "setAttrViewGroup":
 				ret = tx.doSetAttrViewGroup(op)
 			case "hideAttrViewGroup":
@@ -2007,13 +2020,16 @@ func (tx *Transaction) doSetAttrs(operation *Operation) (ret *TxErr) {
 		}
 	}
 
-	if _, setErr := setNodeAttrs0(node, attrs, tree.Box); nil != setErr {
+	oldAttrs, setErr := setNodeAttrs0(node, attrs, tree.Box)
+	if nil != setErr {
 		logging.LogErrorf("set attrs failed: %s", setErr)
 		return &TxErr{code: TxErrCodePushMsg, msg: setErr.Error(), id: id}
 	}
 
 	tx.writeTree(tree)
-	cache.PutBlockIALInBox(id, tree.Box, parse.IAL2Map(node.KramdownIAL))
+	newAttrs := parse.IAL2Map(node.KramdownIAL)
+	cache.PutBlockIALInBox(id, tree.Box, newAttrs)
+	tx.updatedAttrs = append(tx.updatedAttrs, &txUpdatedAttrs{id: id, oldAttrs: oldAttrs, newAttrs: newAttrs})
 	return
 }
 
@@ -2063,6 +2079,7 @@ type Transaction struct {
 	relatedAvIDs   []string               // 事务中变更的属性视图 ID
 	changedRootIDs []string               // 变更的树 ID 列表（包含了变更定义块后影响的动态锚文本所在的树）
 	boxIcons       map[string]string      // 事务提交后需要同步的笔记本图标
+	updatedAttrs   []*txUpdatedAttrs      // 事务提交后需要发布更新事件的块属性
 
 	isGlobalAssetsInit  bool   // 是否初始化过全局资源判断
 	isGlobalAssets      bool   // 是否属于全局资源
@@ -2078,6 +2095,12 @@ type Transaction struct {
 	state      atomic.Int32 // 0: 初始化，1：未提交，:2: 已提交，3: 已回滚
 }
 
+// txUpdatedAttrs 记录事务中一次块属性设置前后的属性。
+type txUpdatedAttrs struct {
+	id                 string
+	oldAttrs, newAttrs map[string]string
+}
+
 func (tx *Transaction) GetChangedRootIDs() (ret []string) {
 	for t := range tx.trees {
 		ret = append(ret, t)
@@ -2090,6 +2113,36 @@ func (tx *Transaction) GetChangedRootIDs() (ret []string) {
 	return
 }
 
+// GetChangedBlockIDs 返回事务操作涉及的块 ID，包括已删除的块。
+func (tx *Transaction) GetChangedBlockIDs() (ret []string) {
+	for id := range tx.nodes {
+		ret = append(ret, id)
+	}
+	for _, op := range tx.DoOperations {
+		if "" != op.ID {
+			ret = append(ret, op.ID)
+		}
+		if "" != op.BlockID {
+			ret = append(ret, op.BlockID)
+		}
+		ret = append(ret, op.BlockIDs...)
+	}
+	ret = gulu.Str.RemoveDuplicatedElem(ret)
+	return
+}
+
+// GetChangedAvIDs 返回事务操作涉及的属性视图 ID。
+func (tx *Transaction) GetChangedAvIDs() (ret []string) {
+	ret = append(ret, tx.relatedAvIDs...)
+	for _, op := range tx.DoOperations {
+		if "" != op.AvID {
+			ret = append(ret, op.AvID)
+		}
+	}
+	ret = gulu.Str.RemoveDuplicatedElem(ret)
+	return
+}
+
 // MarkFromAPI 标记事务来自 /api/transactions HTTP 入口，供全局撤销日志捕获判别。
 func (tx *Transaction) MarkFromAPI() {
 	tx.fromAPI = true
@@ -2124,6 +2177,7 @@ func (tx *Transaction) begin() (err error) {
 	tx.trees = map[string]*parse.Tree{}
 	tx.nodes = map[string]*ast.Node{}
 	tx.boxIcons = map[string]string{}
+	tx.updatedAttrs = nil
 	tx.removedCreatedDocs = nil
 	tx.restoredCreatedDocs = nil
 	tx.luteEngine = util.NewLute()
@@ -2193,11 +2247,21 @@ func (tx *Transaction) commit() (err error) {
 	// 已提交且 trees 稳定后记录到全局撤销日志（rollback 不记录）
 	GlobalUndoLog.Record(tx)
 	tx.m.Unlock()
+	eventbus.Publish(util.EvtTxCommitted, tx.GetChangedRootIDs(), tx.GetChangedBlockIDs(), tx.GetChangedAvIDs())
+	tx.publishUpdatedAttrs()
 	return
 }
 
+// publishUpdatedAttrs 发布事务中更新的块属性事件，需要在事务提交后调用。
+func (tx *Transaction) publishUpdatedAttrs() {
+	for _, updated := range tx.updatedAttrs {
+		eventbus.Publish(util.EvtBlockAttrsUpdated, updated.id, updated.oldAttrs, updated.newAttrs)
+	}
+	tx.updatedAttrs = nil
+}
+
 func (tx *Transaction) rollback() {
-	tx.trees, tx.nodes, tx.boxIcons, tx.removedCreatedDocs, tx.restoredCreatedDocs = nil, nil, nil, nil, nil
+	tx.trees, tx.nodes, tx.boxIcons, tx.updatedAttrs, tx.removedCreatedDocs, tx.restoredCreatedDocs = nil, nil, nil, nil, nil, nil
 	tx.state.Store(3)
 	tx.m.Unlock()
 	return
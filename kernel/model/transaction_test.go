@@ -16,7 +16,13 @@
 
 package model
 
-import "testing"
+import (
+	"sync"
+	"testing"
+
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
 
 func TestDoUpdateRejectsInvalidData(t *testing.T) {
 	tests := []any{nil, 1, ""}
@@ -40,3 +46,60 @@ func TestTxErrFromPanic(t *testing.T) {
 		t.Fatal("expected a committed transaction panic to preserve the committed result")
 	}
 }
+
+func TestSetAttrsTxPublishesBlockAttrsUpdated(t *testing.T) {
+	fixture := setupFileOperationTest(t)
+
+	var lock sync.Mutex
+	var published []map[string]string
+	eventbus.Subscribe(util.EvtBlockAttrsUpdated, func(id string, oldAttrs, newAttrs map[string]string) {
+		if id != fixture.childID {
+			return
+		}
+		lock.Lock()
+		published = append(published, newAttrs)
+		lock.Unlock()
+	})
+	publishedCount := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(published)
+	}
+
+	tx := &Transaction{m: &sync.Mutex{}}
+	if err := tx.begin(); nil != err {
+		t.Fatalf("begin transaction failed: %s", err)
+	}
+	if err := tx.doSetAttrs(&Operation{Action: "setAttrs", ID: fixture.childID, Data: `{"custom-status":"done"}`}); nil != err {
+		tx.rollback()
+		t.Fatalf("set attrs in transaction failed: %s", err.Error())
+	}
+	if 0 != publishedCount() {
+		t.Fatal("expected block attrs updated event to wait for the transaction commit")
+	}
+
+	// 提交时的写库依赖数据库队列，这里只执行提交后的发布步骤
+	tx.state.Store(2)
+	tx.m.Unlock()
+	tx.publishUpdatedAttrs()
+	if 1 != publishedCount() {
+		t.Fatalf("expected one block attrs updated event, got [%d]", publishedCount())
+	}
+	if "done" != published[0]["custom-status"] {
+		t.Fatalf("expected the event to carry the new attrs, got [%v]", published[0])
+	}
+
+	tx = &Transaction{m: &sync.Mutex{}}
+	if err := tx.begin(); nil != err {
+		t.Fatalf("begin transaction failed: %s", err)
+	}
+	if err := tx.doSetAttrs(&Operation{Action: "setAttrs", ID: fixture.childID, Data: `{"custom-status":"todo"}`}); nil != err {
+		tx.rollback()
+		t.Fatalf("set attrs in transaction failed: %s", err.Error())
+	}
+	tx.rollback()
+	tx.publishUpdatedAttrs()
+	if 1 != publishedCount() {
+		t.Fatal("expected a rolled back transaction not to publish block attrs updated events")
+	}
+}
@@ -70,9 +70,10 @@ type UndoLog struct {
 	mu     sync.Mutex
 	stacks map[string]*undoStack
 	max    int
+	dirty  bool // 自上次持久化后是否有变更
 }
 
-// GlobalUndoLog 全局撤销日志单例。内存态，重启清空。
+// GlobalUndoLog 全局撤销日志单例。定时持久化到工作空间临时目录，启动时恢复，详见 undolog_persist.go。
 var GlobalUndoLog = newUndoLog(64)
 
 var undoEntrySeq uint64
@@ -120,6 +121,7 @@ func (l *UndoLog) Record(tx *Transaction) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	l.dirty = true
 	entry := &UndoEntry{
 		id:             newUndoEntryID(),
 		doOperations:   cloneOperations(tx.DoOperations),
@@ -165,6 +167,7 @@ func (l *UndoLog) Undo(rootID string) *UndoEntry {
 		return nil
 	}
 
+	l.dirty = true
 	entry := s.undoStack[len(s.undoStack)-1]
 	s.undoStack = s.undoStack[:len(s.undoStack)-1]
 	// 只压入执行撤销的栈，符合语义 B：在 B 按 Ctrl+Y 不重做这条
@@ -184,6 +187,7 @@ func (l *UndoLog) UndoCommit(entry *UndoEntry, rootID string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	l.dirty = true
 	for _, r := range entry.mutatedRootIDs {
 		if r == rootID {
 			continue
@@ -205,6 +209,7 @@ func (l *UndoLog) UndoRollback(entry *UndoEntry, rootID string) {
 	if nil == s {
 		return
 	}
+	l.dirty = true
 	// 从执行栈重做栈顶移除 entry（Undo 压入的）
 	if 0 < len(s.redoStack) && s.redoStack[len(s.redoStack)-1].id == entry.id {
 		s.redoStack = s.redoStack[:len(s.redoStack)-1]
@@ -225,6 +230,7 @@ func (l *UndoLog) Redo(rootID string) *UndoEntry {
 		return nil
 	}
 
+	l.dirty = true
 	entry := s.redoStack[len(s.redoStack)-1]
 	s.redoStack = s.redoStack[:len(s.redoStack)-1]
 	s.undoStack = append(s.undoStack, entry)
@@ -239,6 +245,7 @@ func (l *UndoLog) RedoCommit(entry *UndoEntry, rootID string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	l.dirty = true
 	for _, r := range entry.mutatedRootIDs {
 		if r == rootID {
 			continue
@@ -264,6 +271,7 @@ func (l *UndoLog) RedoRollback(entry *UndoEntry, rootID string) {
 	if nil == s {
 		return
 	}
+	l.dirty = true
 	// 从执行栈撤销栈顶移除 entry（Redo 压入的）
 	if 0 < len(s.undoStack) && s.undoStack[len(s.undoStack)-1].id == entry.id {
 		s.undoStack = s.undoStack[:len(s.undoStack)-1]
@@ -294,11 +302,92 @@ func (l *UndoLog) State(rootID string) (canUndo, canRedo bool, peekMutatedRootID
 	return
 }
 
+// UndoTimelineItem 是文档操作时间线中的一条记录。
+type UndoTimelineItem struct {
+	ID             string   `json:"id"`
+	Timestamp      int64    `json:"timestamp"`
+	Undone         bool     `json:"undone"`   // 已被撤销，位于重做栈中
+	Actions        []string `json:"actions"`  // 正向操作类型，按首次出现顺序去重
+	BlockIDs       []string `json:"blockIDs"` // 正向操作涉及的块 ID
+	MutatedRootIDs []string `json:"mutatedRootIDs"`
+}
+
+// Timeline 按操作发生的先后顺序返回 rootID 的撤销栈和重做栈条目，重做栈中的条目标记为已撤销。
+func (l *UndoLog) Timeline(rootID string) (ret []*UndoTimelineItem) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ret = []*UndoTimelineItem{}
+	s := l.stack(rootID)
+	if nil == s {
+		return
+	}
+
+	for _, e := range s.undoStack {
+		ret = append(ret, newUndoTimelineItem(e, false))
+	}
+	// 重做栈顶是最近一次撤销的操作，时间上紧跟撤销栈顶
+	for i := len(s.redoStack) - 1; 0 <= i; i-- {
+		ret = append(ret, newUndoTimelineItem(s.redoStack[i], true))
+	}
+	return
+}
+
+func newUndoTimelineItem(e *UndoEntry, undone bool) (ret *UndoTimelineItem) {
+	ret = &UndoTimelineItem{
+		ID:             e.id,
+		Timestamp:      e.timestamp,
+		Undone:         undone,
+		Actions:        []string{},
+		BlockIDs:       []string{},
+		MutatedRootIDs: e.MutatedRootIDs(),
+	}
+	for _, op := range e.doOperations {
+		ret.Actions = append(ret.Actions, op.Action)
+		if "" != op.ID {
+			ret.BlockIDs = append(ret.BlockIDs, op.ID)
+		}
+		ret.BlockIDs = append(ret.BlockIDs, op.BlockIDs...)
+	}
+	if 0 < len(e.doOperations) {
+		ret.Actions = gulu.Str.RemoveDuplicatedElem(ret.Actions)
+		ret.BlockIDs = append([]string{}, gulu.Str.RemoveDuplicatedElem(ret.BlockIDs)...)
+	}
+	return
+}
+
 // Clear 清理撤销日志。rootID 非空时清该文档栈并联动移除其它栈中相关条目；为空时清空全部。
 func (l *UndoLog) Clear(rootID string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	l.clear(rootID)
+}
+
+// Invalidate 在文档被同步、历史回滚等外部途径修改后清理这些文档的撤销日志，返回撤销/重做状态可能变化的 rootID。
+// 跨文档条目会联动从其它文档的栈中移除，这些文档也包含在返回值中。
+func (l *UndoLog) Invalidate(rootIDs []string) (affectedRootIDs []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, rootID := range rootIDs {
+		s := l.stacks[rootID]
+		if nil == s {
+			continue
+		}
+
+		affectedRootIDs = append(affectedRootIDs, rootID)
+		for _, e := range append(append([]*UndoEntry{}, s.undoStack...), s.redoStack...) {
+			affectedRootIDs = append(affectedRootIDs, e.mutatedRootIDs...)
+		}
+		l.clear(rootID)
+	}
+	affectedRootIDs = gulu.Str.RemoveDuplicatedElem(affectedRootIDs)
+	return
+}
+
+func (l *UndoLog) clear(rootID string) {
+	l.dirty = true
 	if "" == rootID {
 		l.stacks = map[string]*undoStack{}
 		return
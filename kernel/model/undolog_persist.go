@@ -0,0 +1,309 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	undoLogDataVersion = 1
+
+	// undoLogPersistMaxAge 为持久化撤销条目的最长保留时间，更早的条目在保存和恢复时丢弃。
+	undoLogPersistMaxAge = 7 * 24 * time.Hour
+	// undoLogPersistMaxSize 为持久化文件的大致上限，超出时优先丢弃最早的条目。
+	undoLogPersistMaxSize = 16 * 1024 * 1024
+)
+
+// undoLogData 是撤销日志的持久化格式。跨文档条目只保存一份，各文档的栈按条目 ID 引用。
+type undoLogData struct {
+	Version int                       `json:"version"`
+	Entries map[string]*undoEntryData `json:"entries"`
+	Stacks  map[string]*undoStackData `json:"stacks"`
+}
+
+type undoEntryData struct {
+	DoOperations   []*Operation `json:"doOperations"`
+	UndoOperations []*Operation `json:"undoOperations"`
+	Timestamp      int64        `json:"timestamp"`
+	MutatedRootIDs []string     `json:"mutatedRootIDs"`
+}
+
+type undoStackData struct {
+	Undo    []string `json:"undo"`
+	Redo    []string `json:"redo"`
+	HasUndo bool     `json:"hasUndo"`
+
+	// Fingerprint 为保存时文档文件的指纹，恢复时不一致说明文档已被撤销日志之外的途径修改，丢弃该文档的栈
+	Fingerprint string `json:"fingerprint"`
+}
+
+// undoLogFingerprint 返回文档当前的指纹，第二个返回值为 false 时表示该文档的撤销日志不能持久化或恢复。
+type undoLogFingerprint func(rootID string) (string, bool)
+
+func undoLogDataPath() string {
+	return filepath.Join(util.TempDir, "undo-log.json")
+}
+
+// LoadUndoLog 在启动时恢复持久化的撤销日志，需要在块树就绪后调用。
+func LoadUndoLog() {
+	dataPath := undoLogDataPath()
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read undo log failed: %s", err)
+		return
+	}
+
+	logData := &undoLogData{}
+	if err = gulu.JSON.UnmarshalJSON(data, logData); err != nil || undoLogDataVersion != logData.Version {
+		logging.LogWarnf("discard undo log [version=%d]: %v", logData.Version, err)
+		if err = filelock.Remove(dataPath); err != nil {
+			logging.LogErrorf("remove undo log failed: %s", err)
+		}
+		return
+	}
+
+	GlobalUndoLog.restore(logData, undoLogDocFingerprint, time.Now(), undoLogPersistMaxAge)
+}
+
+// SaveUndoLog 将撤销日志写入工作空间临时目录，没有变更时不写入。
+func SaveUndoLog() {
+	logData := GlobalUndoLog.snapshot(undoLogDocFingerprint, time.Now(), undoLogPersistMaxAge, undoLogPersistMaxSize)
+	if nil == logData {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(logData)
+	if err != nil {
+		logging.LogErrorf("marshal undo log failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(undoLogDataPath(), data); err != nil {
+		logging.LogErrorf("write undo log failed: %s", err)
+	}
+}
+
+func FlushUndoLogJob() {
+	SaveUndoLog()
+}
+
+// InvalidateUndoLog 清理被同步、历史回滚等途径修改的文档的撤销日志，并通知前端刷新撤销/重做按钮状态。
+func InvalidateUndoLog(rootIDs []string) {
+	affectedRootIDs := GlobalUndoLog.Invalidate(rootIDs)
+	if 1 > len(affectedRootIDs) {
+		return
+	}
+
+	undoStates := map[string]map[string]bool{}
+	for _, rootID := range affectedRootIDs {
+		canUndo, canRedo, _ := GlobalUndoLog.State(rootID)
+		undoStates[rootID] = map[string]bool{
+			"canUndo": canUndo,
+			"canRedo": canRedo,
+		}
+	}
+	util.BroadcastByType("protyle", "undoState", 0, "", undoStates)
+}
+
+// undoLogDocFingerprint 使用文档文件的修改时间和大小作为指纹，加密笔记本中的文档不持久化撤销日志，避免明文内容落盘。
+func undoLogDocFingerprint(rootID string) (string, bool) {
+	bt := treenode.GetBlockTree(rootID)
+	if nil == bt || IsEncryptedBox(bt.BoxID) {
+		return "", false
+	}
+
+	info, err := os.Stat(filepath.Join(util.DataDir, bt.BoxID, bt.Path))
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatInt(info.ModTime().UnixNano(), 10) + "-" + strconv.FormatInt(info.Size(), 10), true
+}
+
+// snapshot 生成持久化数据，没有变更时返回 nil。
+// 超过 maxAge 的条目、涉及不可持久化文档的条目会被丢弃，总大小超过 maxSize 时从最早的条目开始丢弃。
+func (l *UndoLog) snapshot(fingerprint undoLogFingerprint, now time.Time, maxAge time.Duration, maxSize int) (ret *undoLogData) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.dirty {
+		return
+	}
+	l.dirty = false
+
+	fingerprints := map[string]string{}
+	persistable := func(rootID string) bool {
+		if _, ok := fingerprints[rootID]; ok {
+			return true
+		}
+		fp, ok := fingerprint(rootID)
+		if ok {
+			fingerprints[rootID] = fp
+		}
+		return ok
+	}
+
+	entries := map[string]*UndoEntry{}
+	minTimestamp := now.Add(-maxAge).UnixMilli()
+	for _, s := range l.stacks {
+		for _, e := range append(append([]*UndoEntry{}, s.undoStack...), s.redoStack...) {
+			if _, ok := entries[e.id]; ok || e.timestamp < minTimestamp {
+				continue
+			}
+
+			ok := true
+			for _, rootID := range e.mutatedRootIDs {
+				if !persistable(rootID) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				entries[e.id] = e
+			}
+		}
+	}
+
+	// 从最新的条目开始累加大小，超出上限后丢弃更早的条目
+	sorted := make([]*UndoEntry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].timestamp != sorted[j].timestamp {
+			return sorted[i].timestamp > sorted[j].timestamp
+		}
+		return sorted[i].id > sorted[j].id
+	})
+	ret = &undoLogData{Version: undoLogDataVersion, Entries: map[string]*undoEntryData{}, Stacks: map[string]*undoStackData{}}
+	size := 0
+	for _, e := range sorted {
+		entryData := &undoEntryData{
+			DoOperations:   e.doOperations,
+			UndoOperations: e.undoOperations,
+			Timestamp:      e.timestamp,
+			MutatedRootIDs: e.mutatedRootIDs,
+		}
+		data, err := gulu.JSON.MarshalJSON(entryData)
+		if err != nil {
+			logging.LogWarnf("marshal undo entry [%s] failed: %s", e.id, err)
+			continue
+		}
+		if size += len(data); size > maxSize {
+			break
+		}
+		ret.Entries[e.id] = entryData
+	}
+
+	for rootID, s := range l.stacks {
+		if !persistable(rootID) {
+			continue
+		}
+
+		stackData := &undoStackData{HasUndo: s.hasUndo, Fingerprint: fingerprints[rootID]}
+		for _, e := range s.undoStack {
+			if nil != ret.Entries[e.id] {
+				stackData.Undo = append(stackData.Undo, e.id)
+			}
+		}
+		for _, e := range s.redoStack {
+			if nil != ret.Entries[e.id] {
+				stackData.Redo = append(stackData.Redo, e.id)
+			}
+		}
+		if 0 < len(stackData.Undo) || 0 < len(stackData.Redo) {
+			ret.Stacks[rootID] = stackData
+		}
+	}
+	return
+}
+
+// restore 从持久化数据恢复撤销日志。文档指纹不一致（文档已被外部修改）的栈会被丢弃，
+// 涉及被丢弃文档的跨文档条目也会从其它文档的栈中移除，避免撤销时作用到已变化的文档上。
+func (l *UndoLog) restore(data *undoLogData, fingerprint undoLogFingerprint, now time.Time, maxAge time.Duration) {
+	valid := map[string]bool{}
+	for rootID, stackData := range data.Stacks {
+		if fp, ok := fingerprint(rootID); ok && fp == stackData.Fingerprint {
+			valid[rootID] = true
+		}
+	}
+
+	entries := map[string]*UndoEntry{}
+	minTimestamp := now.Add(-maxAge).UnixMilli()
+	for id, entryData := range data.Entries {
+		if entryData.Timestamp < minTimestamp || 1 > len(entryData.MutatedRootIDs) {
+			continue
+		}
+
+		ok := true
+		for _, rootID := range entryData.MutatedRootIDs {
+			if !valid[rootID] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			entries[id] = &UndoEntry{
+				id:             id,
+				doOperations:   entryData.DoOperations,
+				undoOperations: entryData.UndoOperations,
+				timestamp:      entryData.Timestamp,
+				mutatedRootIDs: entryData.MutatedRootIDs,
+			}
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for rootID := range valid {
+		stackData := data.Stacks[rootID]
+		s := &undoStack{hasUndo: stackData.HasUndo}
+		for _, id := range stackData.Undo {
+			if e := entries[id]; nil != e {
+				s.undoStack = append(s.undoStack, e)
+			}
+		}
+		for _, id := range stackData.Redo {
+			if e := entries[id]; nil != e {
+				s.redoStack = append(s.redoStack, e)
+			}
+		}
+		if l.max < len(s.undoStack) {
+			s.undoStack = s.undoStack[len(s.undoStack)-l.max:]
+		}
+		if l.max < len(s.redoStack) {
+			s.redoStack = s.redoStack[len(s.redoStack)-l.max:]
+		}
+		if 0 < len(s.undoStack) || 0 < len(s.redoStack) {
+			l.stacks[rootID] = s
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/parse"
+)
+
+func recordTestUndo(l *UndoLog, blockID string, rootIDs ...string) {
+	tx := &Transaction{
+		fromAPI:        true,
+		DoOperations:   []*Operation{{Action: "update", ID: blockID, Data: "<div>new</div>"}},
+		UndoOperations: []*Operation{{Action: "update", ID: blockID, Data: "<div>old</div>"}},
+		trees:          map[string]*parse.Tree{},
+	}
+	for _, rootID := range rootIDs {
+		tx.trees[rootID] = nil
+	}
+	l.Record(tx)
+}
+
+func TestUndoLogSnapshotRestore(t *testing.T) {
+	l := newUndoLog(64)
+	recordTestUndo(l, "20260101000000-aaaaaaa", "a")
+	recordTestUndo(l, "20260101000000-bbbbbbb", "a", "b")
+	recordTestUndo(l, "20260101000000-ccccccc", "a")
+	l.Undo("a")
+
+	fingerprints := map[string]string{"a": "1", "b": "2"}
+	fingerprint := func(rootID string) (string, bool) {
+		fp, ok := fingerprints[rootID]
+		return fp, ok
+	}
+	now := time.Now()
+	data := l.snapshot(fingerprint, now, time.Hour, 1024*1024)
+	if nil == data || 3 != len(data.Entries) || 2 != len(data.Stacks) {
+		t.Fatalf("快照内容错误：%+v", data)
+	}
+	if nil != l.snapshot(fingerprint, now, time.Hour, 1024*1024) {
+		t.Fatalf("没有变更时不应生成快照")
+	}
+
+	// 经过 JSON 序列化后恢复
+	raw, err := gulu.JSON.MarshalJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = &undoLogData{}
+	if err = gulu.JSON.UnmarshalJSON(raw, data); err != nil {
+		t.Fatal(err)
+	}
+	restored := newUndoLog(64)
+	restored.restore(data, fingerprint, now, time.Hour)
+	canUndo, canRedo, peek := restored.State("a")
+	if !canUndo || !canRedo || 2 != len(peek) {
+		t.Fatalf("恢复后撤销状态错误：canUndo=%v canRedo=%v peek=%v", canUndo, canRedo, peek)
+	}
+	timeline := restored.Timeline("a")
+	if 3 != len(timeline) || timeline[0].Undone || !timeline[2].Undone || "20260101000000-ccccccc" != timeline[2].BlockIDs[0] {
+		t.Fatalf("恢复后时间线错误")
+	}
+	if op := restored.Peek("a").UndoOperationsForReplay()[0]; "<div>old</div>" != op.Data {
+		t.Fatalf("恢复后的逆向操作错误：%v", op.Data)
+	}
+
+	// 跨文档条目在两个栈中应为同一条目
+	entry := restored.Undo("a")
+	restored.UndoCommit(entry, "a")
+	if canUndo, _, _ := restored.State("b"); canUndo {
+		t.Fatalf("撤销跨文档条目后应联动移除另一文档栈中的条目")
+	}
+}
+
+func TestUndoLogRestoreInvalidation(t *testing.T) {
+	l := newUndoLog(64)
+	recordTestUndo(l, "20260101000000-aaaaaaa", "a")
+	recordTestUndo(l, "20260101000000-bbbbbbb", "a", "b")
+	recordTestUndo(l, "20260101000000-ccccccc", "b")
+
+	now := time.Now()
+	data := l.snapshot(func(rootID string) (string, bool) { return "1", true }, now, time.Hour, 1024*1024)
+
+	// 文档 b 已被外部修改，b 的栈和涉及 b 的跨文档条目都应丢弃
+	restored := newUndoLog(64)
+	restored.restore(data, func(rootID string) (string, bool) {
+		if "b" == rootID {
+			return "2", true
+		}
+		return "1", true
+	}, now, time.Hour)
+	if timeline := restored.Timeline("a"); 1 != len(timeline) || "20260101000000-aaaaaaa" != timeline[0].BlockIDs[0] {
+		t.Fatalf("跨文档条目应被丢弃：%d", len(timeline))
+	}
+	if canUndo, _, _ := restored.State("b"); canUndo {
+		t.Fatalf("被修改文档的栈应被丢弃")
+	}
+
+	// 过期条目丢弃
+	restored = newUndoLog(64)
+	restored.restore(data, func(rootID string) (string, bool) { return "1", true }, now.Add(2*time.Hour), time.Hour)
+	if 0 != len(restored.stacks) {
+		t.Fatalf("过期条目应被丢弃")
+	}
+}
+
+func TestUndoLogSnapshotBounds(t *testing.T) {
+	l := newUndoLog(64)
+	for range 10 {
+		recordTestUndo(l, "20260101000000-aaaaaaa", "a")
+	}
+	recordTestUndo(l, "20260101000000-bbbbbbb", "a", "encrypted")
+
+	peek := l.Peek("a")
+	entryData, _ := gulu.JSON.MarshalJSON(&undoEntryData{DoOperations: peek.doOperations, UndoOperations: peek.undoOperations, Timestamp: peek.timestamp, MutatedRootIDs: []string{"a"}})
+	data := l.snapshot(func(rootID string) (string, bool) { return "1", "encrypted" != rootID }, time.Now(), time.Hour, 3*len(entryData)+1)
+	if 1 != len(data.Stacks) || nil != data.Stacks["encrypted"] {
+		t.Fatalf("不可持久化文档的栈不应保存：%d", len(data.Stacks))
+	}
+	if 3 != len(data.Entries) {
+		t.Fatalf("超出大小上限时应丢弃最早的条目：%d", len(data.Entries))
+	}
+	if undo := data.Stacks["a"].Undo; 3 != len(undo) || nil == data.Entries[undo[2]] {
+		t.Fatalf("栈中应只保留最新的条目：%v", undo)
+	}
+
+	affected := l.Invalidate([]string{"encrypted"})
+	if 2 != len(affected) {
+		t.Fatalf("清理跨文档条目应影响关联文档：%v", affected)
+	}
+}
@@ -62,17 +62,20 @@ func InsertAssetBytes(id, fileName string, data []byte) (assetPath string, creat
 		return "", false, err
 	}
 
-	reader := bytes.NewReader(data)
-	hash, err := util.GetEtagByHandle(reader, int64(len(data)))
+	fName, src, original, err := applyUploadImagePipeline(fName, bytes.NewReader(data))
 	if err != nil {
 		return "", false, err
 	}
-	if existAssetPath := GetAssetPathByHash(hash, bt.BoxID); existAssetPath != "" {
-		originalName := util.RemoveID(filepath.Base(existAssetPath))
-		if strings.EqualFold(fName, originalName) {
-			return strings.TrimPrefix(existAssetPath, "/"), false, nil
-		}
-		hash = "random_2_" + gulu.Rand.String(12)
+	hash, err := hashUploadAsset(src)
+	if err != nil {
+		return "", false, err
+	}
+	if existAssetPath := reusableAssetPath(hash, bt.BoxID); existAssetPath != "" {
+		// 内容相同的资源直接复用，不再重复写入
+		return existAssetPath, false, nil
+	}
+	if newExt := filepath.Ext(fName); ext != newExt {
+		baseName = strings.TrimSuffix(baseName, filepath.Ext(baseName)) + newExt
 	}
 
 	blockID := ast.NewNodeID()
@@ -85,12 +88,15 @@ func InsertAssetBytes(id, fileName string, data []byte) (assetPath string, creat
 		fName = util.AssetName(fName, blockID)
 	}
 	writePath := filepath.Join(assetsDirPath, fName)
-	if err = writeAssetFile(writePath, bytes.NewReader(data), bt.BoxID); err != nil {
+	if err = writeAssetFile(writePath, src, bt.BoxID); err != nil {
 		if IsEncryptedBox(bt.BoxID) {
 			_ = removeAssetNameMapping(bt.BoxID, fName)
 		}
 		return "", false, err
 	}
+	if nil != original {
+		keepOriginalImage(writePath, ext, original, bt.BoxID)
+	}
 
 	assetPath = "assets/" + fName
 	if IsEncryptedBox(bt.BoxID) {
@@ -153,7 +159,13 @@ func InsertLocalAssets(id string, assetAbsPaths []string, isUpload bool) (succMa
 			return
 		}
 
-		hash, hashErr := util.GetEtagByHandle(f, fi.Size())
+		fName, src, original, pipelineErr := applyUploadImagePipeline(fName, f)
+		if nil != pipelineErr {
+			err = pipelineErr
+			f.Close()
+			return
+		}
+		hash, hashErr := hashUploadAsset(src)
 		if nil != hashErr {
 			f.Close()
 			return
@@ -163,24 +175,22 @@ func InsertLocalAssets(id string, assetAbsPaths []string, isUpload bool) (succMa
 			hash = "random_1_" + gulu.Rand.String(12)
 		}
 
-		existAssetPath := GetAssetPathByHash(hash, bt.BoxID)
-		if "" != existAssetPath {
-			originalName := util.RemoveID(filepath.Base(existAssetPath))
-			if strings.ToLower(fName) != strings.ToLower(originalName) {
-				hash = "random_2_" + gulu.Rand.String(12)
-			}
-		}
-
-		if "" != existAssetPath && !strings.HasPrefix(hash, "random_") {
-			succMap[baseName] = strings.TrimPrefix(existAssetPath, "/")
+		// 内容相同的资源直接复用，不再重复写入
+		if existAssetPath := reusableAssetPath(hash, bt.BoxID); "" != existAssetPath {
+			succMap[baseName] = existAssetPath
 			f.Close()
 		} else {
+			mappingName := baseName
+			if newExt := filepath.Ext(fName); ext != newExt {
+				mappingName = strings.TrimSuffix(baseName, filepath.Ext(baseName)) + newExt
+			}
+
 			blockID := ast.NewNodeID()
 			if IsEncryptedBox(bt.BoxID) {
 				// 加密 box：磁盘文件名脱敏为 uuid-blockID.ext，原始名存加密映射
 				fName = encryptedAssetName(util.Ext(fName), blockID)
 				// 映射写入失败则不写 asset，避免产出"孤儿密文 asset 无映射"（详见设计文档 §7）
-				if mapErr := writeAssetNameMapping(bt.BoxID, fName, baseName); mapErr != nil {
+				if mapErr := writeAssetNameMapping(bt.BoxID, fName, mappingName); mapErr != nil {
 					err = mapErr
 					f.Close()
 					return
@@ -189,15 +199,14 @@ func InsertLocalAssets(id string, assetAbsPaths []string, isUpload bool) (succMa
 				fName = util.AssetName(fName, blockID)
 			}
 			writePath := filepath.Join(assetsDirPath, fName)
-			if _, err = f.Seek(0, io.SeekStart); err != nil {
-				f.Close()
-				return
-			}
-			if err = writeAssetFile(writePath, f, bt.BoxID); err != nil {
+			if err = writeAssetFile(writePath, src, bt.BoxID); err != nil {
 				f.Close()
 				return
 			}
 			f.Close()
+			if nil != original {
+				keepOriginalImage(writePath, ext, original, bt.BoxID)
+			}
 
 			p := "assets/" + fName
 			if IsEncryptedBox(bt.BoxID) {
@@ -311,10 +320,26 @@ func Upload(c *gin.Context) {
 			break
 		}
 
-		hash, hashErr := util.GetEtagByHandle(f, file.Size)
+		// PDF 标注图片需要按原扩展名匹配已有文件，不做处理
+		var src io.ReadSeeker = f
+		var original []byte
+		mappingName := baseName
+		if !needUnzip2Dir && !skipIfDuplicated {
+			if fName, src, original, err = applyUploadImagePipeline(fName, f); err != nil {
+				errFiles = append(errFiles, fName)
+				ret.Msg = err.Error()
+				f.Close()
+				break
+			}
+			if newExt := filepath.Ext(fName); ext != newExt {
+				mappingName = strings.TrimSuffix(baseName, filepath.Ext(baseName)) + newExt
+			}
+		}
+
+		hash, hashErr := hashUploadAsset(src)
 		if nil != hashErr {
 			errFiles = append(errFiles, fName)
-			ret.Msg = err.Error()
+			ret.Msg = hashErr.Error()
 			f.Close()
 			break
 		}
@@ -323,16 +348,9 @@ func Upload(c *gin.Context) {
 			hash = "random_1_" + gulu.Rand.String(12)
 		}
 
-		existAssetPath := GetAssetPathByHash(hash, uploadBoxID)
-		if "" != existAssetPath {
-			originalName := util.RemoveID(filepath.Base(existAssetPath))
-			if strings.ToLower(fName) != strings.ToLower(originalName) {
-				hash = "random_2_" + gulu.Rand.String(12)
-			}
-		}
-
-		if "" != existAssetPath && !strings.HasPrefix(hash, "random_") {
-			succMap[baseName] = strings.TrimPrefix(existAssetPath, "/")
+		// 内容相同的资源直接复用，不再重复写入
+		if existAssetPath := reusableAssetPath(hash, uploadBoxID); "" != existAssetPath {
+			succMap[baseName] = existAssetPath
 			f.Close()
 		} else {
 			if skipIfDuplicated {
@@ -366,7 +384,7 @@ func Upload(c *gin.Context) {
 				// 加密 box：磁盘文件名脱敏为 uuid-blockID.ext，原始名存加密映射
 				fName = encryptedAssetName(util.Ext(fName), lastID)
 				// 映射写入失败则不写 asset，避免产出"孤儿密文 asset 无映射"（详见设计文档 §7）
-				if mapErr := writeAssetNameMapping(uploadBoxID, fName, baseName); mapErr != nil {
+				if mapErr := writeAssetNameMapping(uploadBoxID, fName, mappingName); mapErr != nil {
 					errFiles = append(errFiles, fName)
 					ret.Msg = mapErr.Error()
 					f.Close()
@@ -394,7 +412,7 @@ func Upload(c *gin.Context) {
 				f.Close()
 				break
 			}
-			if err = writeAssetFile(writePath, f, uploadBoxID); err != nil {
+			if err = writeAssetFile(writePath, src, uploadBoxID); err != nil {
 				logging.LogErrorf("write file failed: %s", err)
 				errFiles = append(errFiles, fName)
 				ret.Msg = err.Error()
@@ -402,6 +420,9 @@ func Upload(c *gin.Context) {
 				break
 			}
 			f.Close()
+			if nil != original {
+				keepOriginalImage(writePath, ext, original, uploadBoxID)
+			}
 
 			if needUnzip2Dir {
 				baseName = strings.TrimSuffix(file.Filename, ".rtfd.zip") + ".rtfd"
@@ -475,6 +496,22 @@ func Upload(c *gin.Context) {
 	IncSync()
 }
 
+// hashUploadAsset 计算即将写入的资源内容哈希，计算后将读取位置复位到开头。
+func hashUploadAsset(src io.ReadSeeker) (hash string, err error) {
+	size, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if hash, err = util.GetEtagByHandle(src, size); err != nil {
+		return
+	}
+	_, err = src.Seek(0, io.SeekStart)
+	return
+}
+
 func getAssetsDir(boxLocalPath, docDirLocalPath string) (assets string) {
 	assets = filepath.Join(docDirLocalPath, "assets")
 	if !filelock.IsExist(assets) {
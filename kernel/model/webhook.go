@@ -0,0 +1,831 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+
+	maskedWebhookSecret = "******"
+
+	webhookDeliveryMaxAttempts  = 8
+	webhookDeliveryBaseDelay    = 10 * time.Second
+	webhookDeliveryMaxDelay     = time.Hour
+	webhookDeliveryTimeout      = 15 * time.Second
+	webhookDeliveryBatchSize    = 32
+	webhookDeliveryLogMax       = 2000 // 投递日志最多保留的条目数，超出时先丢弃最早的已结束条目
+	webhookDeliverySaveInterval = 10 * time.Second
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookInvalidURL       = errors.New("invalid webhook URL")
+	ErrWebhookInvalidEvent     = errors.New("invalid webhook event")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+	webhookLock = sync.RWMutex{}
+
+	webhookDeliveries        []*WebhookDelivery
+	webhookDeliveriesDirty   bool
+	webhookDeliveriesSaved   time.Time
+	webhookDeliveryLock      = sync.Mutex{}
+	webhookDeliveryExecuting = sync.Mutex{}
+
+	webhookHTTPClient *http.Client
+)
+
+// WebhookEvent 是投递给外部地址的 JSON 负载。
+type WebhookEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	Data      any    `json:"data"`
+}
+
+// WebhookBlock 描述事件涉及的块或文档，同时作为过滤条件的匹配对象。
+type WebhookBlock struct {
+	ID      string `json:"id"`
+	RootID  string `json:"rootID"`
+	Box     string `json:"box"`
+	Path    string `json:"path"`
+	HPath   string `json:"hPath"`
+	Type    string `json:"type"`
+	Removed bool   `json:"removed,omitempty"`
+
+	attrs map[string]string
+}
+
+// WebhookDelivery 是一次事件投递的记录，持久化在工作空间临时目录中，重启后继续投递未完成的条目。
+type WebhookDelivery struct {
+	ID           string `json:"id"`
+	WebhookID    string `json:"webhookID"`
+	Event        string `json:"event"`
+	EventID      string `json:"eventID"`
+	Payload      string `json:"payload"`
+	Status       string `json:"status"`
+	Attempts     int    `json:"attempts"`
+	NextAttempt  int64  `json:"nextAttempt"`
+	LastAttempt  int64  `json:"lastAttempt"`
+	ResponseCode int    `json:"responseCode"`
+	Error        string `json:"error"`
+	Created      int64  `json:"created"`
+}
+
+// webhookSource 是尚未按 Webhook 过滤的事件，blocks 为参与过滤的块，data 根据过滤后保留的块生成负载数据。
+type webhookSource struct {
+	typ    string
+	blocks []*WebhookBlock
+	data   func(blocks []*WebhookBlock) any
+}
+
+// InitWebhooks 在启动时恢复投递日志并订阅文档和块变更事件。
+func InitWebhooks() {
+	LoadWebhookDeliveries()
+
+	subscribe := func(topic string, fn any) {
+		if err := eventbus.Subscribe(topic, fn); err != nil {
+			logging.LogErrorf("subscribe webhook event [%s] failed: %s", topic, err)
+		}
+	}
+	subscribe(util.EvtTxCommitted, webhookOnTxCommitted)
+	subscribe(util.EvtDocCreated, webhookOnDocCreated)
+	subscribe(util.EvtDocRenamed, webhookOnDocRenamed)
+	subscribe(util.EvtDocMoved, webhookOnDocMoved)
+	subscribe(util.EvtDocRemoved, webhookOnDocRemoved)
+	subscribe(util.EvtAvCellUpdated, webhookOnAvCellUpdated)
+}
+
+func ListWebhooks() (ret []*conf.Webhook) {
+	webhookLock.RLock()
+	defer webhookLock.RUnlock()
+
+	ret = []*conf.Webhook{}
+	for _, webhook := range Conf.Webhooks.Items {
+		ret = append(ret, copyWebhook(webhook, true))
+	}
+	return
+}
+
+// CreateWebhook 新建 Webhook，未指定签名密钥时随机生成，返回值中的密钥为明文，之后列表中仅返回掩码。
+func CreateWebhook(webhook *conf.Webhook) (ret *conf.Webhook, err error) {
+	if err = checkWebhook(webhook); nil != err {
+		return
+	}
+
+	webhook.ID = ast.NewNodeID()
+	webhook.Name = strings.TrimSpace(webhook.Name)
+	if "" == webhook.Secret || maskedWebhookSecret == webhook.Secret {
+		webhook.Secret = gulu.Rand.String(32)
+	}
+	webhook.Created = time.Now().UnixMilli()
+	webhook.Updated = webhook.Created
+
+	webhookLock.Lock()
+	Conf.Webhooks.Items = append(Conf.Webhooks.Items, webhook)
+	ret = copyWebhook(webhook, false)
+	webhookLock.Unlock()
+	Conf.Save()
+	return
+}
+
+// UpdateWebhook 更新 Webhook，签名密钥为空或掩码时保留原密钥。
+func UpdateWebhook(webhook *conf.Webhook) (ret *conf.Webhook, err error) {
+	if err = checkWebhook(webhook); nil != err {
+		return
+	}
+
+	webhookLock.Lock()
+	old := getWebhookByID(webhook.ID)
+	if nil == old {
+		webhookLock.Unlock()
+		err = ErrWebhookNotFound
+		return
+	}
+	old.Name = strings.TrimSpace(webhook.Name)
+	old.URL = webhook.URL
+	if "" != webhook.Secret && maskedWebhookSecret != webhook.Secret {
+		old.Secret = webhook.Secret
+	}
+	old.Enabled = webhook.Enabled
+	old.Events = webhook.Events
+	old.Boxes = webhook.Boxes
+	old.Paths = webhook.Paths
+	old.BlockTypes = webhook.BlockTypes
+	old.Attrs = webhook.Attrs
+	old.Updated = time.Now().UnixMilli()
+	ret = copyWebhook(old, true)
+	webhookLock.Unlock()
+	Conf.Save()
+	return
+}
+
+func RemoveWebhook(id string) (err error) {
+	webhookLock.Lock()
+	webhook := getWebhookByID(id)
+	if nil == webhook {
+		webhookLock.Unlock()
+		return ErrWebhookNotFound
+	}
+	Conf.Webhooks.Items = slices.DeleteFunc(Conf.Webhooks.Items, func(w *conf.Webhook) bool { return w.ID == id })
+	webhookLock.Unlock()
+	Conf.Save()
+
+	webhookDeliveryLock.Lock()
+	for _, delivery := range webhookDeliveries {
+		if id == delivery.WebhookID && WebhookDeliveryPending == delivery.Status {
+			delivery.Status = WebhookDeliveryFailed
+			delivery.Error = ErrWebhookNotFound.Error()
+			webhookDeliveriesDirty = true
+		}
+	}
+	webhookDeliveryLock.Unlock()
+	return
+}
+
+// TestWebhook 立即向 Webhook 投递一个 ping 事件，不受启用状态、订阅事件和过滤条件的限制。
+func TestWebhook(id string) (ret *WebhookDelivery, err error) {
+	webhookLock.RLock()
+	webhook := getWebhookByID(id)
+	if nil != webhook {
+		webhook = copyWebhook(webhook, false)
+	}
+	webhookLock.RUnlock()
+	if nil == webhook {
+		err = ErrWebhookNotFound
+		return
+	}
+
+	event := newWebhookEvent(conf.WebhookEventPing, map[string]any{"webhook": webhook.ID})
+	ret, err = newWebhookDelivery(webhook.ID, event)
+	if nil != err {
+		return
+	}
+	attemptWebhookDelivery(webhook, ret, time.Now())
+	addWebhookDelivery(ret)
+	ret = copyWebhookDelivery(ret)
+	return
+}
+
+// GetWebhookDeliveries 返回投递日志，按创建时间倒序，webhookID 和 status 为空时不过滤。
+func GetWebhookDeliveries(webhookID, status string, limit int) (ret []*WebhookDelivery) {
+	webhookDeliveryLock.Lock()
+	defer webhookDeliveryLock.Unlock()
+
+	ret = []*WebhookDelivery{}
+	for i := len(webhookDeliveries) - 1; 0 <= i; i-- {
+		delivery := webhookDeliveries[i]
+		if ("" != webhookID && webhookID != delivery.WebhookID) || ("" != status && status != delivery.Status) {
+			continue
+		}
+		ret = append(ret, copyWebhookDelivery(delivery))
+		if 0 < limit && limit <= len(ret) {
+			break
+		}
+	}
+	return
+}
+
+// RedeliverWebhook 将投递记录重置为待投递，下一次投递任务执行时重新发送。
+func RedeliverWebhook(deliveryID string) (err error) {
+	webhookDeliveryLock.Lock()
+	defer webhookDeliveryLock.Unlock()
+
+	for _, delivery := range webhookDeliveries {
+		if deliveryID == delivery.ID {
+			delivery.Status = WebhookDeliveryPending
+			delivery.Attempts = 0
+			delivery.NextAttempt = time.Now().UnixMilli()
+			delivery.Error = ""
+			webhookDeliveriesDirty = true
+			return
+		}
+	}
+	return ErrWebhookDeliveryNotFound
+}
+
+// DeliverWebhooksJob 投递到期的待投递事件，失败时按指数退避重试。
+func DeliverWebhooksJob() {
+	if !webhookDeliveryExecuting.TryLock() {
+		return
+	}
+	defer webhookDeliveryExecuting.Unlock()
+
+	now := time.Now()
+	var due []*WebhookDelivery
+	webhookDeliveryLock.Lock()
+	for _, delivery := range webhookDeliveries {
+		if WebhookDeliveryPending == delivery.Status && delivery.NextAttempt <= now.UnixMilli() {
+			due = append(due, delivery)
+			if webhookDeliveryBatchSize <= len(due) {
+				break
+			}
+		}
+	}
+	webhookDeliveryLock.Unlock()
+
+	for _, delivery := range due {
+		webhookLock.RLock()
+		webhook := getWebhookByID(delivery.WebhookID)
+		if nil != webhook {
+			webhook = copyWebhook(webhook, false)
+		}
+		webhookLock.RUnlock()
+
+		attempt := copyWebhookDelivery(delivery)
+		if nil == webhook {
+			attempt.Status = WebhookDeliveryFailed
+			attempt.Error = ErrWebhookNotFound.Error()
+		} else {
+			attemptWebhookDelivery(webhook, attempt, time.Now())
+		}
+
+		webhookDeliveryLock.Lock()
+		if WebhookDeliveryPending == delivery.Status { // 投递期间可能被删除 Webhook 标记为失败
+			*delivery = *attempt
+		}
+		webhookDeliveriesDirty = true
+		webhookDeliveryLock.Unlock()
+	}
+
+	if time.Since(webhookDeliveriesSaved) >= webhookDeliverySaveInterval {
+		SaveWebhookDeliveries()
+	}
+}
+
+// SaveWebhookDeliveries 将投递日志写入工作空间临时目录，没有变更时不写入。
+func SaveWebhookDeliveries() {
+	webhookDeliveryLock.Lock()
+	if !webhookDeliveriesDirty {
+		webhookDeliveryLock.Unlock()
+		return
+	}
+	data, err := gulu.JSON.MarshalJSON(webhookDeliveries)
+	webhookDeliveriesDirty = false
+	webhookDeliveriesSaved = time.Now()
+	webhookDeliveryLock.Unlock()
+	if err != nil {
+		logging.LogErrorf("marshal webhook deliveries failed: %s", err)
+		return
+	}
+
+	if err = filelock.WriteFile(webhookDeliveriesPath(), data); err != nil {
+		logging.LogErrorf("write webhook deliveries failed: %s", err)
+	}
+}
+
+// LoadWebhookDeliveries 从工作空间临时目录加载投递日志。
+func LoadWebhookDeliveries() {
+	dataPath := webhookDeliveriesPath()
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read webhook deliveries failed: %s", err)
+		return
+	}
+	var deliveries []*WebhookDelivery
+	if err = gulu.JSON.UnmarshalJSON(data, &deliveries); err != nil {
+		logging.LogErrorf("unmarshal webhook deliveries failed: %s", err)
+		return
+	}
+
+	webhookDeliveryLock.Lock()
+	webhookDeliveries = deliveries
+	webhookDeliveryLock.Unlock()
+}
+
+func webhookDeliveriesPath() string {
+	return filepath.Join(util.TempDir, "webhook-deliveries.json")
+}
+
+func webhookOnTxCommitted(rootIDs, blockIDs, avIDs []string) {
+	webhooks := subscribedWebhooks(conf.WebhookEventBlockChanged)
+	if 1 > len(webhooks) || 1 > len(blockIDs) {
+		return
+	}
+
+	var blocks []*WebhookBlock
+	for _, id := range blockIDs {
+		if bt := treenode.GetBlockTree(id); nil != bt {
+			blocks = append(blocks, &WebhookBlock{ID: bt.ID, RootID: bt.RootID, Box: bt.BoxID, Path: bt.Path, HPath: bt.HPath, Type: bt.Type})
+			continue
+		}
+
+		// 已删除的块从块树中移除后，尝试使用尚未刷新的数据库索引确定所属文档
+		if b := sql.GetBlock(id); nil != b {
+			block := &WebhookBlock{ID: b.ID, RootID: b.RootID, Box: b.Box, Path: b.Path, HPath: b.HPath, Type: b.Type, Removed: true}
+			block.attrs = parse.IAL2Map(parse.Tokens2IAL([]byte(b.IAL)))
+			blocks = append(blocks, block)
+		}
+	}
+	if webhooksNeedAttrs(webhooks) {
+		loadWebhookBlockAttrs(blocks)
+	}
+
+	enqueueWebhookEvent(webhooks, &webhookSource{
+		typ:    conf.WebhookEventBlockChanged,
+		blocks: blocks,
+		data: func(blocks []*WebhookBlock) any {
+			return map[string]any{"blocks": blocks}
+		},
+	})
+}
+
+func webhookOnDocCreated(boxID, p, id string) {
+	webhookOnDocEvent(conf.WebhookEventDocCreated, id, nil)
+}
+
+func webhookOnDocRenamed(boxID, p, id, title string) {
+	webhookOnDocEvent(conf.WebhookEventDocRenamed, id, map[string]any{"title": title})
+}
+
+func webhookOnDocMoved(fromBoxID, fromPath, toBoxID, toPath, id string) {
+	webhookOnDocEvent(conf.WebhookEventDocMoved, id, map[string]any{"fromBox": fromBoxID, "fromPath": fromPath})
+}
+
+func webhookOnDocRemoved(boxID, p, id, hPath string, ids []string) {
+	webhooks := subscribedWebhooks(conf.WebhookEventDocRemoved)
+	if 1 > len(webhooks) {
+		return
+	}
+
+	doc := &WebhookBlock{ID: id, RootID: id, Box: boxID, Path: p, HPath: hPath, Type: "d", Removed: true}
+	enqueueWebhookEvent(webhooks, &webhookSource{
+		typ:    conf.WebhookEventDocRemoved,
+		blocks: []*WebhookBlock{doc},
+		data: func(blocks []*WebhookBlock) any {
+			return map[string]any{"doc": blocks[0], "ids": ids}
+		},
+	})
+}
+
+func webhookOnDocEvent(typ, id string, extra map[string]any) {
+	webhooks := subscribedWebhooks(typ)
+	if 1 > len(webhooks) {
+		return
+	}
+
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return
+	}
+	doc := &WebhookBlock{ID: bt.ID, RootID: bt.RootID, Box: bt.BoxID, Path: bt.Path, HPath: bt.HPath, Type: bt.Type}
+	if webhooksNeedAttrs(webhooks) {
+		loadWebhookBlockAttrs([]*WebhookBlock{doc})
+	}
+	enqueueWebhookEvent(webhooks, &webhookSource{
+		typ:    typ,
+		blocks: []*WebhookBlock{doc},
+		data: func(blocks []*WebhookBlock) any {
+			ret := map[string]any{"doc": blocks[0]}
+			for k, v := range extra {
+				ret[k] = v
+			}
+			return ret
+		},
+	})
+}
+
+func webhookOnAvCellUpdated(avID, keyID, itemID, boundBlockID string, value *av.Value) {
+	webhooks := subscribedWebhooks(conf.WebhookEventAvCellUpdated)
+	if 1 > len(webhooks) {
+		return
+	}
+
+	// 加密笔记本中的数据库不投递，避免单元格值以明文发送到外部地址
+	if _, boxID := av.FindAttributeViewPath(avID); "" != boxID && IsEncryptedBox(boxID) {
+		return
+	}
+
+	// 未绑定块的条目没有所属文档，仅投递给未设置笔记本、路径、块类型和属性过滤条件的 Webhook
+	var blocks []*WebhookBlock
+	if "" != boundBlockID {
+		bt := treenode.GetBlockTree(boundBlockID)
+		if nil == bt {
+			// 无法确定绑定块所属的笔记本时不按未绑定块的条目投递
+			return
+		}
+		blocks = append(blocks, &WebhookBlock{ID: bt.ID, RootID: bt.RootID, Box: bt.BoxID, Path: bt.Path, HPath: bt.HPath, Type: bt.Type})
+		if webhooksNeedAttrs(webhooks) {
+			loadWebhookBlockAttrs(blocks)
+		}
+	}
+
+	// 事件处理是同步的，先序列化单元格值，避免后续修改影响负载
+	valueData, err := gulu.JSON.MarshalJSON(value)
+	if err != nil {
+		logging.LogErrorf("marshal attribute view value failed: %s", err)
+		return
+	}
+	enqueueWebhookEvent(webhooks, &webhookSource{
+		typ:    conf.WebhookEventAvCellUpdated,
+		blocks: blocks,
+		data: func(blocks []*WebhookBlock) any {
+			ret := map[string]any{"avID": avID, "keyID": keyID, "itemID": itemID, "value": json.RawMessage(valueData)}
+			if 0 < len(blocks) {
+				ret["block"] = blocks[0]
+			}
+			return ret
+		},
+	})
+}
+
+// enqueueWebhookEvent 按各 Webhook 的过滤条件筛选事件涉及的块，生成投递记录等待投递任务发送。
+// 加密笔记本中的块不会出现在负载中，避免文档路径等信息以明文发送到外部地址。涉及的块全部位于加密笔记本时丢弃事件。
+func enqueueWebhookEvent(webhooks []*conf.Webhook, source *webhookSource) {
+	if 0 < len(source.blocks) {
+		source.blocks = slices.DeleteFunc(source.blocks, func(b *WebhookBlock) bool { return IsEncryptedBox(b.Box) })
+		if 1 > len(source.blocks) {
+			return
+		}
+	}
+
+	for _, webhook := range webhooks {
+		var blocks []*WebhookBlock
+		if 1 > len(source.blocks) {
+			// 只有未绑定块的数据库条目没有涉及的块
+			if conf.WebhookEventAvCellUpdated != source.typ || webhookHasBlockFilter(webhook) {
+				continue
+			}
+		} else {
+			for _, block := range source.blocks {
+				if matchWebhookBlock(webhook, block) {
+					blocks = append(blocks, block)
+				}
+			}
+			if 1 > len(blocks) {
+				continue
+			}
+		}
+
+		delivery, err := newWebhookDelivery(webhook.ID, newWebhookEvent(source.typ, source.data(blocks)))
+		if nil != err {
+			logging.LogErrorf("create webhook [%s] delivery failed: %s", webhook.ID, err)
+			continue
+		}
+		addWebhookDelivery(delivery)
+	}
+}
+
+// subscribedWebhooks 返回已启用且订阅了事件类型的 Webhook。
+func subscribedWebhooks(typ string) (ret []*conf.Webhook) {
+	webhookLock.RLock()
+	defer webhookLock.RUnlock()
+
+	if nil == Conf || nil == Conf.Webhooks {
+		return
+	}
+	for _, webhook := range Conf.Webhooks.Items {
+		if webhook.Enabled && (1 > len(webhook.Events) || slices.Contains(webhook.Events, typ)) {
+			ret = append(ret, copyWebhook(webhook, false))
+		}
+	}
+	return
+}
+
+func webhookHasBlockFilter(webhook *conf.Webhook) bool {
+	return 0 < len(webhook.Boxes) || 0 < len(webhook.Paths) || 0 < len(webhook.BlockTypes) || 0 < len(webhook.Attrs)
+}
+
+func webhooksNeedAttrs(webhooks []*conf.Webhook) bool {
+	for _, webhook := range webhooks {
+		if 0 < len(webhook.Attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWebhookBlock 判断块是否满足 Webhook 的过滤条件。
+func matchWebhookBlock(webhook *conf.Webhook, block *WebhookBlock) bool {
+	if 0 < len(webhook.Boxes) && !slices.Contains(webhook.Boxes, block.Box) {
+		return false
+	}
+
+	if 0 < len(webhook.Paths) {
+		matched := false
+		for _, p := range webhook.Paths {
+			if matchWebhookPath(block.Path, p) || matchWebhookPath(block.HPath, p) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if 0 < len(webhook.BlockTypes) && !slices.Contains(webhook.BlockTypes, block.Type) {
+		return false
+	}
+
+	for name, value := range webhook.Attrs {
+		v, ok := block.attrs[name]
+		if !ok || ("" != value && value != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchWebhookPath 按路径段匹配前缀，/日记 匹配 /日记 和 /日记/2026，不匹配 /日记本。
+func matchWebhookPath(p, prefix string) bool {
+	prefix = strings.TrimSuffix(strings.TrimSuffix(prefix, ".sy"), "/")
+	if "" == prefix {
+		return true
+	}
+	p = strings.TrimSuffix(p, ".sy")
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// loadWebhookBlockAttrs 加载块的当前属性，同一文档只加载一次。已删除的块使用数据库索引中的属性。
+func loadWebhookBlockAttrs(blocks []*WebhookBlock) {
+	luteEngine := util.NewLute()
+	trees := map[string]*parse.Tree{}
+	for _, block := range blocks {
+		if block.Removed {
+			continue
+		}
+
+		tree, ok := trees[block.RootID]
+		if !ok {
+			tree, _ = filesys.LoadTree(block.Box, block.Path, luteEngine)
+			trees[block.RootID] = tree
+		}
+		if nil == tree {
+			continue
+		}
+		if node := treenode.GetNodeInTree(tree, block.ID); nil != node {
+			block.attrs = parse.IAL2Map(node.KramdownIAL)
+		}
+	}
+}
+
+func newWebhookEvent(typ string, data any) *WebhookEvent {
+	return &WebhookEvent{ID: ast.NewNodeID(), Type: typ, Timestamp: time.Now().UnixMilli(), Data: data}
+}
+
+func newWebhookDelivery(webhookID string, event *WebhookEvent) (ret *WebhookDelivery, err error) {
+	payload, err := gulu.JSON.MarshalJSON(event)
+	if nil != err {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	ret = &WebhookDelivery{
+		ID:          ast.NewNodeID(),
+		WebhookID:   webhookID,
+		Event:       event.Type,
+		EventID:     event.ID,
+		Payload:     string(payload),
+		Status:      WebhookDeliveryPending,
+		NextAttempt: now,
+		Created:     now,
+	}
+	return
+}
+
+func addWebhookDelivery(delivery *WebhookDelivery) {
+	webhookDeliveryLock.Lock()
+	defer webhookDeliveryLock.Unlock()
+
+	webhookDeliveries = trimWebhookDeliveries(append(webhookDeliveries, delivery), webhookDeliveryLogMax)
+	webhookDeliveriesDirty = true
+}
+
+// trimWebhookDeliveries 将投递日志裁剪到 max 条，优先丢弃最早的已结束条目，仍超出时再丢弃最早的待投递条目。
+func trimWebhookDeliveries(deliveries []*WebhookDelivery, max int) []*WebhookDelivery {
+	overflow := len(deliveries) - max
+	if 0 >= overflow {
+		return deliveries
+	}
+
+	ret := deliveries[:0]
+	for _, delivery := range deliveries {
+		if 0 < overflow && WebhookDeliveryPending != delivery.Status {
+			overflow--
+			continue
+		}
+		ret = append(ret, delivery)
+	}
+	if 0 < overflow {
+		for _, delivery := range ret[:overflow] {
+			logging.LogWarnf("drop pending webhook [%s] delivery [%s]", delivery.WebhookID, delivery.ID)
+		}
+		ret = ret[overflow:]
+	}
+	return ret
+}
+
+// attemptWebhookDelivery 发送一次投递并更新投递记录的状态。
+// 网络错误、超时、5xx、408 和 429 会按指数退避重试，其它 4xx 视为永久失败。
+func attemptWebhookDelivery(webhook *conf.Webhook, delivery *WebhookDelivery, now time.Time) {
+	delivery.Attempts++
+	delivery.LastAttempt = now.UnixMilli()
+	delivery.ResponseCode = 0
+	delivery.Error = ""
+
+	code, err := postWebhook(webhook, delivery, now)
+	delivery.ResponseCode = code
+	if nil == err && 200 <= code && 300 > code {
+		delivery.Status = WebhookDeliverySucceeded
+		return
+	}
+
+	if nil != err {
+		delivery.Error = err.Error()
+	} else {
+		delivery.Error = http.StatusText(code)
+	}
+	retryable := nil != err || 500 <= code || http.StatusRequestTimeout == code || http.StatusTooManyRequests == code
+	if !retryable || webhookDeliveryMaxAttempts <= delivery.Attempts || conf.WebhookEventPing == delivery.Event {
+		delivery.Status = WebhookDeliveryFailed
+		return
+	}
+	delivery.Status = WebhookDeliveryPending
+	delivery.NextAttempt = now.Add(webhookRetryDelay(delivery.Attempts)).UnixMilli()
+}
+
+func postWebhook(webhook *conf.Webhook, delivery *WebhookDelivery, now time.Time) (code int, err error) {
+	if nil == webhookHTTPClient {
+		webhookHTTPClient = httpclient.NewUserAgentClient(nil)
+		webhookHTTPClient.Timeout = webhookDeliveryTimeout
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if nil != err {
+		return
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-SiYuan-Event", delivery.Event)
+	req.Header.Set("X-SiYuan-Delivery", delivery.ID)
+	req.Header.Set("X-SiYuan-Webhook", webhook.ID)
+	req.Header.Set("X-SiYuan-Timestamp", timestamp)
+	req.Header.Set("X-SiYuan-Signature", "sha256="+signWebhookPayload(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := webhookHTTPClient.Do(req)
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	code = resp.StatusCode
+	return
+}
+
+// signWebhookPayload 计算签名：HMAC-SHA256(secret, timestamp + "." + body) 的十六进制编码。
+// 接收方应使用请求头 X-SiYuan-Timestamp 和原始请求体重新计算并比较，同时校验时间戳以防重放。
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay 返回第 attempts 次投递失败后的重试间隔，从 10 秒开始翻倍，最长 1 小时。
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookDeliveryBaseDelay
+	for i := 1; i < attempts && delay < webhookDeliveryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookDeliveryMaxDelay)
+}
+
+func checkWebhook(webhook *conf.Webhook) error {
+	u, err := url.Parse(strings.TrimSpace(webhook.URL))
+	if nil != err || ("http" != u.Scheme && "https" != u.Scheme) || "" == u.Host {
+		return ErrWebhookInvalidURL
+	}
+	webhook.URL = u.String()
+
+	for _, event := range webhook.Events {
+		if !slices.Contains(conf.WebhookEvents, event) {
+			return ErrWebhookInvalidEvent
+		}
+	}
+	sort.Strings(webhook.Events)
+	return nil
+}
+
+func getWebhookByID(id string) *conf.Webhook {
+	for _, webhook := range Conf.Webhooks.Items {
+		if id == webhook.ID {
+			return webhook
+		}
+	}
+	return nil
+}
+
+func copyWebhook(webhook *conf.Webhook, maskSecret bool) *conf.Webhook {
+	ret := *webhook
+	ret.Events = slices.Clone(webhook.Events)
+	ret.Boxes = slices.Clone(webhook.Boxes)
+	ret.Paths = slices.Clone(webhook.Paths)
+	ret.BlockTypes = slices.Clone(webhook.BlockTypes)
+	if nil != webhook.Attrs {
+		ret.Attrs = map[string]string{}
+		for k, v := range webhook.Attrs {
+			ret.Attrs[k] = v
+		}
+	}
+	if maskSecret && "" != ret.Secret {
+		ret.Secret = maskedWebhookSecret
+	}
+	return &ret
+}
+
+func copyWebhookDelivery(delivery *WebhookDelivery) *WebhookDelivery {
+	ret := *delivery
+	return &ret
+}
@@ -0,0 +1,200 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestMatchWebhookBlock(t *testing.T) {
+	block := &WebhookBlock{
+		ID: "20260101000000-aaaaaaa", RootID: "20260101000000-rrrrrrr", Box: "20260101000000-bbbbbbb",
+		Path: "/20260101000000-ppppppp/20260101000000-rrrrrrr.sy", HPath: "/日记/2026", Type: "p",
+		attrs: map[string]string{"custom-sync": "true"},
+	}
+
+	cases := []struct {
+		webhook *conf.Webhook
+		match   bool
+	}{
+		{&conf.Webhook{}, true},
+		{&conf.Webhook{Boxes: []string{"20260101000000-bbbbbbb"}}, true},
+		{&conf.Webhook{Boxes: []string{"20260101000000-ccccccc"}}, false},
+		{&conf.Webhook{Paths: []string{"/日记"}}, true},
+		{&conf.Webhook{Paths: []string{"/日"}}, false},
+		{&conf.Webhook{Paths: []string{"/20260101000000-ppppppp"}}, true},
+		{&conf.Webhook{Paths: []string{"/20260101000000-ppppppp/20260101000000-rrrrrrr.sy"}}, true},
+		{&conf.Webhook{BlockTypes: []string{"h", "p"}}, true},
+		{&conf.Webhook{BlockTypes: []string{"d"}}, false},
+		{&conf.Webhook{Attrs: map[string]string{"custom-sync": ""}}, true},
+		{&conf.Webhook{Attrs: map[string]string{"custom-sync": "true"}}, true},
+		{&conf.Webhook{Attrs: map[string]string{"custom-sync": "false"}}, false},
+		{&conf.Webhook{Attrs: map[string]string{"custom-other": ""}}, false},
+		{&conf.Webhook{Boxes: []string{"20260101000000-bbbbbbb"}, BlockTypes: []string{"d"}}, false},
+	}
+	for i, c := range cases {
+		if got := matchWebhookBlock(c.webhook, block); got != c.match {
+			t.Fatalf("用例 [%d] 过滤结果错误：期望 %v，实际 %v", i, c.match, got)
+		}
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second}
+	for i, delay := range expected {
+		if got := webhookRetryDelay(i + 1); delay != got {
+			t.Fatalf("第 %d 次重试间隔错误：期望 %s，实际 %s", i+1, delay, got)
+		}
+	}
+	if got := webhookRetryDelay(20); webhookDeliveryMaxDelay != got {
+		t.Fatalf("重试间隔应不超过上限：%s", got)
+	}
+}
+
+func TestTrimWebhookDeliveries(t *testing.T) {
+	deliveries := []*WebhookDelivery{
+		{ID: "1", Status: WebhookDeliveryPending},
+		{ID: "2", Status: WebhookDeliverySucceeded},
+		{ID: "3", Status: WebhookDeliveryFailed},
+		{ID: "4", Status: WebhookDeliveryPending},
+		{ID: "5", Status: WebhookDeliverySucceeded},
+	}
+	deliveries = trimWebhookDeliveries(deliveries, 3)
+	if 3 != len(deliveries) || "1" != deliveries[0].ID || "4" != deliveries[1].ID || "5" != deliveries[2].ID {
+		t.Fatalf("应优先丢弃最早的已结束条目")
+	}
+	deliveries = trimWebhookDeliveries(deliveries, 1)
+	if 1 != len(deliveries) || "4" != deliveries[0].ID {
+		t.Fatalf("仍超出上限时应丢弃最早的待投递条目")
+	}
+}
+
+func TestAttemptWebhookDelivery(t *testing.T) {
+	status := http.StatusOK
+	var signature, timestamp, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		signature = r.Header.Get("X-SiYuan-Signature")
+		timestamp = r.Header.Get("X-SiYuan-Timestamp")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook := &conf.Webhook{ID: "20260101000000-wwwwwww", URL: server.URL, Secret: "secret"}
+	delivery, err := newWebhookDelivery(webhook.ID, newWebhookEvent(conf.WebhookEventBlockChanged, map[string]any{"blocks": []any{}}))
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	attemptWebhookDelivery(webhook, delivery, now)
+	if WebhookDeliverySucceeded != delivery.Status || 1 != delivery.Attempts || http.StatusOK != delivery.ResponseCode {
+		t.Fatalf("投递成功后状态错误：%+v", delivery)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(timestamp + "." + body))
+	if body != delivery.Payload || "sha256="+hex.EncodeToString(mac.Sum(nil)) != signature {
+		t.Fatalf("签名错误：%s", signature)
+	}
+
+	status = http.StatusServiceUnavailable
+	delivery.Status = WebhookDeliveryPending
+	delivery.Attempts = 0
+	attemptWebhookDelivery(webhook, delivery, now)
+	if WebhookDeliveryPending != delivery.Status || now.Add(webhookDeliveryBaseDelay).UnixMilli() != delivery.NextAttempt {
+		t.Fatalf("服务端错误时应退避重试：%+v", delivery)
+	}
+	delivery.Attempts = webhookDeliveryMaxAttempts - 1
+	attemptWebhookDelivery(webhook, delivery, now)
+	if WebhookDeliveryFailed != delivery.Status {
+		t.Fatalf("超过最大重试次数后应标记为失败：%+v", delivery)
+	}
+
+	status = http.StatusBadRequest
+	delivery.Status = WebhookDeliveryPending
+	delivery.Attempts = 0
+	attemptWebhookDelivery(webhook, delivery, now)
+	if WebhookDeliveryFailed != delivery.Status || 1 != delivery.Attempts {
+		t.Fatalf("客户端错误不应重试：%+v", delivery)
+	}
+}
+
+func TestEnqueueWebhookEventSkipsEncryptedBoxes(t *testing.T) {
+	originalConf, originalDataDir := Conf, util.DataDir
+	t.Cleanup(func() {
+		Conf, util.DataDir = originalConf, originalDataDir
+		webhookDeliveryLock.Lock()
+		webhookDeliveries = nil
+		webhookDeliveryLock.Unlock()
+	})
+	util.DataDir = t.TempDir()
+	Conf = NewAppConf()
+	Conf.Webhooks = conf.NewWebhooks()
+	Conf.Webhooks.Items = []*conf.Webhook{{ID: "20260101000000-wwwwwww", Enabled: true}}
+
+	encryptedBoxID, plainBoxID := "20260101000000-eeeeeee", "20260101000000-bbbbbbb"
+	boxConf := conf.NewBoxConf()
+	boxConf.Encrypted = true
+	if err := (&Box{ID: encryptedBoxID}).SaveConf(boxConf); nil != err {
+		t.Fatal(err)
+	}
+	deliveries := func() int {
+		webhookDeliveryLock.Lock()
+		defer webhookDeliveryLock.Unlock()
+		return len(webhookDeliveries)
+	}
+
+	// 涉及的块全部位于加密笔记本时不应按未绑定块的条目投递
+	webhooks := subscribedWebhooks(conf.WebhookEventAvCellUpdated)
+	enqueueWebhookEvent(webhooks, &webhookSource{
+		typ:    conf.WebhookEventAvCellUpdated,
+		blocks: []*WebhookBlock{{ID: "20260101000000-aaaaaaa", Box: encryptedBoxID}},
+		data:   func([]*WebhookBlock) any { return map[string]any{"value": "secret"} },
+	})
+	if 0 != deliveries() {
+		t.Fatal("加密笔记本中的块不应投递")
+	}
+
+	// 加密笔记本中数据库的未绑定块条目不应投递
+	encryptedAvID, plainAvID := "20260101000000-avaaaaa", "20260101000000-avbbbbb"
+	av.SetAVBoxID(encryptedAvID, encryptedBoxID)
+	t.Cleanup(func() { av.SetAVBoxID(encryptedAvID, "") })
+	value := &av.Value{Type: av.KeyTypeText, Text: &av.ValueText{Content: "secret"}}
+	webhookOnAvCellUpdated(encryptedAvID, "20260101000000-kkkkkkk", "20260101000000-iiiiiii", "", value)
+	if 0 != deliveries() {
+		t.Fatal("加密笔记本中数据库的条目不应投递")
+	}
+
+	av.SetAVBoxID(plainAvID, plainBoxID)
+	t.Cleanup(func() { av.SetAVBoxID(plainAvID, "") })
+	webhookOnAvCellUpdated(plainAvID, "20260101000000-kkkkkkk", "20260101000000-iiiiiii", "", value)
+	if 1 != deliveries() {
+		t.Fatal("普通笔记本中数据库的未绑定块条目应投递")
+	}
+}
@@ -20,7 +20,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -63,6 +62,9 @@ func injectClient(p *KernelPlugin, rt *goja.Runtime, siyuan *goja.Object) (err e
 		if argErr == nil && !strings.HasPrefix(path, "/") {
 			argErr = fmt.Errorf("path must start with /")
 		}
+		if argErr == nil {
+			argErr = p.checkClientPermission(path)
+		}
 		if argErr == nil {
 			if init := call.Argument(1); isJsValueNotNull(init) {
 				if initObj := init.ToObject(rt); initObj != nil {
@@ -136,7 +138,7 @@ func injectClient(p *KernelPlugin, rt *goja.Runtime, siyuan *goja.Object) (err e
 				}
 
 				defer resp.Body.Close()
-				body, readErr := io.ReadAll(resp.Body)
+				body, readErr := p.limits.readLimited(resp.Body)
 				if readErr != nil {
 					err = fmt.Errorf("failed to read response body: %w", readErr)
 					return
@@ -207,6 +209,9 @@ func injectClient(p *KernelPlugin, rt *goja.Runtime, siyuan *goja.Object) (err e
 		if argErr == nil && !strings.HasPrefix(path, "/") {
 			argErr = fmt.Errorf("path must start with /")
 		}
+		if argErr == nil {
+			argErr = p.checkClientPermission(path)
+		}
 		if argErr == nil {
 			if proto := call.Argument(1); isJsValueNotNull(proto) {
 				if protoObj := proto.ToObject(rt); protoObj != nil && protoObj.ClassName() == "Array" {
@@ -632,6 +637,9 @@ func injectClient(p *KernelPlugin, rt *goja.Runtime, siyuan *goja.Object) (err e
 		if argErr == nil && !strings.HasPrefix(path, "/") {
 			argErr = fmt.Errorf("path must start with /")
 		}
+		if argErr == nil {
+			argErr = p.checkClientPermission(path)
+		}
 
 		runErr := p.worker.Run(func(rt *goja.Runtime) (result any, err error) {
 			if argErr != nil {
@@ -0,0 +1,150 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/samber/lo"
+	"github.com/siyuan-note/logging"
+)
+
+// injectSchedule adds siyuan.schedule.* methods for cron and fixed interval scheduled jobs.
+func injectSchedule(p *KernelPlugin, rt *goja.Runtime, siyuan *goja.Object) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("injectSchedule: %v", r)
+		}
+	}()
+
+	schedule := rt.NewObject()
+
+	// settle resolves or rejects the promise of a siyuan.schedule.* call with the worker task result.
+	settle := func(method string, resolve, reject func(any) error) TaskCallback {
+		return func(rt *goja.Runtime, result any, err error) {
+			if lo.IsNil(err) {
+				if resolveErr := resolve(result); resolveErr != nil {
+					logging.LogErrorf("[plugin:%s] siyuan.schedule.%s resolve: %v", p.Name, method, resolveErr)
+				}
+			} else {
+				if rejectErr := reject(rt.NewGoError(err)); rejectErr != nil {
+					logging.LogErrorf("[plugin:%s] siyuan.schedule.%s reject: %v", p.Name, method, rejectErr)
+				}
+			}
+		}
+	}
+	rejectOnRunError := func(rt *goja.Runtime, method string, reject func(any) error, runErr error) {
+		if runErr != nil {
+			logging.LogErrorf("[plugin:%s] siyuan.schedule.%s worker run: %v", p.Name, method, runErr)
+			if rejectErr := reject(rt.NewGoError(runErr)); rejectErr != nil {
+				logging.LogErrorf("[plugin:%s] siyuan.schedule.%s reject on run error: %v", p.Name, method, rejectErr)
+			}
+		}
+	}
+
+	// siyuan.schedule.add(id, { cron?: string, interval?: number, catchUp?: "skip" | "once" | "all" }, handler) → Promise<Schedule>
+	lo.Must0(schedule.Set("add", rt.ToValue(func(call goja.FunctionCall, rt *goja.Runtime) goja.Value {
+		promise, resolve, reject := rt.NewPromise()
+
+		var argErr error
+		var handler goja.Callable
+		s := &Schedule{}
+		if len(call.Arguments) < 3 {
+			argErr = fmt.Errorf("add requires 3 arguments: id, options, handler")
+		} else if id := call.Argument(0); !goja.IsString(id) || id.String() == "" {
+			argErr = fmt.Errorf("first argument must be a non-empty schedule id string")
+		} else {
+			s.ID = id.String()
+			if options := call.Argument(1); isJsValueNotNull(options) {
+				optionsObj := options.ToObject(rt)
+				if cron := optionsObj.Get("cron"); isJsValueNotNull(cron) {
+					s.Cron = cron.String()
+				}
+				if interval := optionsObj.Get("interval"); isJsValueNotNull(interval) {
+					s.Interval = interval.ToInteger()
+				}
+				if catchUp := optionsObj.Get("catchUp"); isJsValueNotNull(catchUp) {
+					s.CatchUp = ScheduleCatchUp(catchUp.String())
+				}
+			} else {
+				argErr = fmt.Errorf("second argument must be an options object")
+			}
+			if argErr == nil {
+				if fn, ok := goja.AssertFunction(call.Argument(2)); ok {
+					handler = fn
+				} else {
+					argErr = fmt.Errorf("third argument must be a handler function")
+				}
+			}
+		}
+
+		runErr := p.worker.Run(func(rt *goja.Runtime) (result any, err error) {
+			if argErr != nil {
+				err = argErr
+				return
+			}
+			result, err = p.addSchedule(s, handler)
+			return
+		}, settle("add", resolve, reject))
+		rejectOnRunError(rt, "add", reject, runErr)
+
+		return rt.ToValue(promise)
+	})))
+
+	// siyuan.schedule.remove(id) → Promise<void>
+	lo.Must0(schedule.Set("remove", rt.ToValue(func(call goja.FunctionCall, rt *goja.Runtime) goja.Value {
+		promise, resolve, reject := rt.NewPromise()
+
+		var argErr error
+		var id string
+		if idArg := call.Argument(0); goja.IsString(idArg) {
+			id = idArg.String()
+		} else {
+			argErr = fmt.Errorf("first argument must be a schedule id string")
+		}
+
+		runErr := p.worker.Run(func(rt *goja.Runtime) (result any, err error) {
+			if argErr != nil {
+				err = argErr
+				return
+			}
+			p.removeSchedule(id)
+			return
+		}, settle("remove", resolve, reject))
+		rejectOnRunError(rt, "remove", reject, runErr)
+
+		return rt.ToValue(promise)
+	})))
+
+	// siyuan.schedule.list() → Promise<Schedule[]>
+	lo.Must0(schedule.Set("list", rt.ToValue(func(call goja.FunctionCall, rt *goja.Runtime) goja.Value {
+		promise, resolve, reject := rt.NewPromise()
+
+		runErr := p.worker.Run(func(rt *goja.Runtime) (result any, err error) {
+			result = p.listSchedules()
+			return
+		}, settle("list", resolve, reject))
+		rejectOnRunError(rt, "list", reject, runErr)
+
+		return rt.ToValue(promise)
+	})))
+
+	lo.Must0(ObjectFreeze(rt, schedule))
+	lo.Must0(siyuan.Set("schedule", schedule))
+	return
+}
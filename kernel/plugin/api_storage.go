@@ -41,6 +41,10 @@ func injectStorage(p *KernelPlugin, rt *goja.Runtime, siyuan *goja.Object) (err
 		abs = filepath.Join(p.storageDir, filepath.Clean(relPath))
 		if !(abs == p.storageDir || strings.HasPrefix(abs, p.storageDir+string(filepath.Separator))) {
 			err = fmt.Errorf("siyuan.storage: path traversal not allowed")
+			return
+		}
+		if permissionErr := p.checkStoragePermission(relPath); permissionErr != nil {
+			err = fmt.Errorf("siyuan.storage: %w", permissionErr)
 		}
 		return
 	}
@@ -197,6 +201,12 @@ func injectStorage(p *KernelPlugin, rt *goja.Runtime, siyuan *goja.Object) (err
 					})
 				}()
 
+				if info, statErr := os.Stat(abs); statErr == nil {
+					if err = p.limits.checkDataSize(info.Size()); err != nil {
+						return
+					}
+				}
+
 				data, readErr := filelock.ReadFile(abs)
 				if readErr != nil {
 					err = readErr
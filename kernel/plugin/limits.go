@@ -0,0 +1,218 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// SandboxLimits defines the resource budgets of a kernel plugin's goja runtime.
+//
+// goja allocates on the shared Go heap without per-runtime accounting, so the heap budget is approximate:
+// the watchdog samples the process-wide heap while a plugin call is running, and kernel work running at the same time
+// (indexing, embedding, sync) is counted as well. The default is generous enough to only catch runaway allocations.
+// Data handed to JS by the exposed APIs is bounded exactly by MaxDataSize.
+type SandboxLimits struct {
+	CallTimeout      time.Duration // Max execution time of a single call on the event loop before the watchdog interrupts it
+	MaxHeapGrowth    uint64        // Approximate max heap growth in bytes while a single call is executing, sampled every heapSampleInterval
+	MaxDataSize      int64         // Max size in bytes of a fetch response, a storage file or an RPC request body handed to JS
+	MaxCallStackSize int           // Max JS call stack depth
+	MaxConcurrentRpc int           // Max in-flight RPC requests, excess requests are rejected
+}
+
+// DefaultSandboxLimits is applied to every kernel plugin.
+var DefaultSandboxLimits = SandboxLimits{
+	CallTimeout:      30 * time.Second,
+	MaxHeapGrowth:    1 << 30,
+	MaxDataSize:      64 << 20,
+	MaxCallStackSize: 1024,
+	MaxConcurrentRpc: 64,
+}
+
+// heapSampleInterval bounds how long a call can allocate before the watchdog samples the heap.
+const heapSampleInterval = 100 * time.Millisecond
+
+type SandboxViolationKind string
+
+const (
+	SandboxViolationTimeout SandboxViolationKind = "timeout"
+	SandboxViolationMemory  SandboxViolationKind = "memory"
+)
+
+var ErrDataTooLarge = errors.New("data size exceeds the sandbox limit")
+
+// SandboxViolation records a budget violation that moved the plugin into the error state.
+type SandboxViolation struct {
+	Kind    SandboxViolationKind `json:"kind"`
+	Handler string               `json:"handler"` // Offending handler, e.g. "rpc:echo"
+	Message string               `json:"message"`
+	Time    int64                `json:"time"` // Unix milliseconds
+}
+
+func (v *SandboxViolation) Error() string {
+	return fmt.Sprintf("sandbox %s violation in [%s]: %s", v.Kind, v.Handler, v.Message)
+}
+
+// heapBytes samples the Go heap size occupied by live and unswept objects.
+// goja allocates on the shared Go heap, so this is a process-wide sample.
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// checkDataSize returns ErrDataTooLarge if size exceeds MaxDataSize.
+func (l *SandboxLimits) checkDataSize(size int64) error {
+	if 0 < l.MaxDataSize && l.MaxDataSize < size {
+		return fmt.Errorf("%w: %d bytes, max %d bytes", ErrDataTooLarge, size, l.MaxDataSize)
+	}
+	return nil
+}
+
+// readLimited reads r up to MaxDataSize bytes, returning ErrDataTooLarge if there is more.
+func (l *SandboxLimits) readLimited(r io.Reader) ([]byte, error) {
+	if l.MaxDataSize <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, l.MaxDataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if err = l.checkDataSize(int64(len(data))); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// watchdogInterval returns how often the watchdog checks the event loop.
+func (l *SandboxLimits) watchdogInterval() time.Duration {
+	interval := l.CallTimeout / 4
+	if interval <= 0 || interval > time.Second {
+		interval = time.Second
+	}
+	if 0 < l.MaxHeapGrowth && interval > heapSampleInterval {
+		interval = heapSampleInterval
+	}
+	return interval
+}
+
+// heartbeat is a probe job queued on the event loop, used to detect code blocking the loop outside named worker tasks (e.g. timer callbacks).
+type heartbeat struct {
+	sentTime time.Time
+	sentHeap uint64
+	done     atomic.Bool
+}
+
+// watch monitors the plugin's event loop and interrupts calls that exceed the sandbox budgets.
+// It exits when the plugin context is cancelled.
+func (p *KernelPlugin) watch(vm *goja.Runtime) {
+	limits := p.limits
+	ticker := time.NewTicker(limits.watchdogInterval())
+	defer ticker.Stop()
+
+	var beat *heartbeat
+	for {
+		select {
+		case <-p.context.Done():
+			return
+		case now := <-ticker.C:
+			if beat == nil || beat.done.Load() {
+				beat = &heartbeat{sentTime: now, sentHeap: heapBytes()}
+				current := beat
+				if !p.worker.loop.RunOnLoop(func(*goja.Runtime) { current.done.Store(true) }) {
+					return
+				}
+			}
+
+			violation := p.checkLimits(now, beat)
+			if violation == nil {
+				continue
+			}
+
+			p.violate(vm, violation)
+			return
+		}
+	}
+}
+
+// checkLimits returns the violation of the current call on the event loop, or nil if it is within budgets.
+func (p *KernelPlugin) checkLimits(now time.Time, beat *heartbeat) *SandboxViolation {
+	limits := p.limits
+
+	handler := "timer or promise callback"
+	startTime, startHeap := beat.sentTime, beat.sentHeap
+	if task := p.worker.Current(); task != nil {
+		handler = task.Name
+		startTime, startHeap = task.StartTime, task.StartHeap
+	} else if beat.done.Load() {
+		// The event loop is idle
+		return nil
+	}
+
+	if elapsed := now.Sub(startTime); 0 < limits.CallTimeout && limits.CallTimeout < elapsed {
+		return &SandboxViolation{
+			Kind:    SandboxViolationTimeout,
+			Handler: handler,
+			Message: fmt.Sprintf("execution exceeded %s", limits.CallTimeout),
+			Time:    now.UnixMilli(),
+		}
+	}
+
+	if heap := heapBytes(); 0 < limits.MaxHeapGrowth && startHeap < heap && limits.MaxHeapGrowth < heap-startHeap {
+		return &SandboxViolation{
+			Kind:    SandboxViolationMemory,
+			Handler: handler,
+			Message: fmt.Sprintf("heap grew by about %d bytes, exceeding %d bytes", heap-startHeap, limits.MaxHeapGrowth),
+			Time:    now.UnixMilli(),
+		}
+	}
+	return nil
+}
+
+// violate interrupts the running JS code and moves the plugin into the error state.
+func (p *KernelPlugin) violate(vm *goja.Runtime, violation *SandboxViolation) {
+	p.violation.Store(violation)
+	logging.LogErrorf("[plugin:%s] %s", p.Name, violation)
+	util.PushErrMsg(fmt.Sprintf("[plugin:%s] %s", p.Name, violation), 7000)
+
+	vm.Interrupt(violation)
+
+	if p.State() == PluginStateLoading {
+		// start() fails with the interrupted error and cleans up by itself
+		return
+	}
+
+	p.unsubscribeEventHandlers()
+	p.error()
+}
+
+// Violation returns the last sandbox violation of the plugin, or nil.
+func (p *KernelPlugin) Violation() *SandboxViolation {
+	return p.violation.Load()
+}
@@ -0,0 +1,134 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/eventloop"
+	"github.com/siyuan-note/siyuan/kernel/model"
+)
+
+func newTestSandboxPlugin(t *testing.T, limits SandboxLimits) (*KernelPlugin, *goja.Runtime) {
+	p := NewKernelPlugin(context.Background(), &model.Petal{Name: "test-plugin-sandbox"})
+	p.limits = limits
+	p.rpcSlots = make(chan struct{}, limits.MaxConcurrentRpc)
+	p.runtime = eventloop.NewEventLoop()
+	p.worker.Start(p.runtime)
+	p.runtime.Start()
+
+	vm, err := p.worker.RunSync(func(rt *goja.Runtime) (any, error) { return rt, nil })
+	if err != nil {
+		t.Fatalf("get runtime failed: %v", err)
+	}
+	if err = p.subscribeEventHandlers(); err != nil {
+		t.Fatalf("subscribe event handlers failed: %v", err)
+	}
+	p.updateState(PluginStateRunning)
+	t.Cleanup(func() { p.cancel(); p.runtime.Stop() })
+	return p, vm.(*goja.Runtime)
+}
+
+func TestSandboxWatchdogInterruptsLongCall(t *testing.T) {
+	p, vm := newTestSandboxPlugin(t, SandboxLimits{CallTimeout: 200 * time.Millisecond, MaxConcurrentRpc: 1})
+	go p.watch(vm)
+
+	done := make(chan error, 1)
+	runErr := p.worker.RunTask("rpc:spin", func(rt *goja.Runtime) (any, error) {
+		return rt.RunString("for (;;) {}")
+	}, func(_ *goja.Runtime, _ any, err error) {
+		done <- err
+	})
+	if runErr != nil {
+		t.Fatalf("run task failed: %v", runErr)
+	}
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "timeout") {
+			t.Fatalf("expected interrupted timeout error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not interrupt the long call")
+	}
+
+	violation := p.Violation()
+	if violation == nil || violation.Kind != SandboxViolationTimeout || violation.Handler != "rpc:spin" {
+		t.Fatalf("unexpected violation %+v", violation)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for p.State() != PluginStateError && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p.State() != PluginStateError {
+		t.Fatalf("expected error state, got %s", p.State())
+	}
+}
+
+func TestSandboxWatchdogInterruptsHeapGrowth(t *testing.T) {
+	p, vm := newTestSandboxPlugin(t, SandboxLimits{CallTimeout: time.Minute, MaxHeapGrowth: 64 << 20, MaxConcurrentRpc: 1})
+	go p.watch(vm)
+
+	done := make(chan error, 1)
+	runErr := p.worker.RunTask("rpc:alloc", func(rt *goja.Runtime) (any, error) {
+		return rt.RunString("const chunks = []; for (;;) { chunks.push(new Array(1e5).fill(chunks.length)) }")
+	}, func(_ *goja.Runtime, _ any, err error) {
+		done <- err
+	})
+	if runErr != nil {
+		t.Fatalf("run task failed: %v", runErr)
+	}
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "memory") {
+			t.Fatalf("expected interrupted memory error, got %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("watchdog did not interrupt the allocating call")
+	}
+
+	if violation := p.Violation(); violation == nil || violation.Kind != SandboxViolationMemory || violation.Handler != "rpc:alloc" {
+		t.Fatalf("unexpected violation %+v", violation)
+	}
+}
+
+func TestSandboxReadLimited(t *testing.T) {
+	limits := SandboxLimits{MaxDataSize: 4}
+	if data, err := limits.readLimited(strings.NewReader("1234")); err != nil || string(data) != "1234" {
+		t.Fatalf("expected data within the limit, got %q %v", data, err)
+	}
+	if _, err := limits.readLimited(strings.NewReader("12345")); !errors.Is(err, ErrDataTooLarge) {
+		t.Fatalf("expected data too large error, got %v", err)
+	}
+}
+
+func TestSandboxRejectsExcessRpc(t *testing.T) {
+	p, _ := newTestSandboxPlugin(t, SandboxLimits{MaxConcurrentRpc: 1})
+	p.rpcMethods.Store("echo", &RpcMethod{Name: "echo"})
+	p.rpcSlots <- struct{}{}
+
+	_, rpcError := p.callRpcMethod("echo", nil)
+	if rpcError == nil || rpcError.Code != JsonRpcErrorCodePluginBusy {
+		t.Fatalf("expected busy error, got %+v", rpcError)
+	}
+}
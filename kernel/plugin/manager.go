@@ -18,6 +18,7 @@ package plugin
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
@@ -51,10 +52,11 @@ type PluginManager struct {
 }
 
 type PluginInfo struct {
-	Name      string           `json:"name"`
-	State     string           `json:"state"`
-	StateCode int              `json:"stateCode"`
-	Methods   []*RpcMethodInfo `json:"methods"`
+	Name      string            `json:"name"`
+	State     string            `json:"state"`
+	StateCode int               `json:"stateCode"`
+	Methods   []*RpcMethodInfo  `json:"methods"`
+	Violation *SandboxViolation `json:"violation,omitempty"` // Last sandbox budget violation
 }
 
 var (
@@ -239,6 +241,14 @@ func (m *PluginManager) StartPlugin(petal *model.Petal) (ok bool) {
 		return
 	}
 
+	if !petal.IsPermissionsApproved() {
+		msg := fmt.Sprintf("[plugin:%s] permissions declared in plugin.json have not been approved, skip starting", petal.Name)
+		logging.LogWarnf("%s", msg)
+		util.PushErrMsg(msg, 7000)
+		ok = false
+		return
+	}
+
 	pluginMu := m.getPluginMu(petal.Name)
 	pluginMu.Lock()
 	defer pluginMu.Unlock()
@@ -321,6 +331,7 @@ func (m *PluginManager) GetLoadedPlugin(name string) (plugin *PluginInfo, found
 			State:     p.State().String(),
 			StateCode: int(p.State()),
 			Methods:   p.GetRpcMethodsInfo(),
+			Violation: p.Violation(),
 		}, true
 	}
 	return nil, false
@@ -335,6 +346,7 @@ func (m *PluginManager) GetLoadedPluginsInfo() (plugins []*PluginInfo) {
 			State:     p.State().String(),
 			StateCode: int(p.State()),
 			Methods:   p.GetRpcMethodsInfo(),
+			Violation: p.Violation(),
 		})
 		return true
 	})
@@ -0,0 +1,96 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package plugin
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/siyuan-note/siyuan/kernel/bazaar"
+	"github.com/siyuan-note/siyuan/kernel/model"
+)
+
+// pluginRole returns the kernel API role granted to a plugin with the given permissions.
+// Only writable plugins granted all API groups act as administrators; plugins limited to specific groups are editors,
+// so admin-only routes stay closed to them just like to named API tokens of the same role.
+func pluginRole(permissions *bazaar.PluginPermissions) model.Role {
+	if permissions.Readonly {
+		return model.RoleReader
+	}
+	if slices.Contains(permissions.API, bazaar.PluginPermissionAll) {
+		return model.RoleAdministrator
+	}
+	return model.RoleEditor
+}
+
+// getPermissions returns the effective permissions of the plugin, falling back to full permissions if not initialized.
+func (p *KernelPlugin) getPermissions() *bazaar.PluginPermissions {
+	if p.permissions == nil {
+		return bazaar.FullPluginPermissions()
+	}
+	return p.permissions
+}
+
+// apiGroup returns the route group of a kernel API path (e.g. "block" for "/api/block/getBlockInfo"), or "" for non-API paths.
+func apiGroup(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	rest, ok := strings.CutPrefix(path, "/api/")
+	if !ok {
+		return ""
+	}
+	group, _, _ := strings.Cut(rest, "/")
+	return group
+}
+
+// checkClientPermission checks whether the plugin may call the kernel path via siyuan.client.
+// Non-API paths (e.g. /assets/) are only allowed when all API groups are granted.
+func (p *KernelPlugin) checkClientPermission(path string) error {
+	group := apiGroup(path)
+	if p.getPermissions().AllowAPIGroup(group) {
+		return nil
+	}
+	if group == "" {
+		return fmt.Errorf("permission denied: path [%s] requires api permission [%s]", path, bazaar.PluginPermissionAll)
+	}
+	return fmt.Errorf("permission denied: path [%s] requires api permission [%s]", path, group)
+}
+
+// checkStoragePermission checks whether the plugin may access the path relative to its storage directory.
+func (p *KernelPlugin) checkStoragePermission(relPath string) error {
+	if p.getPermissions().AllowStoragePath(filepath.Clean(relPath)) {
+		return nil
+	}
+	return fmt.Errorf("permission denied: storage path [%s] is not declared", relPath)
+}
+
+// checkMcpPermission checks whether the plugin may register MCP tools.
+func (p *KernelPlugin) checkMcpPermission() error {
+	if p.getPermissions().MCP {
+		return nil
+	}
+	return fmt.Errorf("permission denied: mcp permission is not declared")
+}
+
+// checkNetworkPermission checks whether the plugin may serve HTTP, WebSocket and SSE requests.
+func (p *KernelPlugin) checkNetworkPermission() error {
+	if p.getPermissions().Network {
+		return nil
+	}
+	return fmt.Errorf("permission denied: network permission is not declared")
+}
@@ -0,0 +1,122 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/bazaar"
+	"github.com/siyuan-note/siyuan/kernel/model"
+)
+
+func TestPluginPermissions(t *testing.T) {
+	p := &KernelPlugin{permissions: &bazaar.PluginPermissions{
+		Readonly: true,
+		API:      []string{"block", "query"},
+		Storage:  []string{"cache"},
+	}}
+
+	if err := p.checkClientPermission("/api/block/getBlockInfo?id=1"); err != nil {
+		t.Fatalf("expected block API to be allowed: %s", err)
+	}
+	for _, path := range []string{"/api/filetree/createDocWithMd", "/assets/foo.png"} {
+		if err := p.checkClientPermission(path); err == nil {
+			t.Fatalf("expected path %q to be denied", path)
+		}
+	}
+
+	if err := p.checkStoragePermission("cache/a.json"); err != nil {
+		t.Fatalf("expected storage path to be allowed: %s", err)
+	}
+	for _, path := range []string{"cache-other/a.json", "config.json", "."} {
+		if err := p.checkStoragePermission(path); err == nil {
+			t.Fatalf("expected storage path %q to be denied", path)
+		}
+	}
+
+	if p.checkMcpPermission() == nil || p.checkNetworkPermission() == nil {
+		t.Fatal("expected mcp and network to be denied")
+	}
+	if pluginRole(p.permissions) != model.RoleReader || pluginRole(bazaar.FullPluginPermissions()) != model.RoleAdministrator {
+		t.Fatal("unexpected plugin role")
+	}
+	if pluginRole(&bazaar.PluginPermissions{API: []string{"block"}}) != model.RoleEditor {
+		t.Fatal("expected writable plugin limited to specific API groups to be an editor")
+	}
+}
+
+func TestPetalPermissionsApproval(t *testing.T) {
+	petal := &model.Petal{}
+	if !petal.IsPermissionsApproved() {
+		t.Fatal("expected legacy plugin without permissions to be approved")
+	}
+
+	petal.Permissions = &bazaar.PluginPermissions{API: []string{"block"}, Storage: []string{"cache"}}
+	if petal.IsPermissionsApproved() {
+		t.Fatal("expected declared permissions to require approval")
+	}
+
+	petal.ApprovedPermissions = &bazaar.PluginPermissions{API: []string{"block"}, Storage: []string{"cache"}}
+	if !petal.IsPermissionsApproved() {
+		t.Fatal("expected approved permissions to cover declared permissions")
+	}
+
+	// Expanding permissions on update requires re-approval
+	petal.Permissions = &bazaar.PluginPermissions{API: []string{"block", "filetree"}, Storage: []string{"cache"}, MCP: true}
+	if petal.IsPermissionsApproved() {
+		t.Fatal("expected expanded permissions to require approval")
+	}
+}
+
+func TestPetalEffectivePermissions(t *testing.T) {
+	petal := &model.Petal{Permissions: &bazaar.PluginPermissions{API: []string{"block", "filetree"}, Storage: []string{"cache"}, Network: true, MCP: true}}
+	if permissions := petal.EffectivePermissions(); !permissions.Readonly || 0 < len(permissions.API) || permissions.Network || permissions.MCP {
+		t.Fatalf("expected unapproved plugin to have no permissions: %+v", permissions)
+	}
+
+	// Only the approved part of expanded permissions takes effect until re-approval
+	petal.ApprovedPermissions = &bazaar.PluginPermissions{API: []string{"block"}, Storage: []string{"cache/a"}, Network: true}
+	permissions := petal.EffectivePermissions()
+	if permissions.Readonly || 1 != len(permissions.API) || "block" != permissions.API[0] || !permissions.Network || permissions.MCP {
+		t.Fatalf("unexpected effective permissions: %+v", permissions)
+	}
+	if !permissions.AllowStoragePath("cache/a/b.json") || permissions.AllowStoragePath("cache/b.json") {
+		t.Fatalf("unexpected effective storage permissions: %v", permissions.Storage)
+	}
+
+	petal.Permissions = &bazaar.PluginPermissions{API: []string{bazaar.PluginPermissionAll}}
+	petal.ApprovedPermissions = &bazaar.PluginPermissions{API: []string{bazaar.PluginPermissionAll}}
+	if permissions = petal.EffectivePermissions(); pluginRole(permissions) != model.RoleAdministrator {
+		t.Fatalf("expected plugin approved for all API groups to be an administrator: %+v", permissions)
+	}
+
+	// Removing the manifest after approval keeps the approved permissions instead of granting full access
+	petal.Permissions = nil
+	petal.ApprovedPermissions = &bazaar.PluginPermissions{API: []string{"block"}, Storage: []string{"cache"}}
+	if !petal.IsPermissionsApproved() {
+		t.Fatal("expected plugin without manifest to keep its approval")
+	}
+	permissions = petal.EffectivePermissions()
+	if 1 != len(permissions.API) || "block" != permissions.API[0] || permissions.Network || permissions.MCP || pluginRole(permissions) != model.RoleEditor {
+		t.Fatalf("expected plugin without manifest to keep the approved permissions: %+v", permissions)
+	}
+
+	petal.ApprovedPermissions = nil
+	if permissions = petal.EffectivePermissions(); !permissions.AllowAPIGroup("system") {
+		t.Fatalf("expected legacy plugin without approval record to have full permissions: %+v", permissions)
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/lxzan/gws"
 	"github.com/samber/lo"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/bazaar"
 	"github.com/siyuan-note/siyuan/kernel/mcp/tools"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -92,17 +93,21 @@ func (s PluginState) String() string {
 // KernelPlugin represents a single kernel-side plugin instance.
 type KernelPlugin struct {
 	*model.Petal
-	token string // JWT for this plugin
-	file  string // kernel.js file path named in js runtime (e.g. "plugin-name/kernel.js")
+	token       string                    // JWT for this plugin
+	permissions *bazaar.PluginPermissions // Effective permissions declared in plugin.json
+	file        string                    // kernel.js file path named in js runtime (e.g. "plugin-name/kernel.js")
 
 	pluginDir  string // Base directory for this plugin (e.g. /path/to/workspace/data/plugins/plugin-name)
 	storageDir string // Base directory for this plugin's storage (e.g. /path/to/workspace/data/storage/petal/plugin-name)
 
 	worker  Worker               // Worker for serializing plugin js-call-go (e.g. logger) and go-call-js (e.g. RPC calls) tasks on a single goroutine
+	limits  SandboxLimits        // Resource budgets enforced by the watchdog
 	runtime *eventloop.EventLoop // goja event loop runtime for this plugin
 	watcher *fsnotify.Watcher    // watcher for kernel plugin storage file changes
 
-	state atomic.Int64 //  PluginState
+	state     atomic.Int64                     //  PluginState
+	violation atomic.Pointer[SandboxViolation] // Last sandbox violation that moved the plugin into the error state
+	rpcSlots  chan struct{}                    // Semaphore limiting concurrent RPC requests
 
 	context context.Context    // Context for managing plugin lifecycle and cancellation
 	cancel  context.CancelFunc // Cancel function for managing plugin lifecycle and cancellation
@@ -112,12 +117,16 @@ type KernelPlugin struct {
 	rpcMethods sync.Map // string -> *RpcMethod, registered JSON-RPC methods
 	mcpTools   sync.Map // string -> *tools.Tool, fully-qualified MCP tool names registered by this plugin
 
+	schedules        sync.Map // string -> goja.Callable, schedule handlers registered via siyuan.schedule.add
+	schedulesRunning sync.Map // string -> bool, schedules whose handler is running
+
 	socketsMu sync.RWMutex       // mutex for gwsSockets map
 	sockets   map[*gws.Conn]bool // tracked gws WebSocket connections (true: RPC server, false: regular)
 }
 
 func NewKernelPlugin(ctx context.Context, petal *model.Petal) *KernelPlugin {
-	token, err := model.CreatePluginJWT(petal.Name)
+	permissions := petal.EffectivePermissions()
+	token, err := model.CreatePluginJWT(petal.Name, pluginRole(permissions), permissions.API)
 	if err != nil {
 		logging.LogErrorf("Failed to create plugin JWT for [%s]: %v", petal.Name, err)
 	}
@@ -130,14 +139,16 @@ func NewKernelPlugin(ctx context.Context, petal *model.Petal) *KernelPlugin {
 	}
 
 	plugin := &KernelPlugin{
-		Petal: petal,
-		token: token,
-		file:  fmt.Sprintf("%s/kernel.js", petal.Name),
+		Petal:       petal,
+		token:       token,
+		permissions: permissions,
+		file:        fmt.Sprintf("%s/kernel.js", petal.Name),
 
 		pluginDir:  filepath.Join(util.DataDir, "plugins", petal.Name),
 		storageDir: filepath.Join(util.DataDir, "storage", "petal", petal.Name),
 
 		watcher: watcher,
+		limits:  DefaultSandboxLimits,
 
 		context: context,
 		cancel:  cancel,
@@ -146,6 +157,9 @@ func NewKernelPlugin(ctx context.Context, petal *model.Petal) *KernelPlugin {
 
 		sockets: make(map[*gws.Conn]bool),
 	}
+	if 0 < plugin.limits.MaxConcurrentRpc {
+		plugin.rpcSlots = make(chan struct{}, plugin.limits.MaxConcurrentRpc)
+	}
 
 	plugin.updateState(PluginStateReady)
 	return plugin
@@ -165,10 +179,11 @@ func (p *KernelPlugin) State() PluginState {
 	return PluginState(p.state.Load())
 }
 
-// Clear removes all registered MCP tools and RPC methods for this plugin.
+// Clear removes all registered MCP tools, RPC methods and scheduled jobs for this plugin.
 // Called on plugin stop to prevent residue in global registries.
 func (p *KernelPlugin) Clear() {
 	p.rpcMethods.Clear()
+	p.clearSchedules()
 
 	p.mcpTools.Range(func(_, value any) bool {
 		if tool, ok := value.(*tools.Tool); ok {
@@ -199,6 +214,10 @@ func (p *KernelPlugin) InitRuntime() (err error) {
 
 		// Use JSON struct tags for field name mapping, with fallback to original names if "json" tag is absent.
 		rt.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
+		if 0 < p.limits.MaxCallStackSize {
+			rt.SetMaxCallStackSize(p.limits.MaxCallStackSize)
+		}
+		go p.watch(rt)
 
 		if enableErr := EnableExtendModules(p, rt); enableErr != nil {
 			err = fmt.Errorf("EnableExtendModules: %v", enableErr)
@@ -210,6 +229,8 @@ func (p *KernelPlugin) InitRuntime() (err error) {
 			return
 		}
 
+		untrack := p.worker.track(p.file)
+		defer untrack()
 		if _, runErr := rt.RunScript(p.file, p.Kernel.JS); runErr != nil {
 			err = fmt.Errorf("RunScript: %v", runErr)
 			return
@@ -243,6 +264,10 @@ func (p *KernelPlugin) close() (err error) {
 func (p *KernelPlugin) error() {
 	p.Clear()
 
+	if p.cancel != nil {
+		p.cancel()
+	}
+
 	if err := p.close(); err != nil {
 		logging.LogErrorf("[plugin:%s] failed to close runtime during error handling: %v", p.Name, err)
 	}
@@ -372,6 +397,10 @@ func (p *KernelPlugin) unbindRpcMethod(name string) error {
 
 // registerMcpTool registers a tool to the global MCP registry with a plugin-specific prefix, and tracks it for cleanup on plugin stop.
 func (p *KernelPlugin) registerMcpTool(name string, tool *tools.Tool) error {
+	if err := p.checkMcpPermission(); err != nil {
+		return err
+	}
+
 	p.mcpTools.Store(name, tool)
 	tools.SetTool(tool.Name, tool)
 	return nil
@@ -398,7 +427,7 @@ func (p *KernelPlugin) invokeMcpTool(handler goja.Callable, args map[string]any)
 
 	done := make(chan *TaskResult, 1)
 
-	runErr := p.worker.Run(func(rt *goja.Runtime) (_ any, _ error) {
+	runErr := p.worker.RunTask("mcp", func(rt *goja.Runtime) (_ any, _ error) {
 		jsArgs := rt.ToValue(args)
 		invokeFunction(func(_ *goja.Runtime, result *CallResult) {
 			done <- result.TaskResult()
@@ -449,7 +478,7 @@ func (p *KernelPlugin) invokeMcpTool(handler goja.Callable, args map[string]any)
 
 // runtimeEventHandler dispatches an event to the plugin's goja runtime
 func (p *KernelPlugin) runtimeEventHandler(event any) {
-	p.worker.Run(func(rt *goja.Runtime) (result any, err error) {
+	p.worker.RunTask("event", func(rt *goja.Runtime) (result any, err error) {
 		return dispatchEvent(p, rt, event)
 	}, nil)
 }
@@ -463,7 +492,7 @@ func (p *KernelPlugin) pluginEventHandler(event any) {
 func (p *KernelPlugin) subscribeEventHandlers() (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = r.(error)
+			err = fmt.Errorf("%v", r)
 		}
 	}()
 
@@ -476,7 +505,7 @@ func (p *KernelPlugin) subscribeEventHandlers() (err error) {
 func (p *KernelPlugin) unsubscribeEventHandlers() (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = r.(error)
+			err = fmt.Errorf("%v", r)
 		}
 	}()
 
@@ -665,9 +694,23 @@ func (p *KernelPlugin) callRpcMethod(method string, params any) (rpcResult any,
 		return
 	}
 
+	if p.rpcSlots != nil {
+		select {
+		case p.rpcSlots <- struct{}{}:
+			defer func() { <-p.rpcSlots }()
+		default:
+			rpcError = &JsonRpcError{
+				Code:    JsonRpcErrorCodePluginBusy,
+				Message: JsonRpcErrorPluginBusy.Message,
+				Data:    fmt.Sprintf("plugin [%s] exceeded %d concurrent RPC requests", p.Name, p.limits.MaxConcurrentRpc),
+			}
+			return
+		}
+	}
+
 	done := make(chan *TaskResult, 1)
 
-	p.worker.Run(func(rt *goja.Runtime) (result any, err error) {
+	runErr := p.worker.RunTask("rpc:"+method, func(rt *goja.Runtime) (result any, err error) {
 		rpcParams := []goja.Value{}
 		jsParams := rt.ToValue(params)
 		if isJsArray(rt, jsParams) {
@@ -687,9 +730,24 @@ func (p *KernelPlugin) callRpcMethod(method string, params any) (rpcResult any,
 			done <- result.TaskResult()
 		}, rt, true, rpcMethod.Method, rt.GlobalObject(), rpcParams...)
 		return
-	}, nil)
+	}, func(_ *goja.Runtime, _ any, err error) {
+		if err != nil {
+			done <- &TaskResult{err: err}
+		}
+	})
+	if runErr != nil {
+		done <- &TaskResult{err: runErr}
+	}
 
-	result := <-done
+	var result *TaskResult
+	select {
+	case result = <-done:
+	case <-p.context.Done():
+		result = &TaskResult{err: fmt.Errorf("plugin stopped while invoking method")}
+		if violation := p.Violation(); violation != nil {
+			result.err = violation
+		}
+	}
 	if result.err != nil {
 		rpcError = &JsonRpcError{
 			Code:    JsonRpcErrorCodeInternalError,
@@ -798,7 +856,7 @@ func (p *KernelPlugin) invokeHook(name string) {
 
 	done := make(chan TaskResult, 1)
 
-	runErr := p.worker.Run(func(rt *goja.Runtime) (_ any, err error) {
+	runErr := p.worker.RunTask("lifecycle:"+name, func(rt *goja.Runtime) (_ any, err error) {
 		lifecycle, err := getJsContextValue(rt, []any{"siyuan", "plugin", "lifecycle"})
 		if err != nil {
 			return
@@ -845,7 +903,7 @@ func (p *KernelPlugin) handleHttpRequest(c *gin.Context, request *Request, scope
 	type handleResult FunctionResult[*HttpResponse]
 	done := make(chan *handleResult, 1)
 
-	runErr := p.worker.Run(func(rt *goja.Runtime) (_ any, err error) {
+	runErr := p.worker.RunTask("http:"+request.Context.Path, func(rt *goja.Runtime) (_ any, err error) {
 		handler, handlerObj, getHandlerErr := getRequestHandler(rt, scope, RequestTypeHTTP)
 		if getHandlerErr != nil {
 			err = getHandlerErr
@@ -974,7 +1032,7 @@ func (p *KernelPlugin) handleWebSocketRequest(c *gin.Context, request *Request,
 	var bufferedAmount atomic.Int64
 	readyState.Store(int64(WebSocketReadyStateConnecting))
 
-	runErr := p.worker.Run(func(rt *goja.Runtime) (_ any, err error) {
+	runErr := p.worker.RunTask("websocket:"+request.Context.Path, func(rt *goja.Runtime) (_ any, err error) {
 		handler, handlerObj, getHandlerErr := getRequestHandler(rt, scope, RequestTypeWS)
 		if getHandlerErr != nil {
 			err = getHandlerErr
@@ -1279,7 +1337,7 @@ func (p *KernelPlugin) handleServerSentEventRequest(c *gin.Context, request *Req
 	events := chanx.NewUnboundedChan[sse.Event](ctx, 16)
 	done := make(chan error, 1) // using to receive handler error or close signal
 
-	runErr := p.worker.Run(func(rt *goja.Runtime) (_ any, err error) {
+	runErr := p.worker.RunTask("sse:"+request.Context.Path, func(rt *goja.Runtime) (_ any, err error) {
 		handler, handlerObj, getHandlerErr := getRequestHandler(rt, scope, RequestTypeSSE)
 		if getHandlerErr != nil {
 			err = getHandlerErr
@@ -21,7 +21,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"sync"
 
@@ -45,6 +44,7 @@ const (
 	// Server-defined error codes (-32099 to -32000)
 	JsonRpcErrorCodePluginNotLoaded  JsonRpcErrorCode = -32001
 	JsonRpcErrorCodePluginNotRunning JsonRpcErrorCode = -32002
+	JsonRpcErrorCodePluginBusy       JsonRpcErrorCode = -32003
 )
 
 var (
@@ -56,6 +56,7 @@ var (
 
 	JsonRpcErrorPluginNotLoaded  = &JsonRpcError{Code: JsonRpcErrorCodePluginNotLoaded, Message: "Plugin not loaded"}
 	JsonRpcErrorPluginNotRunning = &JsonRpcError{Code: JsonRpcErrorCodePluginNotRunning, Message: "Plugin not running"}
+	JsonRpcErrorPluginBusy       = &JsonRpcError{Code: JsonRpcErrorCodePluginBusy, Message: "Plugin busy"}
 )
 
 func (e *JsonRpcError) Error() string {
@@ -236,7 +237,7 @@ func HandleRpcHttp(c *gin.Context) {
 		return
 	}
 
-	body, err := io.ReadAll(c.Request.Body)
+	body, err := p.limits.readLimited(c.Request.Body)
 	if err != nil {
 		c.JSON(http.StatusOK, &JsonRpcErrorResponse{
 			JsonRpc: JsonRpcVersion,
@@ -0,0 +1,322 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/dop251/goja"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/job"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+type ScheduleCatchUp string
+
+const (
+	ScheduleCatchUpSkip ScheduleCatchUp = "skip" // Skip runs missed while the kernel or plugin was not running
+	ScheduleCatchUpOnce ScheduleCatchUp = "once" // Run once for all missed runs
+	ScheduleCatchUpAll  ScheduleCatchUp = "all"  // Run for each missed run, up to maxScheduleCatchUpRuns
+
+	maxScheduleCatchUpRuns = 100
+)
+
+// Schedule is a plugin scheduled job registered via siyuan.schedule.add, persisted across restarts.
+type Schedule struct {
+	ID       string          `json:"id"`
+	Cron     string          `json:"cron,omitempty"`     // Cron expression, e.g. "0 2 * * *"
+	Interval int64           `json:"interval,omitempty"` // Fixed interval in milliseconds
+	CatchUp  ScheduleCatchUp `json:"catchUp"`            // Missed-run catch-up policy
+	LastRun  int64           `json:"lastRun"`            // Unix milliseconds of the last dispatched scheduled time
+
+	NextRun int64 `json:"nextRun,omitempty"` // Unix milliseconds of the next run, only set in API results
+	Active  bool  `json:"active"`            // Whether a handler is bound in the current plugin run, only set in API results
+}
+
+var schedulesLock = sync.Mutex{}
+
+// schedulesPath returns the file persisting all plugin schedules (e.g. /path/to/workspace/conf/petal-schedules.json).
+// Schedules and their run state are kept in the local conf dir and not synced, so each device tracks its own last runs
+// and runs are not rewritten into the synced data on every tick.
+func schedulesPath() string {
+	return filepath.Join(util.ConfDir, "petal-schedules.json")
+}
+
+// loadSchedules reads all persisted schedules, keyed by plugin name. The caller must hold schedulesLock.
+func loadSchedules() (ret map[string][]*Schedule) {
+	ret = map[string][]*Schedule{}
+	path := schedulesPath()
+	if !filelock.IsExist(path) {
+		return
+	}
+
+	data, err := filelock.ReadFile(path)
+	if err != nil {
+		logging.LogErrorf("read plugin schedules [%s] failed: %s", path, err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal plugin schedules [%s] failed: %s", path, err)
+		return map[string][]*Schedule{}
+	}
+	return
+}
+
+// saveSchedules writes all schedules. The caller must hold schedulesLock.
+func saveSchedules(schedules map[string][]*Schedule) {
+	path := schedulesPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logging.LogErrorf("create plugin schedules dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(schedules, "", "\t")
+	if err != nil {
+		logging.LogErrorf("marshal plugin schedules failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(path, data); err != nil {
+		logging.LogErrorf("write plugin schedules [%s] failed: %s", path, err)
+	}
+}
+
+// updatePluginSchedules loads the persisted schedules of a plugin, applies update and saves the result.
+func updatePluginSchedules(name string, update func(schedules []*Schedule) []*Schedule) {
+	schedulesLock.Lock()
+	defer schedulesLock.Unlock()
+
+	all := loadSchedules()
+	schedules := update(all[name])
+	if len(schedules) == 0 {
+		delete(all, name)
+	} else {
+		all[name] = schedules
+	}
+	saveSchedules(all)
+}
+
+// getPluginSchedules returns the persisted schedules of a plugin.
+func getPluginSchedules(name string) []*Schedule {
+	schedulesLock.Lock()
+	defer schedulesLock.Unlock()
+	return loadSchedules()[name]
+}
+
+func findSchedule(schedules []*Schedule, id string) (int, *Schedule) {
+	for i, s := range schedules {
+		if s.ID == id {
+			return i, s
+		}
+	}
+	return -1, nil
+}
+
+// scheduleJobID returns the ID of the schedule in the kernel job scheduler.
+func (p *KernelPlugin) scheduleJobID(id string) string {
+	return p.scheduleJobPrefix() + id
+}
+
+func (p *KernelPlugin) scheduleJobPrefix() string {
+	return "plugin:" + p.Name + ":"
+}
+
+// addSchedule registers or replaces a schedule, binds its handler and runs missed runs according to the catch-up policy.
+func (p *KernelPlugin) addSchedule(schedule *Schedule, handler goja.Callable) (ret *Schedule, err error) {
+	switch schedule.CatchUp {
+	case "":
+		schedule.CatchUp = ScheduleCatchUpSkip
+	case ScheduleCatchUpSkip, ScheduleCatchUpOnce, ScheduleCatchUpAll:
+	default:
+		err = fmt.Errorf("invalid catchUp [%s], expected one of skip, once, all", schedule.CatchUp)
+		return
+	}
+
+	rule, err := job.NewSchedule(schedule.Cron, time.Duration(schedule.Interval)*time.Millisecond)
+	if err != nil {
+		return
+	}
+
+	// Keep the last run time if the timing rule is unchanged, so that missed runs can be caught up after restarts.
+	if _, persisted := findSchedule(getPluginSchedules(p.Name), schedule.ID); persisted != nil &&
+		persisted.Cron == schedule.Cron && persisted.Interval == schedule.Interval {
+		schedule.LastRun = persisted.LastRun
+	}
+
+	now := time.Now()
+	next := rule.Next(now)
+	var missed []time.Time
+	if 0 < schedule.LastRun {
+		lastRun := time.UnixMilli(schedule.LastRun)
+		missed = rule.Missed(lastRun, now, maxScheduleCatchUpRuns)
+		if rule.Interval > 0 {
+			// Keep the phase of fixed intervals across restarts
+			elapsed := now.Sub(lastRun)
+			next = lastRun.Add((elapsed/rule.Interval + 1) * rule.Interval)
+		}
+	}
+
+	updatePluginSchedules(p.Name, func(schedules []*Schedule) []*Schedule {
+		persisted := *schedule
+		persisted.NextRun, persisted.Active = 0, false
+		if i, _ := findSchedule(schedules, schedule.ID); i >= 0 {
+			schedules[i] = &persisted
+			return schedules
+		}
+		return append(schedules, &persisted)
+	})
+
+	p.schedules.Store(schedule.ID, handler)
+	job.AddScheduledJob(&job.ScheduledJob{
+		ID:       p.scheduleJobID(schedule.ID),
+		Schedule: rule,
+		Next:     next,
+		Func: func(scheduledTime time.Time) {
+			p.runSchedule(schedule.ID, scheduledTime)
+		},
+	})
+
+	switch schedule.CatchUp {
+	case ScheduleCatchUpOnce:
+		if len(missed) > 0 {
+			missed = missed[len(missed)-1:]
+		}
+	case ScheduleCatchUpSkip:
+		missed = nil
+	}
+	if len(missed) > 0 {
+		logging.LogInfof("[plugin:%s] schedule [%s] catching up %d missed run(s)", p.Name, schedule.ID, len(missed))
+		go func() {
+			// Schedules are usually added while kernel.js is loading, wait until the plugin is running
+			for p.State() != PluginStateRunning {
+				select {
+				case <-time.After(100 * time.Millisecond):
+				case <-p.context.Done():
+					return
+				}
+			}
+
+			for _, scheduledTime := range missed {
+				select {
+				case <-p.runSchedule(schedule.ID, scheduledTime):
+				case <-p.context.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	ret = schedule
+	ret.Active = true
+	ret.NextRun = next.UnixMilli()
+	return
+}
+
+// removeSchedule cancels a schedule and removes it from persistence.
+func (p *KernelPlugin) removeSchedule(id string) {
+	job.RemoveScheduledJob(p.scheduleJobID(id))
+	p.schedules.Delete(id)
+	updatePluginSchedules(p.Name, func(schedules []*Schedule) []*Schedule {
+		if i, _ := findSchedule(schedules, id); i >= 0 {
+			return append(schedules[:i], schedules[i+1:]...)
+		}
+		return schedules
+	})
+}
+
+// listSchedules returns the persisted schedules of the plugin, including ones not yet re-registered in the current run.
+func (p *KernelPlugin) listSchedules() (ret []*Schedule) {
+	ret = []*Schedule{}
+	for _, schedule := range getPluginSchedules(p.Name) {
+		if _, ok := p.schedules.Load(schedule.ID); ok {
+			schedule.Active = true
+			if next, ok := job.GetScheduledJobNext(p.scheduleJobID(schedule.ID)); ok {
+				schedule.NextRun = next.UnixMilli()
+			}
+		}
+		ret = append(ret, schedule)
+	}
+	return
+}
+
+// clearSchedules cancels all schedules of the plugin in the kernel job scheduler, keeping them persisted.
+func (p *KernelPlugin) clearSchedules() {
+	job.RemoveScheduledJobsByPrefix(p.scheduleJobPrefix())
+	p.schedules.Clear()
+}
+
+// runSchedule invokes the schedule handler, skipping the run if the previous one has not finished yet.
+// The returned channel is closed when the handler settles.
+func (p *KernelPlugin) runSchedule(id string, scheduledTime time.Time) <-chan struct{} {
+	done := make(chan struct{})
+	var finishOnce sync.Once
+	finish := func() {
+		finishOnce.Do(func() {
+			p.schedulesRunning.Delete(id)
+			close(done)
+		})
+	}
+
+	value, ok := p.schedules.Load(id)
+	if !ok || p.State() != PluginStateRunning {
+		close(done)
+		return done
+	}
+	handler := value.(goja.Callable)
+
+	if _, running := p.schedulesRunning.LoadOrStore(id, true); running {
+		logging.LogWarnf("[plugin:%s] schedule [%s] skipped at [%s]: previous run has not finished", p.Name, id, scheduledTime.Format(time.RFC3339))
+		close(done)
+		return done
+	}
+
+	updatePluginSchedules(p.Name, func(schedules []*Schedule) []*Schedule {
+		if _, schedule := findSchedule(schedules, id); schedule != nil && schedule.LastRun < scheduledTime.UnixMilli() {
+			schedule.LastRun = scheduledTime.UnixMilli()
+		}
+		return schedules
+	})
+
+	runErr := p.worker.RunTask("schedule:"+id, func(rt *goja.Runtime) (_ any, _ error) {
+		event := rt.ToValue(map[string]any{
+			"id":            id,
+			"scheduledTime": scheduledTime.UnixMilli(),
+		})
+		invokeFunction(func(_ *goja.Runtime, result *CallResult) {
+			if result.Error != nil {
+				logging.LogErrorf("[plugin:%s] schedule [%s] handler error: %v", p.Name, id, result.Error)
+			}
+			finish()
+		}, rt, true, handler, rt.GlobalObject(), event)
+		return
+	}, func(_ *goja.Runtime, _ any, err error) {
+		if err != nil {
+			logging.LogErrorf("[plugin:%s] schedule [%s] run error: %v", p.Name, id, err)
+			finish()
+		}
+	})
+	if runErr != nil {
+		logging.LogErrorf("[plugin:%s] schedule [%s] worker run: %v", p.Name, id, runErr)
+		finish()
+	}
+	return done
+}
@@ -0,0 +1,96 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package plugin
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/siyuan/kernel/job"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestScheduleCatchUpAndPersistence(t *testing.T) {
+	originalDataDir, originalConfDir := util.DataDir, util.ConfDir
+	util.DataDir, util.ConfDir = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { util.DataDir, util.ConfDir = originalDataDir, originalConfDir })
+
+	p, _ := newTestSandboxPlugin(t, SandboxLimits{MaxConcurrentRpc: 1})
+	lastRun := time.Now().Add(-3*time.Hour - time.Minute).UnixMilli()
+	updatePluginSchedules(p.Name, func([]*Schedule) []*Schedule {
+		return []*Schedule{{ID: "poll", Interval: time.Hour.Milliseconds(), CatchUp: ScheduleCatchUpAll, LastRun: lastRun}}
+	})
+
+	runs := make(chan int64, 10)
+	handler, err := p.worker.RunSync(func(rt *goja.Runtime) (any, error) {
+		fn, _ := goja.AssertFunction(rt.ToValue(func(call goja.FunctionCall) goja.Value {
+			runs <- call.Argument(0).ToObject(rt).Get("scheduledTime").ToInteger()
+			return goja.Undefined()
+		}))
+		return fn, nil
+	})
+	if err != nil {
+		t.Fatalf("create handler failed: %v", err)
+	}
+
+	schedule, err := p.addSchedule(&Schedule{ID: "poll", Interval: time.Hour.Milliseconds(), CatchUp: ScheduleCatchUpAll}, handler.(goja.Callable))
+	if err != nil {
+		t.Fatalf("add schedule failed: %v", err)
+	}
+	if schedule.LastRun != lastRun || schedule.NextRun != lastRun+4*time.Hour.Milliseconds() {
+		t.Fatalf("unexpected schedule %+v", schedule)
+	}
+
+	var previous int64
+	for i := 0; i < 3; i++ {
+		select {
+		case scheduledTime := <-runs:
+			if scheduledTime <= previous {
+				t.Fatalf("expected missed runs in order, got %d after %d", scheduledTime, previous)
+			}
+			previous = scheduledTime
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected 3 catch-up runs, got %d", i)
+		}
+	}
+
+	schedules := p.listSchedules()
+	if len(schedules) != 1 || !schedules[0].Active || schedules[0].LastRun != previous {
+		t.Fatalf("unexpected persisted schedules %+v", schedules)
+	}
+	// Run state stays on the local device instead of the synced data dir
+	if !filelock.IsExist(schedulesPath()) || filelock.IsExist(filepath.Join(util.DataDir, "storage", "petal", "schedules.json")) {
+		t.Fatal("expected schedules persisted in the conf dir only")
+	}
+
+	// Stopping the plugin cancels the job but keeps the schedule persisted
+	p.Clear()
+	if _, ok := job.GetScheduledJobNext(p.scheduleJobID("poll")); ok {
+		t.Fatal("expected scheduled job to be cancelled")
+	}
+	if schedules = p.listSchedules(); len(schedules) != 1 || schedules[0].Active {
+		t.Fatalf("expected inactive persisted schedule, got %+v", schedules)
+	}
+
+	p.removeSchedule("poll")
+	if schedules = p.listSchedules(); len(schedules) != 0 {
+		t.Fatalf("expected schedule to be removed, got %+v", schedules)
+	}
+}
@@ -437,6 +437,10 @@ func HandleHttpRequest(c *gin.Context, scope AccessScope) {
 		c.String(http.StatusServiceUnavailable, "[plugin:%s] is not running", name)
 		return
 	}
+	if permissionErr := p.checkNetworkPermission(); permissionErr != nil {
+		c.String(http.StatusForbidden, "[plugin:%s] %s", name, permissionErr)
+		return
+	}
 
 	request, parseErr := parseRequest(c)
 	if parseErr != nil {
@@ -19,6 +19,8 @@ package plugin
 import (
 	"fmt"
 	"runtime/debug"
+	"sync/atomic"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/dop251/goja_nodejs/eventloop"
@@ -29,7 +31,15 @@ type TaskExecutor func(rt *goja.Runtime) (result any, err error)
 type TaskCallback func(rt *goja.Runtime, result any, err error)
 
 type Worker struct {
-	loop *eventloop.EventLoop
+	loop    *eventloop.EventLoop
+	current atomic.Pointer[WorkerTask] // task currently executing on the event loop, nil when idle
+}
+
+// WorkerTask describes a task executing on the event loop, used by the sandbox watchdog.
+type WorkerTask struct {
+	Name      string    // handler name, e.g. "rpc:echo"
+	StartTime time.Time // when the task started executing
+	StartHeap uint64    // heap size sampled when the task started executing
 }
 
 type TaskResult struct {
@@ -42,6 +52,11 @@ func (w *Worker) Start(loop *eventloop.EventLoop) {
 }
 
 func (w *Worker) Run(executor TaskExecutor, callback TaskCallback) error {
+	return w.RunTask("", executor, callback)
+}
+
+// RunTask is like Run but names the task, so that the sandbox watchdog can report the offending handler.
+func (w *Worker) RunTask(name string, executor TaskExecutor, callback TaskCallback) error {
 	if w.loop == nil {
 		return fmt.Errorf("worker event loop not initialized")
 	}
@@ -50,6 +65,7 @@ func (w *Worker) Run(executor TaskExecutor, callback TaskCallback) error {
 		var result any
 		var err error
 
+		defer w.track(name)()
 		defer func() {
 			defer func() {
 				// 捕获回调中的 panic 并保留原始调用栈，便于定位 Promise 处理异常。
@@ -92,3 +108,20 @@ func (w *Worker) RunSync(fn TaskExecutor) (result any, err error) {
 	err = r.err
 	return
 }
+
+// track marks the named task as executing on the event loop and returns a function to unmark it.
+func (w *Worker) track(name string) (untrack func()) {
+	if name == "" {
+		name = "anonymous task"
+	}
+	task := &WorkerTask{Name: name, StartTime: time.Now(), StartHeap: heapBytes()}
+	previous := w.current.Swap(task)
+	return func() {
+		w.current.Store(previous)
+	}
+}
+
+// Current returns the task currently executing on the event loop, or nil when idle.
+func (w *Worker) Current() *WorkerTask {
+	return w.current.Load()
+}
@@ -32,11 +32,13 @@ type AssetContent struct {
 	Size    int64
 	Updated int64
 	Content string
+	Locator string // 分块定位信息（JSON），整个文件作为一条记录时为空
+	Meta    string // 元数据，比如邮件头
 }
 
 const (
-	AssetContentsFTSCaseInsensitiveInsert = "INSERT INTO asset_contents_fts_case_insensitive (id, name, ext, path, size, updated, content) VALUES %s"
-	AssetContentsPlaceholder              = "(?, ?, ?, ?, ?, ?, ?)"
+	AssetContentsFTSCaseInsensitiveInsert = "INSERT INTO asset_contents_fts_case_insensitive (id, name, ext, path, size, updated, content, locator, meta) VALUES %s"
+	AssetContentsPlaceholder              = "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
 )
 
 func insertAssetContents(tx *sql.Tx, assetContents []*AssetContent, context map[string]any) (err error) {
@@ -76,6 +78,8 @@ func insertAssetContents0(tx *sql.Tx, bulk []*AssetContent, context map[string]a
 		valueArgs = append(valueArgs, b.Size)
 		valueArgs = append(valueArgs, b.Updated)
 		valueArgs = append(valueArgs, b.Content)
+		valueArgs = append(valueArgs, b.Locator)
+		valueArgs = append(valueArgs, b.Meta)
 	}
 
 	stmt := fmt.Sprintf(AssetContentsFTSCaseInsensitiveInsert, strings.Join(valueStrings, ","))
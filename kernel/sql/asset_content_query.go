@@ -194,7 +194,16 @@ func selectAssetContentsRawStmt(stmt string, args []any, limit int) (ret []*Asse
 
 func scanAssetContentRows(rows *sql.Rows) (ret *AssetContent) {
 	var ac AssetContent
-	if err := rows.Scan(&ac.ID, &ac.Name, &ac.Ext, &ac.Path, &ac.Size, &ac.Updated, &ac.Content); err != nil {
+	dest := []any{&ac.ID, &ac.Name, &ac.Ext, &ac.Path, &ac.Size, &ac.Updated, &ac.Content}
+	columns, _ := rows.Columns()
+	if len(dest) < len(columns) {
+		// SELECT * 会带出分块定位列和元数据列
+		dest = append(dest, &ac.Locator)
+	}
+	if len(dest) < len(columns) {
+		dest = append(dest, &ac.Meta)
+	}
+	if err := rows.Scan(dest...); err != nil {
 		logging.LogErrorf("query scan field failed: %s\n%s", err, logging.ShortStack())
 		return
 	}
@@ -27,10 +27,10 @@ func TestAssetContentQueryArguments(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer testDB.Close()
-	if _, err = testDB.Exec("CREATE TABLE asset_contents_fts_case_insensitive (id TEXT, name TEXT, ext TEXT, path TEXT, size INTEGER, updated INTEGER, content TEXT)"); err != nil {
+	if _, err = testDB.Exec("CREATE TABLE asset_contents_fts_case_insensitive (id TEXT, name TEXT, ext TEXT, path TEXT, size INTEGER, updated INTEGER, content TEXT, locator TEXT, meta TEXT)"); err != nil {
 		t.Fatal(err)
 	}
-	if _, err = testDB.Exec("INSERT INTO asset_contents_fts_case_insensitive VALUES (?, ?, ?, ?, ?, ?, ?)", "id", "name", ".txt", "assets/test.txt", 4, 1, "needle"); err != nil {
+	if _, err = testDB.Exec("INSERT INTO asset_contents_fts_case_insensitive VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", "id", "name", ".txt", "assets/test.txt", 4, 1, "needle", `{"type":"page","page":2}`, "From: alice"); err != nil {
 		t.Fatal(err)
 	}
 
@@ -46,6 +46,11 @@ func TestAssetContentQueryArguments(t *testing.T) {
 		t.Fatalf("FTS 参数查询结果错误：%v", results)
 	}
 
+	results = SelectAssetContentsRawStmtNoParseArgs("SELECT * FROM asset_contents_fts_case_insensitive WHERE content = ?", []any{"needle"}, 10)
+	if 1 != len(results) || `{"type":"page","page":2}` != results[0].Locator || "From: alice" != results[0].Meta {
+		t.Fatalf("SELECT * 应包含分块定位列和元数据列：%v", results)
+	}
+
 	payload := "needle'; DELETE FROM asset_contents_fts_case_insensitive; --"
 	SelectAssetContentsRawStmtNoParseArgs(stmt, []any{payload}, 10)
 	SelectAssetContentsRawStmtNoParse("DELETE FROM asset_contents_fts_case_insensitive", 10)
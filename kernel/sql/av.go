@@ -65,6 +65,25 @@ func RenderGroupView(attrView *av.AttributeView, view, groupView *av.View, query
 		groupView.Kanban.FitImage = view.Kanban.FitImage
 		groupView.Kanban.DisplayFieldName = view.Kanban.DisplayFieldName
 		groupView.Kanban.FillColBackgroundColor = view.Kanban.FillColBackgroundColor
+	case av.LayoutTypeCalendar:
+		err = copier.CopyWithOption(&groupView.Calendar.Fields, &view.Calendar.Fields, copier.Option{DeepCopy: true})
+		groupView.Calendar.ShowIcon = view.Calendar.ShowIcon
+		groupView.Calendar.WrapField = view.Calendar.WrapField
+
+		groupView.Calendar.StartKeyID = view.Calendar.StartKeyID
+		groupView.Calendar.EndKeyID = view.Calendar.EndKeyID
+		groupView.Calendar.Mode = view.Calendar.Mode
+		groupView.Calendar.FirstDayOfWeek = view.Calendar.FirstDayOfWeek
+		groupView.Calendar.DisplayFieldName = view.Calendar.DisplayFieldName
+	case av.LayoutTypeTimeline:
+		err = copier.CopyWithOption(&groupView.Timeline.Fields, &view.Timeline.Fields, copier.Option{DeepCopy: true})
+		groupView.Timeline.ShowIcon = view.Timeline.ShowIcon
+		groupView.Timeline.WrapField = view.Timeline.WrapField
+
+		groupView.Timeline.StartKeyID = view.Timeline.StartKeyID
+		groupView.Timeline.EndKeyID = view.Timeline.EndKeyID
+		groupView.Timeline.Scale = view.Timeline.Scale
+		groupView.Timeline.DisplayFieldName = view.Timeline.DisplayFieldName
 	}
 	if nil != err {
 		logging.LogErrorf("copy view fields [%s] to group [%s] failed: %s", view.ID, groupView.ID, err)
@@ -75,6 +94,10 @@ func RenderGroupView(attrView *av.AttributeView, view, groupView *av.View, query
 			groupView.Gallery.CardFields = view.Gallery.CardFields
 		case av.LayoutTypeKanban:
 			groupView.Kanban.Fields = view.Kanban.Fields
+		case av.LayoutTypeCalendar:
+			groupView.Calendar.Fields = view.Calendar.Fields
+		case av.LayoutTypeTimeline:
+			groupView.Timeline.Fields = view.Timeline.Fields
 		}
 	}
 
@@ -110,6 +133,10 @@ func renderView(attrView *av.AttributeView, view *av.View, query string, depth *
 		ret = RenderAttributeViewGallery(attrView, view, query, depth, cachedAttrViews, ignoreRows)
 	case av.LayoutTypeKanban:
 		ret = RenderAttributeViewKanban(attrView, view, query, depth, cachedAttrViews, ignoreRows)
+	case av.LayoutTypeCalendar:
+		ret = RenderAttributeViewCalendar(attrView, view, query, depth, cachedAttrViews, ignoreRows)
+	case av.LayoutTypeTimeline:
+		ret = RenderAttributeViewTimeline(attrView, view, query, depth, cachedAttrViews, ignoreRows)
 	}
 	return
 }
@@ -354,6 +381,8 @@ func fillAttributeViewBaseValue(baseValue *av.BaseValue, fieldID, itemID string,
 		baseValue.Value = &av.Value{ID: baseValue.ID, KeyID: fieldID, BlockID: itemID, Type: av.KeyTypeCreated}
 	case av.KeyTypeUpdated: // 填充更新时间字段值，后面再渲染
 		baseValue.Value = &av.Value{ID: baseValue.ID, KeyID: fieldID, BlockID: itemID, Type: av.KeyTypeUpdated}
+	case av.KeyTypeFormula: // 填充公式字段值，后面再计算
+		baseValue.Value = &av.Value{ID: baseValue.ID, KeyID: fieldID, BlockID: itemID, Type: av.KeyTypeFormula, Formula: &av.ValueFormula{}}
 	}
 
 	if nil == baseValue.Value {
@@ -514,7 +543,7 @@ func fillAttributeViewAutoGeneratedValues(attrView *av.AttributeView, collection
 
 		isSameAv := destAv.ID == attrView.ID
 		var furtherCollection av.Collection
-		if av.KeyTypeTemplate == destKey.Type || (!isSameAv && (av.KeyTypeUpdated == destKey.Type || av.KeyTypeCreated == destKey.Type || av.KeyTypeRelation == destKey.Type)) {
+		if av.KeyTypeTemplate == destKey.Type || av.KeyTypeFormula == destKey.Type || (!isSameAv && (av.KeyTypeUpdated == destKey.Type || av.KeyTypeCreated == destKey.Type || av.KeyTypeRelation == destKey.Type)) {
 			viewable := renderView(destAv, destAv.Views[0], "", depth, cachedAttrViews, false)
 			if nil != viewable {
 				furtherCollection = viewable.(av.Collection)
@@ -601,7 +630,7 @@ func GetFurtherCollections(attrView *av.AttributeView, cachedAttrViews map[strin
 		isSameAv := destAv.ID == attrView.ID
 
 		var furtherCollection av.Collection
-		if av.KeyTypeTemplate == destKey.Type || (!isSameAv && (av.KeyTypeUpdated == destKey.Type || av.KeyTypeCreated == destKey.Type || av.KeyTypeRelation == destKey.Type)) {
+		if av.KeyTypeTemplate == destKey.Type || av.KeyTypeFormula == destKey.Type || (!isSameAv && (av.KeyTypeUpdated == destKey.Type || av.KeyTypeCreated == destKey.Type || av.KeyTypeRelation == destKey.Type)) {
 			viewable := RenderView(destAv, destAv.Views[0], "", false)
 			if nil != viewable {
 				furtherCollection = viewable.(av.Collection)
@@ -680,6 +709,64 @@ func fillAttributeViewTemplateValues(attrView *av.AttributeView, view *av.View,
 	return
 }
 
+func fillAttributeViewFormulaValues(attrView *av.AttributeView, collection av.Collection) {
+	formulaKeys, programs, cyclicKeys := av.GetFormulaKeysByResolutionOrder(attrView)
+	if 1 > len(formulaKeys) {
+		return
+	}
+
+	keyIDs := map[string]string{}
+	for _, keyValues := range attrView.KeyValues {
+		if _, ok := keyIDs[keyValues.Key.Name]; !ok { // 同名字段只取第一个
+			keyIDs[keyValues.Key.Name] = keyValues.Key.ID
+		}
+	}
+
+	for _, formulaKey := range formulaKeys {
+		resultType := av.KeyTypeText
+		expr := ""
+		if nil != formulaKey.Formula {
+			resultType = formulaKey.Formula.ResultType
+			expr = formulaKey.Formula.Expr
+		}
+
+		program := programs[formulaKey.ID]
+		var formulaErr error
+		if cyclicKeys[formulaKey.ID] {
+			formulaErr = av.ErrFormulaCircularRef
+		} else if nil == program {
+			_, formulaErr = av.ParseFormula(expr)
+		}
+
+		for _, item := range collection.GetItems() {
+			value := item.GetValue(formulaKey.ID)
+			if nil == value {
+				continue
+			}
+
+			if nil != formulaErr {
+				if "" == strings.TrimSpace(expr) {
+					av.SetFormulaError(value, nil, resultType) // 还没有填写公式时不提示错误
+				} else {
+					av.SetFormulaError(value, formulaErr, resultType)
+				}
+				continue
+			}
+
+			program.Eval(value, resultType, formulaKey.NumberFormat, func(keyName string) (ret *av.Value, ok bool) {
+				keyID, ok := keyIDs[keyName]
+				if !ok {
+					return
+				}
+				if ret = item.GetValue(keyID); nil == ret {
+					ret = attrView.GetValue(keyID, item.GetID())
+				}
+				return
+			})
+		}
+	}
+}
+
 func fillAttributeViewKeyValues(attrView *av.AttributeView, collection av.Collection) {
 	fieldValues := map[string][]*av.Value{}
 	for _, item := range collection.GetItems() {
@@ -766,6 +853,10 @@ func FillAttributeViewNilValue(value *av.Value, typ av.KeyType) {
 		if nil == value.Rollup {
 			value.Rollup = &av.ValueRollup{}
 		}
+	case av.KeyTypeFormula:
+		if nil == value.Formula {
+			value.Formula = &av.ValueFormula{}
+		}
 	}
 }
 
@@ -821,6 +912,26 @@ func removeMissingField(attrView *av.AttributeView, view *av.View, missingKeyID
 		}
 	}
 
+	if nil != view.Calendar {
+		for i, calendarField := range view.Calendar.Fields {
+			if calendarField.ID == missingKeyID {
+				view.Calendar.Fields = append(view.Calendar.Fields[:i], view.Calendar.Fields[i+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+
+	if nil != view.Timeline {
+		for i, timelineField := range view.Timeline.Fields {
+			if timelineField.ID == missingKeyID {
+				view.Timeline.Fields = append(view.Timeline.Fields[:i], view.Timeline.Fields[i+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+
 	if changed {
 		av.SaveAttributeView(attrView)
 	}
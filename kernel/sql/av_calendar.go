@@ -0,0 +1,162 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func RenderAttributeViewCalendar(attrView *av.AttributeView, view *av.View, query string, depth *int, cachedAttrViews map[string]*av.AttributeView, ignoreRows bool) (ret *av.Calendar) {
+	viewable := attrView.RenderedViewables[view.ID]
+	if nil != viewable {
+		ret = viewable.(*av.Calendar)
+		return
+	}
+
+	ret = &av.Calendar{
+		BaseInstance:     av.NewViewBaseInstance(view),
+		StartKeyID:       view.Calendar.StartKeyID,
+		EndKeyID:         view.Calendar.EndKeyID,
+		Mode:             view.Calendar.Mode,
+		FirstDayOfWeek:   view.Calendar.FirstDayOfWeek,
+		DisplayFieldName: view.Calendar.DisplayFieldName,
+		Fields:           []*av.CalendarField{},
+		Events:           []*av.CalendarEvent{},
+	}
+
+	// 组装字段
+	for _, field := range view.Calendar.Fields {
+		key, getErr := attrView.GetKey(field.ID)
+		if nil != getErr {
+			// 找不到字段则在视图中删除（元数据查询场景不写盘）
+			if !ignoreRows {
+				removeMissingField(attrView, view, field.ID)
+			}
+			continue
+		}
+
+		ret.Fields = append(ret.Fields, &av.CalendarField{
+			BaseInstanceField: &av.BaseInstanceField{
+				ID:           key.ID,
+				Name:         key.Name,
+				Type:         key.Type,
+				Icon:         key.Icon,
+				Wrap:         field.Wrap,
+				Hidden:       field.Hidden,
+				Desc:         key.Desc,
+				Calc:         field.Calc,
+				Options:      key.Options,
+				NumberFormat: key.NumberFormat,
+				Template:     key.Template,
+				Relation:     key.Relation,
+				Rollup:       key.Rollup,
+				Date:         key.Date,
+				Created:      key.Created,
+				Updated:      key.Updated,
+				Formula:      key.Formula,
+			},
+		})
+	}
+
+	// 菜单等只需要字段/视图元数据的场景，跳过全部事件处理
+	if ignoreRows {
+		return
+	}
+
+	eventsValues := generateAttrViewItems(attrView, view) // 生成事件
+	filterNotFoundAttrViewItems(eventsValues)             // 过滤掉不存在的事件
+
+	// 批量加载绑定块对应的树
+	var ialIDs []string
+	for _, keyValues := range eventsValues {
+		for _, kValues := range keyValues {
+			blockVal := kValues.GetBlockValue()
+			if nil != blockVal && !blockVal.IsDetached {
+				ialIDs = append(ialIDs, blockVal.Block.ID)
+			}
+		}
+	}
+	boundTrees := filesys.LoadTrees(ialIDs)
+
+	// 生成事件字段值
+	for eventID, eventValues := range eventsValues {
+		// 按字段 ID 建索引，避免后续字段循环里对每个字段做线性查找
+		kvByField := map[string]*av.KeyValues{}
+		for _, keyValues := range eventValues {
+			if _, ok := kvByField[keyValues.Key.ID]; !ok { // 同一字段存在多个值时只取第一个
+				kvByField[keyValues.Key.ID] = keyValues
+			}
+		}
+
+		calendarEvent := av.CalendarEvent{ID: eventID}
+		for _, field := range ret.Fields {
+			var fieldValue *av.CalendarFieldValue
+			if keyValues, ok := kvByField[field.ID]; ok {
+				fieldValue = &av.CalendarFieldValue{
+					BaseValue: &av.BaseValue{
+						ID:        keyValues.Values[0].ID,
+						Value:     keyValues.Values[0],
+						ValueType: field.Type,
+					},
+				}
+			}
+			if nil == fieldValue {
+				fieldValue = &av.CalendarFieldValue{
+					BaseValue: &av.BaseValue{
+						ID:        eventID[:14] + ast.NewNodeID()[14:],
+						ValueType: field.Type,
+					},
+				}
+			}
+
+			filedDateIsTime := false
+			if nil != field.Date {
+				filedDateIsTime = field.Date.FillSpecificTime
+			}
+			fillAttributeViewBaseValue(fieldValue.BaseValue, field.ID, eventID, field.NumberFormat, field.Template, filedDateIsTime)
+			calendarEvent.Values = append(calendarEvent.Values, fieldValue)
+		}
+
+		// 日期字段不一定显示在事件上，所以直接从属性视图中取值
+		calendarEvent.DateSpan = av.NewDateSpan(getAttrViewDateValue(attrView, view.Calendar.StartKeyID, eventID), getAttrViewDateValue(attrView, view.Calendar.EndKeyID, eventID))
+		ret.Events = append(ret.Events, &calendarEvent)
+	}
+
+	// 回填补全数据
+	fillAttributeViewKeyValues(attrView, ret)
+
+	// 批量获取块属性以提升性能
+	ials := BatchGetBlockAttrsWitTrees(ialIDs, boundTrees)
+
+	// 渲染自动生成的字段值，比如关联、汇总、创建时间和更新时间
+	fillAttributeViewAutoGeneratedValues(attrView, ret, ials, depth, cachedAttrViews)
+
+	// 最后渲染模板字段，这样模板就可以使用汇总、关联、创建时间和更新时间的值了
+	renderTemplateErr := fillAttributeViewTemplateValues(attrView, view, ret, ials)
+	if nil != renderTemplateErr {
+		util.PushErrMsg(fmt.Sprintf(util.Langs[util.Lang][44], util.EscapeHTML(renderTemplateErr.Error())), 30000)
+	}
+
+	// 公式字段在模板字段之后计算，这样公式就可以引用模板字段的值了
+	fillAttributeViewFormulaValues(attrView, ret)
+
+	filterByQuery(query, ret)
+	manualSort(view, ret)
+	return
+}
+
+// getAttrViewDateValue 获取日历和时间线布局中用于排期的日期字段值，字段不存在或不是日期字段时返回 nil。
+func getAttrViewDateValue(attrView *av.AttributeView, keyID, itemID string) *av.Value {
+	if "" == keyID {
+		return nil
+	}
+
+	key, _ := attrView.GetKey(keyID)
+	if nil == key || av.KeyTypeDate != key.Type {
+		return nil
+	}
+	return attrView.GetValue(keyID, itemID)
+}
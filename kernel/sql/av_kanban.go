@@ -61,6 +61,7 @@ func RenderAttributeViewKanban(attrView *av.AttributeView, view *av.View, query
 				Date:         key.Date,
 				Created:      key.Created,
 				Updated:      key.Updated,
+				Formula:      key.Formula,
 			},
 		})
 	}
@@ -144,6 +145,9 @@ func RenderAttributeViewKanban(attrView *av.AttributeView, view *av.View, query
 		util.PushErrMsg(fmt.Sprintf(util.Langs[util.Lang][44], util.EscapeHTML(renderTemplateErr.Error())), 30000)
 	}
 
+	// 公式字段在模板字段之后计算，这样公式就可以引用模板字段的值了
+	fillAttributeViewFormulaValues(attrView, ret)
+
 	filterByQuery(query, ret)
 	manualSort(view, ret)
 	return
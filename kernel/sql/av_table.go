@@ -64,6 +64,7 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 				Date:         key.Date,
 				Created:      key.Created,
 				Updated:      key.Updated,
+				Formula:      key.Formula,
 			},
 			Width: col.Width,
 			Pin:   col.Pin,
@@ -143,6 +144,9 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 		util.PushErrMsg(fmt.Sprintf(util.Langs[util.Lang][44], util.EscapeHTML(renderTemplateErr.Error())), 30000)
 	}
 
+	// 公式字段在模板字段之后计算，这样公式就可以引用模板字段的值了
+	fillAttributeViewFormulaValues(attrView, ret)
+
 	filterByQuery(query, ret)
 	manualSort(view, ret)
 	return
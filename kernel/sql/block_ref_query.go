@@ -177,6 +177,26 @@ func QueryRootBlockRefCount() (ret map[string]int) {
 	return
 }
 
+// QueryAllRootRefs 查询文档之间的引用关系，返回引用文档 ID 到被引用文档 ID 列表的映射，不包含文档内部的引用。
+func QueryAllRootRefs() (ret map[string][]string) {
+	ret = map[string][]string{}
+	rows, err := query("SELECT DISTINCT root_id, def_block_root_id FROM refs WHERE root_id != def_block_root_id")
+	if err != nil {
+		logging.LogErrorf("sql query failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rootID, defRootID string
+		if err = rows.Scan(&rootID, &defRootID); err != nil {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret[rootID] = append(ret[rootID], defRootID)
+	}
+	return
+}
+
 func QueryDefRootBlocksByRefRootID(refRootID string) (ret []*Block) {
 	rows, err := query("SELECT * FROM blocks WHERE id IN (SELECT DISTINCT def_block_root_id FROM refs WHERE root_id = ?)", refRootID)
 	if err != nil {
@@ -107,6 +107,7 @@ func initDatabase(forceRebuild bool) {
 		if util.DatabaseVer == getDatabaseVer() {
 			// 老库版本一致但缺少新加的列时，做幂等迁移（不升 DatabaseVer，避免全库重建丢失已嵌入向量）
 			migrateBlockEmbeddingsSchema()
+			migrateAssetEmbeddingsSchema()
 			recoverIndexQueue()
 			return
 		}
@@ -254,6 +255,38 @@ func initDBTables() {
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "create index [idx_block_embeddings_root_id] failed: %s", err)
 	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS asset_embeddings")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "drop table [asset_embeddings] failed: %s", err)
+	}
+	if err = createAssetEmbeddingsTable(); err != nil {
+		logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "create table [asset_embeddings] failed: %s", err)
+	}
+}
+
+// createAssetEmbeddingsTable 创建资源文件内容嵌入向量表。
+//
+// 资源文件内容索引库位于临时目录且随时可能重建，向量存放在主库中以免重建资源文件内容索引时丢失。
+// 每个资源文件内容分块再切分为若干段分别嵌入：locator 为资源文件内容分块的定位信息，chunk 为段序号；
+// updated 为资源文件的修改时间，与资源文件内容索引比对以发现需要重新嵌入的文件。
+func createAssetEmbeddingsTable() (err error) {
+	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS asset_embeddings (id TEXT PRIMARY KEY, path TEXT, ext TEXT, locator TEXT, chunk INTEGER, content TEXT, embedding BLOB, model TEXT, updated INTEGER, ignored_type INTEGER NOT NULL DEFAULT 0)"); err != nil {
+		return
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_asset_embeddings_path ON asset_embeddings(path)")
+	return
+}
+
+// migrateAssetEmbeddingsSchema 为老库幂等补建资源文件内容嵌入向量表，不升 DatabaseVer（避免全库重建丢失已嵌入向量）。
+func migrateAssetEmbeddingsSchema() {
+	if nil == db {
+		return
+	}
+
+	if err := createAssetEmbeddingsTable(); err != nil {
+		logging.LogErrorf("create table [asset_embeddings] failed: %s", err)
+	}
 }
 
 func initFTSBlocks() (err error) {
@@ -388,6 +421,14 @@ func InitAssetContentDatabase(forceRebuild bool) {
 	initAssetContentDBConnection()
 
 	if !forceRebuild && gulu.File.IsExist(util.AssetContentDBPath) {
+		if assetContentDBTablesUpToDate() {
+			return
+		}
+
+		// 旧版资源文件内容库缺少分块定位列或元数据列，重建表后重新索引
+		logging.LogInfof("the asset content database structure is changed, rebuilding asset content database...")
+		initAssetContentDBTables()
+		eventbus.Publish(util.EvtSQLAssetContentRebuild)
 		return
 	}
 
@@ -403,6 +444,30 @@ func InitAssetContentDatabase(forceRebuild bool) {
 	initAssetContentDBTables()
 }
 
+// assetContentDBTablesUpToDate 检查资源文件内容表是否包含当前版本的全部列。
+func assetContentDBTablesUpToDate() bool {
+	rows, err := assetContentDB.Query("PRAGMA table_info(asset_contents_fts_case_insensitive)")
+	if err != nil {
+		logging.LogErrorf("check asset content table columns failed: %s", err)
+		return false
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var cid int
+		var name, ctype string
+		var notnull, pk int
+		var dfltValue any
+		if err = rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
+			logging.LogErrorf("scan asset content table column info failed: %s", err)
+			return false
+		}
+		columns[name] = true
+	}
+	return columns["locator"] && columns["meta"]
+}
+
 func initAssetContentDBConnection() {
 	if nil != assetContentDB {
 		assetContentDB.Close()
@@ -433,7 +498,7 @@ func initAssetContentDBConnection() {
 
 func initAssetContentDBTables() {
 	assetContentDB.Exec("DROP TABLE asset_contents_fts_case_insensitive")
-	_, err := assetContentDB.Exec("CREATE VIRTUAL TABLE asset_contents_fts_case_insensitive USING fts5(id UNINDEXED, name, ext, path, size UNINDEXED, updated UNINDEXED, content, tokenize=\"siyuan case_insensitive\")")
+	_, err := assetContentDB.Exec("CREATE VIRTUAL TABLE asset_contents_fts_case_insensitive USING fts5(id UNINDEXED, name, ext, path, size UNINDEXED, updated UNINDEXED, content, locator UNINDEXED, meta, tokenize=\"siyuan case_insensitive\")")
 	if err != nil {
 		if isRecoverableDBFileError(err) {
 			logging.LogWarnf("create asset content fts table failed: %s, retrying with clean database...", err)
@@ -445,7 +510,7 @@ func initAssetContentDBTables() {
 			time.Sleep(time.Second)
 			initAssetContentDBConnection()
 			assetContentDB.Exec("DROP TABLE asset_contents_fts_case_insensitive")
-			_, err = assetContentDB.Exec("CREATE VIRTUAL TABLE asset_contents_fts_case_insensitive USING fts5(id UNINDEXED, name, ext, path, size UNINDEXED, updated UNINDEXED, content, tokenize=\"siyuan case_insensitive\")")
+			_, err = assetContentDB.Exec("CREATE VIRTUAL TABLE asset_contents_fts_case_insensitive USING fts5(id UNINDEXED, name, ext, path, size UNINDEXED, updated UNINDEXED, content, locator UNINDEXED, meta, tokenize=\"siyuan case_insensitive\")")
 		}
 		if err != nil {
 			logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "create table [asset_contents_fts_case_insensitive] failed: %s", err)
@@ -93,6 +93,11 @@ func SelectSpansRawStmt(stmt string, limit int) (ret []*Span) {
 }
 
 func QueryTagSpansByLabel(label string) (ret []*Span) {
+	return QueryTagSpansByLabelInBox(label, "")
+}
+
+// QueryTagSpansByLabelInBox 与 QueryTagSpansByLabel 一致，但按 boxID 路由到加密笔记本的 db，boxID 为空时查询全局 db。
+func QueryTagSpansByLabelInBox(label, boxID string) (ret []*Span) {
 	var stmt string
 	var args []any
 	if "" != label {
@@ -101,7 +106,7 @@ func QueryTagSpansByLabel(label string) (ret []*Span) {
 	} else {
 		stmt = "SELECT * FROM spans WHERE type LIKE '%tag%' AND content = '' GROUP BY block_id"
 	}
-	rows, err := query(stmt, args...)
+	rows, err := queryForBox(boxID, stmt, args...)
 	if err != nil {
 		logging.LogErrorf("sql query failed: %s", err)
 		return
@@ -0,0 +1,381 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	anthropicVersion = "2023-06-01"
+
+	// Anthropic Messages API 要求必须传 max_tokens，请求未指定时使用该值
+	anthropicDefaultMaxTokens = 8192
+)
+
+// anthropicTransport 把 go-openai 的请求转换为 Anthropic Messages API 请求。
+// 支持 chat/completions（含流式和工具调用）和 models，Anthropic 没有 Embeddings API。
+type anthropicTransport struct {
+	base   openai.HTTPDoer
+	apiKey string
+}
+
+func (t *anthropicTransport) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Del("Authorization")
+	if "" != t.apiKey {
+		req.Header.Set("x-api-key", t.apiKey)
+	}
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	endpoint := aiEndpoint(req)
+	switch {
+	case "/chat/completions" == endpoint && http.MethodPost == req.Method:
+		return t.chat(req)
+	case "/models" == endpoint && http.MethodGet == req.Method:
+		// Anthropic 模型列表响应同样是 {"data":[{"id":...}]} 结构，无需转换
+		aiRewriteURL(req, endpoint, "/models", map[string]string{"limit": "1000"})
+		return t.base.Do(req)
+	}
+	return nil, fmt.Errorf("endpoint [%s] is not supported by the anthropic protocol", req.URL.Path)
+}
+
+func (t *anthropicTransport) chat(req *http.Request) (*http.Response, error) {
+	chatReq := &openai.ChatCompletionRequest{}
+	if err := aiReadRequest(req, chatReq); err != nil {
+		return nil, err
+	}
+	aiRewriteURL(req, "/chat/completions", "/messages", nil)
+	if err := aiSetRequestBody(req, newAnthropicRequest(chatReq)); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.Do(req)
+	if err != nil || !aiIsSuccess(resp) {
+		return resp, err
+	}
+	if chatReq.Stream {
+		return aiStreamResponse(resp, &anthropicStream{id: aiChatID(), model: chatReq.Model, includeUsage: aiIncludeUsage(chatReq), toolIndexes: map[int]int{}}), nil
+	}
+
+	message := &anthropicResponse{}
+	return aiJSONResponse(resp, message, func() any {
+		return message.toOpenAI(chatReq.Model)
+	})
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float32           `json:"temperature,omitempty"`
+	TopP          *float32           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice    map[string]any     `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []map[string]any `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+func newAnthropicRequest(chatReq *openai.ChatCompletionRequest) (ret *anthropicRequest) {
+	system, conversation := aiChatMessages(chatReq.Messages)
+	ret = &anthropicRequest{
+		Model:         chatReq.Model,
+		System:        system,
+		Messages:      []anthropicMessage{},
+		MaxTokens:     aiMaxTokens(chatReq),
+		StopSequences: chatReq.Stop,
+		Stream:        chatReq.Stream,
+	}
+	if 1 > ret.MaxTokens {
+		ret.MaxTokens = anthropicDefaultMaxTokens
+	}
+	if 0 < chatReq.Temperature {
+		// Anthropic 的温度范围为 0~1
+		temperature := min(chatReq.Temperature, 1)
+		ret.Temperature = &temperature
+	}
+	if 0 < chatReq.TopP {
+		topP := chatReq.TopP
+		ret.TopP = &topP
+	}
+
+	for _, msg := range conversation {
+		role, blocks := anthropicBlocks(msg)
+		if 1 > len(blocks) {
+			continue
+		}
+		// Anthropic 要求用户和助手消息交替出现，连续同角色消息（如多个工具结果）合并为一条
+		if last := len(ret.Messages) - 1; 0 <= last && ret.Messages[last].Role == role {
+			ret.Messages[last].Content = append(ret.Messages[last].Content, blocks...)
+			continue
+		}
+		ret.Messages = append(ret.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, tool := range chatReq.Tools {
+		if nil == tool.Function {
+			continue
+		}
+		schema := tool.Function.Parameters
+		if nil == schema {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		ret.Tools = append(ret.Tools, anthropicTool{Name: tool.Function.Name, Description: tool.Function.Description, InputSchema: schema})
+	}
+	if 0 < len(ret.Tools) {
+		switch mode, name := aiToolChoice(chatReq.ToolChoice); mode {
+		case "none":
+			ret.ToolChoice = map[string]any{"type": "none"}
+		case "required":
+			ret.ToolChoice = map[string]any{"type": "any"}
+		case "function":
+			ret.ToolChoice = map[string]any{"type": "tool", "name": name}
+		}
+	}
+	return
+}
+
+// anthropicBlocks 把一条 OpenAI 消息转换为 Anthropic 内容块，工具结果作为用户消息中的 tool_result 块。
+func anthropicBlocks(msg openai.ChatCompletionMessage) (role string, blocks []map[string]any) {
+	switch msg.Role {
+	case openai.ChatMessageRoleTool:
+		role = "user"
+		blocks = append(blocks, map[string]any{"type": "tool_result", "tool_use_id": msg.ToolCallID, "content": aiMessageText(msg)})
+	case openai.ChatMessageRoleAssistant:
+		role = "assistant"
+		if text := aiMessageText(msg); "" != strings.TrimSpace(text) {
+			blocks = append(blocks, map[string]any{"type": "text", "text": text})
+		}
+		for _, toolCall := range msg.ToolCalls {
+			blocks = append(blocks, map[string]any{
+				"type":  "tool_use",
+				"id":    toolCall.ID,
+				"name":  toolCall.Function.Name,
+				"input": aiToolArguments(toolCall.Function.Arguments),
+			})
+		}
+	default:
+		role = "user"
+		if 1 > len(msg.MultiContent) {
+			if "" != strings.TrimSpace(msg.Content) {
+				blocks = append(blocks, map[string]any{"type": "text", "text": msg.Content})
+			}
+			return
+		}
+		for _, part := range msg.MultiContent {
+			switch part.Type {
+			case openai.ChatMessagePartTypeText:
+				if "" != strings.TrimSpace(part.Text) {
+					blocks = append(blocks, map[string]any{"type": "text", "text": part.Text})
+				}
+			case openai.ChatMessagePartTypeImageURL:
+				if nil == part.ImageURL {
+					continue
+				}
+				if mimeType, data, ok := aiParseDataURL(part.ImageURL.URL); ok {
+					blocks = append(blocks, map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": mimeType, "data": data}})
+				} else {
+					blocks = append(blocks, map[string]any{"type": "image", "source": map[string]any{"type": "url", "url": part.ImageURL.URL}})
+				}
+			}
+		}
+	}
+	return
+}
+
+type anthropicResponse struct {
+	ID         string                  `json:"id"`
+	Model      string                  `json:"model"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+type anthropicContentBlock struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text"`
+	Thinking string          `json:"thinking"`
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Input    json.RawMessage `json:"input"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// toOpenAI 转换为 OpenAI 用量。Anthropic 的 input_tokens 不含缓存读写部分，需要加回。
+func (usage anthropicUsage) toOpenAI() openai.Usage {
+	prompt := usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	return aiUsage(prompt, usage.OutputTokens, usage.CacheReadInputTokens)
+}
+
+func (message *anthropicResponse) toOpenAI(model string) openai.ChatCompletionResponse {
+	var content, reasoning strings.Builder
+	var toolCalls []openai.ToolCall
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "thinking":
+			reasoning.WriteString(block.Thinking)
+		case "tool_use":
+			arguments := string(block.Input)
+			if "" == arguments || "null" == arguments {
+				arguments = "{}"
+			}
+			toolCalls = append(toolCalls, openai.ToolCall{
+				ID:       block.ID,
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: block.Name, Arguments: arguments},
+			})
+		}
+	}
+	if "" != message.Model {
+		model = message.Model
+	}
+	return aiChatResponse(message.ID, model, content.String(), reasoning.String(), toolCalls, anthropicFinishReason(message.StopReason), message.Usage.toOpenAI())
+}
+
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens", "model_context_window_exceeded":
+		return string(openai.FinishReasonLength)
+	case "tool_use":
+		return string(openai.FinishReasonToolCalls)
+	case "refusal":
+		return string(openai.FinishReasonContentFilter)
+	case "":
+		return ""
+	}
+	return string(openai.FinishReasonStop)
+}
+
+// anthropicStream 把 Anthropic 流式事件转换为 OpenAI chunk。
+type anthropicStream struct {
+	id           string
+	model        string
+	includeUsage bool
+	toolIndexes  map[int]int // 内容块序号 -> 工具调用序号
+	usage        anthropicUsage
+}
+
+type anthropicStreamEvent struct {
+	Type         string                `json:"type"`
+	Index        int                   `json:"index"`
+	Message      *anthropicResponse    `json:"message"`
+	ContentBlock anthropicContentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (stream *anthropicStream) convert(_ string, data []byte) (ret []any, err error) {
+	event := &anthropicStreamEvent{}
+	if err = json.Unmarshal(data, event); err != nil {
+		return
+	}
+
+	switch event.Type {
+	case "message_start":
+		if nil != event.Message {
+			stream.usage = event.Message.Usage
+		}
+		ret = append(ret, aiChatChunk(stream.id, stream.model, openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant}, ""))
+	case "content_block_start":
+		if "tool_use" != event.ContentBlock.Type {
+			return
+		}
+		index := len(stream.toolIndexes)
+		stream.toolIndexes[event.Index] = index
+		ret = append(ret, aiChatChunk(stream.id, stream.model, openai.ChatCompletionStreamChoiceDelta{
+			ToolCalls: []openai.ToolCall{{
+				Index:    &index,
+				ID:       event.ContentBlock.ID,
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: event.ContentBlock.Name},
+			}},
+		}, ""))
+	case "content_block_delta":
+		delta := openai.ChatCompletionStreamChoiceDelta{}
+		switch event.Delta.Type {
+		case "text_delta":
+			delta.Content = event.Delta.Text
+		case "thinking_delta":
+			delta.ReasoningContent = event.Delta.Thinking
+		case "input_json_delta":
+			index, ok := stream.toolIndexes[event.Index]
+			if !ok || "" == event.Delta.PartialJSON {
+				return
+			}
+			delta.ToolCalls = []openai.ToolCall{{Index: &index, Function: openai.FunctionCall{Arguments: event.Delta.PartialJSON}}}
+		default:
+			return
+		}
+		ret = append(ret, aiChatChunk(stream.id, stream.model, delta, ""))
+	case "message_delta":
+		if nil != event.Usage {
+			stream.usage.OutputTokens = event.Usage.OutputTokens
+		}
+		if finishReason := anthropicFinishReason(event.Delta.StopReason); "" != finishReason {
+			ret = append(ret, aiChatChunk(stream.id, stream.model, openai.ChatCompletionStreamChoiceDelta{}, finishReason))
+		}
+	case "error":
+		if nil != event.Error {
+			err = errors.New(event.Error.Type + ": " + event.Error.Message)
+		} else {
+			err = errors.New(string(data))
+		}
+	}
+	return
+}
+
+func (stream *anthropicStream) finish() []any {
+	if !stream.includeUsage {
+		return nil
+	}
+	return []any{aiUsageChunk(stream.id, stream.model, stream.usage.toOpenAI())}
+}
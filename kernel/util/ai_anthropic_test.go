@@ -0,0 +1,211 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func testToolConversation() []openai.ChatCompletionMessage {
+	return []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "be brief"},
+		{Role: openai.ChatMessageRoleUser, Content: "weather?"},
+		{Role: openai.ChatMessageRoleAssistant, ToolCalls: []openai.ToolCall{
+			{ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`}},
+			{ID: "call_2", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "weather", Arguments: `{"city":"Rome"}`}},
+		}},
+		{Role: openai.ChatMessageRoleTool, ToolCallID: "call_1", Content: "sunny"},
+		{Role: openai.ChatMessageRoleTool, ToolCallID: "call_2", Content: "rainy"},
+	}
+}
+
+func testTools() []openai.Tool {
+	return []openai.Tool{{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{
+		Name:       "weather",
+		Parameters: map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+	}}}
+}
+
+func TestAnthropicChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") == "" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected request: %s %v", r.URL.Path, r.Header)
+		}
+		var body anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if body.System != "be brief" || body.MaxTokens != anthropicDefaultMaxTokens || len(body.Tools) != 1 || body.Tools[0].InputSchema == nil {
+			t.Errorf("unexpected request body: %+v", body)
+		}
+		// user, assistant(tool_use x2), user(tool_result x2)
+		if len(body.Messages) != 3 || len(body.Messages[1].Content) != 2 || len(body.Messages[2].Content) != 2 ||
+			body.Messages[1].Content[0]["type"] != "tool_use" || body.Messages[2].Content[1]["tool_use_id"] != "call_2" {
+			t.Errorf("unexpected messages: %+v", body.Messages)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","model":"claude-test","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Paris is sunny"},{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Oslo"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"cache_read_input_tokens":5,"output_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	client := NewAIClient(AIProtocolAnthropic, "key", server.URL+"/v1", "claude-test")
+	resp, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: "claude-test", Messages: testToolConversation(), Tools: testTools()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := resp.Choices[0].Message
+	if message.Content != "Paris is sunny" || message.ReasoningContent != "hmm" || resp.Choices[0].FinishReason != openai.FinishReasonToolCalls ||
+		len(message.ToolCalls) != 1 || message.ToolCalls[0].Function.Arguments != `{"city":"Oslo"}` {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 15 || resp.Usage.CompletionTokens != 7 || resp.Usage.PromptTokensDetails.CachedTokens != 5 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicChatCompletionStream(t *testing.T) {
+	events := []string{
+		`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`event: ping` + "\n" + `data: {"type":"ping"}`,
+		`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+		`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"weather","input":{}}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Oslo\"}"}}`,
+		`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+		`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true {
+			t.Errorf("stream flag is missing: %v", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(strings.Join(events, "\n\n") + "\n\n"))
+	}))
+	defer server.Close()
+
+	client := NewAIClient(AIProtocolAnthropic, "key", server.URL, "claude-test")
+	stream, err := client.CreateChatCompletionStream(context.Background(), openai.ChatCompletionRequest{
+		Model: "claude-test", Messages: testToolConversation()[:2], Tools: testTools(), StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	content, arguments, finishReason, usage := collectTestStream(t, stream)
+	if content != "Hello" || arguments != `{"city":"Oslo"}` || finishReason != openai.FinishReasonToolCalls {
+		t.Fatalf("unexpected stream result: %q %q %q", content, arguments, finishReason)
+	}
+	if usage == nil || usage.PromptTokens != 12 || usage.CompletionTokens != 9 {
+		t.Fatalf("unexpected stream usage: %+v", usage)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+			"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+	}))
+	defer server.Close()
+
+	client := NewAIClient(AIProtocolAnthropic, "key", server.URL, "claude-test")
+	stream, err := client.CreateChatCompletionStream(context.Background(), openai.ChatCompletionRequest{Model: "claude-test", Messages: testToolConversation()[:2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if errors.Is(err, io.EOF) || !strings.Contains(err.Error(), "Overloaded") {
+		t.Fatalf("stream error was not surfaced: %v", err)
+	}
+}
+
+func TestAnthropicModelsAndErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"data":[{"type":"model","id":"claude-test"}],"has_more":false}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+		}
+	}))
+	defer server.Close()
+
+	_, matched, err := TestModel(AIProtocolAnthropic, "key", server.URL+"/v1", "claude-test", 5)
+	if err != nil || !matched {
+		t.Fatalf("unexpected model test result: matched=%v err=%v", matched, err)
+	}
+
+	client := NewAIClient(AIProtocolAnthropic, "bad", server.URL+"/v1", "claude-test")
+	_, err = client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: "claude-test", Messages: testToolConversation()[:2]})
+	apiErr := &openai.APIError{}
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusUnauthorized || !strings.Contains(apiErr.Message, "invalid x-api-key") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, _, err = BatchGetEmbeddings([]string{"a"}, AIProtocolAnthropic, "key", server.URL+"/v1", "claude-test", 0, 5); err == nil {
+		t.Fatal("anthropic protocol does not support embeddings")
+	}
+}
+
+func collectTestStream(t *testing.T, stream *openai.ChatCompletionStream) (content, arguments string, finishReason openai.FinishReason, usage *openai.Usage) {
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Usage != nil {
+			usage = resp.Usage
+		}
+		for _, choice := range resp.Choices {
+			content += choice.Delta.Content
+			for _, toolCall := range choice.Delta.ToolCalls {
+				if toolCall.Index == nil || *toolCall.Index != 0 {
+					t.Fatalf("unexpected tool call index: %+v", toolCall)
+				}
+				arguments += toolCall.Function.Arguments
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+	}
+}
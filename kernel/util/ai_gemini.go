@@ -0,0 +1,492 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// geminiTransport 把 go-openai 的请求转换为 Google Gemini generateContent 请求。
+// 支持 chat/completions（含流式和工具调用）、embeddings 和 models。
+type geminiTransport struct {
+	base   openai.HTTPDoer
+	apiKey string
+}
+
+func (t *geminiTransport) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Del("Authorization")
+	if "" != t.apiKey {
+		req.Header.Set("x-goog-api-key", t.apiKey)
+	}
+
+	endpoint := aiEndpoint(req)
+	switch {
+	case "/chat/completions" == endpoint && http.MethodPost == req.Method:
+		return t.chat(req)
+	case "/embeddings" == endpoint && http.MethodPost == req.Method:
+		return t.embeddings(req)
+	case "/models" == endpoint && http.MethodGet == req.Method:
+		return t.models(req)
+	}
+	return nil, fmt.Errorf("endpoint [%s] is not supported by the gemini protocol", req.URL.Path)
+}
+
+// geminiModelPath 返回模型资源路径，兼容带或不带 models/ 前缀的模型名。
+func geminiModelPath(model string) string {
+	return "/models/" + strings.TrimPrefix(model, "models/")
+}
+
+func (t *geminiTransport) chat(req *http.Request) (*http.Response, error) {
+	chatReq := &openai.ChatCompletionRequest{}
+	if err := aiReadRequest(req, chatReq); err != nil {
+		return nil, err
+	}
+	if chatReq.Stream {
+		aiRewriteURL(req, "/chat/completions", geminiModelPath(chatReq.Model)+":streamGenerateContent", map[string]string{"alt": "sse"})
+	} else {
+		aiRewriteURL(req, "/chat/completions", geminiModelPath(chatReq.Model)+":generateContent", nil)
+	}
+	if err := aiSetRequestBody(req, newGeminiRequest(chatReq)); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.Do(req)
+	if err != nil || !aiIsSuccess(resp) {
+		return resp, err
+	}
+	if chatReq.Stream {
+		return aiStreamResponse(resp, &geminiStream{id: aiChatID(), model: chatReq.Model, includeUsage: aiIncludeUsage(chatReq)}), nil
+	}
+
+	generated := &geminiResponse{}
+	return aiJSONResponse(resp, generated, func() any {
+		return generated.toOpenAI(chatReq.Model)
+	})
+}
+
+func (t *geminiTransport) embeddings(req *http.Request) (*http.Response, error) {
+	embeddingReq := &struct {
+		Input      any    `json:"input"`
+		Model      string `json:"model"`
+		Dimensions int    `json:"dimensions"`
+	}{}
+	if err := aiReadRequest(req, embeddingReq); err != nil {
+		return nil, err
+	}
+	var texts []string
+	switch input := embeddingReq.Input.(type) {
+	case string:
+		texts = append(texts, input)
+	case []any:
+		for _, item := range input {
+			text, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("embedding input must be strings for the gemini protocol")
+			}
+			texts = append(texts, text)
+		}
+	}
+
+	modelPath := geminiModelPath(embeddingReq.Model)
+	native := map[string]any{}
+	var requests []map[string]any
+	for _, text := range texts {
+		request := map[string]any{
+			"model":   strings.TrimPrefix(modelPath, "/"),
+			"content": geminiContent{Parts: []geminiPart{{Text: text}}},
+		}
+		if 0 < embeddingReq.Dimensions {
+			request["outputDimensionality"] = embeddingReq.Dimensions
+		}
+		requests = append(requests, request)
+	}
+	native["requests"] = requests
+	aiRewriteURL(req, "/embeddings", modelPath+":batchEmbedContents", nil)
+	if err := aiSetRequestBody(req, native); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.Do(req)
+	if err != nil || !aiIsSuccess(resp) {
+		return resp, err
+	}
+	embedded := &struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}{}
+	return aiJSONResponse(resp, embedded, func() any {
+		ret := openai.EmbeddingResponse{Object: "list", Model: openai.EmbeddingModel(embeddingReq.Model), Data: []openai.Embedding{}}
+		for i, embedding := range embedded.Embeddings {
+			ret.Data = append(ret.Data, openai.Embedding{Object: "embedding", Index: i, Embedding: embedding.Values})
+		}
+		return ret
+	})
+}
+
+func (t *geminiTransport) models(req *http.Request) (*http.Response, error) {
+	aiRewriteURL(req, "/models", "/models", map[string]string{"pageSize": "1000"})
+	resp, err := t.base.Do(req)
+	if err != nil || !aiIsSuccess(resp) {
+		return resp, err
+	}
+	list := &struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}{}
+	return aiJSONResponse(resp, list, func() any {
+		ret := openai.ModelsList{Models: []openai.Model{}}
+		for _, m := range list.Models {
+			ret.Models = append(ret.Models, openai.Model{ID: strings.TrimPrefix(m.Name, "models/"), Object: "model", OwnedBy: "google"})
+		}
+		return ret
+	})
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	ThoughtSignature string                  `json:"thoughtSignature,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FileData         *geminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type geminiFunctionCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	ParametersJSONSchema any    `json:"parametersJsonSchema,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode                 string   `json:"mode"`
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float32              `json:"temperature,omitempty"`
+	TopP            *float32              `json:"topP,omitempty"`
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string              `json:"stopSequences,omitempty"`
+	ThinkingConfig  *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type geminiThinkingConfig struct {
+	IncludeThoughts bool `json:"includeThoughts"`
+}
+
+// geminiToolCallID 生成工具调用 ID。Gemini 要求在后续请求中原样回传函数调用的思考签名，
+// OpenAI 消息没有对应字段，所以把签名附在 ID 后，回传历史时再拆出。
+func geminiToolCallID(signature string) string {
+	if "" == signature {
+		return aiToolCallID()
+	}
+	return aiToolCallID() + "." + signature
+}
+
+func geminiToolCallSignature(id string) string {
+	_, signature, _ := strings.Cut(id, ".")
+	return signature
+}
+
+func newGeminiRequest(chatReq *openai.ChatCompletionRequest) (ret *geminiRequest) {
+	system, conversation := aiChatMessages(chatReq.Messages)
+	ret = &geminiRequest{Contents: []geminiContent{}}
+	if "" != system {
+		ret.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+
+	config := &geminiGenerationConfig{MaxOutputTokens: aiMaxTokens(chatReq), StopSequences: chatReq.Stop}
+	if 0 < chatReq.Temperature {
+		temperature := chatReq.Temperature
+		config.Temperature = &temperature
+	}
+	if 0 < chatReq.TopP {
+		topP := chatReq.TopP
+		config.TopP = &topP
+	}
+	if "" != chatReq.ReasoningEffort {
+		config.ThinkingConfig = &geminiThinkingConfig{IncludeThoughts: true}
+	}
+	ret.GenerationConfig = config
+
+	// 工具结果需要携带函数名，从之前的助手消息中按调用 ID 查找
+	toolNames := map[string]string{}
+	for _, msg := range conversation {
+		for _, toolCall := range msg.ToolCalls {
+			toolNames[toolCall.ID] = toolCall.Function.Name
+		}
+	}
+	for _, msg := range conversation {
+		content := geminiMessageContent(msg, toolNames)
+		if 1 > len(content.Parts) {
+			continue
+		}
+		// 同一轮的多个工具结果需要放在同一条消息中，连续同角色消息合并
+		if last := len(ret.Contents) - 1; 0 <= last && ret.Contents[last].Role == content.Role {
+			ret.Contents[last].Parts = append(ret.Contents[last].Parts, content.Parts...)
+			continue
+		}
+		ret.Contents = append(ret.Contents, content)
+	}
+
+	var declarations []geminiFunctionDeclaration
+	for _, tool := range chatReq.Tools {
+		if nil == tool.Function {
+			continue
+		}
+		declarations = append(declarations, geminiFunctionDeclaration{Name: tool.Function.Name, Description: tool.Function.Description, ParametersJSONSchema: tool.Function.Parameters})
+	}
+	if 0 < len(declarations) {
+		ret.Tools = []geminiTool{{FunctionDeclarations: declarations}}
+		toolConfig := &geminiToolConfig{}
+		switch mode, name := aiToolChoice(chatReq.ToolChoice); mode {
+		case "none":
+			toolConfig.FunctionCallingConfig.Mode = "NONE"
+		case "required":
+			toolConfig.FunctionCallingConfig.Mode = "ANY"
+		case "function":
+			toolConfig.FunctionCallingConfig.Mode = "ANY"
+			toolConfig.FunctionCallingConfig.AllowedFunctionNames = []string{name}
+		default:
+			toolConfig = nil
+		}
+		ret.ToolConfig = toolConfig
+	}
+	return
+}
+
+// geminiMessageContent 把一条 OpenAI 消息转换为 Gemini 内容，助手角色为 model，工具结果为 functionResponse。
+func geminiMessageContent(msg openai.ChatCompletionMessage, toolNames map[string]string) (ret geminiContent) {
+	switch msg.Role {
+	case openai.ChatMessageRoleTool:
+		ret.Role = "user"
+		name := toolNames[msg.ToolCallID]
+		if "" == name {
+			name = msg.Name
+		}
+		ret.Parts = append(ret.Parts, geminiPart{FunctionResponse: &geminiFunctionResponse{Name: name, Response: map[string]any{"content": aiMessageText(msg)}}})
+	case openai.ChatMessageRoleAssistant:
+		ret.Role = "model"
+		if text := aiMessageText(msg); "" != strings.TrimSpace(text) {
+			ret.Parts = append(ret.Parts, geminiPart{Text: text})
+		}
+		for _, toolCall := range msg.ToolCalls {
+			ret.Parts = append(ret.Parts, geminiPart{
+				FunctionCall:     &geminiFunctionCall{Name: toolCall.Function.Name, Args: aiToolArguments(toolCall.Function.Arguments)},
+				ThoughtSignature: geminiToolCallSignature(toolCall.ID),
+			})
+		}
+	default:
+		ret.Role = "user"
+		if 1 > len(msg.MultiContent) {
+			if "" != strings.TrimSpace(msg.Content) {
+				ret.Parts = append(ret.Parts, geminiPart{Text: msg.Content})
+			}
+			return
+		}
+		for _, part := range msg.MultiContent {
+			switch part.Type {
+			case openai.ChatMessagePartTypeText:
+				if "" != strings.TrimSpace(part.Text) {
+					ret.Parts = append(ret.Parts, geminiPart{Text: part.Text})
+				}
+			case openai.ChatMessagePartTypeImageURL:
+				if nil == part.ImageURL {
+					continue
+				}
+				if mimeType, data, ok := aiParseDataURL(part.ImageURL.URL); ok {
+					ret.Parts = append(ret.Parts, geminiPart{InlineData: &geminiBlob{MimeType: mimeType, Data: data}})
+				} else {
+					ret.Parts = append(ret.Parts, geminiPart{FileData: &geminiFileData{FileURI: part.ImageURL.URL}})
+				}
+			}
+		}
+	}
+	return
+}
+
+type geminiResponse struct {
+	ResponseID string `json:"responseId"`
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata *geminiUsage `json:"usageMetadata"`
+}
+
+type geminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+}
+
+func (usage *geminiUsage) toOpenAI() openai.Usage {
+	if nil == usage {
+		return openai.Usage{}
+	}
+	return aiUsage(usage.PromptTokenCount, usage.CandidatesTokenCount+usage.ThoughtsTokenCount, usage.CachedContentTokenCount)
+}
+
+// parts 返回首个候选的文本、思考内容、函数调用和结束原因。
+func (generated *geminiResponse) parts() (content, reasoning string, toolCalls []openai.ToolCall, finishReason string) {
+	if 1 > len(generated.Candidates) {
+		return
+	}
+	candidate := generated.Candidates[0]
+	var contentBuf, reasoningBuf strings.Builder
+	for _, part := range candidate.Content.Parts {
+		switch {
+		case nil != part.FunctionCall:
+			arguments, _ := json.Marshal(part.FunctionCall.Args)
+			if nil == part.FunctionCall.Args {
+				arguments = []byte("{}")
+			}
+			toolCalls = append(toolCalls, openai.ToolCall{
+				ID:       geminiToolCallID(part.ThoughtSignature),
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: part.FunctionCall.Name, Arguments: string(arguments)},
+			})
+		case part.Thought:
+			reasoningBuf.WriteString(part.Text)
+		default:
+			contentBuf.WriteString(part.Text)
+		}
+	}
+	content, reasoning = contentBuf.String(), reasoningBuf.String()
+	finishReason = geminiFinishReason(candidate.FinishReason)
+	return
+}
+
+func (generated *geminiResponse) toOpenAI(model string) openai.ChatCompletionResponse {
+	content, reasoning, toolCalls, finishReason := generated.parts()
+	if 0 < len(toolCalls) && string(openai.FinishReasonStop) == finishReason {
+		finishReason = string(openai.FinishReasonToolCalls)
+	}
+	id := generated.ResponseID
+	if "" == id {
+		id = aiChatID()
+	}
+	return aiChatResponse(id, model, content, reasoning, toolCalls, finishReason, generated.UsageMetadata.toOpenAI())
+}
+
+func geminiFinishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "STOP":
+		return string(openai.FinishReasonStop)
+	case "MAX_TOKENS":
+		return string(openai.FinishReasonLength)
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return string(openai.FinishReasonContentFilter)
+	}
+	return string(openai.FinishReasonStop)
+}
+
+// geminiStream 把 Gemini 流式响应转换为 OpenAI chunk。Gemini 每个事件都是完整的响应片段，
+// 函数调用一次性给出完整参数。
+type geminiStream struct {
+	id           string
+	model        string
+	includeUsage bool
+	started      bool
+	toolCount    int
+	usage        *geminiUsage
+}
+
+func (stream *geminiStream) convert(_ string, data []byte) (ret []any, err error) {
+	generated := &geminiResponse{}
+	if err = json.Unmarshal(data, generated); err != nil {
+		return
+	}
+	if nil != generated.UsageMetadata {
+		stream.usage = generated.UsageMetadata
+	}
+
+	content, reasoning, toolCalls, finishReason := generated.parts()
+	delta := openai.ChatCompletionStreamChoiceDelta{Content: content, ReasoningContent: reasoning}
+	if !stream.started {
+		stream.started = true
+		delta.Role = openai.ChatMessageRoleAssistant
+	}
+	for i := range toolCalls {
+		index := stream.toolCount
+		stream.toolCount++
+		toolCalls[i].Index = &index
+	}
+	delta.ToolCalls = toolCalls
+	if 0 < stream.toolCount && string(openai.FinishReasonStop) == finishReason {
+		finishReason = string(openai.FinishReasonToolCalls)
+	}
+	ret = append(ret, aiChatChunk(stream.id, stream.model, delta, finishReason))
+	return
+}
+
+func (stream *geminiStream) finish() []any {
+	if !stream.includeUsage {
+		return nil
+	}
+	return []any{aiUsageChunk(stream.id, stream.model, stream.usage.toOpenAI())}
+}
@@ -0,0 +1,158 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestGeminiChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-test:generateContent" || r.Header.Get("x-goog-api-key") != "key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected request: %s %v", r.URL.Path, r.Header)
+		}
+		var body geminiRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if body.SystemInstruction == nil || body.SystemInstruction.Parts[0].Text != "be brief" || len(body.Tools) != 1 {
+			t.Errorf("unexpected request body: %+v", body)
+		}
+		// user, model(functionCall x2), user(functionResponse x2)
+		if len(body.Contents) != 3 || body.Contents[1].Role != "model" || len(body.Contents[2].Parts) != 2 {
+			t.Fatalf("unexpected contents: %+v", body.Contents)
+		}
+		call := body.Contents[1].Parts[0]
+		response := body.Contents[2].Parts[1].FunctionResponse
+		if call.FunctionCall.Args["city"] != "Paris" || call.ThoughtSignature != "c2ln" || response.Name != "weather" || response.Response["content"] != "rainy" {
+			t.Errorf("unexpected tool round trip: %+v %+v", call, response)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"text":"think","thought":true},{"text":"Rome is rainy"},{"functionCall":{"name":"weather","args":{"city":"Oslo"}},"thoughtSignature":"bmV4dA=="}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":4,"thoughtsTokenCount":2}}`))
+	}))
+	defer server.Close()
+
+	messages := testToolConversation()
+	messages[2].ToolCalls[0].ID = "call_1.c2ln"
+	messages[3].ToolCallID = "call_1.c2ln"
+	client := NewAIClient(AIProtocolGemini, "key", server.URL+"/v1beta", "gemini-test")
+	resp, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: "gemini-test", Messages: messages, Tools: testTools()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := resp.Choices[0].Message
+	if message.Content != "Rome is rainy" || message.ReasoningContent != "think" || resp.Choices[0].FinishReason != openai.FinishReasonToolCalls || len(message.ToolCalls) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if geminiToolCallSignature(message.ToolCalls[0].ID) != "bmV4dA==" || message.ToolCalls[0].Function.Arguments != `{"city":"Oslo"}` {
+		t.Fatalf("unexpected tool call: %+v", message.ToolCalls[0])
+	}
+	if resp.Usage.PromptTokens != 20 || resp.Usage.CompletionTokens != 6 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestGeminiChatCompletionStream(t *testing.T) {
+	chunks := []string{
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}],"usageMetadata":{"promptTokenCount":8}}`,
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"lo"},{"functionCall":{"name":"weather","args":{"city":"Oslo"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":5}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-test:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("unexpected stream request: %s", r.URL.String())
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(strings.Join(chunks, "\r\n\r\n") + "\r\n\r\n"))
+	}))
+	defer server.Close()
+
+	client := NewAIClient(AIProtocolGemini, "key", server.URL, "gemini-test")
+	stream, err := client.CreateChatCompletionStream(context.Background(), openai.ChatCompletionRequest{
+		Model: "gemini-test", Messages: testToolConversation()[:2], Tools: testTools(), StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	content, arguments, finishReason, usage := collectTestStream(t, stream)
+	if content != "Hello" || arguments != `{"city":"Oslo"}` || finishReason != openai.FinishReasonToolCalls {
+		t.Fatalf("unexpected stream result: %q %q %q", content, arguments, finishReason)
+	}
+	if usage == nil || usage.PromptTokens != 8 || usage.CompletionTokens != 5 {
+		t.Fatalf("unexpected stream usage: %+v", usage)
+	}
+}
+
+func TestGeminiEmbeddingsModelsAndVision(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1beta/models/text-embedding:batchEmbedContents":
+			var body struct {
+				Requests []struct {
+					Model                string `json:"model"`
+					OutputDimensionality int    `json:"outputDimensionality"`
+				} `json:"requests"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if len(body.Requests) != 2 || body.Requests[0].Model != "models/text-embedding" || body.Requests[0].OutputDimensionality != 3 {
+				t.Errorf("unexpected embedding request: %+v", body)
+			}
+			_, _ = w.Write([]byte(`{"embeddings":[{"values":[1,2,3]},{"values":[4,5,6]}]}`))
+		case "/v1beta/models":
+			_, _ = w.Write([]byte(`{"models":[{"name":"models/gemini-test"},{"name":"models/text-embedding"}]}`))
+		case "/v1beta/models/gemini-test:generateContent":
+			var body geminiRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			parts := body.Contents[0].Parts
+			if len(parts) != 2 || parts[1].InlineData == nil || parts[1].InlineData.MimeType != "image/png" || parts[1].InlineData.Data != "cG5n" {
+				t.Errorf("unexpected vision request: %+v", body.Contents)
+			}
+			_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"a chart"}]},"finishReason":"STOP"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	vectors, _, err := BatchGetEmbeddings([]string{"a", "b"}, AIProtocolGemini, "key", server.URL+"/v1beta", "text-embedding", 3, 5)
+	if err != nil || len(vectors) != 2 || vectors[1][2] != 6 {
+		t.Fatalf("unexpected embeddings %v: %v", vectors, err)
+	}
+
+	models, err := ListAvailableModels(AIProtocolGemini, "key", server.URL+"/v1beta", 5)
+	if err != nil || len(models) != 2 || models[0] != "gemini-test" {
+		t.Fatalf("unexpected models %v: %v", models, err)
+	}
+
+	adapter := NewImageAdapter(AIProtocolGemini, "key", server.URL+"/v1beta", "gemini-test", 5)
+	analysis, _, err := adapter.Analyze(context.Background(), PreparedImage{Data: []byte("png"), MIMEType: "image/png"}, "What is shown?", "")
+	if err != nil || analysis != "a chart" {
+		t.Fatalf("unexpected analysis %q: %v", analysis, err)
+	}
+	if _, err = adapter.Generate(context.Background(), GenerateImageRequest{Prompt: "x"}); err == nil {
+		t.Fatal("image generation must be rejected for the gemini protocol")
+	}
+}
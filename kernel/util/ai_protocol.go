@@ -0,0 +1,440 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/httpclient"
+)
+
+// AI 服务商协议。OpenAI 兼容服务直接使用 go-openai client，其他协议通过 HTTPDoer 扩展点
+// 把 OpenAI 格式的请求转换为原生请求、再把原生响应转换回 OpenAI 格式，调用方无需感知协议差异。
+const (
+	AIProtocolOpenAI    = "openai"
+	AIProtocolAnthropic = "anthropic"
+	AIProtocolGemini    = "gemini"
+)
+
+// IsAIProtocol 判断是否为支持的服务商协议。
+func IsAIProtocol(protocol string) bool {
+	switch protocol {
+	case AIProtocolOpenAI, AIProtocolAnthropic, AIProtocolGemini:
+		return true
+	}
+	return false
+}
+
+// DefaultAIBaseURL 返回协议的官方 API 地址。
+func DefaultAIBaseURL(protocol string) string {
+	switch protocol {
+	case AIProtocolAnthropic:
+		return "https://api.anthropic.com/v1"
+	case AIProtocolGemini:
+		return "https://generativelanguage.googleapis.com/v1beta"
+	}
+	return "https://api.openai.com/v1"
+}
+
+// NewAIClient 按服务商协议创建 client。OpenAI 协议等同于 NewOpenAIClientWithModel；
+// Anthropic 和 Gemini 协议的 client 支持 Chat Completion（含流式和工具调用）、模型列表，
+// Gemini 协议还支持 Embeddings。
+func NewAIClient(protocol, apiKey, apiBaseURL, model string) *openai.Client {
+	return newAIClient(protocol, apiKey, apiBaseURL, model, httpclient.NewUserAgentClient(nil))
+}
+
+func newAIClient(protocol, apiKey, apiBaseURL, model string, base openai.HTTPDoer) *openai.Client {
+	var transport openai.HTTPDoer
+	switch protocol {
+	case AIProtocolAnthropic:
+		transport = &anthropicTransport{base: base, apiKey: apiKey}
+	case AIProtocolGemini:
+		transport = &geminiTransport{base: base, apiKey: apiKey}
+	default:
+		if extra := ExtraBodyForModel(model); 0 < len(extra) {
+			base = &extraBodyTransport{base: base, extraBody: extra}
+		}
+		config := openai.DefaultConfig(apiKey)
+		config.BaseURL = apiBaseURL
+		config.HTTPClient = base
+		return openai.NewClientWithConfig(config)
+	}
+
+	// 鉴权头由协议适配器按各自协议设置，这里不传 API Key 避免发送 Authorization 头
+	config := openai.DefaultConfig("")
+	config.BaseURL = strings.TrimRight(apiBaseURL, "/")
+	config.HTTPClient = transport
+	return openai.NewClientWithConfig(config)
+}
+
+// aiEndpoint 返回 go-openai 请求的端点后缀（如 /chat/completions、/models），用于协议适配器分派。
+func aiEndpoint(req *http.Request) string {
+	path := strings.TrimRight(req.URL.Path, "/")
+	for _, endpoint := range []string{"/chat/completions", "/embeddings", "/models"} {
+		if strings.HasSuffix(path, endpoint) {
+			return endpoint
+		}
+	}
+	return ""
+}
+
+// aiRewriteURL 把请求地址的端点后缀替换为协议原生端点，query 可为空。
+func aiRewriteURL(req *http.Request, endpoint, nativePath string, query map[string]string) {
+	u := *req.URL
+	u.Path = strings.TrimSuffix(strings.TrimRight(u.Path, "/"), endpoint) + nativePath
+	u.RawPath = ""
+	values := u.Query()
+	for k, v := range query {
+		values.Set(k, v)
+	}
+	u.RawQuery = values.Encode()
+	req.URL = &u
+}
+
+// aiReadRequest 读取 go-openai 发出的 JSON 请求体。
+func aiReadRequest(req *http.Request, v any) error {
+	if nil == req.Body {
+		return errors.New("request body is empty")
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// aiSetRequestBody 把转换后的原生请求体写回请求。
+func aiSetRequestBody(req *http.Request, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return nil
+}
+
+// aiIsSuccess 判断上游是否成功响应。失败响应原样透传：Anthropic 和 Gemini 的错误体
+// 都是 {"error":{"message":...}} 结构，go-openai 可以直接解析为 APIError。
+func aiIsSuccess(resp *http.Response) bool {
+	return http.StatusOK <= resp.StatusCode && http.StatusMultipleChoices > resp.StatusCode
+}
+
+// aiJSONResponse 读取上游 JSON 响应并用 convert 转换后替换响应体。
+func aiJSONResponse(resp *http.Response, native any, convert func() any) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(body, native); err != nil {
+		return nil, err
+	}
+	converted, err := json.Marshal(convert())
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(converted))
+	resp.ContentLength = int64(len(converted))
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(converted)))
+	resp.Header.Set("Content-Type", "application/json")
+	return resp, nil
+}
+
+// aiStreamConverter 把上游 SSE 事件转换为 OpenAI chat.completion.chunk。
+type aiStreamConverter interface {
+	// convert 处理一个上游事件，返回需要下发的 chunk。
+	convert(event string, data []byte) ([]any, error)
+	// finish 在上游结束后调用，返回收尾的 chunk（如用量统计）。
+	finish() []any
+}
+
+// aiStreamResponse 用管道把上游 SSE 流逐事件转换为 OpenAI 流式格式，最后补发 [DONE]。
+// 上游中途报错时下发 data: {"error":...}，go-openai 会将其解析为 APIError。
+func aiStreamResponse(resp *http.Response, converter aiStreamConverter) *http.Response {
+	upstream := resp.Body
+	reader, writer := io.Pipe()
+	go func() {
+		defer upstream.Close()
+
+		write := func(chunks []any) error {
+			for _, chunk := range chunks {
+				data, err := json.Marshal(chunk)
+				if err != nil {
+					return err
+				}
+				if _, err = writer.Write([]byte("data: " + string(data) + "\n\n")); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		fail := func(err error) {
+			data, _ := json.Marshal(map[string]any{"error": map[string]any{"message": err.Error(), "type": "stream_error"}})
+			writer.Write([]byte("data: " + string(data) + "\n\n"))
+			writer.Close()
+		}
+
+		scanner := bufio.NewScanner(upstream)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		var event string
+		var data bytes.Buffer
+		dispatch := func() error {
+			defer func() {
+				event = ""
+				data.Reset()
+			}()
+			if 0 == data.Len() {
+				return nil
+			}
+			chunks, err := converter.convert(event, data.Bytes())
+			if err != nil {
+				return err
+			}
+			return write(chunks)
+		}
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case "" == line:
+				if err := dispatch(); err != nil {
+					fail(err)
+					return
+				}
+			case strings.HasPrefix(line, "event:"):
+				event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			case strings.HasPrefix(line, "data:"):
+				if 0 < data.Len() {
+					data.WriteByte('\n')
+				}
+				data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		if err := dispatch(); err != nil {
+			fail(err)
+			return
+		}
+		if err := write(converter.finish()); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		writer.Write([]byte("data: [DONE]\n\n"))
+		writer.Close()
+	}()
+
+	resp.Body = &aiStreamBody{PipeReader: reader, upstream: upstream}
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	resp.Header.Set("Content-Type", "text/event-stream")
+	return resp
+}
+
+// aiStreamBody 关闭时同时关闭上游响应体，使转换协程及时退出。
+type aiStreamBody struct {
+	*io.PipeReader
+	upstream io.Closer
+}
+
+func (body *aiStreamBody) Close() error {
+	body.upstream.Close()
+	return body.PipeReader.Close()
+}
+
+// aiChatMessages 把 OpenAI 消息拆分为开头的系统提示和对话消息。对话中途的系统消息
+// （如上下文压缩提示）在 Anthropic 和 Gemini 中没有对应角色，转为用户消息。
+func aiChatMessages(messages []openai.ChatCompletionMessage) (system string, conversation []openai.ChatCompletionMessage) {
+	var systems []string
+	leading := true
+	for _, msg := range messages {
+		if openai.ChatMessageRoleSystem == msg.Role || openai.ChatMessageRoleDeveloper == msg.Role {
+			text := aiMessageText(msg)
+			if leading {
+				if "" != text {
+					systems = append(systems, text)
+				}
+				continue
+			}
+			msg = openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: text}
+		}
+		leading = false
+		conversation = append(conversation, msg)
+	}
+	system = strings.Join(systems, "\n\n")
+	return
+}
+
+// aiMessageText 返回消息的纯文本内容。
+func aiMessageText(msg openai.ChatCompletionMessage) string {
+	if 1 > len(msg.MultiContent) {
+		return msg.Content
+	}
+	var texts []string
+	for _, part := range msg.MultiContent {
+		if openai.ChatMessagePartTypeText == part.Type && "" != part.Text {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// aiParseDataURL 解析 data:<mime>;base64,<data> 形式的图片地址，返回 MIME 类型和 base64 数据。
+func aiParseDataURL(dataURL string) (mimeType, data string, ok bool) {
+	if !strings.HasPrefix(dataURL, "data:") {
+		return
+	}
+	header, data, found := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return
+	}
+	mimeType = strings.TrimSuffix(header, ";base64")
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return "", "", false
+	}
+	ok = "" != mimeType
+	return
+}
+
+// aiToolArguments 把工具调用参数解析为对象，参数为空或非法时返回空对象。
+func aiToolArguments(arguments string) map[string]any {
+	ret := map[string]any{}
+	if "" != strings.TrimSpace(arguments) {
+		json.Unmarshal([]byte(arguments), &ret)
+	}
+	if nil == ret {
+		ret = map[string]any{}
+	}
+	return ret
+}
+
+// aiToolChoice 解析 OpenAI tool_choice，返回模式（auto/none/required/function）和指定的函数名。
+func aiToolChoice(choice any) (mode, name string) {
+	switch v := choice.(type) {
+	case string:
+		return v, ""
+	case map[string]any:
+		if function, ok := v["function"].(map[string]any); ok {
+			name, _ = function["name"].(string)
+			return "function", name
+		}
+	}
+	return "", ""
+}
+
+// aiMaxTokens 返回请求的最大输出 token 数，未设置时返回 0。
+func aiMaxTokens(req *openai.ChatCompletionRequest) int {
+	if 0 < req.MaxCompletionTokens {
+		return req.MaxCompletionTokens
+	}
+	return req.MaxTokens
+}
+
+// aiIncludeUsage 判断流式请求是否要求返回用量统计。
+func aiIncludeUsage(req *openai.ChatCompletionRequest) bool {
+	return nil != req.StreamOptions && req.StreamOptions.IncludeUsage
+}
+
+// aiChatID 生成 OpenAI 格式的响应 ID。
+func aiChatID() string {
+	return "chatcmpl-" + gulu.Rand.String(24)
+}
+
+// aiToolCallID 生成工具调用 ID。
+func aiToolCallID() string {
+	return "call_" + gulu.Rand.String(24)
+}
+
+// aiChatResponse 构造非流式 chat.completion 响应。
+func aiChatResponse(id, model, content, reasoning string, toolCalls []openai.ToolCall, finishReason string, usage openai.Usage) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []openai.ChatCompletionChoice{{
+			Index: 0,
+			Message: openai.ChatCompletionMessage{
+				Role:             openai.ChatMessageRoleAssistant,
+				Content:          content,
+				ReasoningContent: reasoning,
+				ToolCalls:        toolCalls,
+			},
+			FinishReason: openai.FinishReason(finishReason),
+		}},
+		Usage: usage,
+	}
+}
+
+// aiChatChunk 构造流式 chat.completion.chunk，finishReason 为空时表示未结束。
+func aiChatChunk(id, model string, delta openai.ChatCompletionStreamChoiceDelta, finishReason string) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []openai.ChatCompletionStreamChoice{{
+			Index:        0,
+			Delta:        delta,
+			FinishReason: openai.FinishReason(finishReason),
+		}},
+	}
+}
+
+// aiUsageChunk 构造流式响应末尾携带用量统计的 chunk。
+func aiUsageChunk(id, model string, usage openai.Usage) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []openai.ChatCompletionStreamChoice{},
+		Usage:   &usage,
+	}
+}
+
+// aiUsage 构造用量统计，cachedTokens 为 0 时不填充缓存命中明细。
+func aiUsage(promptTokens, completionTokens, cachedTokens int) openai.Usage {
+	ret := openai.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+	if 0 < cachedTokens {
+		ret.PromptTokensDetails = &openai.PromptTokensDetails{CachedTokens: cachedTokens}
+	}
+	return ret
+}
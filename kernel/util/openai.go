@@ -72,15 +72,28 @@ type GeneratedImage struct {
 	MIMEType      string
 	Extension     string
 	RevisedPrompt string
+	Usage         AIUsage
+}
+
+// AIUsage 是一次 AI 调用返回的用量，服务商未返回的字段为 0。
+type AIUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Images           int
+}
+
+func newAIUsage(usage openai.Usage) AIUsage {
+	return AIUsage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens}
 }
 
 type OpenAIImageAdapter struct {
-	client  *openai.Client
-	model   string
-	timeout time.Duration
+	client   *openai.Client
+	protocol string
+	model    string
+	timeout  time.Duration
 }
 
-func ChatGPT(msg string, contextMsgs []string, c *openai.Client, model string, maxTokens int, temperature float64, timeout int) (ret string, stop bool, err error) {
+func ChatGPT(msg string, contextMsgs []string, c *openai.Client, model string, maxTokens int, temperature float64, timeout int) (ret string, stop bool, usage AIUsage, err error) {
 	var reqMsgs []openai.ChatCompletionMessage
 
 	for _, ctxMsg := range contextMsgs {
@@ -121,6 +134,7 @@ func ChatGPT(msg string, contextMsgs []string, c *openai.Client, model string, m
 		stop = true
 		return
 	}
+	usage = newAIUsage(resp.Usage)
 
 	if 1 > len(resp.Choices) {
 		stop = true
@@ -227,15 +241,15 @@ func NewOpenAIClientWithModel(apiKey, apiBaseURL, model string) *openai.Client {
 	return openai.NewClientWithConfig(config)
 }
 
-// TestModel 测试模型可用性。优先调用 ListModels（GET /v1/models）拉取可用模型清单，
+// TestModel 按服务商协议测试模型可用性。优先调用 ListModels（GET /v1/models）拉取可用模型清单，
 // 校验 model 是否在其中；若该端点不可用（部分 OpenAI 兼容服务未实现），则回退到极简 Chat Completion。
 // 返回值：available 为可用模型清单（仅 ListModels 成功时填充），matched 表示 model 是否可用，
 // err 为请求错误（鉴权失败、网络异常、模型不存在等，原样返回便于调用方展示原因）。
-func TestModel(apiKey, apiBaseURL, model string, timeout int) (available []string, matched bool, err error) {
+func TestModel(protocol, apiKey, apiBaseURL, model string, timeout int) (available []string, matched bool, err error) {
 	if 1 > timeout {
 		timeout = 30
 	}
-	client := NewOpenAIClient(apiKey, apiBaseURL)
+	client := NewAIClient(protocol, apiKey, apiBaseURL, model)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
 
@@ -273,11 +287,11 @@ func TestModel(apiKey, apiBaseURL, model string, timeout int) (available []strin
 // TestEmbeddingModel 测试嵌入模型可用性，发送极简文本并返回首个向量维度。
 // 返回值：matched 表示是否连通成功，dimensions 为返回的向量维度（便于核对配置），
 // err 为请求错误（鉴权失败、网络异常、模型不存在等，原样返回便于调用方展示原因）。
-func TestEmbeddingModel(apiKey, apiBaseURL, model string, dimensions, timeout int) (matched bool, dims int, err error) {
+func TestEmbeddingModel(protocol, apiKey, apiBaseURL, model string, dimensions, timeout int) (matched bool, dims int, err error) {
 	if 1 > timeout {
 		timeout = 30
 	}
-	client := NewOpenAIClient(apiKey, apiBaseURL)
+	client := NewAIClient(protocol, apiKey, apiBaseURL, model)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
 
@@ -300,11 +314,11 @@ func TestEmbeddingModel(apiKey, apiBaseURL, model string, dimensions, timeout in
 
 // ListAvailableModels 拉取 Provider 的可用模型清单（GET /v1/models），仅返回模型 ID 列表。
 // 用于填充前端模型名称下拉框。不支持该端点的服务会返回错误，由调用方回退为手动输入。
-func ListAvailableModels(apiKey, apiBaseURL string, timeout int) (models []string, err error) {
+func ListAvailableModels(protocol, apiKey, apiBaseURL string, timeout int) (models []string, err error) {
 	if 1 > timeout {
 		timeout = 30
 	}
-	client := NewOpenAIClient(apiKey, apiBaseURL)
+	client := NewAIClient(protocol, apiKey, apiBaseURL, "")
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
 
@@ -360,15 +374,12 @@ func getEmbeddingHTTPClient() *http.Client {
 	return embeddingHTTPClient
 }
 
-func BatchGetEmbeddings(texts []string, apiKey, baseURL, model string, dimensions, timeout int) (ret [][]float32, err error) {
+func BatchGetEmbeddings(texts []string, protocol, apiKey, baseURL, model string, dimensions, timeout int) (ret [][]float32, usage AIUsage, err error) {
 	if 1 > len(texts) {
 		return
 	}
 
-	config := openai.DefaultConfig(apiKey)
-	config.BaseURL = baseURL
-	config.HTTPClient = getEmbeddingHTTPClient()
-	client := openai.NewClientWithConfig(config)
+	client := newAIClient(protocol, apiKey, baseURL, model, getEmbeddingHTTPClient())
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
@@ -382,6 +393,7 @@ func BatchGetEmbeddings(texts []string, apiKey, baseURL, model string, dimension
 		logging.LogErrorf("create embeddings failed: %s", err)
 		return
 	}
+	usage = newAIUsage(resp.Usage)
 
 	for _, data := range resp.Data {
 		ret = append(ret, data.Embedding)
@@ -424,9 +436,12 @@ type rerankResult struct {
 	RelevanceScore float64 `json:"relevance_score"`
 }
 
-// rerankResponse 对应 /v1/rerank 响应体。
+// rerankResponse 对应 /v1/rerank 响应体。用量字段各服务商不一，Jina/阿里云返回 usage.total_tokens，未返回时为 0。
 type rerankResponse struct {
 	Results []rerankResult `json:"results"`
+	Usage   struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
 }
 
 // Rerank 调用重排服务对 query 与候选文档逐对精排。endpoint 为完整重排端点地址，不同服务商路径无统一标准
@@ -435,7 +450,7 @@ type rerankResponse struct {
 // 对每条 document 文本按 rerankDocTextMaxRunes 截断，防超服务端 token 限制并保证 UTF-8 完整。
 // topN 语义：topN <= 0 时不传 top_n（服务端默认返回全部文档评分，搜索场景用此避免被服务端 top_n 上限截断）；
 // topN > 0 时透传给服务端，仅用于测试连通性等只需少量结果的场景。
-func Rerank(query string, documents []string, apiKey, endpoint, model string, topN, timeout int) (indices []int, scores []float64, err error) {
+func Rerank(query string, documents []string, apiKey, endpoint, model string, topN, timeout int) (indices []int, scores []float64, usage AIUsage, err error) {
 	if 1 > timeout {
 		timeout = 30
 	}
@@ -494,6 +509,7 @@ func Rerank(query string, documents []string, apiKey, endpoint, model string, to
 	if err = json.Unmarshal(respBody, &rr); nil != err {
 		return
 	}
+	usage.PromptTokens = rr.Usage.TotalTokens
 
 	for _, r := range rr.Results {
 		if r.Index < 0 || r.Index >= len(documents) {
@@ -517,7 +533,7 @@ func truncateRerankDocument(document string) string {
 // 返回值：matched 表示是否连通成功，err 为请求错误（鉴权失败、网络异常、模型不存在等，原样返回便于调用方展示原因）。
 func TestRerankModel(apiKey, apiBaseURL, model string, timeout int) (matched bool, err error) {
 	documents := []string{"a", "b"}
-	indices, _, err := Rerank("1", documents, apiKey, apiBaseURL, model, len(documents), timeout)
+	indices, _, _, err := Rerank("1", documents, apiKey, apiBaseURL, model, len(documents), timeout)
 	if nil != err {
 		return
 	}
@@ -640,17 +656,23 @@ func ValidateGeneratedImage(data []byte) (mimeType, extension string, err error)
 }
 
 func NewOpenAIImageAdapter(apiKey, apiBaseURL, model string, timeout int) *OpenAIImageAdapter {
+	return NewImageAdapter(AIProtocolOpenAI, apiKey, apiBaseURL, model, timeout)
+}
+
+// NewImageAdapter 按服务商协议创建图片适配器。图片理解支持所有协议，图片生成仅支持 OpenAI 协议。
+func NewImageAdapter(protocol, apiKey, apiBaseURL, model string, timeout int) *OpenAIImageAdapter {
 	if timeout < 1 {
 		timeout = 30
 	}
 	return &OpenAIImageAdapter{
-		client:  NewOpenAIClientWithModel(apiKey, apiBaseURL, model),
-		model:   model,
-		timeout: time.Duration(timeout) * time.Second,
+		client:   NewAIClient(protocol, apiKey, apiBaseURL, model),
+		protocol: protocol,
+		model:    model,
+		timeout:  time.Duration(timeout) * time.Second,
 	}
 }
 
-func (adapter *OpenAIImageAdapter) Analyze(ctx context.Context, image PreparedImage, question, detail string) (string, error) {
+func (adapter *OpenAIImageAdapter) Analyze(ctx context.Context, image PreparedImage, question, detail string) (string, AIUsage, error) {
 	if question == "" {
 		question = "Describe the image accurately and extract any visible text relevant to the user's task."
 	}
@@ -679,23 +701,27 @@ func (adapter *OpenAIImageAdapter) Analyze(ctx context.Context, image PreparedIm
 		MaxCompletionTokens: imageAnalysisMaxTokens,
 	})
 	if err != nil {
-		return "", err
+		return "", AIUsage{}, err
 	}
+	usage := newAIUsage(response.Usage)
 	if len(response.Choices) == 0 {
-		return "", errors.New("vision model returned an empty response")
+		return "", usage, errors.New("vision model returned an empty response")
 	}
 	choice := response.Choices[0]
 	if choice.FinishReason == openai.FinishReasonLength {
-		return "", errors.New("vision model response was truncated")
+		return "", usage, errors.New("vision model response was truncated")
 	}
 	content := strings.TrimSpace(choice.Message.Content)
 	if content == "" {
-		return "", errors.New("vision model returned an empty response")
+		return "", usage, errors.New("vision model returned an empty response")
 	}
-	return content, nil
+	return content, usage, nil
 }
 
 func (adapter *OpenAIImageAdapter) Generate(ctx context.Context, request GenerateImageRequest) (GeneratedImage, error) {
+	if adapter.protocol != "" && adapter.protocol != AIProtocolOpenAI {
+		return GeneratedImage{}, fmt.Errorf("image generation is not supported by the %s protocol", adapter.protocol)
+	}
 	if strings.TrimSpace(request.Prompt) == "" {
 		return GeneratedImage{}, errors.New("image prompt is required")
 	}
@@ -739,7 +765,8 @@ func (adapter *OpenAIImageAdapter) Generate(ctx context.Context, request Generat
 	if err != nil {
 		return GeneratedImage{}, err
 	}
-	return GeneratedImage{Data: data, MIMEType: mimeType, Extension: extension, RevisedPrompt: result.RevisedPrompt}, nil
+	usage := AIUsage{PromptTokens: response.Usage.InputTokens, CompletionTokens: response.Usage.OutputTokens, Images: 1}
+	return GeneratedImage{Data: data, MIMEType: mimeType, Extension: extension, RevisedPrompt: result.RevisedPrompt, Usage: usage}, nil
 }
 
 func downloadGeneratedImage(ctx context.Context, rawURL string) ([]byte, error) {
@@ -57,7 +57,7 @@ func TestKeylessModelFallsBackToChatCompletion(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_, matched, err := TestModel("", server.URL+"/v1", "test-model", 5)
+	_, matched, err := TestModel(AIProtocolOpenAI, "", server.URL+"/v1", "test-model", 5)
 	if err != nil || !matched || chatRequests.Load() != 1 {
 		t.Fatalf("unexpected model test result: matched=%v requests=%d err=%v", matched, chatRequests.Load(), err)
 	}
@@ -112,7 +112,7 @@ func TestOpenAIImageAdapterAnalyzeAndGenerate(t *testing.T) {
 				t.Errorf("vision request is not task-generic: %s", encoded)
 			}
 			w.Header().Set("Content-Type", "application/json")
-			_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"a diagram"}}]}`))
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"a diagram"}}],"usage":{"prompt_tokens":120,"completion_tokens":8}}`))
 		case "/v1/images/generations":
 			w.Header().Set("Content-Type", "application/json")
 			_, _ = w.Write([]byte(`{"data":[{"b64_json":"` + base64.StdEncoding.EncodeToString(generatedBytes) + `","revised_prompt":"refined"}]}`))
@@ -123,15 +123,18 @@ func TestOpenAIImageAdapterAnalyzeAndGenerate(t *testing.T) {
 	defer server.Close()
 
 	adapter := NewOpenAIImageAdapter("test", server.URL+"/v1", "test-model", 5)
-	analysis, err := adapter.Analyze(context.Background(), PreparedImage{Data: []byte("jpeg"), MIMEType: "image/jpeg"}, "What is shown?", "high")
+	analysis, usage, err := adapter.Analyze(context.Background(), PreparedImage{Data: []byte("jpeg"), MIMEType: "image/jpeg"}, "What is shown?", "high")
 	if err != nil || analysis != "a diagram" {
 		t.Fatalf("unexpected analysis %q: %v", analysis, err)
 	}
+	if usage.PromptTokens != 120 || usage.CompletionTokens != 8 {
+		t.Fatalf("unexpected analysis usage: %#v", usage)
+	}
 	generated, err := adapter.Generate(context.Background(), GenerateImageRequest{Prompt: "A header", Size: "1024x1024", OutputFormat: "png"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if generated.MIMEType != "image/png" || generated.Extension != ".png" || generated.RevisedPrompt != "refined" {
+	if generated.MIMEType != "image/png" || generated.Extension != ".png" || generated.RevisedPrompt != "refined" || generated.Usage.Images != 1 {
 		t.Fatalf("unexpected generated image metadata: %#v", generated)
 	}
 }
@@ -144,7 +147,7 @@ func TestOpenAIImageAdapterRejectsTruncatedAnalysis(t *testing.T) {
 	defer server.Close()
 
 	adapter := NewOpenAIImageAdapter("test", server.URL+"/v1", "test-model", 5)
-	_, err := adapter.Analyze(context.Background(), PreparedImage{Data: []byte("jpeg"), MIMEType: "image/jpeg"}, "Describe", "low")
+	_, _, err := adapter.Analyze(context.Background(), PreparedImage{Data: []byte("jpeg"), MIMEType: "image/jpeg"}, "Describe", "low")
 	if err == nil || !strings.Contains(err.Error(), "truncated") {
 		t.Fatalf("unexpected truncated analysis error: %v", err)
 	}
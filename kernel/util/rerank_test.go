@@ -66,15 +66,18 @@ func TestRerankTruncatesDocumentsByRunes(t *testing.T) {
 			t.Fatalf("decode request failed: %v", err)
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{"results":[{"index":0,"relevance_score":1}]}`))
+		_, _ = w.Write([]byte(`{"results":[{"index":0,"relevance_score":1}],"usage":{"total_tokens":42}}`))
 	}))
 	defer server.Close()
 
 	document := strings.Repeat("中", rerankDocTextMaxRunes+1)
-	indices, _, err := Rerank("query", []string{document}, "key", server.URL, "model", 1, 5)
+	indices, _, usage, err := Rerank("query", []string{document}, "key", server.URL, "model", 1, 5)
 	if nil != err {
 		t.Fatalf("Rerank failed: %v", err)
 	}
+	if usage.PromptTokens != 42 {
+		t.Fatalf("usage = %#v, want 42 prompt tokens", usage)
+	}
 	if len(indices) != 1 || indices[0] != 0 {
 		t.Fatalf("unexpected indices: %v", indices)
 	}
@@ -506,6 +506,17 @@ const (
 
 	EvtSQLHistoryRebuild      = "sql.history.rebuild"
 	EvtSQLAssetContentRebuild = "sql.assetContent.rebuild"
+
+	EvtTxCommitted = "tx.committed" // 事务提交，参数为变更的文档根块 ID、块 ID 和属性视图 ID
+
+	EvtDocCreated    = "doc.created"     // 创建文档，参数为笔记本 ID、文档路径和文档 ID
+	EvtDocRenamed    = "doc.renamed"     // 重命名文档，参数为笔记本 ID、文档路径、文档 ID 和新标题
+	EvtDocMoved      = "doc.moved"       // 移动文档，参数为原笔记本 ID、原路径、目标笔记本 ID、新路径和文档 ID
+	EvtDocRemoved    = "doc.removed"     // 删除文档，参数为笔记本 ID、文档路径、文档 ID、人类可读路径和被删除的文档 ID（含子文档）
+	EvtAvCellUpdated = "av.cell.updated" // 更新属性视图单元格，参数为属性视图 ID、字段 ID、条目 ID、绑定的块 ID 和单元格值
+
+	EvtDailyNoteCreated  = "dailynote.created"   // 新建日记，参数为笔记本 ID、文档路径和文档 ID
+	EvtBlockAttrsUpdated = "block.attrs.updated" // 更新块属性，参数为块 ID、旧属性和新属性
 )
 
 var SearchCaseSensitive bool